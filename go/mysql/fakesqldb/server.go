@@ -106,6 +106,8 @@ type DB struct {
 	rejectedData map[string]error
 	// patternData is a map of regexp queries to results.
 	patternData map[string]exprResult
+	// rejectedPatternsTimes are the query patterns rejected the next times their queries are called.
+	rejectedPatternsTimes []*rejectedPatternTimes
 	// queryCalled keeps track of how many times a query was called.
 	queryCalled map[string]int
 	// querylog keeps track of all called queries
@@ -149,6 +151,12 @@ type exprResult struct {
 	err          string
 }
 
+type rejectedPatternTimes struct {
+	expr  *regexp.Regexp
+	err   error
+	times int
+}
+
 // ExpectedExecuteFetch defines for an expected query the to be faked output.
 // It is used for ordered expected output.
 type ExpectedExecuteFetch struct {
@@ -412,6 +420,14 @@ func (db *DB) HandleQuery(c *mysql.Conn, query string, callback func(*sqltypes.R
 		return callback(result.Result)
 	}
 
+	// Check query patterns from RejectQueryPatternTimes().
+	for _, pat := range db.rejectedPatternsTimes {
+		if pat.times > 0 && pat.expr.MatchString(query) {
+			pat.times--
+			return pat.err
+		}
+	}
+
 	// Check query patterns from AddQueryPattern().
 	for _, pat := range db.patternData {
 		if pat.expr.MatchString(query) {
@@ -585,6 +601,15 @@ func (db *DB) RejectQueryPattern(queryPattern, error string) {
 	db.patternData[queryPattern] = exprResult{queryPattern: queryPattern, expr: expr, err: error}
 }
 
+// RejectQueryPatternTimes rejects the queries matching queryPattern with err the next times times,
+// the queries are handled as usual afterwards. err is returned as is, so a *mysql.SQLError keeps its code.
+func (db *DB) RejectQueryPatternTimes(queryPattern string, err error, times int) {
+	expr := regexp.MustCompile("(?is)^" + queryPattern + "$")
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	db.rejectedPatternsTimes = append(db.rejectedPatternsTimes, &rejectedPatternTimes{expr: expr, err: err, times: times})
+}
+
 // ClearQueryPattern removes all query patterns set up
 func (db *DB) ClearQueryPattern() {
 	db.patternData = make(map[string]exprResult)
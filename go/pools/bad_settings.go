@@ -0,0 +1,103 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package pools
+
+import (
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/sync2"
+)
+
+type (
+	// badSettingCache remembers the settings that failed to be applied,
+	// so that the Get calls with one of them fail fast until it expires.
+	badSettingCache struct {
+		// ttl is how long a failed setting is remembered, 0 disables the cache.
+		ttl sync2.AtomicDuration
+
+		// mu protects settings.
+		mu       sync.Mutex
+		settings map[string]badSetting
+	}
+
+	badSetting struct {
+		err      error
+		expireAt time.Time
+	}
+)
+
+func newBadSettingCache() *badSettingCache {
+	return &badSettingCache{settings: make(map[string]badSetting)}
+}
+
+// SetBadSettingTTL makes the pool remember the settings that failed to be applied for ttl,
+// if MySQL rejected the setting itself, see isBadSettingError:
+// within ttl, a Get with the same setting returns the error of ApplySetting right away,
+// instead of taking a resource and failing to apply the setting again.
+// This includes the Get calls that could reuse a resource which already has the setting applied.
+// A ttl of 0 (default) disables the cache.
+func (rp *ResourcePool) SetBadSettingTTL(ttl time.Duration) {
+	bs := rp.badSettings
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bs.ttl.Set(ttl)
+	if ttl == 0 {
+		bs.settings = make(map[string]badSetting)
+	}
+}
+
+// check returns the error of setting if it failed to be applied within the ttl.
+func (bs *badSettingCache) check(setting *Setting) error {
+	if bs.ttl.Get() == 0 {
+		return nil
+	}
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	bad, ok := bs.settings[setting.query]
+	if !ok {
+		return nil
+	}
+	if time.Now().After(bad.expireAt) {
+		delete(bs.settings, setting.query)
+		return nil
+	}
+	return bad.err
+}
+
+// record remembers that setting failed to be applied with err.
+func (bs *badSettingCache) record(setting *Setting, err error) {
+	ttl := bs.ttl.Get()
+	if ttl == 0 {
+		return
+	}
+	now := time.Now()
+	bs.mu.Lock()
+	defer bs.mu.Unlock()
+	// the expired settings which are never used again are dropped here, so the map doesn't grow forever
+	for query, bad := range bs.settings {
+		if now.After(bad.expireAt) {
+			delete(bs.settings, query)
+		}
+	}
+	bs.settings[setting.query] = badSetting{err: err, expireAt: now.Add(ttl)}
+}
+
+// isBadSettingError returns true if err rejects the setting itself, e.g. an unknown variable or a wrong value,
+// so that applying it again would fail the same way. The other errors, e.g. a lost connection, are transient.
+func isBadSettingError(err error) bool {
+	sqlErr, ok := mysql.NewSQLErrorFromError(err).(*mysql.SQLError)
+	if !ok {
+		return false
+	}
+	switch sqlErr.Number() {
+	case mysql.ERUnknownSystemVariable, mysql.ERWrongValueForVar, mysql.ERWrongTypeForVar,
+		mysql.ERIncorrectGlobalLocalVar, mysql.ERGlobalVariable, mysql.ERLocalVariable:
+		return true
+	}
+	return false
+}
@@ -0,0 +1,93 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package pools
+
+import (
+	"context"
+	"sync"
+
+	"vitess.io/vitess/go/sync2"
+)
+
+type (
+	labelKey struct{}
+
+	// labelTracker counts the in-use resources by the label of the Get call that obtained them.
+	labelTracker struct {
+		// inUse is the number of labeled resources in use, it lets Put skip the lookup when there's none.
+		inUse sync2.AtomicInt64
+
+		// mu protects labels and counts.
+		mu     sync.Mutex
+		labels map[Resource]string
+		counts map[string]int64
+	}
+)
+
+// WithLabel returns a copy of ctx that tags the resources obtained with it from a ResourcePool
+// with label, e.g. the name of the subsystem using them. The in-use resources are then counted
+// by label, which tells which subsystem holds the resources when the pool is exhausted.
+func WithLabel(ctx context.Context, label string) context.Context {
+	return context.WithValue(ctx, labelKey{}, label)
+}
+
+// labelFromContext returns the label set by WithLabel, empty if there is none.
+func labelFromContext(ctx context.Context) string {
+	label, _ := ctx.Value(labelKey{}).(string)
+	return label
+}
+
+func newLabelTracker() *labelTracker {
+	return &labelTracker{
+		labels: make(map[Resource]string),
+		counts: make(map[string]int64),
+	}
+}
+
+func (lt *labelTracker) track(resource Resource, label string) {
+	if label == "" {
+		return
+	}
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	lt.labels[resource] = label
+	lt.counts[label]++
+	lt.inUse.Add(1)
+}
+
+func (lt *labelTracker) untrack(resource Resource) {
+	if resource == nil || lt.inUse.Get() == 0 {
+		return
+	}
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	label, ok := lt.labels[resource]
+	if !ok {
+		return
+	}
+	delete(lt.labels, resource)
+	if lt.counts[label]--; lt.counts[label] == 0 {
+		delete(lt.counts, label)
+	}
+	lt.inUse.Add(-1)
+}
+
+// inUseByLabel returns a copy of the counts of the in-use resources by label.
+func (lt *labelTracker) inUseByLabel() map[string]int64 {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	counts := make(map[string]int64, len(lt.counts))
+	for label, count := range lt.counts {
+		counts[label] = count
+	}
+	return counts
+}
+
+// InUseByLabel returns the number of resources in use by label, for the resources
+// obtained by Get with a context tagged by WithLabel.
+func (rp *ResourcePool) InUseByLabel() map[string]int64 {
+	return rp.labels.inUseByLabel()
+}
@@ -0,0 +1,146 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package pools
+
+import (
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/sync2"
+	"vitess.io/vitess/go/timer"
+	"vitess.io/vitess/go/vt/logutil"
+)
+
+type (
+	// leakDetector records when each resource is handed out by Get, and periodically
+	// logs the resources that are held for longer than a threshold without being returned.
+	leakDetector struct {
+		name      string
+		threshold sync2.AtomicDuration
+		timer     *timer.Timer
+		logf      func(format string, args ...any)
+
+		// mu protects captureStack and held.
+		mu           sync.Mutex
+		captureStack bool
+		held         map[Resource]heldResource
+	}
+
+	heldResource struct {
+		since time.Time
+		// stack is the stack of the Get call, it's only captured when enabled because of the cost.
+		stack []byte
+	}
+)
+
+func newLeakDetector(name string) *leakDetector {
+	return &leakDetector{
+		name:  name,
+		timer: timer.NewTimer(0),
+		logf:  logutil.NewThrottledLogger("ResourcePoolLeak"+name, time.Minute).Warningf,
+	}
+}
+
+// SetLeakThreshold enables the detection of leaked resources. Resources obtained by Get
+// and not returned within threshold are logged periodically. If captureStack is true,
+// the stack of the Get call is captured and logged too, which is useful for debugging
+// but costly. A threshold of 0 disables the detection.
+// The resources replaced with Put(nil) can't be matched with their Get, Replace has to be used instead.
+func (rp *ResourcePool) SetLeakThreshold(threshold time.Duration, captureStack bool) {
+	ld := rp.leaks
+	ld.mu.Lock()
+	ld.captureStack = captureStack
+	if threshold > 0 && ld.threshold.Get() == 0 {
+		ld.held = make(map[Resource]heldResource)
+	} else if threshold == 0 {
+		ld.held = nil
+	}
+	ld.threshold.Set(threshold)
+	ld.mu.Unlock()
+
+	// The timer can't be used with mu held, the check it runs acquires mu.
+	if threshold == 0 {
+		ld.timer.Stop()
+		return
+	}
+	ld.timer.SetInterval(threshold / 10)
+	ld.start()
+}
+
+// LeakThreshold returns the threshold after which a resource that is not returned is logged.
+func (rp *ResourcePool) LeakThreshold() time.Duration {
+	return rp.leaks.threshold.Get()
+}
+
+func (ld *leakDetector) start() {
+	if ld.threshold.Get() == 0 {
+		return
+	}
+	ld.timer.Start(ld.check)
+}
+
+func (ld *leakDetector) stop() {
+	ld.timer.Stop()
+}
+
+func (ld *leakDetector) track(resource Resource) {
+	if ld.threshold.Get() == 0 {
+		return
+	}
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+	if ld.held == nil {
+		return
+	}
+	held := heldResource{since: time.Now()}
+	if ld.captureStack {
+		held.stack = debug.Stack()
+	}
+	ld.held[resource] = held
+}
+
+func (ld *leakDetector) untrack(resource Resource) {
+	if resource == nil || ld.threshold.Get() == 0 {
+		return
+	}
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+	delete(ld.held, resource)
+}
+
+// check logs the resources held for longer than the threshold, along with the stack
+// of the Get call of the oldest one if it was captured.
+func (ld *leakDetector) check() {
+	threshold := ld.threshold.Get()
+	if threshold == 0 {
+		return
+	}
+	ld.mu.Lock()
+	defer ld.mu.Unlock()
+
+	var leaked int
+	var oldest heldResource
+	for _, held := range ld.held {
+		if time.Since(held.since) < threshold {
+			continue
+		}
+		if leaked == 0 || held.since.Before(oldest.since) {
+			oldest = held
+		}
+		leaked++
+	}
+	if leaked == 0 {
+		return
+	}
+	if oldest.stack == nil {
+		ld.logf("resource pool %s: %d resource(s) held for longer than %v without being returned, the oldest one for %v",
+			ld.name, leaked, threshold, time.Since(oldest.since))
+		return
+	}
+	ld.logf("resource pool %s: %d resource(s) held for longer than %v without being returned, the oldest one for %v, acquired at:\n%s",
+		ld.name, leaked, threshold, time.Since(oldest.since), oldest.stack)
+}
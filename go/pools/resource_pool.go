@@ -25,6 +25,7 @@ package pools
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math/rand"
@@ -47,6 +48,10 @@ type (
 		// It releases all resources and prevents any further Get operations.
 		Close()
 
+		// CloseWithDeadline shuts down the resource pool like Close, but only waits for
+		// the resources in use until ctx is done. The ones still in use are then discarded.
+		CloseWithDeadline(ctx context.Context) error
+
 		// Name returns the name of the resource pool.
 		// This is useful for identification and logging purposes.
 		Name() string
@@ -60,8 +65,18 @@ type (
 		// Put returns a resource to the pool.
 		// Every successful Get call should be paired with a corresponding Put call.
 		// Passing nil will signal that the resource is no longer needed and should be replaced.
+		// Broken resources should be returned with Discard instead.
 		Put(resource Resource)
 
+		// Replace creates a new resource in place of a resource obtained via Get that isn't returned,
+		// e.g. because the caller closed it or took it over. Unlike Discard, it doesn't close it.
+		// It's preferred to Put(nil), which doesn't tell which resource is replaced.
+		Replace(resource Resource)
+
+		// Discard closes a broken resource obtained via Get and replaces it with a new one.
+		// It must be used instead of Put for that resource.
+		Discard(resource Resource)
+
 		// SetCapacity adjusts the capacity of the resource pool.
 		// It allows resizing the pool within the bounds of the maximum capacity.
 		// If reducing capacity, it waits for resources to be returned before closing excess ones.
@@ -92,6 +107,10 @@ type (
 		// These resources have been acquired via Get and not yet returned via Put.
 		InUse() int64
 
+		// InUseByLabel returns the number of resources currently being used by label,
+		// for the resources acquired via Get with a context tagged by WithLabel.
+		InUseByLabel() map[string]int64
+
 		// MaxCap returns the maximum capacity of the resource pool.
 		// This is the upper limit to which the pool can be resized.
 		MaxCap() int64
@@ -136,6 +155,15 @@ type (
 
 		// CloseIdleResources scans the pool for idle resources and closes them.
 		CloseIdleResources(max int) int
+
+		// SetLowWatermark sets the number of available resources below which ShouldAdmit returns false.
+		// A low watermark of 0 disables the check.
+		SetLowWatermark(lowWatermark int64)
+
+		// ShouldAdmit reports whether the number of available resources is at or above the low watermark.
+		// It is advisory: callers can use it to shed low-priority load instead of waiting in Get,
+		// and Get itself never consults it.
+		ShouldAdmit() bool
 	}
 
 	// Resource defines the interface that every resource must provide.
@@ -177,10 +205,15 @@ type (
 		maxLifetimeClosed sync2.AtomicInt64
 		exhausted         sync2.AtomicInt64
 
-		capacity    sync2.AtomicInt64
-		idleTimeout sync2.AtomicDuration
-		maxLifetime sync2.AtomicDuration
+		capacity     sync2.AtomicInt64
+		idleTimeout  sync2.AtomicDuration
+		maxLifetime  sync2.AtomicDuration
+		lowWatermark sync2.AtomicInt64
+		// closing makes Get fail with ErrClosed while CloseWithDeadline drains the pool.
+		closing sync2.AtomicBool
 
+		// name identifies the pool, e.g. in logs and in the stats exported by its owner.
+		name      string
 		resources chan resourceWrapper
 		factory   Factory
 		idleTimer *timer.Timer
@@ -194,6 +227,27 @@ type (
 
 		reopenMutex sync.Mutex
 		refresh     *poolRefresh
+
+		// leaks tracks the resources handed out by Get to detect the ones that are never returned.
+		leaks *leakDetector
+		// labels counts the resources handed out by Get by the label of their context.
+		labels *labelTracker
+		// badSettings remembers the settings that failed to be applied, see SetBadSettingTTL.
+		badSettings *badSettingCache
+
+		// ctxMutex protects ctx and cancel.
+		ctxMutex sync.Mutex
+		// ctx is used to recreate resources outside of Get, it's canceled by Close
+		// so that a recreation blocked on an unreachable backend doesn't stall Close.
+		ctx    context.Context
+		cancel context.CancelFunc
+
+		// forceCloseMu protects forceClosed. It's held for reading while a resource is returned
+		// to the pool, so that CloseWithDeadline doesn't close the channels meanwhile.
+		forceCloseMu sync.RWMutex
+		// forceClosed is set once CloseWithDeadline gave up waiting for the resources in use,
+		// they are closed when they are returned.
+		forceClosed bool
 	}
 )
 
@@ -254,11 +308,12 @@ func (s *Setting) SetResetQuery(resetQuery string) {
 // The value specifies how many resources can be opened in parallel.
 // refreshCheck is a function we consult at refreshInterval
 // intervals to determine if the pool should be drained and reopened
-func NewResourcePool(factory Factory, capacity, maxCap int, idleTimeout time.Duration, maxLifetime time.Duration, logWait func(time.Time), refreshCheck RefreshCheck, refreshInterval time.Duration) *ResourcePool {
+func NewResourcePool(name string, factory Factory, capacity, maxCap int, idleTimeout time.Duration, maxLifetime time.Duration, logWait func(time.Time), refreshCheck RefreshCheck, refreshInterval time.Duration) *ResourcePool {
 	if capacity <= 0 || maxCap <= 0 || capacity > maxCap {
 		panic(errors.New("invalid/out of range capacity"))
 	}
 	rp := &ResourcePool{
+		name:             name,
 		resources:        make(chan resourceWrapper, maxCap),
 		settingResources: make(chan resourceWrapper, maxCap),
 		factory:          factory,
@@ -268,23 +323,29 @@ func NewResourcePool(factory Factory, capacity, maxCap int, idleTimeout time.Dur
 		maxLifetime:      sync2.NewAtomicDuration(maxLifetime),
 		logWait:          logWait,
 	}
+	rp.ctx, rp.cancel = context.WithCancel(context.Background())
 	for i := 0; i < capacity; i++ {
 		rp.resources <- resourceWrapper{}
 	}
 
 	if idleTimeout != 0 {
 		rp.idleTimer = timer.NewTimer(idleTimeout / 10)
+		rp.idleTimer.SetJitter(idleTimerJitter(idleTimeout))
 		rp.idleTimer.Start(rp.closeIdleResources)
 	}
 
 	rp.refresh = newPoolRefresh(rp, refreshCheck, refreshInterval)
 	rp.refresh.startRefreshTicker()
+	rp.leaks = newLeakDetector(name)
+	rp.labels = newLabelTracker()
+	rp.badSettings = newBadSettingCache()
 
 	return rp
 }
 
+// Name returns the name the pool was created with.
 func (rp *ResourcePool) Name() string {
-	return "ResourcePool"
+	return rp.name
 }
 
 // Close empties the pool calling Close on all its resources.
@@ -292,13 +353,113 @@ func (rp *ResourcePool) Name() string {
 // It waits for all resources to be returned (Put).
 // After a Close, Get is not allowed.
 func (rp *ResourcePool) Close() {
+	// Cancel the recreation of resources first, the idle closer may be blocked in it.
+	rp.ctxMutex.Lock()
+	rp.cancel()
+	rp.ctxMutex.Unlock()
 	if rp.idleTimer != nil {
 		rp.idleTimer.Stop()
 	}
 	rp.refresh.stop()
+	rp.leaks.stop()
 	_ = rp.SetCapacity(0)
 }
 
+// CloseWithDeadline empties the pool calling Close on all its resources, like Close,
+// but it only waits for the resources in use to be returned until ctx is done.
+// Get fails with ErrClosed as soon as it's called. When ctx is done, the pool is closed
+// anyway and the resources still in use are discarded: Put and Discard close them
+// instead of returning them to the pool. An error is returned in that case.
+// The pool must not be reopened while some discarded resources are still in use.
+func (rp *ResourcePool) CloseWithDeadline(ctx context.Context) error {
+	rp.closing.Set(true)
+	rp.ctxMutex.Lock()
+	rp.cancel()
+	rp.ctxMutex.Unlock()
+	if rp.idleTimer != nil {
+		rp.idleTimer.Stop()
+	}
+	rp.refresh.stop()
+	rp.leaks.stop()
+
+	var oldcap int
+	for {
+		oldcap = int(rp.capacity.Get())
+		if oldcap == 0 {
+			return nil
+		}
+		if rp.capacity.CompareAndSwap(int64(oldcap), 0) {
+			break
+		}
+	}
+
+	for drained := 0; drained < oldcap; drained++ {
+		var wrapper resourceWrapper
+		select {
+		case wrapper = <-rp.resources:
+		case wrapper = <-rp.settingResources:
+		case <-ctx.Done():
+			return rp.forceClose(ctx, oldcap-drained)
+		}
+		rp.closeSlot(wrapper)
+	}
+	close(rp.resources)
+	close(rp.settingResources)
+	return nil
+}
+
+// forceClose closes the pool while remaining resources are still in use.
+func (rp *ResourcePool) forceClose(ctx context.Context, remaining int) error {
+	rp.forceCloseMu.Lock()
+	defer rp.forceCloseMu.Unlock()
+	rp.forceClosed = true
+
+	// close the resources returned since the deadline.
+	for drained := true; drained; {
+		select {
+		case wrapper := <-rp.resources:
+			rp.closeSlot(wrapper)
+			remaining--
+		case wrapper := <-rp.settingResources:
+			rp.closeSlot(wrapper)
+			remaining--
+		default:
+			drained = false
+		}
+	}
+	close(rp.resources)
+	close(rp.settingResources)
+	if remaining == 0 {
+		return nil
+	}
+	log.Warningf("Resource pool %s force-closed with %d resource(s) in use: %v", rp.name, remaining, ctx.Err())
+	return fmt.Errorf("resource pool %s closed with %d resource(s) in use, they are discarded when returned: %w", rp.name, remaining, ctx.Err())
+}
+
+// closeSlot closes the resource of a slot taken out of the pool.
+func (rp *ResourcePool) closeSlot(wrapper resourceWrapper) {
+	if wrapper.resource != nil {
+		wrapper.resource.Close()
+		rp.active.Add(-1)
+	}
+	rp.available.Add(-1)
+}
+
+// returnSlot returns a slot that Get failed to hand out, unless the pool
+// has been force-closed meanwhile, in which case its resource is closed.
+func (rp *ResourcePool) returnSlot(wrapper resourceWrapper) {
+	rp.forceCloseMu.RLock()
+	defer rp.forceCloseMu.RUnlock()
+	if rp.forceClosed {
+		if wrapper.resource != nil {
+			wrapper.resource.Close()
+			rp.active.Add(-1)
+		}
+		return
+	}
+	rp.resources <- wrapper
+}
+
 // closeIdleResources scans the pool for idle resources
 func (rp *ResourcePool) closeIdleResources() {
 	rp.CloseIdleResources(int(rp.MaxCap()))
@@ -353,13 +514,14 @@ func (rp *ResourcePool) reopen() {
 	rp.reopenMutex.Lock() // Avoid race, since we can refresh asynchronously
 	defer rp.reopenMutex.Unlock()
 	capacity := int(rp.capacity.Get())
-	log.Infof("Draining and reopening resource pool with capacity %d by request", capacity)
+	log.Infof("Draining and reopening resource pool %s with capacity %d by request", rp.name, capacity)
 	rp.Close()
 	_ = rp.SetCapacity(capacity)
 	if rp.idleTimer != nil {
 		rp.idleTimer.Start(rp.closeIdleResources)
 	}
 	rp.refresh.startRefreshTicker()
+	rp.leaks.start()
 }
 
 // Get will return the next available resource. If capacity
@@ -371,6 +533,9 @@ func (rp *ResourcePool) Get(ctx context.Context, setting *Setting) (resource Res
 	if ctx.Err() != nil {
 		return nil, ErrCtxTimeout
 	}
+	if rp.closing.Get() {
+		return nil, ErrClosed
+	}
 	if setting == nil || setting.GetQuery() == "" {
 		return rp.get(ctx)
 	}
@@ -424,7 +589,7 @@ func (rp *ResourcePool) get(ctx context.Context) (resource Resource, err error)
 	if wrapper.resource == nil {
 		wrapper.resource, err = rp.factory(ctx)
 		if err != nil {
-			rp.resources <- resourceWrapper{}
+			rp.returnSlot(resourceWrapper{})
 			return nil, err
 		}
 		rp.active.Add(1)
@@ -433,11 +598,16 @@ func (rp *ResourcePool) get(ctx context.Context) (resource Resource, err error)
 		rp.exhausted.Add(1)
 	}
 	rp.inUse.Add(1)
+	rp.leaks.track(wrapper.resource)
+	rp.labels.track(wrapper.resource, labelFromContext(ctx))
 	return wrapper.resource, err
 }
 
 func (rp *ResourcePool) getWithSettings(ctx context.Context, setting *Setting) (Resource, error) {
 	rp.getSettingCount.Add(1)
+	if err := rp.badSettings.check(setting); err != nil {
+		return nil, err
+	}
 	var wrapper resourceWrapper
 	var ok bool
 	var err error
@@ -482,7 +652,7 @@ func (rp *ResourcePool) getWithSettings(ctx context.Context, setting *Setting) (
 	if wrapper.resource == nil {
 		wrapper.resource, err = rp.factory(ctx)
 		if err != nil {
-			rp.resources <- resourceWrapper{}
+			rp.returnSlot(resourceWrapper{})
 			return nil, err
 		}
 		rp.active.Add(1)
@@ -491,8 +661,11 @@ func (rp *ResourcePool) getWithSettings(ctx context.Context, setting *Setting) (
 	if !wrapper.resource.IsSettingApplied() {
 		if err = wrapper.resource.ApplySetting(ctx, setting); err != nil {
 			// as we are not able to apply setting, we can return this connection to non-setting channel.
-			// TODO: may check the error code to see if it is recoverable or not.
-			rp.resources <- wrapper
+			// Only the settings rejected by MySQL are remembered, a transient error may not happen again.
+			if isBadSettingError(err) {
+				rp.badSettings.record(setting, err)
+			}
+			rp.returnSlot(wrapper)
 			return nil, err
 		}
 	}
@@ -501,16 +674,21 @@ func (rp *ResourcePool) getWithSettings(ctx context.Context, setting *Setting) (
 		rp.exhausted.Add(1)
 	}
 	rp.inUse.Add(1)
+	rp.leaks.track(wrapper.resource)
+	rp.labels.track(wrapper.resource, labelFromContext(ctx))
 	return wrapper.resource, err
 }
 
 // Put will return a resource to the pool. For every successful Get,
 // a corresponding Put is required. If you no longer need a resource,
-// you will need to call Put(nil) instead of returning the closed resource.
+// you will need to call Replace instead of returning the closed resource.
 // This will cause a new resource to be created in its place.
+// If the resource is broken (e.g. a hung connection that was killed),
+// prefer Discard, which also closes it.
 func (rp *ResourcePool) Put(resource Resource) {
+	rp.leaks.untrack(resource)
+	rp.labels.untrack(resource)
 	var wrapper resourceWrapper
-	var recreated bool
 	var hasSettings bool
 	if resource != nil {
 		wrapper = resourceWrapper{
@@ -527,27 +705,72 @@ func (rp *ResourcePool) Put(resource Resource) {
 	if resource == nil {
 		// Create new resource
 		rp.reopenResource(&wrapper)
-		recreated = true
+		hasSettings = false
 	}
-	if !hasSettings || recreated {
-		select {
-		case rp.resources <- wrapper:
-		default:
-			panic(errors.New("attempt to Put into a full ResourcePool"))
-		}
-	} else {
-		select {
-		case rp.settingResources <- wrapper:
-		default:
-			panic(errors.New("attempt to Put into a full ResourcePool"))
+	rp.putWrapper(wrapper, hasSettings, "Put")
+}
+
+// Replace creates a new resource in place of resource, which was obtained via Get and
+// is not returned to the pool, e.g. because the caller closed it or took it over.
+// Unlike Discard, it doesn't close resource. It replaces Put(nil), which can't be
+// matched with the Get of the resource by the leak detector and the labels.
+func (rp *ResourcePool) Replace(resource Resource) {
+	rp.leaks.untrack(resource)
+	rp.labels.untrack(resource)
+	rp.Put(nil)
+}
+
+// Discard closes a broken resource and creates a new one in its place.
+// It replaces the Put call for a resource obtained via Get, and should be
+// used instead of Put(nil) so that the resource is closed and the active
+// count stays accurate. If the replacement cannot be created, an empty
+// slot is returned to the pool and the next Get will retry the creation.
+func (rp *ResourcePool) Discard(resource Resource) {
+	if resource == nil {
+		// nothing to close, the slot is refilled the same way Put(nil) does.
+		rp.Put(nil)
+		return
+	}
+	rp.leaks.untrack(resource)
+	rp.labels.untrack(resource)
+	resource.Close()
+
+	var wrapper resourceWrapper
+	rp.reopenResource(&wrapper)
+	rp.putWrapper(wrapper, false, "Discard")
+}
+
+// putWrapper returns the slot of a resource obtained via Get to the pool, unless the pool has been
+// force-closed meanwhile, in which case its resource is closed. The resource is recreated by the
+// callers before, so that forceCloseMu isn't held by a slow creation and CloseWithDeadline isn't blocked.
+func (rp *ResourcePool) putWrapper(wrapper resourceWrapper, hasSettings bool, op string) {
+	rp.forceCloseMu.RLock()
+	defer rp.forceCloseMu.RUnlock()
+	if rp.forceClosed {
+		if wrapper.resource != nil {
+			wrapper.resource.Close()
+			rp.active.Add(-1)
 		}
+		rp.inUse.Add(-1)
+		return
+	}
+	resources := rp.resources
+	if hasSettings {
+		resources = rp.settingResources
+	}
+	select {
+	case resources <- wrapper:
+	default:
+		panic(fmt.Errorf("attempt to %s into a full ResourcePool", op))
 	}
 	rp.inUse.Add(-1)
 	rp.available.Add(1)
 }
 
+// reopenResource replaces the resource of wrapper with a new one. If the creation fails,
+// or is canceled because the pool is closed, wrapper is left as an empty slot.
 func (rp *ResourcePool) reopenResource(wrapper *resourceWrapper) {
-	if r, err := rp.factory(context.TODO()); err == nil {
+	if r, err := rp.factory(rp.poolContext()); err == nil {
 		wrapper.resource = r
 		wrapper.timeUsed = time.Now()
 	} else {
@@ -556,6 +779,13 @@ func (rp *ResourcePool) reopenResource(wrapper *resourceWrapper) {
 	}
 }
 
+// poolContext returns the context used to recreate resources outside of Get.
+func (rp *ResourcePool) poolContext() context.Context {
+	rp.ctxMutex.Lock()
+	defer rp.ctxMutex.Unlock()
+	return rp.ctx
+}
+
 // SetCapacity changes the capacity of the pool.
 // You can use it to shrink or expand, but not beyond
 // the max capacity. If the change requires the pool
@@ -575,6 +805,14 @@ func (rp *ResourcePool) SetCapacity(capacity int) error {
 			// Closed this before, re-open the channel
 			rp.resources = make(chan resourceWrapper, cap(rp.resources))
 			rp.settingResources = make(chan resourceWrapper, cap(rp.settingResources))
+			rp.ctxMutex.Lock()
+			rp.cancel()
+			rp.ctx, rp.cancel = context.WithCancel(context.Background())
+			rp.ctxMutex.Unlock()
+			rp.forceCloseMu.Lock()
+			rp.forceClosed = false
+			rp.forceCloseMu.Unlock()
+			rp.closing.Set(false)
 		}
 		if oldcap == capacity {
 			return nil
@@ -631,12 +869,26 @@ func (rp *ResourcePool) SetIdleTimeout(idleTimeout time.Duration) {
 	}
 
 	rp.idleTimeout.Set(idleTimeout)
+	rp.idleTimer.SetJitter(idleTimerJitter(idleTimeout))
 	rp.idleTimer.SetInterval(idleTimeout / 10)
 }
 
+// idleTimerJitter returns the jitter of the interval at which the idle resources are closed.
+// Without it, the pools created together would close and reopen their idle resources at the same time.
+// A resource is closed at most idleTimeout*11/100 after it's idle for idleTimeout, instead of idleTimeout/10.
+func idleTimerJitter(idleTimeout time.Duration) time.Duration {
+	return idleTimeout / 100
+}
+
 // StatsJSON returns the stats in JSON format.
+// The in-use resources by label are only reported while there are some.
 func (rp *ResourcePool) StatsJSON() string {
-	return fmt.Sprintf(`{"Capacity": %v, "Available": %v, "Active": %v, "InUse": %v, "MaxCapacity": %v, "WaitCount": %v, "WaitTime": %v, "IdleTimeout": %v, "IdleClosed": %v, "MaxLifetimeClosed": %v, "Exhausted": %v}`,
+	var inUseByLabel string
+	if labels := rp.InUseByLabel(); len(labels) > 0 {
+		data, _ := json.Marshal(labels)
+		inUseByLabel = fmt.Sprintf(`, "InUseByLabel": %s`, data)
+	}
+	return fmt.Sprintf(`{"Capacity": %v, "Available": %v, "Active": %v, "InUse": %v, "MaxCapacity": %v, "WaitCount": %v, "WaitTime": %v, "IdleTimeout": %v, "IdleClosed": %v, "MaxLifetimeClosed": %v, "Exhausted": %v%s}`,
 		rp.Capacity(),
 		rp.Available(),
 		rp.Active(),
@@ -648,6 +900,7 @@ func (rp *ResourcePool) StatsJSON() string {
 		rp.IdleClosed(),
 		rp.MaxLifetimeClosed(),
 		rp.Exhausted(),
+		inUseByLabel,
 	)
 }
 
@@ -661,6 +914,25 @@ func (rp *ResourcePool) Available() int64 {
 	return rp.available.Get()
 }
 
+// SetLowWatermark sets the number of available resources below which
+// ShouldAdmit returns false. 0 disables the check.
+func (rp *ResourcePool) SetLowWatermark(lowWatermark int64) {
+	rp.lowWatermark.Set(lowWatermark)
+}
+
+// LowWatermark returns the low watermark used by ShouldAdmit.
+func (rp *ResourcePool) LowWatermark() int64 {
+	return rp.lowWatermark.Get()
+}
+
+// ShouldAdmit returns false if the number of available resources dropped
+// below the low watermark. It only does atomic reads, so it is cheap
+// enough to be called before every Get.
+func (rp *ResourcePool) ShouldAdmit() bool {
+	lowWatermark := rp.lowWatermark.Get()
+	return lowWatermark <= 0 || rp.available.Get() >= lowWatermark
+}
+
 // Active returns the number of active (i.e. non-nil) resources either in the
 // pool or claimed for use
 func (rp *ResourcePool) Active() int64 {
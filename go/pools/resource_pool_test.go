@@ -31,11 +31,12 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sync2"
 )
 
 var (
-	lastID, count, closeCount, resetCount sync2.AtomicInt64
+	lastID, count, closeCount, resetCount, applyCount sync2.AtomicInt64
 
 	waitStarts []time.Time
 
@@ -43,6 +44,8 @@ var (
 	sBar    = &Setting{query: "set bar=1"}
 	sFooBar = &Setting{query: "set foo=1, bar=2"}
 	sEmpty  = &Setting{query: ""}
+
+	errUnknownVariable = mysql.NewSQLError(mysql.ERUnknownSystemVariable, mysql.SSUnknownSQLState, "Unknown system variable 'foo'")
 )
 
 type TestResource struct {
@@ -51,6 +54,8 @@ type TestResource struct {
 	closed      bool
 	setting     string
 	failApply   bool
+	// applyErr is the error of ApplySetting if failApply is set, a generic one if it's nil.
+	applyErr error
 }
 
 func (tr *TestResource) ResetSetting(_ context.Context) error {
@@ -60,7 +65,11 @@ func (tr *TestResource) ResetSetting(_ context.Context) error {
 }
 
 func (tr *TestResource) ApplySetting(_ context.Context, setting *Setting) error {
+	applyCount.Add(1)
 	if tr.failApply {
+		if tr.applyErr != nil {
+			return tr.applyErr
+		}
 		return fmt.Errorf("ApplySetting failed")
 	}
 	tr.setting = setting.query
@@ -112,13 +121,19 @@ func DisallowSettingsFactory(context.Context) (Resource, error) {
 	return &TestResource{num: lastID.Add(1), failApply: true}, nil
 }
 
+// UnknownVariableFactory creates resources on which MySQL rejects every setting as an unknown variable.
+func UnknownVariableFactory(context.Context) (Resource, error) {
+	count.Add(1)
+	return &TestResource{num: lastID.Add(1), failApply: true, applyErr: errUnknownVariable}, nil
+}
+
 func TestOpen(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
 	waitStarts = waitStarts[:0]
 
-	p := NewResourcePool(PoolFactory, 6, 6, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 6, 6, time.Second, 0, logWait, nil, 0)
 	p.SetCapacity(5)
 	var resources [10]Resource
 	var r Resource
@@ -237,7 +252,7 @@ func TestShrinking(t *testing.T) {
 	count.Set(0)
 	waitStarts = waitStarts[:0]
 
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	var resources [10]Resource
 	// Leave one empty slot in the pool
 	for i := 0; i < 4; i++ {
@@ -370,7 +385,7 @@ func TestClosing(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	var resources [10]Resource
 	for i := 0; i < 5; i++ {
 		var r Resource
@@ -417,7 +432,7 @@ func TestReopen(t *testing.T) {
 	refreshCheck := func() (bool, error) {
 		return true, nil
 	}
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, refreshCheck, 500*time.Millisecond)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, refreshCheck, 500*time.Millisecond)
 	var resources [10]Resource
 	for i := 0; i < 5; i++ {
 		var r Resource
@@ -452,7 +467,7 @@ func TestIdleTimeout(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(PoolFactory, 1, 1, 10*time.Millisecond, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, 10*time.Millisecond, 0, logWait, nil, 0)
 	defer p.Close()
 
 	r, err := p.Get(ctx, nil)
@@ -510,11 +525,22 @@ func TestIdleTimeout(t *testing.T) {
 	assert.EqualValues(t, 2, p.IdleClosed())
 }
 
+func TestIdleTimerJitter(t *testing.T) {
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+	assert.Equal(t, 100*time.Millisecond, p.idleTimer.Interval())
+	assert.Equal(t, 10*time.Millisecond, p.idleTimer.Jitter())
+
+	p.SetIdleTimeout(2 * time.Second)
+	assert.Equal(t, 200*time.Millisecond, p.idleTimer.Interval())
+	assert.Equal(t, 20*time.Millisecond, p.idleTimer.Jitter())
+}
+
 func TestIdleTimeoutWithSettings(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(PoolFactory, 1, 1, 10*time.Millisecond, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, 10*time.Millisecond, 0, logWait, nil, 0)
 	defer p.Close()
 
 	r, err := p.Get(ctx, sFooBar)
@@ -576,7 +602,7 @@ func TestIdleTimeoutCreateFail(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(PoolFactory, 1, 1, 10*time.Millisecond, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, 10*time.Millisecond, 0, logWait, nil, 0)
 	defer p.Close()
 	for _, setting := range []*Setting{nil, sFoo} {
 		r, err := p.Get(ctx, setting)
@@ -605,7 +631,7 @@ func TestMaxLifetime(t *testing.T) {
 	lastID.Set(0)
 	count.Set(0)
 
-	p := NewResourcePool(PoolFactory, 1, 1, 10*time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, 10*time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 
 	r, err := p.Get(ctx, nil)
@@ -625,7 +651,7 @@ func TestMaxLifetime(t *testing.T) {
 	lastID.Set(0)
 	count.Set(0)
 
-	p = NewResourcePool(PoolFactory, 1, 1, 10*time.Second, 10*time.Millisecond, logWait, nil, 0)
+	p = NewResourcePool("TestPool", PoolFactory, 1, 1, 10*time.Second, 10*time.Millisecond, logWait, nil, 0)
 	defer p.Close()
 
 	r, err = p.Get(ctx, nil)
@@ -655,14 +681,14 @@ func TestMaxLifetime(t *testing.T) {
 
 func TestExtendedLifetimeTimeout(t *testing.T) {
 	// maxLifetime 0
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 	assert.Zero(t, p.extendedMaxLifetime())
 
 	// maxLifetime > 0
 	maxLifetime := 10 * time.Millisecond
 	for i := 0; i < 10; i++ {
-		p = NewResourcePool(PoolFactory, 5, 5, time.Second, maxLifetime, logWait, nil, 0)
+		p = NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, maxLifetime, logWait, nil, 0)
 		defer p.Close()
 		assert.LessOrEqual(t, maxLifetime, p.extendedMaxLifetime())
 		assert.Greater(t, 2*maxLifetime, p.extendedMaxLifetime())
@@ -673,7 +699,7 @@ func TestCreateFail(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(FailFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", FailFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 
 	for _, setting := range []*Setting{nil, sFoo} {
@@ -690,7 +716,7 @@ func TestCreateFailOnPut(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 
 	for _, setting := range []*Setting{nil, sFoo} {
@@ -707,11 +733,401 @@ func TestCreateFailOnPut(t *testing.T) {
 	}
 }
 
+func TestDiscard(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	closeCount.Set(0)
+	// a single slot makes sure the next Get is handed the replacement.
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+
+	for i, setting := range []*Setting{nil, sFoo} {
+		r, err := p.Get(ctx, setting)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, p.Active())
+		assert.EqualValues(t, 1, p.InUse())
+
+		p.Discard(r)
+		assert.True(t, r.(*TestResource).closed)
+		assert.EqualValues(t, i+1, closeCount.Get())
+		assert.EqualValues(t, 1, p.Active())
+		assert.EqualValues(t, 0, p.InUse())
+		assert.EqualValues(t, 1, p.Available())
+		assert.EqualValues(t, 1, count.Get())
+
+		// the replacement is handed out by the next Get.
+		r2, err := p.Get(ctx, nil)
+		require.NoError(t, err)
+		assert.NotEqual(t, r.(*TestResource).num, r2.(*TestResource).num)
+		assert.False(t, r2.(*TestResource).closed)
+		assert.EqualValues(t, 1, p.Active())
+		p.Put(r2)
+	}
+}
+
+func TestLeakThreshold(t *testing.T) {
+	ctx := context.Background()
+	p := NewResourcePool("TestPool", PoolFactory, 2, 2, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+	leaks := make(chan string, 100)
+	p.leaks.logf = func(format string, args ...any) {
+		select {
+		case leaks <- fmt.Sprintf(format, args...):
+		default:
+		}
+	}
+	p.SetLeakThreshold(50*time.Millisecond, true)
+	assert.Equal(t, 50*time.Millisecond, p.LeakThreshold())
+
+	// a resource returned in time is not reported.
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	p.Put(r)
+
+	// a resource held past the threshold is reported with the stack of its Get.
+	r, err = p.Get(ctx, nil)
+	require.NoError(t, err)
+	select {
+	case msg := <-leaks:
+		assert.Contains(t, msg, "resource pool TestPool: 1 resource(s) held for longer than 50ms")
+		assert.Contains(t, msg, "TestLeakThreshold")
+	case <-time.After(5 * time.Second):
+		t.Fatal("no leak was reported")
+	}
+
+	// nothing is reported once it's returned.
+	p.Put(r)
+	time.Sleep(20 * time.Millisecond)
+	for len(leaks) > 0 {
+		<-leaks
+	}
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, leaks)
+}
+
+func TestInUseByLabel(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+
+	dmlCtx := WithLabel(ctx, "dmljob")
+	r1, err := p.Get(dmlCtx, nil)
+	require.NoError(t, err)
+	r2, err := p.Get(dmlCtx, sFoo)
+	require.NoError(t, err)
+	r3, err := p.Get(WithLabel(ctx, "onlineddl"), nil)
+	require.NoError(t, err)
+	// the resources obtained without a label are not counted by label
+	r4, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, map[string]int64{"dmljob": 2, "onlineddl": 1}, p.InUseByLabel())
+	assert.Equal(t, `{"Capacity": 5, "Available": 1, "Active": 4, "InUse": 4, "MaxCapacity": 5, "WaitCount": 0, "WaitTime": 0, "IdleTimeout": 1000000000, "IdleClosed": 0, "MaxLifetimeClosed": 0, "Exhausted": 0, "InUseByLabel": {"dmljob":2,"onlineddl":1}}`, p.StatsJSON())
+
+	p.Put(r1)
+	p.Discard(r3)
+	p.Put(r4)
+	assert.Equal(t, map[string]int64{"dmljob": 1}, p.InUseByLabel())
+
+	// the labels are no longer reported once all the labeled resources are returned
+	p.Put(r2)
+	assert.Empty(t, p.InUseByLabel())
+	assert.Equal(t, `{"Capacity": 5, "Available": 5, "Active": 4, "InUse": 0, "MaxCapacity": 5, "WaitCount": 0, "WaitTime": 0, "IdleTimeout": 1000000000, "IdleClosed": 0, "MaxLifetimeClosed": 0, "Exhausted": 0}`, p.StatsJSON())
+}
+
+func TestReplace(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 3, 3, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+	p.SetLeakThreshold(time.Hour, false)
+
+	r1, err := p.Get(WithLabel(ctx, "dmljob"), nil)
+	require.NoError(t, err)
+	r2, err := p.Get(WithLabel(ctx, "onlineddl"), nil)
+	require.NoError(t, err)
+	// r2 is closed but still in use, it isn't the one replaced.
+	r2.Close()
+
+	// r1 is taken over by the caller, it's replaced without being closed.
+	p.Replace(r1)
+	assert.False(t, r1.(*TestResource).closed)
+	assert.Equal(t, map[string]int64{"onlineddl": 1}, p.InUseByLabel())
+	assert.Len(t, p.leaks.held, 1)
+	assert.Contains(t, p.leaks.held, r2)
+	assert.EqualValues(t, 1, p.InUse())
+	assert.EqualValues(t, 2, p.Active())
+
+	p.Replace(r2)
+	assert.Empty(t, p.InUseByLabel())
+	assert.Empty(t, p.leaks.held)
+	assert.Zero(t, p.InUse())
+	assert.EqualValues(t, 3, p.Available())
+}
+
+func TestReplaceWhileClosing(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 2, 2, time.Second, 0, logWait, nil, 0)
+	p.SetLeakThreshold(time.Hour, false)
+
+	r1, err := p.Get(WithLabel(ctx, "dmljob"), nil)
+	require.NoError(t, err)
+	r2, err := p.Get(ctx, sFoo)
+	require.NoError(t, err)
+	closeDone := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closeDone)
+	}()
+
+	// Wait for goroutine to call Close
+	time.Sleep(10 * time.Millisecond)
+	// Replace is allowed when closing, the replacement is closed by Close.
+	r1.Close()
+	p.Replace(r1)
+	p.Put(r2)
+	select {
+	case <-closeDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close is blocked by a replaced resource")
+	}
+	assert.Empty(t, p.InUseByLabel())
+	assert.Empty(t, p.leaks.held)
+	assert.Zero(t, p.Active())
+	assert.Zero(t, p.InUse())
+	assert.EqualValues(t, 3, lastID.Get())
+	assert.Zero(t, count.Get())
+}
+
+func TestReplaceWhileClosingWithDeadline(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+
+	// a resource replaced before the deadline is waited for.
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		r.Close()
+		p.Replace(r)
+	}()
+	closeCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, p.CloseWithDeadline(closeCtx))
+	assert.Zero(t, p.Active())
+	assert.Zero(t, count.Get())
+
+	// a resource replaced after the deadline isn't returned to the pool, its replacement is closed.
+	p = NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+	p.SetLeakThreshold(time.Hour, false)
+	r, err = p.Get(WithLabel(ctx, "dmljob"), nil)
+	require.NoError(t, err)
+	closeCtx, cancel = context.WithTimeout(ctx, 10*time.Millisecond)
+	defer cancel()
+	require.ErrorIs(t, p.CloseWithDeadline(closeCtx), context.DeadlineExceeded)
+
+	r.Close()
+	p.Replace(r)
+	assert.Empty(t, p.InUseByLabel())
+	assert.Empty(t, p.leaks.held)
+	assert.Zero(t, p.Active())
+	assert.Zero(t, p.InUse())
+	assert.Zero(t, p.Available())
+	assert.Zero(t, count.Get())
+}
+
+func TestDiscardDoesNotBlockCloseWithDeadline(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+
+	// the replacement blocks like a dial that doesn't honor the cancellation of its context.
+	factoryCalled := make(chan struct{})
+	releaseFactory := make(chan struct{})
+	p.factory = func(context.Context) (Resource, error) {
+		close(factoryCalled)
+		<-releaseFactory
+		return PoolFactory(ctx)
+	}
+	discardDone := make(chan struct{})
+	go func() {
+		p.Discard(r)
+		close(discardDone)
+	}()
+	<-factoryCalled
+
+	closeCtx, cancel := context.WithTimeout(ctx, 50*time.Millisecond)
+	defer cancel()
+	closeDone := make(chan error)
+	go func() {
+		closeDone <- p.CloseWithDeadline(closeCtx)
+	}()
+	select {
+	case err := <-closeDone:
+		require.ErrorIs(t, err, context.DeadlineExceeded)
+	case <-time.After(5 * time.Second):
+		t.Fatal("CloseWithDeadline is blocked by the replacement of a discarded resource")
+	}
+
+	// the replacement is closed since the pool was force-closed meanwhile.
+	close(releaseFactory)
+	<-discardDone
+	assert.Zero(t, p.Active())
+	assert.Zero(t, p.InUse())
+	assert.Zero(t, count.Get())
+}
+
+func TestDiscardCreateFail(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+
+	// change factory to fail the replacement.
+	p.factory = FailFactory
+	p.Discard(r)
+	assert.Zero(t, p.Active())
+	assert.Zero(t, p.InUse())
+	assert.EqualValues(t, 5, p.Available())
+	assert.Zero(t, count.Get())
+
+	// the empty slot is filled again by the next Get.
+	p.factory = PoolFactory
+	r, err = p.Get(ctx, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, p.Active())
+	p.Put(r)
+}
+
+func TestDiscardNil(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+
+	_, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	p.Discard(nil)
+	assert.EqualValues(t, 1, p.Active())
+	assert.Zero(t, p.InUse())
+	assert.EqualValues(t, 1, p.Available())
+
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, p.Active())
+	p.Put(r)
+}
+
+func TestCloseCancelsBlockedReopen(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+
+	_, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+
+	// the factory blocks like a connect to an unreachable MySQL, until its context is canceled.
+	factoryCalled := make(chan struct{})
+	p.factory = func(ctx context.Context) (Resource, error) {
+		close(factoryCalled)
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+	putDone := make(chan struct{})
+	go func() {
+		p.Put(nil)
+		close(putDone)
+	}()
+	<-factoryCalled
+
+	closeDone := make(chan struct{})
+	go func() {
+		p.Close()
+		close(closeDone)
+	}()
+	select {
+	case <-closeDone:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Close is blocked by the reopen of a resource")
+	}
+	<-putDone
+	// the canceled reopen leaves an empty slot
+	assert.Zero(t, p.Active())
+	assert.Zero(t, p.InUse())
+	assert.Zero(t, p.Available())
+}
+
+func TestCloseWithDeadline(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 2, 2, time.Second, 0, logWait, nil, 0)
+
+	r1, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	withheld, err := p.Get(ctx, sFoo)
+	require.NoError(t, err)
+	p.Put(r1)
+
+	deadline := 50 * time.Millisecond
+	closeCtx, cancel := context.WithTimeout(ctx, deadline)
+	defer cancel()
+	start := time.Now()
+	err = p.CloseWithDeadline(closeCtx)
+	require.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.GreaterOrEqual(t, time.Since(start), deadline)
+	assert.True(t, r1.(*TestResource).closed)
+	assert.False(t, withheld.(*TestResource).closed)
+
+	_, err = p.Get(ctx, nil)
+	require.ErrorIs(t, err, ErrClosed)
+
+	// the withheld resource is discarded when it's finally returned.
+	p.Put(withheld)
+	assert.True(t, withheld.(*TestResource).closed)
+	assert.Zero(t, p.Active())
+	assert.Zero(t, p.InUse())
+	assert.Zero(t, p.Available())
+	assert.Zero(t, count.Get())
+
+	// the resources returned before the deadline are waited for.
+	p = NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		p.Put(r)
+	}()
+	closeCtx, cancel = context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	require.NoError(t, p.CloseWithDeadline(closeCtx))
+	assert.True(t, r.(*TestResource).closed)
+	assert.Zero(t, p.Active())
+}
+
 func TestSlowCreateFail(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(SlowFailFactory, 2, 2, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", SlowFailFactory, 2, 2, time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 	ch := make(chan bool)
 	for _, setting := range []*Setting{nil, sFoo} {
@@ -733,7 +1149,7 @@ func TestTimeout(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 
 	// take the only connection available
@@ -756,7 +1172,7 @@ func TestTimeout(t *testing.T) {
 func TestExpired(t *testing.T) {
 	lastID.Set(0)
 	count.Set(0)
-	p := NewResourcePool(PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 1, 1, time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 
 	for _, setting := range []*Setting{nil, sFoo} {
@@ -768,13 +1184,71 @@ func TestExpired(t *testing.T) {
 	}
 }
 
+func TestBadSettingTTL(t *testing.T) {
+	ctx := context.Background()
+	p := NewResourcePool("TestPool", UnknownVariableFactory, 2, 2, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+	p.SetBadSettingTTL(100 * time.Millisecond)
+
+	applyCount.Set(0)
+	_, err := p.Get(ctx, sFoo)
+	assert.Equal(t, errUnknownVariable, err)
+	assert.EqualValues(t, 1, applyCount.Get())
+
+	// within the ttl, the Get calls with the same setting fail without applying it again
+	for i := 0; i < 5; i++ {
+		_, err = p.Get(ctx, sFoo)
+		assert.Equal(t, errUnknownVariable, err)
+	}
+	assert.EqualValues(t, 1, applyCount.Get())
+	assert.EqualValues(t, 2, p.Available())
+
+	// the other settings are still applied
+	_, err = p.Get(ctx, sBar)
+	assert.Equal(t, errUnknownVariable, err)
+	assert.EqualValues(t, 2, applyCount.Get())
+
+	// the resources can still be used without setting
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	p.Put(r)
+
+	// the setting is applied again once the ttl expired
+	time.Sleep(150 * time.Millisecond)
+	_, err = p.Get(ctx, sFoo)
+	assert.Equal(t, errUnknownVariable, err)
+	assert.EqualValues(t, 3, applyCount.Get())
+
+	// without the cache every Get applies the setting
+	p.SetBadSettingTTL(0)
+	_, err = p.Get(ctx, sFoo)
+	assert.Equal(t, errUnknownVariable, err)
+	assert.EqualValues(t, 4, applyCount.Get())
+}
+
+func TestBadSettingTTLTransientError(t *testing.T) {
+	ctx := context.Background()
+	// the setting fails with an error which isn't an error of the setting itself, e.g. a lost connection
+	p := NewResourcePool("TestPool", DisallowSettingsFactory, 2, 2, time.Second, 0, logWait, nil, 0)
+	defer p.Close()
+	p.SetBadSettingTTL(time.Minute)
+
+	applyCount.Set(0)
+	for i := 1; i <= 3; i++ {
+		_, err := p.Get(ctx, sFoo)
+		assert.EqualError(t, err, "ApplySetting failed")
+		// the error isn't remembered, so the setting is applied again every time
+		assert.EqualValues(t, i, applyCount.Get())
+	}
+}
+
 func TestMultiSettings(t *testing.T) {
 	ctx := context.Background()
 	lastID.Set(0)
 	count.Set(0)
 	waitStarts = waitStarts[:0]
 
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	var resources [10]Resource
 	var r Resource
 	var err error
@@ -837,7 +1311,7 @@ func TestMultiSettingsWithReset(t *testing.T) {
 	count.Set(0)
 	resetCount.Set(0)
 
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	var resources [10]Resource
 	var r Resource
 	var err error
@@ -883,7 +1357,7 @@ func TestApplySettingsFailure(t *testing.T) {
 	var r Resource
 	var err error
 
-	p := NewResourcePool(PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
+	p := NewResourcePool("TestPool", PoolFactory, 5, 5, time.Second, 0, logWait, nil, 0)
 	defer p.Close()
 
 	settings := []*Setting{nil, sFoo, sBar, sBar, sFoo}
@@ -934,3 +1408,53 @@ func TestApplySettingsFailure(t *testing.T) {
 		p.Put(r)
 	}
 }
+
+func TestName(t *testing.T) {
+	queryPool := NewResourcePool("QueryPool", PoolFactory, 1, 1, 0, 0, nil, nil, 0)
+	defer queryPool.Close()
+	txPool := NewResourcePool("TxPool", PoolFactory, 1, 1, 0, 0, nil, nil, 0)
+	defer txPool.Close()
+
+	assert.Equal(t, "QueryPool", queryPool.Name())
+	assert.Equal(t, "TxPool", txPool.Name())
+}
+
+func TestShouldAdmit(t *testing.T) {
+	ctx := context.Background()
+	lastID.Set(0)
+	count.Set(0)
+	p := NewResourcePool("TestPool", PoolFactory, 4, 4, 0, 0, nil, nil, 0)
+	defer p.Close()
+
+	// Without a low watermark every caller is admitted.
+	var resources []Resource
+	for i := 0; i < 4; i++ {
+		assert.True(t, p.ShouldAdmit())
+		r, err := p.Get(ctx, nil)
+		require.NoError(t, err)
+		resources = append(resources, r)
+	}
+	assert.True(t, p.ShouldAdmit())
+
+	p.SetLowWatermark(2)
+	assert.EqualValues(t, 2, p.LowWatermark())
+	assert.False(t, p.ShouldAdmit())
+
+	// Admission flips back once enough resources are returned.
+	p.Put(resources[0])
+	assert.False(t, p.ShouldAdmit())
+	p.Put(resources[1])
+	assert.True(t, p.ShouldAdmit())
+
+	// And flips again as the pool fills.
+	r, err := p.Get(ctx, nil)
+	require.NoError(t, err)
+	assert.False(t, p.ShouldAdmit())
+	p.Put(r)
+	p.Put(resources[2])
+	p.Put(resources[3])
+	assert.True(t, p.ShouldAdmit())
+
+	p.SetLowWatermark(0)
+	assert.True(t, p.ShouldAdmit())
+}
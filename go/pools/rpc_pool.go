@@ -53,7 +53,7 @@ type RPCPool struct {
 // will not be called).
 func NewRPCPool(size int, waitTimeout time.Duration, logWait func(time.Time)) *RPCPool {
 	return &RPCPool{
-		rp:          NewResourcePool(rpcResourceFactory, size, size, 0, 0, logWait, nil, 0),
+		rp:          NewResourcePool("RPCPool", rpcResourceFactory, size, size, 0, 0, logWait, nil, 0),
 		waitTimeout: waitTimeout,
 	}
 }
@@ -103,6 +103,13 @@ func ResultToProto3(qr *Result) *querypb.QueryResult {
 		Fields:              qr.Fields,
 		RowsAffected:        qr.RowsAffected,
 		InsertId:            qr.InsertID,
+		LastInsertId:        qr.LastInsertID,
+		Truncated:           qr.Truncated,
+		PlanCacheHit:        qr.PlanCacheHit,
+		StreamFailureMarker: qr.StreamFailureMarker,
+		RowsDelivered:       qr.RowsDelivered,
+		RowsExamined:        qr.RowsExamined,
+		ThreadId:            qr.ThreadID,
 		Rows:                RowsToProto3(qr.Rows),
 		Info:                qr.Info,
 		SessionStateChanges: qr.SessionStateChanges,
@@ -119,6 +126,13 @@ func Proto3ToResult(qr *querypb.QueryResult) *Result {
 		Fields:              qr.Fields,
 		RowsAffected:        qr.RowsAffected,
 		InsertID:            qr.InsertId,
+		LastInsertID:        qr.LastInsertId,
+		Truncated:           qr.Truncated,
+		PlanCacheHit:        qr.PlanCacheHit,
+		StreamFailureMarker: qr.StreamFailureMarker,
+		RowsDelivered:       qr.RowsDelivered,
+		RowsExamined:        qr.RowsExamined,
+		ThreadID:            qr.ThreadId,
 		Rows:                proto3ToRows(qr.Fields, qr.Rows),
 		Info:                qr.Info,
 		SessionStateChanges: qr.SessionStateChanges,
@@ -136,6 +150,13 @@ func CustomProto3ToResult(fields []*querypb.Field, qr *querypb.QueryResult) *Res
 		Fields:              qr.Fields,
 		RowsAffected:        qr.RowsAffected,
 		InsertID:            qr.InsertId,
+		LastInsertID:        qr.LastInsertId,
+		Truncated:           qr.Truncated,
+		PlanCacheHit:        qr.PlanCacheHit,
+		StreamFailureMarker: qr.StreamFailureMarker,
+		RowsDelivered:       qr.RowsDelivered,
+		RowsExamined:        qr.RowsExamined,
+		ThreadID:            qr.ThreadId,
 		Rows:                proto3ToRows(fields, qr.Rows),
 		Info:                qr.Info,
 		SessionStateChanges: qr.SessionStateChanges,
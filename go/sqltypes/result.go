@@ -36,9 +36,16 @@ type Result struct {
 	Fields              []*querypb.Field `json:"fields"`
 	RowsAffected        uint64           `json:"rows_affected"`
 	InsertID            uint64           `json:"insert_id"`
+	LastInsertID        uint64           `json:"last_insert_id"`
 	Rows                []Row            `json:"rows"`
 	SessionStateChanges string           `json:"session_state_changes"`
 	StatusFlags         uint16           `json:"status_flags"`
+	Truncated           bool             `json:"truncated"`
+	PlanCacheHit        bool             `json:"plan_cache_hit"`
+	StreamFailureMarker bool             `json:"stream_failure_marker"`
+	RowsDelivered       uint64           `json:"rows_delivered"`
+	RowsExamined        uint64           `json:"rows_examined"`
+	ThreadID            uint64           `json:"thread_id"`
 	Info                string           `json:"info"`
 }
 
@@ -97,8 +104,15 @@ func (result *Result) Copy() *Result {
 	out := &Result{
 		RowsAffected:        result.RowsAffected,
 		InsertID:            result.InsertID,
+		LastInsertID:        result.LastInsertID,
 		SessionStateChanges: result.SessionStateChanges,
 		StatusFlags:         result.StatusFlags,
+		Truncated:           result.Truncated,
+		PlanCacheHit:        result.PlanCacheHit,
+		StreamFailureMarker: result.StreamFailureMarker,
+		RowsDelivered:       result.RowsDelivered,
+		RowsExamined:        result.RowsExamined,
+		ThreadID:            result.ThreadID,
 		Info:                result.Info,
 	}
 	if result.Fields != nil {
@@ -121,10 +135,17 @@ func (result *Result) ShallowCopy() *Result {
 	return &Result{
 		Fields:              result.Fields,
 		InsertID:            result.InsertID,
+		LastInsertID:        result.LastInsertID,
 		RowsAffected:        result.RowsAffected,
 		Info:                result.Info,
 		SessionStateChanges: result.SessionStateChanges,
 		Rows:                result.Rows,
+		Truncated:           result.Truncated,
+		PlanCacheHit:        result.PlanCacheHit,
+		StreamFailureMarker: result.StreamFailureMarker,
+		RowsDelivered:       result.RowsDelivered,
+		RowsExamined:        result.RowsExamined,
+		ThreadID:            result.ThreadID,
 	}
 }
 
@@ -134,6 +155,7 @@ func (result *Result) Metadata() *Result {
 	return &Result{
 		Fields:              result.Fields,
 		InsertID:            result.InsertID,
+		LastInsertID:        result.LastInsertID,
 		RowsAffected:        result.RowsAffected,
 		Info:                result.Info,
 		SessionStateChanges: result.SessionStateChanges,
@@ -158,6 +180,7 @@ func (result *Result) Truncate(l int) *Result {
 
 	out := &Result{
 		InsertID:            result.InsertID,
+		LastInsertID:        result.LastInsertID,
 		RowsAffected:        result.RowsAffected,
 		Info:                result.Info,
 		SessionStateChanges: result.SessionStateChanges,
@@ -334,10 +357,13 @@ func (result *Result) AppendResult(src *Result) {
 		result.Fields = src.Fields
 	}
 	result.RowsAffected += src.RowsAffected
+	result.RowsExamined += src.RowsExamined
 	if src.InsertID != 0 {
 		result.InsertID = src.InsertID
+		result.LastInsertID = src.LastInsertID
 	}
 	result.Rows = append(result.Rows, src.Rows...)
+	result.Truncated = result.Truncated || src.Truncated
 	// Since sharding is not supported, the only mysqld accepting writes is the leader.
 	// We can just append the session state changes.
 	result.SessionStateChanges = src.SessionStateChanges
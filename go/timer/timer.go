@@ -18,6 +18,7 @@ limitations under the License.
 package timer
 
 import (
+	"math/rand"
 	"sync"
 	"time"
 
@@ -54,9 +55,13 @@ schedule an untimely trigger by calling t.TriggerAfter.
 The timer interval can be changed on the fly by calling t.SetInterval.
 A zero value interval will cause the timer to wait indefinitely, and it
 will react only to an explicit Trigger or Stop.
+
+A jitter can be set by calling t.SetJitter, so that timers started
+together don't keep calling keephouse at the same time.
 */
 type Timer struct {
 	interval sync2.AtomicDuration
+	jitter   sync2.AtomicDuration
 
 	// state management
 	mu      sync.Mutex
@@ -90,7 +95,7 @@ func (tm *Timer) run(keephouse func()) {
 	var timer *time.Timer
 	for {
 		var ch <-chan time.Time
-		interval := tm.interval.Get()
+		interval := tm.nextInterval()
 		if interval > 0 {
 			timer = time.NewTimer(interval)
 			ch = timer.C
@@ -124,6 +129,26 @@ func (tm *Timer) SetInterval(ns time.Duration) {
 	}
 }
 
+// SetJitter sets the jitter of the interval: every wait lasts the interval
+// plus or minus a random duration up to jitter. It's capped to half the interval.
+// It takes effect from the next wait.
+func (tm *Timer) SetJitter(jitter time.Duration) {
+	tm.jitter.Set(jitter)
+}
+
+// nextInterval returns the duration of the next wait.
+func (tm *Timer) nextInterval() time.Duration {
+	interval := tm.interval.Get()
+	jitter := tm.jitter.Get()
+	if interval <= 0 || jitter <= 0 {
+		return interval
+	}
+	if jitter > interval/2 {
+		jitter = interval / 2
+	}
+	return interval + time.Duration(rand.Int63n(int64(2*jitter)+1)) - jitter
+}
+
 // Trigger will cause the timer to immediately execute the keephouse function.
 // It will then cause the timer to restart the wait.
 func (tm *Timer) Trigger() {
@@ -158,6 +183,11 @@ func (tm *Timer) Interval() time.Duration {
 	return tm.interval.Get()
 }
 
+// Jitter returns the current jitter.
+func (tm *Timer) Jitter() time.Duration {
+	return tm.jitter.Get()
+}
+
 func (tm *Timer) Running() bool {
 	tm.mu.Lock()
 	defer tm.mu.Unlock()
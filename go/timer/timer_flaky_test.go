@@ -75,3 +75,27 @@ func TestIndefinite(t *testing.T) {
 	time.Sleep(quarter)
 	assert.Equal(t, int64(1), numcalls.Get())
 }
+
+func TestJitter(t *testing.T) {
+	timer := NewTimer(100 * time.Millisecond)
+	assert.Equal(t, 100*time.Millisecond, timer.nextInterval())
+
+	timer.SetJitter(10 * time.Millisecond)
+	assert.Equal(t, 10*time.Millisecond, timer.Jitter())
+	seen := map[time.Duration]bool{}
+	for i := 0; i < 100; i++ {
+		interval := timer.nextInterval()
+		assert.GreaterOrEqual(t, interval, 90*time.Millisecond)
+		assert.LessOrEqual(t, interval, 110*time.Millisecond)
+		seen[interval] = true
+	}
+	assert.Greater(t, len(seen), 1, "the interval should vary")
+
+	// the jitter is capped to half the interval
+	timer.SetJitter(time.Second)
+	for i := 0; i < 100; i++ {
+		interval := timer.nextInterval()
+		assert.GreaterOrEqual(t, interval, 50*time.Millisecond)
+		assert.LessOrEqual(t, interval, 150*time.Millisecond)
+	}
+}
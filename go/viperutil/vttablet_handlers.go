@@ -128,6 +128,15 @@ func RegisterReloadHandlersForVtTablet(v *ViperConfig, tsv *tabletserver.TabletS
 		}
 	})
 
+	v.ReloadHandler.AddReloadHandler("queryserver-config-stream-max-concurrency", func(key string, value string, fs *pflag.FlagSet) {
+		i, err := parseInt(key, value)
+		if err == nil {
+			tsv.SetStreamMaxConcurrency(i)
+		} else {
+			log.Errorf("fail to reload config %s=%s, err: %v", key, value, err)
+		}
+	})
+
 	v.ReloadHandler.AddReloadHandler("queryserver-config-transaction-cap", func(key string, value string, fs *pflag.FlagSet) {
 		i, err := parseInt(key, value)
 		if err == nil {
@@ -109,7 +109,7 @@ func (cp *ConnectionPool) Open(info dbconfigs.Connector) {
 	cp.mu.Lock()
 	defer cp.mu.Unlock()
 	cp.info = info
-	cp.connections = pools.NewResourcePool(cp.connect, cp.capacity, cp.capacity, cp.idleTimeout, cp.maxLifetime, nil, refreshCheck, cp.resolutionFrequency)
+	cp.connections = pools.NewResourcePool(cp.name, cp.connect, cp.capacity, cp.capacity, cp.idleTimeout, cp.maxLifetime, nil, refreshCheck, cp.resolutionFrequency)
 }
 
 // connect is used by the resource pool to create a new Resource.
@@ -171,6 +171,16 @@ func (cp *ConnectionPool) Put(conn *PooledDBConnection) {
 	p.Put(conn)
 }
 
+// Replace creates a new connection in the pool in place of conn, which is not returned to it
+// because it's closed.
+func (cp *ConnectionPool) Replace(conn *PooledDBConnection) {
+	p := cp.pool()
+	if p == nil {
+		panic(ErrConnPoolClosed)
+	}
+	p.Replace(conn)
+}
+
 // SetCapacity alters the size of the pool at runtime.
 func (cp *ConnectionPool) SetCapacity(capacity int) (err error) {
 	cp.mu.Lock()
@@ -55,7 +55,7 @@ func (pc *PooledDBConnection) ResetSetting(context.Context) error {
 // Recycle should be called to return the PooledDBConnection to the pool.
 func (pc *PooledDBConnection) Recycle() {
 	if pc.IsClosed() {
-		pc.pool.Put(nil)
+		pc.pool.Replace(pc)
 	} else {
 		pc.pool.Put(pc)
 	}
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS mysql.non_transactional_dml_job_settings
+(
+    `name`                  varchar(64)     NOT NULL,
+    `value`                 varchar(256)    NOT NULL,
+    `update_time`           timestamp       NOT NULL DEFAULT CURRENT_TIMESTAMP ON UPDATE CURRENT_TIMESTAMP,
+    PRIMARY KEY (`name`)
+) ENGINE = InnoDB;
@@ -23,6 +23,17 @@ CREATE TABLE IF NOT EXISTS mysql.non_transactional_dml_jobs
     `batch_info_table_schema`                  varchar(256)      NOT NULL,
     `batch_info_table_name`                  varchar(256)      NOT NULL UNIQUE,
     `postpone_launch`       tinyint unsigned NOT NULL DEFAULT '0',
+    `launch_at`             varchar(64)     NULL   DEFAULT NULL,
+    `archive_table`         varchar(256)    NULL   DEFAULT NULL,
+    `isolation_level`       varchar(32)     NULL   DEFAULT NULL,
+    `checksum_columns`      varchar(1024)   NULL   DEFAULT NULL,
+    `max_replication_lag`   double          NULL   DEFAULT NULL,
+    `notify_url`            varchar(1024)   NULL   DEFAULT NULL,
+    `batch_order`           varchar(8)      NULL   DEFAULT NULL,
+    `version_column`        varchar(256)    NULL   DEFAULT NULL,
+    `version_snapshot`      varchar(256)    NULL   DEFAULT NULL,
+    `frozen_where`          text            NULL   DEFAULT NULL,
+    `tag`                   varchar(256)    NULL   DEFAULT NULL,
     `status`                varchar(128)     NOT NULL,
     `status_set_time`           timestamp   NOT NULL,
     `time_zone`                 varchar(16)     NOT NULL,
@@ -37,7 +48,12 @@ CREATE TABLE IF NOT EXISTS mysql.non_transactional_dml_jobs
     `running_time_period_start` varchar(64)     NULL   DEFAULT NULL,
     `running_time_period_end`   varchar(64)    NULL   DEFAULT NULL,
     `running_time_period_time_zone`                 varchar(16)     NULL DEFAULT NULL,
+    `start_time`                timestamp(3)    NULL   DEFAULT NULL,
+    `complete_time`             timestamp(3)    NULL   DEFAULT NULL,
+    `duration_in_ms`            bigint          NULL   DEFAULT NULL,
+    `rows_per_second`           double          NULL   DEFAULT NULL,
     PRIMARY KEY (`id`),
     KEY `job_uuid_idx` (`job_uuid`),
-    KEY `status_idx` (`status`)
+    KEY `status_idx` (`status`),
+    KEY `tag_idx` (`tag`)
 ) ENGINE = InnoDB;
@@ -1769,6 +1769,14 @@ type (
 	ShowDMLJob struct {
 		UUID   string
 		Detail bool
+		// TagLike is the LIKE pattern the tags of the jobs shown match, it's only set when UUID is "*".
+		TagLike string
+		// BatchID is the id of the batch of the job to show, e.g. '1-2'.
+		BatchID string
+		// JSON shows the jobs as JSON objects, see JobController.ShowJobJSON.
+		JSON bool
+		// Plan shows the SQLs the job is split into batches with, see JobController.DescribeJob.
+		Plan bool
 	}
 
 	// ShowCreate is of ShowInternal type, holds SHOW CREATE queries.
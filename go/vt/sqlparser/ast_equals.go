@@ -4091,7 +4091,11 @@ func (cmp *Comparator) RefOfShowDMLJob(a, b *ShowDMLJob) bool {
 		return false
 	}
 	return a.UUID == b.UUID &&
-		a.Detail == b.Detail
+		a.Detail == b.Detail &&
+		a.TagLike == b.TagLike &&
+		a.BatchID == b.BatchID &&
+		a.JSON == b.JSON &&
+		a.Plan == b.Plan
 }
 
 // RefOfShowFilter does deep equals between the two objects.
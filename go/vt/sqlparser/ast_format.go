@@ -335,6 +335,10 @@ func (node *AlterDMLJob) Format(buf *TrackedBuffer) {
 		alterType = "resume"
 	case ResumeAllDMLJobType:
 		alterType = "resume all"
+	case PurgeDMLJobType:
+		alterType = "purge"
+	case CloneDMLJobType:
+		alterType = "clone"
 	case ThrottleDMLJobType:
 		alterType = "throttle"
 	case ThrottleAllDMLJobType:
@@ -2034,7 +2038,30 @@ func (node *ShowBasic) Format(buf *TrackedBuffer) {
 }
 
 func (node *ShowDMLJob) Format(buf *TrackedBuffer) {
-
+	if node.UUID == "*" {
+		buf.astPrintf(node, "show dml_jobs")
+		if node.TagLike != "" {
+			buf.astPrintf(node, " like ")
+			sqltypes.BufEncodeStringSQL(buf.Builder, node.TagLike)
+		}
+		if node.JSON {
+			buf.astPrintf(node, " json")
+		}
+		return
+	}
+	buf.astPrintf(node, "show dml_job '%s'", node.UUID)
+	if node.Detail {
+		buf.astPrintf(node, " details")
+	}
+	if node.BatchID != "" {
+		buf.astPrintf(node, " batch '%s'", node.BatchID)
+	}
+	if node.JSON {
+		buf.astPrintf(node, " json")
+	}
+	if node.Plan {
+		buf.astPrintf(node, " plan")
+	}
 }
 
 // Format formats the node.
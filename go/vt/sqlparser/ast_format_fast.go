@@ -465,6 +465,10 @@ func (node *AlterDMLJob) formatFast(buf *TrackedBuffer) {
 		alterType = "resume"
 	case ResumeAllDMLJobType:
 		alterType = "resume all"
+	case PurgeDMLJobType:
+		alterType = "purge"
+	case CloneDMLJobType:
+		alterType = "clone"
 	case ThrottleDMLJobType:
 		alterType = "throttle"
 	case ThrottleAllDMLJobType:
@@ -2674,7 +2678,34 @@ func (node *ShowBasic) formatFast(buf *TrackedBuffer) {
 }
 
 func (node *ShowDMLJob) formatFast(buf *TrackedBuffer) {
-
+	if node.UUID == "*" {
+		buf.WriteString("show dml_jobs")
+		if node.TagLike != "" {
+			buf.WriteString(" like ")
+			sqltypes.BufEncodeStringSQL(buf.Builder, node.TagLike)
+		}
+		if node.JSON {
+			buf.WriteString(" json")
+		}
+		return
+	}
+	buf.WriteString("show dml_job '")
+	buf.WriteString(node.UUID)
+	buf.WriteByte('\'')
+	if node.Detail {
+		buf.WriteString(" details")
+	}
+	if node.BatchID != "" {
+		buf.WriteString(" batch '")
+		buf.WriteString(node.BatchID)
+		buf.WriteByte('\'')
+	}
+	if node.JSON {
+		buf.WriteString(" json")
+	}
+	if node.Plan {
+		buf.WriteString(" plan")
+	}
 }
 
 // formatFast formats the node.
@@ -3517,10 +3517,14 @@ func (cached *ShowDMLJob) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(24)
+		size += int64(64)
 	}
 	// field UUID string
 	size += hack.RuntimeAllocSize(int64(len(cached.UUID)))
+	// field TagLike string
+	size += hack.RuntimeAllocSize(int64(len(cached.TagLike)))
+	// field BatchID string
+	size += hack.RuntimeAllocSize(int64(len(cached.BatchID)))
 	return size
 }
 func (cached *ShowFilter) CachedSize(alloc bool) int64 {
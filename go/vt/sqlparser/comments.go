@@ -68,6 +68,19 @@ const (
 	DirectiveDMLTimePeriodTimeZone = "DML_TIME_PERIOD_TIME_ZONE"
 	DirectiveDMLThrottleDuration   = "DML_THROTTLE_DURATION"
 	DirectiveDMLThrottleRatio      = "DML_THROTTLE_RATIO"
+	DirectiveDMLLaunchAt           = "DML_LAUNCH_AT"
+	DirectiveDMLArchiveTable       = "DML_ARCHIVE_TABLE"
+	DirectiveDMLIsolationLevel     = "DML_ISOLATION_LEVEL"
+	DirectiveDMLConfirmToken       = "DML_CONFIRM_TOKEN"
+	DirectiveDMLChecksumColumns    = "DML_CHECKSUM_COLUMNS"
+	DirectiveDMLPKRangeStart       = "DML_PK_RANGE_START"
+	DirectiveDMLPKRangeEnd         = "DML_PK_RANGE_END"
+	DirectiveDMLAllowFullTable     = "DML_ALLOW_FULL_TABLE"
+	DirectiveDMLMaxReplicationLag  = "DML_MAX_REPLICATION_LAG"
+	DirectiveDMLNotifyURL          = "DML_NOTIFY_URL"
+	DirectiveDMLBatchOrder         = "DML_BATCH_ORDER"
+	DirectiveDMLVersionColumn      = "DML_VERSION_COLUMN"
+	DirectiveDMLTag                = "DML_TAG"
 )
 
 func isNonSpace(r rune) bool {
@@ -554,3 +567,207 @@ func GetDMLJobArgs(stmt Statement) (timeGapInMs int64, batchSize int64, postpone
 
 	return timeGapInMs, batchSize, postponeLaunch, failPolicy, timePeriodStart, timePeriodEnd, timePeriodTimeZone, throttleDuration, throttleRatio
 }
+
+// GetDMLJobLaunchAt returns the value of the DML_LAUNCH_AT directive of a DML job,
+// which is the time at which a postponed job should be launched automatically.
+func GetDMLJobLaunchAt(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	launchAt, _ := comments.Directives().GetString(DirectiveDMLLaunchAt, "")
+	return launchAt
+}
+
+// GetDMLJobArchiveTable returns the value of the DML_ARCHIVE_TABLE directive of a DML job,
+// which is the table the rows of a DELETE job are archived to before they are deleted.
+func GetDMLJobArchiveTable(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	archiveTable, _ := comments.Directives().GetString(DirectiveDMLArchiveTable, "")
+	return archiveTable
+}
+
+// GetDMLJobConfirmToken returns the value of the DML_CONFIRM_TOKEN directive of a DML job,
+// which confirms the submission of a job whose estimated affected rows exceed the threshold.
+func GetDMLJobConfirmToken(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	confirmToken, _ := comments.Directives().GetString(DirectiveDMLConfirmToken, "")
+	return confirmToken
+}
+
+// GetDMLJobIsolationLevel returns the value of the DML_ISOLATION_LEVEL directive of a DML job,
+// which is the transaction isolation level its batches run in.
+func GetDMLJobIsolationLevel(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	isolationLevel, _ := comments.Directives().GetString(DirectiveDMLIsolationLevel, "")
+	return isolationLevel
+}
+
+// GetDMLJobChecksumColumns returns the value of the DML_CHECKSUM_COLUMNS directive of a DML job,
+// which are the columns whose checksum is recorded for the rows of every batch.
+func GetDMLJobChecksumColumns(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	checksumColumns, _ := comments.Directives().GetString(DirectiveDMLChecksumColumns, "")
+	return checksumColumns
+}
+
+// GetDMLJobPKRange returns the values of the DML_PK_RANGE_START and DML_PK_RANGE_END directives of a DML job,
+// which limit the job to the rows whose primary key is in this range, bounds included.
+func GetDMLJobPKRange(stmt Statement) (pkRangeStart, pkRangeEnd string) {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return "", ""
+	}
+	pkRangeStart, _ = comments.Directives().GetString(DirectiveDMLPKRangeStart, "")
+	pkRangeEnd, _ = comments.Directives().GetString(DirectiveDMLPKRangeEnd, "")
+	return pkRangeStart, pkRangeEnd
+}
+
+// GetDMLJobAllowFullTable returns the value of the DML_ALLOW_FULL_TABLE directive of a DML job,
+// which allows a job without a where clause to run on the whole table.
+func GetDMLJobAllowFullTable(stmt Statement) bool {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return false
+	}
+	return comments.Directives().IsSet(DirectiveDMLAllowFullTable)
+}
+
+// GetDMLJobMaxReplicationLag returns the value of the DML_MAX_REPLICATION_LAG directive of a DML job,
+// which is the replication lag in seconds above which the batches of the job are not executed.
+func GetDMLJobMaxReplicationLag(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	maxReplicationLag, _ := comments.Directives().GetString(DirectiveDMLMaxReplicationLag, "")
+	return maxReplicationLag
+}
+
+// GetDMLJobNotifyURL returns the value of the DML_NOTIFY_URL directive of a DML job,
+// which is the URL the job is posted to once it's completed, failed or canceled.
+func GetDMLJobNotifyURL(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	notifyURL, _ := comments.Directives().GetString(DirectiveDMLNotifyURL, "")
+	return notifyURL
+}
+
+// GetDMLJobBatchOrder returns the value of the DML_BATCH_ORDER directive of a DML job,
+// which is the order of the PKs the batches are executed in.
+func GetDMLJobBatchOrder(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	batchOrder, _ := comments.Directives().GetString(DirectiveDMLBatchOrder, "")
+	return batchOrder
+}
+
+// GetDMLJobVersionColumn returns the value of the DML_VERSION_COLUMN directive of a DML job,
+// which is the column whose value advances whenever a row is modified, e.g. updated_at.
+func GetDMLJobVersionColumn(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	versionColumn, _ := comments.Directives().GetString(DirectiveDMLVersionColumn, "")
+	return versionColumn
+}
+
+// GetDMLJobTag returns the value of the DML_TAG directive of a DML job,
+// which is a free-form label the jobs are grouped and filtered by, e.g. a ticket.
+func GetDMLJobTag(stmt Statement) string {
+	var comments *ParsedComments
+	switch stmt := stmt.(type) {
+	case *Update:
+		comments = stmt.Comments
+	case *Delete:
+		comments = stmt.Comments
+	}
+	if comments == nil {
+		return ""
+	}
+	tag, _ := comments.Directives().GetString(DirectiveDMLTag, "")
+	return tag
+}
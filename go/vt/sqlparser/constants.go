@@ -910,6 +910,8 @@ const (
 	PauseAllDMLJobType
 	ResumeAllDMLJobType
 	SetRunningTimePeriodType
+	PurgeDMLJobType
+	CloneDMLJobType
 )
 
 // ColumnStorage constants
@@ -158,6 +158,8 @@ var keywords = []keyword{
 	{"cancel", CANCEL},
 	{"pause", PAUSE},
 	{"resume", RESUME},
+	{"purge", PURGE},
+	{"clone", CLONE},
 	{"cascade", CASCADE},
 	{"cascaded", CASCADED},
 	{"case", CASE},
@@ -682,6 +684,7 @@ var keywords = []keyword{
 	{"dml_job", DML_JOB},
 	{"dml_jobs", DML_JOBS},
 	{"details", DETAILS},
+	{"batch", BATCH},
 	{"time_period", TIME_PERIOD},
 	{"vitess_replication_status", VITESS_REPLICATION_STATUS},
 	{"vitess_shards", VITESS_SHARDS},
@@ -2099,6 +2099,25 @@ var (
 			input: "show vitess_migrations like '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90'",
 		}, {
 			input: "show vitess_migration '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90' logs",
+		}, {
+			input: "show dml_jobs",
+		}, {
+			input: "show dml_jobs like 'ticket-%'",
+		}, {
+			input: "show dml_job '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90'",
+		}, {
+			input: "show dml_job '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90' details",
+		}, {
+			input: "show dml_job '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90' batch '1-2'",
+		}, {
+			input: "show dml_job '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90' json",
+		}, {
+			input: "show dml_jobs json",
+		}, {
+			input: "show dml_job '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90' plan",
+		}, {
+			input:  "show dml_job '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90' BATCH \"3\"",
+			output: "show dml_job '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90' batch '3'",
 		}, {
 			input: "revert vitess_migration '9748c3b7_7fdb_11eb_ac2c_f875a4d24e90'",
 		}, {
@@ -347,11 +347,11 @@ func bindVariable(yylex yyLexer, bvar string) {
 %token <str> CDC CDCS WASM_BINARY_NAME ENV
 
 // Migration tokens
-%token <str> VITESS_MIGRATION CANCEL RETRY LAUNCH COMPLETE CLEANUP THROTTLE UNTHROTTLE EXPIRE RATIO PAUSE RESUME SCHEMA_MIGRATION
+%token <str> VITESS_MIGRATION CANCEL RETRY LAUNCH COMPLETE CLEANUP THROTTLE UNTHROTTLE EXPIRE RATIO PAUSE RESUME PURGE SCHEMA_MIGRATION
 // Throttler tokens
 %token <str> VITESS_THROTTLER
 // DML JOB tokens
-%token <str> DML_JOB DETAILS TIME_PERIOD
+%token <str> DML_JOB DETAILS TIME_PERIOD BATCH
 
 // Transaction Tokens
 %token <str> BEGIN START TRANSACTION COMMIT ROLLBACK SAVEPOINT RELEASE WORK
@@ -4098,6 +4098,20 @@ alter_statement:
         UUID: string($4),
       }
     }
+  |  ALTER comment_opt DML_JOB STRING PURGE
+    {
+      $$ = &AlterDMLJob{
+        Type: PurgeDMLJobType,
+        UUID: string($4),
+      }
+    }
+  |  ALTER comment_opt DML_JOB STRING CLONE
+    {
+      $$ = &AlterDMLJob{
+        Type: CloneDMLJobType,
+        UUID: string($4),
+      }
+    }
   | ALTER comment_opt DML_JOB CANCEL ALL
     {
       $$ = &AlterDMLJob{
@@ -5074,16 +5088,36 @@ show_statement:
   }
 | SHOW DML_JOBS from_database_opt like_or_where_opt
   {
-    $$ = &Show{&ShowDMLJob{UUID: "*", Detail:false}}
+    showDMLJob := &ShowDMLJob{UUID: "*", Detail:false}
+    if $4 != nil {
+      showDMLJob.TagLike = $4.Like
+    }
+    $$ = &Show{showDMLJob}
+  }
+| SHOW DML_JOBS JSON
+  {
+    $$ = &Show{&ShowDMLJob{UUID: "*", JSON: true}}
   }
 | SHOW DML_JOB STRING
   {
     $$ = &Show{&ShowDMLJob{UUID:$3, Detail:false}}
   }
+| SHOW DML_JOB STRING JSON
+  {
+    $$ = &Show{&ShowDMLJob{UUID:$3, JSON: true}}
+  }
+| SHOW DML_JOB STRING PLAN
+  {
+    $$ = &Show{&ShowDMLJob{UUID:$3, Plan: true}}
+  }
 | SHOW DML_JOB STRING DETAILS
 {
   $$ = &Show{&ShowDMLJob{UUID:$3, Detail:true}}
 }
+| SHOW DML_JOB STRING BATCH STRING
+{
+  $$ = &Show{&ShowDMLJob{UUID:$3, BatchID:$5}}
+}
 | SHOW VITESS_MIGRATION STRING LOGS
   {
     $$ = &ShowMigrationLogs{UUID: string($3)}
@@ -8853,6 +8887,7 @@ non_reserved_keyword:
 | PATH
 | PAUSE
 | RESUME
+| PURGE
 | PERSIST
 | PERSIST_ONLY
 | PLAN
@@ -8994,6 +9029,7 @@ non_reserved_keyword:
 | DML_JOB
 | DML_JOBS
 | DETAILS
+| BATCH
 | TIME_PERIOD
 | VITESS_REPLICATION_STATUS
 | VITESS_SHARDS
@@ -60,6 +60,8 @@ type aclEntry struct {
 	tableNameOrPrefix string
 	groupName         string
 	acl               map[Role]acl.ACL
+	// members are the principals acl is built from, to report the ACL in effect.
+	members map[Role][]string
 }
 
 type aclEntries []aclEntry
@@ -328,6 +330,11 @@ func buildACLEntriesFromPrivMap(privMap map[string][]PrivEntry, newACL func([]st
 				WRITER: writers,
 				ADMIN:  admins,
 			},
+			members: map[Role][]string{
+				READER: readerStrs,
+				WRITER: writerStrs,
+				ADMIN:  adminStrs,
+			},
 		})
 	}
 	return entries, nil
@@ -514,6 +521,11 @@ func (tacl *TableACL) loadGlobalFromMysqlBase(newACL func([]string) (acl.ACL, er
 			WRITER: writers,
 			ADMIN:  admins,
 		},
+		members: map[Role][]string{
+			READER: readerStrs,
+			WRITER: writerStrs,
+			ADMIN:  adminStrs,
+		},
 	})
 	sort.Sort(entries)
 	return entries, nil
@@ -548,6 +560,11 @@ func load(config *tableaclpb.Config, newACL func([]string) (acl.ACL, error)) (en
 					WRITER: writers,
 					ADMIN:  admins,
 				},
+				members: map[Role][]string{
+					READER: group.Readers,
+					WRITER: group.Writers,
+					ADMIN:  group.Admins,
+				},
 			})
 		}
 	}
@@ -716,6 +733,31 @@ func (tacl *TableACL) Config() *tableaclpb.Config {
 	return proto.Clone(tacl.config).(*tableaclpb.Config)
 }
 
+// TableGroups returns the ACL in effect as table groups, one per table name or prefix.
+// Unlike Config, it's also filled when the ACL is loaded from the MySQL privileges.
+func (tacl *TableACL) TableGroups() []*tableaclpb.TableGroupSpec {
+	tacl.RLock()
+	defer tacl.RUnlock()
+	groups := make([]*tableaclpb.TableGroupSpec, 0, len(tacl.entries))
+	for _, entry := range tacl.entries {
+		groups = append(groups, &tableaclpb.TableGroupSpec{
+			Name:                 entry.groupName,
+			TableNamesOrPrefixes: []string{entry.tableNameOrPrefix},
+			Readers:              entry.members[READER],
+			Writers:              entry.members[WRITER],
+			Admins:               entry.members[ADMIN],
+		})
+	}
+	return groups
+}
+
+// Mode returns the mode the ACL is loaded in, e.g. simple or mysqlbased.
+func (tacl *TableACL) Mode() string {
+	tacl.RLock()
+	defer tacl.RUnlock()
+	return tacl.tableACLMode
+}
+
 // Register registers an AclFactory.
 func Register(name string, factory acl.Factory) {
 	mu.Lock()
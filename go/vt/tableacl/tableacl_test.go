@@ -419,3 +419,39 @@ func TestAuthorizedListSpecificTable(t *testing.T) {
 		t.Fatalf("user should not have access to table database.other_table")
 	}
 }
+
+func TestTableGroups(t *testing.T) {
+	tacl := TableACL{factory: &simpleacl.Factory{}}
+	config := &tableaclpb.Config{
+		TableGroups: []*tableaclpb.TableGroupSpec{{
+			Name:                 "group01",
+			TableNamesOrPrefixes: []string{"test_table"},
+			Readers:              []string{"vt"},
+			Admins:               []string{"vt_admin"},
+		}},
+	}
+	if err := tacl.Set(config); err != nil {
+		t.Fatal(err)
+	}
+	if got := tacl.TableGroups(); !proto.Equal(got[0], config.TableGroups[0]) || len(got) != 1 {
+		t.Fatalf("TableGroups() = %v, want: %v", got, config.TableGroups)
+	}
+
+	// the groups built from the MySQL privileges are reported too, although they aren't in the config
+	entries, err := buildACLEntriesFromPrivMap(map[string][]PrivEntry{
+		"db1.t1": {{User: "u1@%", role: []Role{READER, WRITER}}},
+	}, (&simpleacl.Factory{}).New)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tacl.entries = entries
+	want := &tableaclpb.TableGroupSpec{
+		Name:                 "db1.t1",
+		TableNamesOrPrefixes: []string{"db1.t1"},
+		Readers:              []string{"u1@%"},
+		Writers:              []string{"u1@%"},
+	}
+	if got := tacl.TableGroups(); len(got) != 1 || !proto.Equal(got[0], want) {
+		t.Fatalf("TableGroups() = %v, want: %v", got, want)
+	}
+}
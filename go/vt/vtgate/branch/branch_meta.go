@@ -1,6 +1,11 @@
 package branch
 
-import "vitess.io/vitess/go/vt/schemadiff"
+import (
+	"fmt"
+	"strings"
+
+	"vitess.io/vitess/go/vt/schemadiff"
+)
 
 type BranchMeta struct {
 	Name string
@@ -30,6 +35,26 @@ const (
 	StatusMerged    BranchStatus = "merged"
 )
 
+// The state of a branch tells whether it can be used.
+// A branch is creating until its snapshot is captured and applied to the target, i.e. its status is init or fetched.
+const (
+	StateCreating = "creating"
+	StateReady    = "ready"
+)
+
+// IsCreating returns true if the snapshot of the branch is still being captured or applied.
+func (s BranchStatus) IsCreating() bool {
+	return s == StatusInit || s == StatusFetched
+}
+
+// State returns the state of a branch with this status.
+func (s BranchStatus) State() string {
+	if s.IsCreating() {
+		return StateCreating
+	}
+	return StateReady
+}
+
 func StringToBranchStatus(s string) BranchStatus {
 	switch s {
 	case "init":
@@ -89,6 +114,59 @@ type BranchDiff struct {
 	Diffs map[string]*DatabaseDiff
 }
 
+// BranchDiffSummary counts the objects created, dropped or altered by a BranchDiff.
+type BranchDiffSummary struct {
+	TablesAdded      int
+	TablesDropped    int
+	TablesAltered    int
+	DatabasesCreated int
+	DatabasesDropped int
+}
+
+func (s BranchDiffSummary) String() string {
+	return fmt.Sprintf("%d tables added, %d dropped, %d altered, %d databases created, %d dropped",
+		s.TablesAdded, s.TablesDropped, s.TablesAltered, s.DatabasesCreated, s.DatabasesDropped)
+}
+
+// Summary counts the databases and tables the diff creates, drops or alters.
+// The tables of a dropped database are not counted.
+func (bd *BranchDiff) Summary() BranchDiffSummary {
+	var summary BranchDiffSummary
+	for _, dbDiff := range bd.Diffs {
+		if dbDiff.NeedDropDatabase {
+			summary.DatabasesDropped++
+			continue
+		}
+		if dbDiff.NeedCreateDatabase {
+			summary.DatabasesCreated++
+		}
+		for _, ddls := range dbDiff.TableDDLs {
+			if len(ddls) == 0 {
+				continue
+			}
+			ddl := strings.ToUpper(ddls[0])
+			switch {
+			case strings.HasPrefix(ddl, "CREATE TABLE"):
+				summary.TablesAdded++
+			case strings.HasPrefix(ddl, "DROP TABLE"):
+				summary.TablesDropped++
+			default:
+				summary.TablesAltered++
+			}
+		}
+	}
+	return summary
+}
+
+// MergeBackDDLValidationError describes a merge back DDL that would not apply cleanly to the source.
+// Database, Table and DDL are empty if the error is not related to a specific DDL, e.g. a schema conflict.
+type MergeBackDDLValidationError struct {
+	Database string
+	Table    string
+	DDL      string
+	Message  string
+}
+
 const (
 	SelectBatchSize = 5000
 
@@ -3,9 +3,11 @@ package branch
 import (
 	"fmt"
 	"github.com/pingcap/failpoint"
+	"sort"
 	"strings"
 	"vitess.io/vitess/go/vt/failpointkey"
 	"vitess.io/vitess/go/vt/schemadiff"
+	"vitess.io/vitess/go/vt/sqlparser"
 )
 
 var (
@@ -108,9 +110,10 @@ func (meta *BranchMeta) Validate() error {
 //
 // State Transitions:
 // - Init -> Fetched -> Created
-// - Starts in Init state
+// - Starts in Init state, the meta is stored before the snapshot is pulled
 // - Moves to Fetched state after successfully pulling and saving the snapshot
 // - Reaches Created state after applying the snapshot to the target
+// - The branch is shown as creating in Init and Fetched states, and ready once it reaches Created state
 //
 // Idempotency:
 // This function is idempotent and can safely handle interruptions:
@@ -173,16 +176,26 @@ func (bs *BranchService) BranchCreate(branchMeta *BranchMeta) error {
 // - FromTargetToSnapshot/ FromSnapshotToTarget: Compares target MySQL schema with stored snapshot
 // - FromSnapshotToSource/ FromSourceToSnapshot: Compares stored snapshot with source MySQL schema
 //
+// The branch can't be diffed while it's still being created.
+//
 // Parameters:
 // - branchMeta: Contains branch configuration including database filters
+// - status: The current status of the branch
 // - branchDiffObjectsFlag: Specifies which objects to compare and comparison direction
 // - hints: Additional configuration for diff calculation
 //
 // Returns:
 // - *BranchDiff: Contains the calculated schema differences
-// - error: Returns nil on success, error on invalid flag or retrieval failure
+// - error: Returns nil on success, error on invalid flag, not ready branch or retrieval failure
 // todo enhancement: filter schemas about table gc and online DDL shadow tables
-func (bs *BranchService) BranchDiff(name string, includeDatabases, excludeDatabases []string, branchDiffObjectsFlag BranchDiffObjectsFlag, hints *schemadiff.DiffHints) (*BranchDiff, error) {
+func (bs *BranchService) BranchDiff(name string, status BranchStatus, includeDatabases, excludeDatabases []string, branchDiffObjectsFlag BranchDiffObjectsFlag, hints *schemadiff.DiffHints) (*BranchDiff, error) {
+	if status.IsCreating() {
+		return nil, fmt.Errorf("branch %s is not ready, its snapshot is still being created (status: %s), run branch create again to finish it", name, status)
+	}
+	return bs.branchDiff(name, includeDatabases, excludeDatabases, branchDiffObjectsFlag, hints)
+}
+
+func (bs *BranchService) branchDiff(name string, includeDatabases, excludeDatabases []string, branchDiffObjectsFlag BranchDiffObjectsFlag, hints *schemadiff.DiffHints) (*BranchDiff, error) {
 	switch branchDiffObjectsFlag {
 	case FromSourceToTarget, FromTargetToSource:
 		// get source schema from source mysql
@@ -312,6 +325,58 @@ func (bs *BranchService) BranchPrepareMergeBack(name string, status BranchStatus
 	return ddls, nil
 }
 
+// BranchPrepareMergeBackDryRun calculates the DDLs that BranchPrepareMergeBack would generate and validates them
+// against the current source schema, without changing the branch status or writing any merge back DDL entries.
+//
+// Validation:
+// - Each DDL must be parsable.
+// - If applyCheck is true, the DDLs are also applied to an in-memory copy of the source schema,
+// so that DDLs which would fail on the source (e.g. altering a table that was dropped in source) are reported.
+// - For MergeDiff, schema conflicts found by the three-way merge check are reported as validation errors
+// instead of failing the whole operation.
+//
+// Parameters:
+// - name: The name of the branch.
+// - status: The current status of the branch. It must be one of "preparing", "prepared", "merged", or "created".
+// - includeDatabases: A list of databases to include in the operation.
+// - excludeDatabases: A list of databases to exclude from the operation.
+// - mergeOption: Determines the merge strategy, same as BranchPrepareMergeBack.
+// - applyCheck: Whether to apply the DDLs to an in-memory copy of the source schema.
+// - hints: Optional hints to guide the schema difference calculation process.
+//
+// Returns:
+// - BranchDiff: The DDL operations that BranchPrepareMergeBack would generate.
+// - []*MergeBackDDLValidationError: The validation errors, empty if all the DDLs are valid.
+// - error: An error if the schemas can not be fetched or compared.
+func (bs *BranchService) BranchPrepareMergeBackDryRun(name string, status BranchStatus, includeDatabases, excludeDatabases []string, mergeOption MergeBackOption, applyCheck bool, hints *schemadiff.DiffHints) (*BranchDiff, []*MergeBackDDLValidationError, error) {
+	if mergeOption != MergeOverride && mergeOption != MergeDiff {
+		return nil, nil, fmt.Errorf("%v is invalid merge option, should be one of %v or %v", mergeOption, MergeOverride, MergeDiff)
+	}
+
+	if !statusIsOneOf(status, []BranchStatus{StatusCreated, StatusPreparing, StatusPrepared, StatusMerged}) {
+		return nil, nil, fmt.Errorf("%v is invalid Status, should be one of %v or %v or %v or %v",
+			status, StatusCreated, StatusPreparing, StatusPrepared, StatusMerged)
+	}
+
+	sourceSchema, err := bs.sourceMySQLService.GetBranchSchema(includeDatabases, excludeDatabases)
+	if err != nil {
+		return nil, nil, err
+	}
+	targetSchema, err := bs.targetMySQLService.GetBranchSchema(includeDatabases, excludeDatabases)
+	if err != nil {
+		return nil, nil, err
+	}
+	var snapshot *BranchSchema
+	if mergeOption == MergeDiff {
+		snapshot, err = bs.targetMySQLService.getSnapshot(name)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	return dryRunMergeBack(sourceSchema, targetSchema, snapshot, mergeOption, applyCheck, hints)
+}
+
 // BranchMergeBack executes the prepared DDLs for merging target branch back into source branch in an idempotent manner.
 // If the operation crashes or is interrupted, it ensures that subsequent executions will continue from the last uncompleted DDL.
 //
@@ -357,6 +422,62 @@ func (bs *BranchService) BranchMergeBack(name string, status BranchStatus) error
 
 }
 
+// BranchApplyMergeBackDDL executes only the prepared merge back DDLs with the given ids, so that the merge back
+// can be rolled out incrementally, e.g. apply the added tables now and the dropped ones later.
+//
+// Parameters:
+// - name: The name of the target branch for the merge-back operation.
+// - status: The current status of the branch. It must be one of StatusPrepared or StatusMerging.
+// - ids: The ids of the merge back DDLs to apply, as listed by buildMergeBackDDLResult.
+//
+// Returns:
+// - error: An error if an id does not belong to the branch, if its DDL is already merged, or if any DDL fails.
+//
+// Notes:
+// - The database DDLs are applied before the table DDLs, same as BranchMergeBack.
+// - The status is set to StatusMerging, and to StatusMerged once no unmerged DDL is left,
+// so the remaining DDLs can still be applied by BranchMergeBack.
+func (bs *BranchService) BranchApplyMergeBackDDL(name string, status BranchStatus, ids []int) error {
+	if !statusIsOneOf(status, []BranchStatus{StatusPrepared, StatusMerging}) {
+		return fmt.Errorf("%v is invalid Status, should be one of %v or %v", status, StatusPrepared, StatusMerging)
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("no merge back ddl id to apply")
+	}
+
+	dbDDLs, tableDDLs, err := bs.selectMergeBackDDLToApply(name, ids)
+	if err != nil {
+		return err
+	}
+
+	err = bs.targetMySQLService.UpdateBranchStatus(name, StatusMerging)
+	if err != nil {
+		return err
+	}
+	err = bs.executeMergeBackDDLOneByOne(dbDDLs)
+	if err != nil {
+		return err
+	}
+	err = bs.executeMergeBackDDLOneByOne(tableDDLs)
+	if err != nil {
+		return err
+	}
+
+	// set status Merged if all the DDLs are merged now
+	selectUnmergedDDLSQL, err := getSelectUnmergedDDLInBatchSQL(name, 0, 1)
+	if err != nil {
+		return err
+	}
+	unmerged, err := bs.targetMySQLService.mysqlService.Query(selectUnmergedDDLSQL)
+	if err != nil {
+		return err
+	}
+	if len(unmerged) > 0 {
+		return nil
+	}
+	return bs.targetMySQLService.UpdateBranchStatus(name, StatusMerged)
+}
+
 func (t *TargetMySQLService) BranchCleanUp(name string) error {
 	deleteMeta, err := getDeleteBranchMetaSQL(name)
 	if err != nil {
@@ -429,6 +550,61 @@ func (bs *BranchService) executeMergeBackDDL(name string) error {
 	return nil
 }
 
+// selectMergeBackDDLToApply returns the merge back DDLs of the branch with the given ids, split into the database DDLs
+// and the table DDLs. It fails if an id does not belong to the branch or its DDL is already merged.
+func (bs *BranchService) selectMergeBackDDLToApply(name string, ids []int) (Rows, Rows, error) {
+	selected := make(map[int]Row, len(ids))
+	for _, id := range ids {
+		selected[id] = Row{}
+	}
+
+	lastID := 0
+	for {
+		selectMergeBackDDLSQL, err := GetSelectMergeBackDDLInBatchSQL(name, lastID, SelectBatchSize)
+		if err != nil {
+			return nil, nil, err
+		}
+		rows, err := bs.targetMySQLService.mysqlService.Query(selectMergeBackDDLSQL)
+		if err != nil {
+			return nil, nil, err
+		}
+		for _, row := range rows {
+			id, _ := BytesToInt(row.RowData["id"])
+			if _, ok := selected[id]; ok {
+				selected[id] = row
+			}
+		}
+		if len(rows) < SelectBatchSize {
+			break
+		}
+		lastID, _ = BytesToInt(rows[len(rows)-1].RowData["id"])
+	}
+
+	dbDDLs := make(Rows, 0)
+	tableDDLs := make(Rows, 0)
+	sortedIDs := make([]int, 0, len(selected))
+	for id := range selected {
+		sortedIDs = append(sortedIDs, id)
+	}
+	sort.Ints(sortedIDs)
+	for _, id := range sortedIDs {
+		row := selected[id]
+		if row.RowData == nil {
+			return nil, nil, fmt.Errorf("merge back ddl %v does not belong to branch %v", id, name)
+		}
+		merged, _ := BytesToBool(row.RowData["merged"])
+		if merged {
+			return nil, nil, fmt.Errorf("merge back ddl %v of branch %v is already merged", id, name)
+		}
+		if BytesToString(row.RowData["table"]) == "" {
+			dbDDLs = append(dbDDLs, row)
+		} else {
+			tableDDLs = append(tableDDLs, row)
+		}
+	}
+	return dbDDLs, tableDDLs, nil
+}
+
 // caller should close rows
 func (bs *BranchService) executeMergeBackDDLOneByOne(rows Rows) error {
 	for _, row := range rows {
@@ -464,8 +640,132 @@ func (bs *BranchService) executeMergeBackDDLOneByOne(rows Rows) error {
 	return nil
 }
 
+func dryRunMergeBack(sourceSchema, targetSchema, snapshot *BranchSchema, mergeOption MergeBackOption, applyCheck bool, hints *schemadiff.DiffHints) (*BranchDiff, []*MergeBackDDLValidationError, error) {
+	validationErrors := make([]*MergeBackDDLValidationError, 0)
+	var ddls *BranchDiff
+	var err error
+	switch mergeOption {
+	case MergeOverride:
+		ddls, err = getBranchSchemaDiff(sourceSchema, targetSchema, hints)
+		if err != nil {
+			return nil, nil, err
+		}
+	case MergeDiff:
+		// the conflict check fails when the diff of one side can not be applied to the other side,
+		// which is also a conflict from the user's point of view, so report it instead of returning it.
+		conflict, message, err := branchSchemasConflictCheck(sourceSchema, targetSchema, snapshot, hints)
+		if err != nil {
+			validationErrors = append(validationErrors, &MergeBackDDLValidationError{Message: fmt.Sprintf("branch schemas conflict: %v", err)})
+		} else if conflict {
+			validationErrors = append(validationErrors, &MergeBackDDLValidationError{Message: fmt.Sprintf("branch schemas conflict: %v", message)})
+		}
+		ddls, err = getBranchSchemaDiff(snapshot, targetSchema, hints)
+		if err != nil {
+			return nil, nil, err
+		}
+	default:
+		return nil, nil, fmt.Errorf("%v is invalid merge option, should be one of %v or %v", mergeOption, MergeOverride, MergeDiff)
+	}
+
+	ddlErrors, err := validateMergeBackDDLs(sourceSchema, ddls, applyCheck)
+	if err != nil {
+		return nil, nil, err
+	}
+	validationErrors = append(validationErrors, ddlErrors...)
+	return ddls, validationErrors, nil
+}
+
+// validateMergeBackDDLs checks that each DDL in ddls is parsable, and if applyCheck is true,
+// that the DDLs of each table can be applied to the source schema.
+func validateMergeBackDDLs(sourceSchema *BranchSchema, ddls *BranchDiff, applyCheck bool) ([]*MergeBackDDLValidationError, error) {
+	var sourceInstanceSchema map[string]*schemadiff.Schema
+	if applyCheck {
+		var err error
+		sourceInstanceSchema, err = branchSchemaToInstanceSchema(sourceSchema)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	validationErrors := make([]*MergeBackDDLValidationError, 0)
+	// iterate in order so that the result is stable
+	databases := make([]string, 0, len(ddls.Diffs))
+	for database := range ddls.Diffs {
+		databases = append(databases, database)
+	}
+	sort.Strings(databases)
+
+	for _, database := range databases {
+		databaseDiff := ddls.Diffs[database]
+		_, existsInSource := sourceInstanceSchema[database]
+		if databaseDiff.NeedDropDatabase {
+			if applyCheck && !existsInSource {
+				validationErrors = append(validationErrors, &MergeBackDDLValidationError{
+					Database: database,
+					DDL:      fmt.Sprintf("DROP DATABASE IF EXISTS `%s`", database),
+					Message:  fmt.Sprintf("database %s does not exist in source", database),
+				})
+			}
+			continue
+		}
+		if databaseDiff.NeedCreateDatabase && applyCheck && existsInSource {
+			validationErrors = append(validationErrors, &MergeBackDDLValidationError{
+				Database: database,
+				DDL:      fmt.Sprintf("CREATE DATABASE IF NOT EXISTS `%s`", database),
+				Message:  fmt.Sprintf("database %s already exists in source", database),
+			})
+			continue
+		}
+		if !databaseDiff.NeedCreateDatabase && applyCheck && !existsInSource {
+			validationErrors = append(validationErrors, &MergeBackDDLValidationError{
+				Database: database,
+				Message:  fmt.Sprintf("database %s does not exist in source", database),
+			})
+			continue
+		}
+
+		tables := make([]string, 0, len(databaseDiff.TableDDLs))
+		for table := range databaseDiff.TableDDLs {
+			tables = append(tables, table)
+		}
+		sort.Strings(tables)
+
+		for _, table := range tables {
+			tableDDLs := databaseDiff.TableDDLs[table]
+			parsable := true
+			for _, ddl := range tableDDLs {
+				if _, err := sqlparser.ParseStrictDDL(ddl); err != nil {
+					parsable = false
+					validationErrors = append(validationErrors, &MergeBackDDLValidationError{
+						Database: database,
+						Table:    table,
+						DDL:      ddl,
+						Message:  fmt.Sprintf("failed to parse ddl: %v", err),
+					})
+				}
+			}
+			if !parsable || !applyCheck || databaseDiff.NeedCreateDatabase {
+				continue
+			}
+			entityDiff := databaseDiff.tableEntityDiffs[table]
+			if entityDiff == nil || entityDiff.IsEmpty() {
+				continue
+			}
+			if _, err := sourceInstanceSchema[database].Apply([]schemadiff.EntityDiff{entityDiff}); err != nil {
+				validationErrors = append(validationErrors, &MergeBackDDLValidationError{
+					Database: database,
+					Table:    table,
+					DDL:      strings.Join(tableDDLs, ";"),
+					Message:  fmt.Sprintf("failed to apply ddl to source: %v", err),
+				})
+			}
+		}
+	}
+	return validationErrors, nil
+}
+
 func (bs *BranchService) getMergeBackOverrideDDLs(name string, includeDatabases, excludeDatabases []string, hints *schemadiff.DiffHints) (*BranchDiff, error) {
-	return bs.BranchDiff(name, includeDatabases, excludeDatabases, FromSourceToTarget, hints)
+	return bs.branchDiff(name, includeDatabases, excludeDatabases, FromSourceToTarget, hints)
 }
 
 func (bs *BranchService) getMergeBackMergeDiffDDLs(name string, includeDatabases, excludeDatabases []string, hints *schemadiff.DiffHints) (*BranchDiff, error) {
@@ -604,6 +904,10 @@ func applyBranchDiffToInstanceSchema(instanceSchema map[string]*schemadiff.Schem
 		databaseSchema := instanceSchema[database]
 		entityDiffs := make([]schemadiff.EntityDiff, 0)
 		for _, entityDiff := range databaseDiff.tableEntityDiffs {
+			// the diff of a table that is the same on both sides is empty
+			if entityDiff == nil || entityDiff.IsEmpty() {
+				continue
+			}
 			entityDiffs = append(entityDiffs, entityDiff)
 		}
 		newDatabaseSchema, err := databaseSchema.Apply(entityDiffs)
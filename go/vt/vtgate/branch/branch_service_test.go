@@ -1,7 +1,10 @@
 package branch
 
 import (
+	"fmt"
+	"github.com/DATA-DOG/go-sqlmock"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"sort"
 	"testing"
 	"vitess.io/vitess/go/vt/schemadiff"
@@ -348,3 +351,275 @@ func compareBranchDiff(t *testing.T, want, got *BranchDiff) {
 		}
 	}
 }
+
+func TestDryRunMergeBack(t *testing.T) {
+	tests := []struct {
+		name             string
+		sourceSchema     *BranchSchema
+		targetSchema     *BranchSchema
+		snapshot         *BranchSchema
+		mergeOption      MergeBackOption
+		wantDDLs         map[string][]string
+		wantConflict     bool
+		wantErrorsTables []string
+	}{
+		{
+			name: "override, clean merge",
+			sourceSchema: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+					},
+				},
+			},
+			targetSchema: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY, c1 INT)",
+					},
+				},
+			},
+			mergeOption: MergeOverride,
+			wantDDLs: map[string][]string{
+				"t1": {"ALTER TABLE `db1`.`t1` ADD COLUMN `c1` int"},
+			},
+		},
+		{
+			name: "diff, clean merge",
+			sourceSchema: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+						"t2": "CREATE TABLE t2 (id INT PRIMARY KEY)",
+					},
+				},
+			},
+			targetSchema: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY, c1 INT)",
+					},
+				},
+			},
+			snapshot: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+					},
+				},
+			},
+			mergeOption: MergeDiff,
+			wantDDLs: map[string][]string{
+				"t1": {"ALTER TABLE `db1`.`t1` ADD COLUMN `c1` int"},
+			},
+		},
+		{
+			name: "diff, column dropped in source but modified in target",
+			sourceSchema: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+					},
+				},
+			},
+			targetSchema: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY, c1 BIGINT)",
+					},
+				},
+			},
+			snapshot: &BranchSchema{
+				branchSchema: map[string]map[string]string{
+					"db1": {
+						"t1": "CREATE TABLE t1 (id INT PRIMARY KEY, c1 INT)",
+					},
+				},
+			},
+			mergeOption: MergeDiff,
+			wantDDLs: map[string][]string{
+				"t1": {"ALTER TABLE `db1`.`t1` MODIFY COLUMN `c1` bigint"},
+			},
+			wantConflict:     true,
+			wantErrorsTables: []string{"t1"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ddls, validationErrors, err := dryRunMergeBack(tt.sourceSchema, tt.targetSchema, tt.snapshot, tt.mergeOption, true, &schemadiff.DiffHints{})
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantDDLs, ddls.Diffs["db1"].TableDDLs)
+
+			conflict := false
+			errorsTables := make([]string, 0)
+			for _, validationError := range validationErrors {
+				if validationError.Table == "" {
+					assert.Contains(t, validationError.Message, "branch schemas conflict")
+					conflict = true
+					continue
+				}
+				errorsTables = append(errorsTables, validationError.Table)
+			}
+			assert.Equal(t, tt.wantConflict, conflict)
+			if len(tt.wantErrorsTables) == 0 {
+				assert.Empty(t, errorsTables)
+			} else {
+				assert.Equal(t, tt.wantErrorsTables, errorsTables)
+			}
+		})
+	}
+}
+
+func TestValidateMergeBackDDLs(t *testing.T) {
+	sourceSchema := &BranchSchema{
+		branchSchema: map[string]map[string]string{
+			"db1": {
+				"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+			},
+		},
+	}
+	ddls := &BranchDiff{
+		Diffs: map[string]*DatabaseDiff{
+			"db1": {
+				TableDDLs: map[string][]string{
+					"t1": {"ALTER TABLE `db1`.`t1` ADD COLUMN"},
+				},
+			},
+			"db2": {
+				NeedDropDatabase: true,
+			},
+		},
+	}
+
+	validationErrors, err := validateMergeBackDDLs(sourceSchema, ddls, false)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(validationErrors))
+	assert.Equal(t, "t1", validationErrors[0].Table)
+	assert.Contains(t, validationErrors[0].Message, "failed to parse ddl")
+
+	validationErrors, err = validateMergeBackDDLs(sourceSchema, ddls, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(validationErrors))
+	assert.Equal(t, "t1", validationErrors[0].Table)
+	assert.Equal(t, "db2", validationErrors[1].Database)
+	assert.Contains(t, validationErrors[1].Message, "does not exist in source")
+}
+
+func TestBranchDiffOnCreatingBranch(t *testing.T) {
+	bs := NewBranchService(nil, nil)
+	for _, status := range []BranchStatus{StatusInit, StatusFetched} {
+		_, err := bs.BranchDiff("test", status, []string{"*"}, nil, FromSourceToTarget, &schemadiff.DiffHints{})
+		assert.EqualError(t, err, fmt.Sprintf("branch test is not ready, its snapshot is still being created (status: %s), run branch create again to finish it", status))
+	}
+}
+
+func TestBranchStatusState(t *testing.T) {
+	assert.Equal(t, StateCreating, StatusInit.State())
+	assert.Equal(t, StateCreating, StatusFetched.State())
+	assert.Equal(t, StateReady, StatusCreated.State())
+	assert.Equal(t, StateReady, StatusMerged.State())
+}
+
+func TestBranchDiffSummary(t *testing.T) {
+	originSchema := &BranchSchema{
+		branchSchema: map[string]map[string]string{
+			"db1": {
+				"unchanged": "CREATE TABLE unchanged (id INT PRIMARY KEY)",
+				"altered1":  "CREATE TABLE altered1 (id INT PRIMARY KEY)",
+				"altered2":  "CREATE TABLE altered2 (id INT PRIMARY KEY, c1 INT)",
+				"dropped":   "CREATE TABLE dropped (id INT PRIMARY KEY)",
+			},
+			"db_dropped": {
+				"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+				"t2": "CREATE TABLE t2 (id INT PRIMARY KEY)",
+			},
+		},
+	}
+	expectSchema := &BranchSchema{
+		branchSchema: map[string]map[string]string{
+			"db1": {
+				"unchanged": "CREATE TABLE unchanged (id INT PRIMARY KEY)",
+				"altered1":  "CREATE TABLE altered1 (id INT PRIMARY KEY, c1 INT)",
+				"altered2":  "CREATE TABLE altered2 (id INT PRIMARY KEY)",
+				"added":     "CREATE TABLE added (id INT PRIMARY KEY)",
+			},
+			"db_created1": {
+				"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+			},
+			"db_created2": {
+				"t1": "CREATE TABLE t1 (id INT PRIMARY KEY)",
+				"t2": "CREATE TABLE t2 (id INT PRIMARY KEY)",
+			},
+		},
+	}
+
+	diff, err := getBranchSchemaDiff(originSchema, expectSchema, &schemadiff.DiffHints{})
+	assert.NoError(t, err)
+	summary := diff.Summary()
+	assert.Equal(t, BranchDiffSummary{
+		TablesAdded:      4,
+		TablesDropped:    1,
+		TablesAltered:    2,
+		DatabasesCreated: 2,
+		DatabasesDropped: 1,
+	}, summary)
+	assert.Equal(t, "4 tables added, 1 dropped, 2 altered, 2 databases created, 1 dropped", summary.String())
+}
+
+func TestBranchApplyMergeBackDDL(t *testing.T) {
+	sourceMysqlService, sourceMock := NewMockMysqlService(t)
+	targetMysqlService, targetMock := NewMockMysqlService(t)
+	bs := NewBranchService(NewSourceMySQLService(sourceMysqlService), NewTargetMySQLService(targetMysqlService))
+
+	expectSelectMergeBackDDL := func() {
+		selectSQL, err := GetSelectMergeBackDDLInBatchSQL("origin", 0, SelectBatchSize)
+		require.NoError(t, err)
+		rows := sqlmock.NewRows([]string{"id", "Name", "database", "table", "ddl", "merged"}).
+			AddRow(1, "origin", "db1", "t1", "ALTER TABLE `t1` ADD COLUMN `c1` int", false).
+			AddRow(2, "origin", "db1", "t2", "DROP TABLE `t2`", false).
+			AddRow(3, "origin", "db2", "", "CREATE DATABASE IF NOT EXISTS `db2`", false).
+			AddRow(4, "origin", "db1", "t3", "DROP TABLE `t3`", true)
+		targetMock.ExpectQuery(selectSQL).WillReturnRows(rows)
+	}
+	expectUpdateStatus := func(status BranchStatus) {
+		updateStatusSQL, err := getUpdateBranchStatusSQL("origin", status)
+		require.NoError(t, err)
+		targetMock.ExpectExec(updateStatusSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+	expectMerged := func(id int) {
+		updateMergedSQL, err := getUpdateDDLMergedSQL(id)
+		require.NoError(t, err)
+		targetMock.ExpectExec(updateMergedSQL).WillReturnResult(sqlmock.NewResult(0, 1))
+	}
+
+	// apply a subset of the DDLs, only those are marked merged
+	expectSelectMergeBackDDL()
+	expectUpdateStatus(StatusMerging)
+	sourceMock.ExpectExec("CREATE DATABASE IF NOT EXISTS `db2`").WillReturnResult(sqlmock.NewResult(0, 1))
+	expectMerged(3)
+	sourceMock.ExpectExec("USE db1; ALTER TABLE `t1` ADD COLUMN `c1` int").WillReturnResult(sqlmock.NewResult(0, 0))
+	expectMerged(1)
+	selectUnmergedSQL, err := getSelectUnmergedDDLInBatchSQL("origin", 0, 1)
+	require.NoError(t, err)
+	targetMock.ExpectQuery(selectUnmergedSQL).WillReturnRows(sqlmock.NewRows([]string{"id", "Name", "database", "table", "ddl", "merged"}).
+		AddRow(2, "origin", "db1", "t2", "DROP TABLE `t2`", false))
+
+	err = bs.BranchApplyMergeBackDDL("origin", StatusPrepared, []int{3, 1})
+	require.NoError(t, err)
+	require.NoError(t, sourceMock.ExpectationsWereMet())
+	require.NoError(t, targetMock.ExpectationsWereMet())
+
+	// the ids must belong to the branch and not be merged yet, nothing is applied otherwise
+	expectSelectMergeBackDDL()
+	err = bs.BranchApplyMergeBackDDL("origin", StatusMerging, []int{2, 5})
+	require.ErrorContains(t, err, "merge back ddl 5 does not belong to branch origin")
+	expectSelectMergeBackDDL()
+	err = bs.BranchApplyMergeBackDDL("origin", StatusMerging, []int{4})
+	require.ErrorContains(t, err, "merge back ddl 4 of branch origin is already merged")
+	require.NoError(t, sourceMock.ExpectationsWereMet())
+	require.NoError(t, targetMock.ExpectationsWereMet())
+
+	err = bs.BranchApplyMergeBackDDL("origin", StatusCreated, []int{2})
+	require.ErrorContains(t, err, "is invalid Status")
+}
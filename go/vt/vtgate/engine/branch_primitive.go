@@ -57,10 +57,14 @@ type Branch struct {
 	commandType BranchCommandType
 	params      branchParams
 
-	targetHost     string
-	targetPort     int
-	targetUser     string
-	targetPassword string
+	// the target password is not a command param, so that it's kept out of the query logs and the plan cache,
+	// the connections to the target use --branch_default_target_password.
+	targetHost string
+	targetPort int
+	targetUser string
+	// useTargetMySQL is set when the target is specified by the command params,
+	// the target is accessed through a MySQL connection instead of the current vtgate session.
+	useTargetMySQL bool
 
 	noInputs
 }
@@ -72,6 +76,10 @@ type branchParams interface {
 
 const (
 	BranchParamsName = "name"
+
+	BranchParamsTargetHost = "target_host"
+	BranchParamsTargetPort = "target_port"
+	BranchParamsTargetUser = "target_user"
 )
 
 const (
@@ -102,10 +110,25 @@ type BranchDiffParams struct {
 
 const (
 	BranchPrepareMergeBackParamsMergeOption = "merge_option"
+	BranchPrepareMergeBackParamsDryRun      = "dry_run"
+	BranchPrepareMergeBackParamsApplyCheck  = "apply_check"
 )
 
 type BranchPrepareMergeBackParams struct {
 	MergeOption string
+	// DryRun only calculates and validates the merge back DDLs, nothing will be written
+	DryRun bool
+	// ApplyCheck also applies the merge back DDLs to an in-memory copy of the source schema during a dry run
+	ApplyCheck bool
+}
+
+const (
+	BranchMergeBackParamsDDLIDs = "ddl_ids"
+)
+
+type BranchMergeBackParams struct {
+	// DDLIDs are the ids of the merge back DDLs to apply, all the unmerged DDLs are applied if it's empty
+	DDLIDs []int
 }
 
 const (
@@ -140,15 +163,6 @@ func BuildBranchPlan(branchCmd *sqlparser.BranchCommand) (*Branch, error) {
 		return nil, fmt.Errorf("invalid branch command params: %w", err)
 	}
 
-	b.targetHost = DefaultBranchTargetHost
-	if DefaultBranchTargetPort == -1 {
-		b.targetPort = share.GetMysqlServerPort()
-	} else {
-		b.targetPort = DefaultBranchTargetPort
-	}
-	b.targetUser = DefaultBranchTargetUser
-	b.targetPassword = DefaultBranchTargetPassword
-
 	return b, nil
 }
 
@@ -275,6 +289,15 @@ func (b *Branch) setAndValidateParams(paramsMap map[string]string) error {
 		b.name = DefaultBranchName
 	}
 
+	err := b.setTargetParams(paramsMap)
+	if err != nil {
+		return err
+	}
+	err = b.validateTargetParams()
+	if err != nil {
+		return err
+	}
+
 	var params branchParams
 	switch b.commandType {
 	case Create:
@@ -283,14 +306,16 @@ func (b *Branch) setAndValidateParams(paramsMap map[string]string) error {
 		params = &BranchDiffParams{}
 	case PrepareMergeBack:
 		params = &BranchPrepareMergeBackParams{}
+	case MergeBack:
+		params = &BranchMergeBackParams{}
 	case Show:
 		params = &BranchShowParams{}
-	case MergeBack, BranchDelete:
+	case BranchDelete:
 		return nil
 	default:
 		return fmt.Errorf("invalid branch command type: %s", b.commandType)
 	}
-	err := params.setValues(paramsMap)
+	err = params.setValues(paramsMap)
 	if err != nil {
 		return err
 	}
@@ -302,6 +327,61 @@ func (b *Branch) setAndValidateParams(paramsMap map[string]string) error {
 	return nil
 }
 
+// setTargetParams sets the target connection params, the ones not specified in params use the default values from flags.
+func (b *Branch) setTargetParams(params map[string]string) error {
+	b.targetHost = DefaultBranchTargetHost
+	if DefaultBranchTargetPort == -1 {
+		b.targetPort = share.GetMysqlServerPort()
+	} else {
+		b.targetPort = DefaultBranchTargetPort
+	}
+	b.targetUser = DefaultBranchTargetUser
+
+	if v, ok := params[BranchParamsTargetHost]; ok {
+		b.targetHost = v
+		b.useTargetMySQL = true
+		delete(params, BranchParamsTargetHost)
+	}
+
+	if v, ok := params[BranchParamsTargetPort]; ok {
+		port, err := strconv.Atoi(v)
+		if err != nil {
+			return fmt.Errorf("branch: target port is not a number")
+		}
+		b.targetPort = port
+		b.useTargetMySQL = true
+		delete(params, BranchParamsTargetPort)
+	}
+
+	if v, ok := params[BranchParamsTargetUser]; ok {
+		b.targetUser = v
+		b.useTargetMySQL = true
+		delete(params, BranchParamsTargetUser)
+	}
+
+	return nil
+}
+
+func (b *Branch) validateTargetParams() error {
+	if !b.useTargetMySQL {
+		return nil
+	}
+
+	if b.targetHost == "" {
+		return fmt.Errorf("branch: target host is required")
+	}
+
+	if b.targetPort <= 0 || b.targetPort > 65535 {
+		return fmt.Errorf("branch: target port %v is not a valid port", b.targetPort)
+	}
+
+	if b.targetUser == "" {
+		return fmt.Errorf("branch: target user is required")
+	}
+
+	return nil
+}
+
 func checkRedundantParams(params map[string]string) error {
 	if len(params) > 0 {
 		invalidParams := make([]string, 0)
@@ -411,6 +491,26 @@ func (bpp *BranchPrepareMergeBackParams) setValues(params map[string]string) err
 		bpp.MergeOption = string(branch.MergeOverride)
 	}
 
+	if v, ok := params[BranchPrepareMergeBackParamsDryRun]; ok {
+		dryRun, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid dry run: %s", v)
+		}
+		bpp.DryRun = dryRun
+		delete(params, BranchPrepareMergeBackParamsDryRun)
+	}
+
+	if v, ok := params[BranchPrepareMergeBackParamsApplyCheck]; ok {
+		applyCheck, err := strconv.ParseBool(v)
+		if err != nil {
+			return fmt.Errorf("invalid apply check: %s", v)
+		}
+		bpp.ApplyCheck = applyCheck
+		delete(params, BranchPrepareMergeBackParamsApplyCheck)
+	} else {
+		bpp.ApplyCheck = true
+	}
+
 	return checkRedundantParams(params)
 }
 
@@ -424,6 +524,30 @@ func (bpp *BranchPrepareMergeBackParams) validate() error {
 	return nil
 }
 
+func (bmp *BranchMergeBackParams) setValues(params map[string]string) error {
+	if v, ok := params[BranchMergeBackParamsDDLIDs]; ok {
+		for _, idStr := range strings.Split(v, ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(idStr))
+			if err != nil {
+				return fmt.Errorf("invalid merge back ddl id: %s", idStr)
+			}
+			bmp.DDLIDs = append(bmp.DDLIDs, id)
+		}
+		delete(params, BranchMergeBackParamsDDLIDs)
+	}
+
+	return checkRedundantParams(params)
+}
+
+func (bmp *BranchMergeBackParams) validate() error {
+	for _, id := range bmp.DDLIDs {
+		if id <= 0 {
+			return fmt.Errorf("invalid merge back ddl id: %d", id)
+		}
+	}
+	return nil
+}
+
 func (bsp *BranchShowParams) setValues(params map[string]string) error {
 	if v, ok := params[BranchShowParamsShowOption]; ok {
 		bsp.ShowOption = v
@@ -444,15 +568,18 @@ func (bsp *BranchShowParams) validate() error {
 	return nil
 }
 
-func createBranchSourceMysqlHandler(sourceUser, sourcePassword, sourceHost string, sourcePort int) (*branch.SourceMySQLService, error) {
-	sourceMysqlConfig := &mysql.Config{
-		User:                 sourceUser,
-		Passwd:               sourcePassword,
+func newBranchMysqlConfig(user, password, host string, port int) *mysql.Config {
+	return &mysql.Config{
+		User:                 user,
+		Passwd:               password,
 		Net:                  "tcp",
-		Addr:                 fmt.Sprintf("%s:%d", sourceHost, sourcePort),
+		Addr:                 fmt.Sprintf("%s:%d", host, port),
 		AllowNativePasswords: true,
 	}
-	sourceMysqlService, err := branch.NewMysqlServiceWithConfig(sourceMysqlConfig)
+}
+
+func createBranchSourceMysqlHandler(sourceUser, sourcePassword, sourceHost string, sourcePort int) (*branch.SourceMySQLService, error) {
+	sourceMysqlService, err := branch.NewMysqlServiceWithConfig(newBranchMysqlConfig(sourceUser, sourcePassword, sourceHost, sourcePort))
 	if err != nil {
 		return nil, err
 	}
@@ -460,14 +587,7 @@ func createBranchSourceMysqlHandler(sourceUser, sourcePassword, sourceHost strin
 	return sourceMysqlHandler, nil
 }
 
-func createBranchTargetMysqlHandler(targetUser, targetPassword, targetHost string, targetPort int) (*branch.TargetMySQLService, error) {
-	targetMysqlConfig := &mysql.Config{
-		User:                 targetUser,
-		Passwd:               targetPassword,
-		Net:                  "tcp",
-		Addr:                 fmt.Sprintf("%s:%d", targetHost, targetPort),
-		AllowNativePasswords: true,
-	}
+func createBranchTargetMysqlHandler(targetMysqlConfig *mysql.Config) (*branch.TargetMySQLService, error) {
 	targetMysqlService, err := branch.NewMysqlServiceWithConfig(targetMysqlConfig)
 	if err != nil {
 		return nil, err
@@ -476,6 +596,20 @@ func createBranchTargetMysqlHandler(targetUser, targetPassword, targetHost strin
 	return targetMysqlHandler, nil
 }
 
+// createBranchTargetHandler connects to the target specified by the command params,
+// or uses the current vtgate session if no target is specified.
+func (b *Branch) createBranchTargetHandler(cursor VCursor) (*branch.TargetMySQLService, error) {
+	if b.useTargetMySQL {
+		return createBranchTargetMysqlHandler(b.targetMysqlConfig())
+	}
+	return createBranchTargetVTGateHandler(cursor)
+}
+
+// targetMysqlConfig returns the config of the connections to the target specified by the command params.
+func (b *Branch) targetMysqlConfig() *mysql.Config {
+	return newBranchMysqlConfig(b.targetUser, DefaultBranchTargetPassword, b.targetHost, b.targetPort)
+}
+
 func createBranchTargetVTGateHandler(cursor VCursor) (*branch.TargetMySQLService, error) {
 	vtgateMysqlService := &VTGateMysqlService{VCursor: cursor}
 	targetMysqlHandler := branch.NewTargetMySQLService(vtgateMysqlService)
@@ -502,7 +636,7 @@ func (b *Branch) branchCreate(cursor VCursor) (*sqltypes.Result, error) {
 	if err != nil {
 		return nil, err
 	}
-	targetHandler, err := createBranchTargetVTGateHandler(cursor)
+	targetHandler, err := b.createBranchTargetHandler(cursor)
 	if err != nil {
 		return nil, err
 	}
@@ -517,18 +651,18 @@ func (b *Branch) branchDiff(cursor VCursor) (*sqltypes.Result, error) {
 	if !ok {
 		return nil, fmt.Errorf("branch diff: invalid branch command params")
 	}
-	meta, bs, _, _, err := getBranchDataStruct(b.name, cursor)
+	meta, bs, _, _, err := b.getBranchDataStruct(cursor)
 	if err != nil {
 		return nil, err
 	}
 
 	// todo enhancement: support diff hints?
-	diff, err := bs.BranchDiff(meta.Name, meta.IncludeDatabases, meta.ExcludeDatabases, branch.BranchDiffObjectsFlag(diffParams.CompareObjects), &schemadiff.DiffHints{})
+	diff, err := bs.BranchDiff(meta.Name, meta.Status, meta.IncludeDatabases, meta.ExcludeDatabases, branch.BranchDiffObjectsFlag(diffParams.CompareObjects), &schemadiff.DiffHints{})
 	if err != nil {
 		return nil, err
 	}
 
-	return buildBranchDiffResult(meta.Name, diff), nil
+	return buildBranchDiffResultWithSummary(meta.Name, diff), nil
 }
 
 func (b *Branch) branchPrepareMergeBack(cursor VCursor) (*sqltypes.Result, error) {
@@ -537,11 +671,19 @@ func (b *Branch) branchPrepareMergeBack(cursor VCursor) (*sqltypes.Result, error
 		return nil, fmt.Errorf("branch prepare merge back: invalid branch command params")
 	}
 
-	meta, bs, _, _, err := getBranchDataStruct(b.name, cursor)
+	meta, bs, _, _, err := b.getBranchDataStruct(cursor)
 	if err != nil {
 		return nil, err
 	}
 
+	if prepareMergeBackParams.DryRun {
+		diff, validationErrors, err := bs.BranchPrepareMergeBackDryRun(meta.Name, meta.Status, meta.IncludeDatabases, meta.ExcludeDatabases, branch.MergeBackOption(prepareMergeBackParams.MergeOption), prepareMergeBackParams.ApplyCheck, &schemadiff.DiffHints{})
+		if err != nil {
+			return nil, err
+		}
+		return buildBranchDryRunResult(meta.Name, diff, validationErrors), nil
+	}
+
 	// todo enhancement: support diff hints?
 	diff, err := bs.BranchPrepareMergeBack(meta.Name, meta.Status, meta.IncludeDatabases, meta.ExcludeDatabases, branch.MergeBackOption(prepareMergeBackParams.MergeOption), &schemadiff.DiffHints{})
 	if err != nil {
@@ -552,16 +694,23 @@ func (b *Branch) branchPrepareMergeBack(cursor VCursor) (*sqltypes.Result, error
 }
 
 func (b *Branch) branchMergeBack(cursor VCursor) (*sqltypes.Result, error) {
-	meta, bs, _, _, err := getBranchDataStruct(b.name, cursor)
+	mergeBackParams, ok := b.params.(*BranchMergeBackParams)
+	if !ok {
+		return nil, fmt.Errorf("branch merge back: invalid branch command params")
+	}
+	meta, bs, _, _, err := b.getBranchDataStruct(cursor)
 	if err != nil {
 		return nil, err
 	}
+	if len(mergeBackParams.DDLIDs) > 0 {
+		return &sqltypes.Result{}, bs.BranchApplyMergeBackDDL(meta.Name, meta.Status, mergeBackParams.DDLIDs)
+	}
 	return &sqltypes.Result{}, bs.BranchMergeBack(meta.Name, meta.Status)
 }
 
 func (b *Branch) branchCleanUp(cursor VCursor) (*sqltypes.Result, error) {
 	// get target handler
-	targetHandler, err := createBranchTargetVTGateHandler(cursor)
+	targetHandler, err := b.createBranchTargetHandler(cursor)
 	if err != nil {
 		return nil, err
 	}
@@ -575,7 +724,7 @@ func (b *Branch) branchShow(cursor VCursor) (*sqltypes.Result, error) {
 		return nil, fmt.Errorf("branch show: invalid branch command params")
 	}
 
-	meta, _, _, targetHandler, err := getBranchDataStruct(b.name, cursor)
+	meta, _, _, targetHandler, err := b.getBranchDataStruct(cursor)
 	if err != nil {
 		return nil, err
 	}
@@ -592,14 +741,14 @@ func (b *Branch) branchShow(cursor VCursor) (*sqltypes.Result, error) {
 	}
 }
 
-func getBranchDataStruct(name string, cursor VCursor) (*branch.BranchMeta, *branch.BranchService, *branch.SourceMySQLService, *branch.TargetMySQLService, error) {
+func (b *Branch) getBranchDataStruct(cursor VCursor) (*branch.BranchMeta, *branch.BranchService, *branch.SourceMySQLService, *branch.TargetMySQLService, error) {
 	// get target handler
-	targetHandler, err := createBranchTargetVTGateHandler(cursor)
+	targetHandler, err := b.createBranchTargetHandler(cursor)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
 	// get branch meta
-	meta, err := targetHandler.SelectAndValidateBranchMeta(name)
+	meta, err := targetHandler.SelectAndValidateBranchMeta(b.name)
 	if err != nil {
 		return nil, nil, nil, nil, err
 	}
@@ -633,12 +782,36 @@ func buildBranchDiffResult(name string, diff *branch.BranchDiff) *sqltypes.Resul
 	return &sqltypes.Result{Fields: fields, Rows: rows}
 }
 
+// buildBranchDiffResultWithSummary leads the DDLs with a row that summarizes them, e.g.
+// "-- 12 tables added, 3 dropped, 7 altered, 2 databases created, 0 dropped".
+// The summary is a comment, so that the "ddl" column can still be executed as is.
+func buildBranchDiffResultWithSummary(name string, diff *branch.BranchDiff) *sqltypes.Result {
+	result := buildBranchDiffResult(name, diff)
+	summaryRow := sqltypes.BuildVarCharRow(name, "", "", "-- "+diff.Summary().String())
+	result.Rows = append([][]sqltypes.Value{summaryRow}, result.Rows...)
+	return result
+}
+
+// buildBranchDryRunResult lists the merge back DDLs first, followed by the validation errors.
+// The "error" column of the DDL rows is empty, validation errors that are not related to a specific DDL have an empty "ddl" column.
+func buildBranchDryRunResult(name string, diff *branch.BranchDiff, validationErrors []*branch.MergeBackDDLValidationError) *sqltypes.Result {
+	result := buildBranchDiffResult(name, diff)
+	result.Fields = sqltypes.BuildVarCharFields("branch name", "database", "table", "ddl", "error")
+	for i := range result.Rows {
+		result.Rows[i] = append(result.Rows[i], sqltypes.NewVarChar(""))
+	}
+	for _, validationError := range validationErrors {
+		result.Rows = append(result.Rows, sqltypes.BuildVarCharRow(name, validationError.Database, validationError.Table, validationError.DDL, validationError.Message))
+	}
+	return result
+}
+
 func buildMetaResult(meta *branch.BranchMeta) (*sqltypes.Result, error) {
-	fields := sqltypes.BuildVarCharFields("name", "status", "source host", "source port", "source user", "include", "exclude")
+	fields := sqltypes.BuildVarCharFields("name", "status", "state", "source host", "source port", "source user", "include", "exclude")
 	rows := make([][]sqltypes.Value, 0)
 	include := strings.Join(meta.IncludeDatabases, ",")
 	exclude := strings.Join(meta.ExcludeDatabases, ",")
-	rows = append(rows, sqltypes.BuildVarCharRow(meta.Name, string(meta.Status), meta.SourceHost, strconv.Itoa(meta.SourcePort), meta.SourceUser, include, exclude))
+	rows = append(rows, sqltypes.BuildVarCharRow(meta.Name, string(meta.Status), meta.Status.State(), meta.SourceHost, strconv.Itoa(meta.SourcePort), meta.SourceUser, include, exclude))
 
 	return &sqltypes.Result{Fields: fields, Rows: rows}, nil
 }
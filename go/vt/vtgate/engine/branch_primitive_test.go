@@ -0,0 +1,211 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package engine
+
+import (
+	"net"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/mysql/fakesqldb"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vtgate/branch"
+)
+
+func newBranchCommand(cmdType string, params map[string]string) *sqlparser.BranchCommand {
+	withParams := &sqlparser.WithParams{}
+	for k, v := range params {
+		withParams.Keys = append(withParams.Keys, k)
+		withParams.Values = append(withParams.Values, v)
+	}
+	return &sqlparser.BranchCommand{Type: cmdType, Params: withParams}
+}
+
+// setDefaultBranchTargetPort sets the default target port for the test, since the mysql server port of vtgate isn't
+// available outside of it.
+func setDefaultBranchTargetPort(t *testing.T) {
+	defaultPort := DefaultBranchTargetPort
+	DefaultBranchTargetPort = 15306
+	t.Cleanup(func() { DefaultBranchTargetPort = defaultPort })
+}
+
+func TestBuildBranchPlanWithTargetParams(t *testing.T) {
+	setDefaultBranchTargetPort(t)
+	b, err := BuildBranchPlan(newBranchCommand(string(Create), map[string]string{
+		BranchCreateParamsSourceHost: "source.example.com",
+		BranchParamsTargetHost:       "target.example.com",
+		BranchParamsTargetPort:       "15307",
+		BranchParamsTargetUser:       "branch_user",
+	}))
+	require.NoError(t, err)
+	assert.True(t, b.useTargetMySQL)
+	assert.Equal(t, "target.example.com", b.targetHost)
+	assert.Equal(t, 15307, b.targetPort)
+	assert.Equal(t, "branch_user", b.targetUser)
+	config := b.targetMysqlConfig()
+	assert.Equal(t, "target.example.com:15307", config.Addr)
+	assert.Equal(t, "branch_user", config.User)
+	assert.Equal(t, DefaultBranchTargetPassword, config.Passwd)
+
+	createParams, ok := b.params.(*BranchCreateParams)
+	require.True(t, ok)
+	assert.Equal(t, "source.example.com", createParams.SourceHost)
+
+	// unspecified target params use the default values
+	b, err = BuildBranchPlan(newBranchCommand(string(Diff), map[string]string{
+		BranchParamsTargetHost: "target.example.com",
+		BranchParamsTargetUser: "branch_user",
+	}))
+	require.NoError(t, err)
+	assert.True(t, b.useTargetMySQL)
+	assert.Equal(t, "target.example.com", b.targetHost)
+	assert.Equal(t, "branch_user", b.targetUser)
+
+	// without target params, the current vtgate session is used as the target
+	b, err = BuildBranchPlan(newBranchCommand(string(MergeBack), nil))
+	require.NoError(t, err)
+	assert.False(t, b.useTargetMySQL)
+}
+
+func TestCreateBranchTargetHandlerWithTargetParams(t *testing.T) {
+	setDefaultBranchTargetPort(t)
+	defaultPassword := DefaultBranchTargetPassword
+	DefaultBranchTargetPassword = "branch_password"
+	t.Cleanup(func() { DefaultBranchTargetPassword = defaultPassword })
+
+	// the target only accepts branch_user with the default target password
+	db := fakesqldb.New(t)
+	defer db.Close()
+	db.AddQuery("SELECT @@max_allowed_packet", sqltypes.MakeTestResult(sqltypes.MakeTestFields("@@max_allowed_packet", "int64"), "67108864"))
+	authServer := mysql.NewAuthServerStatic("", `{"branch_user": [{"Password": "branch_password"}]}`, 0)
+	listener, err := mysql.NewListener("tcp", "127.0.0.1:0", authServer, db, 0, 0, false, false)
+	require.NoError(t, err)
+	defer listener.Close()
+	go listener.Accept()
+	port := listener.Addr().(*net.TCPAddr).Port
+
+	b, err := BuildBranchPlan(newBranchCommand(string(Show), map[string]string{
+		BranchParamsTargetHost: "127.0.0.1",
+		BranchParamsTargetPort: strconv.Itoa(port),
+		BranchParamsTargetUser: "branch_user",
+	}))
+	require.NoError(t, err)
+	targetHandler, err := b.createBranchTargetHandler(nil)
+	require.NoError(t, err)
+	targetService, ok := targetHandler.GetMysqlService().(*branch.NativeMysqlService)
+	require.True(t, ok)
+	defer targetService.Close()
+
+	b, err = BuildBranchPlan(newBranchCommand(string(Show), map[string]string{
+		BranchParamsTargetHost: "127.0.0.1",
+		BranchParamsTargetPort: strconv.Itoa(port),
+		BranchParamsTargetUser: "other_user",
+	}))
+	require.NoError(t, err)
+	_, err = b.createBranchTargetHandler(nil)
+	assert.ErrorContains(t, err, "Access denied")
+}
+
+func TestBuildBranchPlanWithInvalidTargetParams(t *testing.T) {
+	setDefaultBranchTargetPort(t)
+	testCases := []struct {
+		name   string
+		params map[string]string
+		err    string
+	}{
+		{
+			name:   "port is not a number",
+			params: map[string]string{BranchParamsTargetHost: "target.example.com", BranchParamsTargetPort: "abc"},
+			err:    "target port is not a number",
+		},
+		{
+			name:   "port out of range",
+			params: map[string]string{BranchParamsTargetHost: "target.example.com", BranchParamsTargetPort: "70000"},
+			err:    "target port 70000 is not a valid port",
+		},
+		{
+			name:   "empty host",
+			params: map[string]string{BranchParamsTargetHost: "", BranchParamsTargetPort: "15306"},
+			err:    "target host is required",
+		},
+		{
+			name:   "password is not a command param",
+			params: map[string]string{BranchParamsTargetHost: "target.example.com", "target_password": "branch_password"},
+			err:    "target_password",
+		},
+		{
+			name:   "empty user",
+			params: map[string]string{BranchParamsTargetHost: "target.example.com", BranchParamsTargetPort: "15306", BranchParamsTargetUser: ""},
+			err:    "target user is required",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := BuildBranchPlan(newBranchCommand(string(Show), tc.params))
+			require.ErrorContains(t, err, tc.err)
+		})
+	}
+}
+
+func TestBuildBranchPlanPrepareMergeBackApplyCheck(t *testing.T) {
+	setDefaultBranchTargetPort(t)
+	b, err := BuildBranchPlan(newBranchCommand(string(PrepareMergeBack), map[string]string{
+		BranchPrepareMergeBackParamsDryRun: "true",
+	}))
+	require.NoError(t, err)
+	params, ok := b.params.(*BranchPrepareMergeBackParams)
+	require.True(t, ok)
+	assert.True(t, params.DryRun)
+	// the DDLs are applied to the source schema by default
+	assert.True(t, params.ApplyCheck)
+
+	b, err = BuildBranchPlan(newBranchCommand(string(PrepareMergeBack), map[string]string{
+		BranchPrepareMergeBackParamsDryRun:     "true",
+		BranchPrepareMergeBackParamsApplyCheck: "false",
+	}))
+	require.NoError(t, err)
+	params, ok = b.params.(*BranchPrepareMergeBackParams)
+	require.True(t, ok)
+	assert.False(t, params.ApplyCheck)
+
+	_, err = BuildBranchPlan(newBranchCommand(string(PrepareMergeBack), map[string]string{
+		BranchPrepareMergeBackParamsApplyCheck: "maybe",
+	}))
+	require.ErrorContains(t, err, "invalid apply check: maybe")
+}
+
+func TestBuildBranchPlanMergeBackDDLIDs(t *testing.T) {
+	setDefaultBranchTargetPort(t)
+	b, err := BuildBranchPlan(newBranchCommand(string(MergeBack), nil))
+	require.NoError(t, err)
+	params, ok := b.params.(*BranchMergeBackParams)
+	require.True(t, ok)
+	// all the unmerged DDLs are applied by default
+	assert.Empty(t, params.DDLIDs)
+
+	b, err = BuildBranchPlan(newBranchCommand(string(MergeBack), map[string]string{
+		BranchMergeBackParamsDDLIDs: "3, 1,2",
+	}))
+	require.NoError(t, err)
+	params, ok = b.params.(*BranchMergeBackParams)
+	require.True(t, ok)
+	assert.Equal(t, []int{3, 1, 2}, params.DDLIDs)
+
+	_, err = BuildBranchPlan(newBranchCommand(string(MergeBack), map[string]string{
+		BranchMergeBackParamsDDLIDs: "1,a",
+	}))
+	require.ErrorContains(t, err, "invalid merge back ddl id: a")
+
+	_, err = BuildBranchPlan(newBranchCommand(string(MergeBack), map[string]string{
+		BranchMergeBackParamsDDLIDs: "0",
+	}))
+	require.ErrorContains(t, err, "invalid merge back ddl id: 0")
+}
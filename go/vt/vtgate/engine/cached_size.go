@@ -67,7 +67,7 @@ func (cached *Branch) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(64)
+		size += int64(96)
 	}
 	// field name string
 	size += hack.RuntimeAllocSize(int64(len(cached.name)))
@@ -77,6 +77,10 @@ func (cached *Branch) CachedSize(alloc bool) int64 {
 	if cc, ok := cached.params.(cachedObject); ok {
 		size += cc.CachedSize(true)
 	}
+	// field targetHost string
+	size += hack.RuntimeAllocSize(int64(len(cached.targetHost)))
+	// field targetUser string
+	size += hack.RuntimeAllocSize(int64(len(cached.targetUser)))
 	return size
 }
 func (cached *BranchCreateParams) CachedSize(alloc bool) int64 {
@@ -119,7 +123,7 @@ func (cached *BranchPrepareMergeBackParams) CachedSize(alloc bool) int64 {
 	}
 	size := int64(0)
 	if alloc {
-		size += int64(16)
+		size += int64(24)
 	}
 	// field MergeOption string
 	size += hack.RuntimeAllocSize(int64(len(cached.MergeOption)))
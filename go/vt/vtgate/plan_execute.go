@@ -494,6 +494,10 @@ func IsSubmitDMLJob(stmt sqlparser.Statement) bool {
 func HandleDMLJobRequest(stmt sqlparser.Statement, vcursor *vcursorImpl, sql string) (*sqltypes.Result, error) {
 	if IsShowDMLJob(stmt) {
 		showDMLJob, _ := stmt.(*sqlparser.Show).Internal.(*sqlparser.ShowDMLJob)
+		if showDMLJob.TagLike != "" || showDMLJob.BatchID != "" || showDMLJob.JSON || showDMLJob.Plan {
+			// the other shows are sent to the primary tablet by the plan, see buildShowDMLJobPlan
+			return nil, nil
+		}
 		qr, err := vcursor.executor.ShowDMLJob(showDMLJob.UUID, showDMLJob.Detail)
 		return qr, err
 	}
@@ -193,11 +193,12 @@ func buildShowDMLJobPlan(show *sqlparser.ShowDMLJob, vschema plancontext.VSchema
 		dest = key.DestinationAllShards{}
 	}
 
-	// Here we don't do any queries, we do queries in func ShowJob of JobController in controller.go
+	// The show is executed by the JobController of the primary tablet, see execShowDMLJob in query_executor.go.
+	// The plain SHOW DML_JOB is intercepted by HandleDMLJobRequest in plan_execute.go before it's sent.
 	return &engine.Send{
 		Keyspace:          ks,
 		TargetDestination: dest,
-		Query:             "",
+		Query:             sqlparser.String(show),
 	}, nil
 }
 
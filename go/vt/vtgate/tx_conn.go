@@ -43,6 +43,10 @@ import (
 type TxConn struct {
 	tabletGateway *TabletGateway
 	mode          vtgatepb.TransactionMode
+
+	// preCommitValidationQuery is executed in the transaction of every participant
+	// of a 2PC commit before they are prepared, see SetPreCommitValidation.
+	preCommitValidationQuery string
 }
 
 // NewTxConn builds a new TxConn.
@@ -53,6 +57,14 @@ func NewTxConn(gw *TabletGateway, txMode vtgatepb.TransactionMode) *TxConn {
 	}
 }
 
+// SetPreCommitValidation sets a query that validates a 2PC transaction before it is committed,
+// e.g. to verify an invariant across the shards. The query is executed in the transaction of every
+// participant before the prepare phase, and the transaction is rolled back unless it returns a row
+// whose first column is 1 on all of them. An empty query disables the validation.
+func (txc *TxConn) SetPreCommitValidation(query string) {
+	txc.preCommitValidationQuery = query
+}
+
 var txAccessModeToEOTxAccessMode = map[sqlparser.TxAccessMode]querypb.ExecuteOptions_TransactionAccessMode{
 	sqlparser.WithConsistentSnapshot: querypb.ExecuteOptions_CONSISTENT_SNAPSHOT,
 	sqlparser.ReadWrite:              querypb.ExecuteOptions_READ_WRITE,
@@ -177,6 +189,12 @@ func (txc *TxConn) commit2PC(ctx context.Context, session *SafeSession) error {
 		return txc.commitNormal(ctx, session)
 	}
 
+	if err := txc.validatePreCommit(ctx, session); err != nil {
+		// Normal rollback is safe because nothing was prepared yet.
+		_ = txc.Rollback(ctx, session)
+		return err
+	}
+
 	participants := make([]*querypb.Target, 0, len(session.ShardSessions)-1)
 	for _, s := range session.ShardSessions[1:] {
 		participants = append(participants, s.Target)
@@ -218,6 +236,32 @@ func (txc *TxConn) commit2PC(ctx context.Context, session *SafeSession) error {
 	return txc.tabletGateway.ConcludeTransaction(ctx, mmShard.Target, dtid)
 }
 
+// validatePreCommit executes the pre-commit validation query in the transaction of every participant,
+// it returns an error if it fails or doesn't return 1 on any of them.
+func (txc *TxConn) validatePreCommit(ctx context.Context, session *SafeSession) error {
+	if txc.preCommitValidationQuery == "" {
+		return nil
+	}
+	return txc.runSessions(ctx, session.ShardSessions, session.logging, func(ctx context.Context, s *vtgatepb.Session_ShardSession, logging *executeLogger) error {
+		qs, err := txc.queryService(s.TabletAlias)
+		if err != nil {
+			return err
+		}
+		qr, err := qs.Execute(ctx, s.Target, txc.preCommitValidationQuery, nil, s.TransactionId, s.ReservedId, session.Options)
+		if err != nil {
+			return vterrors.Wrapf(err, "pre-commit validation failed on %s/%s", s.Target.Keyspace, s.Target.Shard)
+		}
+		logging.log(nil, s.Target, nil, txc.preCommitValidationQuery, false, nil)
+		if len(qr.Rows) == 0 || len(qr.Rows[0]) == 0 {
+			return vterrors.Errorf(vtrpcpb.Code_ABORTED, "pre-commit validation failed on %s/%s: no result", s.Target.Keyspace, s.Target.Shard)
+		}
+		if ok, err := qr.Rows[0][0].ToBool(); err != nil || !ok {
+			return vterrors.Errorf(vtrpcpb.Code_ABORTED, "pre-commit validation failed on %s/%s: got %s", s.Target.Keyspace, s.Target.Shard, qr.Rows[0][0].ToString())
+		}
+		return nil
+	})
+}
+
 // Rollback rolls back the current transaction. There are no retries on this operation.
 func (txc *TxConn) Rollback(ctx context.Context, session *SafeSession) error {
 	if !session.InTransaction() {
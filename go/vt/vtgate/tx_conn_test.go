@@ -23,6 +23,7 @@ import (
 
 	"github.com/stretchr/testify/assert"
 
+	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/test/utils"
 
 	"github.com/stretchr/testify/require"
@@ -804,6 +805,52 @@ func TestTxConnCommit2PCOneParticipant(t *testing.T) {
 	assert.EqualValues(t, 1, sbc0.CommitCount.Get(), "sbc0.CommitCount")
 }
 
+func TestTxConnCommit2PCPreCommitValidation(t *testing.T) {
+	sc, sbc0, sbc1, rss0, _, rss01 := newTestTxConnEnv(t, "TestTxConnCommit2PCPreCommitValidation")
+	validationQuery := "select sum(balance) >= 0 from accounts"
+	sc.txConn.SetPreCommitValidation(validationQuery)
+
+	session := NewSafeSession(&vtgatepb.Session{InTransaction: true})
+	sc.ExecuteMultiShard(ctx, nil, rss0, queries, session, false, false)
+	sc.ExecuteMultiShard(ctx, nil, rss01, twoQueries, session, false, false)
+	session.TransactionMode = vtgatepb.TransactionMode_TWOPC
+	require.NoError(t,
+		sc.txConn.Commit(ctx, session))
+	// the validation is executed in the transaction of every participant
+	require.Len(t, sbc0.Queries, 3)
+	assert.Equal(t, validationQuery, sbc0.Queries[2].Sql)
+	require.Len(t, sbc1.Queries, 2)
+	assert.Equal(t, validationQuery, sbc1.Queries[1].Sql)
+	assert.EqualValues(t, 1, sbc0.CreateTransactionCount.Get(), "sbc0.CreateTransactionCount")
+	assert.EqualValues(t, 1, sbc1.PrepareCount.Get(), "sbc1.PrepareCount")
+	assert.EqualValues(t, 1, sbc0.StartCommitCount.Get(), "sbc0.StartCommitCount")
+	assert.EqualValues(t, 1, sbc1.CommitPreparedCount.Get(), "sbc1.CommitPreparedCount")
+	assert.EqualValues(t, 1, sbc0.ConcludeTransactionCount.Get(), "sbc0.ConcludeTransactionCount")
+}
+
+func TestTxConnCommit2PCPreCommitValidationFail(t *testing.T) {
+	sc, sbc0, sbc1, rss0, _, rss01 := newTestTxConnEnv(t, "TestTxConnCommit2PCPreCommitValidationFail")
+	sc.txConn.SetPreCommitValidation("select sum(balance) >= 0 from accounts")
+
+	session := NewSafeSession(&vtgatepb.Session{InTransaction: true})
+	sc.ExecuteMultiShard(ctx, nil, rss0, queries, session, false, false)
+	sc.ExecuteMultiShard(ctx, nil, rss01, twoQueries, session, false, false)
+
+	sbc1.SetResults([]*sqltypes.Result{sqltypes.MakeTestResult(sqltypes.MakeTestFields("valid", "int64"), "0")})
+	session.TransactionMode = vtgatepb.TransactionMode_TWOPC
+	err := sc.txConn.Commit(ctx, session)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "pre-commit validation failed on TestTxConnCommit2PCPreCommitValidationFail/1: got 0")
+	assert.Equal(t, vtrpcpb.Code_ABORTED, vterrors.Code(err))
+	assert.EqualValues(t, 1, sbc0.RollbackCount.Get(), "sbc0.RollbackCount")
+	assert.EqualValues(t, 1, sbc1.RollbackCount.Get(), "sbc1.RollbackCount")
+	assert.EqualValues(t, 0, sbc0.CreateTransactionCount.Get(), "sbc0.CreateTransactionCount")
+	assert.EqualValues(t, 0, sbc1.PrepareCount.Get(), "sbc1.PrepareCount")
+	assert.EqualValues(t, 0, sbc0.StartCommitCount.Get(), "sbc0.StartCommitCount")
+	assert.EqualValues(t, 0, sbc1.CommitPreparedCount.Get(), "sbc1.CommitPreparedCount")
+	assert.False(t, session.InTransaction())
+}
+
 func TestTxConnCommit2PCCreateTransactionFail(t *testing.T) {
 	sc, sbc0, sbc1, rss0, rss1, _ := newTestTxConnEnv(t, "TestTxConnCommit2PCCreateTransactionFail")
 
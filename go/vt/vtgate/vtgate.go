@@ -79,6 +79,8 @@ var (
 	normalizeQueries = true
 	streamBufferSize = 32 * 1024
 
+	twopcPreCommitValidationQuery string
+
 	terseErrors bool
 
 	// plan cache related flag
@@ -153,6 +155,7 @@ var (
 
 func registerFlags(fs *pflag.FlagSet) {
 	fs.StringVar(&transactionMode, "transaction_mode", transactionMode, "SINGLE: disallow multi-db transactions, MULTI: allow multi-db transactions with best effort commit, TWOPC: allow multi-db transactions with 2pc commit")
+	fs.StringVar(&twopcPreCommitValidationQuery, "twopc_pre_commit_validation_query", twopcPreCommitValidationQuery, "query executed in the transaction of every participant of a 2pc commit before they are prepared. The transaction is rolled back unless it returns 1 on all of them. Empty disables the validation")
 	fs.BoolVar(&normalizeQueries, "normalize_queries", normalizeQueries, "Rewrite queries with bind vars. Turn this off if the app itself sends normalized queries with bind vars.")
 	fs.BoolVar(&terseErrors, "vtgate-config-terse-errors", terseErrors, "prevent bind vars from escaping in returned errors")
 	fs.IntVar(&streamBufferSize, "stream_buffer_size", streamBufferSize, "the number of bytes sent from vtgate for each stream call. It's recommended to keep this value in sync with vttablet's query-server-config-stream-buffer-size.")
@@ -309,6 +312,7 @@ func Init(
 		log.Fatalf("Invalid value for -read_after_write_consistency: %v", err.Error())
 	}
 	tc := NewTxConn(gw, getTxMode())
+	tc.SetPreCommitValidation(twopcPreCommitValidationQuery)
 	// ScatterConn depends on TxConn to perform forced rollbacks.
 	sc := NewScatterConn("VttabletCall", tc, gw)
 	srvResolver := srvtopo.NewResolver(serv, gw, cell)
@@ -239,6 +239,11 @@ func (client *FakeTabletManagerClient) PrimaryPosition(ctx context.Context, tabl
 	return "", nil
 }
 
+// FlushBinaryLogs is part of the tmclient.TabletManagerClient interface.
+func (client *FakeTabletManagerClient) FlushBinaryLogs(ctx context.Context, tablet *topodatapb.Tablet) (string, error) {
+	return "", nil
+}
+
 // WaitForPosition is part of the tmclient.TabletManagerClient interface.
 func (client *FakeTabletManagerClient) WaitForPosition(ctx context.Context, tablet *topodatapb.Tablet, pos string) error {
 	return nil
@@ -303,6 +308,11 @@ func (client *FakeTabletManagerClient) SetReplicationSource(ctx context.Context,
 	return nil
 }
 
+// SetReplicationSourceWithoutStart is part of the tmclient.TabletManagerClient interface.
+func (client *FakeTabletManagerClient) SetReplicationSourceWithoutStart(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias, semiSync bool) error {
+	return nil
+}
+
 // StopReplicationAndGetStatus is part of the tmclient.TabletManagerClient interface.
 func (client *FakeTabletManagerClient) StopReplicationAndGetStatus(ctx context.Context, tablet *topodatapb.Tablet, stopReplicationMode replicationdatapb.StopReplicationMode) (*replicationdatapb.StopReplicationStatus, error) {
 	return &replicationdatapb.StopReplicationStatus{}, nil
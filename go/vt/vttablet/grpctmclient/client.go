@@ -604,6 +604,20 @@ func (client *Client) PrimaryPosition(ctx context.Context, tablet *topodatapb.Ta
 	return response.Position, nil
 }
 
+// FlushBinaryLogs is part of the tmclient.TabletManagerClient interface.
+func (client *Client) FlushBinaryLogs(ctx context.Context, tablet *topodatapb.Tablet) (string, error) {
+	c, closer, err := client.dialer.dial(ctx, tablet)
+	if err != nil {
+		return "", err
+	}
+	defer closer.Close()
+	response, err := c.FlushBinaryLogs(ctx, &tabletmanagerdatapb.FlushBinaryLogsRequest{})
+	if err != nil {
+		return "", err
+	}
+	return response.Position, nil
+}
+
 // WaitForPosition is part of the tmclient.TabletManagerClient interface.
 func (client *Client) WaitForPosition(ctx context.Context, tablet *topodatapb.Tablet, pos string) error {
 	c, closer, err := client.dialer.dial(ctx, tablet)
@@ -872,6 +886,22 @@ func (client *Client) SetReplicationSource(ctx context.Context, tablet *topodata
 	return err
 }
 
+// SetReplicationSourceWithoutStart is part of the tmclient.TabletManagerClient interface.
+func (client *Client) SetReplicationSourceWithoutStart(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias, semiSync bool) error {
+	c, closer, err := client.dialer.dial(ctx, tablet)
+	if err != nil {
+		return err
+	}
+	defer closer.Close()
+
+	_, err = c.SetReplicationSource(ctx, &tabletmanagerdatapb.SetReplicationSourceRequest{
+		Parent:     parent,
+		SemiSync:   semiSync,
+		ConfigOnly: true,
+	})
+	return err
+}
+
 // ReplicaWasRestarted is part of the tmclient.TabletManagerClient interface.
 func (client *Client) ReplicaWasRestarted(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias) error {
 	c, closer, err := client.dialer.dial(ctx, tablet)
@@ -42,6 +42,7 @@ import (
 	logutilpb "vitess.io/vitess/go/vt/proto/logutil"
 	tabletmanagerdatapb "vitess.io/vitess/go/vt/proto/tabletmanagerdata"
 	tabletmanagerservicepb "vitess.io/vitess/go/vt/proto/tabletmanagerservice"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
 // server is the gRPC implementation of the RPC server
@@ -300,6 +301,17 @@ func (s *server) PrimaryPosition(ctx context.Context, request *tabletmanagerdata
 	return response, err
 }
 
+func (s *server) FlushBinaryLogs(ctx context.Context, request *tabletmanagerdatapb.FlushBinaryLogsRequest) (response *tabletmanagerdatapb.FlushBinaryLogsResponse, err error) {
+	defer s.tm.HandleRPCPanic(ctx, "FlushBinaryLogs", request, response, true /*verbose*/, &err)
+	ctx = callinfo.GRPCCallInfo(ctx)
+	response = &tabletmanagerdatapb.FlushBinaryLogsResponse{}
+	position, err := s.tm.FlushBinaryLogs(ctx)
+	if err == nil {
+		response.Position = position
+	}
+	return response, err
+}
+
 func (s *server) WaitForPosition(ctx context.Context, request *tabletmanagerdatapb.WaitForPositionRequest) (response *tabletmanagerdatapb.WaitForPositionResponse, err error) {
 	defer s.tm.HandleRPCPanic(ctx, "WaitForPosition", request, response, false /*verbose*/, &err)
 	ctx = callinfo.GRPCCallInfo(ctx)
@@ -453,6 +465,12 @@ func (s *server) SetReplicationSource(ctx context.Context, request *tabletmanage
 	defer s.tm.HandleRPCPanic(ctx, "SetReplicationSource", request, response, true /*verbose*/, &err)
 	ctx = callinfo.GRPCCallInfo(ctx)
 	response = &tabletmanagerdatapb.SetReplicationSourceResponse{}
+	if request.ConfigOnly {
+		if request.ForceStartReplication {
+			return response, vterrors.New(vtrpcpb.Code_INVALID_ARGUMENT, "cannot force replication to start when only setting the replication source")
+		}
+		return response, s.tm.SetReplicationSourceWithoutStart(ctx, request.Parent, request.GetSemiSync())
+	}
 	return response, s.tm.SetReplicationSource(ctx, request.Parent, request.TimeCreatedNs, request.WaitPosition, request.ForceStartReplication, request.GetSemiSync())
 }
 
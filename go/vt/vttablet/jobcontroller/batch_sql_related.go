@@ -10,6 +10,7 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
 
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/connpool"
 
@@ -50,6 +51,30 @@ func genPKsGreaterEqualOrLessEqualStr(pkInfos []PKInfo, currentBatchStart []sqlt
 	return buf.String(), nil
 }
 
+// genPKsAfterStr generates the condition that the PK comes after lastPK in batchOrder. For example,
+// if lastPK was (1,2) and the order is ascending, then clause would be: (col1 > 1) or (col1 = 1 and col2 > 2).
+func genPKsAfterStr(pkInfos []PKInfo, lastPK []sqltypes.Value, batchOrder string) string {
+	op := ">"
+	if batchOrder == batchOrderDesc {
+		op = "<"
+	}
+	buf := sqlparser.NewTrackedBuffer(nil)
+	prefix := ""
+	for curCol := range pkInfos {
+		buf.Myprintf("%s(", prefix)
+		prefix = " or "
+		for i, pk := range lastPK[:curCol] {
+			buf.Myprintf("%s = ", pkInfos[i].pkName)
+			pk.EncodeSQL(buf)
+			buf.Myprintf(" and ")
+		}
+		buf.Myprintf("%s %s ", pkInfos[curCol].pkName, op)
+		lastPK[curCol].EncodeSQL(buf)
+		buf.Myprintf(")")
+	}
+	return buf.String()
+}
+
 func genPKConditionExprByStr(greatThanPart, lessThanPart string) (sqlparser.Expr, error) {
 	tmpSQL := fmt.Sprintf("select 1 where (%s) AND (%s)", greatThanPart, lessThanPart)
 	tmpStmt, err := sqlparser.Parse(tmpSQL)
@@ -86,30 +111,132 @@ func genBatchSQL(stmt sqlparser.Statement, whereExpr sqlparser.Expr, currentBatc
 		return "", "", errors.New("genBatchSQL: stmt is nil")
 	}
 
+	// 1.Generate the pk condition expr ast node of the batch
+	pkConditionExpr, err := genBatchPKConditionExpr(currentBatchStart, currentBatchEnd, pkInfos)
+	if err != nil {
+		return "", "", err
+	}
+
+	// 2.Concatenate the where expr ast node of the original SQL with pkConditionExpr using AND to get the where expr of batchSQL.
+	andExpr := sqlparser.Where{Expr: &sqlparser.AndExpr{Left: whereExpr, Right: pkConditionExpr}}
+	batchSQL = genSQLByReplaceWhereExprNode(stmt, andExpr)
+	finalWhereStr = sqlparser.String(andExpr.Expr)
+
+	return batchSQL, finalWhereStr, nil
+}
+
+// genBatchPKConditionExpr generates the condition that the PK is in [currentBatchStart, currentBatchEnd].
+func genBatchPKConditionExpr(currentBatchStart, currentBatchEnd []sqltypes.Value, pkInfos []PKInfo) (sqlparser.Expr, error) {
 	// 1. generate PK condition part of >=
 	greatThanPart, err := genPKsGreaterEqualOrLessEqualStr(pkInfos, currentBatchStart, true)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	// 2.generate generate PK condition part of <=
 	lessThanPart, err := genPKsGreaterEqualOrLessEqualStr(pkInfos, currentBatchEnd, false)
 	if err != nil {
-		return "", "", err
+		return nil, err
 	}
 
 	// 3.Concatenate pk condition part of >= and <= to generate pk condition expr ast node
-	pkConditionExpr, err := genPKConditionExprByStr(greatThanPart, lessThanPart)
+	return genPKConditionExprByStr(greatThanPart, lessThanPart)
+}
+
+// pkRangePlaceholder stands for the PK range condition of a batch in countSQLTemplate.
+const pkRangePlaceholder = "pk_range"
+
+// jobSQLTemplates are the SQLs generated from the DML of a job before it is split into batches.
+// selectSQL selects the PKs of the rows affected by the job, which are split into batches,
+// and countSQLTemplate counts the rows of a batch once :pk_range is replaced by the PK range of the batch.
+type jobSQLTemplates struct {
+	selectSQL        string
+	countSQLTemplate string
+	wherePart        string
+	pkPart           string
+}
+
+func genJobSQLTemplates(tableName string, whereExpr sqlparser.Expr, pkInfos []PKInfo, batchOrder string) jobSQLTemplates {
+	wherePart := sqlparser.String(whereExpr)
+	countWhereExpr := &sqlparser.AndExpr{Left: whereExpr, Right: sqlparser.NewArgument(pkRangePlaceholder)}
+	return jobSQLTemplates{
+		selectSQL:        sprintfSelectPksSQL(tableName, wherePart, pkInfos, batchOrder),
+		countSQLTemplate: genCountSQL(tableName, sqlparser.String(countWhereExpr)),
+		wherePart:        wherePart,
+		pkPart:           genPKColsStr(pkInfos),
+	}
+}
+
+// validateBatchSQLs generates the batch SQL and the count SQL of a sample batch of a job, and checks that
+// they parse and that they are on tableName and bounded by its PK columns pkNames.
+func validateBatchSQLs(tableName string, whereExprs []sqlparser.Expr, stmts []sqlparser.Statement, pkNames []string) error {
+	if len(pkNames) == 0 {
+		return fmt.Errorf("the table %s has no primary key", tableName)
+	}
+	// the PK values are only literals in the batch SQLs, their type doesn't matter here
+	pkInfos := make([]PKInfo, 0, len(pkNames))
+	samplePKs := make([]sqltypes.Value, 0, len(pkNames))
+	for _, pkName := range pkNames {
+		pkInfos = append(pkInfos, PKInfo{pkName: pkName, pkType: sqltypes.Int64})
+		samplePKs = append(samplePKs, sqltypes.NewInt64(1))
+	}
+	// the where clauses of the statements are replaced while generating the batch SQL
+	sampleStmts := make([]sqlparser.Statement, 0, len(stmts))
+	for _, stmt := range stmts {
+		sampleStmts = append(sampleStmts, sqlparser.CloneStatement(stmt))
+	}
+	batchSQL, countSQL, _, _, err := createBatchInfoTableEntry(tableName, sampleStmts, whereExprs, samplePKs, samplePKs, pkInfos)
 	if err != nil {
-		return "", "", err
+		return fmt.Errorf("failed to generate the batch SQL of the job: %v", err)
 	}
 
-	// 4.Concatenate the where expr ast node of the original SQL with pkConditionExpr using AND to get the where expr of batchSQL.
-	andExpr := sqlparser.Where{Expr: &sqlparser.AndExpr{Left: whereExpr, Right: pkConditionExpr}}
-	batchSQL = genSQLByReplaceWhereExprNode(stmt, andExpr)
-	finalWhereStr = sqlparser.String(andExpr.Expr)
+	batchSQLs, err := splitJobSQL(batchSQL)
+	if err != nil {
+		return fmt.Errorf("the generated batch SQL %q is malformed: %v", batchSQL, err)
+	}
+	for _, stmtSQL := range batchSQLs {
+		stmtTableName, whereExpr, _, err := parseDML(stmtSQL)
+		if err == nil {
+			err = checkBatchSQLTarget(stmtTableName, whereExpr, tableName, pkNames)
+		}
+		if err != nil {
+			return fmt.Errorf("the generated batch SQL %q is malformed: %v", stmtSQL, err)
+		}
+	}
 
-	return batchSQL, finalWhereStr, nil
+	countStmt, err := sqlparser.Parse(countSQL)
+	if err == nil {
+		sel, ok := countStmt.(*sqlparser.Select)
+		if !ok || len(sel.From) != 1 || sel.Where == nil {
+			err = errors.New("it doesn't count the rows of a single table")
+		} else {
+			err = checkBatchSQLTarget(sqlparser.String(sel.From[0]), sel.Where.Expr, tableName, pkNames)
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("the generated batch count SQL %q is malformed: %v", countSQL, err)
+	}
+	return nil
+}
+
+// checkBatchSQLTarget checks that a SQL generated for a batch is on tableName, and that its where clause references all the PK columns.
+func checkBatchSQLTarget(sqlTableName string, whereExpr sqlparser.Expr, tableName string, pkNames []string) error {
+	if sqlTableName != tableName {
+		return fmt.Errorf("it is on table %s instead of %s", sqlTableName, tableName)
+	}
+	columns := make(map[string]bool)
+	_ = sqlparser.Walk(func(node sqlparser.SQLNode) (bool, error) {
+		if col, ok := node.(*sqlparser.ColName); ok {
+			columns[col.Name.Lowered()] = true
+		}
+		return true, nil
+	}, whereExpr)
+	for _, pkName := range pkNames {
+		if !columns[strings.ToLower(pkName)] {
+			return fmt.Errorf("it doesn't reference the PK column %s", pkName)
+		}
+	}
+	return nil
 }
 
 func genCountSQL(tableName, whereExpr string) (countSQL string) {
@@ -168,6 +295,11 @@ func getUserWhereExpr(stmt sqlparser.Statement) (expr sqlparser.Expr) {
 		tempAndExpr, _ := s.Where.Expr.(*sqlparser.AndExpr)
 		expr = tempAndExpr.Left
 		return expr
+	case *sqlparser.Select:
+		// select case is for batch count SQL
+		tempAndExpr, _ := s.Where.Expr.(*sqlparser.AndExpr)
+		expr = tempAndExpr.Left
+		return expr
 	default:
 		// the code won't reach here
 		return nil
@@ -202,6 +334,8 @@ func genNewBatchSQLsAndCountSQLsWhenSplittingBatch(batchSQLStmt, batchCountSQLSt
 	// 3.1) First construct the where expr ast nodes of curBatchSQL and newBatchSQL by
 	// concatenating the user input where expr with PK Condition Expr with AND
 	userWhereExpr := getUserWhereExpr(batchSQLStmt)
+	// the where expr of the count SQL is the one of the whole job, which differs from the one of batchSQLStmt in a multi-statement job
+	countWhereExpr := getUserWhereExpr(batchCountSQLStmt)
 	curBatchPKConditionExpr := sqlparser.AndExpr{Left: curBatchGreatThanExpr, Right: curBatchLessThanExpr}
 	newBatchPKConditionExpr := sqlparser.AndExpr{Left: newBatchGreatThanExpr, Right: newBatchLessThanExpr}
 	curBatchWhereExpr := sqlparser.Where{Expr: &sqlparser.AndExpr{Left: userWhereExpr, Right: &curBatchPKConditionExpr}}
@@ -212,7 +346,8 @@ func genNewBatchSQLsAndCountSQLsWhenSplittingBatch(batchSQLStmt, batchCountSQLSt
 	newBatchSQL = genSQLByReplaceWhereExprNode(batchSQLStmt, newBatchWhereExpr)
 
 	// 3.3) Similarly generate batchCountSQL
-	newBatchCountSQL = genSQLByReplaceWhereExprNode(batchCountSQLStmt, newBatchWhereExpr)
+	newBatchCountWhereExpr := sqlparser.Where{Expr: &sqlparser.AndExpr{Left: countWhereExpr, Right: &newBatchPKConditionExpr}}
+	newBatchCountSQL = genSQLByReplaceWhereExprNode(batchCountSQLStmt, newBatchCountWhereExpr)
 	return curBatchSQL, newBatchSQL, newBatchCountSQL, nil
 }
 
@@ -256,6 +391,12 @@ func getNewBatchesBeginAndEndStr(ctx context.Context, conn *connpool.DBConn, bat
 	return currentBatchNewBeginStr, currentBatchNewEndStr, newBatchBeginStr, newBatchEndStr, nil
 }
 func updateBatchInfoTableEntry(ctx context.Context, conn *connpool.DBConn, batchTable string, curBatchSQL, currentBatchNewBeginStr, currentBatchNewEndStr, batchID string) (err error) {
+	if err := checkBatchColumnsLength(batchID,
+		"batch_sql", curBatchSQL,
+		"batch_begin", currentBatchNewBeginStr,
+		"batch_end", currentBatchNewEndStr); err != nil {
+		return err
+	}
 	sqlUpdateBatchInfoTableEntry := fmt.Sprintf(sqlTemplateUpdateBatchSQL, batchTable)
 	queryUpdateBatchInfoTableEntry, err := sqlparser.ParseAndBind(sqlUpdateBatchInfoTableEntry,
 		sqltypes.StringBindVariable(curBatchSQL),
@@ -273,6 +414,13 @@ func updateBatchInfoTableEntry(ctx context.Context, conn *connpool.DBConn, batch
 }
 
 func insertBatchInfoTableEntry(ctx context.Context, conn *connpool.DBConn, batchTable, nextBatchID, newBatchSQL, newBatchCountSQL, newBatchBeginStr, newBatchEndStr string, newBatchSize int64) (err error) {
+	if err := checkBatchColumnsLength(nextBatchID,
+		"batch_sql", newBatchSQL,
+		"batch_count_sql_when_creating_batch", newBatchCountSQL,
+		"batch_begin", newBatchBeginStr,
+		"batch_end", newBatchEndStr); err != nil {
+		return err
+	}
 	sqlInsertBatchInfoTableEntry := fmt.Sprintf(sqlTemplateInsertBatchEntry, batchTable)
 	queryInsertBatchInfoTableEntry, err := sqlparser.ParseAndBind(sqlInsertBatchInfoTableEntry,
 		sqltypes.StringBindVariable(nextBatchID),
@@ -290,3 +438,74 @@ func insertBatchInfoTableEntry(ctx context.Context, conn *connpool.DBConn, batch
 	}
 	return nil
 }
+
+// getNonDeterministicFuncs returns the distinct time functions like NOW() and CURRENT_DATE() in whereExprs.
+// They are evaluated when each batch runs, so the cutoff they define would drift while a long job runs.
+func getNonDeterministicFuncs(whereExprs ...sqlparser.Expr) []string {
+	var funcs []string
+	seen := make(map[string]bool)
+	for _, whereExpr := range whereExprs {
+		_ = sqlparser.Walk(func(node sqlparser.SQLNode) (kontinue bool, err error) {
+			if !isNonDeterministicFunc(node) {
+				return true, nil
+			}
+			funcStr := sqlparser.String(node)
+			if !seen[funcStr] {
+				seen[funcStr] = true
+				funcs = append(funcs, funcStr)
+			}
+			return false, nil
+		}, whereExpr)
+	}
+	return funcs
+}
+
+// timeFuncsWithoutArgs are the time functions that are parsed into a plain FuncExpr.
+var timeFuncsWithoutArgs = map[string]bool{
+	"current_date":   true,
+	"curdate":        true,
+	"utc_date":       true,
+	"curtime":        true,
+	"sysdate":        true,
+	"unix_timestamp": true,
+}
+
+func isNonDeterministicFunc(node sqlparser.SQLNode) bool {
+	switch node := node.(type) {
+	case *sqlparser.CurTimeFuncExpr:
+		return true
+	case *sqlparser.FuncExpr:
+		return timeFuncsWithoutArgs[node.Name.Lowered()] && len(node.Exprs) == 0
+	}
+	return false
+}
+
+// genEvalFuncsSQL generates the SQL that evaluates funcs once, in the order of funcs.
+func genEvalFuncsSQL(funcs []string) string {
+	return fmt.Sprintf("select %s", strings.Join(funcs, ", "))
+}
+
+// freezeNonDeterministicFuncs replaces the time functions in whereExpr with the values they were evaluated to,
+// so that every batch of the job uses the same cutoff.
+func freezeNonDeterministicFuncs(whereExpr sqlparser.Expr, values map[string]sqltypes.Value) sqlparser.Expr {
+	return sqlparser.Rewrite(whereExpr, nil, func(cursor *sqlparser.Cursor) bool {
+		if !isNonDeterministicFunc(cursor.Node()) {
+			return true
+		}
+		value, ok := values[sqlparser.String(cursor.Node())]
+		if !ok {
+			return true
+		}
+		switch {
+		case value.IsNull():
+			cursor.Replace(&sqlparser.NullVal{})
+		case value.IsIntegral():
+			cursor.Replace(sqlparser.NewIntLiteral(value.ToString()))
+		case value.IsQuoted():
+			cursor.Replace(sqlparser.NewStrLiteral(value.ToString()))
+		default:
+			cursor.Replace(sqlparser.NewDecimalLiteral(value.ToString()))
+		}
+		return true
+	}).(sqlparser.Expr)
+}
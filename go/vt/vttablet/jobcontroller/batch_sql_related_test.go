@@ -628,7 +628,7 @@ func TestParseDML2GenBatchSQL(t *testing.T) {
 			assert.Equalf(t, tt.expectedWhereStr, whereStr, "parseDML(%v)", tt.args.dmlSQL)
 
 			// 2.get selectPksSQL
-			selectPksSQL := sprintfSelectPksSQL(tableName, whereStr, tt.args.pkInfos)
+			selectPksSQL := sprintfSelectPksSQL(tableName, whereStr, tt.args.pkInfos, batchOrderAsc)
 			assert.Equalf(t, tt.expectedSelectPksSQL, selectPksSQL, "sprintfSelectPksSQL(%v,%v)", tableName, whereStr)
 
 			// 3.get batchSQL and batchCountSQL
@@ -657,3 +657,58 @@ func TestParseDML2GenBatchSQL(t *testing.T) {
 		})
 	}
 }
+
+func TestFreezeNonDeterministicFuncs(t *testing.T) {
+	stmt, err := sqlparser.Parse("delete from t where created < NOW() - INTERVAL 30 DAY and (d = CURRENT_DATE or ts < unix_timestamp()) and c < now()")
+	assert.NoError(t, err)
+	whereExpr := stmt.(*sqlparser.Delete).Where.Expr
+
+	funcs := getNonDeterministicFuncs(whereExpr)
+	assert.Equal(t, []string{"now()", "current_date()", "unix_timestamp()"}, funcs)
+	assert.Equal(t, "select now(), current_date(), unix_timestamp()", genEvalFuncsSQL(funcs))
+
+	frozenExpr := freezeNonDeterministicFuncs(whereExpr, map[string]sqltypes.Value{
+		"now()":            sqltypes.NewDatetime("2024-05-01 10:00:00"),
+		"current_date()":   sqltypes.NewDate("2024-05-01"),
+		"unix_timestamp()": sqltypes.NewInt64(1714557600),
+	})
+	batchSQL, _, err := genBatchSQL(stmt, frozenExpr, []sqltypes.Value{sqltypes.NewInt64(1)}, []sqltypes.Value{sqltypes.NewInt64(9)}, []PKInfo{{pkName: "pk1"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "delete from t where created < '2024-05-01 10:00:00' - interval 30 DAY and (d = '2024-05-01' or ts < 1714557600) and c < '2024-05-01 10:00:00' and (pk1 >= 1 and pk1 <= 9)", batchSQL)
+
+	// a where clause without time functions is left as is
+	stmt, err = sqlparser.Parse("delete from t where c < 10")
+	assert.NoError(t, err)
+	assert.Empty(t, getNonDeterministicFuncs(stmt.(*sqlparser.Delete).Where.Expr))
+}
+
+func TestValidateBatchSQLs(t *testing.T) {
+	tests := []struct {
+		sql     string
+		pkNames []string
+		wantErr string
+	}{
+		{sql: "delete from t1 where id > 10", pkNames: []string{"id"}},
+		{sql: "update t1 as a set a.c1 = 1 where a.id > 10", pkNames: []string{"id"}},
+		{sql: "update t1 set c1 = 1 where c2 > 10;delete from t1 where c1 = 1", pkNames: []string{"id", "c2"}},
+		{sql: "delete from t1 where id > 10", pkNames: []string{"ID"}},
+		{sql: "delete from t1 where id > 10", pkNames: nil, wantErr: "has no primary key"},
+		{sql: "delete from t1 where id > 10", pkNames: []string{"id) or (c1"}, wantErr: "doesn't reference the PK column"},
+		{sql: "delete from t1 where id > 10", pkNames: []string{"id >"}, wantErr: "failed to generate the batch SQL"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.sql, func(t *testing.T) {
+			tableName, whereExprs, stmts, err := parseJobDMLs(tt.sql)
+			assert.NoError(t, err)
+			sqlBefore := sqlparser.String(stmts[0])
+			err = validateBatchSQLs(tableName, whereExprs, stmts, tt.pkNames)
+			if tt.wantErr == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tt.wantErr)
+			}
+			// the statements of the job are left as they are
+			assert.Equal(t, sqlBefore, sqlparser.String(stmts[0]))
+		})
+	}
+}
@@ -10,7 +10,9 @@ import (
 	"errors"
 	"fmt"
 	"math"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/background"
@@ -46,16 +48,44 @@ var (
 	throttleCheckInterval     = 250  // ms g
 	batchSizeThreshold        = 10000
 	ratioOfBatchSizeThreshold = 0.5
+	confirmRowsThreshold      = 0   // 0 means no confirmation is required
+	confirmTokenTTL           = 300 // second
+	batchCountForShare        = true
+	batchTableOptions         = "ENGINE = InnoDB"
+	batchTableSchema          = "" // empty means the batch table of a job is created in the schema of its table
+	analyzeTableAfterJob      = false
+	batchTimeout              = 0 // ms, 0 means the batches have no timeout
+	batchTableChunkSize       = 100
+	batchTableMaxBuildTime    = 0 // second, 0 means no limit
+	batchDeadlockRetries      = 0 // 0 means a deadlocked batch fails according to the fail policy of its job
+	minBatchSize              = 1
+	maxBatchSize              = 100000
+	minBatchInterval          = 1        // ms
+	maxBatchInterval          = 86400000 // ms
 )
 
 func registerFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&defaultBatchSize, "non_transactional_dml_default_batch_size", defaultBatchSize, "the number of rows to be processed in one batch by default")
 	fs.IntVar(&defaultBatchInterval, "non_transactional_dml_default_batch_interval", defaultBatchInterval, "the interval of batch processing in milliseconds by default")
+	fs.IntVar(&minBatchSize, "non_transactional_dml_min_batch_size", minBatchSize, "the minimum batch size a job can be submitted with")
+	fs.IntVar(&maxBatchSize, "non_transactional_dml_max_batch_size", maxBatchSize, "the maximum batch size a job can be submitted with. The batches can still be smaller than it because of non_transactional_dml_batch_size_threshold")
+	fs.IntVar(&minBatchInterval, "non_transactional_dml_min_batch_interval", minBatchInterval, "the minimum batch interval in milliseconds a job can be submitted with")
+	fs.IntVar(&maxBatchInterval, "non_transactional_dml_max_batch_interval", maxBatchInterval, "the maximum batch interval in milliseconds a job can be submitted with")
 	fs.IntVar(&tableGCInterval, "non_transactional_dml_table_gc_interval", tableGCInterval, "the interval of table GC in hours")
 	fs.IntVar(&jobManagerRunningInterval, "non_transactional_dml_job_manager_running_interval", jobManagerRunningInterval, "the interval of job scheduler running in seconds")
 	fs.IntVar(&throttleCheckInterval, "non_transactional_dml_throttle_check_interval", throttleCheckInterval, "the interval of throttle check in milliseconds")
 	fs.IntVar(&batchSizeThreshold, "non_transactional_dml_batch_size_threshold", batchSizeThreshold, "the	threshold of batch size")
 	fs.Float64Var(&ratioOfBatchSizeThreshold, "non_transactional_dml_batch_size_threshold_ratio", ratioOfBatchSizeThreshold, "final threshold = ratio * non_transactional_dml_batch_size_threshold / table index numbers")
+	fs.IntVar(&confirmRowsThreshold, "non_transactional_dml_confirm_rows_threshold", confirmRowsThreshold, "jobs estimated to affect more rows than this must be submitted again with the returned confirm token, 0 disables the confirmation")
+	fs.IntVar(&confirmTokenTTL, "non_transactional_dml_confirm_token_ttl", confirmTokenTTL, "the time in seconds a confirm token stays valid")
+	fs.BoolVar(&batchCountForShare, "non_transactional_dml_batch_count_for_share", batchCountForShare, "lock the rows counted before each batch in share mode. If disabled, the count doesn't block concurrent writers, but the batch size threshold check becomes advisory, since the rows may change before the batch is executed")
+	fs.StringVar(&batchTableOptions, "non_transactional_dml_batch_table_options", batchTableOptions, "the table options of the batch tables of the jobs, e.g. the storage engine")
+	fs.StringVar(&batchTableSchema, "non_transactional_dml_batch_table_schema", batchTableSchema, "the schema the batch tables of the jobs are created in, e.g. mysql, to keep them out of the schemas of the users. It's created if it doesn't exist. If empty, the batch table of a job is created in the schema of its table")
+	fs.BoolVar(&analyzeTableAfterJob, "non_transactional_dml_analyze_table_after_job", analyzeTableAfterJob, "run ANALYZE TABLE on the table of a job once all its batches are done, so that the optimizer sees the statistics of the table after the job instead of waiting for the next automatic update")
+	fs.IntVar(&batchTableChunkSize, "non_transactional_dml_batch_table_chunk_size", batchTableChunkSize, "the number of batches inserted together into the batch table of a job while it's built. A build interrupted, e.g. by a failover, resumes after the last chunk inserted")
+	fs.IntVar(&batchTableMaxBuildTime, "non_transactional_dml_batch_table_max_build_time", batchTableMaxBuildTime, "the maximum time in seconds the batch table of a job can take to be built, the job fails if it takes longer. 0 means no limit")
+	fs.IntVar(&batchTimeout, "non_transactional_dml_batch_timeout", batchTimeout, "the timeout of a batch in milliseconds, the statements of a batch still running after it are killed and the batch fails according to the fail policy of its job. 0 means no timeout")
+	fs.IntVar(&batchDeadlockRetries, "non_transactional_dml_batch_deadlock_retries", batchDeadlockRetries, "the number of times a batch is executed again when its transaction is rolled back by a deadlock, before it fails according to the fail policy of its job. The whole transaction of the batch is rolled back, so it can't be applied twice. 0 means no retry")
 }
 
 func init() {
@@ -78,6 +108,12 @@ const (
 	CancelJob            = "cancel"
 	SetRunningTimePeriod = "set_running_time_period"
 	ShowJob              = "show_job"
+	ShowJobJSON          = "show_job_json"
+	PurgeJob             = "purge"
+	CloneJob             = "clone"
+	DescribeJob          = "describe_job"
+	ShowJobsWithTag      = "show_jobs_with_tag"
+	ShowBatch            = "show_batch"
 )
 
 // These are strategies when a batch execution fails.
@@ -92,6 +128,15 @@ const (
 	defaultFailPolicy = failPolicyPause
 )
 
+// the orders of the PKs the batches of a job are executed in
+const (
+	batchOrderAsc  = "asc"  // the batch with the lowest PKs first, the default
+	batchOrderDesc = "desc" // the batch with the highest PKs first
+)
+
+// maxTagLength is the maximum length of the tag of a job, which is the length of its column.
+const maxTagLength = 256
+
 // possible status of DML job
 // batch is status is in ('queued', 'completed')
 const (
@@ -105,6 +150,29 @@ const (
 	FailedStatus          = "failed"
 	CompletedStatus       = "completed"
 	NotInTimePeriodStatus = "not-in-time-period"
+	// BlockedStatus is set on a submitted job while another job works on its table,
+	// the job is prepared once the table is free.
+	BlockedStatus = "blocked"
+)
+
+// jobStatusTransitions lists the statuses each status of DML job can be set to by updateJobStatus.
+// canceled, failed and completed are terminal, a job never leaves them.
+// A preparing or running job can be set to the same status again, when it's recovered after a restart.
+var jobStatusTransitions = map[string][]string{
+	SubmittedStatus:       {PreparingStatus, BlockedStatus, CanceledStatus, FailedStatus},
+	BlockedStatus:         {PreparingStatus, CanceledStatus, FailedStatus},
+	PreparingStatus:       {PreparingStatus, QueuedStatus, PostponeLaunchStatus, CanceledStatus, FailedStatus},
+	PostponeLaunchStatus:  {QueuedStatus, CanceledStatus, FailedStatus},
+	QueuedStatus:          {RunningStatus, NotInTimePeriodStatus, CanceledStatus, FailedStatus},
+	NotInTimePeriodStatus: {RunningStatus, NotInTimePeriodStatus, CanceledStatus, FailedStatus},
+	RunningStatus:         {RunningStatus, PausedStatus, NotInTimePeriodStatus, CompletedStatus, CanceledStatus, FailedStatus},
+	PausedStatus:          {RunningStatus, CanceledStatus, FailedStatus},
+}
+
+// names of the settings persisted in mysql.non_transactional_dml_job_settings
+const (
+	// globalPauseSetting is "1" if all the jobs are paused by PauseAllJobs.
+	globalPauseSetting = "pause_all"
 )
 
 type JobController struct {
@@ -113,6 +181,11 @@ type JobController struct {
 	env                    tabletenv.Env
 	lagThrottler           *throttle.Throttler
 	lastSuccessfulThrottle int64
+	// checkThrottler runs a check of the lag throttler for an app, it's replaced in tests to fake the replication lag.
+	checkThrottler func(ctx context.Context, appName string, flags *throttle.CheckFlags) *throttle.CheckResult
+	// checkThrottlerReady returns an error if the lag throttler isn't ready to serve the checks yet,
+	// it's replaced in tests to fake a throttler being initialized.
+	checkThrottlerReady func() error
 
 	initMutex sync.Mutex
 
@@ -130,6 +203,31 @@ type JobController struct {
 	managerNotifyChan chan struct{}
 
 	pool *background.TaskPool
+
+	// batchMutex protects closing and the Add calls of inFlightBatches.
+	batchMutex sync.Mutex
+	// closing is set by Close, no new batch transaction is started once it's set.
+	closing bool
+	// inFlightBatches tracks the batch transactions being executed,
+	// Close waits for them to commit or roll back before returning.
+	inFlightBatches sync.WaitGroup
+
+	confirmMutex sync.Mutex
+	// pendingConfirmations holds the jobs waiting to be submitted again with their confirm token,
+	// keyed by the token. It's protected by confirmMutex.
+	pendingConfirmations map[string]*pendingConfirmation
+
+	// globalPaused is set by PauseAllJobs and cleared by ResumeAllJobs.
+	// While it's set, no job is launched and the running jobs don't execute new batches.
+	// It's persisted in the settings table, and loaded when the jobManager starts.
+	globalPaused atomic.Bool
+}
+
+// pendingConfirmation is a job whose estimated affected rows exceed confirmRowsThreshold.
+type pendingConfirmation struct {
+	sql, tableSchema string
+	estimatedRows    int64
+	expireAt         time.Time
 }
 
 type PKInfo struct {
@@ -142,6 +240,25 @@ type JobArgs struct {
 	batchInterval, batchSize                                                                      int64
 	timePeriodStart, timePeriodEnd                                                                *time.Time
 	postponeLaunch                                                                                bool
+	// batchInfoTableSchema is the schema the batch table lives in, which is tableSchema unless
+	// non_transactional_dml_batch_table_schema was set when the job was submitted.
+	batchInfoTableSchema string
+	// launchAt is the time at which a postponed job is launched automatically, nil if not set.
+	launchAt *time.Time
+	// archiveTable is the table the rows of a DELETE job are archived to before being deleted, empty if not set.
+	archiveTable string
+	// isolationLevel is the transaction isolation level the batches run in, empty to use the session default.
+	isolationLevel string
+	// checksumColumns are the comma separated columns whose checksum is recorded for every batch, empty if not set.
+	checksumColumns string
+	// maxReplicationLag is the replication lag in seconds above which the batches are not executed,
+	// 0 if the job is only throttled by the throttler.
+	maxReplicationLag float64
+	// batchOrder is the order of the PKs the batches are executed in, empty for ascending.
+	batchOrder string
+	// versionColumn is the column whose value advances whenever a row is modified, empty if not set.
+	// The batches skip the rows whose version advanced past versionSnapshot, its highest value when the job was submitted.
+	versionColumn, versionSnapshot string
 }
 
 func (jc *JobController) Open() error {
@@ -157,18 +274,59 @@ func (jc *JobController) initJobController() {
 	jc.ctx, jc.cancelOperation = context.WithCancel(context.Background())
 	jc.workingTables = map[string]bool{}
 	jc.managerNotifyChan = make(chan struct{}, 1)
+	jc.batchMutex.Lock()
+	jc.closing = false
+	jc.batchMutex.Unlock()
 	initThrottleTicker()
 }
 
+// Close stops all the jobs. It blocks until the batch transactions in flight are committed
+// or rolled back, so that no batch can commit after the tablet stops serving as primary.
+// The wait is bounded by the shutdown grace period if it's set.
 func (jc *JobController) Close() {
 	jc.initMutex.Lock()
 	defer jc.initMutex.Unlock()
+	jc.batchMutex.Lock()
+	jc.closing = true
+	jc.batchMutex.Unlock()
 	if jc.cancelOperation != nil {
 		jc.cancelOperation()
 	}
-	if jc.managerNotifyChan != nil {
-		close(jc.managerNotifyChan)
+	jc.waitForInFlightBatches(jc.env.Config().GracePeriods.ShutdownSeconds.Get())
+	// managerNotifyChan is not closed: the jobManager returns once the context is canceled, and
+	// closing it would panic both a runner notifying it late and a second Close, which happens when
+	// a demoted tablet is then stopped.
+}
+
+// waitForInFlightBatches waits for the batch transactions in flight to finish.
+// The context of the batches is canceled before, so their queries are killed and the transactions roll back.
+// If timeout is not positive, it waits until all of them finish.
+func (jc *JobController) waitForInFlightBatches(timeout time.Duration) {
+	done := make(chan struct{})
+	go func() {
+		jc.inFlightBatches.Wait()
+		close(done)
+	}()
+	if timeout <= 0 {
+		<-done
+		return
+	}
+	select {
+	case <-done:
+	case <-time.After(timeout):
+		log.Warningf("JobController: batch transactions are still in flight after %v", timeout)
+	}
+}
+
+// beginBatch registers a batch transaction in flight, it returns false if the controller is closing.
+func (jc *JobController) beginBatch() bool {
+	jc.batchMutex.Lock()
+	defer jc.batchMutex.Unlock()
+	if jc.closing {
+		return false
 	}
+	jc.inFlightBatches.Add(1)
+	return true
 }
 
 func NewJobController(tabletTypeFunc func() topodatapb.TabletType, env tabletenv.Env, lagThrottler *throttle.Throttler, taskPool *background.TaskPool) *JobController {
@@ -176,30 +334,73 @@ func NewJobController(tabletTypeFunc func() topodatapb.TabletType, env tabletenv
 		tabletTypeFunc: tabletTypeFunc,
 		env:            env,
 		lagThrottler:   lagThrottler,
-		pool:           taskPool,
+		checkThrottler: func(ctx context.Context, appName string, flags *throttle.CheckFlags) *throttle.CheckResult {
+			return lagThrottler.CheckByType(ctx, appName, "", flags, throttle.ThrottleCheckPrimaryWrite)
+		},
+		checkThrottlerReady: func() error {
+			return lagThrottler.CheckIsReady()
+		},
+		pool: taskPool,
 	}
 }
 
-func (jc *JobController) HandleRequest(command, sql, jobUUID, tableSchema, runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone, throttleDuration, throttleRatio string, timeGapInMs, usrBatchSize int64, postponeLaunch bool, failPolicy string, showDetails bool) (*sqltypes.Result, error) {
+// JobRequest holds the arguments of a command handled by HandleRequest, each command reads the ones it needs.
+type JobRequest struct {
+	SQL                       string
+	JobUUID                   string
+	TableSchema               string
+	RunningTimePeriodStart    string
+	RunningTimePeriodEnd      string
+	RunningTimePeriodTimeZone string
+	ThrottleDuration          string
+	ThrottleRatio             string
+	BatchIntervalInMs         int64
+	BatchSize                 int64
+	PostponeLaunch            bool
+	FailPolicy                string
+	ShowDetails               bool
+	// TagPattern is the LIKE pattern the tags of the jobs shown by ShowJobsWithTag match.
+	TagPattern string
+	// BatchID is the id of the batch shown by ShowBatch.
+	BatchID string
+}
+
+func (jc *JobController) HandleRequest(command string, req JobRequest) (*sqltypes.Result, error) {
 	switch command {
 	case SubmitJob:
-		return jc.SubmitJob(sql, tableSchema, runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone, timeGapInMs, usrBatchSize, postponeLaunch, failPolicy, throttleDuration, throttleRatio)
+		return jc.SubmitJob(req.SQL, req.TableSchema, req.RunningTimePeriodStart, req.RunningTimePeriodEnd, req.RunningTimePeriodTimeZone, req.BatchIntervalInMs, req.BatchSize, req.PostponeLaunch, req.FailPolicy, req.ThrottleDuration, req.ThrottleRatio)
 	case PauseJob:
-		return jc.PauseJob(jobUUID)
+		return jc.PauseJob(req.JobUUID)
+	case PauseAllJobs:
+		return jc.PauseAllJobs()
 	case ResumeJob:
-		return jc.ResumeJob(jobUUID)
+		return jc.ResumeJob(req.JobUUID)
+	case ResumeAllJobs:
+		return jc.ResumeAllJobs()
 	case LaunchJob:
-		return jc.LaunchJob(jobUUID)
+		return jc.LaunchJob(req.JobUUID)
 	case CancelJob:
-		return jc.CancelJob(jobUUID)
+		return jc.CancelJob(req.JobUUID)
 	case ThrottleJob:
-		return jc.ThrottleJob(jobUUID, throttleDuration, throttleRatio)
+		return jc.ThrottleJob(req.JobUUID, req.ThrottleDuration, req.ThrottleRatio)
 	case UnthrottleJob:
-		return jc.UnthrottleJob(jobUUID)
+		return jc.UnthrottleJob(req.JobUUID)
 	case SetRunningTimePeriod:
-		return jc.SetRunningTimePeriod(jobUUID, runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone)
+		return jc.SetRunningTimePeriod(req.JobUUID, req.RunningTimePeriodStart, req.RunningTimePeriodEnd, req.RunningTimePeriodTimeZone)
 	case ShowJob:
-		return jc.ShowJob(jobUUID, showDetails)
+		return jc.ShowJob(req.JobUUID, req.ShowDetails)
+	case ShowJobJSON:
+		return jc.ShowJobJSON(req.JobUUID)
+	case ShowJobsWithTag:
+		return jc.ShowJobsWithTag(req.TagPattern)
+	case PurgeJob:
+		return jc.PurgeJob(req.JobUUID)
+	case CloneJob:
+		return jc.CloneJob(req.JobUUID)
+	case DescribeJob:
+		return jc.DescribeJob(req.JobUUID)
+	case ShowBatch:
+		return jc.ShowBatch(req.JobUUID, req.BatchID)
 	}
 
 	return &sqltypes.Result{}, fmt.Errorf("unknown command: %s", command)
@@ -214,6 +415,113 @@ func (jc *JobController) ShowJob(uuid string, showDetails bool) (*sqltypes.Resul
 }
 
 func (jc *JobController) SubmitJob(sql, tableSchema, runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone string, batchIntervalInMs, userBatchSize int64, postponeLaunch bool, failPolicy, throttleDuration, throttleRatio string) (*sqltypes.Result, error) {
+	if err := validateBatchParams(batchIntervalInMs, userBatchSize); err != nil {
+		return &sqltypes.Result{}, err
+	}
+	// A job may run several statements on the same table in each of its batches.
+	// Its directives are read from its first statement.
+	sqls, err := splitJobSQL(sql)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	directiveSQL := sqls[0]
+	// The launch time is passed as a comment directive, so it has to be read before comments are stripped.
+	launchAt, err := getLaunchAt(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	if launchAt != "" {
+		// a job with a launch time waits in postpone-launch status until the time arrives
+		postponeLaunch = true
+	}
+	archiveTable, err := getArchiveTable(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	isolationLevel, err := getIsolationLevel(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	checksumColumns, err := getChecksumColumns(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	maxReplicationLag, err := getMaxReplicationLag(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	notifyURL, err := getNotifyURL(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	batchOrder, err := getBatchOrder(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	versionColumn, err := getVersionColumn(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	tag, err := getTag(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	pkRangeStart, pkRangeEnd, err := getPKRange(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	confirmToken, err := getConfirmToken(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	allowFullTable, err := getAllowFullTable(directiveSQL)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	for i := range sqls {
+		sqls[i] = sqlparser.StripComments(sqls[i])
+		sqls[i], err = checkFullTable(sqls[i], allowFullTable)
+		if err != nil {
+			return &sqltypes.Result{}, err
+		}
+	}
+	sql = strings.Join(sqls, jobSQLSeparator)
+	tableName, _, stmts, err := parseJobDMLs(sql)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	// the rows of a batch are archived, checksummed and checked for modifications around a single statement
+	if len(stmts) > 1 && (archiveTable != "" || checksumColumns != "" || versionColumn != "") {
+		return &sqltypes.Result{}, errors.New("the archive table, the checksum columns and the version column are not supported by a job of several statements")
+	}
+	for _, stmt := range stmts {
+		if err = jc.checkGeneratedColumns(jc.ctx, tableSchema, tableName, stmt); err != nil {
+			return &sqltypes.Result{}, err
+		}
+	}
+	// The PK range is part of the job SQL, so that the batches are generated within it.
+	if pkRangeStart != "" || pkRangeEnd != "" {
+		pkInfos, err := jc.getTablePkInfo(jc.ctx, tableSchema, tableName)
+		if err != nil {
+			return &sqltypes.Result{}, err
+		}
+		for i := range sqls {
+			sqls[i], err = limitToPKRange(sqls[i], pkInfos, pkRangeStart, pkRangeEnd)
+			if err != nil {
+				return &sqltypes.Result{}, err
+			}
+		}
+		sql = strings.Join(sqls, jobSQLSeparator)
+	}
+	// The estimate scans the rows affected by the job, so it's done before taking tableMutex
+	// to not block the job manager and the other job commands meanwhile.
+	if confirmRowsThreshold > 0 {
+		confirmRst, err := jc.checkConfirmation(sql, tableSchema, confirmToken)
+		if err != nil || confirmRst != nil {
+			return confirmRst, err
+		}
+	}
+
 	jc.tableMutex.Lock()
 	defer jc.tableMutex.Unlock()
 
@@ -221,7 +529,6 @@ func (jc *JobController) SubmitJob(sql, tableSchema, runningTimePeriodStart, run
 	if err != nil {
 		return &sqltypes.Result{}, err
 	}
-	sql = sqlparser.StripComments(sql)
 	if batchIntervalInMs == 0 {
 		// todo feat: maybe batches can run without interval, just let throttler to decide whether to run
 		batchIntervalInMs = int64(defaultBatchInterval)
@@ -233,8 +540,30 @@ func (jc *JobController) SubmitJob(sql, tableSchema, runningTimePeriodStart, run
 	if err != nil {
 		return &sqltypes.Result{}, err
 	}
-
-	batchInfoTableSchema := tableSchema
+	if archiveTable != "" {
+		if err = jc.checkArchiveTable(jc.ctx, tableSchema, tableName, archiveTable); err != nil {
+			return &sqltypes.Result{}, err
+		}
+	}
+	if checksumColumns != "" {
+		if err = jc.checkChecksumColumns(jc.ctx, tableSchema, tableName, checksumColumns); err != nil {
+			return &sqltypes.Result{}, err
+		}
+	}
+	var versionSnapshot string
+	if versionColumn != "" {
+		if err = jc.checkVersionColumn(jc.ctx, tableSchema, tableName, versionColumn); err != nil {
+			return &sqltypes.Result{}, err
+		}
+		versionSnapshot, err = jc.getVersionSnapshot(jc.ctx, tableSchema, tableName, versionColumn)
+		if err != nil {
+			return &sqltypes.Result{}, err
+		}
+	}
+	batchInfoTableSchema, err := jc.initBatchTableSchema(tableSchema)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
 
 	jobStatus := SubmittedStatus
 
@@ -260,7 +589,7 @@ func (jc *JobController) SubmitJob(sql, tableSchema, runningTimePeriodStart, run
 	}
 
 	err = jc.insertJobEntry(jobUUID, sql, tableSchema, tableName, batchInfoTableSchema, batchInfoTable,
-		jobStatus, statusSetTime, failPolicy, runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone, throttleExpireAt, batchIntervalInMs, batchSize, throttleRatioFloat64, postponeLaunch)
+		jobStatus, statusSetTime, failPolicy, runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone, throttleExpireAt, batchIntervalInMs, batchSize, throttleRatioFloat64, postponeLaunch, launchAt, archiveTable, isolationLevel, checksumColumns, maxReplicationLag, notifyURL, batchOrder, versionColumn, versionSnapshot, tag)
 	if err != nil {
 		return &sqltypes.Result{}, err
 	}
@@ -270,6 +599,61 @@ func (jc *JobController) SubmitJob(sql, tableSchema, runningTimePeriodStart, run
 	return jc.buildJobSubmitResult(jobUUID, batchInfoTable, batchIntervalInMs, batchSize, postponeLaunch, failPolicy), nil
 }
 
+// checkConfirmation makes sure a job whose estimated affected rows exceed confirmRowsThreshold
+// is submitted with a valid confirm token. A job submitted with a token isn't estimated again.
+// It returns a non-nil result holding a new token when the job must not be started yet.
+// the caller must not hold jc.tableMutex, the estimate may scan many rows.
+func (jc *JobController) checkConfirmation(sql, tableSchema, confirmToken string) (*sqltypes.Result, error) {
+	if confirmToken != "" {
+		jc.confirmMutex.Lock()
+		defer jc.confirmMutex.Unlock()
+		jc.expirePendingConfirmations(time.Now())
+		pending, ok := jc.pendingConfirmations[confirmToken]
+		if !ok || pending.sql != sql || pending.tableSchema != tableSchema {
+			return &sqltypes.Result{}, fmt.Errorf("confirm token %s is invalid or has expired, submit the job without it to get a new one", confirmToken)
+		}
+		delete(jc.pendingConfirmations, confirmToken)
+		return nil, nil
+	}
+
+	estimatedRows, err := jc.estimateJobAffectedRows(sql, tableSchema)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	if estimatedRows <= int64(confirmRowsThreshold) {
+		return nil, nil
+	}
+
+	jc.confirmMutex.Lock()
+	defer jc.confirmMutex.Unlock()
+	now := time.Now()
+	jc.expirePendingConfirmations(now)
+	token, err := schema.CreateUUIDWithDelimiter("-")
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	pending := &pendingConfirmation{
+		sql:           sql,
+		tableSchema:   tableSchema,
+		estimatedRows: estimatedRows,
+		expireAt:      now.Add(time.Duration(confirmTokenTTL) * time.Second),
+	}
+	if jc.pendingConfirmations == nil {
+		jc.pendingConfirmations = make(map[string]*pendingConfirmation)
+	}
+	jc.pendingConfirmations[token] = pending
+	return buildJobConfirmResult(token, pending), nil
+}
+
+// acquire jc.confirmMutex before calling this function
+func (jc *JobController) expirePendingConfirmations(now time.Time) {
+	for token, pending := range jc.pendingConfirmations {
+		if now.After(pending.expireAt) {
+			delete(jc.pendingConfirmations, token)
+		}
+	}
+}
+
 // The difference between pause and cancel:
 // 1. Pause will keep job metadata but cancel won't.
 // 2. Jobs in cancel status will get in tableGC but pause won't.
@@ -324,11 +708,82 @@ func (jc *JobController) ResumeJob(uuid string) (*sqltypes.Result, error) {
 	runnerArgs.initArgsByQueryResult(row)
 
 	// dmlJobBatchRunner will set the job status to running
-	go jc.dmlJobBatchRunner(runnerArgs.uuid, runnerArgs.table, runnerArgs.tableSchema, runnerArgs.batchInfoTable, runnerArgs.failPolicy, runnerArgs.batchInterval, runnerArgs.batchSize, runnerArgs.timePeriodStart, runnerArgs.timePeriodEnd)
+	go jc.dmlJobBatchRunner(&runnerArgs)
+	emptyResult.RowsAffected = 1
+	return emptyResult, nil
+}
+
+// PauseAllJobs pauses all the jobs at once. Unlike PauseJob, the status of the jobs is left unchanged:
+// the running jobs stop executing batches once their current batch is done, and no other job is launched,
+// until ResumeAllJobs is called. The pause is persisted, so it survives the restart of the tablet.
+func (jc *JobController) PauseAllJobs() (*sqltypes.Result, error) {
+	var emptyResult = &sqltypes.Result{}
+	if jc.globalPaused.Load() {
+		emptyResult.Info = " All the jobs are already paused"
+		return emptyResult, nil
+	}
+	if err := jc.setGlobalPause(true); err != nil {
+		return emptyResult, err
+	}
+	emptyResult.RowsAffected = 1
+	return emptyResult, nil
+}
+
+// ResumeAllJobs resumes the jobs paused by PauseAllJobs.
+// The jobs paused by PauseJob are not resumed, they still have to be resumed one by one.
+func (jc *JobController) ResumeAllJobs() (*sqltypes.Result, error) {
+	var emptyResult = &sqltypes.Result{}
+	if !jc.globalPaused.Load() {
+		emptyResult.Info = " The jobs are not paused by pause all and don't need resume"
+		return emptyResult, nil
+	}
+	if err := jc.setGlobalPause(false); err != nil {
+		return emptyResult, err
+	}
+	jc.notifyJobManager()
 	emptyResult.RowsAffected = 1
 	return emptyResult, nil
 }
 
+// setGlobalPause persists the global pause before applying it,
+// so that it's not lost if the tablet restarts.
+func (jc *JobController) setGlobalPause(paused bool) error {
+	value := "0"
+	if paused {
+		value = "1"
+	}
+	query, err := sqlparser.ParseAndBind(sqlDMLJobSetSetting,
+		sqltypes.StringBindVariable(globalPauseSetting),
+		sqltypes.StringBindVariable(value))
+	if err != nil {
+		return err
+	}
+	if _, err = jc.execQuery(jc.ctx, "", query); err != nil {
+		return err
+	}
+	jc.globalPaused.Store(paused)
+	return nil
+}
+
+// loadGlobalPause restores the global pause persisted by PauseAllJobs.
+func (jc *JobController) loadGlobalPause(ctx context.Context) error {
+	query, err := sqlparser.ParseAndBind(sqlDMLJobGetSetting,
+		sqltypes.StringBindVariable(globalPauseSetting))
+	if err != nil {
+		return err
+	}
+	qr, err := jc.execQuery(ctx, "", query)
+	if err != nil {
+		return err
+	}
+	paused := false
+	if len(qr.Named().Rows) == 1 {
+		paused = qr.Named().Rows[0].AsString("value", "") == "1"
+	}
+	jc.globalPaused.Store(paused)
+	return nil
+}
+
 func (jc *JobController) LaunchJob(uuid string) (*sqltypes.Result, error) {
 	var emptyResult = &sqltypes.Result{}
 	status, err := jc.getStrJobInfo(jc.ctx, uuid, "status")
@@ -349,7 +804,7 @@ func (jc *JobController) CancelJob(uuid string) (*sqltypes.Result, error) {
 	if err != nil {
 		return emptyResult, err
 	}
-	if status == CanceledStatus || status == FailedStatus || status == CompletedStatus {
+	if !isJobStatusTransitionAllowed(status, CanceledStatus) {
 		return emptyResult, fmt.Errorf(" The job status is %s and can't canceld", status)
 	}
 	statusSetTime := time.Now().Format(time.DateTime)
@@ -358,35 +813,206 @@ func (jc *JobController) CancelJob(uuid string) (*sqltypes.Result, error) {
 		return emptyResult, err
 	}
 
-	tableName, _ := jc.getStrJobInfo(jc.ctx, uuid, "table_name")
-
-	// compared with pause，cancel need to delete job metadata
-	jc.deleteDMLJobRunningMeta(tableName)
+	// compared with pause，cancel need to delete job metadata,
+	// unless the job hasn't been prepared yet and the table is held by another job
+	if status != SubmittedStatus && status != BlockedStatus {
+		tableName, _ := jc.getStrJobInfo(jc.ctx, uuid, "table_name")
+		jc.deleteDMLJobRunningMeta(tableName)
+	}
 
 	jc.notifyJobManager()
+	jc.notifyJobFinished(uuid)
 
 	return qr, nil
 }
 
-func (jc *JobController) CompleteJob(ctx context.Context, uuid, table string) (*sqltypes.Result, error) {
+// PurgeJob deletes the metadata of a finished job and drops its batch table right away,
+// instead of waiting for tableGC to clean them up after tableGCInterval.
+func (jc *JobController) PurgeJob(uuid string) (*sqltypes.Result, error) {
+	var emptyResult = &sqltypes.Result{}
+	status, err := jc.getStrJobInfo(jc.ctx, uuid, "status")
+	if err != nil {
+		return emptyResult, err
+	}
+	if status != CanceledStatus && status != FailedStatus && status != CompletedStatus {
+		return emptyResult, fmt.Errorf("the job status is %s and can't be purged, only canceled, failed or completed jobs can be purged", status)
+	}
+	batchInfoTableSchema, err := jc.getStrJobInfo(jc.ctx, uuid, "batch_info_table_schema")
+	if err != nil {
+		return emptyResult, err
+	}
+	batchInfoTable, err := jc.getStrJobInfo(jc.ctx, uuid, "batch_info_table_name")
+	if err != nil {
+		return emptyResult, err
+	}
+
+	jc.tableMutex.Lock()
+	defer jc.tableMutex.Unlock()
+
+	if batchInfoTable != "" {
+		exists, err := jc.tableExists(jc.ctx, batchInfoTableSchema, batchInfoTable)
+		if err != nil {
+			return emptyResult, err
+		}
+		if exists {
+			_, err = jc.execQuery(jc.ctx, batchInfoTableSchema, fmt.Sprintf(sqlTemplateDropBatchTable, batchInfoTable))
+			if err != nil {
+				return emptyResult, err
+			}
+		}
+	}
+
+	deleteJobSQL, err := sqlparser.ParseAndBind(sqlDMLJobDeleteJob,
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return emptyResult, err
+	}
+	return jc.execQuery(jc.ctx, "", deleteJobSQL)
+}
+
+// CloneJob submits a new job with the SQL and the options of the job uuid, whatever its status,
+// e.g. to run a periodic cleanup again. The new job gets a new uuid and is not throttled,
+// it's launched right away unless a confirmation is required, like any submitted job.
+func (jc *JobController) CloneJob(uuid string) (*sqltypes.Result, error) {
+	var emptyResult = &sqltypes.Result{}
+	getInfoSQL, err := sqlparser.ParseAndBind(sqlDMLJobGetInfo,
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return emptyResult, err
+	}
+	qr, err := jc.execQuery(jc.ctx, "", getInfoSQL)
+	if err != nil {
+		return emptyResult, err
+	}
+	if len(qr.Rows) != 1 {
+		return emptyResult, fmt.Errorf("uuid %s has %d entrys in the table instead of 1", uuid, len(qr.Rows))
+	}
+	row := qr.Named().Row()
+	batchIntervalInMs, err := row.ToInt64("batch_interval_in_ms")
+	if err != nil {
+		return emptyResult, err
+	}
+	batchSize, err := row.ToInt64("batch_size")
+	if err != nil {
+		return emptyResult, err
+	}
+	// the options set by directives are stored apart from the SQL, they are added back to submit it.
+	sql, err := addJobDirectives(row["dml_sql"].ToString(), row["archive_table"].ToString(),
+		row["isolation_level"].ToString(), row["checksum_columns"].ToString(), row["max_replication_lag"].ToString(),
+		row["notify_url"].ToString(), row["batch_order"].ToString(), row["version_column"].ToString(),
+		row["tag"].ToString())
+	if err != nil {
+		return emptyResult, err
+	}
+	return jc.SubmitJob(sql, row["table_schema"].ToString(), row["running_time_period_start"].ToString(),
+		row["running_time_period_end"].ToString(), row["running_time_period_time_zone"].ToString(),
+		batchIntervalInMs, batchSize, false, row["fail_policy"].ToString(), "", "")
+}
+
+// DescribeJob returns the SQLs generated from the DML of a job to split it into batches,
+// so that the batches of a job can be explained without reading the logs.
+// The SQLs are regenerated from the DML, so the non-deterministic functions in the WHERE clause
+// are shown as they are written instead of the values frozen when the job was prepared.
+func (jc *JobController) DescribeJob(uuid string) (*sqltypes.Result, error) {
+	var emptyResult = &sqltypes.Result{}
+	sql, err := jc.getStrJobInfo(jc.ctx, uuid, "dml_sql")
+	if err != nil {
+		return emptyResult, err
+	}
+	tableSchema, err := jc.getStrJobInfo(jc.ctx, uuid, "table_schema")
+	if err != nil {
+		return emptyResult, err
+	}
+	batchOrder, err := jc.getStrJobInfo(jc.ctx, uuid, "batch_order")
+	if err != nil {
+		return emptyResult, err
+	}
+	tableName, whereExprs, _, err := parseJobDMLs(sql)
+	if err != nil {
+		return emptyResult, err
+	}
+	pkInfos, err := jc.getTablePkInfo(jc.ctx, tableSchema, tableName)
+	if err != nil {
+		return emptyResult, err
+	}
+	templates := genJobSQLTemplates(tableName, orWhereExprs(whereExprs), pkInfos, batchOrder)
+
+	return buildJobDescribeResult(uuid, sql, tableSchema, tableName, templates), nil
+}
+
+// ShowBatch returns the SQLs and the status of a batch of a job as they're recorded in its batch table,
+// so that the exact SQL that was or will be run for the batch can be reviewed.
+func (jc *JobController) ShowBatch(uuid, batchID string) (*sqltypes.Result, error) {
+	var emptyResult = &sqltypes.Result{}
+	batchInfoTableSchema, err := jc.getStrJobInfo(jc.ctx, uuid, "batch_info_table_schema")
+	if err != nil {
+		return emptyResult, err
+	}
+	batchInfoTable, err := jc.getStrJobInfo(jc.ctx, uuid, "batch_info_table_name")
+	if err != nil {
+		return emptyResult, err
+	}
+	if batchInfoTable == "" {
+		return emptyResult, fmt.Errorf("the batch table of job %s has not been created yet", uuid)
+	}
+	exists, err := jc.tableExists(jc.ctx, batchInfoTableSchema, batchInfoTable)
+	if err != nil {
+		return emptyResult, err
+	}
+	if !exists {
+		return emptyResult, fmt.Errorf("the batch table %s of job %s doesn't exist", batchInfoTable, uuid)
+	}
+
+	query, err := sqlparser.ParseAndBind(fmt.Sprintf(sqlTemplateShowBatch, batchInfoTable), sqltypes.StringBindVariable(batchID))
+	if err != nil {
+		return emptyResult, err
+	}
+	qr, err := jc.execQuery(jc.ctx, batchInfoTableSchema, query)
+	if err != nil {
+		return emptyResult, err
+	}
+	if len(qr.Rows) == 0 {
+		return emptyResult, fmt.Errorf("batch %s of job %s doesn't exist", batchID, uuid)
+	}
+	return qr, nil
+}
+
+func (jc *JobController) CompleteJob(ctx context.Context, uuid, tableSchema, table string) (*sqltypes.Result, error) {
+	// the table is analyzed before the job is set to completed, so that its statistics are fresh once the job completes,
+	// and before jc.workingTablesMutex is held, so that the job manager isn't blocked meanwhile
+	if analyzeTableAfterJob {
+		// the statistics are only an optimization, so failing to update them doesn't fail the job
+		if err := jc.analyzeJobTable(ctx, tableSchema, table); err != nil {
+			log.Errorf("JobController: failed to analyze table %s of job %s: %v", table, uuid, err)
+		}
+	}
+
 	jc.workingTablesMutex.Lock()
 	defer jc.workingTablesMutex.Unlock()
 
-	statusSetTime := time.Now().Format(time.DateTime)
-	qr, err := jc.updateJobStatus(ctx, uuid, CompletedStatus, statusSetTime)
+	completeTime := time.Now()
+	qr, err := jc.updateJobStatus(ctx, uuid, CompletedStatus, completeTime.Format(time.DateTime))
 	if err != nil {
 		return &sqltypes.Result{}, err
 	}
+	// the stats are only informative, so failing to record them doesn't fail the job
+	if err = jc.recordJobCompletionStats(ctx, uuid, completeTime); err != nil {
+		log.Errorf("JobController: failed to record completion stats of job %s: %v", uuid, err)
+	}
 
 	delete(jc.workingTables, table)
 	jc.notifyJobManager()
+	jc.notifyJobFinished(uuid)
 	return qr, nil
 }
 
 func (jc *JobController) FailJob(ctx context.Context, uuid, message, tableName string) {
 	_ = jc.updateJobMessage(ctx, uuid, message)
 	statusSetTime := time.Now().Format(time.DateTime)
-	_, _ = jc.updateJobStatus(ctx, uuid, FailedStatus, statusSetTime)
+	// a job which is already finished isn't notified again
+	if _, err := jc.updateJobStatus(ctx, uuid, FailedStatus, statusSetTime); err == nil {
+		jc.notifyJobFinished(uuid)
+	}
 
 	jc.deleteDMLJobRunningMeta(tableName)
 	jc.notifyJobManager()
@@ -396,6 +1022,10 @@ func (jc *JobController) jobManager() {
 	// Before jobManager get in infinite loop,
 	// it should check whether there are jobs already in 'queued' or 'postpone-launch' or 'paused' or 'running' status
 	// and recover their metadata.
+	// The global pause is restored first, so that the recovered jobs don't run batches if it's set.
+	if err := jc.loadGlobalPause(jc.ctx); err != nil {
+		log.Errorf("JobController: failed to load the global pause: %v", err)
+	}
 	jc.recoverJobsMetadata(jc.ctx)
 	log.Info("JobController: metadata of all running and paused jobs are restored to memory\n")
 
@@ -415,20 +1045,34 @@ func (jc *JobController) jobManager() {
 
 		qr, _ := jc.execQuery(jc.ctx, "", sqlDMLJobGetAllJobs)
 		if qr != nil {
+			blockedJobs := make(map[string]int64)
 			for _, row := range qr.Named().Rows {
 				jobArgs := JobArgs{}
 				jobArgs.initArgsByQueryResult(row)
 				switch jobArgs.status {
-				case SubmittedStatus:
-					if jc.checkIfDmlJobCanPrepare(jobArgs.uuid, jobArgs.status, jobArgs.table, jobArgs.timePeriodStart, jobArgs.timePeriodEnd) {
-						// init metadata to prevent two jobs with same table preparing at the same time
-						jc.initDMLJobRunningMeta(jobArgs.table)
-						// prepare the dml job: init batch info table
-						go jc.prepareDMLJob(jobArgs.uuid, jobArgs.dmlSQL, jobArgs.tableSchema, jobArgs.batchInfoTable, jobArgs.batchSize, jobArgs.postponeLaunch)
+				case SubmittedStatus, BlockedStatus:
+					if jc.blockJobIfTableBusy(&jobArgs) {
+						blockedJobs[jobArgs.table]++
+						continue
+					}
+					// init metadata to prevent two jobs with same table preparing at the same time
+					jc.initDMLJobRunningMeta(jobArgs.table)
+					// prepare the dml job: init batch info table
+					go jc.prepareDMLJob(jobArgs.uuid, jobArgs.dmlSQL, jobArgs.tableSchema, jobArgs.qualifiedBatchInfoTable(), jobArgs.batchOrder, jobArgs.batchSize, jobArgs.postponeLaunch)
+				case PostponeLaunchStatus:
+					if jc.globalPaused.Load() || !jc.launchScheduledJob(&jobArgs) {
+						continue
+					}
+					if jc.checkDmlJobSchemaExists(&jobArgs) && jc.checkDmlJobRunnable(jobArgs.uuid, jobArgs.status, jobArgs.table, jobArgs.timePeriodStart, jobArgs.timePeriodEnd) {
+						go jc.dmlJobBatchRunner(&jobArgs)
 					}
 				case QueuedStatus, NotInTimePeriodStatus:
-					if jc.checkDmlJobRunnable(jobArgs.uuid, jobArgs.status, jobArgs.table, jobArgs.timePeriodStart, jobArgs.timePeriodEnd) {
-						go jc.dmlJobBatchRunner(jobArgs.uuid, jobArgs.table, jobArgs.tableSchema, jobArgs.batchInfoTable, jobArgs.failPolicy, jobArgs.batchInterval, jobArgs.batchSize, jobArgs.timePeriodStart, jobArgs.timePeriodEnd)
+					// the jobs stay queued while all the jobs are paused
+					if jc.globalPaused.Load() {
+						continue
+					}
+					if jc.checkDmlJobSchemaExists(&jobArgs) && jc.checkDmlJobRunnable(jobArgs.uuid, jobArgs.status, jobArgs.table, jobArgs.timePeriodStart, jobArgs.timePeriodEnd) {
+						go jc.dmlJobBatchRunner(&jobArgs)
 					}
 				case CanceledStatus, FailedStatus, CompletedStatus:
 					timeZoneOffset, err := getTimeZoneOffset(jobArgs.timeZone)
@@ -436,7 +1080,7 @@ func (jc *JobController) jobManager() {
 						log.Errorf("jobManager: getTimeZoneOffset failed, %s", err)
 						continue
 					}
-					err = jc.tableGC(jc.ctx, jobArgs.uuid, jobArgs.tableSchema, jobArgs.batchInfoTable, jobArgs.statusSetTime, timeZoneOffset)
+					err = jc.tableGC(jc.ctx, jobArgs.uuid, jobArgs.batchInfoTableSchema, jobArgs.batchInfoTable, jobArgs.statusSetTime, timeZoneOffset)
 					if err != nil {
 						log.Errorf("jobManager: tableGC failed, %s", err)
 						continue
@@ -447,6 +1091,10 @@ func (jc *JobController) jobManager() {
 
 			}
 
+			jc.env.Stats().JobsBlocked.ResetAll()
+			for table, count := range blockedJobs {
+				jc.env.Stats().JobsBlocked.Set(table, count)
+			}
 		}
 
 		jc.tableMutex.Unlock()
@@ -454,8 +1102,24 @@ func (jc *JobController) jobManager() {
 	}
 }
 
+// launchScheduledJob sets a postponed job with a launch time to queued status once the launch time has arrived,
+// it returns true if the job has been launched.
+// acquire jc.tableMutex before calling this function
+func (jc *JobController) launchScheduledJob(jobArgs *JobArgs) bool {
+	timeNow := time.Now()
+	if jobArgs.launchAt == nil || timeNow.Before(*jobArgs.launchAt) {
+		return false
+	}
+	if _, err := jc.updateJobStatusLocked(jc.ctx, jobArgs.uuid, QueuedStatus, timeNow.Format(time.DateTime)); err != nil {
+		log.Errorf("jobManager: launch job %s failed, %s", jobArgs.uuid, err)
+		return false
+	}
+	jobArgs.status = QueuedStatus
+	return true
+}
+
 func (jc *JobController) checkIfDmlJobCanPrepare(jobUUID, status, table string, periodStartTime, periodEndTime *time.Time) bool {
-	if status != SubmittedStatus {
+	if status != SubmittedStatus && status != BlockedStatus {
 		return false
 	}
 	if _, exit := jc.workingTables[table]; exit {
@@ -464,6 +1128,60 @@ func (jc *JobController) checkIfDmlJobCanPrepare(jobUUID, status, table string,
 	return true
 }
 
+// blockJobIfTableBusy sets a submitted job to blocked status while another job works on its table,
+// it returns true if the job can't be prepared yet.
+// acquire jc.workingTablesMutex and jc.tableMutex before calling this function
+func (jc *JobController) blockJobIfTableBusy(jobArgs *JobArgs) bool {
+	if jc.checkIfDmlJobCanPrepare(jobArgs.uuid, jobArgs.status, jobArgs.table, jobArgs.timePeriodStart, jobArgs.timePeriodEnd) {
+		return false
+	}
+	if jobArgs.status == BlockedStatus {
+		return true
+	}
+	if _, err := jc.updateJobStatusLocked(jc.ctx, jobArgs.uuid, BlockedStatus, time.Now().Format(time.DateTime)); err != nil {
+		// it's set again in the next round
+		log.Errorf("jobManager: failed to block job %s, %s", jobArgs.uuid, err)
+		return true
+	}
+	log.Infof("jobManager: job %s is blocked until another job on table %s is done", jobArgs.uuid, jobArgs.table)
+	jobArgs.status = BlockedStatus
+	return true
+}
+
+// checkDmlJobSchemaExists fails the job if its schema has been dropped since it was submitted,
+// the runner could only fail on its first query otherwise.
+// acquire jc.workingTablesMutex and jc.tableMutex before calling this function
+func (jc *JobController) checkDmlJobSchemaExists(jobArgs *JobArgs) bool {
+	if jobArgs.tableSchema == "" {
+		return true
+	}
+	exists, err := jc.schemaExists(jc.ctx, jobArgs.tableSchema)
+	if err != nil {
+		// it's checked again in the next round
+		log.Errorf("jobManager: failed to check the schema of job %s, %s", jobArgs.uuid, err)
+		return false
+	}
+	if exists {
+		return true
+	}
+	message := fmt.Sprintf("schema dropped: schema %s of the job no longer exists", jobArgs.tableSchema)
+	log.Errorf("jobManager: job %s failed, %s", jobArgs.uuid, message)
+	updateMessageQuery, err := sqlparser.ParseAndBind(sqlDMLJobUpdateMessage,
+		sqltypes.StringBindVariable(message),
+		sqltypes.StringBindVariable(jobArgs.uuid))
+	if err != nil {
+		return false
+	}
+	_, _ = jc.execQuery(jc.ctx, "", updateMessageQuery)
+	if _, err = jc.updateJobStatusLocked(jc.ctx, jobArgs.uuid, FailedStatus, time.Now().Format(time.DateTime)); err != nil {
+		log.Errorf("jobManager: failed to fail job %s, %s", jobArgs.uuid, err)
+		return false
+	}
+	delete(jc.workingTables, jobArgs.table)
+	jc.notifyJobFinished(jobArgs.uuid)
+	return false
+}
+
 // todo feat: we can limit the number of job running concurrently
 // acquire 	jc.workingTablesMutex and jc.tableMutex before calling this function
 func (jc *JobController) checkDmlJobRunnable(jobUUID, status, table string, periodStartTime, periodEndTime *time.Time) bool {
@@ -474,14 +1192,7 @@ func (jc *JobController) checkDmlJobRunnable(jobUUID, status, table string, peri
 		timeNow := time.Now()
 		if !(timeNow.After(*periodStartTime) && timeNow.Before(*periodEndTime)) {
 			// if current time is not in running time period, we set the job status as "not-in-time-period"
-			submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobUpdateStatus,
-				sqltypes.StringBindVariable(NotInTimePeriodStatus),
-				sqltypes.StringBindVariable(timeNow.Format(time.DateTime)),
-				sqltypes.StringBindVariable(jobUUID))
-			if err != nil {
-				return false
-			}
-			_, _ = jc.execQuery(jc.ctx, "", submitQuery)
+			_, _ = jc.updateJobStatusLocked(jc.ctx, jobUUID, NotInTimePeriodStatus, timeNow.Format(time.DateTime))
 			return false
 		}
 	}
@@ -489,8 +1200,17 @@ func (jc *JobController) checkDmlJobRunnable(jobUUID, status, table string, peri
 	return true
 }
 
-func (jc *JobController) execBatchAndRecord(ctx context.Context, tableSchema, table, batchSQL, batchCountSQL, uuid, batchTable, batchID string, batchSize int64) (err error) {
+// execBatchAndRecord executes the batch batchID of the job described by args and records its result
+// in the batch table, in the same transaction.
+func (jc *JobController) execBatchAndRecord(ctx context.Context, args *JobArgs, batchSQL, batchCountSQL, batchID string) (err error) {
 	defer jc.env.LogError()
+	tableSchema, table, batchTable, batchSize := args.tableSchema, args.table, args.qualifiedBatchInfoTable(), args.batchSize
+
+	if !jc.beginBatch() {
+		return errors.New("job controller is closing")
+	}
+	defer jc.inFlightBatches.Done()
+	startTime := time.Now()
 
 	var setting pools.Setting
 	if tableSchema != "" {
@@ -504,6 +1224,19 @@ func (jc *JobController) execBatchAndRecord(ctx context.Context, tableSchema, ta
 		return err
 	}
 	defer conn.Recycle()
+
+	// The timeout starts once the connection is borrowed, so only the statements of the batch count.
+	// conn.Exec kills the statement running when ctx expires, e.g. one stuck in a lock wait.
+	if batchTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(batchTimeout)*time.Millisecond)
+		defer cancel()
+		defer func() {
+			if err != nil && ctx.Err() == context.DeadlineExceeded {
+				err = fmt.Errorf("batch %s exceeded the timeout of %dms: %v", batchID, batchTimeout, err)
+			}
+		}()
+	}
 	failpoint.Inject(failpointkey.CreateErrorWhenExecutingBatch.Name, func(val failpoint.Value) {
 		temp, ok := val.(bool)
 		if ok && temp {
@@ -511,22 +1244,39 @@ func (jc *JobController) execBatchAndRecord(ctx context.Context, tableSchema, ta
 		}
 	})
 
-	// 1. start a transaction
+	// 1. start a transaction, in the isolation level of the job if it's set.
+	// The isolation level is set for the session, so it's reset before the connection goes back to the pool.
+	if args.isolationLevel != "" {
+		_, err = conn.Exec(ctx, fmt.Sprintf(sqlTemplateSetIsolationLevel, args.isolationLevel), math.MaxInt32, false)
+		if err != nil {
+			return err
+		}
+		defer jc.resetBatchIsolationLevel(context.Background(), conn)
+	}
 	_, err = conn.Exec(ctx, "start transaction", math.MaxInt32, false)
-	// ensure the resource acquired by the transaction is released when the function exits
-	defer func() {
-		_, _ = conn.Exec(ctx, "rollback", math.MaxInt32, false)
-	}()
-
 	if err != nil {
 		return err
 	}
+	// Every path that returns before the commit below must roll back explicitly,
+	// otherwise the connection would go back to the pool with an open transaction.
+	// The rollback doesn't use ctx, since it must still be done if ctx is canceled.
+	committed := false
+	defer func() {
+		if !committed {
+			jc.rollbackBatchTransaction(context.Background(), conn)
+		}
+	}()
 
 	// 2. Query the number of rows that is going to be affected by this batch SQL.
 	// If it exceeds the threshold, we should split it.
-	// Here we use "FOR SHARE" to prevent users from modifying rows related to this batch.
-	batchCountSQLForShare := batchCountSQL + " LOCK IN SHARE MODE"
-	qr, err := conn.Exec(ctx, batchCountSQLForShare, math.MaxInt32, true)
+	// By default we use "FOR SHARE" to prevent users from modifying rows related to this batch.
+	// Without it the count takes no locks, so the rows may change before the batch SQL runs
+	// and the threshold check is only advisory.
+	countSQL := batchCountSQL
+	if batchCountForShare {
+		countSQL = batchCountSQL + " LOCK IN SHARE MODE"
+	}
+	qr, err := conn.Exec(ctx, countSQL, math.MaxInt32, true)
 	if err != nil {
 		return err
 	}
@@ -568,17 +1318,84 @@ func (jc *JobController) execBatchAndRecord(ctx context.Context, tableSchema, ta
 		}
 	}
 
-	// 3.Execute the batch SQL.
-	qr, err = conn.Exec(ctx, batchSQL, math.MaxInt32, true)
+	// 3.Archive the rows to delete if needed, and execute the batch SQL.
+	// Both are done in the same transaction, so no row is deleted without being archived.
+	// If asked, the checksum of the rows of the batch is computed before and after the batch SQL,
+	// so that the effect of the batch can be audited.
+	// If the job has a version column, the rows modified since the job was submitted are skipped and counted.
+	var skippedRows int64
+	if args.versionColumn != "" {
+		var skippedRowsSQL string
+		batchSQL, skippedRowsSQL, err = genVersionedBatchSQL(batchSQL, args.versionColumn, args.versionSnapshot)
+		if err != nil {
+			return err
+		}
+		qr, err = conn.Exec(ctx, skippedRowsSQL, math.MaxInt32, true)
+		if err != nil {
+			return err
+		}
+		if len(qr.Named().Rows) != 1 {
+			return errors.New("the len of qr of skipped rows is not 1")
+		}
+		skippedRows, _ = qr.Named().Rows[0].ToInt64("count_rows")
+	}
+	var checksumSQL string
+	var checksumBefore, checksumAfter uint64
+	if args.checksumColumns != "" {
+		checksumSQL, err = genBatchChecksumSQL(batchSQL, args.checksumColumns)
+		if err != nil {
+			return err
+		}
+		checksumBefore, err = execBatchChecksum(ctx, conn, checksumSQL)
+		if err != nil {
+			return err
+		}
+	}
+	var archivedRows uint64
+	if args.archiveTable != "" {
+		colNames, err := jc.getTableColNames(ctx, tableSchema, table)
+		if err != nil {
+			return err
+		}
+		archiveSQL, err := genArchiveBatchSQL(batchSQL, args.archiveTable, colNames)
+		if err != nil {
+			return err
+		}
+		qr, err = conn.Exec(ctx, archiveSQL, math.MaxInt32, false)
+		if err != nil {
+			return err
+		}
+		archivedRows = qr.RowsAffected
+	}
+	// The statements of a multi-statement job run in order on the batch, each with its own WHERE,
+	// so they may affect different rows, and the affected rows of the batch are the sum of theirs.
+	batchSQLs, err := splitJobSQL(batchSQL)
 	if err != nil {
 		return err
 	}
+	var affectedRows uint64
+	for _, stmtSQL := range batchSQLs {
+		qr, err = conn.Exec(ctx, stmtSQL, math.MaxInt32, true)
+		if err != nil {
+			return err
+		}
+		affectedRows += qr.RowsAffected
+	}
+	if args.archiveTable != "" && archivedRows != affectedRows {
+		return fmt.Errorf("batch %s archived %d rows but deleted %d rows", batchID, archivedRows, affectedRows)
+	}
+	if args.checksumColumns != "" {
+		checksumAfter, err = execBatchChecksum(ctx, conn, checksumSQL)
+		if err != nil {
+			return err
+		}
+	}
 
 	// 4.Record the executing result in the batch table.
 	updateBatchStatus := fmt.Sprintf(sqlTempalteUpdateBatchStatusAndAffectedRows, batchTable)
 	updateBatchStatusDoneSQL, err := sqlparser.ParseAndBind(updateBatchStatus,
 		sqltypes.StringBindVariable(CompletedStatus),
-		sqltypes.Int64BindVariable(int64(qr.RowsAffected)),
+		sqltypes.Int64BindVariable(int64(affectedRows)),
 		sqltypes.StringBindVariable(batchID))
 	if err != nil {
 		return err
@@ -587,24 +1404,96 @@ func (jc *JobController) execBatchAndRecord(ctx context.Context, tableSchema, ta
 	if err != nil {
 		return err
 	}
+	if args.checksumColumns != "" {
+		updateBatchChecksumSQL, err := sqlparser.ParseAndBind(fmt.Sprintf(sqlTemplateUpdateBatchChecksum, batchTable),
+			sqltypes.Uint64BindVariable(checksumBefore),
+			sqltypes.Uint64BindVariable(checksumAfter),
+			sqltypes.StringBindVariable(batchID))
+		if err != nil {
+			return err
+		}
+		_, err = conn.Exec(ctx, updateBatchChecksumSQL, math.MaxInt32, false)
+		if err != nil {
+			return err
+		}
+	}
+	if args.versionColumn != "" {
+		updateBatchSkippedRowsSQL, err := sqlparser.ParseAndBind(fmt.Sprintf(sqlTemplateUpdateBatchSkippedRows, batchTable),
+			sqltypes.Int64BindVariable(skippedRows),
+			sqltypes.StringBindVariable(batchID))
+		if err != nil {
+			return err
+		}
+		_, err = conn.Exec(ctx, updateBatchSkippedRowsSQL, math.MaxInt32, false)
+		if err != nil {
+			return err
+		}
+	}
 
 	// 5.Commit the transaction.
+	// Don't commit if the job controller is closed in the meantime, the tablet may no longer be primary.
+	if err = ctx.Err(); err != nil {
+		return err
+	}
 	_, err = conn.Exec(ctx, "commit", math.MaxInt32, false)
 	if err != nil {
 		return err
 	}
+	committed = true
+	// record the distributions to help tuning the batch size
+	jc.env.Stats().JobBatchTimings.Record(table, startTime)
+	jc.env.Stats().JobBatchAffectedRows.Add(int64(affectedRows))
 	return nil
 }
 
+// execBatchChecksum computes the checksum of the rows of a batch with the SQL generated by genBatchChecksumSQL.
+func execBatchChecksum(ctx context.Context, conn *connpool.DBConn, checksumSQL string) (uint64, error) {
+	qr, err := conn.Exec(ctx, checksumSQL, math.MaxInt32, true)
+	if err != nil {
+		return 0, err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return 0, errors.New("the len of qr of batch checksum is not 1")
+	}
+	return qr.Named().Rows[0].ToUint64("checksum")
+}
+
+// rollbackBatchTransaction rolls back the transaction opened by execBatchAndRecord.
+// If the rollback itself fails, the state of the transaction is unknown,
+// so the connection is closed to make sure it won't be reused by others.
+func (jc *JobController) rollbackBatchTransaction(ctx context.Context, conn *connpool.DBConn) {
+	_, err := conn.Exec(ctx, "rollback", math.MaxInt32, false)
+	if err != nil {
+		log.Errorf("JobController: failed to rollback batch transaction, close the connection: %v", err)
+		conn.Close()
+	}
+}
+
+func (jc *JobController) resetBatchIsolationLevel(ctx context.Context, conn *connpool.DBConn) {
+	_, err := conn.Exec(ctx, sqlResetIsolationLevel, math.MaxInt32, false)
+	if err != nil {
+		log.Errorf("JobController: failed to reset the isolation level of batch connection, close the connection: %v", err)
+		conn.Close()
+	}
+}
+
 // Split batches that larger than batchSize into two batches, with the first batch having a size equal to batchSize.
 // The basic principle of the splitting is to iterate through the query result set of batchCountSQL of the original batch.
 // Take the primary key (pk) of the batchSize-th record as the original batch's PKEnd and the primary key of the (batchSize+1)-th record as the PKStart for the new batch.
 // The original batch's PKStart becomes the PKStart for the original batch, and the PKEnd becomes the PKEnd for the new batch.
 func (jc *JobController) splitBatchIntoTwo(ctx context.Context, tableSchema, table, batchTable, batchSQL, batchCountSQL, batchID string, conn *connpool.DBConn, batchSize, expectedRow int64) (newCurrentBatchSQL string, err error) {
-	batchSQLStmt, err := sqlparser.Parse(batchSQL)
+	batchSQLs, err := splitJobSQL(batchSQL)
 	if err != nil {
 		return "", err
 	}
+	var batchSQLStmts []sqlparser.Statement
+	for _, stmtSQL := range batchSQLs {
+		batchSQLStmt, err := sqlparser.Parse(stmtSQL)
+		if err != nil {
+			return "", err
+		}
+		batchSQLStmts = append(batchSQLStmts, batchSQLStmt)
+	}
 	batchCountSQLStmt, err := sqlparser.Parse(batchCountSQL)
 	if err != nil {
 		return "", err
@@ -643,10 +1532,20 @@ func (jc *JobController) splitBatchIntoTwo(ctx context.Context, tableSchema, tab
 		}
 	}
 	// 2.2. Generate new batchSQL and new batchCountSQL.
-	curBatchSQL, newBatchSQL, newBatchCountSQL, err := genNewBatchSQLsAndCountSQLsWhenSplittingBatch(batchSQLStmt, batchCountSQLStmt, curBatchNewEnd, newBatchStart, pkInfos)
-	if err != nil {
-		return "", err
+	// Each statement of a multi-statement job is split the same way, the count SQL is the same whatever the statement.
+	var curBatchSQLs, newBatchSQLs []string
+	var newBatchCountSQL string
+	for _, batchSQLStmt := range batchSQLStmts {
+		stmtCurBatchSQL, stmtNewBatchSQL, stmtNewBatchCountSQL, err := genNewBatchSQLsAndCountSQLsWhenSplittingBatch(batchSQLStmt, batchCountSQLStmt, curBatchNewEnd, newBatchStart, pkInfos)
+		if err != nil {
+			return "", err
+		}
+		curBatchSQLs = append(curBatchSQLs, stmtCurBatchSQL)
+		newBatchSQLs = append(newBatchSQLs, stmtNewBatchSQL)
+		newBatchCountSQL = stmtNewBatchCountSQL
 	}
+	curBatchSQL := strings.Join(curBatchSQLs, jobSQLSeparator)
+	newBatchSQL := strings.Join(newBatchSQLs, jobSQLSeparator)
 
 	// 2.3. Calculate the batch start and end fields for the two batches.
 	currentBatchNewBeginStr, currentBatchNewEndStr, newBatchBeginStr, newBatchEndStr, err := getNewBatchesBeginAndEndStr(ctx, conn, batchTable, batchID, curBatchNewEnd, newBatchStart)
@@ -676,15 +1575,23 @@ func (jc *JobController) splitBatchIntoTwo(ctx context.Context, tableSchema, tab
 	return newCurrentBatchSQL, nil
 }
 
-func (jc *JobController) dmlJobBatchRunner(uuid, table, tableSchema, batchTable, failPolicy string, batchInterval, batchSize int64, timePeriodStart, timePeriodEnd *time.Time) {
-
-	timer := time.NewTicker(time.Duration(batchInterval) * time.Millisecond)
+// dmlJobBatchRunner executes the batches of the job described by args one after another,
+// until the job is completed, paused, canceled or failed.
+func (jc *JobController) dmlJobBatchRunner(args *JobArgs) {
+	uuid, table, tableSchema, batchTable := args.uuid, args.table, args.tableSchema, args.qualifiedBatchInfoTable()
+	timer := time.NewTicker(time.Duration(args.batchInterval) * time.Millisecond)
 	defer timer.Stop()
+	// the connection of the runner is given back once the job is paused, canceled, completed or failed
+	rc := jc.newRunnerConn(tableSchema)
+	defer rc.release()
 
 	_, err := jc.updateJobStatus(jc.ctx, uuid, RunningStatus, time.Now().Format(time.DateTime))
 	if err != nil {
 		jc.FailJob(jc.ctx, uuid, err.Error(), table)
 	}
+	if err = jc.recordJobStartTime(jc.ctx, uuid, time.Now()); err != nil {
+		log.Errorf("JobController: failed to record start time of job %s: %v", uuid, err)
+	}
 
 	for {
 		select {
@@ -692,7 +1599,7 @@ func (jc *JobController) dmlJobBatchRunner(uuid, table, tableSchema, batchTable,
 			return
 		case <-timer.C:
 		}
-		status, err := jc.getStrJobInfo(jc.ctx, uuid, "status")
+		status, err := jc.getStrJobInfoWith(jc.ctx, rc, uuid, "status")
 		if err != nil {
 			jc.FailJob(jc.ctx, uuid, err.Error(), table)
 			return
@@ -701,11 +1608,15 @@ func (jc *JobController) dmlJobBatchRunner(uuid, table, tableSchema, batchTable,
 		if status != RunningStatus {
 			return
 		}
+		// if all the jobs are paused, wait until they are resumed without executing any batch
+		if jc.globalPaused.Load() {
+			continue
+		}
 
 		// check whether current time is in running time period
-		if timePeriodStart != nil && timePeriodEnd != nil {
+		if args.timePeriodStart != nil && args.timePeriodEnd != nil {
 			currentTime := time.Now()
-			if !(currentTime.After(*timePeriodStart) && currentTime.Before(*timePeriodEnd)) {
+			if !(currentTime.After(*args.timePeriodStart) && currentTime.Before(*args.timePeriodEnd)) {
 				_, err = jc.updateJobStatus(jc.ctx, uuid, NotInTimePeriodStatus, currentTime.Format(time.DateTime))
 				if err != nil {
 					jc.FailJob(jc.ctx, uuid, err.Error(), table)
@@ -718,39 +1629,55 @@ func (jc *JobController) dmlJobBatchRunner(uuid, table, tableSchema, batchTable,
 		if !jc.requestThrottle(uuid) {
 			continue
 		}
+		// the job may tolerate less replication lag than the throttler
+		if !jc.requestJobReplicationLag(uuid, args.maxReplicationLag) {
+			continue
+		}
 
 		// get batchID of batch to execute now
-		batchIDToExec, err := jc.getBatchIDToExec(jc.ctx, tableSchema, batchTable)
+		batchIDToExec, err := getBatchIDToExec(jc.ctx, rc, tableSchema, batchTable)
 		if err != nil {
 			jc.FailJob(jc.ctx, uuid, err.Error(), table)
 			return
 		}
 		if batchIDToExec == "" {
 			// it means that all batches are finished, so we can complete the job
-			_, err = jc.CompleteJob(jc.ctx, uuid, table)
+			_, err = jc.CompleteJob(jc.ctx, uuid, tableSchema, table)
 			if err != nil {
 				jc.FailJob(jc.ctx, uuid, err.Error(), table)
 			}
 			return
 		}
 
-		batchSQL, batchCountSQL, err := jc.getBatchSQLsByID(jc.ctx, batchIDToExec, batchTable, tableSchema)
+		batchSQL, batchCountSQL, err := getBatchSQLsByID(jc.ctx, rc, batchIDToExec, batchTable, tableSchema)
 		if err != nil {
 			jc.FailJob(jc.ctx, uuid, err.Error(), table)
 			return
 		}
 
-		// execute the batchSQL and record the result in a transaction
-		err = jc.execBatchAndRecord(jc.ctx, tableSchema, table, batchSQL, batchCountSQL, uuid, batchTable, batchIDToExec, batchSize)
+		// execute the batchSQL and record the result in a transaction,
+		// which is executed again if it's rolled back by a deadlock
+		for retries := 0; ; retries++ {
+			err = jc.execBatchAndRecord(jc.ctx, args, batchSQL, batchCountSQL, batchIDToExec)
+			if err == nil || retries >= batchDeadlockRetries || !isDeadlock(err) || jc.ctx.Err() != nil {
+				break
+			}
+			log.Infof("JobController: batch %s of job %s deadlocked: %v; will retry", batchIDToExec, uuid, err)
+			jc.env.Stats().DeadlockRetries.Add("DMLJobs", 1)
+		}
 		// if the batch fails, do something according to the failPolicy
 		if err != nil {
+			// the batch is interrupted because the job controller is closed, it will be executed again after reopening
+			if jc.ctx.Err() != nil {
+				return
+			}
 			// todo feat: if we support concurrency in batch level, we should redesign the code logic here
-			switch failPolicy {
+			switch args.failPolicy {
 			case failPolicyAbort:
 				jc.FailJob(jc.ctx, uuid, err.Error(), table)
 				return
 			case failPolicySkip:
-				_ = jc.updateBatchStatus(tableSchema, batchTable, failPolicySkip, batchIDToExec, err.Error())
+				_ = updateBatchStatus(rc, tableSchema, batchTable, failPolicySkip, batchIDToExec, err.Error())
 				continue
 			case failPolicyPause:
 				msg := fmt.Sprintf("batch %s failed, pause job: %s", batchIDToExec, err.Error())
@@ -789,12 +1716,12 @@ func (jc *JobController) recoverJobsMetadata(ctx context.Context) {
 			switch status {
 			case PreparingStatus:
 				jc.initDMLJobRunningMeta(jobArgs.table)
-				go jc.prepareDMLJob(jobArgs.uuid, jobArgs.dmlSQL, jobArgs.tableSchema, jobArgs.batchInfoTable, jobArgs.batchSize, jobArgs.postponeLaunch)
+				go jc.prepareDMLJob(jobArgs.uuid, jobArgs.dmlSQL, jobArgs.tableSchema, jobArgs.qualifiedBatchInfoTable(), jobArgs.batchOrder, jobArgs.batchSize, jobArgs.postponeLaunch)
 			case QueuedStatus, NotInTimePeriodStatus, PausedStatus:
 				jc.initDMLJobRunningMeta(jobArgs.table)
 			case RunningStatus:
 				jc.initDMLJobRunningMeta(jobArgs.table)
-				go jc.dmlJobBatchRunner(jobArgs.uuid, jobArgs.table, jobArgs.tableSchema, jobArgs.batchInfoTable, jobArgs.failPolicy, jobArgs.batchInterval, jobArgs.batchSize, jobArgs.timePeriodStart, jobArgs.timePeriodEnd)
+				go jc.dmlJobBatchRunner(&jobArgs)
 			}
 		}
 
@@ -803,7 +1730,7 @@ func (jc *JobController) recoverJobsMetadata(ctx context.Context) {
 	}
 }
 
-func (jc *JobController) tableGC(ctx context.Context, uuid, tableSchema, batchInfoTable, statusSetTime string, timeZoneOffset int) error {
+func (jc *JobController) tableGC(ctx context.Context, uuid, batchInfoTableSchema, batchInfoTable, statusSetTime string, timeZoneOffset int) error {
 	// Because we recode both datetime and timezone in job table, so we can recover the time data correctly
 	statusSetTimeObj, err := time.Parse(time.DateTime, statusSetTime)
 	location := time.FixedZone("time zone", timeZoneOffset)
@@ -823,7 +1750,7 @@ func (jc *JobController) tableGC(ctx context.Context, uuid, tableSchema, batchIn
 		// delete job entry by SQL
 		_, _ = jc.execQuery(ctx, "", deleteJobSQL)
 		// delete batch table by table gc: set the table as "PURGE" status
-		_, _ = jc.gcBatchInfoTable(ctx, tableSchema, batchInfoTable, uuid, time.Now().UTC())
+		_, _ = jc.gcBatchInfoTable(ctx, batchInfoTableSchema, batchInfoTable, uuid, time.Now().UTC())
 	}
 	return nil
 }
@@ -846,31 +1773,44 @@ func (jc *JobController) gcBatchInfoTable(ctx context.Context, tableSchema, arti
 	return toTableName, err
 }
 
-func (jc *JobController) prepareDMLJob(jobUUID, sql, tableSchema, batchTableName string, batchSize int64, postponeLaunch bool) {
+func (jc *JobController) prepareDMLJob(jobUUID, sql, tableSchema, batchTableName, batchOrder string, batchSize int64, postponeLaunch bool) {
 	// 1.Validate and parse the DML SQL submitted by the user.
-	tableName, whereExpr, stmt, err := parseDML(sql)
+	tableName, whereExprs, stmts, err := parseJobDMLs(sql)
 	if err != nil {
 		jc.FailJob(jc.ctx, jobUUID, err.Error(), tableName)
+		return
+	}
+	// Freeze the time functions in the where clause, so that all the batches use the same cutoff,
+	// even if the job is prepared again to resume the build of its batch table.
+	whereExprs, err = jc.getFrozenWhereExprs(jc.ctx, jobUUID, tableSchema, whereExprs)
+	if err != nil {
+		jc.FailJob(jc.ctx, jobUUID, err.Error(), tableName)
+		return
 	}
 	// 2.Validate the PK columns types.
 	pkInfos, err := jc.getTablePkInfo(jc.ctx, tableSchema, tableName)
 	if err != nil {
 		jc.FailJob(jc.ctx, jobUUID, err.Error(), tableName)
+		return
 	}
 	if existUnSupportedPK(pkInfos) {
 		jc.FailJob(jc.ctx, jobUUID, "the table has unsupported PK type", tableName)
+		return
 	}
 
-	// 3.Generate selectPksSQL which are used for creating the batch table.
-	selectPksSQL := sprintfSelectPksSQL(tableName, sqlparser.String(whereExpr), pkInfos)
-
-	// 4.Generate the batch table based on the selectPksSQL.
+	// 3.Generate the batch table from the PKs of the rows affected by the job.
+	// The batches of a multi-statement job cover the rows matched by any of its statements.
 	// after creating batch table, we set the job status to "preparing"
-	err = jc.createBatchTable(jobUUID, selectPksSQL, tableSchema, tableName, batchTableName, whereExpr, stmt, pkInfos, batchSize)
+	err = jc.createBatchTable(jobUUID, tableSchema, tableName, batchTableName, batchOrder, whereExprs, stmts, pkInfos, batchSize)
 	if err != nil {
+		// the build is interrupted because the job controller is closed, it will resume after reopening
+		if jc.ctx.Err() != nil {
+			return
+		}
 		jc.FailJob(jc.ctx, jobUUID, err.Error(), tableName)
+		return
 	}
-	// 5.Set job status to "queued" or "postpone launch"
+	// 4.Set job status to "queued" or "postpone launch"
 	if postponeLaunch {
 		_, err = jc.updateJobStatus(jc.ctx, jobUUID, PostponeLaunchStatus, time.Now().Format(time.DateTime))
 	} else {
@@ -878,23 +1818,28 @@ func (jc *JobController) prepareDMLJob(jobUUID, sql, tableSchema, batchTableName
 	}
 	if err != nil {
 		jc.FailJob(jc.ctx, jobUUID, err.Error(), tableName)
+		return
 	}
 	jc.notifyJobManager()
 }
 
 func (jc *JobController) initJobBatches(jobUUID, sql, tableSchema string, userBatchSize int64) (tableName, batchTableName string, batchSize int64, err error) {
 	// 1.Validate and parse the DML SQL submitted by the user.
-	tableName, _, _, err = parseDML(sql)
+	tableName, whereExprs, stmts, err := parseJobDMLs(sql)
 	if err != nil {
 		return "", "", 0, err
 	}
 
 	// 2.Calculate the batchSize for each batch.
 	// batchSize = min(userBatchSize, batchSizeThreshold / 每个表的index数量 * ratioOfBatchSizeThreshold)
-	indexCount, err := jc.getIndexCount(tableSchema, tableName)
+	indexCount, pkNames, err := jc.getIndexCount(tableSchema, tableName)
 	if err != nil {
 		return "", "", 0, err
 	}
+	// The batch SQLs are only generated once the job runs, a sample of them is checked now to fail the job at submit.
+	if err = validateBatchSQLs(tableName, whereExprs, stmts, pkNames); err != nil {
+		return "", "", 0, err
+	}
 	actualThreshold := int64(float64(batchSizeThreshold/indexCount) * ratioOfBatchSizeThreshold)
 	if userBatchSize < actualThreshold {
 		batchSize = userBatchSize
@@ -906,25 +1851,37 @@ func (jc *JobController) initJobBatches(jobUUID, sql, tableSchema string, userBa
 	return tableName, batchTableName, batchSize, err
 }
 
-func (jc *JobController) createBatchTable(jobUUID, selectSQL, tableSchema, tableName, batchTableName string, whereExpr sqlparser.Expr, stmt sqlparser.Statement, pkInfos []PKInfo, batchSize int64) error {
-	// Execute selectSQL to obtain an ordered result set of PK values
+// createBatchTable builds the batch table of a job from the PKs of the rows matched by any of whereExprs, in batchOrder.
+// The PKs are selected by pages of batchTableChunkSize batches, each page starting after the last PK of the previous one,
+// so that they're never all loaded in memory. The batches of a page are inserted together, and the batch table is kept
+// if it already exists, so that a build interrupted, e.g. by a failover, resumes after the last PK of the batches
+// already inserted instead of restarting. Whatever the order, the begin of a batch is its lowest PK and its end is its highest PK.
+// whereExprs are the where exprs of stmts, the statements of the job.
+func (jc *JobController) createBatchTable(jobUUID, tableSchema, tableName, batchTableName, batchOrder string, whereExprs []sqlparser.Expr, stmts []sqlparser.Statement, pkInfos []PKInfo, batchSize int64) error {
+	startTime := time.Now()
+	whereExpr := orWhereExprs(whereExprs)
+	pageSize := batchSize * int64(batchTableChunkSize)
+	// Execute the select of the first page to obtain an ordered result set of PK values
 	// which are used to generate batch SQL for each batch.
+	selectSQL, err := genSelectPKsPageSQL(tableName, whereExpr, pkInfos, batchOrder, nil, pageSize)
+	if err != nil {
+		return err
+	}
 	qr, err := jc.execQuery(jc.ctx, tableSchema, selectSQL)
 	if err != nil {
 		return err
 	}
-	if len(qr.Named().Rows) == 0 {
+	if len(qr.Rows) == 0 {
 		return errors.New("this DML sql won't affect any rows")
 	}
 
 	// todo feat: maybe we don't need to store batchSQL and batchCountSQL in system table, just generate them during user query, by Go or Mysql
 
 	// For each DML job, create a batch info table records specific information about how the job is divided into batches.
-	// Drop table before creating to make sure that this function is reentrant
-	DropTableSQL := fmt.Sprintf(sqlTemplateDropBatchTable, batchTableName)
-	_, _ = jc.execQuery(jc.ctx, tableSchema, DropTableSQL)
-
-	createTableSQL := fmt.Sprintf(sqlTemplateCreateBatchTable, batchTableName)
+	createTableSQL, err := genCreateBatchTableSQL(batchTableName)
+	if err != nil {
+		return err
+	}
 	_, err = jc.execQuery(jc.ctx, tableSchema, createTableSQL)
 	if err != nil {
 		return err
@@ -936,70 +1893,161 @@ func (jc *JobController) createBatchTable(jobUUID, selectSQL, tableSchema, table
 		return err
 	}
 
+	lastPK, currentBatchID, err := jc.resumeBatchTable(jc.ctx, tableSchema, batchTableName, pkInfos)
+	if err != nil {
+		return err
+	}
+	rows := qr.Rows
+
 	// Iterate through each row and each PK value,
 	// recording the start and end PK values for each batch (maybe more than one PK columns and types).
 	currentBatchSize := int64(0)
 	var currentBatchStart []sqltypes.Value
 	var currentBatchEnd []sqltypes.Value
-	currentBatchID := "1"
-
-	i := 0
-	for i < len(qr.Rows) {
-		if !jc.requestThrottle(jobUUID) {
-			time.Sleep(1 * time.Millisecond)
-			continue
-		}
-
-		values := qr.Rows[i]
-		if currentBatchSize == 0 {
-			currentBatchStart = values
-		}
-		currentBatchEnd = values
-		currentBatchSize++
-		// When the number of rows reaches a batchSize,
-		// generate a batch SQL to be executed for this batch, and insert an entry into the batch table.
-		if currentBatchSize == batchSize {
-			batchSQL, countSQL, batchStartStr, batchEndStr, err := createBatchInfoTableEntry(tableName, stmt, whereExpr, currentBatchStart, currentBatchEnd, pkInfos)
+	var chunk []batchTableEntry
+
+	// addBatch generates the batch SQL of the current batch, and inserts the chunk of batches once it's full or if flush is set.
+	addBatch := func(flush bool) error {
+		if currentBatchSize != 0 {
+			lowestPK, highestPK := currentBatchStart, currentBatchEnd
+			if batchOrder == batchOrderDesc {
+				lowestPK, highestPK = currentBatchEnd, currentBatchStart
+			}
+			batchSQL, countSQL, batchStartStr, batchEndStr, err := createBatchInfoTableEntry(tableName, stmts, whereExprs, lowestPK, highestPK, pkInfos)
 			if err != nil {
 				return err
 			}
-			err = jc.insertBatchInfoTableEntry(jc.ctx, tableSchema, batchTableName, currentBatchID, batchSQL, countSQL, batchStartStr, batchEndStr, currentBatchSize)
+			batchLastPK, err := encodeLastPK(currentBatchEnd)
 			if err != nil {
 				return err
 			}
+			chunk = append(chunk, batchTableEntry{
+				batchID:    currentBatchID,
+				batchSQL:   batchSQL,
+				countSQL:   countSQL,
+				batchSize:  currentBatchSize,
+				batchBegin: batchStartStr,
+				batchEnd:   batchEndStr,
+				lastPK:     batchLastPK,
+			})
 			currentBatchID, err = currentBatchIDInc(currentBatchID)
 			if err != nil {
 				return err
 			}
 			currentBatchSize = 0
 		}
-		i++
+		if len(chunk) == 0 || (!flush && len(chunk) < batchTableChunkSize) {
+			return nil
+		}
+		if err := jc.insertBatchInfoTableEntries(jc.ctx, tableSchema, batchTableName, chunk); err != nil {
+			return err
+		}
+		chunk = chunk[:0]
+		if batchTableMaxBuildTime > 0 && time.Since(startTime) > time.Duration(batchTableMaxBuildTime)*time.Second {
+			return fmt.Errorf("building the batch table took more than %d seconds", batchTableMaxBuildTime)
+		}
+		return nil
+	}
+
+	for {
+		// the first page is selected again from the PK the build resumes after
+		if lastPK != nil {
+			selectSQL, err = genSelectPKsPageSQL(tableName, whereExpr, pkInfos, batchOrder, lastPK, pageSize)
+			if err != nil {
+				return err
+			}
+			qr, err = jc.execQuery(jc.ctx, tableSchema, selectSQL)
+			if err != nil {
+				return err
+			}
+			rows = qr.Rows
+		}
+		for i := 0; i < len(rows); {
+			if err := jc.ctx.Err(); err != nil {
+				return err
+			}
+			if !jc.requestThrottle(jobUUID) {
+				time.Sleep(1 * time.Millisecond)
+				continue
+			}
+
+			values := rows[i]
+			if currentBatchSize == 0 {
+				currentBatchStart = values
+			}
+			currentBatchEnd = values
+			currentBatchSize++
+			// When the number of rows reaches a batchSize,
+			// generate a batch SQL to be executed for this batch, and add an entry to the chunk of batches to insert.
+			if currentBatchSize == batchSize {
+				if err := addBatch(false); err != nil {
+					return err
+				}
+			}
+			i++
+		}
+		if int64(len(rows)) < pageSize {
+			break
+		}
+		lastPK = rows[len(rows)-1]
 	}
 	// The number of rows in the last batch may less than batchSize:
 	// any remaining rows should be allocated to the last batch at the end of the loop.
-	if currentBatchSize != 0 {
-		batchSQL, countSQL, batchStartStr, batchEndStr, err := createBatchInfoTableEntry(tableName, stmt, whereExpr, currentBatchStart, currentBatchEnd, pkInfos)
-		if err != nil {
-			return err
-		}
-		err = jc.insertBatchInfoTableEntry(jc.ctx, tableSchema, batchTableName, currentBatchID, batchSQL, countSQL, batchStartStr, batchEndStr, currentBatchSize)
+	return addBatch(true)
+}
+
+// resumeBatchTable returns the PK the build of a batch table resumes after and the id of the first batch it starts from.
+// The build starts from scratch if the batch table is empty, and resumes after the last PK of the last batch inserted otherwise.
+// The batch table is emptied and built again if its last batch has no last PK, i.e. it was inserted before last_pk was recorded.
+func (jc *JobController) resumeBatchTable(ctx context.Context, tableSchema, batchTableName string, pkInfos []PKInfo) ([]sqltypes.Value, string, error) {
+	qr, err := jc.execQuery(ctx, tableSchema, fmt.Sprintf(sqlTemplateGetLastBatch, batchTableName))
+	if err != nil {
+		return nil, "", err
+	}
+	lastBatch := qr.Named().Row()
+	if lastBatch == nil {
+		return nil, "1", nil
+	}
+	lastBatchID := lastBatch.AsString("batch_id", "")
+	encodedLastPK := lastBatch.AsString("last_pk", "")
+	if encodedLastPK == "" {
+		log.Warningf("JobController: batch %s of batch table %s has no last PK, build it again", lastBatchID, batchTableName)
+		_, err = jc.execQuery(ctx, tableSchema, fmt.Sprintf(sqlTemplateTruncateBatchTable, batchTableName))
 		if err != nil {
-			return err
+			return nil, "", err
 		}
+		return nil, "1", nil
 	}
-	return nil
+	lastPK, err := decodeLastPK(encodedLastPK, pkInfos)
+	if err != nil {
+		return nil, "", err
+	}
+	nextBatchID, err := currentBatchIDInc(lastBatchID)
+	if err != nil {
+		return nil, "", err
+	}
+	log.Infof("JobController: resume building batch table %s from batch %s", batchTableName, nextBatchID)
+	return lastPK, nextBatchID, nil
 }
 
-func createBatchInfoTableEntry(tableName string, sqlStmt sqlparser.Statement, whereExpr sqlparser.Expr,
+// createBatchInfoTableEntry generates the SQLs of a batch. The batch SQL of a multi-statement job has a statement
+// for each statement of the job, and its count SQL counts the rows matched by any of them.
+func createBatchInfoTableEntry(tableName string, sqlStmts []sqlparser.Statement, whereExprs []sqlparser.Expr,
 	currentBatchStart, currentBatchEnd []sqltypes.Value, pkInfos []PKInfo) (batchSQL, countSQL, batchStartStr, batchEndStr string, err error) {
-	batchSQL, finalWhereStr, err := genBatchSQL(sqlStmt, whereExpr, currentBatchStart, currentBatchEnd, pkInfos)
-	if err != nil {
-		return "", "", "", "", err
+	batchSQLs := make([]string, 0, len(sqlStmts))
+	for i, sqlStmt := range sqlStmts {
+		stmtBatchSQL, _, err := genBatchSQL(sqlStmt, whereExprs[i], currentBatchStart, currentBatchEnd, pkInfos)
+		if err != nil {
+			return "", "", "", "", err
+		}
+		batchSQLs = append(batchSQLs, stmtBatchSQL)
 	}
-	countSQL = genCountSQL(tableName, finalWhereStr)
+	batchSQL = strings.Join(batchSQLs, jobSQLSeparator)
+	pkConditionExpr, err := genBatchPKConditionExpr(currentBatchStart, currentBatchEnd, pkInfos)
 	if err != nil {
 		return "", "", "", "", err
 	}
+	countSQL = genCountSQL(tableName, sqlparser.String(&sqlparser.AndExpr{Left: orWhereExprs(whereExprs), Right: pkConditionExpr}))
 	batchStartStr, batchEndStr, err = genBatchStartAndEndStr(currentBatchStart, currentBatchEnd)
 	if err != nil {
 		return "", "", "", "", err
@@ -0,0 +1,1657 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/mysql/fakesqldb"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/dbconfigs"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/background"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/throttle"
+)
+
+func newTestJobController(t *testing.T, db *fakesqldb.DB) *JobController {
+	params, _ := db.ConnParams().MysqlParams()
+	cp := *params
+	config := tabletenv.NewDefaultConfig()
+	config.DB = dbconfigs.NewTestDBConfigs(cp, cp, "fakesqldb")
+	env := tabletenv.NewEnv(config, "JobControllerTest")
+	taskPool := background.NewTaskPool(env)
+	taskPool.Open()
+	t.Cleanup(taskPool.Close)
+	jc := NewJobController(nil, env, nil, taskPool)
+	jc.ctx, jc.cancelOperation = context.WithCancel(context.Background())
+	// there is no throttler, it's faked as a ready one
+	jc.checkThrottlerReady = func() error { return nil }
+	return jc
+}
+
+func TestExecBatchAndRecordRollbackOnError(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	// fail the statement that records the batch result, which runs after the batch SQL
+	db.AddRejectedQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		errors.New("injected error"))
+
+	err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+	require.ErrorContains(t, err, "injected error")
+	assert.Equal(t, 1, db.GetQueryCalledNum(batchSQL))
+	assert.Equal(t, 1, db.GetQueryCalledNum("rollback"))
+	assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+}
+
+func TestExecBatchAndRecordRollbackWhenBatchCompleted(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), CompletedStatus))
+
+	err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, db.GetQueryCalledNum(batchSQL))
+	assert.Equal(t, 1, db.GetQueryCalledNum("rollback"))
+}
+
+func TestExecBatchAndRecordCountWithoutForShare(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	defer func(old bool) { batchCountForShare = old }(batchCountForShare)
+	batchCountForShare = false
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), CompletedStatus))
+
+	err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.GetQueryCalledNum(batchCountSQL))
+	assert.Equal(t, 0, db.GetQueryCalledNum(batchCountSQL+" LOCK IN SHARE MODE"))
+}
+
+func TestExecBatchAndRecordCommit(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+
+	err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.GetQueryCalledNum("commit"))
+	assert.Equal(t, 0, db.GetQueryCalledNum("rollback"))
+}
+
+func TestExecBatchAndRecordMultiStatement(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		updateSQL     = "update t1 set flag = 1 where c1 < 10 and (id >= 1 and id <= 10)"
+		deleteSQL     = "delete from t1 where flag = 1 and (id >= 1 and id <= 10)"
+		batchSQL      = updateSQL + "; " + deleteSQL
+		batchCountSQL = "select count(*) as count_rows from t1 where (c1 < 10 or flag = 1) and (id >= 1 and id <= 10)"
+	)
+
+	for _, failDelete := range []bool{false, true} {
+		t.Run(fmt.Sprintf("failDelete=%v", failDelete), func(t *testing.T) {
+			db := fakesqldb.New(t)
+			defer db.Close()
+			jc := newTestJobController(t, db)
+
+			db.AddQuery("start transaction", &sqltypes.Result{})
+			db.AddQuery("rollback", &sqltypes.Result{})
+			db.AddQuery("commit", &sqltypes.Result{})
+			db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+				sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+			db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+				sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+			db.AddQuery(updateSQL, &sqltypes.Result{RowsAffected: 10})
+			if failDelete {
+				db.AddRejectedQuery(deleteSQL, errors.New("injected error"))
+			} else {
+				db.AddQuery(deleteSQL, &sqltypes.Result{RowsAffected: 4})
+			}
+			// the statements have their own WHERE, so the affected rows of the batch are the sum of theirs
+			recordSQL := fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+14 where batch_id = '%s'", batchTable, CompletedStatus, batchID)
+			db.AddQuery(recordSQL, &sqltypes.Result{RowsAffected: 1})
+
+			err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+			// both statements run in the transaction of the batch, so they apply or are rolled back together
+			assert.Equal(t, 1, db.GetQueryCalledNum(updateSQL))
+			assert.Equal(t, 1, db.GetQueryCalledNum(deleteSQL))
+			if failDelete {
+				require.ErrorContains(t, err, "injected error")
+				assert.Equal(t, 0, db.GetQueryCalledNum(recordSQL))
+				assert.Equal(t, 1, db.GetQueryCalledNum("rollback"))
+				assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, db.GetQueryCalledNum(recordSQL))
+			assert.Equal(t, 1, db.GetQueryCalledNum("commit"))
+			assert.Equal(t, 0, db.GetQueryCalledNum("rollback"))
+		})
+	}
+}
+
+func TestExecBatchAndRecordIsolationLevel(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("set session transaction isolation level READ COMMITTED", &sqltypes.Result{})
+	db.AddQuery("set session transaction_isolation = default", &sqltypes.Result{})
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+
+	for _, batchID := range []string{"1", "2"} {
+		db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+		db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+			&sqltypes.Result{RowsAffected: 1})
+
+		db.ResetQueryLog()
+		err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100, isolationLevel: "READ COMMITTED"}, batchSQL, batchCountSQL, batchID)
+		require.NoError(t, err)
+		// the isolation level is set before the transaction starts and reset after it's committed
+		assert.Contains(t, db.QueryLog(), "set session transaction isolation level read committed;start transaction;")
+		assert.Contains(t, db.QueryLog(), ";commit;set session transaction_isolation = default")
+	}
+	assert.Equal(t, 2, db.GetQueryCalledNum("set session transaction isolation level READ COMMITTED"))
+	assert.Equal(t, 2, db.GetQueryCalledNum("set session transaction_isolation = default"))
+}
+
+func TestExecBatchAndRecordStats(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+
+	stats := jc.env.Stats()
+	startTimings := stats.JobBatchTimings.Counts()["JobControllerTest.t1"]
+	startCount := stats.JobBatchAffectedRows.Count()
+	startTotal := stats.JobBatchAffectedRows.Total()
+
+	batchIDs := []string{"1", "2", "3"}
+	for _, batchID := range batchIDs {
+		db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+		db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+			&sqltypes.Result{RowsAffected: 1})
+		err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+		require.NoError(t, err)
+	}
+
+	assert.Equal(t, int64(len(batchIDs)), stats.JobBatchTimings.Counts()["JobControllerTest.t1"]-startTimings)
+	assert.Equal(t, int64(len(batchIDs)), stats.JobBatchAffectedRows.Count()-startCount)
+	assert.Equal(t, int64(10*len(batchIDs)), stats.JobBatchAffectedRows.Total()-startTotal)
+}
+
+func TestLaunchScheduledJob(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	launched := 0
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), "uuid|"+PostponeLaunchStatus))
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+status = 'queued'.*job_uuid = 'uuid' and status = 'postpone-launch'`,
+		&sqltypes.Result{RowsAffected: 1}, func(string) { launched++ })
+
+	// a postponed job without a launch time waits for a manual launch
+	jobArgs := JobArgs{uuid: "uuid", status: PostponeLaunchStatus}
+	assert.False(t, jc.launchScheduledJob(&jobArgs))
+	assert.Equal(t, PostponeLaunchStatus, jobArgs.status)
+
+	launchAt := time.Now().Add(200 * time.Millisecond)
+	jobArgs.launchAt = &launchAt
+	assert.False(t, jc.launchScheduledJob(&jobArgs))
+	assert.Equal(t, PostponeLaunchStatus, jobArgs.status)
+	assert.Equal(t, 0, launched)
+
+	time.Sleep(time.Until(launchAt) + 10*time.Millisecond)
+	assert.True(t, jc.launchScheduledJob(&jobArgs))
+	assert.Equal(t, QueuedStatus, jobArgs.status)
+	assert.Equal(t, 1, launched)
+}
+
+func TestCheckDmlJobSchemaExists(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	schemaFields := sqltypes.MakeTestFields("SCHEMA_NAME", "varchar")
+	db.AddQueryPattern(`SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA\.SCHEMATA\s+WHERE\s+SCHEMA_NAME = 'db1'`, sqltypes.MakeTestResult(schemaFields, "db1"))
+	db.AddQueryPattern(`SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA\.SCHEMATA\s+WHERE\s+SCHEMA_NAME = 'db2'`, sqltypes.MakeTestResult(schemaFields))
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), "uuid|"+QueuedStatus))
+	var messages, statuses []string
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+message = .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		messages = append(messages, query)
+	})
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+status = .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		statuses = append(statuses, query)
+	})
+
+	jc.workingTables = map[string]bool{}
+	jc.initDMLJobRunningMeta("t1")
+	jobArgs := JobArgs{uuid: "uuid", status: QueuedStatus, tableSchema: "db1", table: "t1"}
+	assert.True(t, jc.checkDmlJobSchemaExists(&jobArgs))
+	assert.Empty(t, statuses)
+
+	// the job of a dropped schema fails without launching a runner
+	jobArgs.tableSchema = "db2"
+	assert.False(t, jc.checkDmlJobSchemaExists(&jobArgs))
+	require.Len(t, messages, 1)
+	assert.Contains(t, messages[0], "schema dropped: schema db2 of the job no longer exists")
+	require.Len(t, statuses, 1)
+	assert.Regexp(t, `(?s)status = 'failed'.*job_uuid = 'uuid'`, statuses[0])
+	assert.NotContains(t, jc.workingTables, "t1")
+}
+
+func TestCloseWaitsForInFlightBatch(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+	batchStarted := make(chan struct{})
+	db.SetBeforeFunc(batchSQL, func() {
+		close(batchStarted)
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	var batchDone atomic.Bool
+	var batchErr error
+	go func() {
+		batchErr = jc.execBatchAndRecord(jc.ctx, &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+		batchDone.Store(true)
+	}()
+
+	// the tablet stops serving as primary while the batch is in flight
+	<-batchStarted
+	jc.Close()
+	require.True(t, batchDone.Load())
+	assert.Error(t, batchErr)
+	assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+
+	// no new batch can start after the job controller is closed
+	err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+	assert.ErrorContains(t, err, "job controller is closing")
+	assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+}
+
+func TestExecBatchAndRecordTimeout(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	defer func(old int) { batchTimeout = old }(batchTimeout)
+	batchTimeout = 100
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+	db.AddQueryPattern("kill .*", &sqltypes.Result{})
+	// the batch SQL is stuck, e.g. in a lock wait
+	db.SetBeforeFunc(batchSQL, func() {
+		time.Sleep(2 * time.Second)
+	})
+
+	// fakesqldb doesn't interrupt the query when it's killed, so only the error is checked
+	err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100}, batchSQL, batchCountSQL, batchID)
+	require.ErrorContains(t, err, "exceeded the timeout of 100ms")
+	assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+}
+
+func TestExecBatchAndRecordArchive(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+		archiveSQL    = "insert into t1_archive (id,c1) select id,c1 from t1 where id >= 1 and id <= 10"
+	)
+
+	testCases := []struct {
+		name         string
+		archivedRows uint64
+		wantErr      string
+	}{
+		{
+			name:         "archived rows match deleted rows",
+			archivedRows: 10,
+		},
+		{
+			name:         "archived rows don't match deleted rows",
+			archivedRows: 9,
+			wantErr:      "batch 1 archived 9 rows but deleted 10 rows",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			db := fakesqldb.New(t)
+			defer db.Close()
+			jc := newTestJobController(t, db)
+
+			db.AddQuery("start transaction", &sqltypes.Result{})
+			db.AddQuery("rollback", &sqltypes.Result{})
+			db.AddQuery("commit", &sqltypes.Result{})
+			db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+				sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+			db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+				sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+			db.AddQueryPattern(`SELECT COLUMN_NAME FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = ''\s+AND TABLE_NAME = 't1'`,
+				sqltypes.MakeTestResult(sqltypes.MakeTestFields("COLUMN_NAME", "varchar"), "id", "c1"))
+			db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+				&sqltypes.Result{RowsAffected: 1})
+
+			// the rows are archived with the same where clause, before they are deleted
+			var executed []string
+			db.AddQuery(archiveSQL, &sqltypes.Result{RowsAffected: tc.archivedRows})
+			db.SetBeforeFunc(archiveSQL, func() { executed = append(executed, archiveSQL) })
+			db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+			db.SetBeforeFunc(batchSQL, func() { executed = append(executed, batchSQL) })
+
+			err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100, archiveTable: "t1_archive"}, batchSQL, batchCountSQL, batchID)
+			assert.Equal(t, []string{archiveSQL, batchSQL}, executed)
+			if tc.wantErr != "" {
+				require.ErrorContains(t, err, tc.wantErr)
+				assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+				assert.Equal(t, 1, db.GetQueryCalledNum("rollback"))
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, 1, db.GetQueryCalledNum("commit"))
+			assert.Equal(t, 0, db.GetQueryCalledNum("rollback"))
+		})
+	}
+}
+
+func TestExecBatchAndRecordChecksum(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "update t1 set c1 = 1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+		checksumSQL   = "select cast(coalesce(sum(crc32(concat_ws('#', c1, concat(isnull(c1))))), 0) as unsigned) as checksum from t1 where id >= 1 and id <= 10"
+		updateSQL     = "update _vt_BATCH_test set checksum_before = 111, checksum_after = 222 where batch_id = '1'"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(updateSQL, &sqltypes.Result{RowsAffected: 1})
+
+	// the checksum is computed before and after the batch SQL
+	checksumFields := sqltypes.MakeTestFields("checksum", "uint64")
+	checksum := db.AddQuery(checksumSQL, &sqltypes.Result{})
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	db.SetBeforeFunc("start transaction", func() {
+		checksum.Result = sqltypes.MakeTestResult(checksumFields, "111")
+	})
+	db.SetBeforeFunc(batchSQL, func() {
+		checksum.Result = sqltypes.MakeTestResult(checksumFields, "222")
+	})
+
+	// the same batch gets the same checksums when it's executed again
+	for i := 0; i < 2; i++ {
+		err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100, checksumColumns: "c1"}, batchSQL, batchCountSQL, batchID)
+		require.NoError(t, err)
+		assert.Equal(t, 2*(i+1), db.GetQueryCalledNum(checksumSQL))
+		assert.Equal(t, i+1, db.GetQueryCalledNum(updateSQL))
+		assert.Equal(t, i+1, db.GetQueryCalledNum("commit"))
+	}
+	assert.Equal(t, 0, db.GetQueryCalledNum("rollback"))
+}
+
+func TestExecBatchAndRecordVersionColumn(t *testing.T) {
+	const (
+		batchTable     = "_vt_BATCH_test"
+		batchSQL       = "update t1 set c1 = 1 where id >= 1 and id <= 10"
+		batchCountSQL  = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+		versionedSQL   = "update t1 set c1 = 1 where id >= 1 and id <= 10 and (updated_at is null or updated_at <= '2023-09-01 10:00:00')"
+		skippedRowsSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10 and not (updated_at is null or updated_at <= '2023-09-01 10:00:00')"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	countFields := sqltypes.MakeTestFields("count_rows", "int64")
+	for _, batchID := range []string{"1", "2"} {
+		db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+		db.AddQueryPattern(fmt.Sprintf(`update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows\+\d+ where batch_id = '%s'`, batchTable, CompletedStatus, batchID),
+			&sqltypes.Result{RowsAffected: 1})
+		db.AddQueryPattern(fmt.Sprintf(`update %s set skipped_rows = \d+ where batch_id = '%s'`, batchTable, batchID), &sqltypes.Result{RowsAffected: 1})
+	}
+
+	// no row of the first batch is modified since the job was submitted
+	skippedRows := db.AddQuery(skippedRowsSQL, sqltypes.MakeTestResult(countFields, "0"))
+	updated := db.AddQuery(versionedSQL, &sqltypes.Result{RowsAffected: 10})
+	err := jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100, versionColumn: "updated_at", versionSnapshot: "2023-09-01 10:00:00"}, batchSQL, batchCountSQL, "1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, db.GetQueryCalledNum(batchSQL))
+	assert.Equal(t, 1, db.GetQueryCalledNum(fmt.Sprintf("update %s set skipped_rows = 0 where batch_id = '1'", batchTable)))
+
+	// a row of the second batch is modified mid-job, it's skipped and counted
+	skippedRows.Result = sqltypes.MakeTestResult(countFields, "1")
+	updated.Result = &sqltypes.Result{RowsAffected: 9}
+	err = jc.execBatchAndRecord(context.Background(), &JobArgs{uuid: "uuid", table: "t1", batchInfoTable: batchTable, batchSize: 100, versionColumn: "updated_at", versionSnapshot: "2023-09-01 10:00:00"}, batchSQL, batchCountSQL, "2")
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.GetQueryCalledNum(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+9 where batch_id = '2'", batchTable, CompletedStatus)))
+	assert.Equal(t, 1, db.GetQueryCalledNum(fmt.Sprintf("update %s set skipped_rows = 1 where batch_id = '2'", batchTable)))
+	assert.Equal(t, 2, db.GetQueryCalledNum("commit"))
+	assert.Equal(t, 0, db.GetQueryCalledNum("rollback"))
+}
+
+func TestCheckConfirmation(t *testing.T) {
+	const (
+		sql      = "delete from t1 where id > 10"
+		countSQL = "select count(*) as count_rows from t1 where id > 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	defer func(threshold int) { confirmRowsThreshold = threshold }(confirmRowsThreshold)
+	confirmRowsThreshold = 100
+	countFields := sqltypes.MakeTestFields("count_rows", "int64")
+
+	t.Run("below threshold", func(t *testing.T) {
+		db.AddQuery(countSQL, sqltypes.MakeTestResult(countFields, "100"))
+		qr, err := jc.checkConfirmation(sql, "", "")
+		require.NoError(t, err)
+		assert.Nil(t, qr)
+		assert.Empty(t, jc.pendingConfirmations)
+	})
+
+	t.Run("above threshold", func(t *testing.T) {
+		db.AddQuery(countSQL, sqltypes.MakeTestResult(countFields, "101"))
+		qr, err := jc.checkConfirmation(sql, "", "")
+		require.NoError(t, err)
+		require.NotNil(t, qr)
+		row := qr.Named().Row()
+		token := row.AsString("confirm_token", "")
+		require.NotEmpty(t, token)
+		assert.Equal(t, "101", row.AsString("estimated_rows", ""))
+
+		// the token only confirms the same job
+		db.AddQuery("select count(*) as count_rows from t1 where id > 10 and id < 1000", sqltypes.MakeTestResult(countFields, "989"))
+		_, err = jc.checkConfirmation("delete from t1 where id > 10 and id < 1000", "", token)
+		assert.ErrorContains(t, err, "invalid or has expired")
+
+		qr, err = jc.checkConfirmation(sql, "", token)
+		require.NoError(t, err)
+		assert.Nil(t, qr)
+
+		// the token can only be used once
+		_, err = jc.checkConfirmation(sql, "", token)
+		assert.ErrorContains(t, err, "invalid or has expired")
+	})
+
+	t.Run("expired token", func(t *testing.T) {
+		db.AddQuery(countSQL, sqltypes.MakeTestResult(countFields, "101"))
+		qr, err := jc.checkConfirmation(sql, "", "")
+		require.NoError(t, err)
+		token := qr.Named().Row().AsString("confirm_token", "")
+		jc.pendingConfirmations[token].expireAt = time.Now().Add(-time.Second)
+
+		_, err = jc.checkConfirmation(sql, "", token)
+		assert.ErrorContains(t, err, "invalid or has expired")
+		assert.Empty(t, jc.pendingConfirmations)
+	})
+
+	t.Run("estimate without holding the table mutex", func(t *testing.T) {
+		db.AddQuery(countSQL, sqltypes.MakeTestResult(countFields, "101"))
+		db.AddQueryPattern(`SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = ''\s+AND TABLE_NAME = 't1'\s+AND EXTRA IN .*`,
+			sqltypes.MakeTestResult(sqltypes.MakeTestFields("COLUMN_NAME|EXTRA", "varchar|varchar")))
+		tableMutexFree := false
+		db.SetBeforeFunc(countSQL, func() {
+			if jc.tableMutex.TryLock() {
+				tableMutexFree = true
+				jc.tableMutex.Unlock()
+			}
+		})
+		qr, err := jc.SubmitJob(sql, "", "", "", "", 0, 0, false, "", "", "")
+		require.NoError(t, err)
+		assert.NotEmpty(t, qr.Named().Row().AsString("confirm_token", ""))
+		assert.True(t, tableMutexFree)
+	})
+}
+
+func TestPurgeJob(t *testing.T) {
+	const (
+		uuid       = "bd8fa4bb_0e73_11ef_b0c6_0a8bd3e0cd4a"
+		batchTable = "_vt_BATCH_bd8fa4bb0e7311efb0c60a8bd3e0cd4a"
+	)
+	getInfoPattern := fmt.Sprintf(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`, uuid)
+	deleteJobSQL := fmt.Sprintf("delete from mysql.non_transactional_dml_jobs where job_uuid = '%s'", uuid)
+	dropTableSQL := fmt.Sprintf(sqlTemplateDropBatchTable, batchTable)
+	jobInfoFields := sqltypes.MakeTestFields("job_uuid|status|batch_info_table_schema|batch_info_table_name", "varchar|varchar|varchar|varchar")
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	t.Run("running job", func(t *testing.T) {
+		db.AddQueryPattern(getInfoPattern, sqltypes.MakeTestResult(jobInfoFields, fmt.Sprintf("%s|%s||%s", uuid, RunningStatus, batchTable)))
+		_, err := jc.PurgeJob(uuid)
+		assert.ErrorContains(t, err, "can't be purged")
+		assert.Equal(t, 0, db.GetQueryCalledNum(dropTableSQL))
+		assert.Equal(t, 0, db.GetQueryCalledNum(deleteJobSQL))
+	})
+
+	t.Run("completed job", func(t *testing.T) {
+		db.AddQueryPattern(getInfoPattern, sqltypes.MakeTestResult(jobInfoFields, fmt.Sprintf("%s|%s||%s", uuid, CompletedStatus, batchTable)))
+		db.AddQuery(`SHOW TABLES LIKE '\_vt\_BATCH\_bd8fa4bb0e7311efb0c60a8bd3e0cd4a'`, sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("Tables_in_test", "varchar"), batchTable))
+		db.AddQuery(dropTableSQL, &sqltypes.Result{})
+		db.AddQuery(deleteJobSQL, &sqltypes.Result{RowsAffected: 1})
+
+		qr, err := jc.PurgeJob(uuid)
+		require.NoError(t, err)
+		assert.EqualValues(t, 1, qr.RowsAffected)
+		assert.Equal(t, 1, db.GetQueryCalledNum(dropTableSQL))
+		assert.Equal(t, 1, db.GetQueryCalledNum(deleteJobSQL))
+	})
+}
+
+func TestCloneJob(t *testing.T) {
+	const (
+		uuid        = "bd8fa4bb_0e73_11ef_b0c6_0a8bd3e0cd4a"
+		tableSchema = "test"
+		dmlSQL      = "delete from t1 where id > 10"
+	)
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQueryPattern(fmt.Sprintf(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`, uuid), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|dml_sql|table_schema|status|batch_interval_in_ms|batch_size|fail_policy|running_time_period_start|running_time_period_end|running_time_period_time_zone|archive_table|isolation_level|checksum_columns|max_replication_lag|notify_url|batch_order|tag",
+			"varchar|varchar|varchar|varchar|int64|int64|varchar|varchar|varchar|varchar|varchar|varchar|varchar|float64|varchar|varchar|varchar"),
+		fmt.Sprintf("%s|%s|%s|%s|500|50|skip|01:00:00|05:00:00|UTC+08:00:00|null|READ COMMITTED|null|0.5|http://localhost:8080/jobs?from=wescale|desc|ticket-1024", uuid, dmlSQL, tableSchema, CompletedStatus)))
+	db.AddQuery("use test", &sqltypes.Result{})
+	db.AddQuery("use fakesqldb", &sqltypes.Result{})
+	db.AddQueryPattern(`SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'test'\s+AND TABLE_NAME = 't1'\s+AND EXTRA IN .*`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("COLUMN_NAME|EXTRA", "varchar|varchar")))
+	db.AddQuery(fmt.Sprintf(sqlGetIndexCount, "t1"), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Key_name|Column_name", "varchar|varchar"), "PRIMARY|id"))
+	var submitQuery string
+	db.AddQueryPatternWithCallback(`insert into mysql\.non_transactional_dml_jobs .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		submitQuery = query
+	})
+
+	qr, err := jc.HandleRequest(CloneJob, JobRequest{JobUUID: uuid})
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 1)
+	row := qr.Named().Row()
+	newUUID := row.AsString("job_uuid", "")
+	assert.NotEmpty(t, newUUID)
+	assert.NotEqual(t, uuid, newUUID)
+	assert.Equal(t, "500", row.AsString("batch_interval_in_ms", ""))
+	assert.Equal(t, "50", row.AsString("batch_size", ""))
+	assert.Equal(t, "skip", row.AsString("fail_policy", ""))
+
+	assert.Contains(t, submitQuery, fmt.Sprintf("'%s'", newUUID))
+	assert.Contains(t, submitQuery, fmt.Sprintf("'%s'", dmlSQL))
+	assert.Contains(t, submitQuery, fmt.Sprintf("'%s'", tableSchema))
+	assert.Contains(t, submitQuery, "'01:00:00','05:00:00','UTC+08:00:00'")
+	assert.Contains(t, submitQuery, "'READ COMMITTED'")
+	assert.Contains(t, submitQuery, ",0.5,'http://localhost:8080/jobs?from=wescale','desc',null,null,'ticket-1024')")
+}
+
+func TestShowJobsWithTag(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("use test", &sqltypes.Result{})
+	db.AddQuery("use fakesqldb", &sqltypes.Result{})
+	db.AddQueryPattern(`SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'test'\s+AND TABLE_NAME = 't1'\s+AND EXTRA IN .*`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("COLUMN_NAME|EXTRA", "varchar|varchar")))
+	db.AddQuery(fmt.Sprintf(sqlGetIndexCount, "t1"), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Key_name|Column_name", "varchar|varchar"), "PRIMARY|id"))
+	var submitQueries []string
+	db.AddQueryPatternWithCallback(`insert into mysql\.non_transactional_dml_jobs .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		submitQueries = append(submitQueries, query)
+	})
+
+	var uuids []string
+	for _, sql := range []string{
+		"delete /*vt+ dml_tag=team-a */ from t1 where id > 10",
+		"delete /*vt+ dml_tag='team-b' */ from t1 where id > 20",
+		"delete /*vt+ dml_tag=team-a */ from t1 where id > 30",
+		"delete from t1 where id > 40",
+	} {
+		qr, err := jc.SubmitJob(sql, "test", "", "", "", 0, 0, false, "", "", "")
+		require.NoError(t, err)
+		uuids = append(uuids, qr.Named().Row().AsString("job_uuid", ""))
+	}
+	require.Len(t, submitQueries, 4)
+	assert.True(t, strings.HasSuffix(submitQueries[0], ",'team-a')"), submitQueries[0])
+	assert.True(t, strings.HasSuffix(submitQueries[1], ",'team-b')"), submitQueries[1])
+	assert.True(t, strings.HasSuffix(submitQueries[2], ",'team-a')"), submitQueries[2])
+	assert.True(t, strings.HasSuffix(submitQueries[3], ",null)"), submitQueries[3])
+
+	_, err := jc.SubmitJob("delete /*vt+ dml_tag='team a' */ from t1 where id > 10", "test", "", "", "", 0, 0, false, "", "", "")
+	assert.ErrorContains(t, err, "invalid tag")
+
+	jobFields := sqltypes.MakeTestFields("id|job_uuid|batch_info_table_schema|status|tag", "int64|varchar|varchar|varchar|varchar")
+	jobRows := []string{
+		fmt.Sprintf("1|%s|test|queued|team-a", uuids[0]),
+		fmt.Sprintf("2|%s|test|queued|team-b", uuids[1]),
+		fmt.Sprintf("3|%s|test|queued|team-a", uuids[2]),
+		fmt.Sprintf("4|%s|test|queued|null", uuids[3]),
+	}
+	for _, row := range jobRows {
+		uuid := strings.Split(row, "|")[1]
+		db.AddQueryPattern(fmt.Sprintf(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`, uuid),
+			sqltypes.MakeTestResult(jobFields, row))
+	}
+	// the job table is filtered by MySQL, so only the matching rows are returned.
+	db.AddQuery("select * from mysql.non_transactional_dml_jobs where tag like 'team-a' order by id",
+		sqltypes.MakeTestResult(jobFields, jobRows[0], jobRows[2]))
+	db.AddQuery("select * from mysql.non_transactional_dml_jobs where tag like 'team-b' order by id",
+		sqltypes.MakeTestResult(jobFields, jobRows[1]))
+	db.AddQuery("select * from mysql.non_transactional_dml_jobs where tag like 'team-%' order by id",
+		sqltypes.MakeTestResult(jobFields, jobRows[0], jobRows[1], jobRows[2]))
+	db.AddQuery("select * from mysql.non_transactional_dml_jobs where tag like 'team-c' order by id",
+		sqltypes.MakeTestResult(jobFields))
+
+	showUUIDs := func(tagPattern string) []string {
+		qr, err := jc.HandleRequest(ShowJobsWithTag, JobRequest{TagPattern: tagPattern})
+		require.NoError(t, err)
+		var got []string
+		for _, row := range qr.Named().Rows {
+			assert.NotEmpty(t, row.AsString("tag", ""))
+			got = append(got, row.AsString("job_uuid", ""))
+		}
+		return got
+	}
+	assert.Equal(t, []string{uuids[0], uuids[2]}, showUUIDs("team-a"))
+	assert.Equal(t, []string{uuids[1]}, showUUIDs("'team-b'"))
+	assert.Equal(t, uuids[:3], showUUIDs("team-%"))
+	assert.Empty(t, showUUIDs("team-c"))
+}
+
+func TestSubmitJobBatchTableSchema(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	defer func(schema string) { batchTableSchema = schema }(batchTableSchema)
+	batchTableSchema = "_vt_jobs"
+
+	db.AddQuery("use test", &sqltypes.Result{})
+	db.AddQuery("use fakesqldb", &sqltypes.Result{})
+	db.AddQuery("create database if not exists `_vt_jobs`", &sqltypes.Result{})
+	db.AddQueryPattern(`SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'test'\s+AND TABLE_NAME = 't1'\s+AND EXTRA IN .*`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("COLUMN_NAME|EXTRA", "varchar|varchar")))
+	db.AddQuery(fmt.Sprintf(sqlGetIndexCount, "t1"), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Key_name|Column_name", "varchar|varchar"), "PRIMARY|id"))
+	var submitQuery string
+	db.AddQueryPatternWithCallback(`insert into mysql\.non_transactional_dml_jobs .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		submitQuery = query
+	})
+
+	qr, err := jc.SubmitJob("delete from t1 where id > 10", "test", "", "", "", 0, 0, false, "", "", "")
+	require.NoError(t, err)
+	batchTable := qr.Named().Row().AsString("batch_info_table_name", "")
+	assert.Contains(t, db.QueryLog(), "create database if not exists `_vt_jobs`")
+	// the batch table is created in the configured schema instead of the schema of the job table
+	assert.Contains(t, submitQuery, fmt.Sprintf("'test','t1','_vt_jobs','%s'", batchTable))
+
+	// the runner reads the batches from the configured schema, while its queries run in the schema of the job table
+	args := JobArgs{tableSchema: "test", batchInfoTableSchema: "_vt_jobs", batchInfoTable: batchTable}
+	qualifiedBatchTable := args.qualifiedBatchInfoTable()
+	assert.Equal(t, fmt.Sprintf("`_vt_jobs`.`%s`", batchTable), qualifiedBatchTable)
+	db.AddQuery(fmt.Sprintf(sqlTemplateGetBatchIDToExec, qualifiedBatchTable), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_id", "varchar"), "1"))
+	batchID, err := getBatchIDToExec(context.Background(), jc, args.tableSchema, qualifiedBatchTable)
+	require.NoError(t, err)
+	assert.Equal(t, "1", batchID)
+
+	// without a dedicated schema, the batch table lives in the schema of the job table
+	args.batchInfoTableSchema = "test"
+	assert.Equal(t, batchTable, args.qualifiedBatchInfoTable())
+}
+
+func TestSubmitJobValidatesBatchSQL(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("use test", &sqltypes.Result{})
+	db.AddQuery("use fakesqldb", &sqltypes.Result{})
+	db.AddQueryPattern(`SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'test'\s+AND TABLE_NAME = 't1'\s+AND EXTRA IN .*`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("COLUMN_NAME|EXTRA", "varchar|varchar")))
+	submitted := false
+	db.AddQueryPatternWithCallback(`insert into mysql\.non_transactional_dml_jobs .*`, &sqltypes.Result{RowsAffected: 1}, func(string) {
+		submitted = true
+	})
+	indexFields := sqltypes.MakeTestFields("Key_name|Column_name", "varchar|varchar")
+
+	// the PK column names are written as they are into the PK condition of the batches,
+	// so a contrived PK column name makes the batch SQL bounded by another column.
+	db.AddQuery(fmt.Sprintf(sqlGetIndexCount, "t1"), sqltypes.MakeTestResult(indexFields, "PRIMARY|id) or (c1"))
+	_, err := jc.SubmitJob("delete from t1 where id > 10", "test", "", "", "", 0, 0, false, "", "", "")
+	assert.ErrorContains(t, err, "doesn't reference the PK column id) or (c1")
+	assert.False(t, submitted)
+
+	// or makes it fail to parse
+	db.AddQuery(fmt.Sprintf(sqlGetIndexCount, "t1"), sqltypes.MakeTestResult(indexFields, "PRIMARY|id >"))
+	_, err = jc.SubmitJob("delete from t1 where id > 10", "test", "", "", "", 0, 0, false, "", "", "")
+	assert.ErrorContains(t, err, "failed to generate the batch SQL of the job")
+	assert.False(t, submitted)
+
+	db.AddQuery(fmt.Sprintf(sqlGetIndexCount, "t1"), sqltypes.MakeTestResult(indexFields, "idx_c1|c1"))
+	_, err = jc.SubmitJob("delete from t1 where id > 10", "test", "", "", "", 0, 0, false, "", "", "")
+	assert.ErrorContains(t, err, "the table t1 has no primary key")
+	assert.False(t, submitted)
+
+	db.AddQuery(fmt.Sprintf(sqlGetIndexCount, "t1"), sqltypes.MakeTestResult(indexFields, "PRIMARY|id", "PRIMARY|c1", "idx_c2|c2"))
+	_, err = jc.SubmitJob("update t1 set c2 = 1 where id > 10;delete from t1 where c2 = 1", "test", "", "", "", 0, 0, false, "", "", "")
+	require.NoError(t, err)
+	assert.True(t, submitted)
+}
+
+func TestDescribeJob(t *testing.T) {
+	const (
+		uuid        = "bd8fa4bb_0e73_11ef_b0c6_0a8bd3e0cd4a"
+		tableSchema = "test"
+		dmlSQL      = "update t1 set c1 = 1 where c2 > 10 and c3 < now()"
+	)
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQueryPattern(fmt.Sprintf(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`, uuid), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|dml_sql|table_schema|batch_order", "varchar|varchar|varchar|varchar"),
+		fmt.Sprintf("%s|%s|%s|null", uuid, dmlSQL, tableSchema)))
+	db.AddQuery("use test", &sqltypes.Result{})
+	db.AddQuery("use fakesqldb", &sqltypes.Result{})
+	db.AddQuery(fmt.Sprintf(sqlGetTablePk, "t1"), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Column_name", "varchar"), "id1", "id2"))
+	db.AddQuery(fmt.Sprintf(sqlTemplateSelectPKCols, "id1,id2", tableSchema, "t1"), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("id1|id2", "int64|varchar"), "1|a"))
+
+	qr, err := jc.HandleRequest(DescribeJob, JobRequest{JobUUID: uuid})
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 1)
+	row := qr.Named().Row()
+	assert.Equal(t, dmlSQL, row.AsString("dml_sql", ""))
+	assert.Equal(t, "t1", row.AsString("table_name", ""))
+	assert.Equal(t, "select id1,id2 from t1 where c2 > 10 and c3 < now() order by id1,id2", row.AsString("select_sql", ""))
+	assert.Equal(t, "select count(*) as count_rows from t1 where c2 > 10 and c3 < now() and :pk_range", row.AsString("count_sql_template", ""))
+	assert.Equal(t, "c2 > 10 and c3 < now()", row.AsString("where_part", ""))
+	assert.Equal(t, "id1,id2", row.AsString("pk_part", ""))
+}
+
+func TestBlockJobIfTableBusy(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	jc.workingTables = map[string]bool{}
+
+	for _, uuid := range []string{"uuid1", "uuid2", "uuid3"} {
+		db.AddQueryPattern(fmt.Sprintf(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`, uuid), sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), uuid+"|"+SubmittedStatus))
+	}
+	var blocked []string
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+status = 'blocked'.*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		blocked = append(blocked, query)
+	})
+
+	job1 := &JobArgs{uuid: "uuid1", status: SubmittedStatus, table: "t1"}
+	job2 := &JobArgs{uuid: "uuid2", status: SubmittedStatus, table: "t1"}
+	job3 := &JobArgs{uuid: "uuid3", status: SubmittedStatus, table: "t2"}
+
+	// the first job on t1 is prepared, as done by the job manager
+	require.False(t, jc.blockJobIfTableBusy(job1))
+	jc.initDMLJobRunningMeta(job1.table)
+
+	// the second one waits for it, while the job on another table isn't affected
+	require.True(t, jc.blockJobIfTableBusy(job2))
+	assert.Equal(t, BlockedStatus, job2.status)
+	require.Len(t, blocked, 1)
+	assert.Contains(t, blocked[0], "job_uuid = 'uuid2'")
+	require.False(t, jc.blockJobIfTableBusy(job3))
+	assert.Equal(t, SubmittedStatus, job3.status)
+
+	// a blocked job isn't set to blocked again in the next rounds
+	require.True(t, jc.blockJobIfTableBusy(job2))
+	assert.Len(t, blocked, 1)
+
+	// it can be prepared once the first job is done
+	jc.deleteDMLJobRunningMeta(job1.table)
+	require.False(t, jc.blockJobIfTableBusy(job2))
+	assert.Equal(t, BlockedStatus, job2.status)
+	assert.Len(t, blocked, 1)
+}
+
+func TestShowBatch(t *testing.T) {
+	const (
+		uuid       = "bd8fa4bb_0e73_11ef_b0c6_0a8bd3e0cd4a"
+		batchTable = "_vt_BATCH_bd8fa4bb0e7311efb0c60a8bd3e0cd4a"
+		dmlSQL     = "delete from t1 where c2 > 10"
+	)
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	stmt, err := sqlparser.Parse(dmlSQL)
+	require.NoError(t, err)
+	whereExpr := stmt.(*sqlparser.Delete).Where.Expr
+	batchSQL, finalWhereStr, err := genBatchSQL(stmt, whereExpr, []sqltypes.Value{sqltypes.NewInt64(1)}, []sqltypes.Value{sqltypes.NewInt64(50)}, []PKInfo{{pkName: "id"}})
+	require.NoError(t, err)
+	batchCountSQL := genCountSQL("t1", finalWhereStr)
+
+	db.AddQueryPattern(fmt.Sprintf(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`, uuid), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|batch_info_table_schema|batch_info_table_name", "varchar|varchar|varchar"),
+		fmt.Sprintf("%s||%s", uuid, batchTable)))
+	db.AddQuery(`SHOW TABLES LIKE '\_vt\_BATCH\_bd8fa4bb0e7311efb0c60a8bd3e0cd4a'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Tables_in_test", "varchar"), batchTable))
+	batchFields := sqltypes.MakeTestFields("batch_id|batch_sql|batch_count_sql|batch_status", "varchar|text|text|varchar")
+	db.AddQuery(fmt.Sprintf("select batch_id, batch_sql, batch_count_sql_when_creating_batch as batch_count_sql, batch_status from %s where batch_id = '1'", batchTable), &sqltypes.Result{
+		Fields: batchFields,
+		Rows: [][]sqltypes.Value{{
+			sqltypes.NewVarChar("1"), sqltypes.NewVarChar(batchSQL), sqltypes.NewVarChar(batchCountSQL), sqltypes.NewVarChar(CompletedStatus),
+		}},
+	})
+	db.AddQuery(fmt.Sprintf("select batch_id, batch_sql, batch_count_sql_when_creating_batch as batch_count_sql, batch_status from %s where batch_id = '2'", batchTable), &sqltypes.Result{
+		Fields: batchFields,
+	})
+
+	t.Run("existing batch", func(t *testing.T) {
+		qr, err := jc.HandleRequest(ShowBatch, JobRequest{JobUUID: uuid, BatchID: "1"})
+		require.NoError(t, err)
+		require.Len(t, qr.Rows, 1)
+		row := qr.Named().Row()
+		assert.Equal(t, "delete from t1 where c2 > 10 and (id >= 1 and id <= 50)", row.AsString("batch_sql", ""))
+		assert.Equal(t, batchSQL, row.AsString("batch_sql", ""))
+		assert.Equal(t, batchCountSQL, row.AsString("batch_count_sql", ""))
+		assert.Equal(t, CompletedStatus, row.AsString("batch_status", ""))
+	})
+
+	t.Run("missing batch", func(t *testing.T) {
+		_, err := jc.ShowBatch(uuid, "2")
+		assert.ErrorContains(t, err, "batch 2 of job bd8fa4bb_0e73_11ef_b0c6_0a8bd3e0cd4a doesn't exist")
+	})
+}
+
+func TestDMLJobBatchRunnerRunsAddedBatch(t *testing.T) {
+	const (
+		uuid       = "uuid"
+		batchTable = "_vt_BATCH_test"
+	)
+	batches := []struct {
+		id       string
+		sql      string
+		countSQL string
+	}{
+		{id: "1", sql: "delete from t1 where id >= 1 and id <= 10", countSQL: "select count(*) as count_rows from t1 where id >= 1 and id <= 10"},
+		{id: "1-2", sql: "delete from t1 where id >= 6 and id <= 10", countSQL: "select count(*) as count_rows from t1 where id >= 6 and id <= 10"},
+	}
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the job is never throttled
+	jc.lastSuccessfulThrottle = math.MaxInt64
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status|start_time|batch_info_table_schema", "varchar|varchar|varchar|varchar"), "uuid|running||"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+(start_time|complete_time|message) = .*`, &sqltypes.Result{RowsAffected: 1})
+
+	// events is only accessed by the fakesqldb callbacks, which are serialized, and after the runner returns
+	var events []string
+	statusRegexp := regexp.MustCompile(`status = '([a-z-]+)'`)
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+status = .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		events = append(events, "status "+statusRegexp.FindStringSubmatch(query)[1])
+	})
+
+	batchIDFields := sqltypes.MakeTestFields("batch_id", "varchar")
+	batchIDToExec := db.AddQuery(fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTable), sqltypes.MakeTestResult(batchIDFields, "1"))
+	for i, batch := range batches {
+		db.AddQuery(fmt.Sprintf("select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = '%s'", batchTable, batch.id), sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("batch_sql|batch_count_sql_when_creating_batch", "text|text"), batch.sql+"|"+batch.countSQL))
+		db.AddQuery(batch.countSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("count_rows", "int64"), "5"))
+		db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batch.id), sqltypes.MakeTestResult(
+			sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+		db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+5 where batch_id = '%s'", batchTable, CompletedStatus, batch.id),
+			&sqltypes.Result{RowsAffected: 1})
+		db.AddQuery(batch.sql, &sqltypes.Result{RowsAffected: 5})
+
+		// batch 1 is split while it runs: batch 1-2 is added to the batch table and becomes the next
+		// queued batch. Once it has run there is no queued batch left.
+		var next *sqltypes.Result
+		if i+1 < len(batches) {
+			next = sqltypes.MakeTestResult(batchIDFields, batches[i+1].id)
+		} else {
+			next = sqltypes.MakeTestResult(batchIDFields)
+		}
+		batchID := batch.id
+		db.SetBeforeFunc(batch.sql, func() {
+			events = append(events, "batch "+batchID)
+			batchIDToExec.Result.Rows = next.Rows
+		})
+	}
+
+	done := make(chan struct{})
+	go func() {
+		jc.dmlJobBatchRunner(&JobArgs{uuid: uuid, table: "t1", batchInfoTable: batchTable, failPolicy: failPolicyAbort, batchInterval: 1, batchSize: 100})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the batch runner didn't complete the job")
+	}
+
+	assert.Equal(t, []string{"status running", "batch 1", "batch 1-2", "status completed"}, events)
+	assert.Equal(t, 1, db.GetQueryCalledNum(batches[1].sql))
+	// the connection of the runner is given back once the job is completed
+	assert.EqualValues(t, 0, jc.pool.InUse())
+}
+
+func TestRunnerConnReusesConn(t *testing.T) {
+	const (
+		uuid       = "uuid"
+		batchTable = "_vt_BATCH_test"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("use db1", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), "uuid|running"))
+	db.AddQuery(fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTable), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_id", "varchar"), "1"))
+	db.AddQuery(fmt.Sprintf("select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = '%s'", batchTable, "1"), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_sql|batch_count_sql_when_creating_batch", "text|text"), "delete from t1 where id = 1|select count(*) as count_rows from t1 where id = 1"))
+
+	rc := jc.newRunnerConn("db1")
+	getCount := jc.pool.GetCount()
+	// the bookkeeping queries of a batch borrow a single connection instead of one each, and switch it to the schema once
+	for i := 0; i < 3; i++ {
+		status, err := jc.getStrJobInfoWith(context.Background(), rc, uuid, "status")
+		require.NoError(t, err)
+		assert.Equal(t, RunningStatus, status)
+		batchID, err := getBatchIDToExec(context.Background(), rc, "db1", batchTable)
+		require.NoError(t, err)
+		assert.Equal(t, "1", batchID)
+		_, _, err = getBatchSQLsByID(context.Background(), rc, batchID, batchTable, "db1")
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 1, jc.pool.GetCount()-getCount)
+	assert.Equal(t, 1, db.GetQueryCalledNum("use db1"))
+	assert.EqualValues(t, 1, jc.pool.InUse())
+
+	rc.release()
+	assert.EqualValues(t, 0, jc.pool.InUse())
+}
+
+func TestPauseAllJobs(t *testing.T) {
+	const (
+		uuid          = "uuid"
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the job is never throttled
+	jc.lastSuccessfulThrottle = math.MaxInt64
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status|start_time|batch_info_table_schema", "varchar|varchar|varchar|varchar"), "uuid|running||"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+.*`, &sqltypes.Result{RowsAffected: 1})
+	db.AddQueryPattern(`insert into mysql\.non_transactional_dml_job_settings .*`, &sqltypes.Result{RowsAffected: 1})
+
+	batchIDFields := sqltypes.MakeTestFields("batch_id", "varchar")
+	batchIDToExec := db.AddQuery(fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTable), sqltypes.MakeTestResult(batchIDFields, batchID))
+	db.AddQuery(fmt.Sprintf("select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = '%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_sql|batch_count_sql_when_creating_batch", "text|text"), batchSQL+"|"+batchCountSQL))
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	// once the batch has run there is no queued batch left
+	db.SetBeforeFunc(batchSQL, func() {
+		batchIDToExec.Result.Rows = nil
+	})
+
+	qr, err := jc.HandleRequest(PauseAllJobs, JobRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, qr.RowsAffected)
+	assert.Contains(t, db.QueryLog(), "insert into mysql.non_transactional_dml_job_settings (name, value) values ('pause_all', '1')")
+
+	done := make(chan struct{})
+	go func() {
+		jc.dmlJobBatchRunner(&JobArgs{uuid: uuid, table: "t1", batchInfoTable: batchTable, failPolicy: failPolicyAbort, batchInterval: 1, batchSize: 100})
+		close(done)
+	}()
+
+	// no batch is executed while all the jobs are paused
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 0, db.GetQueryCalledNum(batchSQL))
+	select {
+	case <-done:
+		t.Fatal("the batch runner returned while all the jobs are paused")
+	default:
+	}
+
+	qr, err = jc.HandleRequest(ResumeAllJobs, JobRequest{})
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, qr.RowsAffected)
+	assert.Contains(t, db.QueryLog(), "insert into mysql.non_transactional_dml_job_settings (name, value) values ('pause_all', '0')")
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the batch runner didn't complete the job after all the jobs are resumed")
+	}
+	assert.Equal(t, 1, db.GetQueryCalledNum(batchSQL))
+
+	// resuming again is a no-op
+	qr, err = jc.ResumeAllJobs()
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, qr.RowsAffected)
+}
+
+func TestDMLJobBatchRunnerMaxReplicationLag(t *testing.T) {
+	const (
+		uuid          = "uuid"
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+		// the threshold of the throttler, in seconds
+		throttlerThreshold = 5.0
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the replication lag is below the threshold of the throttler, but above the one of the job
+	var lagInMs atomic.Int64
+	lagInMs.Store(2000)
+	jc.checkThrottler = func(ctx context.Context, appName string, flags *throttle.CheckFlags) *throttle.CheckResult {
+		threshold := throttlerThreshold
+		if flags.OverrideThreshold > 0 {
+			threshold = flags.OverrideThreshold
+		}
+		lag := float64(lagInMs.Load()) / 1000
+		if lag > threshold {
+			return throttle.NewCheckResult(http.StatusTooManyRequests, lag, threshold, nil)
+		}
+		return throttle.NewCheckResult(http.StatusOK, lag, threshold, nil)
+	}
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status|start_time|batch_info_table_schema", "varchar|varchar|varchar|varchar"), "uuid|running||"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+.*`, &sqltypes.Result{RowsAffected: 1})
+
+	batchIDFields := sqltypes.MakeTestFields("batch_id", "varchar")
+	batchIDToExec := db.AddQuery(fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTable), sqltypes.MakeTestResult(batchIDFields, batchID))
+	db.AddQuery(fmt.Sprintf("select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = '%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_sql|batch_count_sql_when_creating_batch", "text|text"), batchSQL+"|"+batchCountSQL))
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	// once the batch has run there is no queued batch left
+	db.SetBeforeFunc(batchSQL, func() {
+		batchIDToExec.Result.Rows = nil
+	})
+
+	done := make(chan struct{})
+	go func() {
+		jc.dmlJobBatchRunner(&JobArgs{uuid: uuid, table: "t1", batchInfoTable: batchTable, failPolicy: failPolicyAbort, batchInterval: 1, batchSize: 100, maxReplicationLag: 1})
+		close(done)
+	}()
+
+	// no batch is executed while the lag exceeds the threshold of the job
+	time.Sleep(200 * time.Millisecond)
+	assert.Equal(t, 0, db.GetQueryCalledNum(batchSQL))
+	select {
+	case <-done:
+		t.Fatal("the batch runner returned while the replication lag exceeds the threshold of the job")
+	default:
+	}
+	// the throttler doesn't throttle the job by itself
+	assert.True(t, jc.requestThrottle(uuid))
+
+	lagInMs.Store(500)
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the batch runner didn't complete the job once the replication lag is below the threshold of the job")
+	}
+	assert.Equal(t, 1, db.GetQueryCalledNum(batchSQL))
+}
+
+func TestDMLJobBatchRunnerThrottlerNotReady(t *testing.T) {
+	const (
+		uuid          = "uuid"
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the throttler is being initialized, its checks would throttle the job if it asked them
+	jc.checkThrottlerReady = func() error { return throttle.ErrThrottlerNotReady }
+	var throttlerChecks atomic.Int64
+	jc.checkThrottler = func(ctx context.Context, appName string, flags *throttle.CheckFlags) *throttle.CheckResult {
+		throttlerChecks.Add(1)
+		return throttle.NewCheckResult(http.StatusTooManyRequests, 0, 0, nil)
+	}
+	notReadyBefore := jc.env.Stats().JobThrottlerNotReady.Get()
+	// the throttler ticker isn't running in tests, a tick makes the job check the throttler again
+	atomic.AddInt64(&throttleTicks, 1)
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status|start_time|batch_info_table_schema", "varchar|varchar|varchar|varchar"), "uuid|running||"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+.*`, &sqltypes.Result{RowsAffected: 1})
+
+	batchIDFields := sqltypes.MakeTestFields("batch_id", "varchar")
+	batchIDToExec := db.AddQuery(fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTable), sqltypes.MakeTestResult(batchIDFields, batchID))
+	db.AddQuery(fmt.Sprintf("select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = '%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_sql|batch_count_sql_when_creating_batch", "text|text"), batchSQL+"|"+batchCountSQL))
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	// once the batch has run there is no queued batch left
+	db.SetBeforeFunc(batchSQL, func() {
+		batchIDToExec.Result.Rows = nil
+	})
+
+	// the batches proceed without being throttled, with a warning
+	done := make(chan struct{})
+	go func() {
+		jc.dmlJobBatchRunner(&JobArgs{uuid: uuid, table: "t1", batchInfoTable: batchTable, failPolicy: failPolicyAbort, batchInterval: 1, batchSize: 100})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the batch runner didn't complete the job while the throttler is not ready")
+	}
+	assert.Equal(t, 1, db.GetQueryCalledNum(batchSQL))
+	assert.Zero(t, throttlerChecks.Load())
+	assert.Greater(t, jc.env.Stats().JobThrottlerNotReady.Get(), notReadyBefore)
+
+	// the throttler is checked again once it's ready
+	jc.checkThrottlerReady = func() error { return nil }
+	assert.False(t, jc.requestThrottle(uuid))
+	assert.EqualValues(t, 1, throttlerChecks.Load())
+}
+
+func TestDMLJobBatchRunnerDeadlockRetries(t *testing.T) {
+	const (
+		uuid          = "uuid"
+		batchTable    = "_vt_BATCH_test"
+		batchID       = "1"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+	defer func(old int) { batchDeadlockRetries = old }(batchDeadlockRetries)
+	batchDeadlockRetries = 2
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the job is never throttled
+	jc.lastSuccessfulThrottle = math.MaxInt64
+	retriesBefore := jc.env.Stats().DeadlockRetries.Counts()["DMLJobs"]
+
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status|start_time|batch_info_table_schema", "varchar|varchar|varchar|varchar"), "uuid|running||"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+(start_time|complete_time|message) = .*`, &sqltypes.Result{RowsAffected: 1})
+
+	// statuses is only accessed by the fakesqldb callbacks, which are serialized, and after the runner returns
+	var statuses []string
+	statusRegexp := regexp.MustCompile(`status = '([a-z-]+)'`)
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+status = .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		statuses = append(statuses, statusRegexp.FindStringSubmatch(query)[1])
+	})
+
+	batchIDFields := sqltypes.MakeTestFields("batch_id", "varchar")
+	batchIDToExec := db.AddQuery(fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTable), sqltypes.MakeTestResult(batchIDFields, batchID))
+	db.AddQuery(fmt.Sprintf("select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = '%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_sql|batch_count_sql_when_creating_batch", "text|text"), batchSQL+"|"+batchCountSQL))
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='%s'", batchTable, batchID), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), QueuedStatus))
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '%s'", batchTable, CompletedStatus, batchID),
+		&sqltypes.Result{RowsAffected: 1})
+	// the batch SQL is a pattern, since the rejected patterns don't apply to the queries added as is.
+	// It deadlocks twice, then succeeds, after which there is no queued batch left.
+	db.AddQueryPatternWithCallback(regexp.QuoteMeta(batchSQL), &sqltypes.Result{RowsAffected: 10}, func(string) {
+		batchIDToExec.Result.Rows = nil
+	})
+	deadlock := mysql.NewSQLError(mysql.ERLockDeadlock, mysql.SSLockDeadlock, "Deadlock found when trying to get lock; try restarting transaction")
+	db.RejectQueryPatternTimes(regexp.QuoteMeta(batchSQL), deadlock, 2)
+
+	done := make(chan struct{})
+	go func() {
+		jc.dmlJobBatchRunner(&JobArgs{uuid: uuid, table: "t1", batchInfoTable: batchTable, failPolicy: failPolicyAbort, batchInterval: 1, batchSize: 100})
+		close(done)
+	}()
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("the batch runner didn't complete the job")
+	}
+	assert.Equal(t, 3, db.GetQueryCalledNum(batchSQL))
+	assert.Equal(t, retriesBefore+2, jc.env.Stats().DeadlockRetries.Counts()["DMLJobs"])
+	// the job isn't failed by the deadlocks retried
+	assert.Equal(t, []string{RunningStatus, CompletedStatus}, statuses)
+}
+
+func TestLoadGlobalPause(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	getSetting := "select value from mysql.non_transactional_dml_job_settings where name = 'pause_all'"
+	db.AddQuery(getSetting, sqltypes.MakeTestResult(sqltypes.MakeTestFields("value", "varchar"), "1"))
+	require.NoError(t, jc.loadGlobalPause(context.Background()))
+	assert.True(t, jc.globalPaused.Load())
+
+	// the jobs are not paused if the setting has never been set
+	db.AddQuery(getSetting, sqltypes.MakeTestResult(sqltypes.MakeTestFields("value", "varchar")))
+	require.NoError(t, jc.loadGlobalPause(context.Background()))
+	assert.False(t, jc.globalPaused.Load())
+}
+
+func TestCompleteJobAnalyzeTable(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("use db1", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status|start_time|batch_info_table_schema", "varchar|varchar|varchar|varchar"), "uuid|running||"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+.*`, &sqltypes.Result{RowsAffected: 1})
+	db.AddQuery("analyze table `t1`", &sqltypes.Result{})
+
+	// the table is not analyzed by default
+	_, err := jc.CompleteJob(context.Background(), "uuid", "db1", "t1")
+	require.NoError(t, err)
+	assert.Equal(t, 0, db.GetQueryCalledNum("analyze table `t1`"))
+
+	defer func(analyze bool) {
+		analyzeTableAfterJob = analyze
+	}(analyzeTableAfterJob)
+	analyzeTableAfterJob = true
+	_, err = jc.CompleteJob(context.Background(), "uuid", "db1", "t1")
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.GetQueryCalledNum("analyze table `t1`"))
+	assert.Contains(t, db.QueryLog(), "use db1;analyze table `t1`")
+}
+
+func TestCreateBatchTableResumes(t *testing.T) {
+	const (
+		uuid       = "uuid"
+		batchTable = "_vt_BATCH_test"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the job is never throttled
+	jc.lastSuccessfulThrottle = math.MaxInt64
+
+	defer func(chunkSize int) { batchTableChunkSize = chunkSize }(batchTableChunkSize)
+	batchTableChunkSize = 1
+
+	stmt, err := sqlparser.Parse("delete from t1 where id > 0")
+	require.NoError(t, err)
+	whereExpr := stmt.(*sqlparser.Delete).Where.Expr
+	pkInfos := []PKInfo{{pkName: "id", pkType: querypb.Type_INT64}}
+
+	// the PKs are selected by pages of a chunk of batches, each page starts after the last PK of the previous one
+	pkFields := sqltypes.MakeTestFields("id", "int64")
+	firstPageSQL := "select id from t1 where id > 0 order by id limit 2"
+	db.AddQuery(firstPageSQL, sqltypes.MakeTestResult(pkFields, "1", "2"))
+	db.AddQuery("select id from t1 where id > 0 and id > 2 order by id limit 2", sqltypes.MakeTestResult(pkFields, "3", "4"))
+	db.AddQuery("select id from t1 where id > 0 and id > 4 order by id limit 2", sqltypes.MakeTestResult(pkFields, "5", "6"))
+	db.AddQuery("select id from t1 where id > 0 and id > 6 order by id limit 2", sqltypes.MakeTestResult(pkFields))
+	db.AddQueryPattern(`(?s)CREATE TABLE IF NOT EXISTS _vt_BATCH_test.*`, &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), "uuid|preparing"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+status = .*`, &sqltypes.Result{RowsAffected: 1})
+	lastBatchFields := sqltypes.MakeTestFields("batch_id|last_pk", "varchar|varchar")
+	lastBatch := db.AddQuery(fmt.Sprintf(sqlTemplateGetLastBatch, batchTable), sqltypes.MakeTestResult(lastBatchFields))
+
+	// inserted is only accessed by the fakesqldb callbacks, which are serialized, and after the build returns
+	var inserted []string
+	interrupt := true
+	insertRegexp := regexp.MustCompile(`(?s)values \('([^']*)'.*,'([^']*)'\)$`)
+	db.AddQueryPatternWithCallback(`(?s)insert into _vt_BATCH_test .*`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		match := insertRegexp.FindStringSubmatch(query)
+		inserted = append(inserted, match[1])
+		// the last PK is a JSON array, its quotes are escaped in the insert
+		lastPK := strings.ReplaceAll(match[2], `\"`, `"`)
+		lastBatch.Result.Rows = [][]sqltypes.Value{{sqltypes.NewVarChar(match[1]), sqltypes.NewVarChar(lastPK)}}
+		// the job controller is closed while the first chunk is inserted
+		if interrupt {
+			interrupt = false
+			jc.cancelOperation()
+		}
+	})
+
+	err = jc.createBatchTable(uuid, "", "t1", batchTable, "", []sqlparser.Expr{whereExpr}, []sqlparser.Statement{stmt}, pkInfos, 2)
+	require.Error(t, err)
+	require.Error(t, jc.ctx.Err())
+	assert.Equal(t, []string{"1"}, inserted)
+	assert.Equal(t, []sqltypes.Value{sqltypes.NewVarChar("1"), sqltypes.NewVarChar(`["2"]`)}, lastBatch.Result.Rows[0])
+
+	// the job controller is opened again, the build resumes after the last PK of the batches already inserted
+	jc.ctx, jc.cancelOperation = context.WithCancel(context.Background())
+	defer jc.cancelOperation()
+	err = jc.createBatchTable(uuid, "", "t1", batchTable, "", []sqlparser.Expr{whereExpr}, []sqlparser.Statement{stmt}, pkInfos, 2)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, inserted)
+	assert.Equal(t, 2, db.GetQueryCalledNum(fmt.Sprintf(sqlTemplateGetLastBatch, batchTable)))
+	assert.Equal(t, 2, db.GetQueryCalledNum(firstPageSQL))
+	assert.Equal(t, 1, db.GetQueryCalledNum("select id from t1 where id > 0 and id > 6 order by id limit 2"))
+}
+
+func TestCreateBatchTableDescending(t *testing.T) {
+	const (
+		uuid       = "uuid"
+		batchTable = "_vt_BATCH_test"
+		dmlSQL     = "delete from t1 where id > 0"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the job is never throttled
+	jc.lastSuccessfulThrottle = math.MaxInt64
+
+	tableName, whereExpr, stmt, err := parseDML(dmlSQL)
+	require.NoError(t, err)
+	pkInfos := []PKInfo{{pkName: "id", pkType: querypb.Type_INT64}}
+	templates := genJobSQLTemplates(tableName, whereExpr, pkInfos, batchOrderDesc)
+	require.Equal(t, "select id from t1 where id > 0 order by id desc", templates.selectSQL)
+
+	db.AddQuery(templates.selectSQL+" limit 200", sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "5", "4", "3", "2", "1"))
+	db.AddQueryPattern(`(?s)CREATE TABLE IF NOT EXISTS _vt_BATCH_test.*`, &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), "uuid|submitted"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+status = .*`, &sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(fmt.Sprintf(sqlTemplateGetLastBatch, batchTable), sqltypes.MakeTestResult(sqltypes.MakeTestFields("batch_id|last_pk", "varchar|varchar")))
+	var inserts []string
+	db.AddQueryPatternWithCallback(`(?s)insert into _vt_BATCH_test .*`, &sqltypes.Result{RowsAffected: 3}, func(query string) {
+		inserts = append(inserts, query)
+	})
+
+	err = jc.createBatchTable(uuid, "", tableName, batchTable, batchOrderDesc, []sqlparser.Expr{whereExpr}, []sqlparser.Statement{stmt}, pkInfos, 2)
+	require.NoError(t, err)
+	require.Len(t, inserts, 1)
+	// the batches are in descending order and cover all the rows, the range of each batch is from its lowest to its highest PK
+	// the last PK of a batch is its last row in descending order
+	assert.Contains(t, inserts[0], "values ('1','delete from t1 where id > 0 and (id >= 4 and id <= 5)','select count(*) as count_rows from t1 where id > 0 and (id >= 4 and id <= 5)',2,'4','5','[\\\"4\\\"]'),"+
+		"('2','delete from t1 where id > 0 and (id >= 2 and id <= 3)','select count(*) as count_rows from t1 where id > 0 and (id >= 2 and id <= 3)',2,'2','3','[\\\"2\\\"]'),"+
+		"('3','delete from t1 where id > 0 and (id >= 1 and id <= 1)','select count(*) as count_rows from t1 where id > 0 and (id >= 1 and id <= 1)',1,'1','1','[\\\"1\\\"]')")
+}
+
+func TestCreateBatchTableMultiStatement(t *testing.T) {
+	const (
+		uuid       = "uuid"
+		batchTable = "_vt_BATCH_test"
+		dmlSQL     = "update t1 set flag = 1 where c1 < 10; delete from t1 where flag = 1"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+	// the job is never throttled
+	jc.lastSuccessfulThrottle = math.MaxInt64
+
+	tableName, whereExprs, stmts, err := parseJobDMLs(dmlSQL)
+	require.NoError(t, err)
+	pkInfos := []PKInfo{{pkName: "id", pkType: querypb.Type_INT64}}
+	// the batches cover the rows of both statements
+	templates := genJobSQLTemplates(tableName, orWhereExprs(whereExprs), pkInfos, "")
+	require.Equal(t, "select id from t1 where c1 < 10 or flag = 1 order by id", templates.selectSQL)
+
+	db.AddQuery(templates.selectSQL+" limit 200", sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1", "2", "3"))
+	db.AddQueryPattern(`(?s)CREATE TABLE IF NOT EXISTS _vt_BATCH_test.*`, &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), "uuid|submitted"))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+status = .*`, &sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(fmt.Sprintf(sqlTemplateGetLastBatch, batchTable), sqltypes.MakeTestResult(sqltypes.MakeTestFields("batch_id|last_pk", "varchar|varchar")))
+	var inserts []string
+	db.AddQueryPatternWithCallback(`(?s)insert into _vt_BATCH_test .*`, &sqltypes.Result{RowsAffected: 2}, func(query string) {
+		inserts = append(inserts, query)
+	})
+
+	err = jc.createBatchTable(uuid, "", tableName, batchTable, "", whereExprs, stmts, pkInfos, 2)
+	require.NoError(t, err)
+	require.Len(t, inserts, 1)
+	// each batch runs both statements in order within its PK range, and counts the rows of both
+	assert.Contains(t, inserts[0], "values ('1','update t1 set flag = 1 where c1 < 10 and (id >= 1 and id <= 2); delete from t1 where flag = 1 and (id >= 1 and id <= 2)',"+
+		"'select count(*) as count_rows from t1 where (c1 < 10 or flag = 1) and (id >= 1 and id <= 2)',2,'1','2','[\\\"2\\\"]'),"+
+		"('2','update t1 set flag = 1 where c1 < 10 and (id >= 3 and id <= 3); delete from t1 where flag = 1 and (id >= 3 and id <= 3)',"+
+		"'select count(*) as count_rows from t1 where (c1 < 10 or flag = 1) and (id >= 3 and id <= 3)',1,'3','3','[\\\"3\\\"]')")
+}
+
+func TestSubmitJobBatchParamsBounds(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	defer func(interval int) { minBatchInterval = interval }(minBatchInterval)
+	minBatchInterval = 10
+
+	submit := func(batchIntervalInMs, batchSize int64) error {
+		_, err := jc.SubmitJob("delete from t1 where id > 10", "test", "", "", "", batchIntervalInMs, batchSize, false, "", "", "")
+		return err
+	}
+
+	err := submit(0, 10000000)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid batch size 10000000, it should be between 1 and 100000")
+
+	err = submit(1, 0)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid batch interval 1ms, it should be between 10ms and 86400000ms")
+
+	err = submit(0, -1)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid batch size -1")
+
+	// the batch params are valid, the job fails later since the fake db has no table
+	err = submit(10, 100000)
+	require.Error(t, err)
+	assert.NotContains(t, err.Error(), "invalid batch")
+}
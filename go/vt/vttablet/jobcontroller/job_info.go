@@ -0,0 +1,216 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+// JobInfo is the JSON shape of a job returned by the show_job_json command.
+// It is independent of the columns of mysql.non_transactional_dml_jobs, so clients
+// parsing it are not affected by changes to the job table.
+type JobInfo struct {
+	UUID          string     `json:"uuid"`
+	TableSchema   string     `json:"table_schema"`
+	TableName     string     `json:"table_name"`
+	SQL           string     `json:"sql"`
+	Status        string     `json:"status"`
+	StatusSetTime *time.Time `json:"status_set_time,omitempty"`
+	Message       string     `json:"message,omitempty"`
+	Tag           string     `json:"tag,omitempty"`
+
+	BatchSize         int64   `json:"batch_size"`
+	BatchIntervalInMs int64   `json:"batch_interval_in_ms"`
+	FailPolicy        string  `json:"fail_policy"`
+	PostponeLaunch    bool    `json:"postpone_launch"`
+	ThrottleRatio     float64 `json:"throttle_ratio,omitempty"`
+	// ThrottleExpireTime is only set while the job is throttled.
+	ThrottleExpireTime *time.Time `json:"throttle_expire_time,omitempty"`
+
+	StartTime    *time.Time `json:"start_time,omitempty"`
+	CompleteTime *time.Time `json:"complete_time,omitempty"`
+	// DurationInMs is the time the job has been running for, or the time it took if it has finished.
+	DurationInMs int64 `json:"duration_in_ms"`
+
+	TotalBatches     int64 `json:"total_batches"`
+	CompletedBatches int64 `json:"completed_batches"`
+	// Progress is the percentage of the batches that are completed.
+	Progress       float64 `json:"progress"`
+	AffectedRows   int64   `json:"affected_rows"`
+	DealingBatchID string  `json:"dealing_batch_id,omitempty"`
+}
+
+// ShowJobJSON returns a row with a JobInfo in JSON for the job uuid, or for every job if uuid is "*".
+func (jc *JobController) ShowJobJSON(uuid string) (*sqltypes.Result, error) {
+	var query string
+	if uuid == "*" {
+		query = sqlDMLJobGetAllJobs
+	} else {
+		var err error
+		query, err = sqlparser.ParseAndBind(sqlDMLJobGetInfo, sqltypes.StringBindVariable(uuid))
+		if err != nil {
+			return &sqltypes.Result{}, err
+		}
+	}
+	qr, err := jc.execQuery(jc.ctx, "", query)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	if uuid != "*" && len(qr.Rows) != 1 {
+		return &sqltypes.Result{}, fmt.Errorf("the len of query result of select dml job is not 1 but %d", len(qr.Rows))
+	}
+
+	result := &sqltypes.Result{Fields: sqltypes.BuildVarCharFields("job_json")}
+	now := time.Now()
+	for _, row := range qr.Named().Rows {
+		info := jc.genJobInfo(jc.ctx, row, now)
+		jobJSON, err := json.Marshal(info)
+		if err != nil {
+			return &sqltypes.Result{}, err
+		}
+		result.Rows = append(result.Rows, sqltypes.BuildVarCharRow(string(jobJSON)))
+	}
+	return result, nil
+}
+
+// ListActiveJobs returns the JobInfo of the jobs which are not completed, failed or canceled yet, ordered by submission.
+func (jc *JobController) ListActiveJobs(ctx context.Context) ([]*JobInfo, error) {
+	qr, err := jc.execQuery(ctx, "", sqlDMLJobGetActiveJobs)
+	if err != nil {
+		return nil, err
+	}
+	jobs := make([]*JobInfo, 0, len(qr.Rows))
+	now := time.Now()
+	for _, row := range qr.Named().Rows {
+		jobs = append(jobs, jc.genJobInfo(ctx, row, now))
+	}
+	return jobs, nil
+}
+
+// genJobInfo builds the JobInfo of the job in row, with the values derived from its batch table.
+func (jc *JobController) genJobInfo(ctx context.Context, row sqltypes.RowNamedValues, now time.Time) *JobInfo {
+	uuid := row["job_uuid"].ToString()
+	batchInfoTableSchema := row["batch_info_table_schema"].ToString()
+	batchTableName := genBatchTableName(uuid)
+
+	// the batch table may not be created yet, so its values are only informative
+	affectedRows, err := jc.genJobAffectedRows(batchInfoTableSchema, batchTableName, uuid)
+	if err != nil {
+		log.Infof(err.Error())
+	}
+	dealingBatchID, err := getBatchIDToExec(ctx, jc, batchInfoTableSchema, batchTableName)
+	if err != nil {
+		log.Infof(err.Error())
+	}
+	totalBatches, completedBatches, err := jc.getBatchProgress(ctx, batchInfoTableSchema, batchTableName)
+	if err != nil {
+		log.Infof(err.Error())
+	}
+
+	return buildJobInfo(row, affectedRows, dealingBatchID, totalBatches, completedBatches, now)
+}
+
+// getBatchProgress returns the number of batches of a job and how many of them are completed.
+func (jc *JobController) getBatchProgress(ctx context.Context, batchTableSchema, batchTableName string) (total, completed int64, err error) {
+	query := fmt.Sprintf(sqlTemplateGetBatchProgress, batchTableName)
+	qr, err := jc.execQuery(ctx, batchTableSchema, query)
+	if err != nil {
+		return 0, 0, err
+	}
+	if len(qr.Rows) != 1 {
+		return 0, 0, fmt.Errorf("the len of query result of batch progress is not 1 but %d", len(qr.Rows))
+	}
+	row := qr.Named().Row()
+	if total, err = row.ToInt64("total_batches"); err != nil {
+		return 0, 0, err
+	}
+	// SUM returns a decimal
+	completedFloat, err := row["completed_batches"].ToFloat64()
+	if err != nil {
+		return 0, 0, err
+	}
+	return total, int64(completedFloat), nil
+}
+
+func buildJobInfo(row sqltypes.RowNamedValues, affectedRows int64, dealingBatchID string, totalBatches, completedBatches int64, now time.Time) *JobInfo {
+	info := &JobInfo{
+		UUID:               row["job_uuid"].ToString(),
+		TableSchema:        row["table_schema"].ToString(),
+		TableName:          row["table_name"].ToString(),
+		SQL:                row["dml_sql"].ToString(),
+		Status:             row["status"].ToString(),
+		StatusSetTime:      parseJobTime(row["status_set_time"]),
+		Message:            row["message"].ToString(),
+		Tag:                row["tag"].ToString(),
+		FailPolicy:         row["fail_policy"].ToString(),
+		ThrottleExpireTime: parseThrottleExpireTime(row["throttle_expire_time"]),
+		StartTime:          parseJobTime(row["start_time"]),
+		CompleteTime:       parseJobTime(row["complete_time"]),
+		TotalBatches:       totalBatches,
+		CompletedBatches:   completedBatches,
+		AffectedRows:       affectedRows,
+		DealingBatchID:     dealingBatchID,
+	}
+	info.BatchSize, _ = row["batch_size"].ToInt64()
+	info.BatchIntervalInMs, _ = row["batch_interval_in_ms"].ToInt64()
+	postponeLaunch, _ := row["postpone_launch"].ToInt64()
+	info.PostponeLaunch = postponeLaunch == 1
+	info.ThrottleRatio, _ = row["throttle_ratio"].ToFloat64()
+
+	switch {
+	case info.Status == CompletedStatus:
+		info.Progress = 100
+	case totalBatches > 0:
+		info.Progress = float64(completedBatches) * 100 / float64(totalBatches)
+	}
+
+	if info.Status == RunningStatus {
+		if info.StartTime != nil && now.After(*info.StartTime) {
+			info.DurationInMs = now.Sub(*info.StartTime).Milliseconds()
+		}
+	} else if duration, ok := getJobDuration(row); ok {
+		info.DurationInMs = duration.Milliseconds()
+	}
+	return info
+}
+
+// parseJobTime parses a time stored in the job table, it returns nil if the time is not set.
+func parseJobTime(value sqltypes.Value) *time.Time {
+	str := value.ToString()
+	if str == "" {
+		return nil
+	}
+	t, err := time.ParseInLocation(time.DateTime, str, time.Local)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
+
+// parseThrottleExpireTime parses the throttle expire time of a job, which is stored by time.Time.String.
+func parseThrottleExpireTime(value sqltypes.Value) *time.Time {
+	str := value.ToString()
+	// drop the monotonic clock reading
+	if i := strings.Index(str, " m="); i != -1 {
+		str = str[:i]
+	}
+	if str == "" {
+		return nil
+	}
+	t, err := time.Parse("2006-01-02 15:04:05.999999999 -0700 MST", str)
+	if err != nil {
+		return nil
+	}
+	return &t
+}
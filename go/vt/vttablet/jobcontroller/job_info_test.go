@@ -0,0 +1,112 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/fakesqldb"
+	"vitess.io/vitess/go/sqltypes"
+)
+
+func TestShowJobJSON(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	jobFields := sqltypes.MakeTestFields(
+		"id|job_uuid|table_schema|table_name|batch_info_table_schema|dml_sql|status|status_set_time|message|batch_size|batch_interval_in_ms|fail_policy|postpone_launch|throttle_ratio|throttle_expire_time|start_time|complete_time|duration_in_ms",
+		"int64|varchar|varchar|varchar|varchar|text|varchar|timestamp|varchar|int64|int64|varchar|uint8|float64|varchar|timestamp|timestamp|int64")
+	db.AddQuery("use db1", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid1'`, sqltypes.MakeTestResult(jobFields,
+		"1|uuid1|db1|t1|db1|delete from t1 where c1 = 1|running|2023-09-01 10:00:00|null|100|10|pause|0|0.5|2030-01-01 00:00:00 +0000 UTC m=+10.500000001|2023-09-01 10:00:00.000|null|null"))
+	db.AddQuery("SELECT SUM(actually_affected_rows) AS affected_rows FROM _vt_BATCH_uuid1 WHERE batch_status='completed'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("affected_rows", "decimal"), "100"))
+	db.AddQuery("SELECT batch_id FROM _vt_BATCH_uuid1 where batch_status = 'queued' order by CAST(SUBSTRING_INDEX(batch_id, '-', 1) AS SIGNED),id limit 1",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("batch_id", "varchar"), "2"))
+	db.AddQuery("SELECT COUNT(*) AS total_batches, COALESCE(SUM(batch_status = 'completed'), 0) AS completed_batches FROM _vt_BATCH_uuid1",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("total_batches|completed_batches", "int64|decimal"), "4|1"))
+
+	qr, err := jc.HandleRequest(ShowJobJSON, JobRequest{JobUUID: "uuid1"})
+	require.NoError(t, err)
+	require.Len(t, qr.Rows, 1)
+	assert.Equal(t, "job_json", qr.Fields[0].Name)
+
+	var info JobInfo
+	require.NoError(t, json.Unmarshal([]byte(qr.Rows[0][0].ToString()), &info))
+	assert.Equal(t, "uuid1", info.UUID)
+	assert.Equal(t, "db1", info.TableSchema)
+	assert.Equal(t, "t1", info.TableName)
+	assert.Equal(t, "delete from t1 where c1 = 1", info.SQL)
+	assert.Equal(t, RunningStatus, info.Status)
+	assert.Empty(t, info.Message)
+	assert.EqualValues(t, 100, info.BatchSize)
+	assert.EqualValues(t, 10, info.BatchIntervalInMs)
+	assert.Equal(t, "pause", info.FailPolicy)
+	assert.False(t, info.PostponeLaunch)
+	assert.Equal(t, 0.5, info.ThrottleRatio)
+	require.NotNil(t, info.ThrottleExpireTime)
+	assert.True(t, info.ThrottleExpireTime.Equal(time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)))
+	require.NotNil(t, info.StartTime)
+	assert.True(t, info.StartTime.Equal(time.Date(2023, 9, 1, 10, 0, 0, 0, time.Local)))
+	assert.Nil(t, info.CompleteTime)
+	// a running job has been running since it started
+	assert.Greater(t, info.DurationInMs, int64(0))
+	assert.EqualValues(t, 4, info.TotalBatches)
+	assert.EqualValues(t, 1, info.CompletedBatches)
+	assert.Equal(t, 25.0, info.Progress)
+	assert.EqualValues(t, 100, info.AffectedRows)
+	assert.Equal(t, "2", info.DealingBatchID)
+
+	// the keys of the JSON are independent of the job table columns
+	var fields map[string]any
+	require.NoError(t, json.Unmarshal([]byte(qr.Rows[0][0].ToString()), &fields))
+	assert.Contains(t, fields, "progress")
+	assert.Contains(t, fields, "duration_in_ms")
+	assert.NotContains(t, fields, "id")
+	assert.NotContains(t, fields, "batch_info_table_schema")
+}
+
+func TestListActiveJobs(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("select * from mysql.non_transactional_dml_jobs where status NOT IN ('completed','failed','canceled') order by id", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("id|job_uuid|table_schema|table_name|status", "int64|varchar|varchar|varchar|varchar"),
+		"1|uuid1|db1|t1|running",
+		"2|uuid2|db1|t2|queued"))
+
+	jobs, err := jc.ListActiveJobs(context.Background())
+	require.NoError(t, err)
+	require.Len(t, jobs, 2)
+	assert.Equal(t, "uuid1", jobs[0].UUID)
+	assert.Equal(t, RunningStatus, jobs[0].Status)
+	assert.Equal(t, "uuid2", jobs[1].UUID)
+	assert.Equal(t, "t2", jobs[1].TableName)
+	assert.Equal(t, QueuedStatus, jobs[1].Status)
+}
+
+func TestBuildJobInfoTerminal(t *testing.T) {
+	fields := sqltypes.MakeTestFields("job_uuid|status|start_time|status_set_time|duration_in_ms", "varchar|varchar|timestamp|timestamp|int64")
+
+	completed := sqltypes.MakeTestResult(fields, "uuid1|completed|2023-09-01 10:00:00.000|2023-09-01 10:00:02|2000").Named().Row()
+	info := buildJobInfo(completed, 1000, "", 3, 3, time.Now())
+	assert.Equal(t, 100.0, info.Progress)
+	assert.EqualValues(t, 2000, info.DurationInMs)
+
+	failed := sqltypes.MakeTestResult(fields, "uuid2|failed|2023-09-01 10:00:00.000|2023-09-01 10:00:10|null").Named().Row()
+	info = buildJobInfo(failed, 200, "3", 4, 2, time.Now())
+	assert.Equal(t, 50.0, info.Progress)
+	assert.EqualValues(t, 10000, info.DurationInMs)
+	assert.Nil(t, info.ThrottleExpireTime)
+}
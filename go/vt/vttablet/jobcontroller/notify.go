@@ -0,0 +1,99 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package jobcontroller
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+)
+
+var (
+	// notifyTimeout is how long a single post of a finished job to its notify url can take.
+	notifyTimeout = 5 * time.Second
+	// notifyMaxAttempts is how many times a finished job is posted to its notify url before giving up.
+	notifyMaxAttempts = 3
+	// notifyRetryInterval is the time waited between two attempts.
+	notifyRetryInterval = time.Second
+)
+
+// notifyJobFinished posts the JobInfo of a completed, failed or canceled job to the url set by its DML_NOTIFY_URL directive.
+// The post runs in its own goroutine so that a slow receiver never delays the runner or the job commands.
+// The delivery is best-effort: it's given up after notifyMaxAttempts attempts, or when the controller is closed.
+func (jc *JobController) notifyJobFinished(uuid string) {
+	ctx := jc.ctx
+	go func() {
+		if err := jc.postFinishedJob(ctx, uuid); err != nil {
+			log.Errorf("JobController: failed to notify the end of job %s: %v", uuid, err)
+		}
+	}()
+}
+
+func (jc *JobController) postFinishedJob(ctx context.Context, uuid string) error {
+	query, err := sqlparser.ParseAndBind(sqlDMLJobGetInfo,
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return err
+	}
+	qr, err := jc.execQuery(ctx, "", query)
+	if err != nil {
+		return err
+	}
+	if len(qr.Rows) != 1 {
+		return fmt.Errorf("uuid %s has %d entrys in the table instead of 1", uuid, len(qr.Rows))
+	}
+	row := qr.Named().Row()
+	notifyURL := row.AsString("notify_url", "")
+	if notifyURL == "" {
+		return nil
+	}
+	body, err := json.Marshal(jc.genJobInfo(ctx, row, time.Now()))
+	if err != nil {
+		return err
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = postJSON(ctx, notifyURL, body)
+		if err == nil {
+			return nil
+		}
+		if attempt == notifyMaxAttempts {
+			return fmt.Errorf("gave up posting to %s after %d attempts: %v", notifyURL, attempt, err)
+		}
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(notifyRetryInterval):
+		}
+	}
+}
+
+// postJSON posts body to url, it fails unless the receiver answers with a 2xx status.
+func postJSON(ctx context.Context, url string, body []byte) error {
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,82 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/fakesqldb"
+	"vitess.io/vitess/go/sqltypes"
+)
+
+func TestPostFinishedJob(t *testing.T) {
+	defer func(interval time.Duration) {
+		notifyRetryInterval = interval
+	}(notifyRetryInterval)
+	notifyRetryInterval = time.Millisecond
+
+	var (
+		posts    int
+		failures int
+		body     []byte
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		posts++
+		assert.Equal(t, http.MethodPost, r.Method)
+		assert.Equal(t, "application/json", r.Header.Get("Content-Type"))
+		if failures > 0 {
+			failures--
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		body, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	jobFields := sqltypes.MakeTestFields("job_uuid|table_schema|table_name|status|message|notify_url",
+		"varchar|varchar|varchar|varchar|varchar|varchar")
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid1'`, sqltypes.MakeTestResult(jobFields,
+		"uuid1|db1|t1|failed|batch 3 failed|"+server.URL))
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid2'`, sqltypes.MakeTestResult(jobFields,
+		"uuid2|db1|t1|completed||"))
+
+	require.NoError(t, jc.postFinishedJob(context.Background(), "uuid1"))
+	assert.Equal(t, 1, posts)
+	var info JobInfo
+	require.NoError(t, json.Unmarshal(body, &info))
+	assert.Equal(t, "uuid1", info.UUID)
+	assert.Equal(t, "failed", info.Status)
+	assert.Equal(t, "batch 3 failed", info.Message)
+
+	// the post is retried until the receiver accepts it
+	posts, failures = 0, notifyMaxAttempts-1
+	require.NoError(t, jc.postFinishedJob(context.Background(), "uuid1"))
+	assert.Equal(t, notifyMaxAttempts, posts)
+
+	// and given up after notifyMaxAttempts attempts
+	posts, failures = 0, notifyMaxAttempts
+	err := jc.postFinishedJob(context.Background(), "uuid1")
+	assert.ErrorContains(t, err, "gave up posting")
+	assert.Equal(t, notifyMaxAttempts, posts)
+
+	// a job without a notify url is not posted
+	posts = 0
+	require.NoError(t, jc.postFinishedJob(context.Background(), "uuid2"))
+	assert.Equal(t, 0, posts)
+}
@@ -0,0 +1,73 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package jobcontroller
+
+import (
+	"context"
+	"fmt"
+	"math"
+
+	"vitess.io/vitess/go/pools"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/connpool"
+)
+
+// queryExecutor runs a query, in the schema targetString if it's not empty.
+// It's implemented by JobController, which borrows a connection for each query, and by runnerConn.
+type queryExecutor interface {
+	execQuery(ctx context.Context, targetString, query string) (*sqltypes.Result, error)
+}
+
+// runnerConn is the connection a batch runner holds for its lifetime to run its read and bookkeeping queries,
+// so that it doesn't borrow a connection from the small task pool, and switch it to the schema of the job, for each of them.
+// The batches still run in transactions on connections of their own, see execBatchAndRecord.
+// A runnerConn isn't safe for concurrent use.
+type runnerConn struct {
+	jc          *JobController
+	tableSchema string
+	conn        *connpool.DBConn
+}
+
+func (jc *JobController) newRunnerConn(tableSchema string) *runnerConn {
+	return &runnerConn{jc: jc, tableSchema: tableSchema}
+}
+
+// execQuery runs query on the connection of the runner, which is borrowed on first use and stays in the schema of the job,
+// so the queries without a targetString must qualify their tables, as the ones on mysql.non_transactional_dml_jobs do.
+// A query in another schema runs on a connection borrowed for it.
+func (rc *runnerConn) execQuery(ctx context.Context, targetString, query string) (*sqltypes.Result, error) {
+	if targetString != "" && targetString != rc.tableSchema {
+		return rc.jc.execQuery(ctx, targetString, query)
+	}
+	if rc.conn == nil {
+		var setting pools.Setting
+		if rc.tableSchema != "" {
+			setting.SetWithoutDBName(false)
+			setting.SetQuery(fmt.Sprintf("use %s", rc.tableSchema))
+			setting.SetResetQuery(fmt.Sprintf("use %s", rc.jc.env.Config().DB.DBName))
+		}
+		conn, err := rc.jc.pool.BorrowConn(ctx, &setting)
+		if err != nil {
+			return nil, err
+		}
+		rc.conn = conn
+	}
+	qr, err := rc.conn.Exec(ctx, query, math.MaxInt32, true)
+	// the connection is closed if the query was killed, e.g. because ctx is done, a new one is borrowed for the next query
+	if err != nil && rc.conn.IsClosed() {
+		rc.release()
+	}
+	return qr, err
+}
+
+// release gives the connection back to the pool.
+func (rc *runnerConn) release() {
+	if rc.conn == nil {
+		return
+	}
+	rc.conn.Recycle()
+	rc.conn = nil
+}
@@ -17,13 +17,20 @@ const (
     	batch_end                       text        NOT NULL,
    		batch_sql                       text     NOT NULL,
     	batch_count_sql_when_creating_batch                       text     NOT NULL,
-		PRIMARY KEY (id)
-	) ENGINE = InnoDB`
+    	checksum_before                 bigint unsigned  NULL DEFAULT NULL,
+    	checksum_after                  bigint unsigned  NULL DEFAULT NULL,
+    	skipped_rows                    bigint unsigned  NOT NULL DEFAULT 0,
+    	last_pk                         text        NULL DEFAULT NULL,
+		PRIMARY KEY (id),
+		KEY batch_status_idx (batch_status)
+	) %s`
 )
 
 const (
 	sqlDMLJobGetJobsToSchedule = `select * from mysql.non_transactional_dml_jobs where status IN ('queued','not-in-time-period') order by id`
 	sqlDMLJobGetAllJobs        = `select * from mysql.non_transactional_dml_jobs order by id`
+	sqlDMLJobGetJobsWithTag    = `select * from mysql.non_transactional_dml_jobs where tag like %a order by id`
+	sqlDMLJobGetActiveJobs     = `select * from mysql.non_transactional_dml_jobs where status NOT IN ('completed','failed','canceled') order by id`
 	sqlDMLJobSubmit            = `insert into mysql.non_transactional_dml_jobs (
                                       job_uuid,
                                       dml_sql,
@@ -42,7 +49,17 @@ const (
                                       batch_size,
                                       throttle_expire_time,
                                       throttle_ratio,
-                                      postpone_launch) values(%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a)`
+                                      postpone_launch,
+                                      launch_at,
+                                      archive_table,
+                                      isolation_level,
+                                      checksum_columns,
+                                      max_replication_lag,
+                                      notify_url,
+                                      batch_order,
+                                      version_column,
+                                      version_snapshot,
+                                      tag) values(%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a,%a)`
 
 	sqlDMLJobUpdateMessage = `update mysql.non_transactional_dml_jobs set 
                                     message = %a 
@@ -52,20 +69,71 @@ const (
 	sqlDMLJobUpdateStatus = `update mysql.non_transactional_dml_jobs set 
                                     status = %a,
                                     status_set_time = %a
+                                where 
+                                    job_uuid = %a and status = %a`
+
+	sqlDMLJobUpdateStartTime = `update mysql.non_transactional_dml_jobs set 
+                                    start_time = %a
+                                where 
+                                    job_uuid = %a
+                                    and start_time is null`
+
+	sqlDMLJobUpdateFrozenWhere = `update mysql.non_transactional_dml_jobs set 
+                                    frozen_where = %a
                                 where 
                                     job_uuid = %a`
 
+	sqlDMLJobUpdateCompletionStats = `update mysql.non_transactional_dml_jobs set 
+                                    complete_time = %a,
+                                    duration_in_ms = %a,
+                                    rows_per_second = %a
+                                where 
+                                    job_uuid = %a`
+
+	sqlDMLJobGetSetting = `select value from mysql.non_transactional_dml_job_settings where name = %a`
+
+	sqlDMLJobSetSetting = `insert into mysql.non_transactional_dml_job_settings (name, value) values (%a, %a)
+                                on duplicate key update value = values(value)`
+
 	sqlDMLJobGetInfo = `select * from mysql.non_transactional_dml_jobs 
                                 where
                                 	job_uuid = %a`
 
 	sqlGetTablePk = ` show index from %s where key_name = 'primary'`
 
+	sqlGetSchemaExists = `SELECT SCHEMA_NAME FROM INFORMATION_SCHEMA.SCHEMATA
+								WHERE 
+								    SCHEMA_NAME = %a`
+
 	sqlGetTableColNames = `SELECT COLUMN_NAME FROM INFORMATION_SCHEMA.COLUMNS
 								WHERE 
 								    TABLE_SCHEMA = %a
 									AND TABLE_NAME = %a`
 
+	sqlGetTableGeneratedCols = `SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA.COLUMNS
+								WHERE 
+								    TABLE_SCHEMA = %a
+									AND TABLE_NAME = %a
+									AND EXTRA IN ('VIRTUAL GENERATED', 'STORED GENERATED')`
+
+	sqlGetTableColTypes = `SELECT COLUMN_NAME, COLUMN_TYPE FROM INFORMATION_SCHEMA.COLUMNS
+								WHERE 
+								    TABLE_SCHEMA = %a
+									AND TABLE_NAME = %a
+								ORDER BY ORDINAL_POSITION`
+
+	sqlTemplateArchiveBatch = `insert into %s (%s) select %s from %s%s`
+
+	sqlTemplateBatchChecksum = `select cast(coalesce(sum(crc32(concat_ws('#', %s, concat(%s)))), 0) as unsigned) as checksum from %s%s`
+
+	sqlTemplateVersionSnapshot = `select max(%s) as version_snapshot from %s`
+
+	sqlTemplateBatchSkippedRows = `select count(*) as count_rows from %s%s`
+
+	sqlTemplateSetIsolationLevel = `set session transaction isolation level %s`
+
+	sqlResetIsolationLevel = `set session transaction_isolation = default`
+
 	sqlDMLJobUpdateThrottleInfo = `update mysql.non_transactional_dml_jobs set 
                                     throttle_ratio = %a ,
                                     throttle_expire_time = %a
@@ -91,10 +159,12 @@ const (
 
 	sqlTemplateGetBatchSQLsByID = `select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = %%a`
 
-	sqlTemplateGetMaxBatchID = `select batch_id as max_batch_id from %s order by id desc limit 1`
-
 	sqlTempalteUpdateBatchStatusAndAffectedRows = `update %s set batch_status = %%a,actually_affected_rows = actually_affected_rows+%%a where batch_id = %%a`
 
+	sqlTemplateUpdateBatchChecksum = `update %s set checksum_before = %%a, checksum_after = %%a where batch_id = %%a`
+
+	sqlTemplateUpdateBatchSkippedRows = `update %s set skipped_rows = %%a where batch_id = %%a`
+
 	sqlTemplateUpdateBatchSQL = `update %s set batch_sql=%%a,batch_begin=%%a,batch_end=%%a where batch_id=%%a`
 
 	sqlTemplateSelectPKCols = `select %s from %s.%s limit 1`
@@ -116,14 +186,28 @@ const (
 	 	batch_end
 	) values (%%a,%%a,%%a,%%a,%%a,%%a)`
 
+	sqlTemplateInsertBatchEntries = `insert into %s (batch_id, batch_sql, batch_count_sql_when_creating_batch, count_size_when_creating_batch, batch_begin, batch_end, last_pk) values %s`
+
+	sqlTemplateGetLastBatch = `select batch_id, last_pk from %s order by id desc limit 1`
+
+	sqlTemplateTruncateBatchTable = `truncate table %s`
+
 	sqlTemplateDropBatchTable = `drop table %s`
 
+	sqlTemplateCreateBatchTableSchema = `create database if not exists %s`
+
+	sqlTemplateAnalyzeTable = `analyze table %s`
+
 	sqlShowTablesLike = "SHOW TABLES LIKE '%a'"
 
 	sqlTemplateGenAffectedRows = `SELECT SUM(actually_affected_rows) AS affected_rows FROM %s WHERE batch_status='completed';`
 
+	sqlTemplateGetBatchProgress = `SELECT COUNT(*) AS total_batches, COALESCE(SUM(batch_status = 'completed'), 0) AS completed_batches FROM %s`
+
 	sqlTemplateShowBatchTable = `SELECT * FROM %s order by CAST(SUBSTRING_INDEX(batch_id, '-', 1) AS SIGNED),id`
 
+	sqlTemplateShowBatch = `select batch_id, batch_sql, batch_count_sql_when_creating_batch as batch_count_sql, batch_status from %s where batch_id = %%a`
+
 	sqlGetJobTableColNames = `
 		SELECT COLUMN_NAME 
 		FROM INFORMATION_SCHEMA.COLUMNS 
@@ -14,6 +14,7 @@ import (
 	"time"
 
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/logutil"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/vterrors"
@@ -23,6 +24,9 @@ import (
 var throttleTicks int64
 var throttleInit sync.Once
 
+// throttlerNotReadyLogger warns that the batches aren't throttled because the throttler isn't ready.
+var throttlerNotReadyLogger = logutil.NewThrottledLogger("JobThrottlerNotReady", time.Minute)
+
 func initThrottleTicker() {
 	throttleInit.Do(func() {
 		go func() {
@@ -90,18 +94,28 @@ func (jc *JobController) UnthrottleJob(uuid string) (result *sqltypes.Result, er
 	return jc.execQuery(ctx, "", query)
 }
 
+// requestThrottle returns true if a batch of the job can be executed according to the throttler.
+// It fails open: while the throttler isn't ready, e.g. during the startup of the tablet, the batches
+// aren't throttled, so that the jobs are neither blocked nor gated by the metrics of a throttler still
+// being initialized. A warning is logged meanwhile, and the replication lag threshold of a job is still
+// checked by requestJobReplicationLag.
 func (jc *JobController) requestThrottle(uuid string) (throttleCheckOK bool) {
 	if jc.lastSuccessfulThrottle >= atomic.LoadInt64(&throttleTicks) {
 		// if last check was OK just very recently there is no need to check again
 		return true
 	}
+	if err := jc.checkThrottlerReady(); err != nil {
+		// the check isn't recorded as successful, so the throttler is checked again by the next batch
+		jc.env.Stats().JobThrottlerNotReady.Add(1)
+		throttlerNotReadyLogger.Warningf("JobController: job %s is not throttled since the throttler is not ready: %v", uuid, err)
+		return true
+	}
 	ctx := context.Background()
 	// dml-job" prefix is added to the app name.
 	// This allows throttling all DML jobs by throttle "dml-job" app
 	appName := "dml-job:" + uuid
 	throttleCheckFlags := &throttle.CheckFlags{}
-	checkType := throttle.ThrottleCheckPrimaryWrite
-	checkRst := jc.lagThrottler.CheckByType(ctx, appName, "", throttleCheckFlags, checkType)
+	checkRst := jc.checkThrottler(ctx, appName, throttleCheckFlags)
 	if checkRst.StatusCode != http.StatusOK {
 		return false
 	}
@@ -109,6 +123,27 @@ func (jc *JobController) requestThrottle(uuid string) (throttleCheckOK bool) {
 	return true
 }
 
+// requestJobReplicationLag checks whether the replication lag is below maxReplicationLag, the lag threshold
+// of the job, which is usually lower than the threshold of the throttler. 0 means the job has no threshold.
+// Unlike requestThrottle, it checks every time, since a recent successful throttler check doesn't imply
+// the lag is below the threshold of the job.
+func (jc *JobController) requestJobReplicationLag(uuid string, maxReplicationLag float64) bool {
+	if maxReplicationLag <= 0 {
+		return true
+	}
+	ctx := context.Background()
+	appName := "dml-job:" + uuid
+	throttleCheckFlags := &throttle.CheckFlags{
+		OverrideThreshold: maxReplicationLag,
+		// the heartbeats are already requested by requestThrottle
+		SkipRequestHeartbeats: true,
+		// exceeding the threshold of the job must not make the throttler deny the low priority apps
+		ReadCheck: true,
+	}
+	checkRst := jc.checkThrottler(ctx, appName, throttleCheckFlags)
+	return checkRst.StatusCode == http.StatusOK
+}
+
 func (jc *JobController) validateThrottleParams(expireString string, ratioLiteral *sqlparser.Literal) (duration time.Duration, ratio float64, err error) {
 	duration = time.Hour * 24 * 365 * 100
 	if expireString != "" {
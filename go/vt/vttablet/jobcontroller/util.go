@@ -7,16 +7,20 @@ package jobcontroller
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"math"
+	"net/url"
 	"strconv"
 	"strings"
 	"time"
 
 	"vitess.io/vitess/go/vt/log"
 
+	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/pools"
+	"vitess.io/vitess/go/sqlescape"
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/sqlparser"
 )
@@ -33,6 +37,24 @@ func (jc *JobController) buildJobSubmitResult(jobUUID, jobBatchTable string, tim
 	return submitRst
 }
 
+func buildJobConfirmResult(confirmToken string, pending *pendingConfirmation) *sqltypes.Result {
+	message := fmt.Sprintf("the job is estimated to affect %d rows, which exceeds the threshold of %d rows, submit it again with dml_confirm_token='%s' to start it",
+		pending.estimatedRows, confirmRowsThreshold, confirmToken)
+	row := sqltypes.BuildVarCharRow(confirmToken, strconv.FormatInt(pending.estimatedRows, 10), pending.expireAt.Format(time.DateTime), message)
+	return &sqltypes.Result{
+		Fields: sqltypes.BuildVarCharFields("confirm_token", "estimated_rows", "expire_at", "message"),
+		Rows:   []sqltypes.Row{row},
+	}
+}
+
+func buildJobDescribeResult(jobUUID, sql, tableSchema, tableName string, templates jobSQLTemplates) *sqltypes.Result {
+	row := sqltypes.BuildVarCharRow(jobUUID, sql, tableSchema, tableName, templates.selectSQL, templates.countSQLTemplate, templates.wherePart, templates.pkPart)
+	return &sqltypes.Result{
+		Fields: sqltypes.BuildVarCharFields("job_uuid", "dml_sql", "table_schema", "table_name", "select_sql", "count_sql_template", "where_part", "pk_part"),
+		Rows:   []sqltypes.Row{row},
+	}
+}
+
 // execQuery execute sql by using connect poll,so if targetString is not empty, it will add prefix `use database` first then execute sql.
 func (jc *JobController) execQuery(ctx context.Context, targetString, query string) (result *sqltypes.Result, err error) {
 	defer jc.env.LogError()
@@ -119,7 +141,194 @@ func parseDML(sql string) (tableName string, whereExpr sqlparser.Expr, stmt sqlp
 	return tableName, whereExpr, stmt, err
 }
 
-func sprintfSelectPksSQL(tableName, whereStr string, pkInfos []PKInfo) string {
+// jobSQLSeparator separates the statements of a multi-statement job in its SQL and in the SQL of its batches.
+const jobSQLSeparator = "; "
+
+// splitJobSQL splits the SQL of a job, or of one of its batches, into its statements.
+// A job may run several statements separated by ';'.
+func splitJobSQL(sql string) ([]string, error) {
+	sqls, err := sqlparser.SplitStatementToPieces(sql)
+	if err != nil {
+		return nil, err
+	}
+	if len(sqls) == 0 {
+		return nil, errors.New("the SQL of the job is empty")
+	}
+	for i := range sqls {
+		sqls[i] = strings.TrimSpace(sqls[i])
+	}
+	return sqls, nil
+}
+
+// parseJobDMLs parses the statements of the SQL of a job with parseDML, and returns them with their where exprs, in order.
+// The statements of a multi-statement job, e.g. an UPDATE flagging rows then a DELETE of the flagged rows,
+// must be on the same table, since they share the PK ranges of the batches and run in the transaction of each batch.
+func parseJobDMLs(sql string) (tableName string, whereExprs []sqlparser.Expr, stmts []sqlparser.Statement, err error) {
+	sqls, err := splitJobSQL(sql)
+	if err != nil {
+		return "", nil, nil, err
+	}
+	for _, stmtSQL := range sqls {
+		stmtTableName, whereExpr, stmt, err := parseDML(stmtSQL)
+		if err != nil {
+			return "", nil, nil, err
+		}
+		if tableName != "" && stmtTableName != tableName {
+			return "", nil, nil, fmt.Errorf("the statements of a job must be on the same table, got %s and %s", tableName, stmtTableName)
+		}
+		tableName = stmtTableName
+		whereExprs = append(whereExprs, whereExpr)
+		stmts = append(stmts, stmt)
+	}
+	return tableName, whereExprs, stmts, nil
+}
+
+// orWhereExprs returns the where expr matching the rows matched by any of whereExprs,
+// the batches of a multi-statement job are split from these rows.
+func orWhereExprs(whereExprs []sqlparser.Expr) sqlparser.Expr {
+	whereExpr := whereExprs[0]
+	for _, expr := range whereExprs[1:] {
+		whereExpr = &sqlparser.OrExpr{Left: whereExpr, Right: expr}
+	}
+	return whereExpr
+}
+
+// freezeWhereExprs evaluates the time functions like NOW() in whereExprs once and substitutes their values,
+// so that the cutoff of the job doesn't drift while its batches run, and is the same for all its statements.
+func (jc *JobController) freezeWhereExprs(ctx context.Context, tableSchema string, whereExprs []sqlparser.Expr) ([]sqlparser.Expr, error) {
+	funcs := getNonDeterministicFuncs(whereExprs...)
+	if len(funcs) == 0 {
+		return whereExprs, nil
+	}
+	qr, err := jc.execQuery(ctx, tableSchema, genEvalFuncsSQL(funcs))
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Rows) != 1 || len(qr.Rows[0]) != len(funcs) {
+		return nil, fmt.Errorf("failed to evaluate %s in the where clause", strings.Join(funcs, ", "))
+	}
+	values := make(map[string]sqltypes.Value, len(funcs))
+	for i, funcStr := range funcs {
+		values[funcStr] = qr.Rows[0][i]
+	}
+	frozenExprs := make([]sqlparser.Expr, 0, len(whereExprs))
+	for _, whereExpr := range whereExprs {
+		frozenExprs = append(frozenExprs, freezeNonDeterministicFuncs(whereExpr, values))
+	}
+	return frozenExprs, nil
+}
+
+// getFrozenWhereExprs returns whereExprs, the where exprs of the statements of a job, with their time functions frozen.
+// They're frozen by freezeWhereExprs the first time and recorded in the frozen_where of the job, so that a job prepared
+// again, e.g. to resume the build of its batch table after a failover, keeps the cutoff it was first prepared with.
+func (jc *JobController) getFrozenWhereExprs(ctx context.Context, jobUUID, tableSchema string, whereExprs []sqlparser.Expr) ([]sqlparser.Expr, error) {
+	if len(getNonDeterministicFuncs(whereExprs...)) == 0 {
+		return whereExprs, nil
+	}
+	frozenWhere, err := jc.getStrJobInfo(ctx, jobUUID, "frozen_where")
+	if err != nil {
+		return nil, err
+	}
+	if frozenWhere != "" {
+		var whereStrs []string
+		if err := json.Unmarshal([]byte(frozenWhere), &whereStrs); err != nil {
+			return nil, fmt.Errorf("invalid frozen where %s of job %s: %v", frozenWhere, jobUUID, err)
+		}
+		if len(whereStrs) != len(whereExprs) {
+			return nil, fmt.Errorf("the frozen where %s of job %s doesn't match its %d statements", frozenWhere, jobUUID, len(whereExprs))
+		}
+		frozenExprs := make([]sqlparser.Expr, 0, len(whereStrs))
+		for _, whereStr := range whereStrs {
+			expr, err := genExprNodeFromStr(whereStr)
+			if err != nil {
+				return nil, err
+			}
+			frozenExprs = append(frozenExprs, expr)
+		}
+		return frozenExprs, nil
+	}
+
+	frozenExprs, err := jc.freezeWhereExprs(ctx, tableSchema, whereExprs)
+	if err != nil {
+		return nil, err
+	}
+	whereStrs := make([]string, 0, len(frozenExprs))
+	for _, expr := range frozenExprs {
+		whereStrs = append(whereStrs, sqlparser.String(expr))
+	}
+	encoded, err := json.Marshal(whereStrs)
+	if err != nil {
+		return nil, err
+	}
+	updateQuery, err := sqlparser.ParseAndBind(sqlDMLJobUpdateFrozenWhere,
+		sqltypes.StringBindVariable(string(encoded)),
+		sqltypes.StringBindVariable(jobUUID))
+	if err != nil {
+		return nil, err
+	}
+	if _, err = jc.execQuery(ctx, "", updateQuery); err != nil {
+		return nil, err
+	}
+	return frozenExprs, nil
+}
+
+// sprintfSelectPksSQL returns the SQL selecting the PKs of the rows matching whereStr, ordered in batchOrder.
+func sprintfSelectPksSQL(tableName, whereStr string, pkInfos []PKInfo, batchOrder string) string {
+	pkCols := genPKColsStr(pkInfos)
+	orderBy := pkCols
+	if batchOrder == batchOrderDesc {
+		orderCols := make([]string, 0, len(pkInfos))
+		for _, pkInfo := range pkInfos {
+			orderCols = append(orderCols, pkInfo.pkName+" desc")
+		}
+		orderBy = strings.Join(orderCols, ",")
+	}
+	selectPksSQL := fmt.Sprintf("select %s from %s where %s order by %s",
+		pkCols, tableName, whereStr, orderBy)
+	return selectPksSQL
+}
+
+// genSelectPKsPageSQL generates the SQL selecting a page of at most limit PKs of the rows matched by whereExpr in batchOrder,
+// starting after lastPK, or from the first row if lastPK is nil.
+func genSelectPKsPageSQL(tableName string, whereExpr sqlparser.Expr, pkInfos []PKInfo, batchOrder string, lastPK []sqltypes.Value, limit int64) (string, error) {
+	if lastPK != nil {
+		afterLastPKExpr, err := genExprNodeFromStr(genPKsAfterStr(pkInfos, lastPK, batchOrder))
+		if err != nil {
+			return "", err
+		}
+		whereExpr = &sqlparser.AndExpr{Left: whereExpr, Right: afterLastPKExpr}
+	}
+	return fmt.Sprintf("%s limit %d", sprintfSelectPksSQL(tableName, sqlparser.String(whereExpr), pkInfos, batchOrder), limit), nil
+}
+
+// encodeLastPK encodes the PK values of the last row of a batch in the order of the build, to be recorded in its last_pk.
+func encodeLastPK(values []sqltypes.Value) (string, error) {
+	strs := make([]string, 0, len(values))
+	for _, value := range values {
+		strs = append(strs, value.ToString())
+	}
+	encoded, err := json.Marshal(strs)
+	return string(encoded), err
+}
+
+// decodeLastPK decodes the last_pk of a batch into PK values of the types of pkInfos.
+func decodeLastPK(lastPK string, pkInfos []PKInfo) ([]sqltypes.Value, error) {
+	var strs []string
+	if err := json.Unmarshal([]byte(lastPK), &strs); err != nil {
+		return nil, fmt.Errorf("invalid last PK %s: %v", lastPK, err)
+	}
+	if len(strs) != len(pkInfos) {
+		return nil, fmt.Errorf("invalid last PK %s: it has %d values instead of %d", lastPK, len(strs), len(pkInfos))
+	}
+	values := make([]sqltypes.Value, 0, len(strs))
+	for i, str := range strs {
+		values = append(values, sqltypes.MakeTrusted(pkInfos[i].pkType, []byte(str)))
+	}
+	return values, nil
+}
+
+// genPKColsStr joins the names of the PK columns with ",".
+func genPKColsStr(pkInfos []PKInfo) string {
 	pkCols := ""
 	firstPK := true
 	for _, pkInfo := range pkInfos {
@@ -129,9 +338,7 @@ func sprintfSelectPksSQL(tableName, whereStr string, pkInfos []PKInfo) string {
 		pkCols += pkInfo.pkName
 		firstPK = false
 	}
-	selectPksSQL := fmt.Sprintf("select %s from %s where %s order by %s",
-		pkCols, tableName, whereStr, pkCols)
-	return selectPksSQL
+	return pkCols
 }
 
 // the caller don't need to acquire any mutex
@@ -149,19 +356,65 @@ func (jc *JobController) updateJobMessage(ctx context.Context, uuid, message str
 	return err
 }
 
+// updateJobStatus rejects the transitions not listed in jobStatusTransitions,
+// e.g. a canceled job is never set to running by a runner that hasn't noticed the cancellation yet.
 // the caller don't need to acquire any mutex
 func (jc *JobController) updateJobStatus(ctx context.Context, uuid, status, statusSetTime string) (*sqltypes.Result, error) {
 	jc.tableMutex.Lock()
 	defer jc.tableMutex.Unlock()
+	return jc.updateJobStatusLocked(ctx, uuid, status, statusSetTime)
+}
+
+// updateJobStatusLocked is updateJobStatus for the job manager, which already holds jc.tableMutex.
+// acquire jc.tableMutex before calling this function
+func (jc *JobController) updateJobStatusLocked(ctx context.Context, uuid, status, statusSetTime string) (*sqltypes.Result, error) {
+	// the status is read under jc.tableMutex, so that it can't change before it's updated
+	getStatusQuery, err := sqlparser.ParseAndBind(sqlDMLJobGetInfo,
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	qr, err := jc.execQuery(ctx, "", getStatusQuery)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return &sqltypes.Result{}, fmt.Errorf("uuid %s has %d entrys in the table instead of 1", uuid, len(qr.Named().Rows))
+	}
+	currentStatus := qr.Named().Rows[0].AsString("status", "")
+	if !isJobStatusTransitionAllowed(currentStatus, status) {
+		return &sqltypes.Result{}, fmt.Errorf("illegal status transition of job %s from %s to %s", uuid, currentStatus, status)
+	}
 
+	// the update only applies to the status checked above, so that a status written meanwhile
+	// without jc.tableMutex, e.g. by the job controller of another tablet, isn't overwritten.
 	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobUpdateStatus,
 		sqltypes.StringBindVariable(status),
 		sqltypes.StringBindVariable(statusSetTime),
-		sqltypes.StringBindVariable(uuid))
+		sqltypes.StringBindVariable(uuid),
+		sqltypes.StringBindVariable(currentStatus))
 	if err != nil {
 		return &sqltypes.Result{}, err
 	}
-	return jc.execQuery(ctx, "", submitQuery)
+	qr, err = jc.execQuery(ctx, "", submitQuery)
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	// a job set to the same status again may not be changed if it's also set in the same second
+	if qr.RowsAffected == 0 && currentStatus != status {
+		return qr, fmt.Errorf("status of job %s changed from %s before it was set to %s", uuid, currentStatus, status)
+	}
+	return qr, nil
+}
+
+// isJobStatusTransitionAllowed returns true if a job in status from can be set to status to.
+func isJobStatusTransitionAllowed(from, to string) bool {
+	for _, status := range jobStatusTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
 }
 
 // the caller don't need to acquire any mutex
@@ -180,224 +433,997 @@ func (jc *JobController) updateJobPeriodTime(ctx context.Context, uuid, timePeri
 	return jc.execQuery(ctx, "", submitQuery)
 }
 
-// the caller don't need to acquire any mutex
-func (jc *JobController) getIntJobInfo(ctx context.Context, uuid, fieldName string) (int64, error) {
-	jc.tableMutex.Lock()
-	defer jc.tableMutex.Unlock()
+// the caller don't need to acquire any mutex
+func (jc *JobController) getIntJobInfo(ctx context.Context, uuid, fieldName string) (int64, error) {
+	jc.tableMutex.Lock()
+	defer jc.tableMutex.Unlock()
+
+	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobGetInfo,
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return 0, err
+	}
+	qr, err := jc.execQuery(ctx, "", submitQuery)
+	if err != nil {
+		return 0, err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return 0, fmt.Errorf("uuid %s has %d entrys in the table instead of 1", uuid, len(qr.Named().Rows))
+	}
+	return qr.Named().Rows[0].ToInt64(fieldName)
+}
+
+// jobTimeFormat is the format of the start and complete time of a job, which are stored with milliseconds.
+const jobTimeFormat = "2006-01-02 15:04:05.000"
+
+// recordJobStartTime records the time the job starts running for the first time,
+// it's not changed when a paused job is resumed.
+// the caller don't need to acquire any mutex
+func (jc *JobController) recordJobStartTime(ctx context.Context, uuid string, startTime time.Time) error {
+	jc.tableMutex.Lock()
+	defer jc.tableMutex.Unlock()
+
+	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobUpdateStartTime,
+		sqltypes.StringBindVariable(startTime.Format(jobTimeFormat)),
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return err
+	}
+	_, err = jc.execQuery(ctx, "", submitQuery)
+	return err
+}
+
+// recordJobCompletionStats records the complete time of the job, the total duration since it started
+// and the average number of rows affected per second.
+// the caller don't need to acquire any mutex
+func (jc *JobController) recordJobCompletionStats(ctx context.Context, uuid string, completeTime time.Time) error {
+	startTimeStr, err := jc.getStrJobInfo(ctx, uuid, "start_time")
+	if err != nil {
+		return err
+	}
+	startTime := completeTime
+	if startTimeStr != "" {
+		startTime, err = time.ParseInLocation(jobTimeFormat, startTimeStr, time.Local)
+		if err != nil {
+			return err
+		}
+	}
+	batchInfoTableSchema, err := jc.getStrJobInfo(ctx, uuid, "batch_info_table_schema")
+	if err != nil {
+		return err
+	}
+	affectedRows, err := jc.genJobAffectedRows(batchInfoTableSchema, genBatchTableName(uuid), uuid)
+	if err != nil {
+		return err
+	}
+	duration, rowsPerSecond := calcJobThroughput(startTime, completeTime, affectedRows)
+
+	jc.tableMutex.Lock()
+	defer jc.tableMutex.Unlock()
+	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobUpdateCompletionStats,
+		sqltypes.StringBindVariable(completeTime.Format(jobTimeFormat)),
+		sqltypes.Int64BindVariable(duration.Milliseconds()),
+		sqltypes.Float64BindVariable(rowsPerSecond),
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return err
+	}
+	_, err = jc.execQuery(ctx, "", submitQuery)
+	return err
+}
+
+// calcJobThroughput returns the total duration of a job and the average number of rows it affected per second.
+func calcJobThroughput(startTime, completeTime time.Time, affectedRows int64) (time.Duration, float64) {
+	duration := completeTime.Sub(startTime)
+	if duration <= 0 {
+		return 0, 0
+	}
+	return duration, float64(affectedRows) / duration.Seconds()
+}
+
+// the caller don't need to acquire any mutex
+func (jc *JobController) getStrJobInfo(ctx context.Context, uuid, fieldName string) (string, error) {
+	return jc.getStrJobInfoWith(ctx, jc, uuid, fieldName)
+}
+
+// getStrJobInfoWith is getStrJobInfo running its query with exec, e.g. the connection of a batch runner.
+func (jc *JobController) getStrJobInfoWith(ctx context.Context, exec queryExecutor, uuid, fieldName string) (string, error) {
+	jc.tableMutex.Lock()
+	defer jc.tableMutex.Unlock()
+
+	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobGetInfo,
+		sqltypes.StringBindVariable(uuid))
+	if err != nil {
+		return "", err
+	}
+	qr, err := exec.execQuery(ctx, "", submitQuery)
+	if err != nil {
+		return "", err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return "", fmt.Errorf("uuid %s has %d entrys in the table instead of 1", uuid, len(qr.Named().Rows))
+	}
+	return qr.Named().Rows[0].ToString(fieldName)
+}
+
+// getBatchIDToExec returns the first queued batch, or "" if there is none.
+// It reads the batch table every time instead of relying on a max batch id computed in advance,
+// so batches added while the job is running (e.g. by splitting a batch) are executed before the job completes.
+// Batches are ordered by the numeric prefix of their id, then by insertion order, so batch "1-2" runs before batch "2".
+// the caller don't need to acquire any mutex
+func getBatchIDToExec(ctx context.Context, exec queryExecutor, batchTableSchema, batchTableName string) (string, error) {
+	getBatchIDToExecSQL := fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTableName)
+	qr, err := exec.execQuery(ctx, batchTableSchema, getBatchIDToExecSQL)
+	if err != nil {
+		return "", err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return "", nil
+	}
+	return qr.Named().Rows[0].ToString("batch_id")
+}
+
+// todo feat: support concurrency in batch level, pay attention to the operations on batch info table
+func getBatchSQLsByID(ctx context.Context, exec queryExecutor, batchID, batchTableName, tableSchema string) (batchSQL, batchCountSQL string, err error) {
+	getBatchSQLWithTableName := fmt.Sprintf(sqlTemplateGetBatchSQLsByID, batchTableName)
+	query, err := sqlparser.ParseAndBind(getBatchSQLWithTableName,
+		sqltypes.StringBindVariable(batchID))
+	if err != nil {
+		return "", "", err
+	}
+	qr, err := exec.execQuery(ctx, tableSchema, query)
+	if err != nil {
+		return "", "", err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return "", "", errors.New("the len of qr of getting batch sql by ID is not 1")
+	}
+	batchSQL, _ = qr.Named().Rows[0].ToString("batch_sql")
+	batchCountSQL, _ = qr.Named().Rows[0].ToString("batch_count_sql_when_creating_batch")
+	return batchSQL, batchCountSQL, nil
+}
+
+func (jc *JobController) getTablePkInfo(ctx context.Context, tableSchema, tableName string) ([]PKInfo, error) {
+	// 1. get names of PK column
+	submitQuery := fmt.Sprintf(sqlGetTablePk, tableName)
+	qr, err := jc.execQuery(ctx, tableSchema, submitQuery)
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Named().Rows) == 0 {
+		return nil, errors.New("the len of qr of getting pk info is 0")
+	}
+	var pkNames []string
+	for _, row := range qr.Named().Rows {
+		pkNames = append(pkNames, row["Column_name"].ToString())
+	}
+
+	// 2. get types of PK by select one row values of PK
+	pkCols := ""
+	firstPK := true
+	for _, pkName := range pkNames {
+		if !firstPK {
+			pkCols += ","
+		}
+		pkCols += pkName
+		firstPK = false
+	}
+	selectPKCols := fmt.Sprintf(sqlTemplateSelectPKCols, pkCols, tableSchema, tableName)
+	qr, err = jc.execQuery(ctx, "", selectPKCols)
+	if err != nil {
+		return nil, err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return nil, errors.New("the table is empty")
+	}
+
+	var pkInfos []PKInfo
+	for _, pkName := range pkNames {
+		pkInfos = append(pkInfos, PKInfo{pkName: pkName, pkType: qr.Named().Rows[0][pkName].Type()})
+	}
+
+	return pkInfos, nil
+}
+
+func (jc *JobController) getTableColNames(ctx context.Context, tableSchema, tableName string) ([]string, error) {
+	submitQuery, err := sqlparser.ParseAndBind(sqlGetTableColNames,
+		sqltypes.StringBindVariable(tableSchema),
+		sqltypes.StringBindVariable(tableName))
+	if err != nil {
+		return nil, err
+	}
+	qr, err := jc.execQuery(ctx, "", submitQuery)
+	if err != nil {
+		return nil, err
+	}
+	var colNames []string
+	for _, row := range qr.Named().Rows {
+		colNames = append(colNames, row["COLUMN_NAME"].ToString())
+	}
+	return colNames, nil
+}
+
+// getTableGeneratedCols returns the lowercased names of the generated columns of the table,
+// mapped to whether the column is virtual rather than stored.
+func (jc *JobController) getTableGeneratedCols(ctx context.Context, tableSchema, tableName string) (map[string]bool, error) {
+	submitQuery, err := sqlparser.ParseAndBind(sqlGetTableGeneratedCols,
+		sqltypes.StringBindVariable(tableSchema),
+		sqltypes.StringBindVariable(tableName))
+	if err != nil {
+		return nil, err
+	}
+	qr, err := jc.execQuery(ctx, "", submitQuery)
+	if err != nil {
+		return nil, err
+	}
+	generatedCols := make(map[string]bool, len(qr.Rows))
+	for _, row := range qr.Named().Rows {
+		extra := strings.ToUpper(row["EXTRA"].ToString())
+		generatedCols[strings.ToLower(row["COLUMN_NAME"].ToString())] = strings.HasPrefix(extra, "VIRTUAL")
+	}
+	return generatedCols, nil
+}
+
+// checkGeneratedColumns refuses the jobs that assign to a generated column of the table, which MySQL
+// rejects in every batch, and the tables whose primary key has a virtual generated column, since the
+// batches are range scans on the primary key.
+func (jc *JobController) checkGeneratedColumns(ctx context.Context, tableSchema, tableName string, stmt sqlparser.Statement) error {
+	generatedCols, err := jc.getTableGeneratedCols(ctx, tableSchema, tableName)
+	if err != nil {
+		return err
+	}
+	if len(generatedCols) == 0 {
+		return nil
+	}
+	if err = checkAssignGeneratedColumns(stmt, tableName, generatedCols); err != nil {
+		return err
+	}
+	hasVirtualCol := false
+	for _, virtual := range generatedCols {
+		hasVirtualCol = hasVirtualCol || virtual
+	}
+	if !hasVirtualCol {
+		return nil
+	}
+	pkInfos, err := jc.getTablePkInfo(ctx, tableSchema, tableName)
+	if err != nil {
+		return err
+	}
+	for _, pkInfo := range pkInfos {
+		if generatedCols[strings.ToLower(pkInfo.pkName)] {
+			return fmt.Errorf("the primary key column %s of table %s is a virtual generated column, which can't be used to split the table into batches", pkInfo.pkName, tableName)
+		}
+	}
+	return nil
+}
+
+func checkAssignGeneratedColumns(stmt sqlparser.Statement, tableName string, generatedCols map[string]bool) error {
+	update, ok := stmt.(*sqlparser.Update)
+	if !ok {
+		return nil
+	}
+	for _, expr := range update.Exprs {
+		if _, ok := generatedCols[expr.Name.Name.Lowered()]; ok {
+			return fmt.Errorf("the job can't assign to the generated column %s of table %s", expr.Name.Name.String(), tableName)
+		}
+	}
+	return nil
+}
+
+func (jc *JobController) schemaExists(ctx context.Context, tableSchema string) (bool, error) {
+	submitQuery, err := sqlparser.ParseAndBind(sqlGetSchemaExists,
+		sqltypes.StringBindVariable(tableSchema))
+	if err != nil {
+		return false, err
+	}
+	qr, err := jc.execQuery(ctx, "", submitQuery)
+	if err != nil {
+		return false, err
+	}
+	return len(qr.Rows) > 0, nil
+}
+
+func currentBatchIDInc(currentBatchID string) (string, error) {
+	if strings.Contains(currentBatchID, "-") {
+		currentBatchID = strings.Split(currentBatchID, "-")[0]
+	}
+	currentBatchIDInt64, err := strconv.ParseInt(currentBatchID, 10, 64)
+	if err != nil {
+		return "", err
+	}
+	currentBatchIDInt64++
+	return strconv.FormatInt(currentBatchIDInt64, 10), nil
+}
+
+// getIndexCount returns the number of index columns of the table, and the names of its PK columns in order.
+func (jc *JobController) getIndexCount(tableSchema, tableName string) (indexCount int, pkNames []string, err error) {
+	query := fmt.Sprintf(sqlGetIndexCount, tableName)
+
+	ctx := context.Background()
+	qr, err := jc.execQuery(ctx, tableSchema, query)
+	if err != nil {
+		return 0, nil, err
+	}
+	indexCount = len(qr.Named().Rows)
+	if indexCount == 0 {
+		return 0, nil, errors.New("index count is 0")
+	}
+	for _, row := range qr.Named().Rows {
+		if strings.EqualFold(row.AsString("Key_name", ""), "primary") {
+			pkNames = append(pkNames, row.AsString("Column_name", ""))
+		}
+	}
+	return indexCount, pkNames, nil
+}
+
+func genNewBatchID(batchID string) (newBatchID string, err error) {
+	if strings.Contains(batchID, "-") {
+		parts := strings.Split(batchID, "-")
+		num, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return "", err
+		}
+		newBatchID = fmt.Sprintf("%s-%d", parts[0], num+1)
+	} else {
+		num, err := strconv.ParseInt(batchID, 10, 64)
+		if err != nil {
+			return "", err
+		}
+		newBatchID = fmt.Sprintf("%d-2", num)
+	}
+	return newBatchID, nil
+}
+
+func updateBatchStatus(exec queryExecutor, batchTableSchema, batchTableName, status, batchID, errStr string) (err error) {
+	updateBatchStatusAndAffectedRowsSQL := fmt.Sprintf(sqlTempalteUpdateBatchStatusAndAffectedRows, batchTableName)
+	query, err := sqlparser.ParseAndBind(updateBatchStatusAndAffectedRowsSQL,
+		sqltypes.StringBindVariable(status+": "+errStr),
+		sqltypes.Int64BindVariable(0),
+		sqltypes.StringBindVariable(batchID))
+	if err != nil {
+		return err
+	}
+	_, err = exec.execQuery(context.Background(), batchTableSchema, query)
+	return err
+}
+
+func (args *JobArgs) initArgsByQueryResult(row sqltypes.RowNamedValues) {
+	args.uuid = row["job_uuid"].ToString()
+	args.tableSchema = row["table_schema"].ToString()
+	args.table = row["table_name"].ToString()
+	args.batchInfoTableSchema = row["batch_info_table_schema"].ToString()
+	args.batchInfoTable = row["batch_info_table_name"].ToString()
+	args.failPolicy = row["fail_policy"].ToString()
+	args.status = row["status"].ToString()
+	args.statusSetTime = row["status_set_time"].ToString()
+	args.timeZone = row["time_zone"].ToString()
+	args.dmlSQL = row["dml_sql"].ToString()
+
+	batchInterval, _ := row["batch_interval_in_ms"].ToInt64()
+	batchSize, _ := row["batch_size"].ToInt64()
+	args.batchInterval = batchInterval
+	args.batchSize = batchSize
+
+	runningTimePeriodStart := row["running_time_period_start"].ToString()
+	runningTimePeriodEnd := row["running_time_period_end"].ToString()
+	runningTimePeriodTimeZone := row["running_time_period_time_zone"].ToString()
+
+	args.timePeriodStart, args.timePeriodEnd = getRunningPeriodTime(runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone)
+
+	postponeLaunch, _ := row["postpone_launch"].ToInt64()
+	args.postponeLaunch = postponeLaunch == 1
+
+	if launchAtStr := row["launch_at"].ToString(); launchAtStr != "" {
+		launchAt, err := time.Parse(time.RFC3339, launchAtStr)
+		if err == nil {
+			args.launchAt = &launchAt
+		}
+	}
+
+	args.archiveTable = row["archive_table"].ToString()
+	args.isolationLevel = row["isolation_level"].ToString()
+	args.checksumColumns = row["checksum_columns"].ToString()
+	args.maxReplicationLag, _ = row["max_replication_lag"].ToFloat64()
+	args.batchOrder = row["batch_order"].ToString()
+	args.versionColumn = row["version_column"].ToString()
+	args.versionSnapshot = row["version_snapshot"].ToString()
+}
+
+// getLaunchAt returns the value of the DML_LAUNCH_AT directive of the job SQL,
+// which should be in RFC3339 format, e.g. '2006-01-02T15:04:05+08:00'.
+// It returns an empty string if the directive is not set.
+func getLaunchAt(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	launchAt := stripApostrophe(sqlparser.GetDMLJobLaunchAt(stmt))
+	if launchAt == "" {
+		return "", nil
+	}
+	if _, err := time.Parse(time.RFC3339, launchAt); err != nil {
+		return "", fmt.Errorf("check the format, the launch time should be like '2006-01-02T15:04:05+08:00': %v", err)
+	}
+	return launchAt, nil
+}
+
+// getArchiveTable returns the value of the DML_ARCHIVE_TABLE directive of the job SQL,
+// which is only supported by DELETE jobs. It returns an empty string if the directive is not set.
+func getArchiveTable(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	archiveTable := stripApostrophe(sqlparser.GetDMLJobArchiveTable(stmt))
+	if archiveTable == "" {
+		return "", nil
+	}
+	if _, ok := stmt.(*sqlparser.Delete); !ok {
+		return "", errors.New("the archive table can only be set for DELETE jobs")
+	}
+	return archiveTable, nil
+}
+
+// isolationLevels are the transaction isolation levels the batches of a job can run in.
+var isolationLevels = map[string]bool{
+	"READ UNCOMMITTED": true,
+	"READ COMMITTED":   true,
+	"REPEATABLE READ":  true,
+	"SERIALIZABLE":     true,
+}
+
+// getIsolationLevel returns the value of the DML_ISOLATION_LEVEL directive of the job SQL,
+// normalized to the form used by SET TRANSACTION, e.g. 'read_committed' becomes 'READ COMMITTED'.
+// It returns an empty string if the directive is not set.
+func getIsolationLevel(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	isolationLevel := stripApostrophe(sqlparser.GetDMLJobIsolationLevel(stmt))
+	if isolationLevel == "" {
+		return "", nil
+	}
+	normalized := strings.ToUpper(strings.NewReplacer("_", " ", "-", " ").Replace(isolationLevel))
+	if !isolationLevels[normalized] {
+		return "", fmt.Errorf("invalid isolation level %s, it should be one of 'read_uncommitted', 'read_committed', 'repeatable_read' or 'serializable'", isolationLevel)
+	}
+	return normalized, nil
+}
+
+// getChecksumColumns returns the value of the DML_CHECKSUM_COLUMNS directive of the job SQL,
+// normalized to a comma separated list of columns, e.g. 'c1, c2' becomes 'c1,c2'.
+// It returns an empty string if the directive is not set.
+func getChecksumColumns(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	checksumColumns := stripApostrophe(sqlparser.GetDMLJobChecksumColumns(stmt))
+	if checksumColumns == "" {
+		return "", nil
+	}
+	var columns []string
+	for _, column := range strings.Split(checksumColumns, ",") {
+		column = strings.TrimSpace(column)
+		if column == "" {
+			return "", fmt.Errorf("invalid checksum columns %s, it should be a comma separated list of columns", checksumColumns)
+		}
+		columns = append(columns, column)
+	}
+	return strings.Join(columns, ","), nil
+}
+
+// getMaxReplicationLag returns the value of the DML_MAX_REPLICATION_LAG directive of the job SQL,
+// which is a replication lag in seconds, e.g. '0.5'. It returns 0 if the directive is not set.
+func getMaxReplicationLag(sql string) (float64, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return 0, err
+	}
+	maxReplicationLagStr := stripApostrophe(sqlparser.GetDMLJobMaxReplicationLag(stmt))
+	if maxReplicationLagStr == "" {
+		return 0, nil
+	}
+	maxReplicationLag, err := strconv.ParseFloat(maxReplicationLagStr, 64)
+	if err != nil || maxReplicationLag <= 0 {
+		return 0, fmt.Errorf("invalid max replication lag %s, it should be a positive number of seconds, e.g. '0.5'", maxReplicationLagStr)
+	}
+	return maxReplicationLag, nil
+}
+
+// getNotifyURL returns the value of the DML_NOTIFY_URL directive of the job SQL,
+// which is an http or https URL, e.g. 'http://host:8080/jobs'. It returns "" if the directive is not set.
+func getNotifyURL(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	notifyURL := stripApostrophe(sqlparser.GetDMLJobNotifyURL(stmt))
+	if notifyURL == "" {
+		return "", nil
+	}
+	u, err := url.Parse(notifyURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", fmt.Errorf("invalid notify url %s, it should be an http or https url, e.g. 'http://host:8080/jobs'", notifyURL)
+	}
+	return notifyURL, nil
+}
+
+// validateBatchParams checks the batch interval and batch size a job is submitted with are within
+// the bounds set by the flags, 0 meaning the default is used.
+func validateBatchParams(batchIntervalInMs, batchSize int64) error {
+	if batchSize != 0 && (batchSize < int64(minBatchSize) || batchSize > int64(maxBatchSize)) {
+		return fmt.Errorf("invalid batch size %d, it should be between %d and %d, or 0 to use the default %d. "+
+			"Larger jobs should be split into more batches rather than bigger ones", batchSize, minBatchSize, maxBatchSize, defaultBatchSize)
+	}
+	if batchIntervalInMs != 0 && (batchIntervalInMs < int64(minBatchInterval) || batchIntervalInMs > int64(maxBatchInterval)) {
+		return fmt.Errorf("invalid batch interval %dms, it should be between %dms and %dms, or 0 to use the default %dms. "+
+			"Use the throttler to slow a job down further", batchIntervalInMs, minBatchInterval, maxBatchInterval, defaultBatchInterval)
+	}
+	return nil
+}
+
+// getBatchOrder returns the value of the DML_BATCH_ORDER directive of the job SQL,
+// which is 'asc' or 'desc'. It returns "" if the directive is not set.
+func getBatchOrder(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	batchOrder := strings.ToLower(stripApostrophe(sqlparser.GetDMLJobBatchOrder(stmt)))
+	if batchOrder != "" && batchOrder != batchOrderAsc && batchOrder != batchOrderDesc {
+		return "", fmt.Errorf("invalid batch order %s, it should be 'asc' or 'desc'", batchOrder)
+	}
+	return batchOrder, nil
+}
+
+// getVersionColumn returns the value of the DML_VERSION_COLUMN directive of the job SQL,
+// which is only supported by UPDATE jobs. It returns "" if the directive is not set.
+func getVersionColumn(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	versionColumn := strings.TrimSpace(stripApostrophe(sqlparser.GetDMLJobVersionColumn(stmt)))
+	if versionColumn == "" {
+		return "", nil
+	}
+	if _, ok := stmt.(*sqlparser.Update); !ok {
+		return "", errors.New("the version column can only be set for UPDATE jobs")
+	}
+	return versionColumn, nil
+}
+
+// getTag returns the value of the DML_TAG directive of the job SQL, which is a single word
+// of up to maxTagLength characters, e.g. ticket-1024. It returns "" if the directive is not set.
+func getTag(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	tag := stripApostrophe(sqlparser.GetDMLJobTag(stmt))
+	if len(tag) > maxTagLength || strings.ContainsAny(tag, " \t\n'") {
+		return "", fmt.Errorf("invalid tag %s, it should be a single word of up to %d characters, e.g. ticket-1024", tag, maxTagLength)
+	}
+	return tag, nil
+}
+
+// addJobDirectives returns the job SQL with the directives setting the given options,
+// so that submitting it again sets the options stored in the job table apart from the SQL.
+func addJobDirectives(sql, archiveTable, isolationLevel, checksumColumns, maxReplicationLag, notifyURL, batchOrder, versionColumn, tag string) (string, error) {
+	var directives []string
+	if archiveTable != "" {
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLArchiveTable), archiveTable))
+	}
+	if isolationLevel != "" {
+		// the isolation level is stored like 'READ COMMITTED', but a directive value can't contain spaces.
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLIsolationLevel), strings.ReplaceAll(isolationLevel, " ", "_")))
+	}
+	if checksumColumns != "" {
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLChecksumColumns), checksumColumns))
+	}
+	if maxReplicationLag != "" {
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLMaxReplicationLag), maxReplicationLag))
+	}
+	if notifyURL != "" {
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLNotifyURL), notifyURL))
+	}
+	if batchOrder != "" {
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLBatchOrder), batchOrder))
+	}
+	if versionColumn != "" {
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLVersionColumn), versionColumn))
+	}
+	if tag != "" {
+		directives = append(directives, fmt.Sprintf("%s=%s", strings.ToLower(sqlparser.DirectiveDMLTag), tag))
+	}
+	if len(directives) == 0 {
+		return sql, nil
+	}
+	stmt, err := sqlparser.Parse(sql)
+	if err != nil {
+		return "", err
+	}
+	comments := sqlparser.Comments{fmt.Sprintf("/*vt+ %s */", strings.Join(directives, " "))}.Parsed()
+	switch s := stmt.(type) {
+	case *sqlparser.Delete:
+		s.Comments = comments
+	case *sqlparser.Update:
+		s.Comments = comments
+	default:
+		return "", fmt.Errorf("the job SQL should be a DELETE or UPDATE: %s", sql)
+	}
+	return sqlparser.String(stmt), nil
+}
+
+// checkChecksumColumns makes sure the checksum columns of a job are columns of its table.
+func (jc *JobController) checkChecksumColumns(ctx context.Context, tableSchema, tableName, checksumColumns string) error {
+	colNames, err := jc.getTableColNames(ctx, tableSchema, tableName)
+	if err != nil {
+		return err
+	}
+	tableColumns := make(map[string]bool, len(colNames))
+	for _, colName := range colNames {
+		tableColumns[strings.ToLower(colName)] = true
+	}
+	for _, column := range strings.Split(checksumColumns, ",") {
+		if !tableColumns[strings.ToLower(column)] {
+			return fmt.Errorf("table %s has no column %s to compute the checksum of", tableName, column)
+		}
+	}
+	return nil
+}
+
+// checkVersionColumn makes sure the version column of a job is a column of its table.
+func (jc *JobController) checkVersionColumn(ctx context.Context, tableSchema, tableName, versionColumn string) error {
+	colNames, err := jc.getTableColNames(ctx, tableSchema, tableName)
+	if err != nil {
+		return err
+	}
+	for _, colName := range colNames {
+		if strings.EqualFold(colName, versionColumn) {
+			return nil
+		}
+	}
+	return fmt.Errorf("table %s has no version column %s", tableName, versionColumn)
+}
+
+// getVersionSnapshot returns the highest value of the version column of a job when it's submitted,
+// the rows whose version advances past it are modified since then. It returns "" if the table has no
+// row with a version.
+func (jc *JobController) getVersionSnapshot(ctx context.Context, tableSchema, tableName, versionColumn string) (string, error) {
+	qr, err := jc.execQuery(ctx, tableSchema, fmt.Sprintf(sqlTemplateVersionSnapshot,
+		sqlparser.String(sqlparser.NewIdentifierCI(versionColumn)), sqlparser.String(sqlparser.NewIdentifierCS(tableName))))
+	if err != nil {
+		return "", err
+	}
+	if len(qr.Named().Rows) != 1 {
+		return "", errors.New("the len of qr of version snapshot is not 1")
+	}
+	return qr.Named().Rows[0]["version_snapshot"].ToString(), nil
+}
+
+// genVersionedBatchSQL adds to the WHERE clause of an UPDATE batch SQL the predicate that the version column
+// hasn't advanced past versionSnapshot, so that the rows modified since the job was submitted are not overwritten.
+// It also returns the SQL counting the rows of the batch skipped because of the predicate.
+// The rows without a version are never considered modified.
+func genVersionedBatchSQL(batchSQL, versionColumn, versionSnapshot string) (versionedSQL, skippedRowsSQL string, err error) {
+	stmt, err := sqlparser.Parse(batchSQL)
+	if err != nil {
+		return "", "", err
+	}
+	update, ok := stmt.(*sqlparser.Update)
+	if !ok || update.Where == nil {
+		return "", "", errors.New("the version column can only be checked by the batches of UPDATE jobs")
+	}
+	col := sqlparser.String(sqlparser.NewIdentifierCI(versionColumn))
+	predicateStr := fmt.Sprintf("%s is null", col)
+	if versionSnapshot != "" {
+		predicateStr = fmt.Sprintf("%s is null or %s <= %s", col, col, sqlparser.String(sqlparser.NewStrLiteral(versionSnapshot)))
+	}
+	predicate, err := genExprNodeFromStr(predicateStr)
+	if err != nil {
+		return "", "", err
+	}
+	batchWhere := update.Where.Expr
+
+	skippedWhere := sqlparser.NewWhere(sqlparser.WhereClause, &sqlparser.AndExpr{Left: batchWhere, Right: &sqlparser.NotExpr{Expr: predicate}})
+	skippedRowsSQL = fmt.Sprintf(sqlTemplateBatchSkippedRows, sqlparser.String(update.TableExprs), sqlparser.String(skippedWhere))
 
-	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobGetInfo,
-		sqltypes.StringBindVariable(uuid))
+	update.Where = sqlparser.NewWhere(sqlparser.WhereClause, &sqlparser.AndExpr{Left: batchWhere, Right: predicate})
+	return sqlparser.String(update), skippedRowsSQL, nil
+}
+
+// genBatchChecksumSQL generates the SQL that computes the checksum of checksumColumns over the rows of a batch,
+// i.e. the rows matching the WHERE clause of the batch SQL. The checksum doesn't depend on the order of the rows,
+// so that it can be compared across runs. The NULL values are taken into account, unlike by CONCAT_WS.
+func genBatchChecksumSQL(batchSQL, checksumColumns string) (string, error) {
+	stmt, err := sqlparser.Parse(batchSQL)
 	if err != nil {
-		return 0, err
+		return "", err
 	}
-	qr, err := jc.execQuery(ctx, "", submitQuery)
-	if err != nil {
-		return 0, err
+	var tableExprs sqlparser.TableExprs
+	var where *sqlparser.Where
+	switch stmt := stmt.(type) {
+	case *sqlparser.Update:
+		tableExprs, where = stmt.TableExprs, stmt.Where
+	case *sqlparser.Delete:
+		tableExprs, where = stmt.TableExprs, stmt.Where
+	default:
+		return "", errors.New("the checksum can only be computed for UPDATE and DELETE jobs")
 	}
-	if len(qr.Named().Rows) != 1 {
-		return 0, fmt.Errorf("uuid %s has %d entrys in the table instead of 1", uuid, len(qr.Named().Rows))
+	var cols, isNulls []string
+	for _, column := range strings.Split(checksumColumns, ",") {
+		col := sqlparser.String(sqlparser.NewIdentifierCI(column))
+		cols = append(cols, col)
+		isNulls = append(isNulls, fmt.Sprintf("isnull(%s)", col))
 	}
-	return qr.Named().Rows[0].ToInt64(fieldName)
+	return fmt.Sprintf(sqlTemplateBatchChecksum, strings.Join(cols, ", "), strings.Join(isNulls, ", "),
+		sqlparser.String(tableExprs), sqlparser.String(where)), nil
 }
 
-// the caller don't need to acquire any mutex
-func (jc *JobController) getStrJobInfo(ctx context.Context, uuid, fieldName string) (string, error) {
-	jc.tableMutex.Lock()
-	defer jc.tableMutex.Unlock()
-
-	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobGetInfo,
-		sqltypes.StringBindVariable(uuid))
+// getPKRange returns the values of the DML_PK_RANGE_START and DML_PK_RANGE_END directives of the job SQL,
+// they're empty if not set.
+func getPKRange(sql string) (pkRangeStart, pkRangeEnd string, err error) {
+	stmt, err := sqlparser.Parse(sql)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
-	qr, err := jc.execQuery(ctx, "", submitQuery)
+	pkRangeStart, pkRangeEnd = sqlparser.GetDMLJobPKRange(stmt)
+	return stripApostrophe(pkRangeStart), stripApostrophe(pkRangeEnd), nil
+}
+
+// limitToPKRange adds the condition that the primary key is in [pkRangeStart, pkRangeEnd] to the WHERE clause
+// of the job SQL, so that the batches generated from it stay in this range. An empty bound is open.
+// The table must have a single column primary key, and the bounds must be values of its type.
+func limitToPKRange(sql string, pkInfos []PKInfo, pkRangeStart, pkRangeEnd string) (string, error) {
+	if len(pkInfos) != 1 {
+		return "", errors.New("the PK range is only supported for tables with a single column primary key")
+	}
+	pkInfo := pkInfos[0]
+	_, _, stmt, err := parseDML(sql)
 	if err != nil {
 		return "", err
 	}
-	if len(qr.Named().Rows) != 1 {
-		return "", fmt.Errorf("uuid %s has %d entrys in the table instead of 1", uuid, len(qr.Named().Rows))
+	var where *sqlparser.Where
+	switch stmt := stmt.(type) {
+	case *sqlparser.Update:
+		where = stmt.Where
+	case *sqlparser.Delete:
+		where = stmt.Where
+	}
+
+	var start, end sqltypes.Value
+	conditions := []sqlparser.Expr{where.Expr}
+	for _, bound := range []struct {
+		val      string
+		operator sqlparser.ComparisonExprOperator
+		value    *sqltypes.Value
+	}{
+		{pkRangeStart, sqlparser.GreaterEqualOp, &start},
+		{pkRangeEnd, sqlparser.LessEqualOp, &end},
+	} {
+		if bound.val == "" {
+			continue
+		}
+		value, err := sqltypes.NewValue(pkInfo.pkType, []byte(bound.val))
+		if err != nil {
+			return "", fmt.Errorf("invalid PK range bound %s for the primary key %s of type %s: %v", bound.val, pkInfo.pkName, pkInfo.pkType, err)
+		}
+		*bound.value = value
+		var literal strings.Builder
+		value.EncodeSQLStringBuilder(&literal)
+		valueExpr, err := sqlparser.ParseExpr(literal.String())
+		if err != nil {
+			return "", err
+		}
+		conditions = append(conditions, &sqlparser.ComparisonExpr{
+			Operator: bound.operator,
+			Left:     sqlparser.NewColName(pkInfo.pkName),
+			Right:    valueExpr,
+		})
+	}
+	if !start.IsNull() && !end.IsNull() && start.IsIntegral() {
+		greater, err := greaterIntegral(start, end)
+		if err != nil {
+			return "", err
+		}
+		if greater {
+			return "", fmt.Errorf("the PK range start %s is greater than the end %s", pkRangeStart, pkRangeEnd)
+		}
 	}
-	return qr.Named().Rows[0].ToString(fieldName)
+	where.Expr = sqlparser.AndExpressions(conditions...)
+	return sqlparser.String(stmt), nil
 }
 
-// the caller don't need to acquire any mutex
-func (jc *JobController) getBatchIDToExec(ctx context.Context, batchTableSchema, batchTableName string) (string, error) {
-	getBatchIDToExecSQL := fmt.Sprintf(sqlTemplateGetBatchIDToExec, batchTableName)
-	qr, err := jc.execQuery(ctx, batchTableSchema, getBatchIDToExecSQL)
+// greaterIntegral returns whether v1 is greater than v2, both being integral values of the same type.
+func greaterIntegral(v1, v2 sqltypes.Value) (bool, error) {
+	if v1.IsSigned() {
+		i1, err := v1.ToInt64()
+		if err != nil {
+			return false, err
+		}
+		i2, err := v2.ToInt64()
+		if err != nil {
+			return false, err
+		}
+		return i1 > i2, nil
+	}
+	u1, err := v1.ToUint64()
 	if err != nil {
-		return "", err
+		return false, err
 	}
-	if len(qr.Named().Rows) != 1 {
-		return "", nil
+	u2, err := v2.ToUint64()
+	if err != nil {
+		return false, err
 	}
-	return qr.Named().Rows[0].ToString("batch_id")
+	return u1 > u2, nil
 }
 
-// todo feat: support concurrency in batch level, pay attention to the operations on batch info table
-func (jc *JobController) getBatchSQLsByID(ctx context.Context, batchID, batchTableName, tableSchema string) (batchSQL, batchCountSQL string, err error) {
-	getBatchSQLWithTableName := fmt.Sprintf(sqlTemplateGetBatchSQLsByID, batchTableName)
-	query, err := sqlparser.ParseAndBind(getBatchSQLWithTableName,
-		sqltypes.StringBindVariable(batchID))
+// getConfirmToken returns the value of the DML_CONFIRM_TOKEN directive of the job SQL,
+// it returns an empty string if the directive is not set.
+func getConfirmToken(sql string) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
 	if err != nil {
-		return "", "", err
+		return "", err
 	}
-	qr, err := jc.execQuery(ctx, tableSchema, query)
+	return stripApostrophe(sqlparser.GetDMLJobConfirmToken(stmt)), nil
+}
+
+// getAllowFullTable returns whether the DML_ALLOW_FULL_TABLE directive of the job SQL is set.
+func getAllowFullTable(sql string) (bool, error) {
+	stmt, err := sqlparser.Parse(sql)
 	if err != nil {
-		return "", "", err
-	}
-	if len(qr.Named().Rows) != 1 {
-		return "", "", errors.New("the len of qr of getting batch sql by ID is not 1")
+		return false, err
 	}
-	batchSQL, _ = qr.Named().Rows[0].ToString("batch_sql")
-	batchCountSQL, _ = qr.Named().Rows[0].ToString("batch_count_sql_when_creating_batch")
-	return batchSQL, batchCountSQL, nil
+	return sqlparser.GetDMLJobAllowFullTable(stmt), nil
 }
 
-func (jc *JobController) getTablePkInfo(ctx context.Context, tableSchema, tableName string) ([]PKInfo, error) {
-	// 1. get names of PK column
-	submitQuery := fmt.Sprintf(sqlGetTablePk, tableName)
-	qr, err := jc.execQuery(ctx, tableSchema, submitQuery)
+// checkFullTable refuses a job SQL without a where clause, which would affect the whole table,
+// unless allowFullTable is set. In this case, it adds an always true where clause to the SQL,
+// since the batches are generated from the where clause of the job.
+func checkFullTable(sql string, allowFullTable bool) (string, error) {
+	stmt, err := sqlparser.Parse(sql)
 	if err != nil {
-		return nil, err
+		return "", err
 	}
-	if len(qr.Named().Rows) == 0 {
-		return nil, errors.New("the len of qr of getting pk info is 0")
+	var where **sqlparser.Where
+	switch s := stmt.(type) {
+	case *sqlparser.Delete:
+		where = &s.Where
+	case *sqlparser.Update:
+		where = &s.Where
+	default:
+		return sql, nil
 	}
-	var pkNames []string
-	for _, row := range qr.Named().Rows {
-		pkNames = append(pkNames, row["Column_name"].ToString())
+	if *where != nil {
+		return sql, nil
+	}
+	if !allowFullTable {
+		return "", fmt.Errorf("the SQL has no where clause and would affect the whole table, set %s=true if it's intended",
+			strings.ToLower(sqlparser.DirectiveDMLAllowFullTable))
 	}
+	*where = sqlparser.NewWhere(sqlparser.WhereClause, sqlparser.BoolVal(true))
+	return sqlparser.String(stmt), nil
+}
 
-	// 2. get types of PK by select one row values of PK
-	pkCols := ""
-	firstPK := true
-	for _, pkName := range pkNames {
-		if !firstPK {
-			pkCols += ","
-		}
-		pkCols += pkName
-		firstPK = false
+// estimateJobAffectedRows counts the rows matching the where clause of any statement of the job SQL.
+func (jc *JobController) estimateJobAffectedRows(sql, tableSchema string) (int64, error) {
+	tableName, whereExprs, _, err := parseJobDMLs(sql)
+	if err != nil {
+		return 0, err
 	}
-	selectPKCols := fmt.Sprintf(sqlTemplateSelectPKCols, pkCols, tableSchema, tableName)
-	qr, err = jc.execQuery(ctx, "", selectPKCols)
+	qr, err := jc.execQuery(jc.ctx, tableSchema, genCountSQL(tableName, sqlparser.String(orWhereExprs(whereExprs))))
 	if err != nil {
-		return nil, err
+		return 0, err
 	}
 	if len(qr.Named().Rows) != 1 {
-		return nil, errors.New("the table is empty")
-	}
-
-	var pkInfos []PKInfo
-	for _, pkName := range pkNames {
-		pkInfos = append(pkInfos, PKInfo{pkName: pkName, pkType: qr.Named().Rows[0][pkName].Type()})
+		return 0, errors.New("failed to estimate the affected rows of the job")
 	}
-
-	return pkInfos, nil
+	return qr.Named().Row().ToInt64("count_rows")
 }
 
-func (jc *JobController) getTableColNames(ctx context.Context, tableSchema, tableName string) ([]string, error) {
-	submitQuery, err := sqlparser.ParseAndBind(sqlGetTableColNames,
+func (jc *JobController) getTableColTypes(ctx context.Context, tableSchema, tableName string) (colNames []string, colTypes map[string]string, err error) {
+	query, err := sqlparser.ParseAndBind(sqlGetTableColTypes,
 		sqltypes.StringBindVariable(tableSchema),
 		sqltypes.StringBindVariable(tableName))
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	qr, err := jc.execQuery(ctx, "", submitQuery)
+	qr, err := jc.execQuery(ctx, "", query)
 	if err != nil {
-		return nil, err
+		return nil, nil, err
 	}
-	var colNames []string
+	colTypes = make(map[string]string)
 	for _, row := range qr.Named().Rows {
-		colNames = append(colNames, row["COLUMN_NAME"].ToString())
+		colName := row["COLUMN_NAME"].ToString()
+		colNames = append(colNames, colName)
+		colTypes[strings.ToLower(colName)] = strings.ToLower(row["COLUMN_TYPE"].ToString())
 	}
-	return colNames, nil
+	return colNames, colTypes, nil
 }
 
-func currentBatchIDInc(currentBatchID string) (string, error) {
-	if strings.Contains(currentBatchID, "-") {
-		currentBatchID = strings.Split(currentBatchID, "-")[0]
+// checkArchiveTable checks that the archive table is compatible with the table of a DELETE job,
+// i.e. it is another table in the same schema which has all the columns of the table with the same types.
+// The archive table may have extra columns, as long as they can be filled with their default values.
+func (jc *JobController) checkArchiveTable(ctx context.Context, tableSchema, tableName, archiveTable string) error {
+	if strings.EqualFold(tableName, archiveTable) {
+		return errors.New("the archive table must be different from the table of the job")
 	}
-	currentBatchIDInt64, err := strconv.ParseInt(currentBatchID, 10, 64)
+	colNames, colTypes, err := jc.getTableColTypes(ctx, tableSchema, tableName)
 	if err != nil {
-		return "", err
+		return err
 	}
-	currentBatchIDInt64++
-	return strconv.FormatInt(currentBatchIDInt64, 10), nil
-}
-
-func (jc *JobController) getIndexCount(tableSchema, tableName string) (indexCount int, err error) {
-	query := fmt.Sprintf(sqlGetIndexCount, tableName)
-
-	ctx := context.Background()
-	qr, err := jc.execQuery(ctx, tableSchema, query)
+	_, archiveColTypes, err := jc.getTableColTypes(ctx, tableSchema, archiveTable)
 	if err != nil {
-		return 0, err
+		return err
 	}
-	indexCount = len(qr.Named().Rows)
-	if indexCount == 0 {
-		return 0, errors.New("index count is 0")
+	if len(archiveColTypes) == 0 {
+		return fmt.Errorf("archive table %s.%s does not exist", tableSchema, archiveTable)
 	}
-	return indexCount, nil
-}
-
-func genNewBatchID(batchID string) (newBatchID string, err error) {
-	if strings.Contains(batchID, "-") {
-		parts := strings.Split(batchID, "-")
-		num, err := strconv.ParseInt(parts[1], 10, 64)
-		if err != nil {
-			return "", err
+	for _, colName := range colNames {
+		colName = strings.ToLower(colName)
+		archiveColType, ok := archiveColTypes[colName]
+		if !ok {
+			return fmt.Errorf("archive table %s has no column %s", archiveTable, colName)
 		}
-		newBatchID = fmt.Sprintf("%s-%d", parts[0], num+1)
-	} else {
-		num, err := strconv.ParseInt(batchID, 10, 64)
-		if err != nil {
-			return "", err
+		if archiveColType != colTypes[colName] {
+			return fmt.Errorf("the type of column %s is %s in archive table %s, but %s in table %s",
+				colName, archiveColType, archiveTable, colTypes[colName], tableName)
 		}
-		newBatchID = fmt.Sprintf("%d-2", num)
 	}
-	return newBatchID, nil
+	return nil
 }
 
-func (jc *JobController) updateBatchStatus(batchTableSchema, batchTableName, status, batchID, errStr string) (err error) {
-	updateBatchStatusAndAffectedRowsSQL := fmt.Sprintf(sqlTempalteUpdateBatchStatusAndAffectedRows, batchTableName)
-	query, err := sqlparser.ParseAndBind(updateBatchStatusAndAffectedRowsSQL,
-		sqltypes.StringBindVariable(status+": "+errStr),
-		sqltypes.Int64BindVariable(0),
-		sqltypes.StringBindVariable(batchID))
+// genArchiveBatchSQL generates the SQL that copies the rows a DELETE batch is going to delete into the archive table.
+func genArchiveBatchSQL(batchSQL, archiveTable string, colNames []string) (string, error) {
+	stmt, err := sqlparser.Parse(batchSQL)
 	if err != nil {
-		return err
+		return "", err
 	}
-	_, err = jc.execQuery(context.Background(), batchTableSchema, query)
-	return err
+	deleteStmt, ok := stmt.(*sqlparser.Delete)
+	if !ok {
+		return "", errors.New("only the rows of DELETE jobs can be archived")
+	}
+	if len(colNames) == 0 {
+		return "", errors.New("the table to archive has no columns")
+	}
+	cols := make([]string, 0, len(colNames))
+	for _, colName := range colNames {
+		cols = append(cols, sqlparser.String(sqlparser.NewIdentifierCI(colName)))
+	}
+	colsStr := strings.Join(cols, ",")
+	return fmt.Sprintf(sqlTemplateArchiveBatch, sqlparser.String(sqlparser.NewIdentifierCS(archiveTable)),
+		colsStr, colsStr, sqlparser.String(deleteStmt.TableExprs), sqlparser.String(deleteStmt.Where)), nil
 }
 
-func (args *JobArgs) initArgsByQueryResult(row sqltypes.RowNamedValues) {
-	args.uuid = row["job_uuid"].ToString()
-	args.tableSchema = row["table_schema"].ToString()
-	args.table = row["table_name"].ToString()
-	args.batchInfoTable = row["batch_info_table_name"].ToString()
-	args.failPolicy = row["fail_policy"].ToString()
-	args.status = row["status"].ToString()
-	args.statusSetTime = row["status_set_time"].ToString()
-	args.timeZone = row["time_zone"].ToString()
-	args.dmlSQL = row["dml_sql"].ToString()
-
-	batchInterval, _ := row["batch_interval_in_ms"].ToInt64()
-	batchSize, _ := row["batch_size"].ToInt64()
-	args.batchInterval = batchInterval
-	args.batchSize = batchSize
-
-	runningTimePeriodStart := row["running_time_period_start"].ToString()
-	runningTimePeriodEnd := row["running_time_period_end"].ToString()
-	runningTimePeriodTimeZone := row["running_time_period_time_zone"].ToString()
-
-	args.timePeriodStart, args.timePeriodEnd = getRunningPeriodTime(runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone)
-
-	postponeLaunch, _ := row["postpone_launch"].ToInt64()
-	args.postponeLaunch = postponeLaunch == 1
+// maxBatchColumnLength is the maximum length in bytes of the TEXT columns of the batch table.
+const maxBatchColumnLength = 65535
+
+// checkBatchColumnsLength returns an error if one of the values to store in the TEXT columns
+// of the batch table is too long, MySQL would otherwise reject or, without a strict sql_mode,
+// silently truncate it.
+// columnsAndValues alternates the names of the columns and their values.
+func checkBatchColumnsLength(batchID string, columnsAndValues ...string) error {
+	for i := 0; i+1 < len(columnsAndValues); i += 2 {
+		name, value := columnsAndValues[i], columnsAndValues[i+1]
+		if len(value) > maxBatchColumnLength {
+			return fmt.Errorf("the %s of batch %s is %d bytes long, more than the %d bytes the batch table can store, use a shorter WHERE clause",
+				name, batchID, len(value), maxBatchColumnLength)
+		}
+	}
+	return nil
 }
 
 func (jc *JobController) insertBatchInfoTableEntry(ctx context.Context, tableSchema, batchTableName, currentBatchID, batchSQL, countSQL, batchStartStr, batchEndStr string, batchSize int64) (err error) {
+	if err := checkBatchColumnsLength(currentBatchID,
+		"batch_sql", batchSQL,
+		"batch_count_sql_when_creating_batch", countSQL,
+		"batch_begin", batchStartStr,
+		"batch_end", batchEndStr); err != nil {
+		return err
+	}
 	insertBatchSQLWithTableName := fmt.Sprintf(sqlTemplateInsertBatchEntry, batchTableName)
 	insertBatchSQLQuery, err := sqlparser.ParseAndBind(insertBatchSQLWithTableName,
 		sqltypes.StringBindVariable(currentBatchID),
@@ -416,11 +1442,58 @@ func (jc *JobController) insertBatchInfoTableEntry(ctx context.Context, tableSch
 	return nil
 }
 
+// batchTableEntry is a batch to insert into a batch table.
+type batchTableEntry struct {
+	batchID    string
+	batchSQL   string
+	countSQL   string
+	batchSize  int64
+	batchBegin string
+	batchEnd   string
+	// lastPK is the PK of the last row of the batch in the order of the build, encoded by encodeLastPK.
+	lastPK string
+}
+
+// insertBatchInfoTableEntries inserts entries into the batch table in a single statement, so that either all or none of them are inserted.
+func (jc *JobController) insertBatchInfoTableEntries(ctx context.Context, tableSchema, batchTableName string, entries []batchTableEntry) error {
+	var values strings.Builder
+	for i, entry := range entries {
+		if err := checkBatchColumnsLength(entry.batchID,
+			"batch_sql", entry.batchSQL,
+			"batch_count_sql_when_creating_batch", entry.countSQL,
+			"batch_begin", entry.batchBegin,
+			"batch_end", entry.batchEnd); err != nil {
+			return err
+		}
+		if i > 0 {
+			values.WriteString(",")
+		}
+		values.WriteString("(")
+		for j, value := range []sqltypes.Value{
+			sqltypes.NewVarChar(entry.batchID),
+			sqltypes.NewVarChar(entry.batchSQL),
+			sqltypes.NewVarChar(entry.countSQL),
+			sqltypes.NewInt64(entry.batchSize),
+			sqltypes.NewVarChar(entry.batchBegin),
+			sqltypes.NewVarChar(entry.batchEnd),
+			sqltypes.NewVarChar(entry.lastPK),
+		} {
+			if j > 0 {
+				values.WriteString(",")
+			}
+			value.EncodeSQLStringBuilder(&values)
+		}
+		values.WriteString(")")
+	}
+	_, err := jc.execQuery(ctx, tableSchema, fmt.Sprintf(sqlTemplateInsertBatchEntries, batchTableName, values.String()))
+	return err
+}
+
 func (jc *JobController) insertJobEntry(jobUUID, sql, tableSchema, tableName, batchInfoTableSchema,
 	batchInfoTable, jobStatus, statusSetTime, failPolicy, runningTimePeriodStart, runningTimePeriodEnd, runningTimePeriodTimeZone, throttleExpireAt string,
 	timeGapInMs, batchSize int64,
 	throttleRatio float64,
-	postponeLaunch bool) (err error) {
+	postponeLaunch bool, launchAt, archiveTable, isolationLevel, checksumColumns string, maxReplicationLag float64, notifyURL, batchOrder, versionColumn, versionSnapshot, tag string) (err error) {
 
 	runningTimePeriodStart = stripApostrophe(runningTimePeriodStart)
 	runningTimePeriodEnd = stripApostrophe(runningTimePeriodEnd)
@@ -437,6 +1510,50 @@ func (jc *JobController) insertJobEntry(jobUUID, sql, tableSchema, tableName, ba
 	_, offset := time.Now().Zone()
 	statusSetTimeTimeZone := getTimeZoneStr(offset)
 
+	// launch_at is NULL unless the job is launched at a given time.
+	launchAtBindVar := sqltypes.NullBindVariable
+	if launchAt != "" {
+		launchAtBindVar = sqltypes.StringBindVariable(launchAt)
+	}
+	// isolation_level is NULL unless the batches run in a given isolation level.
+	isolationLevelBindVar := sqltypes.NullBindVariable
+	if isolationLevel != "" {
+		isolationLevelBindVar = sqltypes.StringBindVariable(isolationLevel)
+	}
+	// checksum_columns is NULL unless the checksum of the batches is recorded.
+	checksumColumnsBindVar := sqltypes.NullBindVariable
+	if checksumColumns != "" {
+		checksumColumnsBindVar = sqltypes.StringBindVariable(checksumColumns)
+	}
+	// max_replication_lag is NULL unless the job has its own replication lag threshold.
+	maxReplicationLagBindVar := sqltypes.NullBindVariable
+	if maxReplicationLag > 0 {
+		maxReplicationLagBindVar = sqltypes.Float64BindVariable(maxReplicationLag)
+	}
+	// notify_url is NULL unless the job is posted somewhere once it's finished.
+	notifyURLBindVar := sqltypes.NullBindVariable
+	if notifyURL != "" {
+		notifyURLBindVar = sqltypes.StringBindVariable(notifyURL)
+	}
+	// batch_order is NULL unless the batches are executed in a given order.
+	batchOrderBindVar := sqltypes.NullBindVariable
+	if batchOrder != "" {
+		batchOrderBindVar = sqltypes.StringBindVariable(batchOrder)
+	}
+	// version_column and version_snapshot are NULL unless the rows modified since the job was submitted are skipped.
+	versionColumnBindVar, versionSnapshotBindVar := sqltypes.NullBindVariable, sqltypes.NullBindVariable
+	if versionColumn != "" {
+		versionColumnBindVar = sqltypes.StringBindVariable(versionColumn)
+		if versionSnapshot != "" {
+			versionSnapshotBindVar = sqltypes.StringBindVariable(versionSnapshot)
+		}
+	}
+	// tag is NULL unless the job is labeled.
+	tagBindVar := sqltypes.NullBindVariable
+	if tag != "" {
+		tagBindVar = sqltypes.StringBindVariable(tag)
+	}
+
 	submitQuery, err := sqlparser.ParseAndBind(sqlDMLJobSubmit,
 		sqltypes.StringBindVariable(jobUUID),
 		sqltypes.StringBindVariable(sql),
@@ -456,6 +1573,16 @@ func (jc *JobController) insertJobEntry(jobUUID, sql, tableSchema, tableName, ba
 		sqltypes.StringBindVariable(throttleExpireAt),
 		sqltypes.Float64BindVariable(throttleRatio),
 		sqltypes.BoolBindVariable(postponeLaunch),
+		launchAtBindVar,
+		sqltypes.StringBindVariable(archiveTable),
+		isolationLevelBindVar,
+		checksumColumnsBindVar,
+		maxReplicationLagBindVar,
+		notifyURLBindVar,
+		batchOrderBindVar,
+		versionColumnBindVar,
+		versionSnapshotBindVar,
+		tagBindVar,
 	)
 
 	if err != nil {
@@ -597,6 +1724,44 @@ func getTabletAliasName(sql string) (tableName string, err error) {
 	return tableName, err
 }
 
+// genCreateBatchTableSQL returns the statement creating the batch table of a job, with the table options
+// set by non_transactional_dml_batch_table_options. The options are validated by parsing the statement.
+func genCreateBatchTableSQL(batchTableName string) (string, error) {
+	createTableSQL := fmt.Sprintf(sqlTemplateCreateBatchTable, batchTableName, batchTableOptions)
+	if _, err := sqlparser.Parse(createTableSQL); err != nil {
+		return "", fmt.Errorf("invalid batch table options %q: %v", batchTableOptions, err)
+	}
+	return createTableSQL, nil
+}
+
+// qualifiedBatchInfoTable returns the name of the batch table to use in the queries executed in the schema of the job table,
+// which is qualified by its schema if the batch table lives in another schema.
+func (args *JobArgs) qualifiedBatchInfoTable() string {
+	if args.batchInfoTableSchema == "" || args.batchInfoTableSchema == args.tableSchema {
+		return args.batchInfoTable
+	}
+	return sqlescape.EscapeID(args.batchInfoTableSchema) + "." + sqlescape.EscapeID(args.batchInfoTable)
+}
+
+// initBatchTableSchema returns the schema the batch table of a job on a table of tableSchema is created in,
+// and creates it if it's the dedicated schema set by non_transactional_dml_batch_table_schema.
+func (jc *JobController) initBatchTableSchema(tableSchema string) (string, error) {
+	if batchTableSchema == "" || batchTableSchema == tableSchema {
+		return tableSchema, nil
+	}
+	if _, err := jc.execQuery(jc.ctx, "", fmt.Sprintf(sqlTemplateCreateBatchTableSchema, sqlescape.EscapeID(batchTableSchema))); err != nil {
+		return "", err
+	}
+	return batchTableSchema, nil
+}
+
+// analyzeJobTable updates the statistics of the table of a job.
+// A DELETE job which emptied the table is analyzed too, since it's as cheap as checking whether the table is empty.
+func (jc *JobController) analyzeJobTable(ctx context.Context, tableSchema, table string) error {
+	_, err := jc.execQuery(ctx, tableSchema, fmt.Sprintf(sqlTemplateAnalyzeTable, sqlescape.EscapeID(table)))
+	return err
+}
+
 func genBatchTableName(jobUUID string) string {
 	return "_vt_BATCH_" + strings.Replace(jobUUID, "-", "_", -1)
 }
@@ -606,8 +1771,8 @@ func (jc *JobController) genJobAffectedRows(batchInfoTableSchema, batchTableName
 	if err != nil {
 		return 0, err
 	}
-	// if job is in "submitted" status, the batch table is not created yet
-	if jobStatus == SubmittedStatus {
+	// if job is in "submitted" or "blocked" status, the batch table is not created yet
+	if jobStatus == SubmittedStatus || jobStatus == BlockedStatus {
 		return 0, nil
 	}
 
@@ -627,10 +1792,26 @@ func (jc *JobController) genJobAffectedRows(batchInfoTableSchema, batchTableName
 
 // ShowAllDMLJobs we add affected_rows and dealing_batch_id cols to job table query result
 func (jc *JobController) ShowAllDMLJobs() (*sqltypes.Result, error) {
-	qr, err := jc.execQuery(jc.ctx, "", sqlDMLJobGetAllJobs)
+	return jc.showDMLJobs(sqlDMLJobGetAllJobs)
+}
+
+// ShowJobsWithTag shows the jobs whose tag matches the LIKE pattern, e.g. 'ticket-%',
+// like ShowAllDMLJobs shows all the jobs. The jobs without a tag never match.
+func (jc *JobController) ShowJobsWithTag(tagPattern string) (*sqltypes.Result, error) {
+	query, err := sqlparser.ParseAndBind(sqlDMLJobGetJobsWithTag, sqltypes.StringBindVariable(stripApostrophe(tagPattern)))
+	if err != nil {
+		return &sqltypes.Result{}, err
+	}
+	return jc.showDMLJobs(query)
+}
+
+// showDMLJobs returns the jobs selected by the query, with the affected_rows, dealing_batch_id and summary cols added.
+func (jc *JobController) showDMLJobs(query string) (*sqltypes.Result, error) {
+	qr, err := jc.execQuery(jc.ctx, "", query)
 	if err != nil {
 		return &sqltypes.Result{}, err
 	}
+	namedRows := qr.Named().Rows
 	for i := range qr.Rows {
 		uuid := qr.Rows[i][1].ToString()
 
@@ -644,14 +1825,13 @@ func (jc *JobController) ShowAllDMLJobs() (*sqltypes.Result, error) {
 		affectedRows, err := jc.genJobAffectedRows(batchInfoTableSchema, batchTableName, uuid)
 		if err != nil {
 			// perhaps the error is just because there is no any rows or no batches in batch table
-			qr.Rows[i] = append(qr.Rows[i], sqltypes.NewInt64(0))
+			affectedRows = 0
 			log.Infof(err.Error())
-		} else {
-			qr.Rows[i] = append(qr.Rows[i], sqltypes.NewInt64(affectedRows))
 		}
+		qr.Rows[i] = append(qr.Rows[i], sqltypes.NewInt64(affectedRows))
 
 		// add dealing_batch_id value to current row
-		dealingBatchID, err := jc.getBatchIDToExec(jc.ctx, batchInfoTableSchema, batchTableName)
+		dealingBatchID, err := getBatchIDToExec(jc.ctx, jc, batchInfoTableSchema, batchTableName)
 		if err != nil {
 			// perhaps the error is just because there is no any rows in batch table
 			qr.Rows[i] = append(qr.Rows[i], sqltypes.NewVarChar(""))
@@ -660,9 +1840,11 @@ func (jc *JobController) ShowAllDMLJobs() (*sqltypes.Result, error) {
 			qr.Rows[i] = append(qr.Rows[i], sqltypes.NewVarChar(dealingBatchID))
 		}
 
+		// add summary value to current row
+		qr.Rows[i] = append(qr.Rows[i], sqltypes.NewVarChar(genJobSummary(namedRows[i], affectedRows)))
 	}
 
-	qr.Fields = append(qr.Fields, sqltypes.BuildVarCharFields("affected_rows", "dealing_batch_id")...)
+	qr.Fields = append(qr.Fields, sqltypes.BuildVarCharFields("affected_rows", "dealing_batch_id", "summary")...)
 	return qr, nil
 }
 
@@ -690,17 +1872,18 @@ func (jc *JobController) ShowSingleDMLJob(uuid string, showDetails bool) (qr *sq
 		return &sqltypes.Result{}, fmt.Errorf("the len of query result of select dml job is not 1 but %d", len(qr.Rows))
 	}
 
+	namedRow := qr.Named().Row()
+
 	// add affected rows
 	affectedRows, err := jc.genJobAffectedRows(batchInfoTableSchema, batchTableName, uuid)
 	if err != nil {
 		// perhaps the error is just because there is no any rows or no batches in batch table
-		qr.Rows[0] = append(qr.Rows[0], sqltypes.NewInt64(0))
+		affectedRows = 0
 		log.Infof(err.Error())
-	} else {
-		qr.Rows[0] = append(qr.Rows[0], sqltypes.NewInt64(affectedRows))
 	}
+	qr.Rows[0] = append(qr.Rows[0], sqltypes.NewInt64(affectedRows))
 	// add dealing batch id
-	dealingBatchID, err := jc.getBatchIDToExec(jc.ctx, batchInfoTableSchema, batchTableName)
+	dealingBatchID, err := getBatchIDToExec(jc.ctx, jc, batchInfoTableSchema, batchTableName)
 	if err != nil {
 		// perhaps the error is just because there is no any rows in batch table
 		qr.Rows[0] = append(qr.Rows[0], sqltypes.NewVarChar(""))
@@ -708,8 +1891,57 @@ func (jc *JobController) ShowSingleDMLJob(uuid string, showDetails bool) (qr *sq
 	} else {
 		qr.Rows[0] = append(qr.Rows[0], sqltypes.NewVarChar(dealingBatchID))
 	}
+	// add summary
+	qr.Rows[0] = append(qr.Rows[0], sqltypes.NewVarChar(genJobSummary(namedRow, affectedRows)))
 
-	qr.Fields = append(qr.Fields, sqltypes.BuildVarCharFields("affected_rows", "dealing_batch_id")...)
+	qr.Fields = append(qr.Fields, sqltypes.BuildVarCharFields("affected_rows", "dealing_batch_id", "summary")...)
 	return qr, nil
 
 }
+
+// genJobSummary returns a human-readable summary of a job in a terminal state, which combines
+// the affected rows, the duration and the final message of the job.
+// It returns an empty string if the job has not finished yet.
+func genJobSummary(row sqltypes.RowNamedValues, affectedRows int64) string {
+	status := row["status"].ToString()
+	if status != CompletedStatus && status != FailedStatus && status != CanceledStatus {
+		return ""
+	}
+	summary := fmt.Sprintf("%s, affected rows: %d", status, affectedRows)
+	if duration, ok := getJobDuration(row); ok {
+		summary += fmt.Sprintf(", duration: %v", duration)
+	}
+	if message := row["message"].ToString(); message != "" {
+		summary += fmt.Sprintf(", message: %s", message)
+	}
+	return summary
+}
+
+// getJobDuration returns the duration of a job in a terminal state. Completed jobs have it recorded,
+// for the others it is the time between the start of the job and its last status change.
+func getJobDuration(row sqltypes.RowNamedValues) (time.Duration, bool) {
+	if durationInMs, err := row["duration_in_ms"].ToInt64(); err == nil {
+		return time.Duration(durationInMs) * time.Millisecond, true
+	}
+	startTimeStr := row["start_time"].ToString()
+	statusSetTimeStr := row["status_set_time"].ToString()
+	if startTimeStr == "" || statusSetTimeStr == "" {
+		return 0, false
+	}
+	// the layout parses the optional fractional seconds of the timestamps as well
+	startTime, err := time.ParseInLocation(time.DateTime, startTimeStr, time.Local)
+	if err != nil {
+		return 0, false
+	}
+	statusSetTime, err := time.ParseInLocation(time.DateTime, statusSetTimeStr, time.Local)
+	if err != nil || statusSetTime.Before(startTime) {
+		return 0, false
+	}
+	return statusSetTime.Sub(startTime).Truncate(time.Second), true
+}
+
+// isDeadlock returns true if err is a deadlock, which rolled back the transaction of the batch.
+func isDeadlock(err error) bool {
+	sqlErr, ok := mysql.NewSQLErrorFromError(err).(*mysql.SQLError)
+	return ok && sqlErr.Number() == mysql.ERLockDeadlock
+}
@@ -6,12 +6,19 @@ Licensed under the Apache v2(found in the LICENSE file in the root directory).
 package jobcontroller
 
 import (
+	"context"
 	"errors"
+	"fmt"
+	"strings"
 	"testing"
+	"time"
 
+	"vitess.io/vitess/go/mysql/fakesqldb"
+	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/vt/sqlparser"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func TestParseDML(t *testing.T) {
@@ -192,6 +199,25 @@ func TestParseDML(t *testing.T) {
 
 }
 
+func TestParseJobDMLs(t *testing.T) {
+	tableName, whereExprs, stmts, err := parseJobDMLs("update t1 set flag = 1 where c1 < 10; delete from t1 where flag = 1")
+	require.NoError(t, err)
+	assert.Equal(t, "t1", tableName)
+	require.Len(t, stmts, 2)
+	require.Len(t, whereExprs, 2)
+	assert.Equal(t, "c1 < 10 or flag = 1", sqlparser.String(orWhereExprs(whereExprs)))
+
+	// a single statement is the where of the job as is
+	_, whereExprs, _, err = parseJobDMLs("delete from t1 where c1 < 10;")
+	require.NoError(t, err)
+	assert.Equal(t, "c1 < 10", sqlparser.String(orWhereExprs(whereExprs)))
+
+	_, _, _, err = parseJobDMLs("update t1 set flag = 1 where c1 < 10; delete from t2 where flag = 1")
+	assert.ErrorContains(t, err, "the statements of a job must be on the same table")
+	_, _, _, err = parseJobDMLs("update t1 set flag = 1 where c1 < 10; select * from t1")
+	assert.ErrorContains(t, err, "the type of sql is not supported")
+}
+
 func TestGetTimeZoneStr(t *testing.T) {
 	tests := []struct {
 		offset int
@@ -243,3 +269,805 @@ func TestGetTimeZoneOffset(t *testing.T) {
 		}
 	}
 }
+
+func TestGetLaunchAt(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"delete from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true dml_launch_at=2023-09-01T02:00:00+08:00 */ from t1 where id = 1", "2023-09-01T02:00:00+08:00", false},
+		{"update /*vt+ dml_split=true dml_launch_at='2023-09-01T02:00:00Z' */ t1 set c1 = 1 where id = 1", "2023-09-01T02:00:00Z", false},
+		{"delete /*vt+ dml_split=true dml_launch_at=2023-09-01 */ from t1 where id = 1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getLaunchAt(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestCalcJobThroughput(t *testing.T) {
+	startTime := time.Date(2023, 9, 1, 10, 0, 0, 0, time.Local)
+
+	duration, rowsPerSecond := calcJobThroughput(startTime, startTime.Add(4*time.Second), 1000)
+	assert.Equal(t, 4*time.Second, duration)
+	assert.Equal(t, 250.0, rowsPerSecond)
+
+	duration, rowsPerSecond = calcJobThroughput(startTime, startTime, 1000)
+	assert.Zero(t, duration)
+	assert.Zero(t, rowsPerSecond)
+}
+
+func TestRecordJobCompletionStats(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status|batch_info_table_schema|start_time", "varchar|varchar|varchar|timestamp"),
+		"uuid|completed||2023-09-01 10:00:00.000"))
+	db.AddQuery("SELECT SUM(actually_affected_rows) AS affected_rows FROM _vt_BATCH_uuid WHERE batch_status='completed'", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("affected_rows", "decimal"), "1000"))
+	var statsQuery string
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+complete_time = .*`, &sqltypes.Result{RowsAffected: 1},
+		func(query string) { statsQuery = query })
+
+	startTime, err := time.ParseInLocation(jobTimeFormat, "2023-09-01 10:00:00.000", time.Local)
+	require.NoError(t, err)
+	err = jc.recordJobCompletionStats(context.Background(), "uuid", startTime.Add(2*time.Second))
+	require.NoError(t, err)
+	assert.Contains(t, statsQuery, "complete_time = '2023-09-01 10:00:02.000'")
+	assert.Contains(t, statsQuery, "duration_in_ms = 2000")
+	assert.Contains(t, statsQuery, "rows_per_second = 500")
+}
+
+func TestInsertJobEntryLaunchAt(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	var submitQuery string
+	db.AddQueryPatternWithCallback(`insert into mysql\.non_transactional_dml_jobs.*`, &sqltypes.Result{RowsAffected: 1},
+		func(query string) { submitQuery = query })
+	insertJobEntry := func(launchAt string) {
+		err := jc.insertJobEntry("uuid", "delete from t1 where id = 1", "ks", "t1", "ks", "_vt_BATCH_uuid", "submitted",
+			"2023-09-01 10:00:00", "skip", "", "", "", "", 1000, 100, 0, true, launchAt, "", "", "", 0, "", "", "", "", "")
+		require.NoError(t, err)
+	}
+
+	insertJobEntry("")
+	assert.Regexp(t, `,1,null,'',null,null,null,null,null,null,null,null\)$`, submitQuery)
+
+	insertJobEntry("2023-09-01T02:00:00+08:00")
+	assert.Regexp(t, `,1,'2023-09-01T02:00:00\+08:00','',null,null,null,null,null,null,null,null\)$`, submitQuery)
+}
+
+func TestInsertBatchInfoTableEntryTooLong(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	inserted := false
+	db.AddQueryPatternWithCallback(`\s*insert into _vt_BATCH_uuid.*`, &sqltypes.Result{RowsAffected: 1},
+		func(string) { inserted = true })
+
+	values := make([]string, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		values = append(values, fmt.Sprintf("'value-%d'", i))
+	}
+	where := fmt.Sprintf("c1 in (%s) and id >= 1 and id <= 100", strings.Join(values, ","))
+	batchSQL := "delete from t1 where " + where
+	countSQL := "select count(*) as count_rows from t1 where " + where
+	require.Greater(t, len(batchSQL), maxBatchColumnLength)
+
+	err := jc.insertBatchInfoTableEntry(context.Background(), "", "_vt_BATCH_uuid", "1", batchSQL, countSQL, "1", "100", 100)
+	require.ErrorContains(t, err, "the batch_sql of batch 1 is")
+	assert.False(t, inserted)
+
+	err = jc.insertBatchInfoTableEntry(context.Background(), "", "_vt_BATCH_uuid", "1", "delete from t1 where id >= 1 and id <= 100",
+		"select count(*) as count_rows from t1 where id >= 1 and id <= 100", "1", "100", 100)
+	require.NoError(t, err)
+	assert.True(t, inserted)
+}
+
+func TestGetArchiveTable(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true dml_archive_table=t1_archive */ from t1 where id = 1", "t1_archive", false},
+		{"delete /*vt+ dml_split=true dml_archive_table='t1_archive' */ from t1 where id = 1", "t1_archive", false},
+		{"update /*vt+ dml_split=true dml_archive_table=t1_archive */ t1 set c1 = 1 where id = 1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getArchiveTable(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGetIsolationLevel(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true dml_isolation_level=read_committed */ from t1 where id = 1", "READ COMMITTED", false},
+		{"update /*vt+ dml_split=true dml_isolation_level='REPEATABLE-READ' */ t1 set c1 = 1 where id = 1", "REPEATABLE READ", false},
+		{"delete /*vt+ dml_split=true dml_isolation_level=serializable */ from t1 where id = 1", "SERIALIZABLE", false},
+		{"delete /*vt+ dml_split=true dml_isolation_level=snapshot */ from t1 where id = 1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getIsolationLevel(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGenArchiveBatchSQL(t *testing.T) {
+	archiveSQL, err := genArchiveBatchSQL("delete from t1 where id >= 1 and id <= 10", "t1_archive", []string{"id", "c1"})
+	require.NoError(t, err)
+	assert.Equal(t, "insert into t1_archive (id,c1) select id,c1 from t1 where id >= 1 and id <= 10", archiveSQL)
+
+	_, err = genArchiveBatchSQL("update t1 set c1 = 1 where id >= 1 and id <= 10", "t1_archive", []string{"id", "c1"})
+	assert.Error(t, err)
+}
+
+func TestCheckArchiveTable(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	colTypesFields := sqltypes.MakeTestFields("COLUMN_NAME|COLUMN_TYPE", "varchar|varchar")
+	colTypesQuery := `SELECT COLUMN_NAME, COLUMN_TYPE FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'db1'\s+AND TABLE_NAME = '%s'\s+ORDER BY ORDINAL_POSITION`
+	db.AddQueryPattern(fmt.Sprintf(colTypesQuery, "t1"), sqltypes.MakeTestResult(colTypesFields, "id|bigint", "c1|varchar(64)"))
+	// the archive table may have extra columns
+	db.AddQueryPattern(fmt.Sprintf(colTypesQuery, "t1_archive"), sqltypes.MakeTestResult(colTypesFields, "id|bigint", "c1|varchar(64)", "archived_at|timestamp"))
+	db.AddQueryPattern(fmt.Sprintf(colTypesQuery, "t1_missing_col"), sqltypes.MakeTestResult(colTypesFields, "id|bigint"))
+	db.AddQueryPattern(fmt.Sprintf(colTypesQuery, "t1_wrong_type"), sqltypes.MakeTestResult(colTypesFields, "id|bigint", "c1|int"))
+	db.AddQueryPattern(fmt.Sprintf(colTypesQuery, "t1_not_exist"), sqltypes.MakeTestResult(colTypesFields))
+
+	ctx := context.Background()
+	assert.NoError(t, jc.checkArchiveTable(ctx, "db1", "t1", "t1_archive"))
+	assert.ErrorContains(t, jc.checkArchiveTable(ctx, "db1", "t1", "t1"), "must be different")
+	assert.ErrorContains(t, jc.checkArchiveTable(ctx, "db1", "t1", "t1_missing_col"), "has no column c1")
+	assert.ErrorContains(t, jc.checkArchiveTable(ctx, "db1", "t1", "t1_wrong_type"), "the type of column c1 is int")
+	assert.ErrorContains(t, jc.checkArchiveTable(ctx, "db1", "t1", "t1_not_exist"), "does not exist")
+}
+
+func TestGetChecksumColumns(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true dml_checksum_columns=c1 */ from t1 where id = 1", "c1", false},
+		{"update /*vt+ dml_split=true dml_checksum_columns='c1,c2' */ t1 set c1 = 1 where id = 1", "c1,c2", false},
+		{"delete /*vt+ dml_split=true dml_checksum_columns='c1,,c2' */ from t1 where id = 1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getChecksumColumns(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGetMaxReplicationLag(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      float64
+		wantError bool
+	}{
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", 0, false},
+		{"delete /*vt+ dml_split=true dml_max_replication_lag=2 */ from t1 where id = 1", 2, false},
+		{"update /*vt+ dml_split=true dml_max_replication_lag='0.5' */ t1 set c1 = 1 where id = 1", 0.5, false},
+		{"delete /*vt+ dml_split=true dml_max_replication_lag=0 */ from t1 where id = 1", 0, true},
+		{"delete /*vt+ dml_split=true dml_max_replication_lag=1s */ from t1 where id = 1", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := getMaxReplicationLag(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGetNotifyURL(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true dml_notify_url=http://localhost:8080/jobs?from=wescale */ from t1 where id = 1", "http://localhost:8080/jobs?from=wescale", false},
+		{"update /*vt+ dml_split=true dml_notify_url='https://example.com/jobs' */ t1 set c1 = 1 where id = 1", "https://example.com/jobs", false},
+		{"delete /*vt+ dml_split=true dml_notify_url=ftp://example.com/jobs */ from t1 where id = 1", "", true},
+		{"delete /*vt+ dml_split=true dml_notify_url=localhost:8080 */ from t1 where id = 1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getNotifyURL(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGetBatchOrder(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true dml_batch_order=desc */ from t1 where id = 1", batchOrderDesc, false},
+		{"update /*vt+ dml_split=true dml_batch_order='ASC' */ t1 set c1 = 1 where id = 1", batchOrderAsc, false},
+		{"delete /*vt+ dml_split=true dml_batch_order=random */ from t1 where id = 1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getBatchOrder(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestCheckChecksumColumns(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQueryPattern(`SELECT COLUMN_NAME FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'db1'\s+AND TABLE_NAME = 't1'`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("COLUMN_NAME", "varchar"), "id", "c1"))
+
+	ctx := context.Background()
+	assert.NoError(t, jc.checkChecksumColumns(ctx, "db1", "t1", "id,C1"))
+	assert.ErrorContains(t, jc.checkChecksumColumns(ctx, "db1", "t1", "id,c2"), "table t1 has no column c2")
+}
+
+func TestCheckGeneratedColumns(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	db.AddQuery("use db1", &sqltypes.Result{})
+	generatedColsFields := sqltypes.MakeTestFields("COLUMN_NAME|EXTRA", "varchar|varchar")
+	db.AddQueryPattern(`SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'db1'\s+AND TABLE_NAME = 't1'\s+AND EXTRA IN .*`,
+		sqltypes.MakeTestResult(generatedColsFields, "c_virtual|VIRTUAL GENERATED", "c_stored|STORED GENERATED"))
+	db.AddQueryPattern(`\s*show index from t1 where key_name = 'primary'`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("Column_name", "varchar"), "id"))
+	db.AddQuery("select id from db1.t1 limit 1", sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1"))
+
+	ctx := context.Background()
+	checkSQL := func(sql string) error {
+		_, _, stmt, err := parseDML(sql)
+		require.NoError(t, err)
+		return jc.checkGeneratedColumns(ctx, "db1", "t1", stmt)
+	}
+	// the generated columns can be read, in the WHERE clause too
+	assert.NoError(t, checkSQL("update t1 set c1 = c_stored + 1 where c_virtual > 10"))
+	assert.NoError(t, checkSQL("delete from t1 where c_virtual > 10"))
+	// but not assigned to
+	assert.ErrorContains(t, checkSQL("update t1 set c1 = 1, C_Virtual = 2 where id > 10"), "the job can't assign to the generated column C_Virtual of table t1")
+	assert.ErrorContains(t, checkSQL("update t1 set c_stored = 2 where id > 10"), "the job can't assign to the generated column c_stored of table t1")
+
+	// a virtual generated column in the primary key can't be used to split the table into batches
+	db.AddQueryPattern(`SELECT COLUMN_NAME, EXTRA FROM INFORMATION_SCHEMA\.COLUMNS\s+WHERE\s+TABLE_SCHEMA = 'db1'\s+AND TABLE_NAME = 't2'\s+AND EXTRA IN .*`,
+		sqltypes.MakeTestResult(generatedColsFields, "id|VIRTUAL GENERATED"))
+	db.AddQueryPattern(`\s*show index from t2 where key_name = 'primary'`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("Column_name", "varchar"), "id"))
+	db.AddQuery("select id from db1.t2 limit 1", sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1"))
+	_, _, stmt, err := parseDML("delete from t2 where c1 > 10")
+	require.NoError(t, err)
+	assert.ErrorContains(t, jc.checkGeneratedColumns(ctx, "db1", "t2", stmt), "the primary key column id of table t2 is a virtual generated column")
+}
+
+func TestGenBatchChecksumSQL(t *testing.T) {
+	checksumSQL, err := genBatchChecksumSQL("delete from t1 where id >= 1 and id <= 10", "c1,c2")
+	require.NoError(t, err)
+	assert.Equal(t, "select cast(coalesce(sum(crc32(concat_ws('#', c1, c2, concat(isnull(c1), isnull(c2))))), 0) as unsigned) as checksum from t1 where id >= 1 and id <= 10", checksumSQL)
+
+	checksumSQL, err = genBatchChecksumSQL("update t1 set c1 = 1 where id >= 1 and id <= 10", "c1")
+	require.NoError(t, err)
+	assert.Equal(t, "select cast(coalesce(sum(crc32(concat_ws('#', c1, concat(isnull(c1))))), 0) as unsigned) as checksum from t1 where id >= 1 and id <= 10", checksumSQL)
+
+	_, err = genBatchChecksumSQL("insert into t1 (id) values (1)", "c1")
+	assert.Error(t, err)
+}
+
+func TestGetVersionColumn(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"update /*vt+ dml_split=true */ t1 set c1 = 1 where id = 1", "", false},
+		{"update /*vt+ dml_split=true dml_version_column=updated_at */ t1 set c1 = 1 where id = 1", "updated_at", false},
+		{"update /*vt+ dml_split=true dml_version_column='version' */ t1 set c1 = 1 where id = 1", "version", false},
+		{"delete /*vt+ dml_split=true dml_version_column=updated_at */ from t1 where id = 1", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getVersionColumn(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGetTag(t *testing.T) {
+	tests := []struct {
+		sql       string
+		want      string
+		wantError bool
+	}{
+		{"delete /*vt+ dml_split=true */ from t1 where id = 1", "", false},
+		{"delete /*vt+ dml_split=true dml_tag=ticket-1024 */ from t1 where id = 1", "ticket-1024", false},
+		{"update /*vt+ dml_split=true dml_tag='team_a' */ t1 set c1 = 1 where id = 1", "team_a", false},
+		{"delete /*vt+ dml_split=true dml_tag='team a' */ from t1 where id = 1", "", true},
+		{fmt.Sprintf("delete /*vt+ dml_split=true dml_tag=%s */ from t1 where id = 1", strings.Repeat("a", maxTagLength+1)), "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := getTag(tt.sql)
+		if tt.wantError {
+			assert.Error(t, err, tt.sql)
+			continue
+		}
+		assert.NoError(t, err, tt.sql)
+		assert.Equal(t, tt.want, got, tt.sql)
+	}
+}
+
+func TestGenVersionedBatchSQL(t *testing.T) {
+	versionedSQL, skippedRowsSQL, err := genVersionedBatchSQL("update t1 set c1 = 1 where c2 > 10 and (id >= 1 and id <= 10)", "updated_at", "2023-09-01 10:00:00")
+	require.NoError(t, err)
+	assert.Equal(t, "update t1 set c1 = 1 where c2 > 10 and (id >= 1 and id <= 10) and (updated_at is null or updated_at <= '2023-09-01 10:00:00')", versionedSQL)
+	assert.Equal(t, "select count(*) as count_rows from t1 where c2 > 10 and (id >= 1 and id <= 10) and not (updated_at is null or updated_at <= '2023-09-01 10:00:00')", skippedRowsSQL)
+
+	// no row had a version when the job was submitted
+	versionedSQL, skippedRowsSQL, err = genVersionedBatchSQL("update t1 set c1 = 1 where id >= 1 and id <= 10", "version", "")
+	require.NoError(t, err)
+	assert.Equal(t, "update t1 set c1 = 1 where id >= 1 and id <= 10 and version is null", versionedSQL)
+	assert.Equal(t, "select count(*) as count_rows from t1 where id >= 1 and id <= 10 and not version is null", skippedRowsSQL)
+
+	_, _, err = genVersionedBatchSQL("delete from t1 where id >= 1 and id <= 10", "updated_at", "2023-09-01 10:00:00")
+	assert.Error(t, err)
+}
+
+func TestGetPKRange(t *testing.T) {
+	start, end, err := getPKRange("delete /*vt+ dml_split=true dml_pk_range_start=1 dml_pk_range_end='10000000' */ from t1 where c1 = 1")
+	require.NoError(t, err)
+	assert.Equal(t, "1", start)
+	assert.Equal(t, "10000000", end)
+
+	start, end, err = getPKRange("delete /*vt+ dml_split=true */ from t1 where c1 = 1")
+	require.NoError(t, err)
+	assert.Empty(t, start)
+	assert.Empty(t, end)
+}
+
+func TestCheckFullTable(t *testing.T) {
+	sql := "delete /*vt+ dml_split=true */ from t1"
+	allowFullTable, err := getAllowFullTable(sql)
+	require.NoError(t, err)
+	assert.False(t, allowFullTable)
+	_, err = checkFullTable(sqlparser.StripComments(sql), allowFullTable)
+	assert.EqualError(t, err, "the SQL has no where clause and would affect the whole table, set dml_allow_full_table=true if it's intended")
+
+	_, err = checkFullTable("update t1 set c1 = 1", false)
+	assert.ErrorContains(t, err, "the SQL has no where clause")
+
+	sql = "delete /*vt+ dml_split=true dml_allow_full_table=true */ from t1"
+	allowFullTable, err = getAllowFullTable(sql)
+	require.NoError(t, err)
+	assert.True(t, allowFullTable)
+	sql, err = checkFullTable(sqlparser.StripComments(sql), allowFullTable)
+	require.NoError(t, err)
+	assert.Equal(t, "delete from t1 where true", sql)
+	// the job runs on the whole table
+	tableName, whereExpr, _, err := parseDML(sql)
+	require.NoError(t, err)
+	assert.Equal(t, "t1", tableName)
+	templates := genJobSQLTemplates(tableName, whereExpr, []PKInfo{{pkName: "id", pkType: sqltypes.Int64}}, "")
+	assert.Equal(t, "select id from t1 where true order by id", templates.selectSQL)
+
+	sql, err = checkFullTable("update t1 set c1 = 1", true)
+	require.NoError(t, err)
+	assert.Equal(t, "update t1 set c1 = 1 where true", sql)
+
+	// a SQL with a where clause is kept as is
+	sql, err = checkFullTable("delete from t1 where c1 = 1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "delete from t1 where c1 = 1", sql)
+}
+
+func TestLimitToPKRange(t *testing.T) {
+	intPK := []PKInfo{{pkName: "id", pkType: sqltypes.Int64}}
+	uintPK := []PKInfo{{pkName: "id", pkType: sqltypes.Uint64}}
+	strPK := []PKInfo{{pkName: "uname", pkType: sqltypes.VarChar}}
+	tests := []struct {
+		name       string
+		sql        string
+		pkInfos    []PKInfo
+		start, end string
+		want       string
+		wantErr    string
+	}{
+		{
+			name:    "both bounds",
+			sql:     "delete from t1 where c1 = 1",
+			pkInfos: intPK,
+			start:   "100",
+			end:     "200",
+			want:    "delete from t1 where c1 = 1 and id >= 100 and id <= 200",
+		},
+		{
+			name:    "the where clause is kept as a whole",
+			sql:     "update t1 set c1 = 2 where c1 = 1 or c2 = 2",
+			pkInfos: intPK,
+			start:   "100",
+			end:     "200",
+			want:    "update t1 set c1 = 2 where (c1 = 1 or c2 = 2) and id >= 100 and id <= 200",
+		},
+		{
+			name:    "start only",
+			sql:     "delete from t1 where c1 = 1",
+			pkInfos: uintPK,
+			start:   "100",
+			want:    "delete from t1 where c1 = 1 and id >= 100",
+		},
+		{
+			name:    "end only",
+			sql:     "delete from t1 where c1 = 1",
+			pkInfos: intPK,
+			end:     "1000",
+			want:    "delete from t1 where c1 = 1 and id <= 1000",
+		},
+		{
+			name:    "string PK",
+			sql:     "delete from t1 where c1 = 1",
+			pkInfos: strPK,
+			start:   "a",
+			end:     "m",
+			want:    "delete from t1 where c1 = 1 and uname >= 'a' and uname <= 'm'",
+		},
+		{
+			name:    "bound not of the PK type",
+			sql:     "delete from t1 where c1 = 1",
+			pkInfos: intPK,
+			start:   "abc",
+			wantErr: "invalid PK range bound abc for the primary key id of type INT64",
+		},
+		{
+			name:    "start greater than end",
+			sql:     "delete from t1 where c1 = 1",
+			pkInfos: uintPK,
+			start:   "200",
+			end:     "100",
+			wantErr: "the PK range start 200 is greater than the end 100",
+		},
+		{
+			name:    "composite PK",
+			sql:     "delete from t1 where c1 = 1",
+			pkInfos: []PKInfo{{pkName: "id1", pkType: sqltypes.Int64}, {pkName: "id2", pkType: sqltypes.Int64}},
+			start:   "100",
+			wantErr: "only supported for tables with a single column primary key",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := limitToPKRange(tt.sql, tt.pkInfos, tt.start, tt.end)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestLimitToPKRangeBatches(t *testing.T) {
+	pkInfos := []PKInfo{{pkName: "id", pkType: sqltypes.Int64}}
+	sql, err := limitToPKRange("delete from t1 where c1 = 1", pkInfos, "100", "200")
+	require.NoError(t, err)
+
+	// the rows of the batches are selected within the range, and every batch SQL keeps it
+	tableName, whereExpr, stmt, err := parseDML(sql)
+	require.NoError(t, err)
+	templates := genJobSQLTemplates(tableName, whereExpr, pkInfos, "")
+	assert.Equal(t, "select id from t1 where c1 = 1 and id >= 100 and id <= 200 order by id", templates.selectSQL)
+	assert.Contains(t, templates.countSQLTemplate, "c1 = 1 and id >= 100 and id <= 200")
+
+	batchSQL, _, err := genBatchSQL(stmt, whereExpr, []sqltypes.Value{sqltypes.NewInt64(100)}, []sqltypes.Value{sqltypes.NewInt64(150)}, pkInfos)
+	require.NoError(t, err)
+	assert.Contains(t, batchSQL, "c1 = 1 and id >= 100 and id <= 200")
+	assert.Contains(t, batchSQL, "id >= 100 and id <= 150")
+}
+
+func TestGenCreateBatchTableSQL(t *testing.T) {
+	defer func(options string) { batchTableOptions = options }(batchTableOptions)
+
+	createTableSQL, err := genCreateBatchTableSQL("_vt_BATCH_test")
+	require.NoError(t, err)
+	stmt, err := sqlparser.Parse(createTableSQL)
+	require.NoError(t, err)
+	createTable, ok := stmt.(*sqlparser.CreateTable)
+	require.True(t, ok)
+	assert.Equal(t, "_vt_BATCH_test", createTable.Table.Name.String())
+	require.Len(t, createTable.TableSpec.Options, 1)
+	assert.Equal(t, "InnoDB", createTable.TableSpec.Options[0].String)
+
+	batchTableOptions = "ENGINE = InnoDB ROW_FORMAT = COMPRESSED"
+	createTableSQL, err = genCreateBatchTableSQL("_vt_BATCH_test")
+	require.NoError(t, err)
+	assert.True(t, strings.HasSuffix(createTableSQL, ") ENGINE = InnoDB ROW_FORMAT = COMPRESSED"))
+
+	batchTableOptions = "ENGINE = InnoDB; drop table t1"
+	_, err = genCreateBatchTableSQL("_vt_BATCH_test")
+	assert.ErrorContains(t, err, "invalid batch table options")
+}
+
+func TestGenSelectPKsPageSQL(t *testing.T) {
+	stmt, err := sqlparser.Parse("delete from t1 where c1 = 1")
+	require.NoError(t, err)
+	whereExpr := stmt.(*sqlparser.Delete).Where.Expr
+	pkInfos := []PKInfo{{pkName: "id1", pkType: sqltypes.Int64}, {pkName: "id2", pkType: sqltypes.VarChar}}
+
+	selectSQL, err := genSelectPKsPageSQL("t1", whereExpr, pkInfos, "", nil, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "select id1,id2 from t1 where c1 = 1 order by id1,id2 limit 100", selectSQL)
+
+	lastPK := []sqltypes.Value{sqltypes.NewInt64(3), sqltypes.NewVarChar("a")}
+	selectSQL, err = genSelectPKsPageSQL("t1", whereExpr, pkInfos, "", lastPK, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "select id1,id2 from t1 where c1 = 1 and (id1 > 3 or id1 = 3 and id2 > 'a') order by id1,id2 limit 100", selectSQL)
+
+	selectSQL, err = genSelectPKsPageSQL("t1", whereExpr, pkInfos, batchOrderDesc, lastPK, 100)
+	require.NoError(t, err)
+	assert.Equal(t, "select id1,id2 from t1 where c1 = 1 and (id1 < 3 or id1 = 3 and id2 < 'a') order by id1 desc,id2 desc limit 100", selectSQL)
+}
+
+func TestEncodeDecodeLastPK(t *testing.T) {
+	pkInfos := []PKInfo{{pkName: "id1", pkType: sqltypes.Int64}, {pkName: "id2", pkType: sqltypes.VarChar}}
+	// the values may contain the separators of batch_begin and batch_end
+	lastPK := []sqltypes.Value{sqltypes.NewInt64(3), sqltypes.NewVarChar(`a,"b"`)}
+
+	encoded, err := encodeLastPK(lastPK)
+	require.NoError(t, err)
+	decoded, err := decodeLastPK(encoded, pkInfos)
+	require.NoError(t, err)
+	assert.Equal(t, lastPK, decoded)
+
+	_, err = decodeLastPK(`["3"]`, pkInfos)
+	assert.ErrorContains(t, err, "it has 1 values instead of 2")
+	_, err = decodeLastPK("3,a", pkInfos)
+	assert.ErrorContains(t, err, "invalid last PK")
+}
+
+// TestBatchStatusLookupsUseIndex checks that the batch table has an index on batch_status,
+// and that the lookups of the batches by status filter on it, so that they don't scan the whole table.
+func TestBatchStatusLookupsUseIndex(t *testing.T) {
+	createTableSQL, err := genCreateBatchTableSQL("_vt_BATCH_test")
+	require.NoError(t, err)
+	stmt, err := sqlparser.Parse(createTableSQL)
+	require.NoError(t, err)
+	indexed := false
+	for _, index := range stmt.(*sqlparser.CreateTable).TableSpec.Indexes {
+		if !index.Info.Primary && index.Columns[0].Column.EqualString("batch_status") {
+			indexed = true
+		}
+	}
+	require.True(t, indexed, "no index on batch_status in %s", createTableSQL)
+
+	for _, template := range []string{sqlTemplateGetBatchIDToExec, sqlTemplateGenAffectedRows} {
+		query := fmt.Sprintf(template, "_vt_BATCH_test")
+		stmt, err := sqlparser.Parse(query)
+		require.NoError(t, err)
+		sel, ok := stmt.(*sqlparser.Select)
+		require.True(t, ok)
+		require.NotNil(t, sel.Where, query)
+		cmp, ok := sel.Where.Expr.(*sqlparser.ComparisonExpr)
+		require.True(t, ok, query)
+		assert.Equal(t, sqlparser.EqualOp, cmp.Operator, query)
+		col, ok := cmp.Left.(*sqlparser.ColName)
+		require.True(t, ok, query)
+		assert.True(t, col.Name.EqualString("batch_status"), query)
+	}
+}
+
+func TestGenJobSummary(t *testing.T) {
+	fields := sqltypes.MakeTestFields("status|message|start_time|status_set_time|duration_in_ms", "varchar|varchar|timestamp|timestamp|int64")
+	genSummary := func(row string, affectedRows int64) string {
+		return genJobSummary(sqltypes.MakeTestResult(fields, row).Named().Row(), affectedRows)
+	}
+
+	assert.Equal(t, "completed, affected rows: 1000, duration: 2.5s",
+		genSummary("completed||2023-09-01 10:00:00.000|2023-09-01 10:00:02|2500", 1000))
+	assert.Equal(t, "failed, affected rows: 200, duration: 1m5s, message: batch 3 failed",
+		genSummary("failed|batch 3 failed|2023-09-01 10:00:00.000|2023-09-01 10:01:05|null", 200))
+	// a job that failed before it started has no duration
+	assert.Equal(t, "failed, affected rows: 0, message: invalid dml",
+		genSummary("failed|invalid dml|null|2023-09-01 10:01:05|null", 0))
+	assert.Equal(t, "", genSummary("running||2023-09-01 10:00:00.000|2023-09-01 10:00:00|null", 100))
+}
+
+func TestShowSingleDMLJobSummary(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	jobFields := sqltypes.MakeTestFields("id|job_uuid|status|batch_info_table_schema|message|start_time|status_set_time|duration_in_ms",
+		"int64|varchar|varchar|varchar|varchar|timestamp|timestamp|int64")
+	jobQuery := `select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`
+	affectedRowsQuery := "SELECT SUM(actually_affected_rows) AS affected_rows FROM _vt_BATCH_%s WHERE batch_status='completed'"
+	batchIDQuery := "SELECT batch_id FROM _vt_BATCH_%s where batch_status = 'queued' order by CAST(SUBSTRING_INDEX(batch_id, '-', 1) AS SIGNED),id limit 1"
+
+	db.AddQuery("use db1", &sqltypes.Result{})
+	db.AddQueryPattern(fmt.Sprintf(jobQuery, "uuid1"), sqltypes.MakeTestResult(jobFields,
+		"1|uuid1|completed|db1||2023-09-01 10:00:00.000|2023-09-01 10:00:02|2000"))
+	db.AddQuery(fmt.Sprintf(affectedRowsQuery, "uuid1"), sqltypes.MakeTestResult(sqltypes.MakeTestFields("affected_rows", "decimal"), "1000"))
+	db.AddQuery(fmt.Sprintf(batchIDQuery, "uuid1"), sqltypes.MakeTestResult(sqltypes.MakeTestFields("batch_id", "varchar")))
+	db.AddQueryPattern(fmt.Sprintf(jobQuery, "uuid2"), sqltypes.MakeTestResult(jobFields,
+		"2|uuid2|failed|db1|batch 3 failed|2023-09-01 10:00:00.000|2023-09-01 10:00:10|null"))
+	db.AddQuery(fmt.Sprintf(affectedRowsQuery, "uuid2"), sqltypes.MakeTestResult(sqltypes.MakeTestFields("affected_rows", "decimal"), "200"))
+	db.AddQuery(fmt.Sprintf(batchIDQuery, "uuid2"), sqltypes.MakeTestResult(sqltypes.MakeTestFields("batch_id", "varchar"), "3"))
+
+	qr, err := jc.ShowSingleDMLJob("uuid1", false)
+	require.NoError(t, err)
+	assert.Equal(t, "completed, affected rows: 1000, duration: 2s", qr.Named().Row().AsString("summary", ""))
+
+	qr, err = jc.ShowSingleDMLJob("uuid2", false)
+	require.NoError(t, err)
+	assert.Equal(t, "failed, affected rows: 200, duration: 10s, message: batch 3 failed", qr.Named().Row().AsString("summary", ""))
+}
+
+func TestIsJobStatusTransitionAllowed(t *testing.T) {
+	tests := []struct {
+		from    string
+		to      string
+		allowed bool
+	}{
+		{from: SubmittedStatus, to: PreparingStatus, allowed: true},
+		{from: PreparingStatus, to: PostponeLaunchStatus, allowed: true},
+		{from: PostponeLaunchStatus, to: QueuedStatus, allowed: true},
+		{from: QueuedStatus, to: RunningStatus, allowed: true},
+		{from: RunningStatus, to: RunningStatus, allowed: true},
+		{from: RunningStatus, to: PausedStatus, allowed: true},
+		{from: PausedStatus, to: RunningStatus, allowed: true},
+		{from: NotInTimePeriodStatus, to: RunningStatus, allowed: true},
+		{from: RunningStatus, to: CompletedStatus, allowed: true},
+		{from: QueuedStatus, to: CanceledStatus, allowed: true},
+		{from: PreparingStatus, to: FailedStatus, allowed: true},
+		{from: SubmittedStatus, to: BlockedStatus, allowed: true},
+		{from: BlockedStatus, to: PreparingStatus, allowed: true},
+		{from: SubmittedStatus, to: RunningStatus, allowed: false},
+		{from: BlockedStatus, to: RunningStatus, allowed: false},
+		{from: PausedStatus, to: CompletedStatus, allowed: false},
+		{from: QueuedStatus, to: PausedStatus, allowed: false},
+		{from: CompletedStatus, to: RunningStatus, allowed: false},
+		{from: CanceledStatus, to: QueuedStatus, allowed: false},
+		{from: FailedStatus, to: FailedStatus, allowed: false},
+		{from: CompletedStatus, to: CanceledStatus, allowed: false},
+		{from: "unknown", to: RunningStatus, allowed: false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.from+" to "+tt.to, func(t *testing.T) {
+			assert.Equal(t, tt.allowed, isJobStatusTransitionAllowed(tt.from, tt.to))
+		})
+	}
+}
+
+func TestUpdateJobStatus(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	jobFields := sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar")
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid1'`, sqltypes.MakeTestResult(jobFields, "uuid1|running"))
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid2'`, sqltypes.MakeTestResult(jobFields, "uuid2|completed"))
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid3'`, sqltypes.MakeTestResult(jobFields))
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid4'`, sqltypes.MakeTestResult(jobFields, "uuid4|queued"))
+	var updates []string
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+status = .*job_uuid = 'uuid1' and status = 'running'`, &sqltypes.Result{RowsAffected: 1}, func(query string) {
+		updates = append(updates, query)
+	})
+	// the status of uuid4 is changed by another writer between the select and the update
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+status = .*job_uuid = 'uuid4' and status = 'queued'`, &sqltypes.Result{})
+
+	qr, err := jc.updateJobStatus(context.Background(), "uuid1", PausedStatus, "2023-09-01 10:00:00")
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, qr.RowsAffected)
+	require.Len(t, updates, 1)
+	assert.Regexp(t, `(?s)status = 'paused'.*job_uuid = 'uuid1'`, updates[0])
+
+	// a completed job is never set back to running
+	_, err = jc.updateJobStatus(context.Background(), "uuid2", RunningStatus, "2023-09-01 10:00:00")
+	assert.ErrorContains(t, err, "illegal status transition of job uuid2 from completed to running")
+
+	_, err = jc.updateJobStatus(context.Background(), "uuid3", RunningStatus, "2023-09-01 10:00:00")
+	assert.ErrorContains(t, err, "uuid uuid3 has 0 entrys in the table instead of 1")
+	assert.Len(t, updates, 1)
+
+	_, err = jc.updateJobStatus(context.Background(), "uuid4", RunningStatus, "2023-09-01 10:00:00")
+	assert.ErrorContains(t, err, "status of job uuid4 changed from queued before it was set to running")
+}
+
+func TestGetFrozenWhereExprsResume(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	jc := newTestJobController(t, db)
+
+	stmt, err := sqlparser.Parse("delete from t1 where c3 < now()")
+	require.NoError(t, err)
+	whereExprs := []sqlparser.Expr{stmt.(*sqlparser.Delete).Where.Expr}
+
+	jobFields := sqltypes.MakeTestFields("job_uuid|status|frozen_where", "varchar|varchar|text")
+	jobInfoPattern := `select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`
+	db.AddQueryPattern(jobInfoPattern, sqltypes.MakeTestResult(jobFields, "uuid|preparing|null"))
+	evalFuncs := db.AddQuery("select now()", sqltypes.MakeTestResult(sqltypes.MakeTestFields("now()", "datetime"), "2024-05-01 10:00:00"))
+	var updateQuery string
+	db.AddQueryPatternWithCallback(`update mysql\.non_transactional_dml_jobs set\s+frozen_where = .*`, &sqltypes.Result{RowsAffected: 1},
+		func(query string) { updateQuery = query })
+
+	// the time functions are frozen the first time the job is prepared, and recorded in the job
+	frozenExprs, err := jc.getFrozenWhereExprs(context.Background(), "uuid", "", whereExprs)
+	require.NoError(t, err)
+	require.Len(t, frozenExprs, 1)
+	assert.Equal(t, "c3 < '2024-05-01 10:00:00'", sqlparser.String(frozenExprs[0]))
+	assert.Contains(t, updateQuery, "2024-05-01 10:00:00")
+	assert.Equal(t, 1, db.GetQueryCalledNum("select now()"))
+
+	// the job is prepared again later, e.g. after a failover, it keeps the cutoff recorded
+	evalFuncs.Result = sqltypes.MakeTestResult(sqltypes.MakeTestFields("now()", "datetime"), "2024-05-02 10:00:00")
+	db.AddQueryPattern(jobInfoPattern, sqltypes.MakeTestResult(jobFields, `uuid|preparing|["c3 < '2024-05-01 10:00:00'"]`))
+	updateQuery = ""
+	frozenExprs, err = jc.getFrozenWhereExprs(context.Background(), "uuid", "", whereExprs)
+	require.NoError(t, err)
+	require.Len(t, frozenExprs, 1)
+	assert.Equal(t, "c3 < '2024-05-01 10:00:00'", sqlparser.String(frozenExprs[0]))
+	assert.Empty(t, updateQuery)
+	assert.Equal(t, 1, db.GetQueryCalledNum("select now()"))
+}
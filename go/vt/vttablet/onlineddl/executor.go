@@ -4476,6 +4476,50 @@ func (e *Executor) SubmitMigration(
 	return result, nil
 }
 
+// MigrationProgress is the progress of a migration, as listed by ListMigrations.
+type MigrationProgress struct {
+	UUID     string
+	Keyspace string
+	Table    string
+	Status   schema.OnlineDDLStatus
+	// Progress is the estimated progress of the migration, in percent.
+	Progress float64
+	// ETASeconds is the estimated number of seconds until the migration is ready to complete, -1 if unknown.
+	ETASeconds int64
+}
+
+// ListMigrations lists the migrations in the given status with their progress, ordered by submission.
+// An empty status lists the migrations in flight, i.e. queued, ready, running or paused.
+func (e *Executor) ListMigrations(ctx context.Context, status schema.OnlineDDLStatus) ([]*MigrationProgress, error) {
+	if atomic.LoadInt64(&e.isOpen) == 0 {
+		return nil, vterrors.New(vtrpcpb.Code_FAILED_PRECONDITION, "online ddl is disabled")
+	}
+	parsed := sqlparser.BuildParsedQuery(sqlSelectMigrationsProgress, ":migration_status", ":migration_status")
+	bindVars := map[string]*querypb.BindVariable{
+		"migration_status": sqltypes.StringBindVariable(string(status)),
+	}
+	bound, err := parsed.GenerateQuery(bindVars, nil)
+	if err != nil {
+		return nil, err
+	}
+	r, err := e.execQuery(ctx, sidecardb.SidecarDBName, bound)
+	if err != nil {
+		return nil, err
+	}
+	migrations := make([]*MigrationProgress, 0, len(r.Rows))
+	for _, row := range r.Named().Rows {
+		migrations = append(migrations, &MigrationProgress{
+			UUID:       row["migration_uuid"].ToString(),
+			Keyspace:   row["keyspace"].ToString(),
+			Table:      row["mysql_table"].ToString(),
+			Status:     schema.OnlineDDLStatus(row["migration_status"].ToString()),
+			Progress:   row.AsFloat64("progress", 0),
+			ETASeconds: row.AsInt64("eta_seconds", -1),
+		})
+	}
+	return migrations, nil
+}
+
 // ShowMigrationLogs reads the migration log for a given migration
 func (e *Executor) ShowMigrationLogs(ctx context.Context, stmt *sqlparser.ShowMigrationLogs) (result *sqltypes.Result, err error) {
 	if atomic.LoadInt64(&e.isOpen) == 0 {
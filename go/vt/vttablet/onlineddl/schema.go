@@ -390,6 +390,19 @@ const (
 			migration_status IN ('queued', 'ready', 'running')
 		ORDER BY id
 	`
+	sqlSelectMigrationsProgress = `SELECT
+			migration_uuid,
+			keyspace,
+			mysql_table,
+			migration_status,
+			progress,
+			eta_seconds
+		FROM mysql.schema_migrations
+		WHERE
+			(%a = '' AND migration_status IN ('queued', 'ready', 'running', 'paused'))
+			OR migration_status = %a
+		ORDER BY id
+	`
 	sqlSelectQueuedUnreviewedMigrations = `SELECT
 			migration_uuid
 		FROM mysql.schema_migrations
@@ -94,6 +94,8 @@ type RPCTM interface {
 
 	PrimaryPosition(ctx context.Context) (string, error)
 
+	FlushBinaryLogs(ctx context.Context) (string, error)
+
 	WaitForPosition(ctx context.Context, pos string) error
 
 	// VExec generic API
@@ -126,6 +128,8 @@ type RPCTM interface {
 
 	SetReplicationSource(ctx context.Context, parent *topodatapb.TabletAlias, timeCreatedNS int64, waitPosition string, forceStartReplication bool, semiSync bool) error
 
+	SetReplicationSourceWithoutStart(ctx context.Context, parent *topodatapb.TabletAlias, semiSync bool) error
+
 	StopReplicationAndGetStatus(ctx context.Context, stopReplicationMode replicationdatapb.StopReplicationMode) (StopReplicationAndGetStatusResponse, error)
 
 	ReplicaWasRestarted(ctx context.Context, parent *topodatapb.TabletAlias) error
@@ -184,6 +184,29 @@ func (tm *TabletManager) PrimaryPosition(ctx context.Context) (string, error) {
 	return mysql.EncodePosition(pos), nil
 }
 
+// FlushBinaryLogs rotates the binary logs of a primary database and returns
+// the position right after the rotation, so the events a consumer needs to
+// read from there on start in a new binary log.
+func (tm *TabletManager) FlushBinaryLogs(ctx context.Context) (string, error) {
+	if err := tm.lock(ctx); err != nil {
+		return "", err
+	}
+	defer tm.unlock()
+
+	// On a replica the position returned would not be the one of its own writes.
+	if tabletType := tm.Tablet().Type; tabletType != topodatapb.TabletType_PRIMARY {
+		return "", vterrors.Errorf(vtrpc.Code_FAILED_PRECONDITION, "FlushBinaryLogs can only be called on a primary tablet, not on a %v tablet", tabletType)
+	}
+	if err := tm.MysqlDaemon.FlushBinaryLogs(ctx); err != nil {
+		return "", err
+	}
+	pos, err := tm.MysqlDaemon.PrimaryPosition()
+	if err != nil {
+		return "", err
+	}
+	return mysql.EncodePosition(pos), nil
+}
+
 // WaitForPosition waits until replication reaches the desired position
 func (tm *TabletManager) WaitForPosition(ctx context.Context, pos string) error {
 	log.Infof("WaitForPosition: %v", pos)
@@ -590,7 +613,20 @@ func (tm *TabletManager) SetReplicationSource(ctx context.Context, parentAlias *
 
 	// setReplicationSourceLocked also fixes the semi-sync. In case the tablet type is primary it assumes that it will become a replica if SetReplicationSource
 	// is called, so we always call fixSemiSync with a non-primary tablet type. This will always set the source side replication to false.
-	return tm.setReplicationSourceLocked(ctx, parentAlias, timeCreatedNS, waitPosition, forceStartReplication, convertBoolToSemiSyncAction(semiSync))
+	return tm.setReplicationSourceLocked(ctx, parentAlias, timeCreatedNS, waitPosition, forceStartReplication, convertBoolToSemiSyncAction(semiSync), false)
+}
+
+// SetReplicationSourceWithoutStart points replication at parentAlias and leaves it stopped,
+// whether or not the tablet was replicating before. It's used to stage a replica before a
+// cutover, replication has to be started explicitly afterwards.
+func (tm *TabletManager) SetReplicationSourceWithoutStart(ctx context.Context, parentAlias *topodatapb.TabletAlias, semiSync bool) error {
+	log.Infof("SetReplicationSourceWithoutStart: parent: %v semiSync: %v", parentAlias, semiSync)
+	if err := tm.lock(ctx); err != nil {
+		return err
+	}
+	defer tm.unlock()
+
+	return tm.setReplicationSourceLocked(ctx, parentAlias, 0, "", false, convertBoolToSemiSyncAction(semiSync), true)
 }
 
 func (tm *TabletManager) setReplicationSourceRepairReplication(ctx context.Context, parentAlias *topodatapb.TabletAlias, timeCreatedNS int64, waitPosition string, forceStartReplication bool) (err error) {
@@ -606,7 +642,7 @@ func (tm *TabletManager) setReplicationSourceRepairReplication(ctx context.Conte
 
 	defer unlock(&err)
 
-	return tm.setReplicationSourceLocked(ctx, parentAlias, timeCreatedNS, waitPosition, forceStartReplication, SemiSyncActionNone)
+	return tm.setReplicationSourceLocked(ctx, parentAlias, timeCreatedNS, waitPosition, forceStartReplication, SemiSyncActionNone, false)
 }
 
 func (tm *TabletManager) setReplicationSourceSemiSyncNoAction(ctx context.Context, parentAlias *topodatapb.TabletAlias, timeCreatedNS int64, waitPosition string, forceStartReplication bool) error {
@@ -616,10 +652,17 @@ func (tm *TabletManager) setReplicationSourceSemiSyncNoAction(ctx context.Contex
 	}
 	defer tm.unlock()
 
-	return tm.setReplicationSourceLocked(ctx, parentAlias, timeCreatedNS, waitPosition, forceStartReplication, SemiSyncActionNone)
+	return tm.setReplicationSourceLocked(ctx, parentAlias, timeCreatedNS, waitPosition, forceStartReplication, SemiSyncActionNone, false)
 }
 
-func (tm *TabletManager) setReplicationSourceLocked(ctx context.Context, parentAlias *topodatapb.TabletAlias, timeCreatedNS int64, waitPosition string, forceStartReplication bool, semiSync SemiSyncAction) (err error) {
+// setReplicationSourceLocked points replication at parentAlias. Replication is started if it was
+// running before or if forceStartReplication is set. If configOnly is set, the source is always
+// updated and replication is stopped, whatever its previous state.
+func (tm *TabletManager) setReplicationSourceLocked(ctx context.Context, parentAlias *topodatapb.TabletAlias, timeCreatedNS int64, waitPosition string, forceStartReplication bool, semiSync SemiSyncAction, configOnly bool) (err error) {
+	if configOnly && forceStartReplication {
+		return vterrors.New(vtrpc.Code_INVALID_ARGUMENT, "cannot force replication to start when only setting the replication source")
+	}
+
 	// Change our type to REPLICA if we used to be PRIMARY.
 	// Being sent SetReplicationSource means another PRIMARY has been successfully promoted,
 	// so we convert to REPLICA first, since we want to do it even if other
@@ -657,6 +700,9 @@ func (tm *TabletManager) setReplicationSourceLocked(ctx context.Context, parentA
 	if forceStartReplication {
 		shouldbeReplicating = true
 	}
+	if configOnly {
+		shouldbeReplicating = false
+	}
 
 	// If using semi-sync, we need to enable it before connecting to primary.
 	// If we are currently PRIMARY, assume we are about to become REPLICA.
@@ -685,7 +731,7 @@ func (tm *TabletManager) setReplicationSourceLocked(ctx context.Context, parentA
 	// These errors can only be resolved by resetting the replication parameters, otherwise START SLAVE fails. So when this RPC
 	// gets called from VTOrc or replication manager to fix the replication in these cases with forceStartReplication, we should also
 	// reset the replication parameters and set the source port information again.
-	if status.SourceHost != host || status.SourcePort != port || forceStartReplication {
+	if status.SourceHost != host || status.SourcePort != port || forceStartReplication || configOnly {
 		// This handles reseting the replication parameters, changing the address and then starting the replication.
 		if err := tm.MysqlDaemon.SetReplicationSource(ctx, host, port, wasReplicating, shouldbeReplicating); err != nil {
 			if err := tm.handleRelayLogError(err); err != nil {
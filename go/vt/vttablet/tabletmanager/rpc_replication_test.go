@@ -0,0 +1,75 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletmanager
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/vt/mysqlctl/fakemysqldaemon"
+	"vitess.io/vitess/go/vt/topo/memorytopo"
+
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+)
+
+func TestFlushBinaryLogs(t *testing.T) {
+	ctx := context.Background()
+	ts := memorytopo.NewServer("cell1")
+	tm := newTestTM(t, ts, 1, "ks", "0")
+	defer tm.Stop()
+
+	daemon := tm.MysqlDaemon.(*fakemysqldaemon.FakeMysqlDaemon)
+	pos, err := mysql.DecodePosition("MySQL56/16b1039f-22b6-11ed-b765-0a43f95f28a3:1-615")
+	require.NoError(t, err)
+	daemon.CurrentPrimaryPosition = pos
+	daemon.ExpectedExecuteSuperQueryList = []string{"FAKE FLUSH BINARY LOGS"}
+
+	// the binary logs are not flushed on a replica
+	_, err = tm.FlushBinaryLogs(ctx)
+	require.ErrorContains(t, err, "can only be called on a primary tablet")
+	assert.Equal(t, 0, daemon.ExpectedExecuteSuperQueryCurrent)
+
+	err = tm.tmState.ChangeTabletType(ctx, topodatapb.TabletType_PRIMARY, DBActionSetReadWrite)
+	require.NoError(t, err)
+	position, err := tm.FlushBinaryLogs(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, mysql.EncodePosition(pos), position)
+	require.NoError(t, daemon.CheckSuperQueryList())
+}
+
+func TestSetReplicationSourceWithoutStart(t *testing.T) {
+	ctx := context.Background()
+	ts := memorytopo.NewServer("cell1")
+	tm := newTestTM(t, ts, 1, "ks", "0")
+	defer tm.Stop()
+
+	parent := newTestTablet(t, 2, "ks", "0")
+	parent.MysqlHostname = "source.example.com"
+	parent.MysqlPort = 3307
+	require.NoError(t, ts.CreateTablet(ctx, parent))
+
+	daemon := tm.MysqlDaemon.(*fakemysqldaemon.FakeMysqlDaemon)
+	// the replica is replicating from another source
+	daemon.Replicating = true
+	daemon.IOThreadRunning = true
+	daemon.CurrentSourceHost = "old-source.example.com"
+	daemon.CurrentSourcePort = 3306
+	daemon.SetReplicationSourceInputs = []string{"source.example.com:3307"}
+	daemon.ExpectedExecuteSuperQueryList = []string{
+		"STOP SLAVE",
+		"RESET SLAVE ALL",
+		"FAKE SET MASTER",
+	}
+
+	err := tm.SetReplicationSourceWithoutStart(ctx, parent.Alias, false)
+	require.NoError(t, err)
+	// the source is set, but replication isn't started again
+	require.NoError(t, daemon.CheckSuperQueryList())
+}
@@ -786,6 +786,8 @@ func (vcq *vcopierCopyWorkQueue) open(
 
 	poolCapacity := int(math.Max(float64(vcq.maxDepth), 1))
 	vcq.workerPool = pools.NewResourcePool(
+		/* name */
+		"VCopierWorkerPool",
 		/* factory */
 		func(ctx context.Context) (pools.Resource, error) {
 			worker, err := vcq.workerFactory(ctx)
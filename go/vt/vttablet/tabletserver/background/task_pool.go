@@ -81,6 +81,14 @@ func (te *TaskPool) InUse() int64 {
 	return te.conns.InUse()
 }
 
+// GetCount returns the number of connections borrowed from the pool so far, with or without settings.
+func (te *TaskPool) GetCount() int64 {
+	if te.conns == nil {
+		return 0
+	}
+	return te.conns.GetCount() + te.conns.GetSettingCount()
+}
+
 func (te *TaskPool) SetCapacity(size int) {
 	if te.conns == nil || size < 0 {
 		return
@@ -0,0 +1,66 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"vitess.io/vitess/go/acl"
+)
+
+// BufferedTable is a table whose queries are buffered for the cut-over of an online DDL.
+type BufferedTable struct {
+	Table string    `json:"table"`
+	Since time.Time `json:"since"`
+	// BufferingDurationInMs is the time the table has been buffered for.
+	BufferingDurationInMs int64 `json:"buffering_duration_in_ms"`
+}
+
+// onlineDDLQueryRuleSource is the query rule source holding the rule buffering the queries on table.
+func onlineDDLQueryRuleSource(table string) string {
+	return fmt.Sprintf("onlineddl/%s", table)
+}
+
+// BufferedTables returns the tables buffered for the cut-over of an online DDL, sorted by table name,
+// so that operators can tell whether a cut-over is progressing or stuck.
+// Only the tables whose onlineddl/<table> query rule source is installed are listed.
+func (tsv *TabletServer) BufferedTables() []*BufferedTable {
+	tsv.bufferedTablesMu.Lock()
+	defer tsv.bufferedTablesMu.Unlock()
+
+	now := time.Now()
+	tables := make([]*BufferedTable, 0, len(tsv.bufferedTables))
+	for table, since := range tsv.bufferedTables {
+		if _, err := tsv.qe.queryRuleSources.Get(onlineDDLQueryRuleSource(table)); err != nil {
+			continue
+		}
+		tables = append(tables, &BufferedTable{
+			Table:                 table,
+			Since:                 since,
+			BufferingDurationInMs: now.Sub(since).Milliseconds(),
+		})
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Table < tables[j].Table })
+	return tables
+}
+
+func (tsv *TabletServer) registerBufferedTablesHandler() {
+	tsv.exporter.HandleFunc("/debug/buffered_tables", tsv.bufferedTablesHandler)
+}
+
+// bufferedTablesHandler renders the tables buffered for the cut-over of an online DDL as JSON.
+func (tsv *TabletServer) bufferedTablesHandler(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.MONITORING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tsv.BufferedTables())
+}
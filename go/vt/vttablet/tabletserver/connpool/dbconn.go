@@ -386,7 +386,7 @@ func (dbc *DBConn) Recycle() {
 	case dbc.pool == nil:
 		dbc.Close()
 	case dbc.conn.IsClosed():
-		dbc.pool.Put(nil)
+		dbc.pool.Replace(dbc)
 	default:
 		dbc.pool.Put(dbc)
 	}
@@ -397,7 +397,7 @@ func (dbc *DBConn) Taint() {
 	if dbc.pool == nil {
 		return
 	}
-	dbc.pool.Put(nil)
+	dbc.pool.Replace(dbc)
 	dbc.pool = nil
 }
 
@@ -431,6 +431,29 @@ func (dbc *DBConn) Kill(reason string, elapsed time.Duration) error {
 	return nil
 }
 
+// KillQuery kills the currently executing query on MySQL side with KILL QUERY.
+// Unlike Kill, the connection is kept, so are its session state and transaction.
+// If no query is executing, it's a no-op.
+func (dbc *DBConn) KillQuery(reason string) error {
+	dbc.stats.KillCounters.Add("Queries", 1)
+	log.Infof("Due to %s, killing the query of connection ID %v %s", reason, dbc.conn.ID(), dbc.CurrentForLogging())
+
+	killConn, err := dbc.dbaPool.Get(context.TODO())
+	if err != nil {
+		log.Warningf("Failed to get conn from dba pool: %v", err)
+		return err
+	}
+	defer killConn.Recycle()
+	sql := fmt.Sprintf("kill query %d", dbc.conn.ID())
+	_, err = killConn.ExecuteFetch(sql, 10000, false)
+	if err != nil {
+		log.Errorf("Could not kill the query of connection ID %v %s: %v", dbc.conn.ID(),
+			dbc.CurrentForLogging(), err)
+		return err
+	}
+	return nil
+}
+
 // Current returns the currently executing query.
 func (dbc *DBConn) Current() string {
 	return dbc.current.Get()
@@ -70,6 +70,10 @@ type Pool struct {
 	timeout            time.Duration
 	idleTimeout        time.Duration
 	maxLifetime        time.Duration
+	leakThreshold      time.Duration
+	leakCaptureStack   bool
+	badSettingTTL      time.Duration
+	lowWatermark       int64
 	waiterCap          int64
 	waiterCount        sync2.AtomicInt64
 	waiterQueueFull    sync2.AtomicInt64
@@ -98,6 +102,9 @@ func NewPool(env tabletenv.Env, name string, cfg tabletenv.ConnPoolConfig) *Pool
 		timeout:            cfg.TimeoutSeconds.Get(),
 		idleTimeout:        idleTimeout,
 		maxLifetime:        maxLifetime,
+		leakThreshold:      cfg.LeakThresholdSeconds.Get(),
+		leakCaptureStack:   cfg.LeakCaptureStack,
+		badSettingTTL:      cfg.BadSettingTTLSeconds.Get(),
 		waiterCap:          int64(cfg.MaxWaiters),
 		dbaPool:            dbconnpool.NewConnectionPool("DbaPoolOf"+name, 1, idleTimeout, maxLifetime, 0),
 	}
@@ -151,7 +158,15 @@ func (cp *Pool) Open(appParams, dbaParams, appDebugParams dbconfigs.Connector) {
 		refreshCheck = netutil.DNSTracker(appParams.Host())
 	}
 
-	cp.connections = pools.NewResourcePool(f, cp.capacity, cp.maxCapacity, cp.idleTimeout, cp.maxLifetime, cp.getLogWaitCallback(), refreshCheck, mysqlctl.PoolDynamicHostnameResolution)
+	rp := pools.NewResourcePool(cp.name, f, cp.capacity, cp.maxCapacity, cp.idleTimeout, cp.maxLifetime, cp.getLogWaitCallback(), refreshCheck, mysqlctl.PoolDynamicHostnameResolution)
+	if cp.leakThreshold > 0 {
+		rp.SetLeakThreshold(cp.leakThreshold, cp.leakCaptureStack)
+	}
+	if cp.badSettingTTL > 0 {
+		rp.SetBadSettingTTL(cp.badSettingTTL)
+	}
+	rp.SetLowWatermark(cp.lowWatermark)
+	cp.connections = rp
 	cp.appDebugParams = appDebugParams
 
 	cp.dbaPool.Open(dbaParams)
@@ -251,6 +266,16 @@ func (cp *Pool) Put(conn *DBConn) {
 	}
 }
 
+// Replace creates a new connection in the pool in place of conn, which is not returned to it
+// because it's closed or tainted.
+func (cp *Pool) Replace(conn *DBConn) {
+	p := cp.pool()
+	if p == nil {
+		panic(ErrConnPoolClosed)
+	}
+	p.Replace(conn)
+}
+
 // SetCapacity alters the size of the pool at runtime.
 func (cp *Pool) SetCapacity(capacity int) (err error) {
 	cp.mu.Lock()
@@ -308,6 +333,28 @@ func (cp *Pool) Available() int64 {
 	return p.Available()
 }
 
+// ShouldAdmit returns false if the number of available connections
+// dropped below the low watermark of the pool. It returns true if
+// the pool is closed, so that Get reports the proper error.
+func (cp *Pool) ShouldAdmit() bool {
+	p := cp.pool()
+	if p == nil {
+		return true
+	}
+	return p.ShouldAdmit()
+}
+
+// SetLowWatermark sets the number of available connections below
+// which ShouldAdmit returns false. 0 disables the check.
+func (cp *Pool) SetLowWatermark(lowWatermark int64) {
+	cp.mu.Lock()
+	defer cp.mu.Unlock()
+	if cp.connections != nil {
+		cp.connections.SetLowWatermark(lowWatermark)
+	}
+	cp.lowWatermark = lowWatermark
+}
+
 // Active returns the number of active connections in the pool
 func (cp *Pool) Active() int64 {
 	p := cp.pool()
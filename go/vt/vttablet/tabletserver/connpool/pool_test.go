@@ -31,6 +31,7 @@ import (
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 
+	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/mysql/fakesqldb"
 	"vitess.io/vitess/go/pools"
 	"vitess.io/vitess/go/sqltypes"
@@ -568,6 +569,60 @@ func TestPoolGetConnTime(t *testing.T) {
 	assert.EqualValues(t, 1, getTimeMap["PoolTest.GetWithSettings"])
 }
 
+func TestConnPoolLeakThresholdAndBadSettingTTLFromConfig(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+
+	// The env has no config, the leak threshold and the bad setting TTL come from the pool config only.
+	connPool := NewPool(tabletenv.NewEnv(nil, "PoolTest"), "TestPool", tabletenv.ConnPoolConfig{
+		Size:                 10,
+		IdleTimeoutSeconds:   10,
+		LeakThresholdSeconds: 5,
+		BadSettingTTLSeconds: 5,
+	})
+	connPool.Open(db.ConnParams(), db.ConnParams(), db.ConnParams())
+	defer connPool.Close()
+
+	rp, ok := connPool.pool().(*pools.ResourcePool)
+	require.True(t, ok)
+	assert.Equal(t, 5*time.Second, rp.LeakThreshold())
+
+	db.AddRejectedQuery("set sql_mode = ''", mysql.NewSQLError(mysql.ERWrongValueForVar, mysql.SSUnknownSQLState, "bad setting"))
+	setting := pools.NewSetting(false, "set sql_mode = ''", "")
+	_, err := connPool.Get(context.Background(), setting)
+	require.Error(t, err)
+	// The setting is remembered as bad, so it's not applied again even if it would now succeed.
+	db.DeleteRejectedQuery("set sql_mode = ''")
+	db.AddQuery("set sql_mode = ''", &sqltypes.Result{})
+	dbConn, err := connPool.Get(context.Background(), setting)
+	if err == nil {
+		dbConn.Recycle()
+	}
+	require.ErrorContains(t, err, "bad setting")
+	assert.Zero(t, db.GetQueryCalledNum("set sql_mode = ''"))
+}
+
+func TestConnPoolTaintReleasesLabel(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	connPool := newPool()
+	connPool.Open(db.ConnParams(), db.ConnParams(), db.ConnParams())
+	defer connPool.Close()
+	rp, ok := connPool.pool().(*pools.ResourcePool)
+	require.True(t, ok)
+
+	dbConn, err := connPool.Get(pools.WithLabel(context.Background(), "dmljob"), nil)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"dmljob": 1}, rp.InUseByLabel())
+
+	// the tainted connection is still open, it's no longer counted once it's taken out of the pool.
+	dbConn.Taint()
+	defer dbConn.Close()
+	assert.False(t, dbConn.IsClosed())
+	assert.Empty(t, rp.InUseByLabel())
+	assert.Zero(t, rp.InUse())
+}
+
 func newPool() *Pool {
 	return newPoolWithCapacity(100)
 }
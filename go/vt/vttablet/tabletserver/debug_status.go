@@ -0,0 +1,131 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/vt/vttablet/jobcontroller"
+	"vitess.io/vitess/go/vt/vttablet/onlineddl"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/throttle"
+)
+
+// DebugStatus aggregates the state of the tablet server subsystems, as rendered by /debug/status_json.
+// Each section is collected on its own: a subsystem failing only sets the error of its section.
+type DebugStatus struct {
+	Serving    *ServingStatusSection    `json:"serving"`
+	Pools      *PoolsStatusSection      `json:"pools"`
+	Throttler  *ThrottlerStatusSection  `json:"throttler"`
+	Migrations *MigrationsStatusSection `json:"migrations"`
+	DMLJobs    *DMLJobsStatusSection    `json:"dml_jobs"`
+}
+
+// ServingStatusSection is the serving state of the tablet server.
+type ServingStatusSection struct {
+	State           string `json:"state,omitempty"`
+	IsServing       bool   `json:"is_serving"`
+	TransitionError string `json:"transition_error,omitempty"`
+	Error           string `json:"error,omitempty"`
+}
+
+// PoolsStatusSection has the stats of the connection pools, keyed by pool name.
+type PoolsStatusSection struct {
+	Stats map[string]json.RawMessage `json:"stats,omitempty"`
+	Error string                     `json:"error,omitempty"`
+}
+
+// ThrottlerStatusSection is the status of the lag throttler.
+type ThrottlerStatusSection struct {
+	Status *throttle.ThrottlerStatus `json:"status,omitempty"`
+	Error  string                    `json:"error,omitempty"`
+}
+
+// MigrationsStatusSection lists the online DDL migrations in flight.
+type MigrationsStatusSection struct {
+	Migrations []*onlineddl.MigrationProgress `json:"migrations,omitempty"`
+	Error      string                         `json:"error,omitempty"`
+}
+
+// DMLJobsStatusSection lists the non-transactional DML jobs which are not finished yet.
+type DMLJobsStatusSection struct {
+	Jobs  []*jobcontroller.JobInfo `json:"jobs,omitempty"`
+	Error string                   `json:"error,omitempty"`
+}
+
+// GetDebugStatus collects the state of the tablet server subsystems.
+func (tsv *TabletServer) GetDebugStatus(ctx context.Context) *DebugStatus {
+	status := &DebugStatus{
+		Serving:    &ServingStatusSection{},
+		Pools:      &PoolsStatusSection{},
+		Throttler:  &ThrottlerStatusSection{},
+		Migrations: &MigrationsStatusSection{},
+		DMLJobs:    &DMLJobsStatusSection{},
+	}
+	status.Serving.Error = collectDebugStatusSection(func() error {
+		status.Serving.State = tsv.sm.IsServingString()
+		status.Serving.IsServing = tsv.sm.IsServing()
+		status.Serving.TransitionError = tsv.sm.TransitionError()
+		return nil
+	})
+	status.Pools.Error = collectDebugStatusSection(func() error {
+		status.Pools.Stats = map[string]json.RawMessage{
+			"conn_pool":        json.RawMessage(tsv.qe.conns.StatsJSON()),
+			"stream_conn_pool": json.RawMessage(tsv.qe.streamConns.StatsJSON()),
+			"transaction_pool": json.RawMessage(tsv.te.txPool.scp.conns.StatsJSON()),
+		}
+		return nil
+	})
+	status.Throttler.Error = collectDebugStatusSection(func() error {
+		status.Throttler.Status = tsv.lagThrottler.Status()
+		return nil
+	})
+	status.Migrations.Error = collectDebugStatusSection(func() (err error) {
+		status.Migrations.Migrations, err = tsv.onlineDDLExecutor.ListMigrations(ctx, "")
+		return err
+	})
+	status.DMLJobs.Error = collectDebugStatusSection(func() (err error) {
+		status.DMLJobs.Jobs, err = tsv.dmlJonController.ListActiveJobs(ctx)
+		return err
+	})
+	return status
+}
+
+// collectDebugStatusSection runs collect and returns the error it failed with, if any,
+// so that a subsystem failing, or even panicking, doesn't fail the whole status.
+func collectDebugStatusSection(collect func() error) (errMsg string) {
+	defer func() {
+		if x := recover(); x != nil {
+			errMsg = fmt.Sprintf("panic: %v", x)
+		}
+	}()
+	if err := collect(); err != nil {
+		return err.Error()
+	}
+	return ""
+}
+
+// registerDebugStatusHandler registers the JSON counterpart of the /debug/status page,
+// which is already taken by the servenv status page.
+func (tsv *TabletServer) registerDebugStatusHandler() {
+	tsv.exporter.HandleFunc("/debug/status_json", tsv.debugStatusHandler)
+}
+
+// debugStatusHandler renders the state of the tablet server subsystems as JSON.
+func (tsv *TabletServer) debugStatusHandler(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.MONITORING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	ctx, cancel := context.WithTimeout(tabletenv.LocalContext(), tsv.QueryTimeout.Get())
+	defer cancel()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tsv.GetDebugStatus(ctx))
+}
@@ -114,6 +114,8 @@ func debugEnvHandler(tsv *TabletServer, w http.ResponseWriter, r *http.Request)
 			setIntVal(tsv.SetPoolSize)
 		case "StreamPoolSize":
 			setIntVal(tsv.SetStreamPoolSize)
+		case "StreamMaxConcurrency":
+			setIntVal(tsv.SetStreamMaxConcurrency)
 		case "TxPoolSize":
 			setIntVal(tsv.SetTxPoolSize)
 		case "QueryCacheCapacity":
@@ -132,6 +134,12 @@ func debugEnvHandler(tsv *TabletServer, w http.ResponseWriter, r *http.Request)
 			setDurationVal(tsv.sm.SetUnhealthyThreshold)
 		case "ThrottleMetricThreshold":
 			setFloat64Val(tsv.SetThrottleMetricThreshold)
+		case "ThrottleMetric":
+			if err := tsv.SetThrottleMetric(value); err != nil {
+				msg = fmt.Sprintf("Failed setting value for %v: %v", varname, err)
+			} else {
+				msg = fmt.Sprintf("Setting %v to: %v", varname, value)
+			}
 		case "Consolidator":
 			tsv.SetConsolidatorMode(value)
 			msg = fmt.Sprintf("Setting %v to: %v", varname, value)
@@ -141,6 +149,7 @@ func debugEnvHandler(tsv *TabletServer, w http.ResponseWriter, r *http.Request)
 	var vars []envValue
 	vars = addVar(vars, "PoolSize", tsv.PoolSize)
 	vars = addVar(vars, "StreamPoolSize", tsv.StreamPoolSize)
+	vars = addVar(vars, "StreamMaxConcurrency", tsv.StreamMaxConcurrency)
 	vars = addVar(vars, "TxPoolSize", tsv.TxPoolSize)
 	vars = addVar(vars, "QueryCacheCapacity", tsv.QueryPlanCacheCap)
 	vars = addVar(vars, "MaxResultSize", tsv.MaxResultSize)
@@ -149,6 +158,7 @@ func debugEnvHandler(tsv *TabletServer, w http.ResponseWriter, r *http.Request)
 	vars = addVar(vars, "RowStreamerMaxMySQLReplLagSecs", func() int64 { return tsv.Config().RowStreamer.MaxMySQLReplLagSecs })
 	vars = addVar(vars, "UnhealthyThreshold", tsv.Config().Healthcheck.UnhealthyThresholdSeconds.Get)
 	vars = addVar(vars, "ThrottleMetricThreshold", tsv.ThrottleMetricThreshold)
+	vars = addVar(vars, "ThrottleMetric", tsv.ThrottleMetric)
 	vars = append(vars, envValue{
 		Name:  "Consolidator",
 		Value: tsv.ConsolidatorMode(),
@@ -0,0 +1,197 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"vitess.io/vitess/go/mysql"
+	"vitess.io/vitess/go/vt/dbconnpool"
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sidecardb"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/planbuilder"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/rules"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+)
+
+// diskFullQueryRuleSource is the query rule source holding the rule
+// installed while the disk of MySQL is full.
+const diskFullQueryRuleSource = "DiskFullQueryRules"
+
+// diskFullProbeQueries write a row and roll it back, the write fails as long as the disk of MySQL is full.
+var diskFullProbeQueries = []string{
+	"begin",
+	fmt.Sprintf("insert into %s.heartbeat (keyspaceShard, tabletUid, ts) values ('disk_full_probe', 0, 0)", sidecardb.SidecarDBName),
+	"rollback",
+}
+
+// isDiskFullError returns true if err is returned by MySQL because its disk is full.
+// ERRecordFileFull isn't one of them, a table also reports it once it reaches its own size limit, e.g. a MEMORY table.
+func isDiskFullError(err error) bool {
+	sqlErr, ok := err.(*mysql.SQLError)
+	return ok && sqlErr.Number() == mysql.ERDiskFull
+}
+
+// isWritePlan returns true if the plan is rejected while the disk of MySQL is full.
+func isWritePlan(planID planbuilder.PlanType) bool {
+	for _, plan := range keyspaceWritePlans {
+		if plan == planID {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDiskFullError counts the disk full errors returned by MySQL in a row, and makes the tablet
+// reject the writes once there are DiskFullReadOnlyThreshold of them.
+func (tsv *TabletServer) recordDiskFullError(err error) {
+	threshold := tsv.config.DiskFullReadOnlyThreshold
+	if threshold <= 0 || !isDiskFullError(err) {
+		return
+	}
+	if tsv.diskFullErrors.Add(1) >= int64(threshold) {
+		tsv.enterDiskFullReadOnly()
+	}
+}
+
+// recordWriteSucceeded resets the count of disk full errors in a row.
+func (tsv *TabletServer) recordWriteSucceeded(planID planbuilder.PlanType) {
+	if tsv.config.DiskFullReadOnlyThreshold > 0 && isWritePlan(planID) {
+		tsv.diskFullErrors.Set(0)
+	}
+}
+
+// IsDiskFullReadOnly returns true if the writes are rejected because the disk of MySQL is full.
+func (tsv *TabletServer) IsDiskFullReadOnly() bool {
+	tsv.diskFullMu.Lock()
+	defer tsv.diskFullMu.Unlock()
+	return tsv.diskFullReadOnly
+}
+
+// enterDiskFullReadOnly makes the tablet reject the writes, and probes the disk of MySQL
+// until it has space again. The reads are unaffected.
+func (tsv *TabletServer) enterDiskFullReadOnly() {
+	tsv.diskFullMu.Lock()
+	defer tsv.diskFullMu.Unlock()
+	if tsv.diskFullReadOnly {
+		return
+	}
+
+	qr := rules.NewActiveQueryRule("the disk of MySQL is full, writes are rejected until it has space again", "disk_full_read_only", rules.QRFail)
+	for _, plan := range keyspaceWritePlans {
+		qr.AddPlanCond(plan)
+	}
+	qrs := rules.New()
+	qrs.Add(qr)
+	if err := tsv.SetQueryRules(diskFullQueryRuleSource, qrs); err != nil {
+		log.Errorf("Failed to reject the writes while the disk of MySQL is full: %v", err)
+		return
+	}
+	tsv.diskFullReadOnly = true
+	tsv.diskFullProbeDone = make(chan struct{})
+	log.Warningf("MySQL returned %d disk full errors in a row, rejecting the writes until its disk has space again", tsv.diskFullErrors.Get())
+	go tsv.probeDiskFull(tsv.diskFullProbeDone)
+}
+
+// exitDiskFullReadOnly makes the tablet accept the writes again, unless the probe
+// whose done channel is probeDone was stopped in the meantime.
+func (tsv *TabletServer) exitDiskFullReadOnly(probeDone <-chan struct{}) error {
+	tsv.diskFullMu.Lock()
+	defer tsv.diskFullMu.Unlock()
+	if !tsv.diskFullReadOnly || tsv.diskFullProbeDone != probeDone {
+		return nil
+	}
+	if err := tsv.acceptWritesLocked(); err != nil {
+		return err
+	}
+	log.Infof("The disk of MySQL has space again, accepting the writes")
+	return nil
+}
+
+// stopDiskFullProbe stops the probe of the disk of MySQL when the query service stops,
+// and accepts the writes again. The disk full errors are counted from scratch once it starts again.
+func (tsv *TabletServer) stopDiskFullProbe() {
+	tsv.diskFullMu.Lock()
+	defer tsv.diskFullMu.Unlock()
+	if !tsv.diskFullReadOnly {
+		return
+	}
+	if err := tsv.acceptWritesLocked(); err != nil {
+		log.Errorf("Failed to accept the writes again: %v", err)
+	}
+}
+
+// acceptWritesLocked removes the rule rejecting the writes and stops the probe.
+// diskFullMu must be held.
+func (tsv *TabletServer) acceptWritesLocked() error {
+	if err := tsv.SetQueryRules(diskFullQueryRuleSource, rules.New()); err != nil {
+		return err
+	}
+	close(tsv.diskFullProbeDone)
+	tsv.diskFullProbeDone = nil
+	tsv.diskFullReadOnly = false
+	tsv.diskFullErrors.Set(0)
+	return nil
+}
+
+// probeDiskFull tries a write every DiskFullProbeIntervalSeconds, and makes the tablet
+// accept the writes again as soon as one succeeds. It returns once done is closed.
+func (tsv *TabletServer) probeDiskFull(done <-chan struct{}) {
+	interval := tsv.config.DiskFullProbeIntervalSeconds.Get()
+	if interval <= 0 {
+		interval = time.Second
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+		}
+		if err := tsv.checkDiskWritable(); err != nil {
+			log.Infof("The disk of MySQL is still full: %v", err)
+			continue
+		}
+		if err := tsv.exitDiskFullReadOnly(done); err != nil {
+			log.Errorf("Failed to accept the writes again: %v", err)
+			continue
+		}
+		return
+	}
+}
+
+// checkDiskWritable returns an error if MySQL fails to write a row.
+func (tsv *TabletServer) checkDiskWritable() error {
+	ctx, cancel := context.WithTimeout(tabletenv.LocalContext(), tsv.QueryTimeout.Get())
+	defer cancel()
+	conn, err := dbconnpool.NewDBConnection(ctx, tsv.config.DB.DbaWithDB())
+	if err != nil {
+		return err
+	}
+	// closing the connection rolls back the row if the rollback isn't reached.
+	defer conn.Close()
+	for _, query := range diskFullProbeQueries {
+		if _, err := conn.ExecuteFetch(query, 1, false); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// appendDiskFullDetails adds the disk full mode to the health details of the status page.
+func (tsv *TabletServer) appendDiskFullDetails(details []*kv) []*kv {
+	if !tsv.IsDiskFullReadOnly() {
+		return details
+	}
+	return append(details, &kv{
+		Key:   "Disk Full",
+		Class: unhappyClass,
+		Value: "the disk of MySQL is full, writes are rejected until it has space again",
+	})
+}
@@ -0,0 +1,102 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/planbuilder"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/rules"
+)
+
+// keyspaceReadOnlyQueryRuleSource is the query rule source holding the rules
+// installed by SetKeyspaceReadOnly.
+const keyspaceReadOnlyQueryRuleSource = "KeyspaceReadOnlyQueryRules"
+
+// keyspaceWritePlans are the plans rejected for a read-only keyspace.
+var keyspaceWritePlans = []planbuilder.PlanType{
+	planbuilder.PlanInsert,
+	planbuilder.PlanInsertMessage,
+	planbuilder.PlanUpdate,
+	planbuilder.PlanUpdateLimit,
+	planbuilder.PlanDelete,
+	planbuilder.PlanDeleteLimit,
+}
+
+// SetKeyspaceReadOnly makes the tablet reject writes targeting keyspace when ro is true,
+// and accept them again when ro is false. Reads and other keyspaces are unaffected,
+// and the tablet type is left untouched.
+func (tsv *TabletServer) SetKeyspaceReadOnly(keyspace string, ro bool) error {
+	tsv.readOnlyKeyspacesMu.Lock()
+	defer tsv.readOnlyKeyspacesMu.Unlock()
+
+	if ro {
+		tsv.readOnlyKeyspaces[keyspace] = true
+	} else {
+		delete(tsv.readOnlyKeyspaces, keyspace)
+	}
+
+	qrs := rules.New()
+	for _, ks := range tsv.readOnlyKeyspacesLocked() {
+		qr := rules.NewActiveQueryRule(fmt.Sprintf("keyspace %s is read-only", ks), fmt.Sprintf("keyspace_read_only_%s", ks), rules.QRFail)
+		for _, plan := range keyspaceWritePlans {
+			qr.AddPlanCond(plan)
+		}
+		qr.AddTableCond(ks + ".*")
+		qrs.Add(qr)
+	}
+	return tsv.SetQueryRules(keyspaceReadOnlyQueryRuleSource, qrs)
+}
+
+// ReadOnlyKeyspaces returns the sorted list of keyspaces flagged by SetKeyspaceReadOnly.
+func (tsv *TabletServer) ReadOnlyKeyspaces() []string {
+	tsv.readOnlyKeyspacesMu.Lock()
+	defer tsv.readOnlyKeyspacesMu.Unlock()
+	return tsv.readOnlyKeyspacesLocked()
+}
+
+func (tsv *TabletServer) readOnlyKeyspacesLocked() []string {
+	keyspaces := make([]string, 0, len(tsv.readOnlyKeyspaces))
+	for ks := range tsv.readOnlyKeyspaces {
+		keyspaces = append(keyspaces, ks)
+	}
+	sort.Strings(keyspaces)
+	return keyspaces
+}
+
+// registerKeyspaceReadOnlyHandler registers the handler to list and toggle the read-only keyspaces.
+// A POST with "keyspace" and "readonly" form values changes the mode of a keyspace.
+func (tsv *TabletServer) registerKeyspaceReadOnlyHandler() {
+	tsv.exporter.HandleFunc("/debug/keyspace_read_only", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		if r.Method == "POST" {
+			keyspace := r.FormValue("keyspace")
+			if keyspace == "" {
+				http.Error(w, "not ok: missing keyspace", http.StatusBadRequest)
+				return
+			}
+			ro, err := strconv.ParseBool(r.FormValue("readonly"))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("not ok: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := tsv.SetKeyspaceReadOnly(keyspace, ro); err != nil {
+				http.Error(w, fmt.Sprintf("not ok: %v", err), http.StatusInternalServerError)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tsv.ReadOnlyKeyspaces())
+	})
+}
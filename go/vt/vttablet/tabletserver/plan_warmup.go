@@ -0,0 +1,38 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"context"
+	"fmt"
+
+	"vitess.io/vitess/go/vt/concurrency"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+)
+
+// WarmupPlans builds the plans of queries and caches them, without executing the queries,
+// so that their first executions after a restart or a plan cache clear don't pay the plan build.
+// The plans are cached like the ones of Execute on target, so the queries should be sent
+// the same way they are executed, e.g. with bind variables instead of literals if they are normalized.
+// A query whose plan can't be built doesn't stop the warmup, the errors are returned together.
+func (tsv *TabletServer) WarmupPlans(ctx context.Context, target *querypb.Target, queries []string) error {
+	var allErr concurrency.AllErrorRecorder
+	for _, sql := range queries {
+		if err := ctx.Err(); err != nil {
+			allErr.RecordError(err)
+			break
+		}
+		logStats := tabletenv.NewLogStats(ctx, "WarmupPlans")
+		// like Execute, the plans are cached without the margin comments
+		query, _ := sqlparser.SplitMarginComments(sql)
+		if _, err := tsv.qe.GetPlan(ctx, logStats, target.GetKeyspace(), query, false); err != nil {
+			allErr.RecordError(fmt.Errorf("failed to build the plan of %s: %v", sqlparser.TruncateForLog(sql), err))
+		}
+	}
+	return allErr.Error()
+}
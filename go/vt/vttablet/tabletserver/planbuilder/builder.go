@@ -32,6 +32,7 @@ import (
 	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/schema"
 
+	querypb "vitess.io/vitess/go/vt/proto/query"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
 
@@ -68,6 +69,9 @@ func analyzeSelect(sel *sqlparser.Select, tables map[string]*schema.Table) (plan
 		plan.PlanID = PlanSelectLockFunc
 		plan.NeedsReservedConn = true
 	}
+	if plan.PlanID == PlanSelect {
+		plan.OrderedQuery = GenerateOrderedLimitQuery(sel, plan.Table)
+	}
 	return plan, nil
 }
 
@@ -133,9 +137,28 @@ func analyzeInsert(ins *sqlparser.Insert, tables map[string]*schema.Table) (plan
 
 	tableName := sqlparser.GetTableName(ins.Table)
 	plan.Table = tables[tableName.String()]
+	plan.ConsecutiveInsertIDs = generatesConsecutiveInsertIDs(ins, plan.Table)
 	return plan, nil
 }
 
+// generatesConsecutiveInsertIDs returns true if MySQL generates the AUTO_INCREMENT value of
+// every row inserted by ins. Rows ignored or updated on duplicate keys, rows coming from a
+// select and explicit AUTO_INCREMENT values would make the generated values unpredictable.
+func generatesConsecutiveInsertIDs(ins *sqlparser.Insert, table *schema.Table) bool {
+	if table == nil || ins.Action != sqlparser.InsertAct || ins.Ignore || len(ins.OnDup) != 0 || len(ins.Columns) == 0 {
+		return false
+	}
+	if _, ok := ins.Rows.(sqlparser.Values); !ok {
+		return false
+	}
+	for _, field := range table.Fields {
+		if field.Flags&uint32(querypb.MySqlFlag_AUTO_INCREMENT_FLAG) != 0 && ins.Columns.FindColumn(sqlparser.NewIdentifierCI(field.Name)) >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
 func analyzeShow(show *sqlparser.Show, dbName string) (plan *Plan, err error) {
 	switch showInternal := show.Internal.(type) {
 	case *sqlparser.ShowBasic:
@@ -158,6 +181,8 @@ func analyzeShow(show *sqlparser.Show, dbName string) (plan *Plan, err error) {
 			PlanID:    PlanShow,
 			FullQuery: GenerateFullQuery(show),
 		}, nil
+	case *sqlparser.ShowDMLJob:
+		return &Plan{PlanID: PlanShowDMLJob, FullStmt: show}, nil
 	}
 	return &Plan{PlanID: PlanOtherRead}, nil
 }
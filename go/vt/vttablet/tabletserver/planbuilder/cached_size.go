@@ -63,6 +63,8 @@ func (cached *Plan) CachedSize(alloc bool) int64 {
 	}
 	// field FullQuery *vitess.io/vitess/go/vt/sqlparser.ParsedQuery
 	size += cached.FullQuery.CachedSize(true)
+	// field OrderedQuery *vitess.io/vitess/go/vt/sqlparser.ParsedQuery
+	size += cached.OrderedQuery.CachedSize(true)
 	// field NextCount vitess.io/vitess/go/vt/vtgate/evalengine.Expr
 	if cc, ok := cached.NextCount.(cachedObject); ok {
 		size += cc.CachedSize(true)
@@ -82,6 +82,7 @@ const (
 	PlanCallProc
 	PlanAlterMigration
 	PlanAlterDMLJob
+	PlanShowDMLJob
 	PlanRevertMigration
 	PlanShowMigrationLogs
 	PlanShowThrottledApps
@@ -119,6 +120,7 @@ var planName = []string{
 	"CallProcedure",
 	"AlterMigration",
 	"AlterDMLJob",
+	"ShowDMLJob",
 	"RevertMigration",
 	"ShowMigrationLogs",
 	"ShowThrottledApps",
@@ -174,6 +176,11 @@ type Plan struct {
 	// FullQuery will be set for all plans.
 	FullQuery *sqlparser.ParsedQuery
 
+	// OrderedQuery is set for the selects that can be ordered by the primary key
+	// of their table. It is executed instead of FullQuery if the caller asks for
+	// a deterministic order.
+	OrderedQuery *sqlparser.ParsedQuery
+
 	// NextCount stores the count for "select next".
 	NextCount evalengine.Expr
 
@@ -186,6 +193,10 @@ type Plan struct {
 
 	// NeedsReservedConn indicates at a reserved connection is needed to execute this plan
 	NeedsReservedConn bool
+
+	// ConsecutiveInsertIDs is set for inserts that let MySQL generate the AUTO_INCREMENT
+	// values of all their rows, which are then consecutive.
+	ConsecutiveInsertIDs bool
 }
 
 // TableName returns the table name for the plan.
@@ -37,6 +37,7 @@ import (
 
 	"github.com/stretchr/testify/require"
 
+	querypb "vitess.io/vitess/go/vt/proto/query"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/tableacl"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/schema"
@@ -287,6 +288,95 @@ func TestLockPlan(t *testing.T) {
 	}
 }
 
+func TestGeneratesConsecutiveInsertIDs(t *testing.T) {
+	table := &schema.Table{
+		Name: sqlparser.NewIdentifierCS("t"),
+		Fields: []*querypb.Field{{
+			Name:  "id",
+			Type:  querypb.Type_INT64,
+			Flags: uint32(querypb.MySqlFlag_AUTO_INCREMENT_FLAG),
+		}, {
+			Name: "name",
+			Type: querypb.Type_VARCHAR,
+		}},
+	}
+	testcases := []struct {
+		query string
+		want  bool
+	}{
+		{"insert into t(name) values ('a'), ('b')", true},
+		{"insert into t(id, name) values (1, 'a'), (2, 'b')", false},
+		{"insert into t(ID, name) values (null, 'a')", false},
+		{"insert into t values (null, 'a')", false},
+		{"insert ignore into t(name) values ('a')", false},
+		{"insert into t(name) values ('a') on duplicate key update name = 'b'", false},
+		{"insert into t(name) select name from t", false},
+		{"replace into t(name) values ('a')", false},
+	}
+	for _, tcase := range testcases {
+		t.Run(tcase.query, func(t *testing.T) {
+			stmt, err := sqlparser.Parse(tcase.query)
+			require.NoError(t, err)
+			require.Equal(t, tcase.want, generatesConsecutiveInsertIDs(stmt.(*sqlparser.Insert), table))
+		})
+	}
+}
+
+func TestGenerateOrderedLimitQuery(t *testing.T) {
+	table := &schema.Table{
+		Name: sqlparser.NewIdentifierCS("t"),
+		Fields: []*querypb.Field{{
+			Name: "id1",
+			Type: querypb.Type_INT64,
+		}, {
+			Name: "id2",
+			Type: querypb.Type_INT64,
+		}, {
+			Name: "name",
+			Type: querypb.Type_VARCHAR,
+		}},
+		PKColumns: []int{0, 1},
+	}
+	tables := map[string]*schema.Table{"t": table, "nopk": schema.NewTable("nopk")}
+	testcases := []struct {
+		query string
+		want  string
+	}{
+		{"select * from t", "select * from t order by t.id1 asc, t.id2 asc limit :#maxLimit"},
+		{"select name as id1 from t where name = 'a' limit 10", "select `name` as id1 from t where `name` = 'a' order by t.id1 asc, t.id2 asc limit 10"},
+		{"select * from t as a for update", "select * from t as a order by a.id1 asc, a.id2 asc limit :#maxLimit for update"},
+		{"select * from t order by name", ""},
+		{"select name from t group by name", ""},
+		{"select distinct name from t", ""},
+		{"select count(*) from t", ""},
+		{"select * from t join nopk", ""},
+		{"select * from nopk", ""},
+		{"select * from db.t", ""},
+		{"select * from (select * from t) as x", ""},
+		{"with x as (select * from t) select * from t", ""},
+	}
+	for _, tcase := range testcases {
+		t.Run(tcase.query, func(t *testing.T) {
+			stmt, err := sqlparser.Parse(tcase.query)
+			require.NoError(t, err)
+			sel := stmt.(*sqlparser.Select)
+			var single *schema.Table
+			if len(sel.From) == 1 {
+				single = lookupSingleTable(sel.From[0], tables)
+			}
+			got := GenerateOrderedLimitQuery(sel, single)
+			if tcase.want == "" {
+				require.Nil(t, got)
+				return
+			}
+			require.NotNil(t, got)
+			require.Equal(t, tcase.want, got.Query)
+			// the select is left unordered
+			require.Empty(t, sel.OrderBy)
+		})
+	}
+}
+
 func loadSchema(name string) map[string]*schema.Table {
 	b, err := os.ReadFile(locateFile(name))
 	if err != nil {
@@ -18,6 +18,7 @@ package planbuilder
 
 import (
 	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/schema"
 )
 
 // GenerateFullQuery generates the full query from the ast.
@@ -64,3 +65,39 @@ func GenerateLimitQuery(selStmt sqlparser.SelectStatement) *sqlparser.ParsedQuer
 	buf.Myprintf("%v", selStmt)
 	return buf.ParsedQuery()
 }
+
+// GenerateOrderedLimitQuery generates a select query with a limit clause that orders
+// the rows by the primary key of the table. It returns nil if the select doesn't read
+// a single table with a primary key, or if an ORDER BY on the primary key could change
+// its result, e.g. it already has an ORDER BY, or it groups or aggregates the rows.
+func GenerateOrderedLimitQuery(sel *sqlparser.Select, table *schema.Table) *sqlparser.ParsedQuery {
+	if table == nil || !table.HasPrimary() || len(sel.From) != 1 || sel.With != nil || sel.Into != nil ||
+		len(sel.OrderBy) != 0 || len(sel.GroupBy) != 0 || sel.Having != nil || sel.Distinct ||
+		sqlparser.ContainsAggregation(sel.SelectExprs) {
+		return nil
+	}
+	aliased, ok := sel.From[0].(*sqlparser.AliasedTableExpr)
+	if !ok {
+		return nil
+	}
+	// the columns are qualified so that they aren't mistaken for the aliases of the select expressions.
+	qualifier, err := aliased.TableName()
+	if err != nil || !qualifier.Qualifier.IsEmpty() {
+		return nil
+	}
+	orderBy := make(sqlparser.OrderBy, 0, len(table.PKColumns))
+	for _, pkCol := range table.PKColumns {
+		if pkCol >= len(table.Fields) {
+			return nil
+		}
+		orderBy = append(orderBy, &sqlparser.Order{
+			Expr:      sqlparser.NewColNameWithQualifier(table.Fields[pkCol].Name, qualifier),
+			Direction: sqlparser.AscOrder,
+		})
+	}
+	sel.OrderBy = orderBy
+	defer func() {
+		sel.OrderBy = nil
+	}()
+	return GenerateLimitQuery(sel)
+}
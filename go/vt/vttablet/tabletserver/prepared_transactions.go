@@ -0,0 +1,81 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+)
+
+// preparedTransactionsPollInterval is how often DrainPreparedTransactions checks whether the prepared transactions are resolved.
+var preparedTransactionsPollInterval = 100 * time.Millisecond
+
+// PreparedTransactions is the list of prepared transactions, as rendered by /debug/prepared_transactions.
+type PreparedTransactions struct {
+	Dtids []string `json:"dtids"`
+	// Drained is true if the prepared transactions were waited for and they are all resolved.
+	Drained bool `json:"drained"`
+}
+
+// OutstandingPreparedTransactions returns the dtids of the prepared (2PC) transactions which are
+// not committed or rolled back yet. Shutting down the tablet with outstanding prepared transactions
+// leaves them to be resolved by the recovery once it's back.
+func (tsv *TabletServer) OutstandingPreparedTransactions(ctx context.Context) []string {
+	return tsv.te.preparedPool.Dtids()
+}
+
+// DrainPreparedTransactions waits until there is no outstanding prepared transaction or ctx is done,
+// and returns the dtids of the prepared transactions which are still outstanding.
+// It doesn't prevent new transactions from being prepared meanwhile.
+func (tsv *TabletServer) DrainPreparedTransactions(ctx context.Context) []string {
+	ticker := time.NewTicker(preparedTransactionsPollInterval)
+	defer ticker.Stop()
+	for {
+		dtids := tsv.OutstandingPreparedTransactions(ctx)
+		if len(dtids) == 0 {
+			return dtids
+		}
+		select {
+		case <-ctx.Done():
+			return dtids
+		case <-ticker.C:
+		}
+	}
+}
+
+func (tsv *TabletServer) registerPreparedTransactionsHandler() {
+	tsv.exporter.HandleFunc("/debug/prepared_transactions", tsv.preparedTransactionsHandler)
+}
+
+// preparedTransactionsHandler renders the outstanding prepared transactions as JSON.
+// If the wait parameter is set to a duration, e.g. wait=30s, it waits up to that long for them to be resolved first.
+func (tsv *TabletServer) preparedTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.MONITORING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	transactions := &PreparedTransactions{}
+	if waitStr := r.FormValue("wait"); waitStr != "" {
+		wait, err := time.ParseDuration(waitStr)
+		if err != nil {
+			http.Error(w, "invalid wait: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		ctx, cancel := context.WithTimeout(tabletenv.LocalContext(), wait)
+		defer cancel()
+		transactions.Dtids = tsv.DrainPreparedTransactions(ctx)
+		transactions.Drained = len(transactions.Dtids) == 0
+	} else {
+		transactions.Dtids = tsv.OutstandingPreparedTransactions(tabletenv.LocalContext())
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(transactions)
+}
@@ -160,6 +160,7 @@ type QueryEngine struct {
 	// Services
 	consolidator       *sync2.Consolidator
 	streamConsolidator *StreamConsolidator
+	resultCache        *ResultCache
 	// txSerializer protects vttablet from applications which try to concurrently
 	// UPDATE (or DELETE) a "hot" row (or range of rows).
 	// Such queries would be serialized by MySQL anyway. This serializer prevents
@@ -173,6 +174,10 @@ type QueryEngine struct {
 	maxResultSize    sync2.AtomicInt64
 	warnResultSize   sync2.AtomicInt64
 	streamBufferSize sync2.AtomicInt64
+	// streamMaxConcurrency is the maximum number of streams executed at the same time, 0 means no limit.
+	streamMaxConcurrency sync2.AtomicInt64
+	activeStreams        sync2.AtomicInt64
+	rejectedStreams      sync2.AtomicInt64
 	// tableaclExemptCount count the number of accesses allowed
 	// based on membership in the superuser ACL
 	tableaclExemptCount  sync2.AtomicInt64
@@ -183,6 +188,9 @@ type QueryEngine struct {
 
 	strictTransTables bool
 
+	// allowedSettingVariables are the system variables the connection settings can set, nil allows every variable.
+	allowedSettingVariables map[string]bool
+
 	consolidatorMode sync2.AtomicString
 
 	// stats
@@ -238,10 +246,22 @@ func NewQueryEngine(env tabletenv.Env, se *schema.Engine) *QueryEngine {
 	} else {
 		log.Info("Stream consolidator is not enabled.")
 	}
+	qe.resultCache = NewResultCache(config.ResultCacheTables, config.ResultCacheTTLSeconds.Get(), config.ResultCacheSize)
+	if qe.resultCache.Enabled() {
+		log.Infof("Result cache is enabled for tables %v with ttl %v and size %d.",
+			config.ResultCacheTables, config.ResultCacheTTLSeconds.Get(), config.ResultCacheSize)
+	}
 	qe.txSerializer = txserializer.New(env)
 	qe.concurrencyController = ccl.New(env.Exporter())
 	qe.wasmPluginController = NewWasmPluginController(qe)
 
+	if len(config.AllowedSettingVariables) > 0 {
+		qe.allowedSettingVariables = make(map[string]bool, len(config.AllowedSettingVariables))
+		for _, name := range config.AllowedSettingVariables {
+			qe.allowedSettingVariables[strings.ToLower(strings.TrimSpace(name))] = true
+		}
+	}
+
 	qe.strictTableACL = config.StrictTableACL
 	qe.enableTableACLDryRun = config.EnableTableACLDryRun
 
@@ -261,6 +281,7 @@ func NewQueryEngine(env tabletenv.Env, se *schema.Engine) *QueryEngine {
 	qe.maxResultSize = sync2.NewAtomicInt64(int64(config.Oltp.MaxRows))
 	qe.warnResultSize = sync2.NewAtomicInt64(int64(config.Oltp.WarnRows))
 	qe.streamBufferSize = sync2.NewAtomicInt64(int64(config.StreamBufferSize))
+	qe.streamMaxConcurrency = sync2.NewAtomicInt64(int64(config.StreamMaxConcurrency))
 
 	planbuilder.PassthroughDMLs = config.PassthroughDML
 
@@ -269,6 +290,9 @@ func NewQueryEngine(env tabletenv.Env, se *schema.Engine) *QueryEngine {
 	env.Exporter().NewGaugeFunc("MaxResultSize", "Query engine max result size", qe.maxResultSize.Get)
 	env.Exporter().NewGaugeFunc("WarnResultSize", "Query engine warn result size", qe.warnResultSize.Get)
 	env.Exporter().NewGaugeFunc("StreamBufferSize", "Query engine stream buffer size", qe.streamBufferSize.Get)
+	env.Exporter().NewGaugeFunc("StreamMaxConcurrency", "Query engine stream max concurrency", qe.streamMaxConcurrency.Get)
+	env.Exporter().NewGaugeFunc("StreamActive", "Query engine active streams", qe.activeStreams.Get)
+	env.Exporter().NewCounterFunc("StreamRejected", "Query engine streams rejected by the stream max concurrency", qe.rejectedStreams.Get)
 	env.Exporter().NewCounterFunc("TableACLExemptCount", "Query engine table ACL exempt count", qe.tableaclExemptCount.Get)
 
 	env.Exporter().NewGaugeFunc("QueryCacheLength", "Query engine query cache length", func() int64 {
@@ -277,6 +301,11 @@ func NewQueryEngine(env tabletenv.Env, se *schema.Engine) *QueryEngine {
 	env.Exporter().NewGaugeFunc("QueryCacheSize", "Query engine query cache size", qe.plans.UsedCapacity)
 	env.Exporter().NewGaugeFunc("QueryCacheCapacity", "Query engine query cache capacity", qe.plans.MaxCapacity)
 	env.Exporter().NewCounterFunc("QueryCacheEvictions", "Query engine query cache evictions", qe.plans.Evictions)
+	env.Exporter().NewGaugeFunc("ResultCacheLength", "Query engine result cache length", func() int64 {
+		return int64(qe.resultCache.Len())
+	})
+	env.Exporter().NewCounterFunc("ResultCacheHits", "Query engine result cache hits", qe.resultCache.hits.Get)
+	env.Exporter().NewCounterFunc("ResultCacheMisses", "Query engine result cache misses", qe.resultCache.misses.Get)
 	qe.queryCounts = env.Exporter().NewCountersWithMultiLabels("QueryCounts", "query counts", []string{"Table", "Plan"})
 	qe.queryTimes = env.Exporter().NewCountersWithMultiLabels("QueryTimesNs", "query times in ns", []string{"Table", "Plan"})
 	qe.queryRowsAffected = env.Exporter().NewCountersWithMultiLabels("QueryRowsAffected", "query rows affected", []string{"Table", "Plan"})
@@ -291,12 +320,35 @@ func NewQueryEngine(env tabletenv.Env, se *schema.Engine) *QueryEngine {
 	env.Exporter().HandleFunc("/debug/tablet_plans_json", qe.handleHTTPTabletPlansJSON)
 	env.Exporter().HandleFunc("/debug/query_stats", qe.handleHTTPQueryStats)
 	env.Exporter().HandleFunc("/debug/query_rules", qe.handleHTTPQueryRules)
+	env.Exporter().HandleFunc("/debug/query-rules", qe.handleHTTPQueryRulesForMonitoring)
 	env.Exporter().HandleFunc("/debug/consolidations", qe.handleHTTPConsolidations)
 	env.Exporter().HandleFunc("/debug/acl", qe.handleHTTPAclJSON)
 
 	return qe
 }
 
+// acquireStream counts a stream as active. It returns false if streamMaxConcurrency
+// streams are already active, in which case the stream must be rejected.
+// releaseStream must be called once an accepted stream is done.
+func (qe *QueryEngine) acquireStream() bool {
+	for {
+		maxStreams := qe.streamMaxConcurrency.Get()
+		active := qe.activeStreams.Get()
+		if maxStreams > 0 && active >= maxStreams {
+			qe.rejectedStreams.Add(1)
+			return false
+		}
+		if qe.activeStreams.CompareAndSwap(active, active+1) {
+			return true
+		}
+	}
+}
+
+// releaseStream counts an accepted stream as done.
+func (qe *QueryEngine) releaseStream() {
+	qe.activeStreams.Add(-1)
+}
+
 // Open must be called before sending requests to QueryEngine.
 func (qe *QueryEngine) Open() error {
 	if qe.isOpen {
@@ -341,6 +393,7 @@ func (qe *QueryEngine) Close() {
 	// Close in reverse order of Open.
 	qe.se.UnregisterNotifier("qe")
 	qe.plans.Clear()
+	qe.resultCache.Clear()
 	qe.tables = make(map[string]*schema.Table)
 	qe.streamWithoutDBConns.Close()
 	qe.withoutDBConns.Close()
@@ -354,8 +407,9 @@ func (qe *QueryEngine) Close() {
 func (qe *QueryEngine) GetPlan(ctx context.Context, logStats *tabletenv.LogStats, dbName string, sql string, skipQueryPlanCache bool) (*TabletPlan, error) {
 	span, _ := trace.NewSpan(ctx, "QueryEngine.GetPlan")
 	defer span.Finish()
+	cacheKey := planCacheKey(dbName, sql)
 	if !skipQueryPlanCache {
-		if plan := qe.getQuery(sql); plan != nil {
+		if plan := qe.getQuery(cacheKey); plan != nil {
 			logStats.CachedPlan = true
 			return plan, nil
 		}
@@ -383,11 +437,28 @@ func (qe *QueryEngine) GetPlan(ctx context.Context, logStats *tabletenv.LogStats
 		return plan, nil
 	}
 	if !skipQueryPlanCache && !sqlparser.SkipQueryPlanCacheDirective(statement) && plan.Authorized != nil {
-		qe.plans.Set(sql, plan)
+		qe.plans.Set(cacheKey, plan)
 	}
 	return plan, nil
 }
 
+// forgetPlan removes the plan of sql from the query plan cache, so that the
+// next call to GetPlan builds a fresh one.
+func (qe *QueryEngine) forgetPlan(dbName string, sql string) {
+	qe.plans.Delete(planCacheKey(dbName, sql))
+}
+
+// planCacheKey returns the key of the plan of sql in the query plan cache.
+// Plans are built against the database they are executed in, which decides
+// the tables they touch and therefore the query rules attached to them, so
+// the same query executed in different databases gets distinct cache entries.
+func planCacheKey(dbName string, sql string) string {
+	if dbName == "" {
+		return sql
+	}
+	return dbName + ":" + sql
+}
+
 // GetStreamPlan is similar to GetPlan, but doesn't use the cache
 // and doesn't enforce a limit. It just returns the parsed query.
 func (qe *QueryEngine) GetStreamPlan(sql string, dbName string) (*TabletPlan, error) {
@@ -433,6 +504,11 @@ func (qe *QueryEngine) GetConnSetting(ctx context.Context, settings []string) (*
 		return plan, nil
 	}
 
+	// the cached settings have been checked already.
+	if err := qe.checkSettingsAllowed(settings); err != nil {
+		return nil, err
+	}
+
 	// build the setting queries
 	query, resetQuery, err := planbuilder.BuildSettingQuery(settings)
 	if err != nil {
@@ -446,6 +522,30 @@ func (qe *QueryEngine) GetConnSetting(ctx context.Context, settings []string) (*
 	return connSetting, nil
 }
 
+// checkSettingsAllowed returns an error if a setting sets a system variable
+// that isn't in queryserver-config-allowed-setting-variables.
+func (qe *QueryEngine) checkSettingsAllowed(settings []string) error {
+	if qe.allowedSettingVariables == nil {
+		return nil
+	}
+	for _, setting := range settings {
+		stmt, err := sqlparser.Parse(setting)
+		if err != nil {
+			return vterrors.Wrapf(err, "failed to parse system setting: %s", setting)
+		}
+		set, ok := stmt.(*sqlparser.Set)
+		if !ok {
+			return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "invalid set statement: %s", setting)
+		}
+		for _, expr := range set.Exprs {
+			if name := expr.Var.Name.Lowered(); !qe.allowedSettingVariables[name] {
+				return vterrors.Errorf(vtrpcpb.Code_PERMISSION_DENIED, "system variable %s is not allowed to be set, allowed variables are set by queryserver-config-allowed-setting-variables", name)
+			}
+		}
+	}
+	return nil
+}
+
 // ClearQueryPlanCache should be called if query plan cache is potentially obsolete
 func (qe *QueryEngine) ClearQueryPlanCache() {
 	qe.plans.Clear()
@@ -465,18 +565,21 @@ func (qe *QueryEngine) IsMySQLReachable() error {
 	return nil
 }
 
-func (qe *QueryEngine) schemaChanged(tables map[string]*schema.Table, _, altered, dropped []string) {
+func (qe *QueryEngine) schemaChanged(tables map[string]*schema.Table, created, altered, dropped []string) {
 	qe.mu.Lock()
 	defer qe.mu.Unlock()
 	qe.tables = tables
 	if len(altered) != 0 || len(dropped) != 0 {
 		qe.plans.Clear()
 	}
+	qe.resultCache.Invalidate(created...)
+	qe.resultCache.Invalidate(altered...)
+	qe.resultCache.Invalidate(dropped...)
 }
 
-// getQuery fetches the plan and makes it the most recent.
-func (qe *QueryEngine) getQuery(sql string) *TabletPlan {
-	cacheResult, ok := qe.plans.Get(sql)
+// getQuery fetches the plan cached under key and makes it the most recent.
+func (qe *QueryEngine) getQuery(key string) *TabletPlan {
+	cacheResult, ok := qe.plans.Get(key)
 	if !ok {
 		return nil
 	}
@@ -661,6 +764,21 @@ func (qe *QueryEngine) handleHTTPQueryRules(response http.ResponseWriter, reques
 		acl.SendError(response, err)
 		return
 	}
+	qe.writeQueryRules(response)
+}
+
+// handleHTTPQueryRulesForMonitoring serves the same rules as handleHTTPQueryRules to monitoring.
+// It only shows the active rule set, to help finding why a query is buffered or rejected.
+func (qe *QueryEngine) handleHTTPQueryRulesForMonitoring(response http.ResponseWriter, request *http.Request) {
+	if err := acl.CheckAccessHTTP(request, acl.MONITORING); err != nil {
+		acl.SendError(response, err)
+		return
+	}
+	qe.writeQueryRules(response)
+}
+
+// writeQueryRules renders the rules of all the registered query rule sources as JSON, keyed by source.
+func (qe *QueryEngine) writeQueryRules(response http.ResponseWriter) {
 	response.Header().Set("Content-Type", "application/json; charset=utf-8")
 	b, err := json.MarshalIndent(qe.queryRuleSources, "", " ")
 	if err != nil {
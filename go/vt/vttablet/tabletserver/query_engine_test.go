@@ -49,7 +49,9 @@ import (
 	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/streamlog"
 	"vitess.io/vitess/go/vt/dbconfigs"
+	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 	"vitess.io/vitess/go/vt/tableacl"
+	"vitess.io/vitess/go/vt/vterrors"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/background"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/planbuilder"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/schema"
@@ -688,6 +690,32 @@ func TestPlanPoolUnsafe(t *testing.T) {
 	}
 }
 
+func TestGetConnSettingAllowedVariables(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+
+	// the allowlist is opt-in, every variable is allowed by default.
+	qe, _ := newTestQueryEngine(1*time.Second, true, newDBConfigs(db))
+	setting, err := qe.GetConnSetting(context.Background(), []string{"set @@character_set_server = 'utf8mb4'", "set @@sql_log_bin = 0"})
+	require.NoError(t, err)
+	assert.Equal(t, "set @@character_set_server = 'utf8mb4', @@sql_log_bin = 0", setting.GetQuery())
+
+	config := tabletenv.NewDefaultConfig()
+	config.DB = newDBConfigs(db)
+	config.AllowedSettingVariables = []string{"sql_mode", " Time_Zone "}
+	env := tabletenv.NewEnv(config, "TabletServerTest")
+	qe = NewQueryEngine(env, schema.NewEngine(env, background.NewTaskPool(env)))
+
+	_, err = qe.GetConnSetting(context.Background(), []string{"set @@sql_mode = ''", "set @@sql_log_bin = 0"})
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_PERMISSION_DENIED, vterrors.Code(err))
+	assert.Contains(t, err.Error(), "system variable sql_log_bin is not allowed to be set")
+
+	setting, err = qe.GetConnSetting(context.Background(), []string{"set @@sql_mode = ''", "set @@time_zone = '+08:00'"})
+	require.NoError(t, err)
+	assert.Equal(t, "set @@sql_mode = '', @@time_zone = '+08:00'", setting.GetQuery())
+}
+
 func TestXXHash(t *testing.T) {
 
 	tests := []struct {
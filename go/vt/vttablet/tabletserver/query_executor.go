@@ -23,6 +23,7 @@ package tabletserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"strconv"
@@ -71,6 +72,12 @@ type QueryExecutor struct {
 	setting           *pools.Setting
 	matchedActionList []ActionInterface
 	calledActionList  []ActionInterface
+	// rowsExamined is the number of rows examined by the queries executed on MySQL,
+	// it's only counted when ExecuteOptions.ReportRowsExamined is set.
+	rowsExamined uint64
+	// threadID is the id of the MySQL connection the query was last executed on,
+	// it's only recorded when ExecuteOptions.ReportThreadId is set.
+	threadID uint64
 }
 
 const (
@@ -108,6 +115,9 @@ func allocStreamResult() *sqltypes.Result {
 }
 
 func (qre *QueryExecutor) shouldConsolidate() bool {
+	if qre.options.GetBypassConsolidation() {
+		return false
+	}
 	co := qre.options.GetConsolidator()
 	switch co {
 	case querypb.ExecuteOptions_CONSOLIDATOR_DISABLED:
@@ -126,6 +136,7 @@ func (qre *QueryExecutor) shouldConsolidate() bool {
 func (qre *QueryExecutor) Execute() (reply *sqltypes.Result, err error) {
 	planName := qre.plan.PlanID.String()
 	qre.logStats.PlanType = planName
+	qre.logStats.TableName = qre.plan.TableName()
 	defer func(start time.Time) {
 		duration := time.Since(start)
 		qre.tsv.stats.QueryTimings.Add(planName, duration)
@@ -222,6 +233,8 @@ func (qre *QueryExecutor) Execute() (reply *sqltypes.Result, err error) {
 		return qre.execAlterMigration()
 	case p.PlanAlterDMLJob:
 		return qre.execAlterDMLJob()
+	case p.PlanShowDMLJob:
+		return qre.execShowDMLJob()
 	case p.PlanRevertMigration:
 		return qre.execRevertMigration()
 	case p.PlanShowMigrationLogs:
@@ -303,7 +316,14 @@ func (qre *QueryExecutor) execAsTransaction(f func(conn *StatefulConnection) (*s
 
 func (qre *QueryExecutor) txConnExec(conn *StatefulConnection) (*sqltypes.Result, error) {
 	switch qre.plan.PlanID {
-	case p.PlanInsert, p.PlanUpdate, p.PlanDelete, p.PlanSet:
+	case p.PlanInsert:
+		qr, err := qre.txFetch(conn, true)
+		if err != nil {
+			return nil, err
+		}
+		qre.setLastInsertID(qr)
+		return qr, nil
+	case p.PlanUpdate, p.PlanDelete, p.PlanSet:
 		return qre.txFetch(conn, true)
 	case p.PlanInsertMessage:
 		qre.bindVars["#time_now"] = sqltypes.Int64BindVariable(time.Now().UnixNano())
@@ -470,6 +490,7 @@ func execWithDDLView(ctx context.Context, conn *StatefulConnection, sql string)
 // Stream performs a streaming query execution.
 func (qre *QueryExecutor) Stream(callback StreamCallback) error {
 	qre.logStats.PlanType = qre.plan.PlanID.String()
+	qre.logStats.TableName = qre.plan.TableName()
 
 	defer func(start time.Time) {
 		qre.tsv.stats.QueryTimings.Record(qre.plan.PlanID.String(), start)
@@ -498,7 +519,8 @@ func (qre *QueryExecutor) Stream(callback StreamCallback) error {
 	}
 
 	if consolidator := qre.tsv.qe.streamConsolidator; consolidator != nil {
-		if qre.connID == 0 && qre.plan.PlanID == p.PlanSelectStream && qre.shouldConsolidate() {
+		// a stream with a row limit is cut short, so its results can't be shared
+		if qre.connID == 0 && qre.plan.PlanID == p.PlanSelectStream && qre.options.GetStreamRowLimit() == 0 && qre.shouldConsolidate() {
 			return consolidator.Consolidate(qre.logStats, sqlWithoutComments, callback,
 				func(callback StreamCallback) error {
 					dbConn, err := qre.getStreamConn()
@@ -551,7 +573,7 @@ func (qre *QueryExecutor) Stream(callback StreamCallback) error {
 	if waitGtidErr != nil {
 		return waitGtidErr
 	}
-	return qre.execStreamSQL(conn, qre.connID != 0, sql, func(result *sqltypes.Result) error {
+	err = qre.execStreamSQL(conn, qre.connID != 0, sql, qre.limitStreamRows(conn, func(result *sqltypes.Result) error {
 		// this stream result is only used by the calling client, so it can be
 		// returned to the pool once the callback has fully returned
 		defer returnStreamResult(result)
@@ -560,13 +582,49 @@ func (qre *QueryExecutor) Stream(callback StreamCallback) error {
 			result.ReplaceKeyspace(replaceKeyspace)
 		}
 		return callback(result)
-	})
+	}))
+	if err == errStreamRowLimitReached {
+		return nil
+	}
+	return err
+}
+
+// errStreamRowLimitReached is returned by the callback of limitStreamRows to stop the stream.
+var errStreamRowLimitReached = errors.New("stream row limit reached")
+
+// limitStreamRows wraps callback to stop the stream once ExecuteOptions.StreamRowLimit rows have been sent.
+// The rows beyond the limit are dropped and the last result is marked as truncated. The query is killed
+// so that MySQL stops producing rows, the connection and its transaction can still be used afterwards.
+func (qre *QueryExecutor) limitStreamRows(conn *connpool.DBConn, callback StreamCallback) StreamCallback {
+	limit := qre.options.GetStreamRowLimit()
+	if limit == 0 {
+		return callback
+	}
+	var sent uint64
+	return func(result *sqltypes.Result) error {
+		if sent+uint64(len(result.Rows)) <= limit {
+			sent += uint64(len(result.Rows))
+			return callback(result)
+		}
+		result.Rows = result.Rows[:limit-sent]
+		result.Truncated = true
+		sent = limit
+		if err := callback(result); err != nil {
+			return err
+		}
+		// the remaining rows are still read and discarded if the query can't be killed
+		if err := conn.KillQuery("stream row limit reached"); err != nil {
+			log.Warningf("Failed to kill the query after the stream row limit was reached: %v", err)
+		}
+		return errStreamRowLimitReached
+	}
 }
 
 // MessageStream streams messages from a message table.
 func (qre *QueryExecutor) MessageStream(callback StreamCallback) error {
 	qre.logStats.OriginalSQL = qre.query
 	qre.logStats.PlanType = qre.plan.PlanID.String()
+	qre.logStats.TableName = qre.plan.TableName()
 
 	defer func(start time.Time) {
 		qre.tsv.stats.QueryTimings.Record(qre.plan.PlanID.String(), start)
@@ -888,14 +946,28 @@ func (qre *QueryExecutor) execNextval() (*sqltypes.Result, error) {
 
 // execSelect sends a query to mysql only if another identical query is not running. Otherwise, it waits and
 // reuses the result. If the plan is missing field info, it sends the query to mysql requesting full info.
-func (qre *QueryExecutor) execSelect() (*sqltypes.Result, error) {
-	sql, sqlWithoutComments, err := qre.generateFinalSQL(qre.plan.FullQuery, qre.bindVars)
+func (qre *QueryExecutor) execSelect() (reply *sqltypes.Result, err error) {
+	sql, sqlWithoutComments, err := qre.generateFinalSQL(qre.selectQuery(), qre.bindVars)
 	if err != nil {
 		return nil, err
 	}
 	wantFields := true
 	// If the query is a read after write, we need to add the wait gtid prefix
 	sql, waitGtidPrefixAdded := qre.addPrefixWaitGtid(sql)
+	// A read after write must see the write, so it is never served from the result cache.
+	if tableNames := qre.planTableNames(); !waitGtidPrefixAdded && qre.plan.PlanID == p.PlanSelect && !qre.options.GetBypassConsolidation() && qre.tsv.qe.resultCache.Cacheable(tableNames) {
+		cacheKey := qre.resultCacheKey(sqlWithoutComments, tableNames)
+		if qr := qre.tsv.qe.resultCache.Get(cacheKey); qr != nil {
+			qre.logStats.QuerySources |= tabletenv.QuerySourceResultCache
+			return qr, nil
+		}
+		generation := qre.tsv.qe.resultCache.Generation()
+		defer func() {
+			if err == nil {
+				qre.tsv.qe.resultCache.Set(cacheKey, tableNames, reply, generation)
+			}
+		}()
+	}
 	// Check tablet type.
 	if qre.shouldConsolidate() {
 		q, original := qre.tsv.qe.consolidator.Create(sqlWithoutComments)
@@ -938,6 +1010,34 @@ func (qre *QueryExecutor) execSelect() (*sqltypes.Result, error) {
 	return res, nil
 }
 
+// planTableNames returns the names of the tables the plan reads from or writes to,
+// qualified with their database when it is known.
+func (qre *QueryExecutor) planTableNames() []string {
+	names := make([]string, 0, len(qre.plan.Permissions))
+	for _, permission := range qre.plan.Permissions {
+		names = append(names, permission.GetFullTableName())
+	}
+	return names
+}
+
+// resultCacheKey returns the key of the result cache entry for sql, which already has the bind vars
+// substituted. The qualified tables the query reads from and the setting of the connection are part
+// of the key since they decide the database the query runs against, and so are the options that
+// change the shape of the result.
+func (qre *QueryExecutor) resultCacheKey(sql string, tableNames []string) string {
+	var buf strings.Builder
+	buf.WriteString(strings.Join(tableNames, ","))
+	buf.WriteString(";")
+	if qre.setting != nil {
+		buf.WriteString(qre.setting.GetQuery())
+	}
+	buf.WriteString(";")
+	buf.WriteString(qre.options.GetIncludedFields().String())
+	buf.WriteString(";")
+	buf.WriteString(sql)
+	return buf.String()
+}
+
 // addPrefixWaitGtid adds a prefix to the query to wait for the gtid to be replicated.
 // make sure to call discardWaitGtidResponse if waitGtidPrefixAdded returns true.
 func (qre *QueryExecutor) addPrefixWaitGtid(sql string) (newSQL string, waitGtidPrefixAdded bool) {
@@ -1079,9 +1179,31 @@ func (qre *QueryExecutor) getStreamConn() (*connpool.DBConn, error) {
 	return nil, err
 }
 
+// setLastInsertID reports the last AUTO_INCREMENT value generated by the insert if the
+// client asked for it. MySQL only reports the first one, but the values are consecutive
+// when they have been generated for all the rows of the plan.
+func (qre *QueryExecutor) setLastInsertID(qr *sqltypes.Result) {
+	if !qre.options.GetIncludeInsertIdRange() || !qre.plan.ConsecutiveInsertIDs {
+		return
+	}
+	if qr.InsertID == 0 || qr.RowsAffected == 0 {
+		return
+	}
+	qr.LastInsertID = qr.InsertID + qr.RowsAffected - 1
+}
+
+// selectQuery returns the query of the plan to execute. A select is ordered by the primary key
+// of its table if ExecuteOptions.DeterministicOrder is set and the plan has an ordered query.
+func (qre *QueryExecutor) selectQuery() *sqlparser.ParsedQuery {
+	if qre.options.GetDeterministicOrder() && qre.plan.OrderedQuery != nil {
+		return qre.plan.OrderedQuery
+	}
+	return qre.plan.FullQuery
+}
+
 // txFetch fetches from a TxConnection.
 func (qre *QueryExecutor) txFetch(conn *StatefulConnection, record bool) (*sqltypes.Result, error) {
-	sql, _, err := qre.generateFinalSQL(qre.plan.FullQuery, qre.bindVars)
+	sql, _, err := qre.generateFinalSQL(qre.selectQuery(), qre.bindVars)
 	if err != nil {
 		return nil, err
 	}
@@ -1096,6 +1218,7 @@ func (qre *QueryExecutor) txFetch(conn *StatefulConnection, record bool) (*sqlty
 	// Only record successful queries.
 	if record {
 		conn.TxProperties().RecordQuery(sql)
+		conn.TxProperties().RecordWrittenTables(qre.planTableNames()...)
 	}
 	if waitGtidPrefixAdded {
 		qr, err = qre.discardWaitGtidResponse(qr, err, conn.UnderlyingDBConn(), true)
@@ -1256,23 +1379,55 @@ func (qre *QueryExecutor) execAlterDMLJob() (*sqltypes.Result, error) {
 	uuid := alterDMLJob.UUID
 	switch alterDMLJob.Type {
 	case sqlparser.PauseDMLJobType:
-		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.PauseJob, "", uuid, "", "", "", "", "", "", 0, 0, false, "", false)
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.PauseJob, jobcontroller.JobRequest{JobUUID: uuid})
+	case sqlparser.PauseAllDMLJobType:
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.PauseAllJobs, jobcontroller.JobRequest{})
 	case sqlparser.ResumeDMLJobType:
-		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ResumeJob, "", uuid, "", "", "", "", "", "", 0, 0, false, "", false)
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ResumeJob, jobcontroller.JobRequest{JobUUID: uuid})
+	case sqlparser.ResumeAllDMLJobType:
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ResumeAllJobs, jobcontroller.JobRequest{})
 	case sqlparser.LaunchDMLJobType:
-		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.LaunchJob, "", uuid, "", "", "", "", "", "", 0, 0, false, "", false)
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.LaunchJob, jobcontroller.JobRequest{JobUUID: uuid})
 	case sqlparser.CancelDMLJobType:
-		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.CancelJob, "", uuid, "", "", "", "", "", "", 0, 0, false, "", false)
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.CancelJob, jobcontroller.JobRequest{JobUUID: uuid})
 	case sqlparser.ThrottleDMLJobType:
-		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ThrottleJob, "", uuid, "", "", "", "", alterDMLJob.Expire, alterDMLJob.Ratio.Val, 0, 0, false, "", false)
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ThrottleJob, jobcontroller.JobRequest{JobUUID: uuid, ThrottleDuration: alterDMLJob.Expire, ThrottleRatio: alterDMLJob.Ratio.Val})
 	case sqlparser.UnthrottleDMLJobType:
-		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.UnthrottleJob, "", uuid, "", "", "", "", "", "", 0, 0, false, "", false)
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.UnthrottleJob, jobcontroller.JobRequest{JobUUID: uuid})
+	case sqlparser.PurgeDMLJobType:
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.PurgeJob, jobcontroller.JobRequest{JobUUID: uuid})
+	case sqlparser.CloneDMLJobType:
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.CloneJob, jobcontroller.JobRequest{JobUUID: uuid})
 	case sqlparser.SetRunningTimePeriodType:
-		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.SetRunningTimePeriod, "", uuid, "", alterDMLJob.TimePeriodStart, alterDMLJob.TimePeriodEnd, alterDMLJob.TimePeriodTimeZone, "", "", 0, 0, false, "", false)
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.SetRunningTimePeriod, jobcontroller.JobRequest{JobUUID: uuid, RunningTimePeriodStart: alterDMLJob.TimePeriodStart, RunningTimePeriodEnd: alterDMLJob.TimePeriodEnd, RunningTimePeriodTimeZone: alterDMLJob.TimePeriodTimeZone})
 	}
 	return nil, vterrors.New(vtrpcpb.Code_UNIMPLEMENTED, "ALTER DML_JOB not implemented")
 }
 
+func (qre *QueryExecutor) execShowDMLJob() (*sqltypes.Result, error) {
+	show, ok := qre.plan.FullStmt.(*sqlparser.Show)
+	if !ok {
+		return nil, vterrors.New(vtrpcpb.Code_INTERNAL, "Expecting SHOW DML_JOB plan")
+	}
+	showDMLJob, ok := show.Internal.(*sqlparser.ShowDMLJob)
+	if !ok {
+		return nil, vterrors.New(vtrpcpb.Code_INTERNAL, "Expecting SHOW DML_JOB plan")
+	}
+	if showDMLJob.TagLike != "" {
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ShowJobsWithTag, jobcontroller.JobRequest{TagPattern: showDMLJob.TagLike})
+	}
+	if showDMLJob.Plan {
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.DescribeJob, jobcontroller.JobRequest{JobUUID: showDMLJob.UUID})
+	}
+	if showDMLJob.JSON {
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ShowJobJSON, jobcontroller.JobRequest{JobUUID: showDMLJob.UUID})
+	}
+	if showDMLJob.BatchID != "" {
+		return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ShowBatch, jobcontroller.JobRequest{JobUUID: showDMLJob.UUID, BatchID: showDMLJob.BatchID})
+	}
+	return qre.tsv.dmlJonController.HandleRequest(jobcontroller.ShowJob, jobcontroller.JobRequest{JobUUID: showDMLJob.UUID, ShowDetails: showDMLJob.Detail})
+}
+
 func (qre *QueryExecutor) execRevertMigration() (*sqltypes.Result, error) {
 	if _, ok := qre.plan.FullStmt.(*sqlparser.RevertMigration); !ok {
 		return nil, vterrors.New(vtrpcpb.Code_INTERNAL, "Expecting REVERT VITESS_MIGRATION plan")
@@ -1384,10 +1539,11 @@ func (qre *QueryExecutor) execDBConn(conn *connpool.DBConn, sql string, wantfiel
 	defer qre.logStats.AddRewrittenSQL(sql, time.Now())
 
 	qd := NewQueryDetail(qre.logStats.Ctx, conn)
-	qre.tsv.statelessql.Add(qd)
-	defer qre.tsv.statelessql.Remove(qd)
+	ql := qre.queryList(qre.tsv.statelessql)
+	ql.Add(qd)
+	defer ql.Remove(qd)
 
-	return conn.Exec(ctx, sql, int(qre.tsv.qe.maxResultSize.Get()), wantfields)
+	return qre.execConn(ctx, conn, sql, wantfields)
 }
 
 func (qre *QueryExecutor) execStatefulConn(conn *StatefulConnection, sql string, wantfields bool) (*sqltypes.Result, error) {
@@ -1397,10 +1553,11 @@ func (qre *QueryExecutor) execStatefulConn(conn *StatefulConnection, sql string,
 	defer qre.logStats.AddRewrittenSQL(sql, time.Now())
 
 	qd := NewQueryDetail(qre.logStats.Ctx, conn)
-	qre.tsv.statefulql.Add(qd)
-	defer qre.tsv.statefulql.Remove(qd)
+	ql := qre.queryList(qre.tsv.statefulql)
+	ql.Add(qd)
+	defer ql.Remove(qd)
 
-	return conn.Exec(ctx, sql, int(qre.tsv.qe.maxResultSize.Get()), wantfields)
+	return qre.execConn(ctx, conn, sql, wantfields)
 }
 
 func (qre *QueryExecutor) execStreamSQL(conn *connpool.DBConn, isTransaction bool, sql string, callback func(*sqltypes.Result) error) error {
@@ -1421,15 +1578,32 @@ func (qre *QueryExecutor) execStreamSQL(conn *connpool.DBConn, isTransaction boo
 	// once their grace period is over.
 	qd := NewQueryDetail(qre.logStats.Ctx, conn)
 	if isTransaction {
-		qre.tsv.statefulql.Add(qd)
-		defer qre.tsv.statefulql.Remove(qd)
+		ql := qre.queryList(qre.tsv.statefulql)
+		ql.Add(qd)
+		defer ql.Remove(qd)
 		return conn.StreamOnce(ctx, sql, callBackClosingSpan, allocStreamResult, int(qre.tsv.qe.streamBufferSize.Get()), sqltypes.IncludeFieldsOrDefault(qre.options))
 	}
-	qre.tsv.olapql.Add(qd)
-	defer qre.tsv.olapql.Remove(qd)
+	ql := qre.queryList(qre.tsv.olapql)
+	ql.Add(qd)
+	defer ql.Remove(qd)
 	return conn.Stream(ctx, sql, callBackClosingSpan, allocStreamResult, int(qre.tsv.qe.streamBufferSize.Get()), sqltypes.IncludeFieldsOrDefault(qre.options))
 }
 
+// queryList returns the query list the query is added to while it's executed, which is ql
+// unless the query is pinned to another one by queryserver-config-query-list-override.
+func (qre *QueryExecutor) queryList(ql *QueryList) *QueryList {
+	if qre.tsv.queryListOverrides.empty() || qre.plan == nil {
+		return ql
+	}
+	switch qre.tsv.queryListOverrides.match(qre.query, qre.planTableNames()) {
+	case tabletenv.OlapQueryList:
+		return qre.tsv.olapql
+	case tabletenv.StatefulQueryList:
+		return qre.tsv.statefulql
+	}
+	return ql
+}
+
 func (qre *QueryExecutor) recordUserQuery(queryType string, duration int64) {
 	username := callerid.GetPrincipal(callerid.EffectiveCallerIDFromContext(qre.ctx))
 	if username == "" {
@@ -23,6 +23,7 @@ package tabletserver
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"math/rand"
@@ -46,6 +47,7 @@ import (
 	"vitess.io/vitess/go/vt/tableacl/simpleacl"
 	"vitess.io/vitess/go/vt/topo/memorytopo"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/jobcontroller"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/planbuilder"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/rules"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
@@ -306,7 +308,7 @@ func TestQueryExecutorPlans(t *testing.T) {
 			assert.Equal(t, tsv.alias, state.TabletAlias, "Wrong alias returned by Begin")
 			defer tsv.Commit(ctx, target, state.TransactionID)
 
-			qre = newTestQueryExecutor(ctx, tsv, tcase.input, state.TransactionID)
+			qre = newTestQueryExecutorByDbName(ctx, tsv, tsv.config.DB.DBName, tcase.input, state.TransactionID)
 			got, err = qre.Execute()
 			require.NoError(t, err, tcase.input)
 			assert.Equal(t, tcase.resultWant, got, "in tx: %v", tcase.input)
@@ -638,6 +640,39 @@ func TestQueryExecutorPlanPassSelectWithLockOutsideATransaction(t *testing.T) {
 	assert.NoError(t, err)
 }
 
+func TestQueryExecutorInsertIDRange(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	insertQuery := "insert into test_table(addr) values (1), (2), (3)"
+	db.AddQuery(insertQuery, &sqltypes.Result{RowsAffected: 3, InsertID: 10})
+	upsertQuery := "insert into test_table(addr) values (1), (2), (3) on duplicate key update addr = 4"
+	db.AddQuery(upsertQuery, &sqltypes.Result{RowsAffected: 3, InsertID: 10})
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	execInsert := func(query string, options *querypb.ExecuteOptions) *sqltypes.Result {
+		qre := newTestQueryExecutor(ctx, tsv, query, 0)
+		qre.options = options
+		qr, err := qre.Execute()
+		require.NoError(t, err)
+		return qr
+	}
+
+	qr := execInsert(insertQuery, &querypb.ExecuteOptions{IncludeInsertIdRange: true})
+	assert.EqualValues(t, 10, qr.InsertID)
+	assert.EqualValues(t, 12, qr.LastInsertID)
+
+	// the range is only reported on request
+	qr = execInsert(insertQuery, nil)
+	assert.Zero(t, qr.LastInsertID)
+
+	// rows updated on duplicate keys don't generate values
+	qr = execInsert(upsertQuery, &querypb.ExecuteOptions{IncludeInsertIdRange: true})
+	assert.EqualValues(t, 10, qr.InsertID)
+	assert.Zero(t, qr.LastInsertID)
+}
+
 func TestQueryExecutorPlanNextval(t *testing.T) {
 	db := setUpQueryExecutorTest(t)
 	defer db.Close()
@@ -760,6 +795,107 @@ func TestQueryExecutorPlanNextval(t *testing.T) {
 	}
 }
 
+func TestQueryExecutorShowDMLJobsWithTag(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	jobFields := sqltypes.MakeTestFields("id|job_uuid|batch_info_table_schema|status|tag", "int64|varchar|varchar|varchar|varchar")
+	jobRows := []string{"1|uuid1|test|completed|ticket-1", "2|uuid2|test|queued|ticket-2"}
+	for _, row := range jobRows {
+		uuid := strings.Split(row, "|")[1]
+		db.AddQueryPattern(fmt.Sprintf(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = '%s'`, uuid),
+			sqltypes.MakeTestResult(jobFields, row))
+	}
+	db.AddQuery("select * from mysql.non_transactional_dml_jobs where tag like 'ticket-%' order by id",
+		sqltypes.MakeTestResult(jobFields, jobRows...))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+	qre := newTestQueryExecutor(ctx, tsv, "show dml_jobs like 'ticket-%'", 0)
+	assert.Equal(t, planbuilder.PlanShowDMLJob, qre.plan.PlanID)
+	got, err := qre.Execute()
+	require.NoError(t, err)
+	var uuids []string
+	for _, row := range got.Named().Rows {
+		uuids = append(uuids, row.AsString("job_uuid", ""))
+	}
+	assert.Equal(t, []string{"uuid1", "uuid2"}, uuids)
+}
+
+func TestQueryExecutorShowDMLJobBatch(t *testing.T) {
+	const batchTable = "_vt_BATCH_uuid1"
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid1'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|batch_info_table_schema|batch_info_table_name", "varchar|varchar|varchar"),
+		"uuid1||"+batchTable))
+	db.AddQuery(`SHOW TABLES LIKE '\_vt\_BATCH\_uuid1'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Tables_in_test", "varchar"), batchTable))
+	want := sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_id|batch_sql|batch_count_sql|batch_status", "varchar|text|text|varchar"),
+		"1-2|delete from t1 where id >= 1 and id <= 50|select count(*) as count_rows from t1 where id >= 1 and id <= 50|queued")
+	db.AddQuery(fmt.Sprintf("select batch_id, batch_sql, batch_count_sql_when_creating_batch as batch_count_sql, batch_status from %s where batch_id = '1-2'", batchTable), want)
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+	qre := newTestQueryExecutor(ctx, tsv, "show dml_job 'uuid1' batch '1-2'", 0)
+	assert.Equal(t, planbuilder.PlanShowDMLJob, qre.plan.PlanID)
+	got, err := qre.Execute()
+	require.NoError(t, err)
+	assert.Equal(t, want.Rows, got.Rows)
+}
+
+func TestQueryExecutorShowDMLJobJSON(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	db.AddQuery("use db1", &sqltypes.Result{})
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid1'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("id|job_uuid|table_schema|table_name|batch_info_table_schema|dml_sql|status", "int64|varchar|varchar|varchar|varchar|text|varchar"),
+		"1|uuid1|db1|t1|db1|delete from t1 where c1 = 1|running"))
+	db.AddQuery("SELECT SUM(actually_affected_rows) AS affected_rows FROM _vt_BATCH_uuid1 WHERE batch_status='completed'",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("affected_rows", "decimal"), "100"))
+	db.AddQuery("SELECT batch_id FROM _vt_BATCH_uuid1 where batch_status = 'queued' order by CAST(SUBSTRING_INDEX(batch_id, '-', 1) AS SIGNED),id limit 1",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("batch_id", "varchar"), "2"))
+	db.AddQuery("SELECT COUNT(*) AS total_batches, COALESCE(SUM(batch_status = 'completed'), 0) AS completed_batches FROM _vt_BATCH_uuid1",
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("total_batches|completed_batches", "int64|decimal"), "4|1"))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+	qre := newTestQueryExecutor(ctx, tsv, "show dml_job 'uuid1' json", 0)
+	assert.Equal(t, planbuilder.PlanShowDMLJob, qre.plan.PlanID)
+	got, err := qre.Execute()
+	require.NoError(t, err)
+	require.Len(t, got.Rows, 1)
+	assert.Equal(t, "job_json", got.Fields[0].Name)
+	var info jobcontroller.JobInfo
+	require.NoError(t, json.Unmarshal([]byte(got.Rows[0][0].ToString()), &info))
+	assert.Equal(t, "uuid1", info.UUID)
+	assert.Equal(t, "2", info.DealingBatchID)
+}
+
+func TestQueryExecutorShowDMLJobPlan(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid1'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|dml_sql|table_schema|batch_order", "varchar|varchar|varchar|varchar"),
+		"uuid1|delete from t1 where c2 > 10|test|null"))
+	db.AddQuery("use test", &sqltypes.Result{})
+	db.AddQuery(" show index from t1 where key_name = 'primary'", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Column_name", "varchar"), "id"))
+	db.AddQuery("select id from test.t1 limit 1", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("id", "int64"), "1"))
+	ctx := context.Background()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+	qre := newTestQueryExecutor(ctx, tsv, "show dml_job 'uuid1' plan", 0)
+	assert.Equal(t, planbuilder.PlanShowDMLJob, qre.plan.PlanID)
+	got, err := qre.Execute()
+	require.NoError(t, err)
+	require.Len(t, got.Rows, 1)
+	row := got.Named().Row()
+	assert.Equal(t, "select id from t1 where c2 > 10 order by id", row.AsString("select_sql", ""))
+	assert.Equal(t, "id", row.AsString("pk_part", ""))
+}
+
 func TestQueryExecutorMessageStreamACL(t *testing.T) {
 	aclName := fmt.Sprintf("simpleacl-test-%d", rand.Int63())
 	tableacl.Register(aclName, &simpleacl.Factory{})
@@ -1267,6 +1403,8 @@ func TestQueryExecutorShouldConsolidate(t *testing.T) {
 		name          string
 		// Whether or not query consolidator is requested.
 		options []querypb.ExecuteOptions_Consolidator
+		// Whether or not consolidation is bypassed, false if unset.
+		bypass []bool
 		// Whether or not query is consolidated.
 		queries []string
 	}{{
@@ -1320,6 +1458,36 @@ func TestQueryExecutorShouldConsolidate(t *testing.T) {
 			// This query shouldn't be passed to the consolidator.
 			"select * from t limit 100001",
 		},
+	}, {
+		consolidates: []bool{
+			false,
+			false,
+			false,
+			true,
+		},
+		executorFlags: enableConsolidator,
+		name:          "consolidator=enabled,bypass",
+		options: []querypb.ExecuteOptions_Consolidator{
+			querypb.ExecuteOptions_CONSOLIDATOR_UNSPECIFIED,
+			querypb.ExecuteOptions_CONSOLIDATOR_UNSPECIFIED,
+			querypb.ExecuteOptions_CONSOLIDATOR_ENABLED,
+			querypb.ExecuteOptions_CONSOLIDATOR_UNSPECIFIED,
+		},
+		bypass: []bool{
+			false,
+			true,
+			true,
+			false,
+		},
+		queries: []string{
+			"select * from t limit 100001",
+			// These queries bypass the consolidator, even when it's requested,
+			// so each of them hits MySQL.
+			"select * from t limit 100001",
+			"select * from t limit 100001",
+			// This query consolidates into the first one.
+			"select * from t limit 100001",
+		},
 	}}
 	for _, tcase := range testcases {
 		t.Run(tcase.name, func(t *testing.T) {
@@ -1339,7 +1507,8 @@ func TestQueryExecutorShouldConsolidate(t *testing.T) {
 			for i, input := range tcase.queries {
 				qre := newTestQueryExecutor(ctx, tsv, input, 0)
 				qre.options = &querypb.ExecuteOptions{
-					Consolidator: tcase.options[i],
+					Consolidator:        tcase.options[i],
+					BypassConsolidation: i < len(tcase.bypass) && tcase.bypass[i],
 				}
 				qres = append(qres, qre)
 
@@ -99,6 +99,17 @@ func (ql *QueryList) Remove(qd *QueryDetail) {
 	}
 }
 
+// Len returns the number of queries in QueryList.
+func (ql *QueryList) Len() int {
+	ql.mu.Lock()
+	defer ql.mu.Unlock()
+	n := 0
+	for _, qds := range ql.queryDetails {
+		n += len(qds)
+	}
+	return n
+}
+
 // Terminate updates the query status and kills the connection
 func (ql *QueryList) Terminate(connID int64) bool {
 	ql.mu.Lock()
@@ -0,0 +1,75 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"strings"
+
+	"vitess.io/vitess/go/vt/log"
+	"vitess.io/vitess/go/vt/sqlparser"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+)
+
+// queryListOverrides pin queries to the olap or the oltp-stateful query list, whatever list they'd
+// be added to otherwise, e.g. so that known-heavy queries are killed along with the olap ones.
+// A query is matched by its normalized SQL, i.e. without its comments and with its bind variables
+// not substituted, or by a table it reads from or writes to. Like in the ResultCache, a table is
+// identified by its qualified "database.table" name, and a table without a database matches the
+// table in any database. A query matching both is pinned by its SQL.
+type queryListOverrides struct {
+	// queries and tables map the normalized queries and the tables to the name of their query list.
+	queries map[string]string
+	tables  map[string]string
+}
+
+// newQueryListOverrides creates the queryListOverrides of QueryListOverrides, the invalid ones are ignored.
+func newQueryListOverrides(overrides []string) *queryListOverrides {
+	qlo := &queryListOverrides{
+		queries: make(map[string]string),
+		tables:  make(map[string]string),
+	}
+	for _, override := range overrides {
+		queryList, target, err := tabletenv.ParseQueryListOverride(override)
+		if err != nil {
+			log.Errorf("Ignoring the query list override: %v", err)
+			continue
+		}
+		// a normalized query has at least a space, a table name can't
+		if strings.ContainsAny(target, " \t\n") {
+			qlo.queries[normalizeQueryForOverride(target)] = queryList
+			continue
+		}
+		qlo.tables[strings.ToLower(target)] = queryList
+	}
+	return qlo
+}
+
+// normalizeQueryForOverride returns sql as it's matched against the pinned queries.
+func normalizeQueryForOverride(sql string) string {
+	return strings.ToLower(strings.Join(strings.Fields(sqlparser.StripComments(sql)), " "))
+}
+
+// empty returns true if no query is pinned.
+func (qlo *queryListOverrides) empty() bool {
+	return qlo == nil || (len(qlo.queries) == 0 && len(qlo.tables) == 0)
+}
+
+// match returns the name of the query list a query on tableNames is pinned to, "" if it's not pinned.
+func (qlo *queryListOverrides) match(sql string, tableNames []string) string {
+	if queryList, ok := qlo.queries[normalizeQueryForOverride(sql)]; ok {
+		return queryList
+	}
+	for _, table := range tableNames {
+		table = strings.ToLower(table)
+		if queryList, ok := qlo.tables[table]; ok {
+			return queryList
+		}
+		if queryList, ok := qlo.tables[unqualifiedTableName(table)]; ok {
+			return queryList
+		}
+	}
+	return ""
+}
@@ -0,0 +1,35 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+)
+
+func TestQueryListOverridesMatch(t *testing.T) {
+	qlo := newQueryListOverrides([]string{
+		"olap:t1",
+		"oltp-stateful:db2.t2",
+		"olap:select * from t2 where c = :c",
+		"invalid:t3",
+		"olap:",
+	})
+
+	assert.Equal(t, tabletenv.OlapQueryList, qlo.match("select * from t1", []string{"db1.t1"}))
+	assert.Equal(t, tabletenv.OlapQueryList, qlo.match("select * from T1", []string{"T1"}))
+	assert.Equal(t, tabletenv.StatefulQueryList, qlo.match("select * from t2", []string{"db2.t2"}))
+	assert.Equal(t, "", qlo.match("select * from t2", []string{"db1.t2"}))
+	// the query is matched before its tables, whatever its comments and spaces
+	assert.Equal(t, tabletenv.OlapQueryList, qlo.match("/* comment */ SELECT *  from t2\nwhere c = :c", []string{"db2.t2"}))
+	// the invalid overrides are ignored
+	assert.Equal(t, "", qlo.match("select * from t3", []string{"t3"}))
+	assert.False(t, qlo.empty())
+	assert.True(t, newQueryListOverrides(nil).empty())
+}
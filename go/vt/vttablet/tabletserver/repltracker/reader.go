@@ -64,6 +64,8 @@ type heartbeatReader struct {
 	lagMu          sync.Mutex
 	lastKnownLag   time.Duration
 	lastKnownError error
+	// lastRead is the time of the last successful heartbeat read.
+	lastRead time.Time
 }
 
 // newHeartbeatReader returns a new heartbeatReader.
@@ -136,6 +138,13 @@ func (r *heartbeatReader) Status() (time.Duration, error) {
 	return r.lastKnownLag, nil
 }
 
+// LastRead returns the time of the last successful heartbeat read, it's zero if there was none.
+func (r *heartbeatReader) LastRead() time.Time {
+	r.lagMu.Lock()
+	defer r.lagMu.Unlock()
+	return r.lastRead
+}
+
 // readHeartbeat reads from the heartbeat table exactly once, updating
 // the last known lag and/or error, and incrementing counters.
 func (r *heartbeatReader) readHeartbeat() {
@@ -164,6 +173,7 @@ func (r *heartbeatReader) readHeartbeat() {
 	r.lagMu.Lock()
 	r.lastKnownLag = lag
 	r.lastKnownError = nil
+	r.lastRead = r.now()
 	r.lagMu.Unlock()
 }
 
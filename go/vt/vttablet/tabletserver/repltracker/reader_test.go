@@ -65,6 +65,7 @@ func TestReaderReadHeartbeat(t *testing.T) {
 	assert.Equal(t, expectedCumLag, cumulativeLagNs.Get(), "wrong cumulative lag")
 	assert.Equal(t, int64(1), reads.Get(), "wrong read count")
 	assert.Equal(t, int64(0), readErrors.Get(), "wrong read error count")
+	assert.Equal(t, now, tr.LastRead(), "wrong last read")
 	expectedHisto := map[string]int64{
 		"0":      int64(0),
 		"1ms":    int64(0),
@@ -98,6 +99,7 @@ func TestReaderReadHeartbeatError(t *testing.T) {
 	assert.Equal(t, 0*time.Second, lag, "wrong lastKnownLag")
 	assert.Equal(t, int64(0), cumulativeLagNs.Get(), "wrong cumulative lag")
 	assert.Equal(t, int64(1), readErrors.Get(), "wrong read error count")
+	assert.True(t, tr.LastRead().IsZero(), "wrong last read")
 }
 
 func newReader(db *fakesqldb.DB, nowFunc func() time.Time) *heartbeatReader {
@@ -150,6 +150,23 @@ func (rt *ReplTracker) Status() (time.Duration, error) {
 	return rt.poller.Status()
 }
 
+// HeartbeatStatus reports the heartbeat lag and the time of the last successful heartbeat,
+// which is the last write on a primary and the last read on a replica. The time is zero
+// if there was none yet, or if the replication isn't tracked with heartbeats.
+func (rt *ReplTracker) HeartbeatStatus() (time.Duration, time.Time, error) {
+	rt.mu.Lock()
+	defer rt.mu.Unlock()
+
+	switch {
+	case rt.isPrimary:
+		return 0, rt.hw.LastWrite(), nil
+	case rt.mode == tabletenv.Heartbeat:
+		lag, err := rt.hr.Status()
+		return lag, rt.hr.LastRead(), err
+	}
+	return 0, time.Time{}, nil
+}
+
 // GtidExecuted returns the GTID_EXECUTED value.
 func (rt *ReplTracker) GtidExecuted() (mysql.Position, error) {
 	rt.mu.Lock()
@@ -74,6 +74,13 @@ func TestReplTracker(t *testing.T) {
 	assert.NoError(t, err)
 	assert.Equal(t, 1*time.Second, lag)
 
+	lastRead := time.Now()
+	rt.hr.lastRead = lastRead
+	lag, lastHeartbeat, err := rt.HeartbeatStatus()
+	assert.NoError(t, err)
+	assert.Equal(t, 1*time.Second, lag)
+	assert.Equal(t, lastRead, lastHeartbeat)
+
 	rt.Close()
 	assert.False(t, rt.hw.isOpen)
 	assert.False(t, rt.hr.isOpen)
@@ -91,6 +98,11 @@ func TestReplTracker(t *testing.T) {
 	assert.False(t, rt.hr.isOpen)
 	assert.False(t, rt.isPrimary)
 
+	// the heartbeat isn't used in polling mode
+	_, lastHeartbeat, err = rt.HeartbeatStatus()
+	assert.NoError(t, err)
+	assert.True(t, lastHeartbeat.IsZero())
+
 	mysqld.ReplicationStatusError = errors.New("err")
 	_, err = rt.Status()
 	assert.Equal(t, "err", err.Error())
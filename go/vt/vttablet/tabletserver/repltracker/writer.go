@@ -66,6 +66,10 @@ type heartbeatWriter struct {
 	concurrentHeartbeatRequests int64
 	onDemandRequestTicks        int64
 	onDemandLastRequestTick     int64
+
+	lastWriteMu sync.Mutex
+	// lastWrite is the time of the last successful heartbeat write.
+	lastWrite time.Time
 }
 
 // newHeartbeatWriter creates a new heartbeatWriter.
@@ -187,6 +191,16 @@ func (w *heartbeatWriter) writeHeartbeat() {
 		return
 	}
 	writes.Add(1)
+	w.lastWriteMu.Lock()
+	w.lastWrite = w.now()
+	w.lastWriteMu.Unlock()
+}
+
+// LastWrite returns the time of the last successful heartbeat write, it's zero if there was none.
+func (w *heartbeatWriter) LastWrite() time.Time {
+	w.lastWriteMu.Lock()
+	defer w.lastWriteMu.Unlock()
+	return w.lastWrite
 }
 
 func (w *heartbeatWriter) write() error {
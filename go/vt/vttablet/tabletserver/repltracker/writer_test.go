@@ -52,6 +52,7 @@ func TestWriteHeartbeat(t *testing.T) {
 	tw.writeHeartbeat()
 	assert.Equal(t, int64(1), writes.Get())
 	assert.Equal(t, int64(0), writeErrors.Get())
+	assert.Equal(t, now, tw.LastWrite())
 }
 
 func TestWriteHeartbeatError(t *testing.T) {
@@ -66,6 +67,7 @@ func TestWriteHeartbeatError(t *testing.T) {
 	tw.writeHeartbeat()
 	assert.Equal(t, int64(0), writes.Get())
 	assert.Equal(t, int64(1), writeErrors.Get())
+	assert.True(t, tw.LastWrite().IsZero())
 }
 
 func newTestWriter(db *fakesqldb.DB, nowFunc func() time.Time) *heartbeatWriter {
@@ -0,0 +1,232 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"container/list"
+	"strings"
+	"sync"
+	"time"
+
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/sync2"
+)
+
+// ResultCache caches the results of SELECTs that only read from a designated
+// set of tables, typically small reference tables that are read frequently and
+// rarely change. Entries expire after a short TTL, the cache holds at most
+// maxEntries results and evicts the least recently used one when it is full.
+// Entries are invalidated when a transaction that wrote to one of the tables
+// they read from commits through this tablet, or when the schema engine reports
+// a change of the table. Writes that are not seen by this tablet are only picked
+// up after the TTL.
+//
+// Tables are identified by their qualified "database.table" name. A designated
+// table without a database matches the table in any database.
+type ResultCache struct {
+	ttl        time.Duration
+	maxEntries int
+	// tables are the names of the tables whose reads can be cached.
+	tables map[string]bool
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	lru     *list.List
+	// tableKeys maps a table name to the keys of the cached results that read from it.
+	tableKeys map[string]map[string]bool
+	// generation is bumped on every invalidation. A result read before an invalidation
+	// may be stale and is not cached, see Set.
+	generation int64
+
+	hits   sync2.AtomicInt64
+	misses sync2.AtomicInt64
+}
+
+type resultCacheEntry struct {
+	key      string
+	tables   []string
+	result   *sqltypes.Result
+	expireAt time.Time
+}
+
+// NewResultCache creates a ResultCache for the given tables. The cache is
+// disabled if no tables are given, or if ttl or maxEntries is not positive.
+func NewResultCache(tables []string, ttl time.Duration, maxEntries int) *ResultCache {
+	rc := &ResultCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		tables:     make(map[string]bool),
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+		tableKeys:  make(map[string]map[string]bool),
+	}
+	for _, table := range tables {
+		table = strings.TrimSpace(table)
+		if table != "" {
+			rc.tables[strings.ToLower(table)] = true
+		}
+	}
+	return rc
+}
+
+// Enabled returns true if results can be cached.
+func (rc *ResultCache) Enabled() bool {
+	return rc != nil && len(rc.tables) > 0 && rc.ttl > 0 && rc.maxEntries > 0
+}
+
+// Cacheable returns true if a query reading from the given tables can be cached,
+// which is only the case if all of them are designated tables.
+func (rc *ResultCache) Cacheable(tables []string) bool {
+	if !rc.Enabled() || len(tables) == 0 {
+		return false
+	}
+	for _, table := range tables {
+		table = strings.ToLower(table)
+		if !rc.tables[table] && !rc.tables[unqualifiedTableName(table)] {
+			return false
+		}
+	}
+	return true
+}
+
+// Generation returns the current invalidation generation. It must be read before
+// the query whose result is passed to Set is executed.
+func (rc *ResultCache) Generation() int64 {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.generation
+}
+
+// Get returns a copy of the cached result for key, or nil if there is no
+// cached result or it has expired.
+func (rc *ResultCache) Get(key string) *sqltypes.Result {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	elem, ok := rc.entries[key]
+	if !ok {
+		rc.misses.Add(1)
+		return nil
+	}
+	entry := elem.Value.(*resultCacheEntry)
+	if time.Now().After(entry.expireAt) {
+		rc.removeLocked(elem)
+		rc.misses.Add(1)
+		return nil
+	}
+	rc.lru.MoveToFront(elem)
+	rc.hits.Add(1)
+	return entry.result.Copy()
+}
+
+// Set caches a copy of result for key. tables are the tables the query reads
+// from, the entry is invalidated when any of them changes. generation is the
+// value of Generation before the query was executed: if an invalidation happened
+// since, the result may predate a committed write and is not cached.
+func (rc *ResultCache) Set(key string, tables []string, result *sqltypes.Result, generation int64) {
+	if !rc.Enabled() {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if generation != rc.generation {
+		return
+	}
+	if elem, ok := rc.entries[key]; ok {
+		rc.removeLocked(elem)
+	}
+	entry := &resultCacheEntry{
+		key:      key,
+		result:   result.Copy(),
+		expireAt: time.Now().Add(rc.ttl),
+	}
+	for _, table := range tables {
+		table = strings.ToLower(table)
+		entry.tables = append(entry.tables, table)
+		keys, ok := rc.tableKeys[table]
+		if !ok {
+			keys = make(map[string]bool)
+			rc.tableKeys[table] = keys
+		}
+		keys[key] = true
+	}
+	rc.entries[key] = rc.lru.PushFront(entry)
+	for rc.lru.Len() > rc.maxEntries {
+		rc.removeLocked(rc.lru.Back())
+	}
+}
+
+// Invalidate removes the cached results that read from any of the given tables.
+// A table without a database invalidates the table in any database.
+func (rc *ResultCache) Invalidate(tables ...string) {
+	if !rc.Enabled() || len(tables) == 0 {
+		return
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.generation++
+	for _, table := range tables {
+		table = strings.ToLower(table)
+		for cached, keys := range rc.tableKeys {
+			if !sameTable(cached, table) {
+				continue
+			}
+			for key := range keys {
+				if elem, ok := rc.entries[key]; ok {
+					rc.removeLocked(elem)
+				}
+			}
+		}
+	}
+}
+
+// Clear removes all the cached results.
+func (rc *ResultCache) Clear() {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.generation++
+	rc.entries = make(map[string]*list.Element)
+	rc.lru.Init()
+	rc.tableKeys = make(map[string]map[string]bool)
+}
+
+// Len returns the number of cached results.
+func (rc *ResultCache) Len() int {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	return rc.lru.Len()
+}
+
+func (rc *ResultCache) removeLocked(elem *list.Element) {
+	entry := rc.lru.Remove(elem).(*resultCacheEntry)
+	delete(rc.entries, entry.key)
+	for _, table := range entry.tables {
+		keys := rc.tableKeys[table]
+		delete(keys, entry.key)
+		if len(keys) == 0 {
+			delete(rc.tableKeys, table)
+		}
+	}
+}
+
+// unqualifiedTableName strips the database, if any, from a "database.table" name.
+func unqualifiedTableName(table string) string {
+	if i := strings.LastIndexByte(table, '.'); i >= 0 {
+		return table[i+1:]
+	}
+	return table
+}
+
+// sameTable returns true if the lowercased names a and b refer to the same table.
+// A name without a database matches the table of that name in any database.
+func sameTable(a, b string) bool {
+	if a == b {
+		return true
+	}
+	if !strings.Contains(a, ".") || !strings.Contains(b, ".") {
+		return unqualifiedTableName(a) == unqualifiedTableName(b)
+	}
+	return false
+}
@@ -0,0 +1,134 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"vitess.io/vitess/go/sqltypes"
+)
+
+func TestResultCacheDisabled(t *testing.T) {
+	rc := NewResultCache(nil, time.Second, 10)
+	assert.False(t, rc.Enabled())
+	assert.False(t, rc.Cacheable([]string{"t1"}))
+
+	rc = NewResultCache([]string{"t1"}, 0, 10)
+	assert.False(t, rc.Enabled())
+
+	rc = NewResultCache([]string{"t1"}, time.Second, 0)
+	assert.False(t, rc.Enabled())
+}
+
+func TestResultCacheCacheable(t *testing.T) {
+	rc := NewResultCache([]string{"t1", " T2 "}, time.Second, 10)
+	assert.True(t, rc.Enabled())
+	assert.True(t, rc.Cacheable([]string{"t1"}))
+	assert.True(t, rc.Cacheable([]string{"t1", "t2"}))
+	assert.False(t, rc.Cacheable([]string{"t1", "t3"}))
+	assert.False(t, rc.Cacheable(nil))
+}
+
+func TestResultCacheGetSet(t *testing.T) {
+	rc := NewResultCache([]string{"t1", "t2"}, time.Minute, 10)
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1")
+
+	assert.Nil(t, rc.Get("select * from t1"))
+	rc.Set("select * from t1", []string{"t1"}, result, rc.Generation())
+	got := rc.Get("select * from t1")
+	assert.Equal(t, result, got)
+	assert.EqualValues(t, 1, rc.hits.Get())
+	assert.EqualValues(t, 1, rc.misses.Get())
+
+	// the cached result is not affected by changes to the returned one
+	got.Rows = nil
+	assert.Equal(t, result, rc.Get("select * from t1"))
+}
+
+func TestResultCacheExpire(t *testing.T) {
+	rc := NewResultCache([]string{"t1"}, 100*time.Millisecond, 10)
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1")
+
+	rc.Set("select * from t1", []string{"t1"}, result, rc.Generation())
+	assert.NotNil(t, rc.Get("select * from t1"))
+	time.Sleep(150 * time.Millisecond)
+	assert.Nil(t, rc.Get("select * from t1"))
+	assert.Equal(t, 0, rc.Len())
+}
+
+func TestResultCacheEvict(t *testing.T) {
+	rc := NewResultCache([]string{"t1"}, time.Minute, 2)
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1")
+
+	rc.Set("q1", []string{"t1"}, result, rc.Generation())
+	rc.Set("q2", []string{"t1"}, result, rc.Generation())
+	// q1 becomes the most recently used entry, so q2 is evicted
+	assert.NotNil(t, rc.Get("q1"))
+	rc.Set("q3", []string{"t1"}, result, rc.Generation())
+	assert.Equal(t, 2, rc.Len())
+	assert.NotNil(t, rc.Get("q1"))
+	assert.Nil(t, rc.Get("q2"))
+	assert.NotNil(t, rc.Get("q3"))
+}
+
+func TestResultCacheInvalidate(t *testing.T) {
+	rc := NewResultCache([]string{"t1", "t2"}, time.Minute, 10)
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1")
+
+	rc.Set("q1", []string{"t1"}, result, rc.Generation())
+	rc.Set("q2", []string{"t2"}, result, rc.Generation())
+	rc.Set("q3", []string{"t1", "t2"}, result, rc.Generation())
+
+	rc.Invalidate("T1")
+	assert.Nil(t, rc.Get("q1"))
+	assert.NotNil(t, rc.Get("q2"))
+	assert.Nil(t, rc.Get("q3"))
+
+	rc.Clear()
+	assert.Equal(t, 0, rc.Len())
+	assert.Nil(t, rc.Get("q2"))
+}
+
+func TestResultCacheQualifiedTables(t *testing.T) {
+	rc := NewResultCache([]string{"t1", "ks.t2"}, time.Minute, 10)
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1")
+
+	assert.True(t, rc.Cacheable([]string{"ks.t1"}))
+	assert.True(t, rc.Cacheable([]string{"other.t1"}))
+	assert.True(t, rc.Cacheable([]string{"ks.t2"}))
+	assert.False(t, rc.Cacheable([]string{"other.t2"}))
+
+	rc.Set("q1", []string{"ks.t1"}, result, rc.Generation())
+	rc.Set("q2", []string{"other.t1"}, result, rc.Generation())
+	rc.Set("q3", []string{"ks.t2"}, result, rc.Generation())
+
+	// a write to a table of another database leaves the results of ks alone
+	rc.Invalidate("other.t1")
+	assert.NotNil(t, rc.Get("q1"))
+	assert.Nil(t, rc.Get("q2"))
+
+	// a table without a database matches the table in any database
+	rc.Invalidate("t2")
+	assert.NotNil(t, rc.Get("q1"))
+	assert.Nil(t, rc.Get("q3"))
+}
+
+func TestResultCacheSetAfterInvalidate(t *testing.T) {
+	rc := NewResultCache([]string{"t1"}, time.Minute, 10)
+	result := sqltypes.MakeTestResult(sqltypes.MakeTestFields("id", "int64"), "1")
+
+	// a result read before a write committed must not be cached after the invalidation
+	generation := rc.Generation()
+	rc.Invalidate("t1")
+	rc.Set("q1", []string{"t1"}, result, generation)
+	assert.Nil(t, rc.Get("q1"))
+
+	rc.Set("q1", []string{"t1"}, result, rc.Generation())
+	assert.NotNil(t, rc.Get("q1"))
+}
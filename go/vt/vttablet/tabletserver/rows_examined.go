@@ -0,0 +1,50 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"context"
+
+	"vitess.io/vitess/go/sqltypes"
+)
+
+// sqlLastStatementRowsExamined reads the rows examined by the last statement
+// executed on the connection, from the statement history of performance_schema.
+const sqlLastStatementRowsExamined = "select rows_examined from performance_schema.events_statements_history " +
+	"where thread_id = ps_current_thread_id() order by event_id desc limit 1"
+
+// queryConn is implemented by the connections a QueryExecutor runs its queries on.
+type queryConn interface {
+	Exec(ctx context.Context, query string, maxrows int, wantfields bool) (*sqltypes.Result, error)
+	ID() int64
+}
+
+// execConn executes sql on conn. When ExecuteOptions.ReportRowsExamined is set,
+// the rows examined by sql are added to qre.rowsExamined, which costs one more
+// round-trip to MySQL. When ExecuteOptions.ReportThreadId is set, the id of conn
+// is recorded in qre.threadID.
+func (qre *QueryExecutor) execConn(ctx context.Context, conn queryConn, sql string, wantfields bool) (*sqltypes.Result, error) {
+	if qre.options.GetReportThreadId() {
+		qre.threadID = uint64(conn.ID())
+	}
+	result, err := conn.Exec(ctx, sql, int(qre.tsv.qe.maxResultSize.Get()), wantfields)
+	if err != nil || !qre.options.GetReportRowsExamined() {
+		return result, err
+	}
+	qr, err := conn.Exec(ctx, sqlLastStatementRowsExamined, 1, false)
+	if err != nil {
+		return nil, err
+	}
+	// the history is empty if the events_statements_history consumer is disabled.
+	if len(qr.Rows) == 1 {
+		rowsExamined, err := qr.Rows[0][0].ToUint64()
+		if err != nil {
+			return nil, err
+		}
+		qre.rowsExamined += rowsExamined
+	}
+	return result, nil
+}
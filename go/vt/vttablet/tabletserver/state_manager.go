@@ -23,6 +23,7 @@ package tabletserver
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -75,6 +76,9 @@ func (state servingState) String() string {
 // transitionRetryInterval is for tests.
 var transitionRetryInterval = 1 * time.Second
 
+// drainPollInterval is how often the in-flight queries are checked while draining.
+var drainPollInterval = 100 * time.Millisecond
+
 // stateManager manages state transition for all the TabletServer
 // subcomponents.
 type stateManager struct {
@@ -103,9 +107,13 @@ type stateManager struct {
 	retrying       bool
 	replHealthy    bool
 	lameduck       bool
+	draining       bool
 	alsoAllow      []topodatapb.TabletType
 	reason         string
 	transitionErr  error
+	// transitionComponent is the name of the subcomponent
+	// that caused transitionErr, if known.
+	transitionComponent string
 
 	requests sync.WaitGroup
 
@@ -148,6 +156,7 @@ type stateManager struct {
 	unhealthyThreshold    sync2.AtomicDuration
 	shutdownGracePeriod   time.Duration
 	transitionGracePeriod time.Duration
+	drainPeriod           time.Duration
 }
 
 type (
@@ -163,6 +172,7 @@ type (
 		MakeNonPrimary()
 		Close()
 		Status() (time.Duration, error)
+		HeartbeatStatus() (time.Duration, time.Time, error)
 		GtidExecuted() (mysql.Position, error)
 		ThreadsStatus() (*querypb.MysqlThreadsStats, error)
 	}
@@ -236,6 +246,7 @@ func (sm *stateManager) Init(env tabletenv.Env, target *querypb.Target) {
 	sm.unhealthyThreshold = sync2.NewAtomicDuration(env.Config().Healthcheck.UnhealthyThresholdSeconds.Get())
 	sm.shutdownGracePeriod = env.Config().GracePeriods.ShutdownSeconds.Get()
 	sm.transitionGracePeriod = env.Config().GracePeriods.TransitionSeconds.Get()
+	sm.drainPeriod = env.Config().GracePeriods.DrainSeconds.Get()
 }
 
 // SetServingType changes the state to the specified settings.
@@ -305,6 +316,11 @@ func (sm *stateManager) execTransition(tabletType topodatapb.TabletType, state s
 	}
 	sm.mu.Lock()
 	sm.transitionErr = err
+	sm.transitionComponent = ""
+	var terr *transitionError
+	if errors.As(err, &terr) {
+		sm.transitionComponent = terr.component
+	}
 	sm.mu.Unlock()
 	if err != nil {
 		sm.retryTransition(fmt.Sprintf("Error transitioning to the desired state: %v, %v, will keep retrying: %v", tabletType, state, err))
@@ -346,12 +362,13 @@ func (sm *stateManager) recheckState() bool {
 	return false
 }
 
-// checkMySQL verifies that we can connect to mysql.
-// If it fails, then we shutdown the service and initiate
-// the retry loop.
-func (sm *stateManager) checkMySQL() {
+// checkMySQL checks the connectivity to MySQL in the background. If MySQL
+// is unreachable, it shuts down the query service and initiates the retry
+// loop. It returns false if a check is already running or ran less than a
+// second ago.
+func (sm *stateManager) checkMySQL() bool {
 	if !sm.checkMySQLThrottler.TryAcquire() {
-		return
+		return false
 	}
 	log.Infof("CheckMySQL started")
 	sm.checkMySQLRunning.Set(true)
@@ -385,6 +402,7 @@ func (sm *stateManager) checkMySQL() {
 		sm.setWantState(StateServing)
 		sm.retryTransition(fmt.Sprintf("Cannot connect to MySQL, shutting down query service: %v", err))
 	}()
+	return true
 }
 
 func (sm *stateManager) setWantState(stateWanted servingState) {
@@ -404,15 +422,51 @@ func (sm *stateManager) isCheckMySQLRunning() int64 {
 // StopService shuts down sm. If the shutdown doesn't complete
 // within timeBombDuration, it crashes the process.
 func (sm *stateManager) StopService() {
+	log.Info("Stopping TabletServer")
+	// drain is bounded by drainPeriod on its own, the time bomb is only armed
+	// afterwards so that a long drain period doesn't crash a planned shutdown.
+	sm.drain()
+	defer sm.setDraining(false)
 	defer close(sm.setTimeBomb())
 
-	log.Info("Stopping TabletServer")
 	sm.SetServingType(sm.Target().TabletType, time.Time{}, StateNotConnected, "service stopped")
 	sm.hcticks.Stop()
 	sm.hs.Close()
 	sm.taskPool.Close()
 }
 
+// drain refuses new queries and waits, up to drainPeriod, for the in-flight
+// stateless queries to finish, so that they are not killed by the shutdown.
+// Unlike lameduck, the tablet keeps reporting itself as serving, queries
+// that are allowed on shutdown, like the ones in a transaction, are accepted.
+func (sm *stateManager) drain() {
+	if sm.drainPeriod == 0 || !sm.IsServing() {
+		return
+	}
+	sm.setDraining(true)
+	log.Infof("Draining TabletServer, waiting up to %v for %d in-flight queries", sm.drainPeriod, sm.statelessql.Len())
+
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+	deadline := time.NewTimer(sm.drainPeriod)
+	defer deadline.Stop()
+	for sm.statelessql.Len() > 0 {
+		select {
+		case <-ticker.C:
+		case <-deadline.C:
+			log.Infof("Drain period %v exceeded with %d in-flight queries", sm.drainPeriod, sm.statelessql.Len())
+			return
+		}
+	}
+	log.Info("Finished draining TabletServer")
+}
+
+func (sm *stateManager) setDraining(draining bool) {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	sm.draining = draining
+}
+
 // StartRequest validates the current state and target and registers
 // the request (a waitgroup) as started. Every StartRequest must be
 // ended with an EndRequest.
@@ -425,7 +479,7 @@ func (sm *stateManager) StartRequest(ctx context.Context, target *querypb.Target
 		return vterrors.New(vtrpcpb.Code_CLUSTER_EVENT, vterrors.NotServing)
 	}
 
-	shuttingDown := sm.wantState != StateServing
+	shuttingDown := sm.wantState != StateServing || sm.draining
 	if shuttingDown && !allowOnShutdown {
 		// This specific error string needs to be returned for vtgate buffering to work.
 		return vterrors.New(vtrpcpb.Code_CLUSTER_EVENT, vterrors.ShuttingDown)
@@ -558,17 +612,42 @@ func (sm *stateManager) unserveNonPrimary(wantTabletType topodatapb.TabletType)
 
 func (sm *stateManager) connect(tabletType topodatapb.TabletType) error {
 	if err := sm.se.EnsureConnectionAndDB(tabletType); err != nil {
-		return err
+		return &transitionError{component: "SchemaEngine", err: err}
 	}
 	if err := sm.se.Open(); err != nil {
-		return err
+		return &transitionError{component: "SchemaEngine", err: err}
 	}
 	sm.vstreamer.Open()
 	if err := sm.qe.Open(); err != nil {
-		return err
+		return &transitionError{component: "QueryEngine", err: err}
 	}
 	sm.poolSizeController.Open()
-	return sm.txThrottler.Open()
+	if err := sm.txThrottler.Open(); err != nil {
+		return &transitionError{component: "TxThrottler", err: err}
+	}
+	return nil
+}
+
+// transitionError is returned when a subcomponent fails to open
+// during a state transition. It records the name of the component
+// so that the failure can be reported without parsing the message.
+type transitionError struct {
+	component string
+	err       error
+}
+
+func (e *transitionError) Error() string {
+	return fmt.Sprintf("%s: %v", e.component, e.err)
+}
+
+// Cause returns the underlying error so that vterrors.Code
+// still reports the original error code.
+func (e *transitionError) Cause() error {
+	return e.err
+}
+
+func (e *transitionError) Unwrap() error {
+	return e.err
 }
 
 func (sm *stateManager) unserveCommon() {
@@ -842,6 +921,13 @@ func (sm *stateManager) AppendDetails(details []*kv) []*kv {
 			Class: unhealthyClass,
 			Value: sm.transitionErr.Error(),
 		})
+		if sm.transitionComponent != "" {
+			details = append(details, &kv{
+				Key:   "Transition Error Component",
+				Class: unhealthyClass,
+				Value: sm.transitionComponent,
+			})
+		}
 	}
 	if sm.lameduck {
 		details = append(details, &kv{
@@ -878,6 +964,26 @@ func (sm *stateManager) Target() *querypb.Target {
 	return proto.Clone(sm.target).(*querypb.Target)
 }
 
+// TransitionError returns the error of the last state transition,
+// prefixed with the subcomponent that failed. It returns an empty
+// string if the last transition succeeded.
+func (sm *stateManager) TransitionError() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	if sm.transitionErr == nil {
+		return ""
+	}
+	return sm.transitionErr.Error()
+}
+
+// TransitionErrorComponent returns the name of the subcomponent that
+// failed the last state transition, or an empty string if there is none.
+func (sm *stateManager) TransitionErrorComponent() string {
+	sm.mu.Lock()
+	defer sm.mu.Unlock()
+	return sm.transitionComponent
+}
+
 // IsServingString returns the name of the current TabletServer state.
 func (sm *stateManager) IsServingString() string {
 	if sm.IsServing() {
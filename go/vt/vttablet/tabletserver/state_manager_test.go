@@ -25,6 +25,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"testing"
 	"time"
@@ -39,12 +40,18 @@ import (
 
 	"vitess.io/vitess/go/mysql/fakesqldb"
 
+	"vitess.io/vitess/go/sqltypes"
 	"vitess.io/vitess/go/sync2"
+	"vitess.io/vitess/go/vt/dbconfigs"
 	"vitess.io/vitess/go/vt/log"
 	querypb "vitess.io/vitess/go/vt/proto/query"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/jobcontroller"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/background"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/connpool"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/throttle"
 )
 
 var testNow = time.Now()
@@ -228,6 +235,165 @@ func TestStateManagerStopService(t *testing.T) {
 	assert.Equal(t, StateNotConnected, sm.state)
 }
 
+func TestStateManagerStopServiceDrain(t *testing.T) {
+	defer func(interval time.Duration) { drainPollInterval = interval }(drainPollInterval)
+	drainPollInterval = time.Millisecond
+
+	sm := newTestStateManager(t)
+	defer sm.StopService()
+	sm.drainPeriod = 10 * time.Second
+	err := sm.SetServingType(topodatapb.TabletType_REPLICA, testNow, StateServing, "")
+	require.NoError(t, err)
+
+	kconn := &killableConn{id: 1}
+	qd := &QueryDetail{conn: kconn, connID: kconn.id}
+	sm.statelessql.Add(qd)
+
+	stopped := make(chan struct{})
+	go func() {
+		sm.StopService()
+		close(stopped)
+	}()
+
+	// New queries are refused while the in-flight one is drained,
+	// the ones allowed on shutdown are still accepted.
+	target := &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}
+	assert.Eventually(t, func() bool {
+		err := sm.StartRequest(context.Background(), target, false)
+		if err == nil {
+			sm.EndRequest()
+		}
+		return err != nil && strings.Contains(err.Error(), vterrors.ShuttingDown)
+	}, 5*time.Second, time.Millisecond)
+	err = sm.StartRequest(context.Background(), target, true)
+	require.NoError(t, err)
+	sm.EndRequest()
+	select {
+	case <-stopped:
+		t.Fatal("StopService returned before the in-flight query finished")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, StateServing, sm.State())
+
+	// The service is stopped once the in-flight query finishes, without killing it.
+	sm.statelessql.Remove(qd)
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		t.Fatal("StopService didn't return after the in-flight query finished")
+	}
+	assert.False(t, kconn.killed.Get())
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.False(t, sm.draining)
+}
+
+func TestStateManagerStopServiceDrainTimeout(t *testing.T) {
+	sm := newTestStateManager(t)
+	defer sm.StopService()
+	sm.drainPeriod = 10 * time.Millisecond
+	err := sm.SetServingType(topodatapb.TabletType_REPLICA, testNow, StateServing, "")
+	require.NoError(t, err)
+
+	// A query that doesn't finish within the drain period doesn't block the shutdown.
+	kconn := &killableConn{id: 1}
+	sm.statelessql.Add(&QueryDetail{conn: kconn, connID: kconn.id})
+	sm.StopService()
+	assert.Equal(t, StateNotConnected, sm.State())
+}
+
+func TestStateManagerStopServiceDrainOutlastsTimeBomb(t *testing.T) {
+	sm := newTestStateManager(t)
+	defer sm.StopService()
+	err := sm.SetServingType(topodatapb.TabletType_REPLICA, testNow, StateServing, "")
+	require.NoError(t, err)
+
+	// Draining for longer than the time bomb must not crash the shutdown.
+	sm.timebombDuration = time.Second
+	sm.drainPeriod = 10 * time.Second
+	kconn := &killableConn{id: 1}
+	qd := &QueryDetail{conn: kconn, connID: kconn.id}
+	sm.statelessql.Add(qd)
+	go func() {
+		time.Sleep(2 * sm.timebombDuration)
+		sm.statelessql.Remove(qd)
+	}()
+	sm.StopService()
+	assert.Equal(t, StateNotConnected, sm.State())
+	assert.False(t, kconn.killed.Get())
+}
+
+func TestStateManagerTransitionToReplicaStopsDMLJobBatch(t *testing.T) {
+	const (
+		batchTable    = "_vt_BATCH_test"
+		batchSQL      = "delete from t1 where id >= 1 and id <= 10"
+		batchCountSQL = "select count(*) as count_rows from t1 where id >= 1 and id <= 10"
+	)
+
+	db := fakesqldb.New(t)
+	defer db.Close()
+	params, _ := db.ConnParams().MysqlParams()
+	cp := *params
+	config := tabletenv.NewDefaultConfig()
+	config.DB = dbconfigs.NewTestDBConfigs(cp, cp, "fakesqldb")
+	env := tabletenv.NewEnv(config, "StateManagerJobControllerTest")
+	taskPool := background.NewTaskPool(env)
+	taskPool.Open()
+	defer taskPool.Close()
+	tabletTypeFunc := func() topodatapb.TabletType { return topodatapb.TabletType_PRIMARY }
+	jc := jobcontroller.NewJobController(tabletTypeFunc, env, throttle.NewThrottler(env, nil, nil, "cell", nil, tabletTypeFunc), taskPool)
+
+	// a running job is resumed when the job controller opens, its first batch is still in flight
+	// when the tablet is demoted.
+	db.AddQuery("select * from mysql.non_transactional_dml_jobs order by id", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|table_schema|table_name|batch_info_table_name|fail_policy|status|batch_interval_in_ms|batch_size",
+			"varchar|varchar|varchar|varchar|varchar|varchar|int64|int64"),
+		fmt.Sprintf("uuid||t1|%s|abort|%s|1|100", batchTable, jobcontroller.RunningStatus)))
+	db.AddQueryPattern(`select \* from mysql\.non_transactional_dml_jobs\s+where\s+job_uuid = 'uuid'`, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("job_uuid|status", "varchar|varchar"), "uuid|"+jobcontroller.RunningStatus))
+	db.AddQueryPattern(`update mysql\.non_transactional_dml_jobs set\s+.*`, &sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(fmt.Sprintf("SELECT batch_id FROM %s where batch_status = 'queued' order by CAST(SUBSTRING_INDEX(batch_id, '-', 1) AS SIGNED),id limit 1", batchTable),
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("batch_id", "varchar"), "1"))
+	db.AddQuery(fmt.Sprintf("select batch_sql,batch_count_sql_when_creating_batch from %s where batch_id = '1'", batchTable), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_sql|batch_count_sql_when_creating_batch", "text|text"), batchSQL+"|"+batchCountSQL))
+	db.AddQuery("start transaction", &sqltypes.Result{})
+	db.AddQuery("rollback", &sqltypes.Result{})
+	db.AddQuery("commit", &sqltypes.Result{})
+	db.AddQuery(batchCountSQL+" LOCK IN SHARE MODE", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("count_rows", "int64"), "10"))
+	db.AddQuery(fmt.Sprintf("SELECT batch_status FROM %s where batch_id='1'", batchTable), sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("batch_status", "varchar"), jobcontroller.QueuedStatus))
+	db.AddQuery(fmt.Sprintf("update %s set batch_status = '%s',actually_affected_rows = actually_affected_rows+10 where batch_id = '1'", batchTable, jobcontroller.CompletedStatus),
+		&sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(batchSQL, &sqltypes.Result{RowsAffected: 10})
+	batchStarted := make(chan struct{})
+	var once sync.Once
+	db.SetBeforeFunc(batchSQL, func() {
+		once.Do(func() { close(batchStarted) })
+		time.Sleep(500 * time.Millisecond)
+	})
+
+	sm := newTestStateManager(t)
+	defer sm.StopService()
+	sm.dmlJobController = jc
+	err := sm.SetServingType(topodatapb.TabletType_PRIMARY, testNow, StateServing, "")
+	require.NoError(t, err)
+
+	select {
+	case <-batchStarted:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the batch of the running job didn't start")
+	}
+	err = sm.SetServingType(topodatapb.TabletType_REPLICA, testNow, StateServing, "")
+	require.NoError(t, err)
+	assert.Equal(t, topodatapb.TabletType_REPLICA, sm.Target().TabletType)
+
+	// the in-flight batch has been rolled back by the transition, and nothing commits afterwards
+	assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, db.GetQueryCalledNum("commit"))
+	assert.Equal(t, 1, db.GetQueryCalledNum(batchSQL))
+}
+
 func TestStateManagerGracePeriod(t *testing.T) {
 	sm := newTestStateManager(t)
 	defer sm.StopService()
@@ -369,6 +535,37 @@ func TestStateManagerTransitionFailRetry(t *testing.T) {
 	assert.Equal(t, StateServing, sm.State())
 }
 
+func TestStateManagerTransitionErrorComponent(t *testing.T) {
+	sm := newTestStateManager(t)
+	defer sm.StopService()
+	sm.qe.(*testQueryEngine).failOpen = true
+
+	err := sm.SetServingType(topodatapb.TabletType_PRIMARY, testNow, StateServing, "")
+	require.EqualError(t, err, "QueryEngine: intentional error")
+
+	assert.Equal(t, "QueryEngine: intentional error", sm.TransitionError())
+	assert.Equal(t, "QueryEngine", sm.TransitionErrorComponent())
+	details := sm.AppendDetails(nil)
+	assert.Contains(t, details, &kv{
+		Key:   "Transition Error Component",
+		Class: unhealthyClass,
+		Value: "QueryEngine",
+	})
+
+	// The retry succeeds and clears the error.
+	for {
+		sm.mu.Lock()
+		retrying := sm.retrying
+		sm.mu.Unlock()
+		if !retrying {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, "", sm.TransitionError())
+	assert.Equal(t, "", sm.TransitionErrorComponent())
+}
+
 func TestStateManagerNotConnectedType(t *testing.T) {
 	sm := newTestStateManager(t)
 	defer sm.StopService()
@@ -830,8 +1027,9 @@ func (te *testSchemaEngine) Close() {
 
 type testReplTracker struct {
 	testOrderState
-	lag time.Duration
-	err error
+	lag           time.Duration
+	lastHeartbeat time.Time
+	err           error
 }
 
 func (te *testReplTracker) MakePrimary() {
@@ -853,6 +1051,10 @@ func (te *testReplTracker) Status() (time.Duration, error) {
 	return te.lag, te.err
 }
 
+func (te *testReplTracker) HeartbeatStatus() (time.Duration, time.Time, error) {
+	return te.lag, te.lastHeartbeat, te.err
+}
+
 func (te *testReplTracker) GtidExecuted() (mysql.Position, error) {
 	return mysql.Position{}, nil
 }
@@ -865,9 +1067,14 @@ type testQueryEngine struct {
 	testOrderState
 
 	failMySQL bool
+	failOpen  bool
 }
 
 func (te *testQueryEngine) Open() error {
+	if te.failOpen {
+		te.failOpen = false
+		return errors.New("intentional error")
+	}
 	te.order = order.Add(1)
 	te.state = testStateOpen
 	return nil
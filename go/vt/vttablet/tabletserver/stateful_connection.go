@@ -55,6 +55,10 @@ type StatefulConnection struct {
 	enforceTimeout bool
 	timeout        time.Duration
 	expiryTime     time.Time
+	// lastUsed is when the connection was last returned to the pool by its user.
+	lastUsed time.Time
+	// idleReported is set once the connection is reported as idle, until it's used again.
+	idleReported bool
 }
 
 // Properties contains meta information about the connection
@@ -92,6 +96,25 @@ func (sc *StatefulConnection) ElapsedTimeout() bool {
 	return sc.expiryTime.Before(time.Now())
 }
 
+// ExceededMaxDuration returns true if the connection is in a transaction that
+// was begun longer than maxDuration ago. Unlike the timeout, this is not reset
+// by the statements executed in the transaction.
+func (sc *StatefulConnection) ExceededMaxDuration(maxDuration time.Duration) bool {
+	if !sc.enforceTimeout || maxDuration <= 0 || !sc.IsInTransaction() {
+		return false
+	}
+	return time.Since(sc.txProps.StartTime) > maxDuration
+}
+
+// IdleReserved returns true if the connection is reserved and has not been
+// used for longer than threshold.
+func (sc *StatefulConnection) IdleReserved(threshold time.Duration) bool {
+	if !sc.enforceTimeout || threshold <= 0 || !sc.IsTainted() {
+		return false
+	}
+	return time.Since(sc.lastUsed) > threshold
+}
+
 // Exec executes the statement in the dedicated connection
 func (sc *StatefulConnection) Exec(ctx context.Context, query string, maxrows int, wantfields bool) (*sqltypes.Result, error) {
 	if sc.IsClosed() {
@@ -160,6 +183,8 @@ func (sc *StatefulConnection) unlock(updateTime bool) {
 	if sc.dbConn.IsClosed() {
 		sc.Releasef("unlocked closed connection")
 	} else {
+		sc.lastUsed = time.Now()
+		sc.idleReported = false
 		sc.pool.markAsNotInUse(sc, updateTime)
 	}
 }
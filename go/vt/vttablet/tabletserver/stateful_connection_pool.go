@@ -186,6 +186,39 @@ func (sf *StatefulConnectionPool) GetElapsedTimeout(purpose string) []*StatefulC
 	}))
 }
 
+// GetExceededMaxDuration returns the transactions that were begun longer than
+// maxDuration ago. Does not return any connections that are in use.
+func (sf *StatefulConnectionPool) GetExceededMaxDuration(purpose string, maxDuration time.Duration) []*StatefulConnection {
+	return mapToTxConn(sf.active.GetByFilter(purpose, func(val any) bool {
+		sc := val.(*StatefulConnection)
+		return sc.ExceededMaxDuration(maxDuration)
+	}))
+}
+
+// GetIdleReserved returns the reserved connections that have not been used
+// for longer than threshold. Does not return any connections that are in use.
+func (sf *StatefulConnectionPool) GetIdleReserved(purpose string, threshold time.Duration) []*StatefulConnection {
+	return mapToTxConn(sf.active.GetByFilter(purpose, func(val any) bool {
+		sc := val.(*StatefulConnection)
+		return sc.IdleReserved(threshold)
+	}))
+}
+
+// OldestReservedAge returns the age of the oldest reserved connection, 0 if there is none.
+func (sf *StatefulConnectionPool) OldestReservedAge() time.Duration {
+	var oldest time.Duration
+	for _, connection := range mapToTxConn(sf.active.GetAll()) {
+		props := connection.reservedProps
+		if props == nil {
+			continue
+		}
+		if age := time.Since(props.StartTime); age > oldest {
+			oldest = age
+		}
+	}
+	return oldest
+}
+
 func mapToTxConn(vals []any) []*StatefulConnection {
 	result := make([]*StatefulConnection, len(vals))
 	for i, el := range vals {
@@ -240,6 +273,7 @@ func (sf *StatefulConnectionPool) NewConn(ctx context.Context, options *querypb.
 		pool:           sf,
 		env:            sf.env,
 		enforceTimeout: options.GetWorkload() != querypb.ExecuteOptions_DBA,
+		lastUsed:       time.Now(),
 	}
 	// This will set both the timeout and initialize the expiryTime.
 	sfConn.SetTimeout(sf.env.Config().TxTimeoutForWorkload(options.GetWorkload()))
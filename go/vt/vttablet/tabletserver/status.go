@@ -244,6 +244,7 @@ func (tsv *TabletServer) AddStatusPart() {
 		}
 		status.Details = tsv.sm.AppendDetails(nil)
 		status.Details = tsv.hs.AppendDetails(status.Details)
+		status.Details = tsv.appendDiskFullDetails(status.Details)
 		rates := tsv.stats.QPSRates.Get()
 		if qps, ok := rates["All"]; ok && len(qps) > 0 {
 			status.CurrentQPS = qps[0]
@@ -255,6 +256,7 @@ func (tsv *TabletServer) AddStatusPart() {
 		w.Header().Set("Content-Type", "application/json; charset=utf-8")
 		details := tsv.sm.AppendDetails(nil)
 		details = tsv.hs.AppendDetails(details)
+		details = tsv.appendDiskFullDetails(details)
 		b, err := json.MarshalIndent(details, "", " ")
 		if err != nil {
 			w.Write([]byte(err.Error()))
@@ -0,0 +1,115 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"strings"
+
+	"vitess.io/vitess/go/sqltypes"
+	querypb "vitess.io/vitess/go/vt/proto/query"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/schema"
+)
+
+// streamProgress tracks the rows a stream delivered to its client, so that
+// the client can be sent a marker saying how far it got if the stream fails.
+// It is used when ExecuteOptions.ReportStreamFailureMarker is set.
+type streamProgress struct {
+	callback func(*sqltypes.Result) error
+	// table is the table the stream reads from, it's used to find the
+	// primary key of the rows. It's nil if the plan has no single table.
+	table *schema.Table
+
+	delivered uint64
+	// callbackFailed is set if the client callback returned an error,
+	// in which case the marker can't be delivered either.
+	callbackFailed bool
+
+	// pkFields and pkIndexes are the fields and the positions of the
+	// primary key columns in the stream, nil if they're not all selected.
+	pkFields  []*querypb.Field
+	pkIndexes []int
+	lastPK    []sqltypes.Value
+}
+
+func newStreamProgress(callback func(*sqltypes.Result) error) *streamProgress {
+	return &streamProgress{callback: callback}
+}
+
+// send delivers result to the client and records its rows.
+func (sp *streamProgress) send(result *sqltypes.Result) error {
+	if result.Fields != nil {
+		sp.findPK(result.Fields)
+	}
+	// The rows may be reused once the callback returns, so the primary key
+	// of the last row is copied before.
+	var lastPK []sqltypes.Value
+	if len(result.Rows) > 0 && sp.pkIndexes != nil {
+		lastRow := result.Rows[len(result.Rows)-1]
+		lastPK = make([]sqltypes.Value, 0, len(sp.pkIndexes))
+		for _, i := range sp.pkIndexes {
+			lastPK = append(lastPK, sqltypes.MakeTrusted(lastRow[i].Type(), append([]byte(nil), lastRow[i].Raw()...)))
+		}
+	}
+	if err := sp.callback(result); err != nil {
+		sp.callbackFailed = true
+		return err
+	}
+	sp.delivered += uint64(len(result.Rows))
+	if lastPK != nil {
+		sp.lastPK = lastPK
+	}
+	return nil
+}
+
+// findPK looks for the primary key columns of the table in fields.
+func (sp *streamProgress) findPK(fields []*querypb.Field) {
+	sp.pkFields, sp.pkIndexes = nil, nil
+	if sp.table == nil || !sp.table.HasPrimary() {
+		return
+	}
+	var pkFields []*querypb.Field
+	var pkIndexes []int
+	for _, pkColumn := range sp.table.PKColumns {
+		pkName := sp.table.Fields[pkColumn].Name
+		index := -1
+		for i, field := range fields {
+			if field.OrgTable != "" && !strings.EqualFold(field.OrgTable, sp.table.Name.String()) {
+				continue
+			}
+			name := field.OrgName
+			if name == "" {
+				name = field.Name
+			}
+			if strings.EqualFold(name, pkName) {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			return
+		}
+		pkFields = append(pkFields, &querypb.Field{Name: pkName, Type: fields[index].Type})
+		pkIndexes = append(pkIndexes, index)
+	}
+	sp.pkFields, sp.pkIndexes = pkFields, pkIndexes
+}
+
+// sendMarker tells the client how many rows it got before the stream failed,
+// and the primary key of the last one if it's known.
+func (sp *streamProgress) sendMarker() error {
+	if sp.callbackFailed {
+		return nil
+	}
+	marker := &sqltypes.Result{
+		StreamFailureMarker: true,
+		RowsDelivered:       sp.delivered,
+	}
+	if sp.lastPK != nil {
+		marker.Fields = sp.pkFields
+		marker.Rows = [][]sqltypes.Value{sp.lastPK}
+	}
+	return sp.callback(marker)
+}
@@ -0,0 +1,48 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"vitess.io/vitess/go/acl"
+	tableaclpb "vitess.io/vitess/go/vt/proto/tableacl"
+	"vitess.io/vitess/go/vt/tableacl"
+)
+
+// TableACLInfo is the table ACL in effect, as returned by GetTableACL.
+type TableACLInfo struct {
+	// Mode is the mode the ACL is loaded in, e.g. simple or mysqlbased.
+	Mode string `json:"mode"`
+	// TableGroups has one group per table name or prefix, whichever the mode,
+	// so the groups loaded from a config with several tables are listed once per table.
+	TableGroups []*tableaclpb.TableGroupSpec `json:"table_groups"`
+}
+
+// GetTableACL returns the table ACL enforced by the tablet, e.g. to check
+// what is enforced after the ACL is reloaded.
+func (tsv *TabletServer) GetTableACL() *TableACLInfo {
+	currentACL := tableacl.GetCurrentACL()
+	return &TableACLInfo{
+		Mode:        currentACL.Mode(),
+		TableGroups: currentACL.TableGroups(),
+	}
+}
+
+func (tsv *TabletServer) registerTableACLHandler() {
+	tsv.exporter.HandleFunc("/debug/table_acl", tsv.tableACLHandler)
+}
+
+// tableACLHandler renders the table ACL in effect as JSON.
+func (tsv *TabletServer) tableACLHandler(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.DEBUGGING); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(tsv.GetTableACL())
+}
@@ -0,0 +1,96 @@
+/*
+Copyright ApeCloud, Inc.
+Licensed under the Apache v2(found in the LICENSE file in the root directory).
+*/
+
+package tabletserver
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"vitess.io/vitess/go/acl"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/rules"
+)
+
+// tableWritableQueryRuleSource is the query rule source holding the rules
+// installed by SetTableWritable.
+const tableWritableQueryRuleSource = "TableWritableQueryRules"
+
+// SetTableWritable makes the tablet reject writes to table when writable is false,
+// and accept them again when writable is true. table is fully qualified, e.g. "db.t1".
+// Reads and the other tables are unaffected.
+func (tsv *TabletServer) SetTableWritable(table string, writable bool) error {
+	if parts := strings.Split(table, "."); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return fmt.Errorf("table %q is not a fully qualified table name", table)
+	}
+
+	tsv.writeDisabledTablesMu.Lock()
+	defer tsv.writeDisabledTablesMu.Unlock()
+
+	if writable {
+		delete(tsv.writeDisabledTables, table)
+	} else {
+		tsv.writeDisabledTables[table] = true
+	}
+
+	qrs := rules.New()
+	for _, tbl := range tsv.writeDisabledTablesLocked() {
+		qr := rules.NewActiveQueryRule(fmt.Sprintf("writes to table %s are disabled", tbl), fmt.Sprintf("table_write_disabled_%s", tbl), rules.QRFail)
+		for _, plan := range keyspaceWritePlans {
+			qr.AddPlanCond(plan)
+		}
+		qr.AddTableCond(tbl)
+		qrs.Add(qr)
+	}
+	return tsv.SetQueryRules(tableWritableQueryRuleSource, qrs)
+}
+
+// WriteDisabledTables returns the sorted list of tables made unwritable by SetTableWritable.
+func (tsv *TabletServer) WriteDisabledTables() []string {
+	tsv.writeDisabledTablesMu.Lock()
+	defer tsv.writeDisabledTablesMu.Unlock()
+	return tsv.writeDisabledTablesLocked()
+}
+
+func (tsv *TabletServer) writeDisabledTablesLocked() []string {
+	tables := make([]string, 0, len(tsv.writeDisabledTables))
+	for tbl := range tsv.writeDisabledTables {
+		tables = append(tables, tbl)
+	}
+	sort.Strings(tables)
+	return tables
+}
+
+// registerTableWritableHandler registers the handler to list and toggle the tables whose writes are disabled.
+// A POST with "table" and "writable" form values changes the mode of a table.
+func (tsv *TabletServer) registerTableWritableHandler() {
+	tsv.exporter.HandleFunc("/debug/table_writable", func(w http.ResponseWriter, r *http.Request) {
+		if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+			acl.SendError(w, err)
+			return
+		}
+		if r.Method == "POST" {
+			table := r.FormValue("table")
+			if table == "" {
+				http.Error(w, "not ok: missing table", http.StatusBadRequest)
+				return
+			}
+			writable, err := strconv.ParseBool(r.FormValue("writable"))
+			if err != nil {
+				http.Error(w, fmt.Sprintf("not ok: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := tsv.SetTableWritable(table, writable); err != nil {
+				http.Error(w, fmt.Sprintf("not ok: %v", err), http.StatusBadRequest)
+				return
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(tsv.WriteDisabledTables())
+	})
+}
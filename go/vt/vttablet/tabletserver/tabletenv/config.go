@@ -24,6 +24,7 @@ package tabletenv
 import (
 	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
@@ -109,16 +110,22 @@ func registerTabletEnvFlags(fs *pflag.FlagSet) {
 	fs.IntVar(&currentConfig.OlapReadPool.PrefillParallelism, "queryserver-config-stream-pool-prefill-parallelism", defaultConfig.OlapReadPool.PrefillParallelism, "Query server stream pool prefill parallelism, a non-zero value will prefill the pool using the specified parallelism")
 	_ = fs.MarkDeprecated("queryserver-config-stream-pool-prefill-parallelism", "it will be removed in a future release.")
 	fs.IntVar(&currentConfig.TxPool.Size, "queryserver-config-transaction-cap", defaultConfig.TxPool.Size, "query server transaction cap is the maximum number of transactions allowed to happen at any given point of a time for a single vttablet. E.g. by setting transaction cap to 100, there are at most 100 transactions will be processed by a vttablet and the 101th transaction will be blocked (and fail if it cannot get connection within specified timeout)")
+	fs.IntVar(&currentConfig.MinPoolSize, "queryserver-config-min-pool-size", defaultConfig.MinPoolSize, "query server minimum pool size, the query, stream and transaction pools can't be resized below it at runtime, e.g. by the debug env page or a config reload, to prevent a mistyped size from starving the tablet of connections")
 	fs.IntVar(&currentConfig.TxPool.MaxSize, "queryserver-config-max-transaction-maxsize", defaultConfig.TxPool.MaxSize, "query server max transaction size defines the maximum size of a transaction in bytes. This is used to prevent excessively large transactions which can lead to performance degradation or instability. For example, setting max transaction size to 1048576 will limit the size of a transaction to 1MB, and any transaction exceeding this size will be rejected or truncated.")
 	fs.IntVar(&currentConfig.TxPool.PrefillParallelism, "queryserver-config-transaction-prefill-parallelism", defaultConfig.TxPool.PrefillParallelism, "Query server transaction prefill parallelism, a non-zero value will prefill the pool using the specified parallism.")
 	_ = fs.MarkDeprecated("queryserver-config-transaction-prefill-parallelism", "it will be removed in a future release.")
+	fs.IntVar(&currentConfig.MessageDeadlockRetries, "queryserver-config-message-deadlock-retries", defaultConfig.MessageDeadlockRetries, "query server message deadlock retries is the number of times the DMLs of the message subsystem (acks, postpones and purges) are retried when their transaction is rolled back by a deadlock. They are idempotent, so a retry can't apply them twice. If set to 0 (default) then the deadlock is returned as is.")
 	fs.IntVar(&currentConfig.MessagePostponeParallelism, "queryserver-config-message-postpone-cap", defaultConfig.MessagePostponeParallelism, "query server message postpone cap is the maximum number of messages that can be postponed at any given time. Set this number to substantially lower than transaction cap, so that the transaction pool isn't exhausted by the message subsystem.")
 	SecondsVar(fs, &currentConfig.Oltp.TxTimeoutSeconds, "queryserver-config-transaction-timeout", defaultConfig.Oltp.TxTimeoutSeconds, "query server transaction timeout (in seconds), a transaction will be killed if it takes longer than this value")
+	SecondsVar(fs, &currentConfig.TxMaxDurationSeconds, "queryserver-config-transaction-max-duration", defaultConfig.TxMaxDurationSeconds, "query server transaction max duration (in seconds), a transaction that has been open since its begin for longer than this value is rolled back, no matter how often it executes statements. If set to 0 (default) then there is no limit.")
 	SecondsVar(fs, &currentConfig.GracePeriods.ShutdownSeconds, "shutdown_grace_period", defaultConfig.GracePeriods.ShutdownSeconds, "how long to wait (in seconds) for queries and transactions to complete during graceful shutdown.")
+	SecondsVar(fs, &currentConfig.GracePeriods.DrainSeconds, "shutdown_drain_period", defaultConfig.GracePeriods.DrainSeconds, "how long to wait (in seconds) for in-flight queries to finish when stopping the service, before shutting it down. New queries are refused while waiting. If set to 0 (default) then there is no draining.")
 	fs.IntVar(&currentConfig.Oltp.MaxRows, "queryserver-config-max-result-size", defaultConfig.Oltp.MaxRows, "query server max result size, maximum number of rows allowed to return from vttablet for non-streaming queries.")
 	fs.IntVar(&currentConfig.Oltp.WarnRows, "queryserver-config-warn-result-size", defaultConfig.Oltp.WarnRows, "query server result size warning threshold, warn if number of rows returned from vttablet for non-streaming queries exceeds this")
 	fs.BoolVar(&currentConfig.PassthroughDML, "queryserver-config-passthrough-dmls", defaultConfig.PassthroughDML, "query server pass through all dml statements without rewriting")
 
+	fs.IntVar(&currentConfig.StreamMaxConcurrency, "queryserver-config-stream-max-concurrency", defaultConfig.StreamMaxConcurrency, "query server stream max concurrency, the maximum number of stream queries executed at the same time. The stream queries beyond it are rejected, independently of the stream pool size. If set to 0 (default) then there is no limit.")
+	fs.IntVar(&currentConfig.MaxBindVarsPerQuery, "queryserver-config-max-bind-vars-per-query", defaultConfig.MaxBindVarsPerQuery, "query server max bind vars per query, the maximum number of bind variables of a non-streaming query, every value of a list, e.g. the one of a normalized IN clause, counting as a variable. The queries beyond it are rejected before being planned. If set to 0 then there is no limit.")
 	fs.IntVar(&currentConfig.StreamBufferSize, "queryserver-config-stream-buffer-size", defaultConfig.StreamBufferSize, "query server stream buffer size, the maximum number of bytes sent from vttablet for each stream call. It's recommended to keep this value in sync with vtgate's stream_buffer_size.")
 	fs.IntVar(&currentConfig.QueryCacheSize, "queryserver-config-query-cache-size", defaultConfig.QueryCacheSize, "query server query cache size, maximum number of queries to be cached. vttablet analyzes every incoming query and generate a query plan, these plans are being cached in a lru cache. This config controls the capacity of the lru cache.")
 	fs.Int64Var(&currentConfig.QueryCacheMemory, "queryserver-config-query-cache-memory", defaultConfig.QueryCacheMemory, "query server query cache size in bytes, maximum amount of memory to be used for caching. vttablet analyzes every incoming query and generate a query plan, these plans are being cached in a lru cache. This config controls the capacity of the lru cache.")
@@ -127,7 +134,16 @@ func registerTabletEnvFlags(fs *pflag.FlagSet) {
 	SecondsVar(fs, &currentConfig.SignalSchemaChangeReloadIntervalSeconds, "queryserver-config-schema-change-signal-interval", defaultConfig.SignalSchemaChangeReloadIntervalSeconds, "query server schema change signal interval defines at which interval the query server shall send schema updates to vtgate.")
 	fs.BoolVar(&currentConfig.SignalWhenSchemaChange, "queryserver-config-schema-change-signal", defaultConfig.SignalWhenSchemaChange, "query server schema signal, will signal connected vtgates that schema has changed whenever this is detected. VTGates will need to have -schema_change_signal enabled for this to work")
 	SecondsVar(fs, &currentConfig.Olap.TxTimeoutSeconds, "queryserver-config-olap-transaction-timeout", defaultConfig.Olap.TxTimeoutSeconds, "query server transaction timeout (in seconds), after which a transaction in an OLAP session will be killed")
+	SecondsVar(fs, &currentConfig.Olap.QueryTimeoutSeconds, "queryserver-config-olap-query-timeout", defaultConfig.Olap.QueryTimeoutSeconds, "query server query timeout (in seconds) for streaming queries in an OLAP session. If set to 0 (default) then streaming queries outside of a transaction have no timeout.")
 	SecondsVar(fs, &currentConfig.Oltp.QueryTimeoutSeconds, "queryserver-config-query-timeout", defaultConfig.Oltp.QueryTimeoutSeconds, "query server query timeout (in seconds), this is the query timeout in vttablet side. If a query takes more than this timeout, it will be killed.")
+	SecondsVar(fs, &currentConfig.OltpReadPool.LeakThresholdSeconds, "queryserver-config-pool-leak-threshold", defaultConfig.OltpReadPool.LeakThresholdSeconds, "query server connection leak threshold (in seconds), connections taken from the query, stream and transaction pools and not returned within this threshold are logged as leaked. If set to 0 (default) then leak detection is disabled.")
+	SecondsVar(fs, &currentConfig.ReservedConnIdleThresholdSeconds, "queryserver-config-reserved-conn-idle-threshold", defaultConfig.ReservedConnIdleThresholdSeconds, "query server reserved connection idle threshold (in seconds), reserved connections that are not used for longer than this value are counted and logged as leaked. If set to 0 (default) then the detection is disabled.")
+	fs.BoolVar(&currentConfig.ReservedConnIdleRelease, "queryserver-config-reserved-conn-idle-release", defaultConfig.ReservedConnIdleRelease, "query server releases the reserved connections that are not used for longer than queryserver-config-reserved-conn-idle-threshold, instead of only reporting them.")
+	fs.IntVar(&currentConfig.DiskFullReadOnlyThreshold, "queryserver-config-disk-full-read-only-threshold", defaultConfig.DiskFullReadOnlyThreshold, "query server disk full read only threshold, the number of disk full errors returned by MySQL in a row, i.e. without a successful write in between, after which vttablet rejects the writes until the disk of MySQL has space again. If set to 0 (default) then the writes are never rejected because of a full disk.")
+	SecondsVar(fs, &currentConfig.DiskFullProbeIntervalSeconds, "queryserver-config-disk-full-probe-interval", defaultConfig.DiskFullProbeIntervalSeconds, "query server disk full probe interval (in seconds), how often vttablet tries a write to check if the disk of MySQL has space again while the writes are rejected because of a full disk.")
+	fs.BoolVar(&currentConfig.OltpReadPool.LeakCaptureStack, "queryserver-config-pool-leak-capture-stack", defaultConfig.OltpReadPool.LeakCaptureStack, "query server captures the stack when a connection is taken from a pool and logs it with the leaked connections. Useful for debugging leaks, but costly.")
+	SecondsVar(fs, &currentConfig.OltpReadPool.BadSettingTTLSeconds, "queryserver-config-pool-bad-setting-ttl", defaultConfig.OltpReadPool.BadSettingTTLSeconds, "query server remembers for this long (in seconds) the settings that MySQL rejected when applying them to a pooled connection, e.g. an unknown variable or a wrong value, queries with such a setting fail right away instead of trying to apply it again. If set to 0 (default) then the settings are always applied.")
+	SecondsVar(fs, &currentConfig.SlowQueryThresholdSeconds, "queryserver-config-slow-query-threshold", defaultConfig.SlowQueryThresholdSeconds, "query server slow query threshold (in seconds), queries that take longer than this value are logged as slow queries together with the table they touch. If set to 0 (default) then slow query logging is disabled.")
 	SecondsVar(fs, &currentConfig.OltpReadPool.TimeoutSeconds, "queryserver-config-query-pool-timeout", defaultConfig.OltpReadPool.TimeoutSeconds, "query server query pool timeout (in seconds), it is how long vttablet waits for a connection from the query pool. If set to 0 (default) then the overall query timeout is used instead.")
 	SecondsVar(fs, &currentConfig.OlapReadPool.TimeoutSeconds, "queryserver-config-stream-pool-timeout", defaultConfig.OlapReadPool.TimeoutSeconds, "query server stream pool timeout (in seconds), it is how long vttablet waits for a connection from the stream pool. If set to 0 (default) then there is no timeout.")
 	SecondsVar(fs, &currentConfig.TxPool.TimeoutSeconds, "queryserver-config-txpool-timeout", defaultConfig.TxPool.TimeoutSeconds, "query server transaction pool timeout, it is how long vttablet waits if tx pool is full")
@@ -150,6 +166,7 @@ func registerTabletEnvFlags(fs *pflag.FlagSet) {
 	flagutil.DualFormatBoolVar(fs, &currentConfig.EnableTxThrottler, "enable_tx_throttler", defaultConfig.EnableTxThrottler, "If true replication-lag-based throttling on transactions will be enabled.")
 	flagutil.DualFormatStringVar(fs, &currentConfig.TxThrottlerConfig, "tx_throttler_config", defaultConfig.TxThrottlerConfig, "The configuration of the transaction throttler as a text formatted throttlerdata.Configuration protocol buffer message")
 	flagutil.DualFormatStringListVar(fs, &currentConfig.TxThrottlerHealthCheckCells, "tx_throttler_healthcheck_cells", defaultConfig.TxThrottlerHealthCheckCells, "A comma-separated list of cells. Only tabletservers running in these cells will be monitored for replication lag by the transaction throttler.")
+	SecondsVar(fs, &currentConfig.TxThrottlerMaxWaitSeconds, "tx_throttler_max_wait", defaultConfig.TxThrottlerMaxWaitSeconds, "How long (in seconds) a begin waits for the transaction throttler to clear, retrying with backoff, before it's rejected. If set to 0 (default) then throttled begins are rejected immediately.")
 
 	fs.BoolVar(&enableHotRowProtection, "enable_hot_row_protection", false, "If true, incoming transactions for the same row (range) will be queued and cannot consume all txpool slots.")
 	fs.BoolVar(&enableHotRowProtectionDryRun, "enable_hot_row_protection_dry_run", false, "If true, hot row protection is not enforced but logs if transactions would have been queued.")
@@ -174,6 +191,11 @@ func registerTabletEnvFlags(fs *pflag.FlagSet) {
 	flagutil.DualFormatBoolVar(fs, &enableConsolidatorReplicas, "enable_consolidator_replicas", false, "This option enables the query consolidator only on replicas.")
 	fs.Int64Var(&currentConfig.ConsolidatorStreamQuerySize, "consolidator-stream-query-size", defaultConfig.ConsolidatorStreamQuerySize, "Configure the stream consolidator query size in bytes. Setting to 0 disables the stream consolidator.")
 	fs.Int64Var(&currentConfig.ConsolidatorStreamTotalSize, "consolidator-stream-total-size", defaultConfig.ConsolidatorStreamTotalSize, "Configure the stream consolidator total size in bytes. Setting to 0 disables the stream consolidator.")
+	fs.StringSliceVar(&currentConfig.ResultCacheTables, "queryserver-config-result-cache-tables", defaultConfig.ResultCacheTables, "Comma-separated list of tables whose SELECT results are cached by vttablet, meant for small reference tables that are read frequently and rarely change. Empty (default) disables the result cache.")
+	SecondsVar(fs, &currentConfig.ResultCacheTTLSeconds, "queryserver-config-result-cache-ttl", defaultConfig.ResultCacheTTLSeconds, "How long (in seconds) a cached result of a table in queryserver-config-result-cache-tables is served before it expires.")
+	fs.IntVar(&currentConfig.ResultCacheSize, "queryserver-config-result-cache-size", defaultConfig.ResultCacheSize, "Maximum number of query results kept in the result cache.")
+	fs.StringArrayVar(&currentConfig.QueryListOverrides, "queryserver-config-query-list-override", defaultConfig.QueryListOverrides, "Pins the queries on a table, or a normalized query, to the olap or the oltp-stateful query list whatever list they'd be added to otherwise, e.g. to kill known-heavy queries along with the olap ones. The value is like 'olap:table' or 'olap:select * from t where c = :c', the flag can be repeated.")
+	fs.StringSliceVar(&currentConfig.AllowedSettingVariables, "queryserver-config-allowed-setting-variables", defaultConfig.AllowedSettingVariables, "Comma-separated list of the system variables the clients can set on the backend connections, the settings of any other variable are rejected. Empty (the default) allows every variable.")
 	flagutil.DualFormatBoolVar(fs, &currentConfig.DeprecatedCacheResultFields, "enable_query_plan_field_caching", defaultConfig.DeprecatedCacheResultFields, "This option fetches & caches fields (columns) when storing query plans")
 	_ = fs.MarkDeprecated("enable_query_plan_field_caching", "it will be removed in a future release.")
 	_ = fs.MarkDeprecated("enable-query-plan-field-caching", "it will be removed in a future release.")
@@ -181,6 +203,8 @@ func registerTabletEnvFlags(fs *pflag.FlagSet) {
 	fs.DurationVar(&healthCheckInterval, "health_check_interval", 20*time.Second, "Interval between health checks")
 	fs.DurationVar(&degradedThreshold, "degraded_threshold", 30*time.Second, "replication lag after which a replica is considered degraded")
 	fs.DurationVar(&unhealthyThreshold, "unhealthy_threshold", 2*time.Hour, "replication lag after which a replica is considered unhealthy")
+	fs.IntVar(&currentConfig.Healthcheck.Retries, "health_check_retries", defaultConfig.Healthcheck.Retries, "How many times a health check query failing with a transient connection error is retried before the tablet is reported unhealthy.")
+	SecondsVar(fs, &currentConfig.Healthcheck.RetryIntervalSeconds, "health_check_retry_interval", defaultConfig.Healthcheck.RetryIntervalSeconds, "How long (in seconds) to wait before retrying a health check query that failed with a transient connection error.")
 	fs.DurationVar(&transitionGracePeriod, "serving_state_grace_period", 0, "how long to pause after broadcasting health to vtgate, before enforcing a new serving state")
 
 	fs.BoolVar(&enableReplicationReporter, "enable_replication_reporter", false, "Use polling to track replication lag.")
@@ -209,6 +233,12 @@ func Init() {
 	currentConfig.TxPool.IdleTimeoutSeconds = currentConfig.OltpReadPool.IdleTimeoutSeconds
 	currentConfig.OlapReadPool.MaxLifetimeSeconds = currentConfig.OltpReadPool.MaxLifetimeSeconds
 	currentConfig.TxPool.MaxLifetimeSeconds = currentConfig.OltpReadPool.MaxLifetimeSeconds
+	// So do the leak detection and the bad setting TTL.
+	for _, pool := range []*ConnPoolConfig{&currentConfig.OlapReadPool, &currentConfig.TxPool} {
+		pool.LeakThresholdSeconds = currentConfig.OltpReadPool.LeakThresholdSeconds
+		pool.LeakCaptureStack = currentConfig.OltpReadPool.LeakCaptureStack
+		pool.BadSettingTTLSeconds = currentConfig.OltpReadPool.BadSettingTTLSeconds
+	}
 
 	if enableHotRowProtection {
 		if enableHotRowProtectionDryRun {
@@ -282,6 +312,8 @@ type TabletConfig struct {
 	OltpReadPool ConnPoolConfig `json:"oltpReadPool,omitempty"`
 	OlapReadPool ConnPoolConfig `json:"olapReadPool,omitempty"`
 	TxPool       ConnPoolConfig `json:"txPool,omitempty"`
+	// MinPoolSize is the size the query, stream and transaction pools can't be resized below at runtime.
+	MinPoolSize int `json:"minPoolSize,omitempty"`
 
 	Olap             OlapConfig             `json:"olap,omitempty"`
 	Oltp             OltpConfig             `json:"oltp,omitempty"`
@@ -296,6 +328,8 @@ type TabletConfig struct {
 	Consolidator                            string  `json:"consolidator,omitempty"`
 	PassthroughDML                          bool    `json:"passthroughDML,omitempty"`
 	StreamBufferSize                        int     `json:"streamBufferSize,omitempty"`
+	StreamMaxConcurrency                    int     `json:"streamMaxConcurrency,omitempty"`
+	MaxBindVarsPerQuery                     int     `json:"maxBindVarsPerQuery,omitempty"`
 	ConsolidatorStreamTotalSize             int64   `json:"consolidatorStreamTotalSize,omitempty"`
 	ConsolidatorStreamQuerySize             int64   `json:"consolidatorStreamQuerySize,omitempty"`
 	QueryCacheSize                          int     `json:"queryCacheSize,omitempty"`
@@ -306,11 +340,34 @@ type TabletConfig struct {
 	WatchReplication                        bool    `json:"watchReplication,omitempty"`
 	TrackSchemaVersions                     bool    `json:"trackSchemaVersions,omitempty"`
 	TerseErrors                             bool    `json:"terseErrors,omitempty"`
+	SlowQueryThresholdSeconds               Seconds `json:"slowQueryThresholdSeconds,omitempty"`
+	TxMaxDurationSeconds                    Seconds `json:"txMaxDurationSeconds,omitempty"`
 	AnnotateQueries                         bool    `json:"annotateQueries,omitempty"`
 	MessagePostponeParallelism              int     `json:"messagePostponeParallelism,omitempty"`
+	MessageDeadlockRetries                  int     `json:"messageDeadlockRetries,omitempty"`
 	DeprecatedCacheResultFields             bool    `json:"cacheResultFields,omitempty"`
 	SignalWhenSchemaChange                  bool    `json:"signalWhenSchemaChange,omitempty"`
 
+	// ResultCacheTables are the tables whose SELECT results are cached, the cache is disabled if empty.
+	ResultCacheTables     []string `json:"resultCacheTables,omitempty"`
+	ResultCacheTTLSeconds Seconds  `json:"resultCacheTTLSeconds,omitempty"`
+	ResultCacheSize       int      `json:"resultCacheSize,omitempty"`
+
+	// QueryListOverrides pin the queries on a table, or a normalized query, to a query list, see ParseQueryListOverride.
+	QueryListOverrides []string `json:"queryListOverrides,omitempty"`
+
+	// AllowedSettingVariables are the system variables the connection settings can set, any variable is allowed if empty.
+	AllowedSettingVariables []string `json:"allowedSettingVariables,omitempty"`
+
+	// ReservedConnIdleThresholdSeconds is how long a reserved connection can stay unused before it's reported as leaked, 0 disables it.
+	ReservedConnIdleThresholdSeconds Seconds `json:"reservedConnIdleThresholdSeconds,omitempty"`
+	// ReservedConnIdleRelease releases the reserved connections found idle instead of only reporting them.
+	ReservedConnIdleRelease bool `json:"reservedConnIdleRelease,omitempty"`
+
+	// DiskFullReadOnlyThreshold is the number of disk full errors in a row after which the writes are rejected, 0 disables it.
+	DiskFullReadOnlyThreshold    int     `json:"diskFullReadOnlyThreshold,omitempty"`
+	DiskFullProbeIntervalSeconds Seconds `json:"diskFullProbeIntervalSeconds,omitempty"`
+
 	ExternalConnections map[string]*dbconfigs.DBConfigs `json:"externalConnections,omitempty"`
 
 	SanitizeLogMessages     bool    `json:"-"`
@@ -324,6 +381,7 @@ type TabletConfig struct {
 	EnableTxThrottler           bool     `json:"-"`
 	TxThrottlerConfig           string   `json:"-"`
 	TxThrottlerHealthCheckCells []string `json:"-"`
+	TxThrottlerMaxWaitSeconds   Seconds  `json:"-"`
 
 	EnableLagThrottler bool `json:"-"`
 	EnableTableGC      bool `json:"-"` // can be turned off programmatically by tests
@@ -348,11 +406,19 @@ type ConnPoolConfig struct {
 	PrefillParallelism int     `json:"prefillParallelism,omitempty"`
 	MaxWaiters         int     `json:"maxWaiters,omitempty"`
 	MaxSize            int     `json:"maxSize,omitempty"`
+
+	// LeakThresholdSeconds is how long a connection can be held before it's logged as leaked, 0 disables it.
+	LeakThresholdSeconds Seconds `json:"leakThresholdSeconds,omitempty"`
+	LeakCaptureStack     bool    `json:"leakCaptureStack,omitempty"`
+
+	// BadSettingTTLSeconds is how long a setting that failed to be applied to a connection is remembered, 0 disables it.
+	BadSettingTTLSeconds Seconds `json:"badSettingTTLSeconds,omitempty"`
 }
 
 // OlapConfig contains the config for olap settings.
 type OlapConfig struct {
-	TxTimeoutSeconds Seconds `json:"txTimeoutSeconds,omitempty"`
+	QueryTimeoutSeconds Seconds `json:"queryTimeoutSeconds,omitempty"`
+	TxTimeoutSeconds    Seconds `json:"txTimeoutSeconds,omitempty"`
 }
 
 // OltpConfig contains the config for oltp settings.
@@ -377,6 +443,9 @@ type HealthcheckConfig struct {
 	IntervalSeconds           Seconds `json:"intervalSeconds,omitempty"`
 	DegradedThresholdSeconds  Seconds `json:"degradedThresholdSeconds,omitempty"`
 	UnhealthyThresholdSeconds Seconds `json:"unhealthyThresholdSeconds,omitempty"`
+	// Retries is the number of retries of a health check query failing with a transient connection error.
+	Retries              int     `json:"retries,omitempty"`
+	RetryIntervalSeconds Seconds `json:"retryIntervalSeconds,omitempty"`
 }
 
 // GracePeriodsConfig contains various grace periods.
@@ -384,6 +453,7 @@ type HealthcheckConfig struct {
 type GracePeriodsConfig struct {
 	ShutdownSeconds   Seconds `json:"shutdownSeconds,omitempty"`
 	TransitionSeconds Seconds `json:"transitionSeconds,omitempty"`
+	DrainSeconds      Seconds `json:"drainSeconds,omitempty"`
 }
 
 // ReplicationTrackerConfig contains the config for the replication tracker.
@@ -474,9 +544,32 @@ func (c *TabletConfig) Verify() error {
 	if v := c.HotRowProtection.MaxConcurrency; v <= 0 {
 		return fmt.Errorf("-hot_row_protection_concurrent_transactions must be > 0 (specified value: %v)", v)
 	}
+	for _, override := range c.QueryListOverrides {
+		if _, _, err := ParseQueryListOverride(override); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
+// The query lists a query can be pinned to by QueryListOverrides.
+const (
+	OlapQueryList     = "olap"
+	StatefulQueryList = "oltp-stateful"
+)
+
+// ParseQueryListOverride splits an entry of QueryListOverrides like 'olap:table' into the query list
+// it pins to, and the table or the normalized query pinned.
+func ParseQueryListOverride(override string) (queryList, target string, err error) {
+	queryList, target, ok := strings.Cut(override, ":")
+	queryList, target = strings.TrimSpace(queryList), strings.TrimSpace(target)
+	if !ok || target == "" || (queryList != OlapQueryList && queryList != StatefulQueryList) {
+		return "", "", fmt.Errorf("invalid query list override %q, it should be like '%s:table' or '%s:select * from t where c = :c'",
+			override, OlapQueryList, StatefulQueryList)
+	}
+	return queryList, target, nil
+}
+
 // verifyTransactionLimitConfig checks TransactionLimitConfig for sanity
 func (c *TabletConfig) verifyTransactionLimitConfig() error {
 	actual, dryRun := c.EnableTransactionLimit, c.EnableTransactionLimitDryRun
@@ -528,6 +621,7 @@ var defaultConfig = TabletConfig{
 		IdleTimeoutSeconds: 30 * 60,
 		MaxWaiters:         5000,
 	},
+	MinPoolSize: 1,
 	Olap: OlapConfig{
 		TxTimeoutSeconds: 60,
 	},
@@ -540,6 +634,8 @@ var defaultConfig = TabletConfig{
 		IntervalSeconds:           20,
 		DegradedThresholdSeconds:  30,
 		UnhealthyThresholdSeconds: 7200,
+		Retries:                   2,
+		RetryIntervalSeconds:      0.1,
 	},
 	ReplicationTracker: ReplicationTrackerConfig{
 		Mode:                     Disable,
@@ -572,6 +668,13 @@ var defaultConfig = TabletConfig{
 	MessagePostponeParallelism:              4,
 	DeprecatedCacheResultFields:             true,
 	SignalWhenSchemaChange:                  true,
+	ResultCacheTTLSeconds:                   1,
+	ResultCacheSize:                         1000,
+
+	// MySQL doesn't accept more placeholders in a prepared statement either.
+	MaxBindVarsPerQuery: 65535,
+
+	DiskFullProbeIntervalSeconds: 10,
 
 	EnableTxThrottler:           false,
 	TxThrottlerConfig:           defaultTxThrottlerConfig(),
@@ -39,6 +39,8 @@ const (
 	QuerySourceConsolidator = 1 << iota
 	// QuerySourceMySQL means query result is returned from MySQL.
 	QuerySourceMySQL
+	// QuerySourceResultCache means query result is found in the result cache.
+	QuerySourceResultCache
 )
 
 // LogStats records the stats for a single query
@@ -47,6 +49,7 @@ type LogStats struct {
 	Method               string
 	Target               *querypb.Target
 	PlanType             string
+	TableName            string
 	OriginalSQL          string
 	BindVariables        map[string]*querypb.BindVariable
 	rewrittenSqls        []string
@@ -137,7 +140,7 @@ func (stats *LogStats) FmtQuerySources() string {
 	if stats.QuerySources == 0 {
 		return "none"
 	}
-	sources := make([]string, 2)
+	sources := make([]string, 3)
 	n := 0
 	if stats.QuerySources&QuerySourceMySQL != 0 {
 		sources[n] = "mysql"
@@ -147,6 +150,10 @@ func (stats *LogStats) FmtQuerySources() string {
 		sources[n] = "consolidator"
 		n++
 	}
+	if stats.QuerySources&QuerySourceResultCache != 0 {
+		sources[n] = "result_cache"
+		n++
+	}
 	return strings.Join(sources[:n], ",")
 }
 
@@ -43,10 +43,18 @@ type Stats struct {
 	TableaclAllowed        *stats.CountersWithMultiLabels // Number of allows
 	TableaclDenied         *stats.CountersWithMultiLabels // Number of denials
 	TableaclPseudoDenied   *stats.CountersWithMultiLabels // Number of pseudo denials
+	SlowQueryCounts        *stats.CountersWithSingleLabel // Per table slow query counts
+	JobBatchTimings        *servenv.TimingsWrapper        // Per table non-transactional DML job batch latencies
+	JobBatchAffectedRows   *stats.Histogram               // Distribution of rows affected by non-transactional DML job batches
+	JobsBlocked            *stats.GaugesWithSingleLabel   // Per table non-transactional DML jobs blocked by another job on the same table
+	JobThrottlerNotReady   *stats.Counter                 // Non-transactional DML job batches not throttled because the throttler wasn't ready
+	KeyspaceRewrites       *stats.CountersWithSingleLabel // Results whose fields have the database name instead of the keyspace name
+	DeadlockRetries        *stats.CountersWithSingleLabel // Transactions executed again after a deadlock, by subsystem
 
 	UserActiveReservedCount *stats.CountersWithSingleLabel // Per CallerID active reserved connection counts
 	UserReservedCount       *stats.CountersWithSingleLabel // Per CallerID reserved connection counts
 	UserReservedTimesNs     *stats.CountersWithSingleLabel // Per CallerID reserved connection duration
+	IdleReservedCount       *stats.CountersWithSingleLabel // Reserved connections idle beyond the threshold, by whether they were released
 }
 
 // NewStats instantiates a new set of stats scoped by exporter.
@@ -79,7 +87,7 @@ func NewStats(exporter *servenv.Exporter) *Stats {
 			vtrpcpb.Code_DATA_LOSS.String(),
 			vtrpcpb.Code_CLUSTER_EVENT.String(),
 		),
-		InternalErrors:         exporter.NewCountersWithSingleLabel("InternalErrors", "Internal component errors", "type", "Task", "StrayTransactions", "Panic", "HungQuery", "Schema", "TwopcCommit", "TwopcResurrection", "WatchdogFail", "Messages"),
+		InternalErrors:         exporter.NewCountersWithSingleLabel("InternalErrors", "Internal component errors", "type", "Task", "StrayTransactions", "Panic", "HungQuery", "Schema", "TwopcCommit", "TwopcResurrection", "WatchdogFail", "Messages", "GetPlan", "ComputeRowSerializerKey"),
 		Warnings:               exporter.NewCountersWithSingleLabel("Warnings", "Warnings", "type", "ResultsExceeded"),
 		Unresolved:             exporter.NewGaugesWithSingleLabel("Unresolved", "Unresolved items", "item_type", "Prepares"),
 		UserTableQueryCount:    exporter.NewCountersWithMultiLabels("UserTableQueryCount", "Queries received for each CallerID/table combination", []string{"TableName", "CallerID", "Type"}),
@@ -90,10 +98,18 @@ func NewStats(exporter *servenv.Exporter) *Stats {
 		TableaclAllowed:        exporter.NewCountersWithMultiLabels("TableACLAllowed", "ACL acceptances", []string{"TableName", "TableGroup", "PlanID", "Username"}),
 		TableaclDenied:         exporter.NewCountersWithMultiLabels("TableACLDenied", "ACL denials", []string{"TableName", "TableGroup", "PlanID", "Username"}),
 		TableaclPseudoDenied:   exporter.NewCountersWithMultiLabels("TableACLPseudoDenied", "ACL pseudodenials", []string{"TableName", "TableGroup", "PlanID", "Username"}),
+		SlowQueryCounts:        exporter.NewCountersWithSingleLabel("SlowQueryCounts", "Queries exceeding the slow query threshold for each table", "TableName"),
+		JobBatchTimings:        exporter.NewTimings("JobBatches", "Non-transactional DML job batch execution timings", "TableName"),
+		JobBatchAffectedRows:   exporter.NewHistogram("JobBatchAffectedRows", "Distribution of rows affected by non-transactional DML job batches", []int64{0, 1, 10, 50, 100, 500, 1000, 2000, 5000, 10000}),
+		JobsBlocked:            exporter.NewGaugesWithSingleLabel("JobsBlocked", "Non-transactional DML jobs blocked by another job on the same table", "TableName"),
+		JobThrottlerNotReady:   exporter.NewCounter("JobThrottlerNotReady", "Non-transactional DML job batches executed without being throttled because the throttler wasn't ready"),
+		KeyspaceRewrites:       exporter.NewCountersWithSingleLabel("KeyspaceRewrites", "Results whose fields have the database name instead of the keyspace name, by whether they are rewritten", "Result", "Rewritten", "NotRewritten"),
+		DeadlockRetries:        exporter.NewCountersWithSingleLabel("DeadlockRetries", "Transactions executed again after being rolled back by a deadlock, by subsystem", "Subsystem", "Messages", "DMLJobs"),
 
 		UserActiveReservedCount: exporter.NewCountersWithSingleLabel("UserActiveReservedCount", "active reserved connection for each CallerID", "CallerID"),
 		UserReservedCount:       exporter.NewCountersWithSingleLabel("UserReservedCount", "reserved connection received for each CallerID", "CallerID"),
 		UserReservedTimesNs:     exporter.NewCountersWithSingleLabel("UserReservedTimesNs", "Total reserved connection latency for each CallerID", "CallerID"),
+		IdleReservedCount:       exporter.NewCountersWithSingleLabel("IdleReservedConnections", "Reserved connections not used for longer than the idle threshold, by whether they were released", "Action", "Reported", "Released"),
 	}
 	stats.QPSRates = exporter.NewRates("QPS", stats.QueryTimings, 15*60/5, 5*time.Second)
 	return stats
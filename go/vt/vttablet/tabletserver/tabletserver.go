@@ -59,6 +59,7 @@ import (
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/logutil"
 	"vitess.io/vitess/go/vt/mysqlctl"
+	vtschema "vitess.io/vitess/go/vt/schema"
 	"vitess.io/vitess/go/vt/servenv"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/srvtopo"
@@ -92,6 +93,14 @@ var logPoolFull = logutil.NewThrottledLogger("PoolFull", 1*time.Minute)
 
 var logComputeRowSerializerKey = logutil.NewThrottledLogger("ComputeRowSerializerKey", 1*time.Minute)
 
+// logSlowQuery is for throttling slow query messages in the log.
+// It is a variable so that tests can capture the messages.
+var logSlowQuery = logutil.NewThrottledLogger("SlowQuery", 1*time.Second).Warningf
+
+// logKeyspaceRewrite is for throttling the messages about the database name of result fields
+// being rewritten to the keyspace name.
+var logKeyspaceRewrite = logutil.NewThrottledLogger("KeyspaceRewrite", 1*time.Minute)
+
 // TabletServer implements the RPC interface for the query service.
 // TabletServer is initialized in the following sequence:
 // NewTabletServer->InitDBConfig->SetServingType.
@@ -110,6 +119,8 @@ type TabletServer struct {
 	config                 *tabletenv.TabletConfig
 	stats                  *tabletenv.Stats
 	QueryTimeout           sync2.AtomicDuration
+	OlapQueryTimeout       sync2.AtomicDuration
+	SlowQueryThreshold     sync2.AtomicDuration
 	TerseErrors            bool
 	enableHotRowProtection bool
 	topoServer             *topo.Server
@@ -118,6 +129,7 @@ type TabletServer struct {
 	statelessql        *QueryList
 	statefulql         *QueryList
 	olapql             *QueryList
+	queryListOverrides *queryListOverrides
 	se                 *schema.Engine
 	rt                 *repltracker.ReplTracker
 	vstreamer          *vstreamer.Engine
@@ -142,6 +154,26 @@ type TabletServer struct {
 	// alias is used for identifying this tabletserver in healthcheck responses.
 	alias *topodatapb.TabletAlias
 
+	// readOnlyKeyspaces holds the keyspaces whose writes are rejected by query rules.
+	readOnlyKeyspacesMu sync.Mutex
+	readOnlyKeyspaces   map[string]bool
+
+	// writeDisabledTables holds the fully qualified tables whose writes are rejected by query rules.
+	writeDisabledTablesMu sync.Mutex
+	writeDisabledTables   map[string]bool
+
+	// diskFullErrors counts the disk full errors returned by MySQL in a row,
+	// diskFullReadOnly is set while the writes are rejected because of them,
+	// and diskFullProbeDone is closed to stop the probe of the disk meanwhile.
+	diskFullErrors    sync2.AtomicInt64
+	diskFullMu        sync.Mutex
+	diskFullReadOnly  bool
+	diskFullProbeDone chan struct{}
+
+	// bufferedTables holds the time each table buffered for an online DDL cut-over started to be buffered.
+	bufferedTablesMu sync.Mutex
+	bufferedTables   map[string]time.Time
+
 	// This field is only stored for testing
 	checkMysqlGaugeFunc *stats.GaugeFunc
 }
@@ -172,10 +204,15 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 		stats:                  tabletenv.NewStats(exporter),
 		config:                 config,
 		QueryTimeout:           sync2.NewAtomicDuration(config.Oltp.QueryTimeoutSeconds.Get()),
+		OlapQueryTimeout:       sync2.NewAtomicDuration(config.Olap.QueryTimeoutSeconds.Get()),
+		SlowQueryThreshold:     sync2.NewAtomicDuration(config.SlowQueryThresholdSeconds.Get()),
 		TerseErrors:            config.TerseErrors,
 		enableHotRowProtection: config.HotRowProtection.Mode != tabletenv.Disable,
 		topoServer:             topoServer,
 		alias:                  proto.Clone(alias).(*topodatapb.TabletAlias),
+		readOnlyKeyspaces:      make(map[string]bool),
+		writeDisabledTables:    make(map[string]bool),
+		bufferedTables:         make(map[string]time.Time),
 	}
 
 	tsOnce.Do(func() { srvTopoServer = srvtopo.NewResilientServer(topoServer, "TabletSrvTopo") })
@@ -192,6 +229,7 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 	tsv.statelessql = NewQueryList("oltp-stateless")
 	tsv.statefulql = NewQueryList("oltp-stateful")
 	tsv.olapql = NewQueryList("olap")
+	tsv.queryListOverrides = newQueryListOverrides(config.QueryListOverrides)
 	tsv.hs = newHealthStreamer(tsv, alias, tsv.taskPool)
 	tsv.se = schema.NewEngine(tsv, tsv.taskPool)
 	tsv.rt = repltracker.NewReplTracker(tsv, alias)
@@ -202,6 +240,8 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 	tsv.qe = NewQueryEngine(tsv, tsv.se)
 	tsv.txThrottler = txthrottler.NewTxThrottler(tsv.config, topoServer)
 	tsv.te = NewTxEngine(tsv)
+	// cached results are only invalidated once the writes are visible to other readers.
+	tsv.te.txPool.onCommit = tsv.qe.resultCache.Invalidate
 	tsv.messager = messager.NewEngine(tsv, tsv.se, tsv.vstreamer)
 	tsv.branchWatch = NewBranchWatcher(tsv, tsv.config.DB.DbaWithDB())
 
@@ -209,6 +249,9 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 	tsv.dmlJonController = jobcontroller.NewJobController(tabletTypeFunc, tsv, tsv.lagThrottler, tsv.taskPool)
 	tsv.tableGC = gc.NewTableGC(tsv, topoServer, tsv.lagThrottler)
 	tsv.poolSizeController = NewPoolSizeController(tsv, tsv.taskPool, tsv.te, tsv.qe)
+	tsv.RegisterQueryRuleSource(keyspaceReadOnlyQueryRuleSource)
+	tsv.RegisterQueryRuleSource(tableWritableQueryRuleSource)
+	tsv.RegisterQueryRuleSource(diskFullQueryRuleSource)
 
 	tsv.sm = &stateManager{
 		statelessql:        tsv.statelessql,
@@ -236,7 +279,23 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 
 	tsv.exporter.NewGaugeFunc("TabletState", "Tablet server state", func() int64 { return int64(tsv.sm.State()) })
 	tsv.checkMysqlGaugeFunc = tsv.exporter.NewGaugeFunc("CheckMySQLRunning", "Check MySQL operation currently in progress", tsv.sm.isCheckMySQLRunning)
+	tsv.exporter.NewGaugeFunc("DiskFullReadOnly", "Writes are rejected because the disk of MySQL is full", func() int64 {
+		if tsv.IsDiskFullReadOnly() {
+			return 1
+		}
+		return 0
+	})
 	tsv.exporter.Publish("TabletStateName", stats.StringFunc(tsv.sm.IsServingString))
+	tsv.exporter.Publish("TabletTransitionError", stats.StringFunc(tsv.sm.TransitionError))
+	// TabletTransitionErrorComponent exports the subcomponent that failed the last transition
+	// as a label, so that it can be scraped by Prometheus.
+	tsv.exporter.NewGaugesFuncWithMultiLabels("TabletTransitionErrorComponent", "Subcomponent that failed the last tablet server state transition", []string{"component"}, func() map[string]int64 {
+		component := tsv.sm.TransitionErrorComponent()
+		if component == "" {
+			return map[string]int64{}
+		}
+		return map[string]int64{component: 1}
+	})
 
 	// TabletServerState exports the same information as the above two stats (TabletState / TabletStateName),
 	// but exported with TabletStateName as a label for Prometheus, which doesn't support exporting strings as stat values.
@@ -244,9 +303,23 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 		return map[string]int64{tsv.sm.IsServingString(): 1}
 	})
 	tsv.exporter.NewGaugeDurationFunc("QueryTimeout", "Tablet server query timeout", tsv.QueryTimeout.Get)
+	tsv.exporter.NewGaugeDurationFunc("OlapQueryTimeout", "Tablet server query timeout of streaming queries", tsv.OlapQueryTimeout.Get)
+	tsv.exporter.NewGaugeDurationFunc("SlowQueryThreshold", "Tablet server slow query threshold", tsv.SlowQueryThreshold.Get)
+	tsv.exporter.NewGaugeDurationFunc("HeartbeatLag", "Heartbeat lag of the tablet", func() time.Duration {
+		lag, _, _ := tsv.HeartbeatStatus()
+		return lag
+	})
+	tsv.exporter.NewGaugeFunc("HeartbeatLastTimestamp", "Unix timestamp of the last successful heartbeat write or read, 0 if there was none", func() int64 {
+		_, lastHeartbeat, _ := tsv.HeartbeatStatus()
+		if lastHeartbeat.IsZero() {
+			return 0
+		}
+		return lastHeartbeat.Unix()
+	})
 
 	tsv.registerHealthzHealthHandler()
 	tsv.registerDebugHealthHandler()
+	tsv.registerDebugCheckMySQLHandler()
 	tsv.registerQueryzHandler()
 	tsv.registerQueryListHandlers([]*QueryList{tsv.statelessql, tsv.statefulql, tsv.olapql})
 	tsv.registerTwopczHandler()
@@ -254,6 +327,12 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 	tsv.registerThrottlerHandlers()
 	tsv.registerDebugEnvHandler()
 	tsv.registerDebugConfigHandler()
+	tsv.registerKeyspaceReadOnlyHandler()
+	tsv.registerTableWritableHandler()
+	tsv.registerTableACLHandler()
+	tsv.registerDebugStatusHandler()
+	tsv.registerBufferedTablesHandler()
+	tsv.registerPreparedTransactionsHandler()
 
 	return tsv
 }
@@ -266,15 +345,19 @@ func NewTabletServer(name string, config *tabletenv.TabletConfig, topoServer *to
 //     for all new queries (see Execute() function and call to GetPlan())
 //  2. affecting already existing rules: a Rule has a concext.WithCancel, that is cancelled by onlineDDLExecutor
 func (tsv *TabletServer) onlineDDLExecutorToggleTableBuffer(bufferingCtx context.Context, tableName string, bufferQueries bool) {
-	queryRuleSource := fmt.Sprintf("onlineddl/%s", tableName)
+	queryRuleSource := onlineDDLQueryRuleSource(tableName)
 
+	tsv.bufferedTablesMu.Lock()
+	defer tsv.bufferedTablesMu.Unlock()
 	if bufferQueries {
 		tsv.RegisterQueryRuleSource(queryRuleSource)
 		bufferRules := rules.New()
 		bufferRules.Add(rules.NewActiveBufferedTableQueryRule(bufferingCtx, tableName, "buffered for cut-over"))
 		tsv.SetQueryRules(queryRuleSource, bufferRules)
+		tsv.bufferedTables[tableName] = time.Now()
 	} else {
 		tsv.UnRegisterQueryRuleSource(queryRuleSource) // new rules will not have buffering. Existing rules will be affected by bufferingContext.Done()
+		delete(tsv.bufferedTables, tableName)
 	}
 }
 
@@ -403,25 +486,34 @@ func (tsv *TabletServer) StartService(target *querypb.Target, dbcfgs *dbconfigs.
 // Under normal circumstances, SetServingType should be called.
 func (tsv *TabletServer) StopService() {
 	tsv.sm.StopService()
+	tsv.stopDiskFullProbe()
 }
 
 // IsHealthy returns nil for non-serving types or if the query service is healthy (able to
 // connect to the database and serving traffic), or an error explaining
 // the unhealthiness otherwise.
 func (tsv *TabletServer) IsHealthy() error {
-	if topoproto.IsServingType(tsv.sm.Target().TabletType) {
+	target := tsv.sm.Target()
+	if !topoproto.IsServingType(target.TabletType) {
+		return nil
+	}
+	// A transient connection error is retried, so that a momentary blip doesn't flap the health check.
+	for attempt := 0; ; attempt++ {
 		_, err := tsv.Execute(
 			tabletenv.LocalContext(),
-			nil,
+			target,
 			"/* health */ select 1 from dual",
 			nil,
 			0,
 			0,
 			nil,
 		)
-		return err
+		if err == nil || attempt >= tsv.config.Healthcheck.Retries || vterrors.Code(err) != vtrpcpb.Code_UNAVAILABLE {
+			return err
+		}
+		log.Warningf("Health check query failed with a transient error, retrying: %v", err)
+		time.Sleep(tsv.config.Healthcheck.RetryIntervalSeconds.Get())
 	}
-	return nil
 }
 
 // ReloadSchema reloads the schema.
@@ -506,7 +598,7 @@ func (tsv *TabletServer) begin(ctx context.Context, target *querypb.Target, save
 		target, options, false, /* allowOnShutdown */
 		func(ctx context.Context, logStats *tabletenv.LogStats) error {
 			startTime := time.Now()
-			if tsv.txThrottler.Throttle() {
+			if tsv.txThrottler.ThrottleWithWait(ctx) {
 				return vterrors.Errorf(vtrpcpb.Code_RESOURCE_EXHAUSTED, "Transaction throttled")
 			}
 			var connSetting *pools.Setting
@@ -758,7 +850,7 @@ func (tsv *TabletServer) Execute(ctx context.Context, target *querypb.Target, sq
 
 func (tsv *TabletServer) execute(ctx context.Context, target *querypb.Target, sql string, bindVariables map[string]*querypb.BindVariable, transactionID int64, reservedID int64, settings []string, options *querypb.ExecuteOptions) (result *sqltypes.Result, err error) {
 	allowOnShutdown := false
-	timeout := tsv.QueryTimeout.Get()
+	timeout := tsv.queryTimeoutForWorkload(querypb.ExecuteOptions_OLTP)
 	if transactionID != 0 {
 		allowOnShutdown = true
 		// Execute calls happen for OLTP only, so we can directly fetch the
@@ -773,10 +865,16 @@ func (tsv *TabletServer) execute(ctx context.Context, target *querypb.Target, sq
 		"Execute", sql, bindVariables,
 		target, options, allowOnShutdown,
 		func(ctx context.Context, logStats *tabletenv.LogStats) error {
+			if err := tsv.checkBindVarCount(bindVariables); err != nil {
+				return err
+			}
 			if bindVariables == nil {
 				bindVariables = make(map[string]*querypb.BindVariable)
 			}
 			query, comments := sqlparser.SplitMarginComments(sql)
+			if options.GetForceFreshPlan() {
+				tsv.qe.forgetPlan(target.Keyspace, query)
+			}
 			plan, err := tsv.qe.GetPlan(ctx, logStats, target.Keyspace, query, skipQueryPlanCache(options))
 			if err != nil {
 				return err
@@ -813,20 +911,26 @@ func (tsv *TabletServer) execute(ctx context.Context, target *querypb.Target, sq
 			if err != nil {
 				return err
 			}
+			tsv.recordWriteSucceeded(plan.PlanID)
 			result = result.StripMetadata(sqltypes.IncludeFieldsOrDefault(options))
+			if options.GetReportPlanCacheHit() {
+				// The result may be shared, e.g. with the result cache, so it's copied
+				// before being marked.
+				result = result.ShallowCopy()
+				result.PlanCacheHit = logStats.CachedPlan
+			}
+			if options.GetReportRowsExamined() {
+				result = result.ShallowCopy()
+				result.RowsExamined = qre.rowsExamined
+			}
+			if options.GetReportThreadId() {
+				result = result.ShallowCopy()
+				result.ThreadID = qre.threadID
+			}
 
 			// Change database name in mysql output to the keyspace name
 			if tsv.sm.target.Keyspace != tsv.config.DB.DBName && sqltypes.IncludeFieldsOrDefault(options) == querypb.ExecuteOptions_ALL {
-				switch qre.plan.PlanID {
-				case planbuilder.PlanSelect, planbuilder.PlanSelectImpossible:
-					dbName := tsv.config.DB.DBName
-					ksName := tsv.sm.target.Keyspace
-					for _, f := range result.Fields {
-						if f.Database == dbName {
-							f.Database = ksName
-						}
-					}
-				}
+				tsv.rewriteFieldsDatabase(result, qre.plan.PlanID)
 			}
 			return nil
 		},
@@ -834,6 +938,36 @@ func (tsv *TabletServer) execute(ctx context.Context, target *querypb.Target, sq
 	return result, err
 }
 
+// rewriteFieldsDatabase changes the database of the result fields from the MySQL database name to the
+// keyspace name, for the select plans. Rewrites and the mismatches left for the other plans are counted
+// and logged, since they are a sign of a keyspace mapped to a database with a different name.
+func (tsv *TabletServer) rewriteFieldsDatabase(result *sqltypes.Result, planID planbuilder.PlanType) {
+	dbName := tsv.config.DB.DBName
+	ksName := tsv.sm.target.Keyspace
+	mismatches := 0
+	for _, f := range result.Fields {
+		if f.Database == dbName {
+			mismatches++
+		}
+	}
+	if mismatches == 0 {
+		return
+	}
+	switch planID {
+	case planbuilder.PlanSelect, planbuilder.PlanSelectImpossible:
+		for _, f := range result.Fields {
+			if f.Database == dbName {
+				f.Database = ksName
+			}
+		}
+		tsv.stats.KeyspaceRewrites.Add("Rewritten", 1)
+		logKeyspaceRewrite.Infof("rewrote the database of %d result fields from %s to the keyspace name %s", mismatches, dbName, ksName)
+	default:
+		tsv.stats.KeyspaceRewrites.Add("NotRewritten", 1)
+		logKeyspaceRewrite.Warningf("%d result fields of a %s plan have the database %s instead of the keyspace name %s", mismatches, planID.String(), dbName, ksName)
+	}
+}
+
 func (tsv *TabletServer) buildConnSettingForUserKeyspace(ctx context.Context, settings []string, keyspaceName string, options *querypb.ExecuteOptions) (*pools.Setting, error) {
 	var connSetting *pools.Setting
 	var err error
@@ -869,6 +1003,40 @@ func (tsv *TabletServer) buildConnSettingForUserKeyspace(ctx context.Context, se
 	return connSetting, nil
 }
 
+// queryTimeoutForWorkload returns the default query timeout for the given
+// workload type. DBA queries have no timeout.
+func (tsv *TabletServer) queryTimeoutForWorkload(workload querypb.ExecuteOptions_Workload) time.Duration {
+	switch workload {
+	case querypb.ExecuteOptions_DBA:
+		return 0
+	case querypb.ExecuteOptions_OLAP:
+		return tsv.OlapQueryTimeout.Get()
+	default:
+		return tsv.QueryTimeout.Get()
+	}
+}
+
+// checkBindVarCount rejects the queries with more bind variables than MaxBindVarsPerQuery,
+// every value of a tuple counting as a variable, before they're planned.
+func (tsv *TabletServer) checkBindVarCount(bindVariables map[string]*querypb.BindVariable) error {
+	maxBindVars := tsv.config.MaxBindVarsPerQuery
+	if maxBindVars <= 0 || len(bindVariables) == 0 {
+		return nil
+	}
+	count := 0
+	for _, bv := range bindVariables {
+		if bv.GetType() == querypb.Type_TUPLE {
+			count += len(bv.Values)
+		} else {
+			count++
+		}
+	}
+	if count > maxBindVars {
+		return vterrors.Errorf(vtrpcpb.Code_INVALID_ARGUMENT, "the query has %d bind variables, more than the limit of %d, insert the values into a temporary table to join with or split the query into batches", count, maxBindVars)
+	}
+	return nil
+}
+
 // smallerTimeout returns the smaller of the two timeouts.
 // 0 is treated as infinity.
 func smallerTimeout(t1, t2 time.Duration) time.Duration {
@@ -896,16 +1064,32 @@ func (tsv *TabletServer) StreamExecute(ctx context.Context, target *querypb.Targ
 }
 
 func (tsv *TabletServer) streamExecute(ctx context.Context, target *querypb.Target, sql string, bindVariables map[string]*querypb.BindVariable, transactionID int64, reservedID int64, settings []string, options *querypb.ExecuteOptions, callback func(*sqltypes.Result) error) error {
+	// The streams are limited independently of the stream pool, since the consolidated streams
+	// and the ones executed in a transaction don't take a connection from it.
+	if !tsv.qe.acquireStream() {
+		return vterrors.Errorf(vtrpcpb.Code_RESOURCE_EXHAUSTED, "too many stream queries executed at the same time, the limit is %d", tsv.qe.streamMaxConcurrency.Get())
+	}
+	defer tsv.qe.releaseStream()
+
 	allowOnShutdown := false
-	var timeout time.Duration
+	// StreamExecute calls happen for OLAP only, so we can directly fetch the
+	// OLAP timeouts.
+	timeout := tsv.queryTimeoutForWorkload(querypb.ExecuteOptions_OLAP)
 	if transactionID != 0 {
 		allowOnShutdown = true
-		// Use the transaction timeout. StreamExecute calls happen for OLAP only,
-		// so we can directly fetch the OLAP TX timeout.
-		timeout = tsv.config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP)
+		txTimeout := tsv.config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP)
+		// Use the smaller of the two values (0 means infinity).
+		timeout = smallerTimeout(timeout, txTimeout)
 	}
 
-	return tsv.execRequest(
+	// If asked, count the rows delivered so that the client can be told
+	// how far the stream got when it fails.
+	var progress *streamProgress
+	if options.GetReportStreamFailureMarker() {
+		progress = newStreamProgress(callback)
+	}
+
+	err := tsv.execRequest(
 		ctx, timeout,
 		"StreamExecute", sql, bindVariables,
 		target, options, allowOnShutdown,
@@ -945,9 +1129,19 @@ func (tsv *TabletServer) streamExecute(ctx context.Context, target *querypb.Targ
 				tsv:            tsv,
 				setting:        connSetting,
 			}
+			if progress != nil {
+				progress.table = plan.Table
+				return qre.Stream(progress.send)
+			}
 			return qre.Stream(callback)
 		},
 	)
+	if err != nil && progress != nil {
+		if markerErr := progress.sendMarker(); markerErr != nil {
+			log.Warningf("Failed to send the stream failure marker: %v", markerErr)
+		}
+	}
+	return err
 }
 
 // BeginExecute combines Begin and Execute.
@@ -1043,6 +1237,7 @@ func (tsv *TabletServer) computeTxSerializerKey(ctx context.Context, logStats *t
 	plan, err := tsv.qe.GetPlan(ctx, logStats, dbName, sql, false)
 	if err != nil {
 		logComputeRowSerializerKey.Errorf("failed to get plan for query: %v err: %v", sql, err)
+		tsv.stats.InternalErrors.Add("GetPlan", 1)
 		return "", ""
 	}
 
@@ -1063,6 +1258,7 @@ func (tsv *TabletServer) computeTxSerializerKey(ctx context.Context, logStats *t
 	where, err := plan.WhereClause.GenerateQuery(bindVariables, nil)
 	if err != nil {
 		logComputeRowSerializerKey.Errorf("failed to substitute bind vars in where clause: %v query: %v bind vars: %v", err, sql, bindVariables)
+		tsv.stats.InternalErrors.Add("ComputeRowSerializerKey", 1)
 		return "", ""
 	}
 
@@ -1116,6 +1312,54 @@ func (tsv *TabletServer) MessageAck(ctx context.Context, target *querypb.Target,
 	return count, nil
 }
 
+// MessageAckMulti acks the lists of messages for several message tables,
+// keyed by table name, in a single transaction. It returns the number of
+// messages successfully acked for each table.
+func (tsv *TabletServer) MessageAckMulti(ctx context.Context, target *querypb.Target, ids map[string][]*querypb.Value) (counts map[string]int64, err error) {
+	// Sort the table names so that concurrent calls lock the rows in the same order.
+	names := make([]string, 0, len(ids))
+	for name, tableIDs := range ids {
+		if len(tableIDs) == 0 {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	queryGenerators := make([]func() (string, map[string]*querypb.BindVariable, error), 0, len(names))
+	for _, name := range names {
+		querygen, err := tsv.messager.GetGenerator(name)
+		if err != nil {
+			return nil, err
+		}
+		sids := make([]string, 0, len(ids[name]))
+		for _, val := range ids[name] {
+			sids = append(sids, sqltypes.ProtoToValue(val).ToString())
+		}
+		queryGenerators = append(queryGenerators, func() (string, map[string]*querypb.BindVariable, error) {
+			query, bv := querygen.GenerateAckQuery(sids)
+			return query, bv, nil
+		})
+	}
+
+	counts = make(map[string]int64, len(ids))
+	for name := range ids {
+		counts[name] = 0
+	}
+	if len(queryGenerators) == 0 {
+		return counts, nil
+	}
+	tableCounts, err := tsv.execDMLs(ctx, target, queryGenerators...)
+	if err != nil {
+		return nil, err
+	}
+	for i, name := range names {
+		counts[name] = tableCounts[i]
+		messager.MessageStats.Add([]string{name, "Acked"}, tableCounts[i])
+	}
+	return counts, nil
+}
+
 // PostponeMessages postpones the list of messages for a given message table.
 // It returns the number of messages successfully postponed.
 func (tsv *TabletServer) PostponeMessages(ctx context.Context, target *querypb.Target, querygen messager.QueryGenerator, ids []string) (count int64, err error) {
@@ -1135,20 +1379,50 @@ func (tsv *TabletServer) PurgeMessages(ctx context.Context, target *querypb.Targ
 }
 
 func (tsv *TabletServer) execDML(ctx context.Context, target *querypb.Target, queryGenerator func() (string, map[string]*querypb.BindVariable, error)) (count int64, err error) {
-	if err = tsv.sm.StartRequest(ctx, target, false /* allowOnShutdown */); err != nil {
+	counts, err := tsv.execDMLs(ctx, target, queryGenerator)
+	// counts is empty without an error if execDMLs recovered from a panic.
+	if err != nil || len(counts) == 0 {
 		return 0, err
 	}
+	return counts[0], nil
+}
+
+// execDMLs executes the generated queries in a single transaction and
+// returns the number of rows affected by each of them.
+// The transaction is executed again, up to MessageDeadlockRetries times, if it's rolled back by a deadlock.
+func (tsv *TabletServer) execDMLs(ctx context.Context, target *querypb.Target, queryGenerators ...func() (string, map[string]*querypb.BindVariable, error)) (counts []int64, err error) {
+	if err = tsv.sm.StartRequest(ctx, target, false /* allowOnShutdown */); err != nil {
+		return nil, err
+	}
 	defer tsv.sm.EndRequest()
 	defer tsv.handlePanicAndSendLogStats("ack", nil, nil)
 
-	query, bv, err := queryGenerator()
-	if err != nil {
-		return 0, err
+	queries := make([]string, 0, len(queryGenerators))
+	bvs := make([]map[string]*querypb.BindVariable, 0, len(queryGenerators))
+	for _, queryGenerator := range queryGenerators {
+		query, bv, err := queryGenerator()
+		if err != nil {
+			return nil, err
+		}
+		queries = append(queries, query)
+		bvs = append(bvs, bv)
 	}
 
+	for retries := 0; ; retries++ {
+		counts, err = tsv.execDMLsInTransaction(ctx, target, queries, bvs)
+		if err == nil || retries >= tsv.config.MessageDeadlockRetries || !isDeadlock(err) {
+			return counts, err
+		}
+		log.Infof("Deadlock detected executing %v: %v; will retry", queries, err)
+		tsv.stats.DeadlockRetries.Add("Messages", 1)
+	}
+}
+
+// execDMLsInTransaction executes the queries in a single transaction.
+func (tsv *TabletServer) execDMLsInTransaction(ctx context.Context, target *querypb.Target, queries []string, bvs []map[string]*querypb.BindVariable) (counts []int64, err error) {
 	state, err := tsv.Begin(ctx, target, nil)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
 	// If transaction was not committed by the end, it means
 	// that there was an error, roll it back.
@@ -1157,16 +1431,26 @@ func (tsv *TabletServer) execDML(ctx context.Context, target *querypb.Target, qu
 			tsv.Rollback(ctx, target, state.TransactionID)
 		}
 	}()
-	qr, err := tsv.Execute(ctx, target, query, bv, state.TransactionID, 0, nil)
-	if err != nil {
-		return 0, err
+	counts = make([]int64, 0, len(queries))
+	for i, query := range queries {
+		qr, err := tsv.Execute(ctx, target, query, bvs[i], state.TransactionID, 0, nil)
+		if err != nil {
+			return nil, err
+		}
+		counts = append(counts, int64(qr.RowsAffected))
 	}
 	if _, _, err = tsv.Commit(ctx, target, state.TransactionID); err != nil {
 		state.TransactionID = 0
-		return 0, err
+		return nil, err
 	}
 	state.TransactionID = 0
-	return int64(qr.RowsAffected), nil
+	return counts, nil
+}
+
+// isDeadlock returns true if err is a deadlock, which rolled back its transaction.
+func isDeadlock(err error) bool {
+	sqlErr, ok := mysql.NewSQLErrorFromError(err).(*mysql.SQLError)
+	return ok && sqlErr.Number() == mysql.ERLockDeadlock
 }
 
 // VStream streams VReplication events.
@@ -1213,6 +1497,30 @@ func (tsv *TabletServer) VStreamResults(ctx context.Context, target *querypb.Tar
 	return tsv.vstreamer.StreamResults(ctx, query, send)
 }
 
+// ListMigrations lists the online DDL migrations whose status is filter, with their progress.
+// An empty filter lists the migrations in flight.
+func (tsv *TabletServer) ListMigrations(ctx context.Context, target *querypb.Target, filter string) ([]*onlineddl.MigrationProgress, error) {
+	if err := tsv.sm.VerifyTarget(ctx, target); err != nil {
+		return nil, err
+	}
+	return tsv.onlineDDLExecutor.ListMigrations(ctx, vtschema.OnlineDDLStatus(filter))
+}
+
+// CancelQuery kills the connection of the in-flight query whose id is queryID, like /livequeryz/terminate.
+// The id of a query is the id of its MySQL connection, as listed by /livequeryz.
+// It returns false if no query with this id is in flight.
+func (tsv *TabletServer) CancelQuery(ctx context.Context, target *querypb.Target, queryID int64) (bool, error) {
+	if err := tsv.sm.VerifyTarget(ctx, target); err != nil {
+		return false, err
+	}
+	for _, ql := range []*QueryList{tsv.statelessql, tsv.statefulql, tsv.olapql} {
+		if ql.Terminate(queryID) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
 // ReserveBeginExecute implements the QueryService interface
 func (tsv *TabletServer) ReserveBeginExecute(ctx context.Context, target *querypb.Target, preQueries []string, postBeginQueries []string, sql string, bindVariables map[string]*querypb.BindVariable, options *querypb.ExecuteOptions) (state queryservice.ReservedTransactionState, result *sqltypes.Result, err error) {
 	if tsv.config.EnableSettingsPool {
@@ -1510,11 +1818,24 @@ func (tsv *TabletServer) SetFailPoint(_ context.Context, command string, key str
 }
 
 func (tsv *TabletServer) SubmitDMLJob(_ context.Context, command, sql, jobUUID, tableSchema, timePeriodStart, timePeriodEnd, timePeriodTimeZone string, timeGapInMs, batchSize int64, postponeLaunch bool, failPolicy, throttleDuration, throttleRatio string) (*sqltypes.Result, error) {
-	return tsv.dmlJonController.HandleRequest(command, sql, jobUUID, tableSchema, timePeriodStart, timePeriodEnd, timePeriodTimeZone, throttleDuration, throttleRatio, timeGapInMs, batchSize, postponeLaunch, failPolicy, false)
+	return tsv.dmlJonController.HandleRequest(command, jobcontroller.JobRequest{
+		SQL:                       sql,
+		JobUUID:                   jobUUID,
+		TableSchema:               tableSchema,
+		RunningTimePeriodStart:    timePeriodStart,
+		RunningTimePeriodEnd:      timePeriodEnd,
+		RunningTimePeriodTimeZone: timePeriodTimeZone,
+		ThrottleDuration:          throttleDuration,
+		ThrottleRatio:             throttleRatio,
+		BatchIntervalInMs:         timeGapInMs,
+		BatchSize:                 batchSize,
+		PostponeLaunch:            postponeLaunch,
+		FailPolicy:                failPolicy,
+	})
 }
 
 func (tsv *TabletServer) ShowDMLJob(_ context.Context, uuid string, showDetails bool) (*sqltypes.Result, error) {
-	return tsv.dmlJonController.HandleRequest(jobcontroller.ShowJob, "", uuid, "", "", "", "", "", "", 0, 0, false, "", showDetails)
+	return tsv.dmlJonController.HandleRequest(jobcontroller.ShowJob, jobcontroller.JobRequest{JobUUID: uuid, ShowDetails: showDetails})
 }
 
 // execRequest performs verifications, sets up the necessary environments
@@ -1591,7 +1912,37 @@ func (tsv *TabletServer) handlePanicAndSendLogStats(
 	// - Begin / Commit in autocommit mode
 	if logStats != nil && logStats.Method != "" {
 		logStats.Send()
+		tsv.recordSlowQuery(logStats)
+	}
+}
+
+// recordSlowQuery logs the query described by logStats if it took longer than
+// the slow query threshold. Unlike the query log, it is not subject to any
+// sampling or filtering, so every slow query is counted. Bind variables are
+// never logged, and the literals of the query are redacted.
+func (tsv *TabletServer) recordSlowQuery(logStats *tabletenv.LogStats) {
+	threshold := tsv.SlowQueryThreshold.Get()
+	if threshold <= 0 {
+		return
+	}
+	totalTime := logStats.TotalTime()
+	if totalTime <= threshold {
+		return
+	}
+	// Queries without a table in their plan, e.g. joins or queries that were
+	// not planned at all, are counted together.
+	tableName := logStats.TableName
+	if tableName == "" {
+		tableName = "NoTable"
+	}
+	tsv.stats.SlowQueryCounts.Add(tableName, 1)
+	piiSafeSQL, err := sqlparser.RedactSQLQuery(logStats.OriginalSQL)
+	if err != nil {
+		piiSafeSQL = logStats.PlanType
 	}
+	logSlowQuery("Slow query: Sql: %q, Table: %s, PlanType: %s, TotalTime: %v, MysqlTime: %v, RowsAffected: %d, RowsReturned: %d, ImmediateCaller: %s",
+		sqlparser.TruncateForLog(piiSafeSQL), tableName, logStats.PlanType, totalTime,
+		logStats.MysqlResponseTime, logStats.RowsAffected, len(logStats.Rows), logStats.ImmediateCaller())
 }
 
 func (tsv *TabletServer) convertAndLogError(ctx context.Context, sql string, bindVariables map[string]*querypb.BindVariable, err error, logStats *tabletenv.LogStats) error {
@@ -1599,6 +1950,7 @@ func (tsv *TabletServer) convertAndLogError(ctx context.Context, sql string, bin
 		return nil
 	}
 
+	tsv.recordDiskFullError(err)
 	errCode := convertErrorCode(err)
 	tsv.stats.ErrorCounters.Add(errCode.String(), 1)
 
@@ -1855,6 +2207,30 @@ func (tsv *TabletServer) registerDebugHealthHandler() {
 	})
 }
 
+// registerDebugCheckMySQLHandler registers a handler to recheck the
+// connectivity to MySQL on demand, e.g. after MySQL was fixed.
+func (tsv *TabletServer) registerDebugCheckMySQLHandler() {
+	tsv.exporter.HandleFunc("/debug/checkmysql", tsv.debugCheckMySQLHandler)
+}
+
+func (tsv *TabletServer) debugCheckMySQLHandler(w http.ResponseWriter, r *http.Request) {
+	if err := acl.CheckAccessHTTP(r, acl.ADMIN); err != nil {
+		acl.SendError(w, err)
+		return
+	}
+	// checkMySQL is rate-limited, Triggered is false if a check
+	// is already running or has just finished.
+	triggered := tsv.sm.checkMySQL()
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(struct {
+		Triggered bool
+		State     string
+	}{
+		Triggered: triggered,
+		State:     tsv.sm.IsServingString(),
+	})
+}
+
 func (tsv *TabletServer) registerQueryzHandler() {
 	tsv.exporter.HandleFunc("/queryz", func(w http.ResponseWriter, r *http.Request) {
 		queryzHandler(tsv.qe, w, r)
@@ -2007,6 +2383,12 @@ func (tsv *TabletServer) registerDebugConfigHandler() {
 	})
 }
 
+// HeartbeatStatus returns the heartbeat lag and the time of the last successful
+// heartbeat write (on a primary) or read (on a replica), zero if there was none.
+func (tsv *TabletServer) HeartbeatStatus() (time.Duration, time.Time, error) {
+	return tsv.sm.rt.HeartbeatStatus()
+}
+
 // EnableHeartbeat forces heartbeat to be on or off.
 // Only to be used for testing.
 func (tsv *TabletServer) EnableHeartbeat(enabled bool) {
@@ -2034,7 +2416,7 @@ func (tsv *TabletServer) EnableHistorian(enabled bool) {
 
 // SetPoolSize changes the pool size to the specified value.
 func (tsv *TabletServer) SetPoolSize(val int) {
-	if val <= 0 {
+	if !tsv.checkPoolSize("pool size", val) {
 		return
 	}
 	tsv.qe.conns.SetCapacity(val)
@@ -2047,6 +2429,9 @@ func (tsv *TabletServer) PoolSize() int {
 
 // SetStreamPoolSize changes the pool size to the specified value.
 func (tsv *TabletServer) SetStreamPoolSize(val int) {
+	if !tsv.checkPoolSize("stream pool size", val) {
+		return
+	}
 	tsv.qe.streamConns.SetCapacity(val)
 }
 
@@ -2062,9 +2447,22 @@ func (tsv *TabletServer) StreamPoolSize() int {
 
 // SetTxPoolSize changes the tx pool size to the specified value.
 func (tsv *TabletServer) SetTxPoolSize(val int) {
+	if !tsv.checkPoolSize("tx pool size", val) {
+		return
+	}
 	tsv.te.txPool.scp.conns.SetCapacity(val)
 }
 
+// checkPoolSize returns false and logs an error if val is below the minimum pool size,
+// a pool that small could starve the tablet of connections.
+func (tsv *TabletServer) checkPoolSize(name string, val int) bool {
+	if minPoolSize := tsv.config.MinPoolSize; val < minPoolSize {
+		log.Errorf("Rejecting %s %d: it's below the minimum pool size %d set by --queryserver-config-min-pool-size", name, val, minPoolSize)
+		return false
+	}
+	return true
+}
+
 func (tsv *TabletServer) SetTaskPoolSize(val int) {
 	tsv.taskPool.SetCapacity(val)
 }
@@ -2094,6 +2492,17 @@ func (tsv *TabletServer) QueryPlanCacheWait() {
 	tsv.qe.plans.Wait()
 }
 
+// SetStreamMaxConcurrency changes the maximum number of stream queries executed at the same time,
+// 0 means no limit. The streams already executing are not interrupted.
+func (tsv *TabletServer) SetStreamMaxConcurrency(val int) {
+	tsv.qe.streamMaxConcurrency.Set(int64(val))
+}
+
+// StreamMaxConcurrency returns the maximum number of stream queries executed at the same time.
+func (tsv *TabletServer) StreamMaxConcurrency() int {
+	return int(tsv.qe.streamMaxConcurrency.Get())
+}
+
 // SetMaxResultSize changes the max result size to the specified value.
 func (tsv *TabletServer) SetMaxResultSize(val int) {
 	tsv.qe.maxResultSize.Set(int64(val))
@@ -2124,6 +2533,23 @@ func (tsv *TabletServer) ThrottleMetricThreshold() float64 {
 	return tsv.lagThrottler.MetricsThreshold.Get()
 }
 
+// SetThrottleMetric changes the query of the metric evaluated by the throttler, which is
+// the replication lag by default. An empty query restores the replication lag.
+func (tsv *TabletServer) SetThrottleMetric(query string) error {
+	return tsv.lagThrottler.SetMetricsQuery(query)
+}
+
+// ThrottleMetric returns the query of the metric evaluated by the throttler
+func (tsv *TabletServer) ThrottleMetric() string {
+	return tsv.lagThrottler.GetMetricsQuery()
+}
+
+// ExemptAppFromThrottle lets the given app pass throttler checks for the duration d,
+// after which the exemption expires and the app is throttled as usual again.
+func (tsv *TabletServer) ExemptAppFromThrottle(app string, d time.Duration) {
+	tsv.lagThrottler.ExemptApp(app, time.Now().Add(d))
+}
+
 // SetPassthroughDMLs changes the setting to pass through all DMLs
 // It should only be used for testing
 func (tsv *TabletServer) SetPassthroughDMLs(val bool) {
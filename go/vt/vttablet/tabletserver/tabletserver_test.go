@@ -23,6 +23,7 @@ package tabletserver
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -52,15 +53,18 @@ import (
 
 	"vitess.io/vitess/go/mysql"
 	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/dbconfigs"
 	"vitess.io/vitess/go/vt/log"
 	"vitess.io/vitess/go/vt/sqlparser"
 	"vitess.io/vitess/go/vt/tableacl"
 	"vitess.io/vitess/go/vt/tableacl/simpleacl"
 	"vitess.io/vitess/go/vt/topo/memorytopo"
 	"vitess.io/vitess/go/vt/vterrors"
+	"vitess.io/vitess/go/vt/vttablet/onlineddl"
 	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
 
 	querypb "vitess.io/vitess/go/vt/proto/query"
+	tableaclpb "vitess.io/vitess/go/vt/proto/tableacl"
 	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
 	vtrpcpb "vitess.io/vitess/go/vt/proto/vtrpc"
 )
@@ -220,13 +224,13 @@ func TestTabletServerRedoLogIsKeptBetweenRestarts(t *testing.T) {
 			sqltypes.NewVarBinary("dtid0"),
 			sqltypes.NewInt64(RedoStatePrepared),
 			sqltypes.NewVarBinary(""),
-			sqltypes.NewVarBinary("update test_table set `name` = 2 where pk = 1 limit 10001"),
+			sqltypes.NewVarBinary("update test_table set `name` = 2 where pk = 1 limit 100001"),
 		}},
 	})
 	turnOnTxEngine()
 	assert.EqualValues(t, 1, len(tsv.te.preparedPool.conns), "len(tsv.te.preparedPool.conns)")
 	got := tsv.te.preparedPool.conns["dtid0"].TxProperties().Queries
-	want := []string{"update test_table set `name` = 2 where pk = 1 limit 10001"}
+	want := []string{"update test_table set `name` = 2 where pk = 1 limit 100001"}
 	utils.MustMatch(t, want, got, "Prepared queries")
 	turnOffTxEngine()
 	assert.Empty(t, tsv.te.preparedPool.conns, "tsv.te.preparedPool.conns")
@@ -249,7 +253,7 @@ func TestTabletServerRedoLogIsKeptBetweenRestarts(t *testing.T) {
 			sqltypes.NewVarBinary("a:b:10"),
 			sqltypes.NewInt64(RedoStatePrepared),
 			sqltypes.NewVarBinary(""),
-			sqltypes.NewVarBinary("update test_table set `name` = 2 where pk = 1 limit 10001"),
+			sqltypes.NewVarBinary("update test_table set `name` = 2 where pk = 1 limit 100001"),
 		}, {
 			sqltypes.NewVarBinary("a:b:20"),
 			sqltypes.NewInt64(RedoStateFailed),
@@ -260,7 +264,7 @@ func TestTabletServerRedoLogIsKeptBetweenRestarts(t *testing.T) {
 	turnOnTxEngine()
 	assert.EqualValues(t, 1, len(tsv.te.preparedPool.conns), "len(tsv.te.preparedPool.conns)")
 	got = tsv.te.preparedPool.conns["a:b:10"].TxProperties().Queries
-	want = []string{"update test_table set `name` = 2 where pk = 1 limit 10001"}
+	want = []string{"update test_table set `name` = 2 where pk = 1 limit 100001"}
 	utils.MustMatch(t, want, got, "Prepared queries")
 	wantFailed := map[string]error{"a:b:20": errPrepFailed}
 	if !reflect.DeepEqual(tsv.te.preparedPool.reserved, wantFailed) {
@@ -524,6 +528,39 @@ func TestTabletServerPrepare(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestTabletServerOutstandingPreparedTransactions(t *testing.T) {
+	// Reuse code from tx_executor_test.
+	_, tsv, db := newTestTxExecutor(t)
+	defer tsv.StopService()
+	defer db.Close()
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	assert.Empty(t, tsv.OutstandingPreparedTransactions(ctx))
+
+	state, err := tsv.Begin(ctx, &target, nil)
+	require.NoError(t, err)
+	_, err = tsv.Execute(ctx, &target, "update test_table set `name` = 2 where pk = 1", nil, state.TransactionID, 0, nil)
+	require.NoError(t, err)
+	err = tsv.Prepare(ctx, &target, state.TransactionID, "aa")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aa"}, tsv.OutstandingPreparedTransactions(ctx))
+
+	// the handler reports the transaction, which isn't resolved within the wait
+	request, _ := http.NewRequest("GET", "/debug/prepared_transactions?wait=10ms", nil)
+	response := httptest.NewRecorder()
+	tsv.preparedTransactionsHandler(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.JSONEq(t, `{"dtids":["aa"],"drained":false}`, response.Body.String())
+
+	// the drain returns once the transaction is resolved
+	go func() {
+		time.Sleep(10 * time.Millisecond)
+		tsv.RollbackPrepared(ctx, &target, "aa", 0)
+	}()
+	drainCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	assert.Empty(t, tsv.DrainPreparedTransactions(drainCtx))
+}
+
 func TestTabletServerCommitPrepared(t *testing.T) {
 	// Reuse code from tx_executor_test.
 	_, tsv, db := newTestTxExecutor(t)
@@ -571,6 +608,94 @@ func TestSmallerTimeout(t *testing.T) {
 	}
 }
 
+func TestQueryTimeoutForWorkload(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	config.Oltp.QueryTimeoutSeconds.Set(10 * time.Second)
+	config.Olap.QueryTimeoutSeconds.Set(300 * time.Second)
+	config.DB = dbconfigs.NewTestDBConfigs(mysql.ConnParams{}, mysql.ConnParams{}, "")
+	// the timeouts are taken from the config when the tablet server is created, it doesn't need to be started
+	tsv := NewTabletServer("TabletServerTest", config, memorytopo.NewServer(""), &topodatapb.TabletAlias{})
+
+	assert.Equal(t, 10*time.Second, tsv.queryTimeoutForWorkload(querypb.ExecuteOptions_OLTP))
+	assert.Equal(t, 300*time.Second, tsv.queryTimeoutForWorkload(querypb.ExecuteOptions_OLAP))
+	assert.Equal(t, time.Duration(0), tsv.queryTimeoutForWorkload(querypb.ExecuteOptions_DBA))
+
+	tsv.QueryTimeout.Set(20 * time.Second)
+	tsv.OlapQueryTimeout.Set(600 * time.Second)
+	assert.Equal(t, 20*time.Second, tsv.queryTimeoutForWorkload(querypb.ExecuteOptions_OLTP))
+	assert.Equal(t, 600*time.Second, tsv.queryTimeoutForWorkload(querypb.ExecuteOptions_OLAP))
+}
+
+func TestQueryTimeoutForWorkloadOnExecute(t *testing.T) {
+	db := setUpQueryExecutorTest(t)
+	defer db.Close()
+	tsv := newTestTabletServer(ctx, noFlags, db)
+	defer tsv.StopService()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	})
+	db.SetBeforeFunc(executeSQL, func() {
+		time.Sleep(100 * time.Millisecond)
+	})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	execute := func(transactionID int64, options *querypb.ExecuteOptions) error {
+		_, err := tsv.Execute(ctx, &target, executeSQL, nil, transactionID, 0, options)
+		return err
+	}
+	streamExecute := func(transactionID int64, options *querypb.ExecuteOptions) error {
+		return tsv.StreamExecute(ctx, &target, executeSQL, nil, transactionID, 0, options, func(*sqltypes.Result) error { return nil })
+	}
+	// begin starts a new transaction for every query that is expected to be
+	// killed, since the kill also closes the connection of the transaction.
+	begin := func() int64 {
+		state, err := tsv.Begin(ctx, &target, nil)
+		require.NoError(t, err)
+		return state.TransactionID
+	}
+	const short = 10 * time.Millisecond
+
+	tsv.QueryTimeout.Set(short)
+	tsv.OlapQueryTimeout.Set(time.Hour)
+	require.Error(t, execute(0, nil))
+	require.NoError(t, streamExecute(0, nil))
+	// DBA queries have no timeout.
+	require.NoError(t, execute(0, &querypb.ExecuteOptions{Workload: querypb.ExecuteOptions_DBA}))
+
+	tsv.QueryTimeout.Set(time.Hour)
+	tsv.OlapQueryTimeout.Set(short)
+	require.NoError(t, execute(0, nil))
+	require.Error(t, streamExecute(0, nil))
+	require.NoError(t, streamExecute(0, &querypb.ExecuteOptions{Workload: querypb.ExecuteOptions_DBA}))
+
+	// Inside a transaction, the smaller of the query and transaction timeouts applies.
+	tsv.OlapQueryTimeout.Set(short)
+	tsv.config.SetTxTimeoutForWorkload(time.Hour, querypb.ExecuteOptions_OLAP)
+	require.Error(t, streamExecute(begin(), nil))
+
+	tsv.OlapQueryTimeout.Set(time.Hour)
+	tsv.config.SetTxTimeoutForWorkload(short, querypb.ExecuteOptions_OLAP)
+	require.Error(t, streamExecute(begin(), nil))
+
+	tsv.OlapQueryTimeout.Set(0)
+	require.Error(t, streamExecute(begin(), nil))
+
+	tsv.config.SetTxTimeoutForWorkload(time.Hour, querypb.ExecuteOptions_OLAP)
+	transactionID := begin()
+	require.NoError(t, streamExecute(transactionID, nil))
+	_, err := tsv.Rollback(ctx, &target, transactionID)
+	require.NoError(t, err)
+
+	// Begin before lowering the transaction timeout so that only the query
+	// gets killed, not the whole transaction.
+	tsv.QueryTimeout.Set(time.Hour)
+	transactionID = begin()
+	tsv.config.SetTxTimeoutForWorkload(short, querypb.ExecuteOptions_OLTP)
+	require.Error(t, execute(transactionID, nil))
+}
+
 func TestTabletServerReserveConnection(t *testing.T) {
 	db, tsv := setupTabletServerTest(t, "")
 	defer tsv.StopService()
@@ -727,6 +852,636 @@ func TestTabletServerStreamExecute(t *testing.T) {
 	}
 }
 
+func TestTabletServerStreamExecuteRowLimit(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	executeSQLResult := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Type: sqltypes.VarBinary},
+		},
+	}
+	for i := 1; i <= 5; i++ {
+		executeSQLResult.Rows = append(executeSQLResult.Rows, []sqltypes.Value{sqltypes.NewVarBinary(fmt.Sprintf("row%02d", i))})
+	}
+	db.AddQuery(executeSQL, executeSQLResult)
+	db.AddQueryPattern(`kill query \d+`, &sqltypes.Result{})
+
+	var rows []sqltypes.Row
+	var truncated bool
+	callback := func(qr *sqltypes.Result) error {
+		require.False(t, truncated, "no result should be sent after the truncated one")
+		rows = append(rows, qr.Rows...)
+		truncated = qr.Truncated
+		return nil
+	}
+	reset := func() {
+		rows = nil
+		truncated = false
+	}
+
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	err := tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, 0, &querypb.ExecuteOptions{StreamRowLimit: 3}, callback)
+	require.NoError(t, err)
+	assert.Equal(t, executeSQLResult.Rows[:3], rows)
+	assert.True(t, truncated)
+
+	// the result isn't truncated if all the rows are within the limit
+	reset()
+	err = tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, 0, &querypb.ExecuteOptions{StreamRowLimit: 5}, callback)
+	require.NoError(t, err)
+	assert.Equal(t, executeSQLResult.Rows, rows)
+	assert.False(t, truncated)
+
+	// the transaction can still be used after the stream is cut short
+	reset()
+	state, err := tsv.BeginStreamExecute(ctx, &target, nil, executeSQL, nil, 0, &querypb.ExecuteOptions{StreamRowLimit: 2}, callback)
+	require.NoError(t, err)
+	assert.Equal(t, executeSQLResult.Rows[:2], rows)
+	assert.True(t, truncated)
+	qr, err := tsv.Execute(ctx, &target, executeSQL, nil, state.TransactionID, 0, nil)
+	require.NoError(t, err)
+	assert.Len(t, qr.Rows, 5)
+	_, _, err = tsv.Commit(ctx, &target, state.TransactionID)
+	require.NoError(t, err)
+}
+
+func TestTabletServerStreamMaxConcurrency(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+	tsv.SetStreamMaxConcurrency(2)
+	assert.Equal(t, 2, tsv.StreamMaxConcurrency())
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	// the first streams are blocked in their callback until release is closed
+	started := make(chan struct{})
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 2; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var once sync.Once
+			err := tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, 0, nil, func(*sqltypes.Result) error {
+				once.Do(func() { started <- struct{}{} })
+				<-release
+				return nil
+			})
+			assert.NoError(t, err)
+		}()
+	}
+	for i := 0; i < 2; i++ {
+		<-started
+	}
+
+	// the streams beyond the limit are rejected
+	err := tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, 0, nil, func(*sqltypes.Result) error { return nil })
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.Code(err))
+	assert.Contains(t, err.Error(), "the limit is 2")
+	assert.EqualValues(t, 1, tsv.qe.rejectedStreams.Get())
+
+	close(release)
+	wg.Wait()
+	assert.EqualValues(t, 0, tsv.qe.activeStreams.Get())
+
+	// a stream is accepted again once the others are done
+	err = tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, 0, nil, func(*sqltypes.Result) error { return nil })
+	require.NoError(t, err)
+
+	// 0 means no limit
+	tsv.SetStreamMaxConcurrency(0)
+	for i := 0; i < 3; i++ {
+		require.True(t, tsv.qe.acquireStream())
+	}
+	for i := 0; i < 3; i++ {
+		tsv.qe.releaseStream()
+	}
+}
+
+func TestTabletServerStreamExecuteFailureMarker(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+	// send every row in its own result
+	tsv.qe.streamBufferSize.Set(1)
+
+	executeSQL := "select * from test_table limit 1000"
+	executeSQLResult := &sqltypes.Result{
+		Fields: []*querypb.Field{
+			{Name: "pk", Type: sqltypes.Int32},
+			{Name: "name", Type: sqltypes.VarBinary},
+		},
+	}
+	for i := 1; i <= 5; i++ {
+		executeSQLResult.Rows = append(executeSQLResult.Rows, []sqltypes.Value{
+			sqltypes.NewInt32(int32(i)),
+			sqltypes.NewVarBinary(fmt.Sprintf("row%02d", i)),
+		})
+	}
+	db.AddQuery(executeSQL, executeSQLResult)
+	db.AddQueryPattern(`kill \d+`, &sqltypes.Result{})
+
+	// the stream fails in the middle because its context is canceled
+	// once the first row has been received
+	var results []*sqltypes.Result
+	var rowsReceived int
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	callback := func(qr *sqltypes.Result) error {
+		results = append(results, qr.Copy())
+		if qr.StreamFailureMarker {
+			return nil
+		}
+		rowsReceived += len(qr.Rows)
+		if rowsReceived == 1 {
+			cancel()
+			// give the stream time to be killed
+			time.Sleep(100 * time.Millisecond)
+		}
+		return nil
+	}
+
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	options := &querypb.ExecuteOptions{ReportStreamFailureMarker: true}
+	err := tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, 0, options, callback)
+	require.Error(t, err)
+	require.NotEmpty(t, results)
+	marker := results[len(results)-1]
+	require.True(t, marker.StreamFailureMarker)
+	assert.EqualValues(t, rowsReceived, marker.RowsDelivered)
+	require.Len(t, marker.Rows, 1)
+	assert.Equal(t, "pk", marker.Fields[0].Name)
+	assert.Equal(t, executeSQLResult.Rows[rowsReceived-1][0], marker.Rows[0][0])
+
+	// without the option, no marker is sent
+	results, rowsReceived = nil, 0
+	ctx, cancel = context.WithCancel(context.Background())
+	defer cancel()
+	err = tsv.StreamExecute(ctx, &target, executeSQL, nil, 0, 0, nil, callback)
+	require.Error(t, err)
+	for _, qr := range results {
+		assert.False(t, qr.StreamFailureMarker)
+	}
+}
+
+func TestTabletServerSlowQueryLog(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	config.SlowQueryThresholdSeconds.Set(100 * time.Millisecond)
+	db, tsv := setupTabletServerTestCustom(t, config, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	fastSQL := "select * from test_table where pk = 1 limit 1000"
+	slowSQL := "select * from test_table where pk = 2 limit 1000"
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	}
+	db.AddQuery(fastSQL, result)
+	db.AddQuery(slowSQL, result)
+	db.SetBeforeFunc(slowSQL, func() {
+		time.Sleep(200 * time.Millisecond)
+	})
+
+	var logged []string
+	defer func(f func(string, ...any)) { logSlowQuery = f }(logSlowQuery)
+	logSlowQuery = func(format string, v ...any) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	_, err := tsv.Execute(ctx, &target, fastSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 0, tsv.stats.SlowQueryCounts.Counts()["test_table"])
+	assert.Empty(t, logged)
+
+	_, err = tsv.Execute(ctx, &target, slowSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, tsv.stats.SlowQueryCounts.Counts()["test_table"])
+	require.Len(t, logged, 1)
+	assert.Contains(t, logged[0], `Sql: "select * from test_table where pk = :pk limit :redacted1"`)
+	assert.Contains(t, logged[0], "Table: test_table")
+
+	// A zero threshold disables slow query logging.
+	tsv.SlowQueryThreshold.Set(0)
+	_, err = tsv.Execute(ctx, &target, slowSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, tsv.stats.SlowQueryCounts.Counts()["test_table"])
+	assert.Len(t, logged, 1)
+}
+
+func TestRecordSlowQueryRedactsLiterals(t *testing.T) {
+	_, tsv, db := newTestTxExecutor(t)
+	defer db.Close()
+	defer tsv.StopService()
+	tsv.SlowQueryThreshold.Set(100 * time.Millisecond)
+
+	var logged []string
+	defer func(f func(string, ...any)) { logSlowQuery = f }(logSlowQuery)
+	logSlowQuery = func(format string, v ...any) {
+		logged = append(logged, fmt.Sprintf(format, v...))
+	}
+
+	logStats := tabletenv.NewLogStats(ctx, "Execute")
+	logStats.OriginalSQL = "select * from test_table where name = 'secret-name' and pk = 42"
+	logStats.TableName = "test_table"
+	logStats.PlanType = "Select"
+	logStats.EndTime = logStats.StartTime.Add(time.Second)
+	tsv.recordSlowQuery(logStats)
+	require.Len(t, logged, 1)
+	assert.Contains(t, logged[0], `Sql: "select * from test_table where `+"`name`"+` = :name and pk = :pk"`)
+	assert.NotContains(t, logged[0], "secret-name")
+	assert.NotContains(t, logged[0], "42")
+
+	// a query that can't be parsed is logged by its plan type only.
+	logStats.OriginalSQL = "select 'secret-name' from"
+	tsv.recordSlowQuery(logStats)
+	require.Len(t, logged, 2)
+	assert.Contains(t, logged[1], `Sql: "Select"`)
+	assert.NotContains(t, logged[1], "secret-name")
+}
+
+func TestTabletServerHeartbeatStatus(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	rt := tsv.sm.rt
+	defer func() { tsv.sm.rt = rt }()
+	lastHeartbeat := time.Now()
+	tsv.sm.rt = &testReplTracker{lag: 3 * time.Second, lastHeartbeat: lastHeartbeat}
+
+	lag, last, err := tsv.HeartbeatStatus()
+	require.NoError(t, err)
+	assert.Equal(t, 3*time.Second, lag)
+	assert.Equal(t, lastHeartbeat, last)
+
+	tsv.sm.rt = &testReplTracker{err: errors.New("heartbeat read failed")}
+	_, last, err = tsv.HeartbeatStatus()
+	assert.EqualError(t, err, "heartbeat read failed")
+	assert.True(t, last.IsZero())
+}
+
+func TestTabletServerListMigrations(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	db.AddQuery("use mysql", &sqltypes.Result{})
+	db.AddQueryPattern(`SELECT\s+migration_uuid,\s+keyspace,\s+mysql_table,\s+migration_status,\s+progress,\s+eta_seconds\s+FROM mysql\.schema_migrations.*`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("migration_uuid|keyspace|mysql_table|migration_status|progress|eta_seconds", "varchar|varchar|varchar|varchar|float32|int64"),
+			"9e8a9249_3976_11ed_9442_0a43f95f28a3|ks|t1|running|42.5|120"))
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	migrations, err := tsv.ListMigrations(ctx, &target, "")
+	require.NoError(t, err)
+	require.Len(t, migrations, 1)
+	assert.Equal(t, &onlineddl.MigrationProgress{
+		UUID:       "9e8a9249_3976_11ed_9442_0a43f95f28a3",
+		Keyspace:   "ks",
+		Table:      "t1",
+		Status:     "running",
+		Progress:   42.5,
+		ETASeconds: 120,
+	}, migrations[0])
+
+	// the target is verified
+	_, err = tsv.ListMigrations(ctx, &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}, "")
+	assert.Error(t, err)
+}
+
+func TestTabletServerDebugStatus(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	db.AddQuery("use mysql", &sqltypes.Result{})
+	db.AddQueryPattern(`SELECT\s+migration_uuid,\s+keyspace,\s+mysql_table,\s+migration_status,\s+progress,\s+eta_seconds\s+FROM mysql\.schema_migrations.*`,
+		sqltypes.MakeTestResult(sqltypes.MakeTestFields("migration_uuid|keyspace|mysql_table|migration_status|progress|eta_seconds", "varchar|varchar|varchar|varchar|float32|int64"),
+			"9e8a9249_3976_11ed_9442_0a43f95f28a3|ks|t1|running|42.5|120"))
+	// a failing subsystem only fails its own section
+	db.RejectQueryPattern("select \\* from mysql.non_transactional_dml_jobs.*", "dml jobs unavailable")
+
+	request, _ := http.NewRequest("GET", "/debug/status_json", nil)
+	response := httptest.NewRecorder()
+	tsv.debugStatusHandler(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	var status map[string]map[string]any
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &status))
+	for _, section := range []string{"serving", "pools", "throttler", "migrations", "dml_jobs"} {
+		assert.Contains(t, status, section)
+	}
+	assert.Equal(t, "SERVING", status["serving"]["state"])
+	assert.Contains(t, status["pools"]["stats"], "conn_pool")
+	assert.Contains(t, status["throttler"], "status")
+	assert.Len(t, status["migrations"]["migrations"], 1)
+	assert.NotContains(t, status["migrations"], "error")
+	assert.Contains(t, status["dml_jobs"]["error"], "dml jobs unavailable")
+}
+
+func TestTabletServerReportPlanCacheHit(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	execute := func(options *querypb.ExecuteOptions) *sqltypes.Result {
+		qr, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, options)
+		require.NoError(t, err)
+		// plan cache writes are asynchronous
+		tsv.qe.plans.Wait()
+		return qr
+	}
+	report := &querypb.ExecuteOptions{ReportPlanCacheHit: true}
+
+	assert.False(t, execute(report).PlanCacheHit)
+	assert.True(t, execute(report).PlanCacheHit)
+	// the flag is only set on request
+	assert.False(t, execute(nil).PlanCacheHit)
+
+	assert.False(t, execute(&querypb.ExecuteOptions{ReportPlanCacheHit: true, ForceFreshPlan: true}).PlanCacheHit)
+	// the fresh plan replaces the cached one
+	assert.True(t, execute(report).PlanCacheHit)
+}
+
+func TestTabletServerCancelQuery(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	conn := &testConn{id: 12, query: "select sleep(100) from dual"}
+	qd := NewQueryDetail(ctx, conn)
+	tsv.statefulql.Add(qd)
+	defer tsv.statefulql.Remove(qd)
+
+	found, err := tsv.CancelQuery(ctx, &target, 13)
+	require.NoError(t, err)
+	assert.False(t, found)
+	assert.False(t, conn.IsKilled())
+
+	found, err = tsv.CancelQuery(ctx, &target, 12)
+	require.NoError(t, err)
+	assert.True(t, found)
+	assert.True(t, conn.IsKilled())
+
+	_, err = tsv.CancelQuery(ctx, &querypb.Target{TabletType: topodatapb.TabletType_REPLICA}, 12)
+	assert.Error(t, err)
+}
+
+func TestTabletServerWarmupPlans(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	tsv.ClearQueryPlanCache()
+	queryCount := db.GetQueryCalledNum(executeSQL)
+
+	err := tsv.WarmupPlans(ctx, &target, []string{
+		executeSQL + " /* trailing comment */",
+		"update test_table set `name` = 2 where pk = :pk",
+		"select from",
+	})
+	// the invalid query doesn't stop the warmup of the other ones
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "select from")
+	assert.Equal(t, 2, tsv.QueryPlanCacheLen())
+	// the queries are not executed
+	assert.Equal(t, queryCount, db.GetQueryCalledNum(executeSQL))
+
+	qr, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, &querypb.ExecuteOptions{ReportPlanCacheHit: true})
+	require.NoError(t, err)
+	assert.True(t, qr.PlanCacheHit)
+	assert.Equal(t, 2, tsv.QueryPlanCacheLen())
+}
+
+func TestTabletServerReportRowsExamined(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table where `name` = 1 limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	})
+	// the query scans the table to find its only row
+	db.AddQuery(sqlLastStatementRowsExamined, sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("rows_examined", "uint64"), "1000"))
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	qr, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, &querypb.ExecuteOptions{ReportRowsExamined: true})
+	require.NoError(t, err)
+	assert.Len(t, qr.Rows, 1)
+	assert.EqualValues(t, 1000, qr.RowsExamined)
+	assert.Equal(t, 1, db.GetQueryCalledNum(sqlLastStatementRowsExamined))
+
+	// rows examined are only read on request
+	qr, err = tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Zero(t, qr.RowsExamined)
+	assert.Equal(t, 1, db.GetQueryCalledNum(sqlLastStatementRowsExamined))
+}
+
+func TestTabletServerReportThreadID(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	qr, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, &querypb.ExecuteOptions{ReportThreadId: true})
+	require.NoError(t, err)
+	assert.Len(t, qr.Rows, 1)
+	assert.NotZero(t, qr.ThreadID)
+
+	// the thread id is the one of the connection of the transaction
+	state, err := tsv.Begin(ctx, &target, nil)
+	require.NoError(t, err)
+	qr, err = tsv.Execute(ctx, &target, executeSQL, nil, state.TransactionID, 0, &querypb.ExecuteOptions{ReportThreadId: true})
+	require.NoError(t, err)
+	conn, err := tsv.te.txPool.GetAndLock(state.TransactionID, "for test")
+	require.NoError(t, err)
+	assert.EqualValues(t, conn.ID(), qr.ThreadID)
+	conn.Unlock()
+	_, err = tsv.Rollback(ctx, &target, state.TransactionID)
+	require.NoError(t, err)
+
+	// the thread id is only reported on request
+	qr, err = tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Zero(t, qr.ThreadID)
+}
+
+func TestTabletServerDeterministicOrder(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	orderedSQL := "select * from test_table order by test_table.pk asc limit 1000"
+	fields := []*querypb.Field{{Name: "pk", Type: sqltypes.Int64}}
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: fields,
+		Rows:   [][]sqltypes.Value{{sqltypes.NewInt64(2)}, {sqltypes.NewInt64(1)}},
+	})
+	db.AddQuery(orderedSQL, &sqltypes.Result{
+		Fields: fields,
+		Rows:   [][]sqltypes.Value{{sqltypes.NewInt64(1)}, {sqltypes.NewInt64(2)}},
+	})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	options := &querypb.ExecuteOptions{DeterministicOrder: true}
+
+	// two reads return the rows in the same order, and both are ordered by the primary key
+	qr1, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, options)
+	require.NoError(t, err)
+	qr2, err := tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, options)
+	require.NoError(t, err)
+	assert.Equal(t, qr1.Rows, qr2.Rows)
+	assert.Equal(t, 2, db.GetQueryCalledNum(orderedSQL))
+
+	// so is a read in a transaction
+	state, err := tsv.Begin(ctx, &target, nil)
+	require.NoError(t, err)
+	qr3, err := tsv.Execute(ctx, &target, executeSQL, nil, state.TransactionID, 0, options)
+	require.NoError(t, err)
+	assert.Equal(t, qr1.Rows, qr3.Rows)
+	assert.Equal(t, 3, db.GetQueryCalledNum(orderedSQL))
+	_, err = tsv.Rollback(ctx, &target, state.TransactionID)
+	require.NoError(t, err)
+
+	// a query that already has an ORDER BY is left as it is
+	sortedSQL := "select * from test_table order by `name` asc limit 1000"
+	db.AddQuery(sortedSQL, &sqltypes.Result{Fields: fields})
+	_, err = tsv.Execute(ctx, &target, sortedSQL, nil, 0, 0, options)
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.GetQueryCalledNum(sortedSQL))
+
+	// the order is only added on request
+	_, err = tsv.Execute(ctx, &target, executeSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, db.GetQueryCalledNum(executeSQL))
+	assert.Equal(t, 3, db.GetQueryCalledNum(orderedSQL))
+}
+
+func TestTabletServerMaxBindVarsPerQuery(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	config.MaxBindVarsPerQuery = 3
+	db, tsv := setupTabletServerTestCustom(t, config, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table where pk in ::pks and `name` = :name"
+	db.AddQuery("select * from test_table where pk in (1, 2) and `name` = 'a' limit 100001", &sqltypes.Result{})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	bindVars := map[string]*querypb.BindVariable{
+		"pks":  sqltypes.TestBindVariable([]any{1, 2}),
+		"name": sqltypes.StringBindVariable("a"),
+	}
+	_, err := tsv.Execute(ctx, &target, executeSQL, bindVars, 0, 0, nil)
+	require.NoError(t, err)
+
+	// every value of the list counts as a bind variable
+	bindVars = map[string]*querypb.BindVariable{
+		"pks":  sqltypes.TestBindVariable([]any{1, 2, 3}),
+		"name": sqltypes.StringBindVariable("a"),
+	}
+	_, err = tsv.Execute(ctx, &target, executeSQL, bindVars, 0, 0, nil)
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_INVALID_ARGUMENT, vterrors.Code(err))
+	assert.Contains(t, err.Error(), "the query has 4 bind variables, more than the limit of 3")
+	assert.Equal(t, 1, db.GetQueryCalledNum("select * from test_table where pk in (1, 2) and `name` = 'a' limit 100001"))
+}
+
+func TestTabletServerResultCache(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	config.ResultCacheTables = []string{"test_table"}
+	config.ResultCacheTTLSeconds.Set(time.Minute)
+	db, tsv := setupTabletServerTestCustom(t, config, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	selectSQL := "select * from test_table where pk = 1 limit 1000"
+	updateSQL := "update test_table set `name` = 2 where pk = 1"
+	result := &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	}
+	db.AddQuery(selectSQL, result)
+	db.AddQuery(updateSQL+" limit 100001", &sqltypes.Result{RowsAffected: 1})
+
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	for i := 0; i < 3; i++ {
+		got, err := tsv.Execute(ctx, &target, selectSQL, nil, 0, 0, nil)
+		require.NoError(t, err)
+		assert.Equal(t, result.Rows, got.Rows)
+	}
+	// repeated reads are served from the result cache
+	assert.Equal(t, 1, db.GetQueryCalledNum(selectSQL))
+	assert.EqualValues(t, 2, tsv.qe.resultCache.hits.Get())
+
+	// a write to the table invalidates its cached results
+	_, err := tsv.Execute(ctx, &target, updateSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 0, tsv.qe.resultCache.Len())
+	_, err = tsv.Execute(ctx, &target, selectSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 2, db.GetQueryCalledNum(selectSQL))
+
+	// a write in a transaction only invalidates them once it commits
+	state, err := tsv.Begin(ctx, &target, nil)
+	require.NoError(t, err)
+	_, err = tsv.Execute(ctx, &target, updateSQL, nil, state.TransactionID, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 1, tsv.qe.resultCache.Len())
+	_, _, err = tsv.Commit(ctx, &target, state.TransactionID)
+	require.NoError(t, err)
+	assert.Equal(t, 0, tsv.qe.resultCache.Len())
+	_, err = tsv.Execute(ctx, &target, selectSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 3, db.GetQueryCalledNum(selectSQL))
+
+	// the options that change the shape of the result are part of the key
+	_, err = tsv.Execute(ctx, &target, selectSQL, nil, 0, 0, &querypb.ExecuteOptions{IncludedFields: querypb.ExecuteOptions_ALL})
+	require.NoError(t, err)
+	assert.Equal(t, 4, db.GetQueryCalledNum(selectSQL))
+
+	// so does a schema change of the table
+	tsv.qe.schemaChanged(tsv.qe.tables, nil, []string{"test_table"}, nil)
+	assert.Equal(t, 0, tsv.qe.resultCache.Len())
+	_, err = tsv.Execute(ctx, &target, selectSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, 5, db.GetQueryCalledNum(selectSQL))
+}
+
 func TestTabletServerStreamExecuteComments(t *testing.T) {
 	db, tsv := setupTabletServerTest(t, "")
 	defer tsv.StopService()
@@ -868,7 +1623,7 @@ func TestSerializeTransactionsSameRow(t *testing.T) {
 	// Make sure that tx3 could finish while tx2 could not.
 	tx3Finished := make(chan struct{})
 
-	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 10001",
+	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 100001",
 		func() {
 			close(tx1Started)
 			if err := waitForTxSerializationPendingQueries(tsv, "test_table where pk = 1 and `name` = 1", 2); err != nil {
@@ -950,7 +1705,7 @@ func TestDMLQueryWithoutWhereClause(t *testing.T) {
 	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
 	q := "delete from test_table"
 
-	db.AddQuery(q+" limit 10001", &sqltypes.Result{})
+	db.AddQuery(q+" limit 100001", &sqltypes.Result{})
 
 	state, _, err := tsv.BeginExecute(ctx, &target, nil, q, nil, 0, nil)
 	require.NoError(t, err)
@@ -958,6 +1713,26 @@ func TestDMLQueryWithoutWhereClause(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestComputeTxSerializerKeyInternalErrors(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	logStats := tabletenv.NewLogStats(ctx, "ComputeTxSerializerKey")
+	startCounts := tsv.stats.InternalErrors.Counts()
+
+	// the bind variable of the where clause is missing
+	key, table := tsv.computeTxSerializerKey(ctx, logStats, "", "update test_table set name_string = 'a' where pk = :pk", nil)
+	assert.Equal(t, "", key)
+	assert.Equal(t, "", table)
+	assert.Equal(t, int64(1), tsv.stats.InternalErrors.Counts()["ComputeRowSerializerKey"]-startCounts["ComputeRowSerializerKey"])
+
+	key, table = tsv.computeTxSerializerKey(ctx, logStats, "", "update test_table set", nil)
+	assert.Equal(t, "", key)
+	assert.Equal(t, "", table)
+	assert.Equal(t, int64(1), tsv.stats.InternalErrors.Counts()["GetPlan"]-startCounts["GetPlan"])
+}
+
 func TestSerializeTransactionsSameRow_ConcurrentTransactions(t *testing.T) {
 	// This test runs three transaction in parallel:
 	// tx1 | tx2 | tx3
@@ -996,7 +1771,7 @@ func TestSerializeTransactionsSameRow_ConcurrentTransactions(t *testing.T) {
 
 	tx1Started := make(chan struct{})
 	allQueriesPending := make(chan struct{})
-	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 10001",
+	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 100001",
 		func() {
 			close(tx1Started)
 			<-allQueriesPending
@@ -1128,7 +1903,7 @@ func TestSerializeTransactionsSameRow_TooManyPendingRequests(t *testing.T) {
 	// Signal when tx2 is done.
 	tx2Failed := make(chan struct{})
 
-	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 10001",
+	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 100001",
 		func() {
 			close(tx1Started)
 			<-tx2Failed
@@ -1214,7 +1989,7 @@ func TestSerializeTransactionsSameRow_RequestCanceled(t *testing.T) {
 	// Signal when tx2 is done.
 	tx2Done := make(chan struct{})
 
-	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 10001",
+	db.SetBeforeFunc("update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 100001",
 		func() {
 			close(tx1Started)
 			// Keep blocking until tx2 was canceled.
@@ -1317,6 +2092,29 @@ func TestMessageStream(t *testing.T) {
 	}
 }
 
+func TestMessageStreamWithStreamPoolExhausted(t *testing.T) {
+	_, tsv, db := newTestTxExecutor(t)
+	defer db.Close()
+	defer tsv.StopService()
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	// The messager reads the messages through the vstreamer, not the stream pool,
+	// so an exhausted stream pool doesn't stop the delivery.
+	tsv.SetStreamPoolSize(1)
+	conn, err := tsv.qe.streamConns.Get(ctx, nil)
+	require.NoError(t, err)
+	defer conn.Recycle()
+	require.EqualValues(t, 0, tsv.qe.streamConns.Available())
+
+	called := false
+	err = tsv.MessageStream(ctx, &target, "msg", func(qr *sqltypes.Result) error {
+		called = true
+		return io.EOF
+	})
+	require.NoError(t, err)
+	assert.True(t, called, "callback was not called for MessageStream")
+}
+
 func TestCheckMySQLGauge(t *testing.T) {
 	_, tsv, db := newTestTxExecutor(t)
 	defer db.Close()
@@ -1345,6 +2143,45 @@ func TestCheckMySQLGauge(t *testing.T) {
 	}
 }
 
+func TestDebugCheckMySQLHandler(t *testing.T) {
+	_, tsv, db := newTestTxExecutor(t)
+	defer db.Close()
+	defer tsv.StopService()
+
+	checkMySQL := func() (triggered bool, state string) {
+		request, _ := http.NewRequest("POST", "/debug/checkmysql", nil)
+		response := httptest.NewRecorder()
+		tsv.debugCheckMySQLHandler(response, request)
+		require.Equal(t, http.StatusOK, response.Code)
+		var result struct {
+			Triggered bool
+			State     string
+		}
+		require.NoError(t, json.Unmarshal(response.Body.Bytes(), &result))
+		return result.Triggered, result.State
+	}
+
+	triggered, state := checkMySQL()
+	assert.True(t, triggered)
+	assert.Equal(t, "SERVING", state)
+	assert.EqualValues(t, 1, tsv.checkMysqlGaugeFunc.Get())
+
+	// A second request within a second is rate-limited.
+	triggered, state = checkMySQL()
+	assert.False(t, triggered)
+	assert.Equal(t, "SERVING", state)
+
+	// Wait for CheckMySQL to finish.
+	timeout := time.After(2 * time.Second)
+	for tsv.checkMysqlGaugeFunc.Get() != 0 {
+		select {
+		case <-timeout:
+			t.Fatalf("Timedout waiting for CheckMySQL to finish")
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
 func TestMessageAck(t *testing.T) {
 	_, tsv, db := newTestTxExecutor(t)
 	defer db.Close()
@@ -1374,6 +2211,102 @@ func TestMessageAck(t *testing.T) {
 	require.EqualValues(t, 1, count)
 }
 
+func TestMessageAckDeadlockRetry(t *testing.T) {
+	_, tsv, db := newTestTxExecutor(t)
+	defer db.Close()
+	defer tsv.StopService()
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	ids := []*querypb.Value{{
+		Type:  sqltypes.VarChar,
+		Value: []byte("1"),
+	}}
+	deadlock := mysql.NewSQLError(mysql.ERLockDeadlock, mysql.SSLockDeadlock, "Deadlock found when trying to get lock; try restarting transaction")
+	db.AddQueryPattern("update msg set time_acked = .*", &sqltypes.Result{RowsAffected: 1})
+
+	// the deadlock is returned as is by default
+	db.RejectQueryPatternTimes("update msg set time_acked = .*", deadlock, 1)
+	_, err := tsv.MessageAck(ctx, &target, "msg", ids)
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_ABORTED, vterrors.Code(err))
+
+	tsv.config.MessageDeadlockRetries = 2
+	retries := tsv.stats.DeadlockRetries.Counts()["Messages"]
+	db.RejectQueryPatternTimes("update msg set time_acked = .*", deadlock, 2)
+	count, err := tsv.MessageAck(ctx, &target, "msg", ids)
+	require.NoError(t, err)
+	assert.EqualValues(t, 1, count)
+	assert.EqualValues(t, retries+2, tsv.stats.DeadlockRetries.Counts()["Messages"])
+
+	// the retries are bounded
+	db.RejectQueryPatternTimes("update msg set time_acked = .*", deadlock, 3)
+	_, err = tsv.MessageAck(ctx, &target, "msg", ids)
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_ABORTED, vterrors.Code(err))
+}
+
+func TestMessageAckMulti(t *testing.T) {
+	db := setupFakeDB(t)
+	defer db.Close()
+	msgFields := []*querypb.Field{
+		{Name: "id", Type: sqltypes.Int64},
+		{Name: "priority", Type: sqltypes.Int64},
+		{Name: "time_next", Type: sqltypes.Int64},
+		{Name: "epoch", Type: sqltypes.Int64},
+		{Name: "time_acked", Type: sqltypes.Int64},
+		{Name: "message", Type: sqltypes.Int64},
+	}
+	db.AddQueryPattern(baseShowTablesPattern, &sqltypes.Result{
+		Fields: mysql.BaseShowTablesFields,
+		Rows: [][]sqltypes.Value{
+			mysql.BaseShowTablesRow("test_table", false, ""),
+			mysql.BaseShowTablesRow("msg", false, "vitess_message,vt_ack_wait=30,vt_purge_after=120,vt_batch_size=1,vt_cache_size=10,vt_poller_interval=30"),
+			mysql.BaseShowTablesRow("msg2", false, "vitess_message,vt_ack_wait=30,vt_purge_after=120,vt_batch_size=1,vt_cache_size=10,vt_poller_interval=30"),
+		},
+	})
+	db.AddQuery(mysql.BaseShowPrimary, &sqltypes.Result{
+		Fields: mysql.ShowPrimaryFields,
+		Rows: [][]sqltypes.Value{
+			mysql.ShowPrimaryRow("test_table", "pk"),
+			mysql.ShowPrimaryRow("msg", "id"),
+			mysql.ShowPrimaryRow("msg2", "id"),
+		},
+	})
+	db.AddQuery("select * from msg2 where 1 != 1", &sqltypes.Result{Fields: msgFields})
+	db.MockQueriesForTable("msg2", &sqltypes.Result{Fields: msgFields})
+
+	tsv := NewTabletServer("TabletServerTest", tabletenv.NewDefaultConfig(), memorytopo.NewServer(""), &topodatapb.TabletAlias{})
+	target := querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+	err := tsv.StartService(&target, newDBConfigs(db), nil /* mysqld */)
+	require.NoError(t, err)
+	defer tsv.StopService()
+
+	ids := []*querypb.Value{{
+		Type:  sqltypes.VarChar,
+		Value: []byte("1"),
+	}, {
+		Type:  sqltypes.VarChar,
+		Value: []byte("2"),
+	}}
+
+	_, err = tsv.MessageAckMulti(ctx, &target, map[string][]*querypb.Value{"msg": ids, "nonmsg": ids})
+	require.ErrorContains(t, err, "message table nonmsg not found in schema")
+
+	db.AddQueryPattern("update msg set time_acked = .*", &sqltypes.Result{RowsAffected: 2})
+	db.AddQueryPattern("update msg2 set time_acked = .*", &sqltypes.Result{RowsAffected: 1})
+	beginCount := db.GetQueryCalledNum("begin")
+	commitCount := db.GetQueryCalledNum("commit")
+	counts, err := tsv.MessageAckMulti(ctx, &target, map[string][]*querypb.Value{
+		"msg":  ids,
+		"msg2": ids[:1],
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int64{"msg": 2, "msg2": 1}, counts)
+	// both tables are acked in the same transaction
+	assert.Equal(t, beginCount+1, db.GetQueryCalledNum("begin"))
+	assert.Equal(t, commitCount+1, db.GetQueryCalledNum("commit"))
+}
+
 func TestRescheduleMessages(t *testing.T) {
 	_, tsv, db := newTestTxExecutor(t)
 	defer db.Close()
@@ -1788,6 +2721,70 @@ func TestACLHUP(t *testing.T) {
 	}
 }
 
+func TestGetTableACL(t *testing.T) {
+	aclName := fmt.Sprintf("simpleacl-test-%d", rand.Int63())
+	tableacl.Register(aclName, &simpleacl.Factory{})
+	tableacl.SetDefaultACL(aclName)
+	config := &tableaclpb.Config{
+		TableGroups: []*tableaclpb.TableGroupSpec{{
+			Name:                 "group01",
+			TableNamesOrPrefixes: []string{"test_table1", "test_table2"},
+			Readers:              []string{"vt1"},
+			Writers:              []string{"vt2"},
+		}},
+	}
+	require.NoError(t, tableacl.InitFromProto(config))
+
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	info := tsv.GetTableACL()
+	require.Len(t, info.TableGroups, 2)
+	for i, table := range []string{"test_table1", "test_table2"} {
+		group := info.TableGroups[i]
+		assert.Equal(t, "group01", group.Name)
+		assert.Equal(t, []string{table}, group.TableNamesOrPrefixes)
+		assert.Equal(t, []string{"vt1"}, group.Readers)
+		assert.Equal(t, []string{"vt2"}, group.Writers)
+		assert.Empty(t, group.Admins)
+	}
+
+	// the handler renders the same ACL
+	request, _ := http.NewRequest("GET", "/debug/table_acl", nil)
+	response := httptest.NewRecorder()
+	tsv.tableACLHandler(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"table_names_or_prefixes":["test_table2"]`)
+}
+
+func TestMinPoolSize(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	config.MinPoolSize = 4
+	db, tsv := setupTabletServerTestCustom(t, config, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	poolSize, streamPoolSize, txPoolSize := tsv.PoolSize(), tsv.StreamPoolSize(), tsv.TxPoolSize()
+	for _, size := range []int{-1, 0, 1, 3} {
+		tsv.SetPoolSize(size)
+		tsv.SetStreamPoolSize(size)
+		tsv.SetTxPoolSize(size)
+		assert.Equal(t, poolSize, tsv.PoolSize(), "pool size %d is below the minimum", size)
+		assert.Equal(t, streamPoolSize, tsv.StreamPoolSize(), "stream pool size %d is below the minimum", size)
+		assert.Equal(t, txPoolSize, tsv.TxPoolSize(), "tx pool size %d is below the minimum", size)
+	}
+
+	for _, size := range []int{4, 5} {
+		tsv.SetPoolSize(size)
+		tsv.SetStreamPoolSize(size)
+		tsv.SetTxPoolSize(size)
+		assert.Equal(t, size, tsv.PoolSize())
+		assert.Equal(t, size, tsv.StreamPoolSize())
+		assert.Equal(t, size, tsv.TxPoolSize())
+	}
+}
+
 func TestConfigChanges(t *testing.T) {
 	db, tsv := setupTabletServerTest(t, "")
 	defer tsv.StopService()
@@ -1867,7 +2864,7 @@ func TestReserveBeginExecute(t *testing.T) {
 	expected := []string{
 		"select 43",
 		"begin",
-		"select 42 from dual limit 10001",
+		"select 42 from dual limit 100001",
 	}
 	splitOutput := strings.Split(db.QueryLog(), ";")
 	for _, exp := range expected {
@@ -1889,7 +2886,7 @@ func TestReserveExecute_WithoutTx(t *testing.T) {
 	assert.NotEqual(t, int64(0), state.ReservedID, "reservedID should not be zero")
 	expected := []string{
 		"select 43",
-		"select 42 from dual limit 10001",
+		"select 42 from dual limit 100001",
 	}
 	splitOutput := strings.Split(db.QueryLog(), ";")
 	for _, exp := range expected {
@@ -1916,7 +2913,7 @@ func TestReserveExecute_WithTx(t *testing.T) {
 	assert.Equal(t, beginState.TransactionID, reserveState.ReservedID, "reservedID should be equal to transactionID")
 	expected := []string{
 		"select 43",
-		"select 42 from dual limit 10001",
+		"select 42 from dual limit 100001",
 	}
 	splitOutput := strings.Split(db.QueryLog(), ";")
 	for _, exp := range expected {
@@ -2054,6 +3051,291 @@ func TestReserveStats(t *testing.T) {
 	assert.NotEmpty(t, tsv.te.txPool.env.Stats().UserReservedTimesNs.Counts()["test"])
 }
 
+func TestSetKeyspaceReadOnly(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "ks")
+	defer tsv.StopService()
+	defer db.Close()
+
+	readSQL := "select * from test_table limit 1000"
+	db.AddQuery(readSQL, &sqltypes.Result{})
+	writeSQL := "update test_table set `name` = 2 where pk = 1"
+	ksTarget := &querypb.Target{Keyspace: "ks", TabletType: topodatapb.TabletType_PRIMARY}
+	otherTarget := &querypb.Target{Keyspace: "other", TabletType: topodatapb.TabletType_PRIMARY}
+
+	execWrite := func(target *querypb.Target) error {
+		state, err := tsv.Begin(ctx, target, nil)
+		require.NoError(t, err)
+		defer tsv.Rollback(ctx, target, state.TransactionID)
+		_, err = tsv.Execute(ctx, target, writeSQL, nil, state.TransactionID, 0, nil)
+		return err
+	}
+
+	require.NoError(t, execWrite(ksTarget))
+
+	require.NoError(t, tsv.SetKeyspaceReadOnly("ks", true))
+	assert.Equal(t, []string{"ks"}, tsv.ReadOnlyKeyspaces())
+
+	// the plan cached for the other keyspace must not be reused for the read-only one.
+	require.NoError(t, execWrite(otherTarget))
+	err := execWrite(ksTarget)
+	require.ErrorContains(t, err, "disallowed due to rule: keyspace_read_only_ks")
+	err = execWrite(ksTarget)
+	require.ErrorContains(t, err, "disallowed due to rule: keyspace_read_only_ks")
+	_, err = tsv.Execute(ctx, ksTarget, readSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	require.NoError(t, execWrite(otherTarget))
+
+	require.NoError(t, tsv.SetKeyspaceReadOnly("ks", false))
+	assert.Empty(t, tsv.ReadOnlyKeyspaces())
+	require.NoError(t, execWrite(ksTarget))
+}
+
+func TestSetTableWritable(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "ks")
+	defer tsv.StopService()
+	defer db.Close()
+
+	readSQL := "select * from test_table limit 1000"
+	db.AddQuery(readSQL, &sqltypes.Result{})
+	writeSQL := "update test_table set `name` = 2 where pk = 1"
+	ksTarget := &querypb.Target{Keyspace: "ks", TabletType: topodatapb.TabletType_PRIMARY}
+	otherTarget := &querypb.Target{Keyspace: "other", TabletType: topodatapb.TabletType_PRIMARY}
+
+	execWrite := func(target *querypb.Target) error {
+		state, err := tsv.Begin(ctx, target, nil)
+		require.NoError(t, err)
+		defer tsv.Rollback(ctx, target, state.TransactionID)
+		_, err = tsv.Execute(ctx, target, writeSQL, nil, state.TransactionID, 0, nil)
+		return err
+	}
+
+	require.Error(t, tsv.SetTableWritable("test_table", false))
+	require.NoError(t, tsv.SetTableWritable("ks.test_table", false))
+	assert.Equal(t, []string{"ks.test_table"}, tsv.WriteDisabledTables())
+
+	err := execWrite(ksTarget)
+	require.ErrorContains(t, err, "disallowed due to rule: table_write_disabled_ks.test_table")
+	_, err = tsv.Execute(ctx, ksTarget, readSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+	// the table of the same name in another database is still writable
+	require.NoError(t, execWrite(otherTarget))
+
+	require.NoError(t, tsv.SetTableWritable("ks.test_table", true))
+	assert.Empty(t, tsv.WriteDisabledTables())
+	require.NoError(t, execWrite(ksTarget))
+}
+
+func TestQueryListOverrides(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	config.QueryListOverrides = []string{"olap:test_table", "oltp-stateful:select * from msg where id = :id"}
+	db, tsv := setupTabletServerTestCustom(t, config, "")
+	defer tsv.StopService()
+	defer db.Close()
+	target := &querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	queryLists := func() []int {
+		return []int{tsv.statelessql.Len(), tsv.statefulql.Len(), tsv.olapql.Len()}
+	}
+
+	// a query on a pinned table lands in the olap list instead of the stateless one
+	sql := "select * from test_table limit 1000"
+	db.AddQuery(sql, &sqltypes.Result{})
+	var got []int
+	db.SetBeforeFunc(sql, func() { got = queryLists() })
+	_, err := tsv.Execute(ctx, target, sql, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 0, 1}, got)
+
+	// a pinned query is matched by its normalized SQL
+	sql = "select * from msg where id = :id"
+	db.AddQuery("/* pinned */ select * from msg where id = 1 limit 100001", &sqltypes.Result{})
+	db.SetBeforeFunc("/* pinned */ select * from msg where id = 1 limit 100001", func() { got = queryLists() })
+	_, err = tsv.Execute(ctx, target, "/* pinned */ "+sql, map[string]*querypb.BindVariable{"id": sqltypes.Int64BindVariable(1)}, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int{0, 1, 0}, got)
+
+	// the other queries keep their query list
+	sql = "select * from msg where id = 2"
+	db.AddQuery(sql+" limit 100001", &sqltypes.Result{})
+	db.SetBeforeFunc(sql+" limit 100001", func() { got = queryLists() })
+	_, err = tsv.Execute(ctx, target, sql, nil, 0, 0, nil)
+	require.NoError(t, err)
+	assert.Equal(t, []int{1, 0, 0}, got)
+}
+
+func TestDiskFullReadOnly(t *testing.T) {
+	_, tsv, db := newTestTxExecutor(t)
+	defer db.Close()
+	defer tsv.StopService()
+	tsv.config.DiskFullReadOnlyThreshold = 2
+	tsv.config.DiskFullProbeIntervalSeconds = 0.01
+
+	readSQL := "select * from test_table limit 1000"
+	db.AddQuery(readSQL, &sqltypes.Result{})
+	writeSQL := "update test_table set `name` = 2 where pk = 1"
+	diskFullErr := mysql.NewSQLError(mysql.ERDiskFull, mysql.SSUnknownSQLState, "Disk full")
+	db.AddRejectedQuery(writeSQL+" limit 100001", diskFullErr)
+	db.AddRejectedQuery(diskFullProbeQueries[1], diskFullErr)
+	target := &querypb.Target{TabletType: topodatapb.TabletType_PRIMARY}
+
+	execWrite := func() error {
+		state, err := tsv.Begin(ctx, target, nil)
+		require.NoError(t, err)
+		defer tsv.Rollback(ctx, target, state.TransactionID)
+		_, err = tsv.Execute(ctx, target, writeSQL, nil, state.TransactionID, 0, nil)
+		return err
+	}
+
+	// the writes keep failing with the error of MySQL until the threshold is reached
+	err := execWrite()
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.Code(err))
+	assert.False(t, tsv.IsDiskFullReadOnly())
+	err = execWrite()
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_RESOURCE_EXHAUSTED, vterrors.Code(err))
+	assert.True(t, tsv.IsDiskFullReadOnly())
+
+	// then they're rejected without reaching MySQL, while the reads still work
+	err = execWrite()
+	require.ErrorContains(t, err, "disallowed due to rule: disk_full_read_only")
+	_, err = tsv.Execute(ctx, target, readSQL, nil, 0, 0, nil)
+	require.NoError(t, err)
+
+	// the writes are accepted again once the probe succeeds
+	db.DeleteRejectedQuery(writeSQL + " limit 100001")
+	db.DeleteRejectedQuery(diskFullProbeQueries[1])
+	db.AddQuery(writeSQL+" limit 100001", &sqltypes.Result{RowsAffected: 1})
+	db.AddQuery(diskFullProbeQueries[1], &sqltypes.Result{})
+	assert.Eventually(t, func() bool {
+		return !tsv.IsDiskFullReadOnly()
+	}, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, execWrite())
+}
+
+func TestDiskFullProbeStopsWithService(t *testing.T) {
+	_, tsv, db := newTestTxExecutor(t)
+	defer db.Close()
+	tsv.config.DiskFullReadOnlyThreshold = 1
+	tsv.config.DiskFullProbeIntervalSeconds = 0.01
+
+	// a table reaching its own size limit doesn't mean the disk is full
+	tsv.recordDiskFullError(mysql.NewSQLError(mysql.ERRecordFileFull, mysql.SSUnknownSQLState, "The table 't' is full"))
+	assert.False(t, tsv.IsDiskFullReadOnly())
+
+	db.AddRejectedQuery(diskFullProbeQueries[1], mysql.NewSQLError(mysql.ERDiskFull, mysql.SSUnknownSQLState, "Disk full"))
+	tsv.recordDiskFullError(mysql.NewSQLError(mysql.ERDiskFull, mysql.SSUnknownSQLState, "Disk full"))
+	require.True(t, tsv.IsDiskFullReadOnly())
+	assert.Eventually(t, func() bool {
+		return db.GetQueryCalledNum(diskFullProbeQueries[1]) > 0
+	}, 5*time.Second, 10*time.Millisecond)
+
+	// the probe stops with the query service, and the writes are accepted once it starts again
+	tsv.StopService()
+	assert.False(t, tsv.IsDiskFullReadOnly())
+	// a probe already running when the service stops still completes
+	time.Sleep(50 * time.Millisecond)
+	probes := db.GetQueryCalledNum(diskFullProbeQueries[1])
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, probes, db.GetQueryCalledNum(diskFullProbeQueries[1]))
+}
+
+func TestIsHealthyRetriesTransientErrors(t *testing.T) {
+	config := tabletenv.NewDefaultConfig()
+	config.Healthcheck.Retries = 2
+	config.Healthcheck.RetryIntervalSeconds = 0.1
+	db, tsv := setupTabletServerTestCustom(t, config, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	healthSQL := "/* health */ select 1 from dual limit 100001"
+	db.AddQuery(healthSQL, &sqltypes.Result{})
+	db.AddRejectedQuery(healthSQL, mysql.NewSQLError(mysql.CRServerGone, mysql.SSUnknownSQLState, "server has gone away"))
+
+	// the first probe fails transiently, the connection reconnects once before giving up,
+	// and then the retry succeeds
+	go func() {
+		for db.GetQueryCalledNum(healthSQL) < 2 {
+			time.Sleep(time.Millisecond)
+		}
+		db.DeleteRejectedQuery(healthSQL)
+	}()
+	require.NoError(t, tsv.IsHealthy())
+	assert.Equal(t, 3, db.GetQueryCalledNum(healthSQL))
+
+	// a persistent failure is reported once the retries are exhausted
+	db.AddRejectedQuery(healthSQL, mysql.NewSQLError(mysql.CRServerGone, mysql.SSUnknownSQLState, "server has gone away"))
+	err := tsv.IsHealthy()
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_UNAVAILABLE, vterrors.Code(err))
+	assert.Equal(t, 3+2*3, db.GetQueryCalledNum(healthSQL))
+
+	// an error which isn't transient is reported right away
+	db.AddRejectedQuery(healthSQL, mysql.NewSQLError(mysql.ERAccessDeniedError, mysql.SSAccessDeniedError, "access denied"))
+	err = tsv.IsHealthy()
+	require.Error(t, err)
+	assert.Equal(t, vtrpcpb.Code_PERMISSION_DENIED, vterrors.Code(err))
+	assert.Equal(t, 3+2*3+1, db.GetQueryCalledNum(healthSQL))
+}
+
+func TestQueryRulesHandler(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	bufferingCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tsv.onlineDDLExecutorToggleTableBuffer(bufferingCtx, "test_table", true)
+
+	request, _ := http.NewRequest("GET", "/debug/query-rules", nil)
+	response := httptest.NewRecorder()
+	tsv.qe.handleHTTPQueryRulesForMonitoring(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+
+	var sources map[string][]map[string]any
+	require.NoError(t, json.Unmarshal(response.Body.Bytes(), &sources))
+	require.Contains(t, sources, "onlineddl/test_table")
+	require.Len(t, sources["onlineddl/test_table"], 1)
+	bufferRule := sources["onlineddl/test_table"][0]
+	assert.Equal(t, "BUFFER", bufferRule["Action"])
+	assert.Equal(t, []any{"test_table"}, bufferRule["FullyQualifiedTableNames"])
+	// the other registered sources are listed too
+	assert.Contains(t, sources, keyspaceReadOnlyQueryRuleSource)
+
+	tsv.onlineDDLExecutorToggleTableBuffer(bufferingCtx, "test_table", false)
+	response = httptest.NewRecorder()
+	tsv.qe.handleHTTPQueryRulesForMonitoring(response, request)
+	assert.NotContains(t, response.Body.String(), "onlineddl/test_table")
+}
+
+func TestBufferedTables(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "")
+	defer tsv.StopService()
+	defer db.Close()
+
+	assert.Empty(t, tsv.BufferedTables())
+
+	bufferingCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	tsv.onlineDDLExecutorToggleTableBuffer(bufferingCtx, "test_table", true)
+	time.Sleep(10 * time.Millisecond)
+
+	tables := tsv.BufferedTables()
+	require.Len(t, tables, 1)
+	assert.Equal(t, "test_table", tables[0].Table)
+	assert.GreaterOrEqual(t, tables[0].BufferingDurationInMs, int64(10))
+
+	// the handler renders the same tables
+	request, _ := http.NewRequest("GET", "/debug/buffered_tables", nil)
+	response := httptest.NewRecorder()
+	tsv.bufferedTablesHandler(response, request)
+	require.Equal(t, http.StatusOK, response.Code)
+	assert.Contains(t, response.Body.String(), `"table":"test_table"`)
+
+	tsv.onlineDDLExecutorToggleTableBuffer(bufferingCtx, "test_table", false)
+	assert.Empty(t, tsv.BufferedTables())
+}
+
 func TestDatabaseNameReplaceByKeyspaceNameExecuteMethod(t *testing.T) {
 	db, tsv := setupTabletServerTest(t, "keyspaceName")
 	setDBName(db, tsv, "databaseInMysql")
@@ -2090,6 +3372,41 @@ func TestDatabaseNameReplaceByKeyspaceNameExecuteMethod(t *testing.T) {
 	require.NoError(t, err)
 }
 
+func TestDatabaseNameReplaceByKeyspaceNameCounter(t *testing.T) {
+	db, tsv := setupTabletServerTest(t, "keyspaceName")
+	setDBName(db, tsv, "databaseInMysql")
+	defer tsv.StopService()
+	defer db.Close()
+
+	executeSQL := "select * from test_table limit 1000"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Type: sqltypes.VarBinary, Database: "databaseInMysql"}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewVarBinary("row01")}},
+	})
+	target := tsv.sm.target
+	rewritten := tsv.stats.KeyspaceRewrites.Counts()["Rewritten"]
+
+	res, err := tsv.Execute(ctx, target, executeSQL, nil, 0, 0, &querypb.ExecuteOptions{
+		IncludedFields: querypb.ExecuteOptions_ALL,
+	})
+	require.NoError(t, err)
+	require.Equal(t, "keyspaceName", res.Fields[0].Database)
+	assert.EqualValues(t, rewritten+1, tsv.stats.KeyspaceRewrites.Counts()["Rewritten"])
+	assert.Zero(t, tsv.stats.KeyspaceRewrites.Counts()["NotRewritten"])
+
+	// a result without the database name is not counted
+	executeSQL = "select 1 from dual"
+	db.AddQuery(executeSQL, &sqltypes.Result{
+		Fields: []*querypb.Field{{Name: "1", Type: sqltypes.Int64}},
+		Rows:   [][]sqltypes.Value{{sqltypes.NewInt64(1)}},
+	})
+	_, err = tsv.Execute(ctx, target, executeSQL, nil, 0, 0, &querypb.ExecuteOptions{
+		IncludedFields: querypb.ExecuteOptions_ALL,
+	})
+	require.NoError(t, err)
+	assert.EqualValues(t, rewritten+1, tsv.stats.KeyspaceRewrites.Counts()["Rewritten"])
+}
+
 func TestDatabaseNameReplaceByKeyspaceNameStreamExecuteMethod(t *testing.T) {
 	db, tsv := setupTabletServerTest(t, "keyspaceName")
 	setDBName(db, tsv, "databaseInMysql")
@@ -2276,17 +3593,17 @@ func setupFakeDB(t *testing.T) *fakesqldb.DB {
 func addTabletServerSupportedQueries(db *fakesqldb.DB) {
 	queryResultMap := map[string]*sqltypes.Result{
 		// Queries for how row protection test (txserializer).
-		"update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 10001": {
+		"update test_table set name_string = 'tx1' where pk = 1 and `name` = 1 limit 100001": {
 			RowsAffected: 1,
 		},
-		"update test_table set name_string = 'tx2' where pk = 1 and `name` = 1 limit 10001": {
+		"update test_table set name_string = 'tx2' where pk = 1 and `name` = 1 limit 100001": {
 			RowsAffected: 1,
 		},
-		"update test_table set name_string = 'tx3' where pk = 1 and `name` = 1 limit 10001": {
+		"update test_table set name_string = 'tx3' where pk = 1 and `name` = 1 limit 100001": {
 			RowsAffected: 1,
 		},
 		// tx3, but with different primary key.
-		"update test_table set name_string = 'tx3' where pk = 2 and `name` = 1 limit 10001": {
+		"update test_table set name_string = 'tx3' where pk = 2 and `name` = 1 limit 100001": {
 			RowsAffected: 1,
 		},
 		// queries for schema info
@@ -2336,7 +3653,7 @@ func addTabletServerSupportedQueries(db *fakesqldb.DB) {
 				Type: sqltypes.Int32,
 			}},
 		},
-		"select 42 from dual limit 10001": {
+		"select 42 from dual limit 100001": {
 			Fields: []*querypb.Field{{
 				Name: "42",
 				Type: sqltypes.Int32,
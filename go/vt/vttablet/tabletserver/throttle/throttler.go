@@ -140,6 +140,7 @@ type Throttler struct {
 	mysqlClusterThresholds *cache.Cache
 	aggregatedMetrics      *cache.Cache
 	throttledApps          *cache.Cache
+	exemptedApps           *cache.Cache
 	recentApps             *cache.Cache
 	metricsHealth          *cache.Cache
 
@@ -192,6 +193,7 @@ func NewThrottler(env tabletenv.Env, srvTopoServer srvtopo.Server, ts *topo.Serv
 	throttler.mysqlInventory = mysql.NewInventory()
 
 	throttler.throttledApps = cache.New(cache.NoExpiration, 0)
+	throttler.exemptedApps = cache.New(cache.NoExpiration, time.Minute)
 	throttler.mysqlClusterThresholds = cache.New(cache.NoExpiration, 0)
 	throttler.aggregatedMetrics = cache.New(aggregatedMetricsExpiration, 0)
 	throttler.recentApps = cache.New(recentAppsExpiration, 0)
@@ -255,6 +257,22 @@ func (throttler *Throttler) GetMetricsQuery() string {
 	return throttler.metricsQuery.Load().(string)
 }
 
+// SetMetricsQuery changes the metric the throttler evaluates. The query is either a `SELECT` returning
+// a single row with a single value, or a `SHOW GLOBAL ... LIKE ...`. An empty query restores the default
+// metric, the replication lag. The threshold is kept, so it should be changed with MetricsThreshold to
+// suit the new metric. The probes use the new query from the next refresh of the inventory.
+// If the throttler config is read from the topo, it overrides the query on its next change.
+func (throttler *Throttler) SetMetricsQuery(query string) error {
+	if query == "" {
+		query = replicationLagQuery
+	}
+	if mysql.GetMetricsQueryType(query) == mysql.MetricsQueryTypeUnknown {
+		return fmt.Errorf("unsupported metrics query type for query: %s", query)
+	}
+	throttler.metricsQuery.Store(query)
+	return nil
+}
+
 // initThrottler initializes config
 func (throttler *Throttler) initConfig() {
 	log.Infof("Throttler: initializing config")
@@ -927,6 +945,34 @@ func (throttler *Throttler) IsAppThrottled(appName string) bool {
 	return false
 }
 
+// ExemptApp exempts an app from throttling until the given time. Checks made by an exempted app
+// pass regardless of the collected metrics. The exemption expires on its own.
+func (throttler *Throttler) ExemptApp(appName string, expireAt time.Time) {
+	d := time.Until(expireAt)
+	if d <= 0 {
+		throttler.exemptedApps.Delete(appName)
+		return
+	}
+	throttler.exemptedApps.Set(appName, expireAt, d)
+}
+
+// IsAppExempted tells whether some app, or any of its ":" separated components, is currently
+// exempted from throttling
+func (throttler *Throttler) IsAppExempted(appName string) bool {
+	if _, found := throttler.exemptedApps.Get(appName); found {
+		return true
+	}
+	for _, singleAppName := range strings.Split(appName, ":") {
+		if singleAppName == "" {
+			continue
+		}
+		if _, found := throttler.exemptedApps.Get(singleAppName); found {
+			return true
+		}
+	}
+	return false
+}
+
 // ThrottledAppsMap returns a (copy) map of currently throttled apps
 func (throttler *Throttler) ThrottledAppsMap() (result map[string](*base.AppThrottle)) {
 	result = make(map[string](*base.AppThrottle))
@@ -1008,6 +1054,9 @@ func (throttler *Throttler) checkSelf(ctx context.Context, appName string, remot
 
 // CheckByType runs a check by requested check type
 func (throttler *Throttler) CheckByType(ctx context.Context, appName string, remoteAddr string, flags *CheckFlags, checkType ThrottleCheckType) (checkResult *CheckResult) {
+	if throttler.IsAppExempted(appName) {
+		return okMetricCheckResult
+	}
 	if throttler.IsEnabled() && !flags.SkipRequestHeartbeats {
 		go throttler.heartbeatWriter.RequestHeartbeats()
 	}
@@ -0,0 +1,90 @@
+/*
+ Copyright 2017 GitHub Inc.
+
+ Licensed under MIT License. See https://github.com/github/freno/blob/master/LICENSE
+*/
+
+package throttle
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"vitess.io/vitess/go/mysql/fakesqldb"
+	"vitess.io/vitess/go/sqltypes"
+	"vitess.io/vitess/go/vt/dbconfigs"
+	topodatapb "vitess.io/vitess/go/vt/proto/topodata"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/tabletenv"
+	"vitess.io/vitess/go/vt/vttablet/tabletserver/throttle/mysql"
+)
+
+func TestExemptApp(t *testing.T) {
+	env := tabletenv.NewEnv(tabletenv.NewDefaultConfig(), "ThrottlerTest")
+	tabletTypeFunc := func() topodatapb.TabletType { return topodatapb.TabletType_PRIMARY }
+	throttler := NewThrottler(env, nil, nil, "cell", nil, tabletTypeFunc)
+	atomic.StoreInt64(&throttler.isEnabled, 1)
+
+	ctx := context.Background()
+	flags := &CheckFlags{SkipRequestHeartbeats: true}
+	check := func(appName string) int {
+		return throttler.CheckByType(ctx, appName, "", flags, ThrottleCheckPrimaryWrite).StatusCode
+	}
+
+	// no metrics have been collected, so checks fail
+	assert.NotEqual(t, http.StatusOK, check("critical-job"))
+
+	throttler.ExemptApp("critical-job", time.Now().Add(200*time.Millisecond))
+	assert.True(t, throttler.IsAppExempted("critical-job"))
+	assert.True(t, throttler.IsAppExempted("critical-job:uuid"))
+	assert.False(t, throttler.IsAppExempted("other-job"))
+	assert.Equal(t, http.StatusOK, check("critical-job"))
+	assert.NotEqual(t, http.StatusOK, check("other-job"))
+
+	time.Sleep(300 * time.Millisecond)
+	assert.False(t, throttler.IsAppExempted("critical-job"))
+	assert.NotEqual(t, http.StatusOK, check("critical-job"))
+}
+
+func TestSetMetricsQuery(t *testing.T) {
+	db := fakesqldb.New(t)
+	defer db.Close()
+	params, _ := db.ConnParams().MysqlParams()
+	config := tabletenv.NewDefaultConfig()
+	config.DB = dbconfigs.NewTestDBConfigs(*params, *params, "fakesqldb")
+	env := tabletenv.NewEnv(config, "ThrottlerTest")
+	tabletTypeFunc := func() topodatapb.TabletType { return topodatapb.TabletType_PRIMARY }
+	throttler := NewThrottler(env, nil, nil, "cell", nil, tabletTypeFunc)
+	throttler.pool.Open(config.DB.AppWithDB(), config.DB.DbaWithDB(), config.DB.AppDebugWithDB())
+	defer throttler.pool.Close()
+
+	assert.Equal(t, replicationLagQuery, throttler.GetMetricsQuery())
+
+	// the throttler evaluates the custom metric instead of the replication lag
+	require.NoError(t, throttler.SetMetricsQuery("show global status like 'threads_running'"))
+	assert.Equal(t, "show global status like 'threads_running'", throttler.GetMetricsQuery())
+	db.AddQuery("show global status like 'threads_running'", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("Variable_name|Value", "varchar|varchar"), "Threads_running|12"))
+	metric := throttler.readSelfMySQLThrottleMetric(context.Background(), &mysql.Probe{MetricQuery: throttler.GetMetricsQuery()})
+	require.NoError(t, metric.Err)
+	assert.Equal(t, 12.0, metric.Value)
+
+	require.NoError(t, throttler.SetMetricsQuery("select avg(load) from metrics.load"))
+	db.AddQuery("select avg(load) from metrics.load", sqltypes.MakeTestResult(
+		sqltypes.MakeTestFields("avg(load)", "float64"), "0.75"))
+	metric = throttler.readSelfMySQLThrottleMetric(context.Background(), &mysql.Probe{MetricQuery: throttler.GetMetricsQuery()})
+	require.NoError(t, metric.Err)
+	assert.Equal(t, 0.75, metric.Value)
+
+	assert.ErrorContains(t, throttler.SetMetricsQuery("delete from metrics.load"), "unsupported metrics query type")
+	assert.Equal(t, "select avg(load) from metrics.load", throttler.GetMetricsQuery())
+
+	// an empty query restores the replication lag
+	require.NoError(t, throttler.SetMetricsQuery(""))
+	assert.Equal(t, replicationLagQuery, throttler.GetMetricsQuery())
+}
@@ -54,6 +54,7 @@ type (
 		StartTime       time.Time
 		EndTime         time.Time
 		Queries         []string
+		WrittenTables   []string
 		Autocommit      bool
 		Conclusion      string
 		LogToFile       bool
@@ -122,6 +123,14 @@ func (p *Properties) RecordQuery(query string) {
 	p.Queries = append(p.Queries, query)
 }
 
+// RecordWrittenTables records the tables written to by this transaction.
+func (p *Properties) RecordWrittenTables(tables ...string) {
+	if p == nil {
+		return
+	}
+	p.WrittenTables = append(p.WrittenTables, tables...)
+}
+
 // InTransaction returns true as soon as this struct is not nil
 func (p *Properties) InTransaction() bool { return p != nil }
 
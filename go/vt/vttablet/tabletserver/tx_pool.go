@@ -78,6 +78,9 @@ type (
 		logMu   sync.Mutex
 		lastLog time.Time
 		txStats *servenv.TimingsWrapper
+
+		// onCommit, if set, is called with the tables written to by a transaction when it commits.
+		onCommit func(tables ...string)
 	}
 )
 
@@ -99,6 +102,7 @@ func NewTxPool(env tabletenv.Env, limiter txlimiter.TxLimiter) *TxPool {
 	env.Exporter().NewGaugeDurationFunc("TransactionTimeout", "Transaction timeout", func() time.Duration {
 		return config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLTP)
 	})
+	env.Exporter().NewGaugeDurationFunc("ReservedConnectionOldestAge", "Age of the oldest reserved connection", axp.scp.OldestReservedAge)
 	return axp
 }
 
@@ -136,25 +140,55 @@ func (tp *TxPool) transactionKiller() {
 	defer tp.env.LogError()
 	for _, conn := range tp.scp.GetElapsedTimeout(vterrors.TxKillerRollback) {
 		log.Warningf("killing transaction (exceeded timeout: %v): %s", conn.timeout, conn.String(tp.env.Config().SanitizeLogMessages))
-		switch {
-		case conn.IsTainted():
-			conn.Close()
-			tp.env.Stats().KillCounters.Add("ReservedConnection", 1)
-		case conn.IsInTransaction():
-			_, err := conn.Exec(context.Background(), "rollback", 1, false)
-			if err != nil {
-				conn.Close()
-			}
-			tp.env.Stats().KillCounters.Add("Transactions", 1)
+		tp.killConn(conn)
+		conn.Releasef("exceeded timeout: %v", conn.timeout)
+	}
+	maxDuration := tp.env.Config().TxMaxDurationSeconds.Get()
+	for _, conn := range tp.scp.GetExceededMaxDuration(vterrors.TxKillerRollback, maxDuration) {
+		log.Warningf("killing transaction (exceeded max duration: %v): %s", maxDuration, conn.String(tp.env.Config().SanitizeLogMessages))
+		tp.killConn(conn)
+		conn.Releasef("exceeded max duration: %v", maxDuration)
+	}
+	idleThreshold := tp.env.Config().ReservedConnIdleThresholdSeconds.Get()
+	for _, conn := range tp.scp.GetIdleReserved(vterrors.TxKillerRollback, idleThreshold) {
+		if tp.env.Config().ReservedConnIdleRelease {
+			log.Warningf("releasing reserved connection (idle longer than: %v): %s", idleThreshold, conn.String(tp.env.Config().SanitizeLogMessages))
+			tp.env.Stats().IdleReservedCount.Add("Released", 1)
+			tp.killConn(conn)
+			conn.Releasef("reserved connection idle longer than: %v", idleThreshold)
+			continue
 		}
-		// For logging, as transaction is killed as the connection is closed.
-		if conn.IsTainted() && conn.IsInTransaction() {
-			tp.env.Stats().KillCounters.Add("Transactions", 1)
+		// Only report each idle connection once, until it's used again.
+		if !conn.idleReported {
+			conn.idleReported = true
+			log.Warningf("reserved connection idle longer than %v, it may have been leaked: %s", idleThreshold, conn.String(tp.env.Config().SanitizeLogMessages))
+			tp.env.Stats().IdleReservedCount.Add("Reported", 1)
 		}
-		if conn.IsInTransaction() {
-			tp.txComplete(conn, tx.TxKill)
+		// Put the connection back without counting it as used.
+		tp.scp.markAsNotInUse(conn, false)
+	}
+}
+
+// killConn rolls back the transaction on the connection, or closes the
+// connection if it is tainted. The caller is responsible for releasing it.
+func (tp *TxPool) killConn(conn *StatefulConnection) {
+	switch {
+	case conn.IsTainted():
+		conn.Close()
+		tp.env.Stats().KillCounters.Add("ReservedConnection", 1)
+	case conn.IsInTransaction():
+		_, err := conn.Exec(context.Background(), "rollback", 1, false)
+		if err != nil {
+			conn.Close()
 		}
-		conn.Releasef("exceeded timeout: %v", conn.timeout)
+		tp.env.Stats().KillCounters.Add("Transactions", 1)
+	}
+	// For logging, as transaction is killed as the connection is closed.
+	if conn.IsTainted() && conn.IsInTransaction() {
+		tp.env.Stats().KillCounters.Add("Transactions", 1)
+	}
+	if conn.IsInTransaction() {
+		tp.txComplete(conn, tx.TxKill)
 	}
 }
 
@@ -181,6 +215,15 @@ func (tp *TxPool) GetAndLock(connID tx.ConnID, reason string) (*StatefulConnecti
 	if err != nil {
 		return nil, vterrors.Errorf(vtrpcpb.Code_ABORTED, "transaction %d: %v", connID, err)
 	}
+	// The tx killer only checks the max duration periodically, so make sure a
+	// transaction that has exceeded it cannot execute any more statements.
+	maxDuration := tp.env.Config().TxMaxDurationSeconds.Get()
+	if conn.ExceededMaxDuration(maxDuration) {
+		log.Warningf("killing transaction (exceeded max duration: %v): %s", maxDuration, conn.String(tp.env.Config().SanitizeLogMessages))
+		tp.killConn(conn)
+		conn.Releasef("exceeded max duration: %v", maxDuration)
+		return nil, vterrors.Errorf(vtrpcpb.Code_ABORTED, "transaction %d: exceeded max duration: %v", connID, maxDuration)
+	}
 	return conn, nil
 }
 
@@ -461,6 +504,9 @@ func (tp *TxPool) LogActive() {
 }
 
 func (tp *TxPool) txComplete(conn *StatefulConnection, reason tx.ReleaseReason) {
+	if reason == tx.TxCommit && tp.onCommit != nil {
+		tp.onCommit(conn.TxProperties().WrittenTables...)
+	}
 	conn.LogTransaction(reason)
 	tp.limiter.Release(conn.TxProperties().ImmediateCaller, conn.TxProperties().EffectiveCaller)
 	conn.CleanTxState()
@@ -468,8 +514,14 @@ func (tp *TxPool) txComplete(conn *StatefulConnection, reason tx.ReleaseReason)
 
 func txKillerTimeoutInterval(config *tabletenv.TabletConfig) time.Duration {
 	return smallerTimeout(
-		config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP),
-		config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLTP),
+		smallerTimeout(
+			config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLAP),
+			config.TxTimeoutForWorkload(querypb.ExecuteOptions_OLTP),
+		),
+		smallerTimeout(
+			config.TxMaxDurationSeconds.Get(),
+			config.ReservedConnIdleThresholdSeconds.Get(),
+		),
 	) / 10
 }
 
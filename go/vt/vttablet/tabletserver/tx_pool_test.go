@@ -464,6 +464,60 @@ func TestTxTimeoutDoesNotKillShortLivedTransactions(t *testing.T) {
 	require.Equal(t, int64(0), txPool.env.Stats().KillCounters.Counts()["Transactions"]-startingKills)
 }
 
+func TestTxMaxDurationKillsActiveTransactions(t *testing.T) {
+	env := newEnv("TabletServerTest")
+	env.Config().TxPool.Size = 1
+	env.Config().TxPool.MaxWaiters = 0
+	env.Config().TxMaxDurationSeconds = 1
+	_, txPool, _, closer := setupWithEnv(t, env)
+	defer closer()
+	startingKills := txPool.env.Stats().KillCounters.Counts()["Transactions"]
+
+	// Start a transaction that keeps executing statements, so that its timeout
+	// is never reached.
+	conn, _, _, err := txPool.Begin(ctx, &querypb.ExecuteOptions{}, false, 0, nil, nil)
+	require.NoError(t, err)
+	connID := conn.ReservedID()
+	conn.Unlock()
+	for i := 0; i < 4; i++ {
+		time.Sleep(200 * time.Millisecond)
+		conn, err = txPool.GetAndLock(connID, "for query")
+		require.NoError(t, err)
+		_, err = conn.Exec(ctx, "select 1", 1, false)
+		require.NoError(t, err)
+		conn.Unlock()
+	}
+
+	// Let it exceed the max duration and get killed by the tx killer.
+	time.Sleep(500 * time.Millisecond)
+	require.Equal(t, int64(1), txPool.env.Stats().KillCounters.Counts()["Transactions"]-startingKills)
+	_, err = txPool.GetAndLock(connID, "for query")
+	require.Error(t, err)
+}
+
+func TestTxMaxDurationRejectsStatements(t *testing.T) {
+	env := newEnv("TabletServerTest")
+	env.Config().TxPool.Size = 1
+	env.Config().TxPool.MaxWaiters = 0
+	env.Config().TxMaxDurationSeconds = 1
+	_, txPool, _, closer := setupWithEnv(t, env)
+	defer closer()
+	// Stop the tx killer, the transaction must be rolled back on its next statement.
+	txPool.ticks.Stop()
+	startingKills := txPool.env.Stats().KillCounters.Counts()["Transactions"]
+
+	conn, _, _, err := txPool.Begin(ctx, &querypb.ExecuteOptions{}, false, 0, nil, nil)
+	require.NoError(t, err)
+	connID := conn.ReservedID()
+	conn.Unlock()
+
+	time.Sleep(1200 * time.Millisecond)
+	_, err = txPool.GetAndLock(connID, "for query")
+	require.ErrorContains(t, err, "exceeded max duration")
+	require.Equal(t, vtrpcpb.Code_ABORTED, vterrors.Code(err))
+	require.Equal(t, int64(1), txPool.env.Stats().KillCounters.Counts()["Transactions"]-startingKills)
+}
+
 func TestTxTimeoutKillsOlapTransactions(t *testing.T) {
 	env := newEnv("TabletServerTest")
 	env.Config().TxPool.Size = 1
@@ -579,6 +633,72 @@ func TestTxTimeoutReservedConn(t *testing.T) {
 	require.Equal(t, int64(1), txPool.env.Stats().KillCounters.Counts()["Transactions"]-startingTxKills)
 }
 
+func TestIdleReservedConnReported(t *testing.T) {
+	env := newEnv("TabletServerTest")
+	env.Config().ReservedConnIdleThresholdSeconds = 0.5
+	_, txPool, _, closer := setupWithEnv(t, env)
+	defer closer()
+	startingReported := txPool.env.Stats().IdleReservedCount.Counts()["Reported"]
+
+	conn, err := txPool.scp.NewConn(ctx, &querypb.ExecuteOptions{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.Taint(ctx, nil))
+	connID := conn.ReservedID()
+	conn.Unlock()
+
+	// Let it idle past the threshold, it's reported once but not released.
+	time.Sleep(1200 * time.Millisecond)
+	require.Equal(t, int64(1), txPool.env.Stats().IdleReservedCount.Counts()["Reported"]-startingReported)
+	require.Greater(t, txPool.scp.OldestReservedAge(), time.Second)
+	conn, err = txPool.GetAndLock(connID, "for query")
+	require.NoError(t, err)
+
+	// Using it again makes it reportable again.
+	conn.Unlock()
+	time.Sleep(1200 * time.Millisecond)
+	require.Equal(t, int64(2), txPool.env.Stats().IdleReservedCount.Counts()["Reported"]-startingReported)
+	conn, err = txPool.GetAndLock(connID, "for query")
+	require.NoError(t, err)
+	conn.Releasef("test")
+	require.Zero(t, txPool.scp.OldestReservedAge())
+}
+
+func TestIdleReservedConnReleased(t *testing.T) {
+	env := newEnv("TabletServerTest")
+	env.Config().TxPool.Size = 1
+	env.Config().TxPool.MaxWaiters = 0
+	env.Config().ReservedConnIdleThresholdSeconds = 0.5
+	env.Config().ReservedConnIdleRelease = true
+	_, txPool, _, closer := setupWithEnv(t, env)
+	defer closer()
+	startingReleased := txPool.env.Stats().IdleReservedCount.Counts()["Released"]
+	startingRcKills := txPool.env.Stats().KillCounters.Counts()["ReservedConnection"]
+
+	conn, err := txPool.scp.NewConn(ctx, &querypb.ExecuteOptions{}, nil)
+	require.NoError(t, err)
+	require.NoError(t, conn.Taint(ctx, nil))
+	connID := conn.ReservedID()
+	conn.Unlock()
+
+	// A reserved connection in use is not idle.
+	time.Sleep(200 * time.Millisecond)
+	conn, err = txPool.GetAndLock(connID, "for query")
+	require.NoError(t, err)
+	time.Sleep(800 * time.Millisecond)
+	require.Equal(t, int64(0), txPool.env.Stats().IdleReservedCount.Counts()["Released"]-startingReleased)
+	conn.Unlock()
+
+	// Let it idle past the threshold, it's released and its pool connection is reclaimed.
+	time.Sleep(1000 * time.Millisecond)
+	require.Equal(t, int64(1), txPool.env.Stats().IdleReservedCount.Counts()["Released"]-startingReleased)
+	require.Equal(t, int64(1), txPool.env.Stats().KillCounters.Counts()["ReservedConnection"]-startingRcKills)
+	_, err = txPool.GetAndLock(connID, "for query")
+	require.Error(t, err)
+	conn, _, _, err = txPool.Begin(ctx, &querypb.ExecuteOptions{}, false, 0, nil, nil)
+	require.NoError(t, err)
+	txPool.RollbackAndRelease(ctx, conn)
+}
+
 func TestTxTimeoutReusedReservedConn(t *testing.T) {
 	env := newEnv("TabletServerTest")
 	env.Config().TxPool.Size = 1
@@ -19,6 +19,7 @@ package tabletserver
 import (
 	"errors"
 	"fmt"
+	"sort"
 	"sync"
 )
 
@@ -121,6 +122,22 @@ func (pp *TxPreparedPool) Forget(dtid string) {
 	delete(pp.reserved, dtid)
 }
 
+// Dtids returns the sorted dtids of the prepared transactions which are not resolved yet,
+// including the ones being committed and the ones whose commit failed.
+func (pp *TxPreparedPool) Dtids() []string {
+	pp.mu.Lock()
+	defer pp.mu.Unlock()
+	dtids := make([]string, 0, len(pp.conns)+len(pp.reserved))
+	for dtid := range pp.conns {
+		dtids = append(dtids, dtid)
+	}
+	for dtid := range pp.reserved {
+		dtids = append(dtids, dtid)
+	}
+	sort.Strings(dtids)
+	return dtids
+}
+
 // FetchAll removes all connections and returns them as a list.
 // It also forgets all reserved dtids.
 func (pp *TxPreparedPool) FetchAll() []*StatefulConnection {
@@ -124,6 +124,7 @@ func tryCreateTxThrottler(config *tabletenv.TabletConfig, topoServer *topo.Serve
 		topoServer:       topoServer,
 		throttlerConfig:  &throttlerConfig,
 		healthCheckCells: healthCheckCells,
+		maxWait:          config.TxThrottlerMaxWaitSeconds.Get(),
 	})
 }
 
@@ -140,6 +141,8 @@ type txThrottlerConfig struct {
 	// healthCheckCells stores the cell names in which running vttablets will be monitored for
 	// replication lag.
 	healthCheckCells []string
+	// maxWait is how long ThrottleWithWait retries before giving up, 0 means no retry.
+	maxWait time.Duration
 }
 
 // ThrottlerInterface defines the public interface that is implemented by go/vt/throttler.Throttler
@@ -209,6 +212,9 @@ func resetTxThrottlerFactories() {
 // go/vt/throttler.GlobalManager.
 const TxThrottlerName = "TransactionThrottler"
 
+// throttleRetryInitialBackoff is the first backoff of ThrottleWithWait, it doubles on each retry.
+const throttleRetryInitialBackoff = 10 * time.Millisecond
+
 func newTxThrottler(config *txThrottlerConfig) (*TxThrottler, error) {
 	if config.enabled {
 		// Verify config.
@@ -268,6 +274,38 @@ func (t *TxThrottler) Throttle() (result bool) {
 	return t.state.throttle()
 }
 
+// ThrottleWithWait is like Throttle, but when the transaction is throttled it
+// retries with an exponential backoff, up to the configured max wait, before
+// returning true. It returns true right away if the max wait is 0 or ctx is done.
+func (t *TxThrottler) ThrottleWithWait(ctx context.Context) bool {
+	if !t.Throttle() {
+		return false
+	}
+	if t.config.maxWait <= 0 {
+		return true
+	}
+	deadline := time.Now().Add(t.config.maxWait)
+	backoff := throttleRetryInitialBackoff
+	for {
+		remaining := time.Until(deadline)
+		if remaining <= 0 {
+			return true
+		}
+		if backoff > remaining {
+			backoff = remaining
+		}
+		select {
+		case <-ctx.Done():
+			return true
+		case <-time.After(backoff):
+		}
+		if !t.Throttle() {
+			return false
+		}
+		backoff *= 2
+	}
+}
+
 func newTxThrottlerState(config *txThrottlerConfig, keyspace, shard, cell string) (*txThrottlerState, error) {
 	t, err := throttlerFactory(
 		TxThrottlerName,
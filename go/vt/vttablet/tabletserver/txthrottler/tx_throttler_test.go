@@ -22,6 +22,7 @@ package txthrottler
 //go:generate mockgen -destination mock_topology_watcher_test.go -package txthrottler vitess.io/vitess/go/vt/vttablet/tabletserver/txthrottler TopologyWatcherInterface
 
 import (
+	"context"
 	"testing"
 	"time"
 
@@ -144,3 +145,79 @@ func TestEnabledThrottler(t *testing.T) {
 	}
 	throttler.Close()
 }
+
+func TestThrottlerWithWait(t *testing.T) {
+	mockCtrl := gomock.NewController(t)
+	defer mockCtrl.Finish()
+
+	defer resetTxThrottlerFactories()
+	ts := memorytopo.NewServer("cell1")
+
+	mockHealthCheck := NewMockHealthCheck(mockCtrl)
+	mockHealthCheck.EXPECT().Subscribe().AnyTimes()
+	mockHealthCheck.EXPECT().Close().AnyTimes()
+	healthCheckFactory = func(topoServer *topo.Server, cell string, cellsToWatch []string) discovery.HealthCheck {
+		return mockHealthCheck
+	}
+	mockThrottler := NewMockThrottlerInterface(mockCtrl)
+	throttlerFactory = func(name, unit string, threadCount int, maxRate, maxReplicationLag int64) (ThrottlerInterface, error) {
+		return mockThrottler, nil
+	}
+	mockThrottler.EXPECT().UpdateConfiguration(gomock.Any(), true /* copyZeroValues */).AnyTimes()
+	mockThrottler.EXPECT().Close().AnyTimes()
+	// The transactions are throttled until clearTime.
+	var clearTime time.Time
+	mockThrottler.EXPECT().Throttle(0).DoAndReturn(func(threadID int) time.Duration {
+		if time.Now().Before(clearTime) {
+			return 1 * time.Second
+		}
+		return 0
+	}).AnyTimes()
+
+	config := tabletenv.NewDefaultConfig()
+	config.EnableTxThrottler = true
+	config.TxThrottlerHealthCheckCells = []string{"cell1"}
+
+	// By default, a throttled transaction is rejected right away.
+	throttler, err := tryCreateTxThrottler(config, ts)
+	if err != nil {
+		t.Fatalf("want: nil, got: %v", err)
+	}
+	throttler.InitDBConfig(&querypb.Target{Keyspace: "keyspace", Shard: "shard"})
+	if err := throttler.Open(); err != nil {
+		t.Fatalf("want: nil, got: %v", err)
+	}
+	clearTime = time.Now().Add(time.Hour)
+	if result := throttler.ThrottleWithWait(context.Background()); result != true {
+		t.Errorf("want: true, got: %v", result)
+	}
+	throttler.Close()
+
+	// With a max wait, the throttler is retried until it clears.
+	config.TxThrottlerMaxWaitSeconds = 2
+	throttler, err = tryCreateTxThrottler(config, ts)
+	if err != nil {
+		t.Fatalf("want: nil, got: %v", err)
+	}
+	throttler.InitDBConfig(&querypb.Target{Keyspace: "keyspace", Shard: "shard"})
+	if err := throttler.Open(); err != nil {
+		t.Fatalf("want: nil, got: %v", err)
+	}
+	start := time.Now()
+	clearTime = start.Add(100 * time.Millisecond)
+	if result := throttler.ThrottleWithWait(context.Background()); result != false {
+		t.Errorf("want: false, got: %v", result)
+	}
+	if elapsed := time.Since(start); elapsed >= 2*time.Second {
+		t.Errorf("want: less than the max wait, got: %v", elapsed)
+	}
+
+	// The wait gives up when the context is done.
+	clearTime = time.Now().Add(time.Hour)
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if result := throttler.ThrottleWithWait(ctx); result != true {
+		t.Errorf("want: true, got: %v", result)
+	}
+	throttler.Close()
+}
@@ -166,6 +166,9 @@ type TabletManagerClient interface {
 	// PrimaryPosition returns the tablet's primary position
 	PrimaryPosition(ctx context.Context, tablet *topodatapb.Tablet) (string, error)
 
+	// FlushBinaryLogs rotates the binary logs of a primary tablet and returns its position after the rotation
+	FlushBinaryLogs(ctx context.Context, tablet *topodatapb.Tablet) (string, error)
+
 	// WaitForPosition waits for the position to be reached
 	WaitForPosition(ctx context.Context, tablet *topodatapb.Tablet, pos string) error
 
@@ -220,6 +223,11 @@ type TabletManagerClient interface {
 	// reparent_journal table (if timeCreatedNS is non-zero).
 	SetReplicationSource(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias, timeCreatedNS int64, waitPosition string, forceStartReplication bool, semiSync bool) error
 
+	// SetReplicationSourceWithoutStart tells a tablet to replicate from the
+	// passed in tablet alias, and leaves its replication stopped,
+	// e.g. to stage a replica before a cutover.
+	SetReplicationSourceWithoutStart(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias, semiSync bool) error
+
 	// ReplicaWasRestarted tells the replica tablet its primary has changed
 	ReplicaWasRestarted(ctx context.Context, tablet *topodatapb.Tablet, parent *topodatapb.TabletAlias) error
 
@@ -806,6 +806,23 @@ func tmRPCTestPrimaryPositionPanic(ctx context.Context, t *testing.T, client tmc
 	expectHandleRPCPanic(t, "PrimaryPosition", false /*verbose*/, err)
 }
 
+func (fra *fakeRPCTM) FlushBinaryLogs(ctx context.Context) (string, error) {
+	if fra.panics {
+		panic(fmt.Errorf("test-triggered panic"))
+	}
+	return testReplicationPosition, nil
+}
+
+func tmRPCTestFlushBinaryLogs(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.Tablet) {
+	rs, err := client.FlushBinaryLogs(ctx, tablet)
+	compareError(t, "FlushBinaryLogs", err, rs, testReplicationPosition)
+}
+
+func tmRPCTestFlushBinaryLogsPanic(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.Tablet) {
+	_, err := client.FlushBinaryLogs(ctx, tablet)
+	expectHandleRPCPanic(t, "FlushBinaryLogs", true /*verbose*/, err)
+}
+
 var testStopReplicationCalled = false
 
 func (fra *fakeRPCTM) StopReplication(ctx context.Context) error {
@@ -1152,6 +1169,28 @@ func tmRPCTestSetReplicationSourcePanic(ctx context.Context, t *testing.T, clien
 	expectHandleRPCPanic(t, "SetReplicationSource", true /*verbose*/, err)
 }
 
+var testSetReplicationSourceWithoutStartCalled = false
+
+func (fra *fakeRPCTM) SetReplicationSourceWithoutStart(ctx context.Context, parent *topodatapb.TabletAlias, semiSync bool) error {
+	if fra.panics {
+		panic(fmt.Errorf("test-triggered panic"))
+	}
+	compare(fra.t, "SetReplicationSourceWithoutStart parent", parent, testPrimaryAlias)
+	compare(fra.t, "SetReplicationSourceWithoutStart semiSync", semiSync, true)
+	testSetReplicationSourceWithoutStartCalled = true
+	return nil
+}
+
+func tmRPCTestSetReplicationSourceWithoutStart(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.Tablet) {
+	err := client.SetReplicationSourceWithoutStart(ctx, tablet, testPrimaryAlias, true)
+	compareError(t, "SetReplicationSourceWithoutStart", err, true, testSetReplicationSourceWithoutStartCalled)
+}
+
+func tmRPCTestSetReplicationSourceWithoutStartPanic(ctx context.Context, t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.Tablet) {
+	err := client.SetReplicationSourceWithoutStart(ctx, tablet, testPrimaryAlias, true)
+	expectHandleRPCPanic(t, "SetReplicationSource", true /*verbose*/, err)
+}
+
 func (fra *fakeRPCTM) StopReplicationAndGetStatus(ctx context.Context, stopReplicationMode replicationdatapb.StopReplicationMode) (tabletmanager.StopReplicationAndGetStatusResponse, error) {
 	if fra.panics {
 		panic(fmt.Errorf("test-triggered panic"))
@@ -1343,6 +1382,7 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.T
 	tmRPCTestReplicationStatus(ctx, t, client, tablet)
 	tmRPCTestFullStatus(ctx, t, client, tablet)
 	tmRPCTestPrimaryPosition(ctx, t, client, tablet)
+	tmRPCTestFlushBinaryLogs(ctx, t, client, tablet)
 	tmRPCTestStopReplication(ctx, t, client, tablet)
 	tmRPCTestStopReplicationMinimum(ctx, t, client, tablet)
 	tmRPCTestStartReplication(ctx, t, client, tablet)
@@ -1360,6 +1400,7 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.T
 	tmRPCTestDemotePrimary(ctx, t, client, tablet)
 	tmRPCTestUndoDemotePrimary(ctx, t, client, tablet)
 	tmRPCTestSetReplicationSource(ctx, t, client, tablet)
+	tmRPCTestSetReplicationSourceWithoutStart(ctx, t, client, tablet)
 	tmRPCTestStopReplicationAndGetStatus(ctx, t, client, tablet)
 	tmRPCTestPromoteReplica(ctx, t, client, tablet)
 
@@ -1396,6 +1437,7 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.T
 
 	// Replication related methods
 	tmRPCTestPrimaryPositionPanic(ctx, t, client, tablet)
+	tmRPCTestFlushBinaryLogsPanic(ctx, t, client, tablet)
 	tmRPCTestReplicationStatusPanic(ctx, t, client, tablet)
 	tmRPCTestFullStatusPanic(ctx, t, client, tablet)
 	tmRPCTestStopReplicationPanic(ctx, t, client, tablet)
@@ -1413,6 +1455,7 @@ func Run(t *testing.T, client tmclient.TabletManagerClient, tablet *topodatapb.T
 	tmRPCTestDemotePrimaryPanic(ctx, t, client, tablet)
 	tmRPCTestUndoDemotePrimaryPanic(ctx, t, client, tablet)
 	tmRPCTestSetReplicationSourcePanic(ctx, t, client, tablet)
+	tmRPCTestSetReplicationSourceWithoutStartPanic(ctx, t, client, tablet)
 	tmRPCTestStopReplicationAndGetStatusPanic(ctx, t, client, tablet)
 	tmRPCTestPromoteReplicaPanic(ctx, t, client, tablet)
 